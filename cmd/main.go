@@ -5,9 +5,12 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,8 +20,20 @@ import (
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/grpcapi"
 	"alimpay-go/internal/handler"
 	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/botfilter"
+	"alimpay-go/internal/pkg/cache"
+	"alimpay-go/internal/pkg/captcha"
+	"alimpay-go/internal/pkg/geoip"
+	"alimpay-go/internal/pkg/httpclient"
+	"alimpay-go/internal/pkg/listen"
+	"alimpay-go/internal/pkg/lock"
+	"alimpay-go/internal/pkg/notifier"
+	approuter "alimpay-go/internal/router"
 	"alimpay-go/internal/service"
 	"alimpay-go/internal/pkg/logger"
 	"alimpay-go/internal/web"
@@ -27,6 +42,16 @@ import (
 	"go.uber.org/zap"
 )
 
+// 构建信息，编译时通过 -ldflags "-X main.Version=..." 注入
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// websocketDrainTimeout 平滑升级后等待WebSocket客户端迁移到新进程的最长时间（包含在 server.shutdown_timeout 内）
+const websocketDrainTimeout = 5 * time.Second
+
 func main() {
 	// 设置全局时区为北京时间（和PHP版本保持一致）
 	loc, err := time.LoadLocation("Asia/Shanghai")
@@ -38,6 +63,7 @@ func main() {
 
 	// 解析命令行参数
 	configPath := flag.String("config", "./configs/config.yaml", "Path to configuration file")
+	migrateOnly := flag.Bool("migrate", false, "Upgrade the database schema and exit")
 	flag.Parse()
 
 	// 加载配置
@@ -72,9 +98,39 @@ func main() {
 
 	// 美化的启动信息
 	logger.Highlight("AliMPay Golang Version Starting",
-		zap.String("version", "1.0.0"),
+		zap.String("version", Version),
+		zap.String("commit", Commit),
+		zap.String("build_time", BuildTime),
 		zap.String("config", *configPath),
 		zap.String("timezone", "Asia/Shanghai"))
+	if overrides := cfg.EnvOverrides(); len(overrides) > 0 {
+		logger.Info("Config overridden by environment variables", zap.Strings("variables", overrides))
+	}
+
+	// 初始化出站HTTP客户端（支付宝网关、商户通知、在线二维码API共享连接池和代理设置）
+	if err := httpclient.Init(cfg.HTTPClient.Options()); err != nil {
+		logger.Fatal("Failed to initialize HTTP client", zap.Error(err))
+	}
+	for destination, proxy := range httpclient.Proxies() {
+		if proxy != "environment" && proxy != httpclient.ProxyNone {
+			logger.Info("Outbound requests use proxy", zap.String("destination", destination), zap.String("proxy", proxy))
+		}
+	}
+	for destination, address := range httpclient.LocalAddresses() {
+		logger.Info("Outbound requests use local address", zap.String("destination", destination), zap.String("local_address", address))
+	}
+
+	// 加载离线IP库（可选，用于买家和登录IP归属地）
+	if cfg.GeoIP.Enabled {
+		geoDB, err := geoip.Open(cfg.GeoIP.Database)
+		if err != nil {
+			logger.Fatal("Failed to load geoip database", zap.Error(err))
+		}
+		geoip.SetDefault(geoDB)
+		logger.Info("GeoIP database loaded",
+			zap.String("path", cfg.GeoIP.Database),
+			zap.Int("ranges", geoDB.Len()))
+	}
 
 	// 初始化数据库
 	dbCfg := &database.Config{
@@ -83,6 +139,13 @@ func main() {
 		MaxIdleConns:    cfg.Database.MaxIdleConns,
 		MaxOpenConns:    cfg.Database.MaxOpenConns,
 		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		SchemaUpgrade:   cfg.Database.SchemaUpgrade,
+		AppVersion:      Version,
+	}
+
+	// -migrate 只升级数据库结构后退出（滚动升级时由单个实例先执行）
+	if *migrateOnly {
+		dbCfg.SchemaUpgrade = database.SchemaUpgradeAuto
 	}
 
 	db, err := database.Init(dbCfg)
@@ -91,28 +154,189 @@ func main() {
 	}
 	defer db.Close()
 
+	if *migrateOnly {
+		logger.Success("Database schema is up to date", zap.Int("schema_version", database.SchemaVersion))
+		return
+	}
+
+	// 初始化Redis（可选，不可用时降级为无缓存模式）
+	var redisCache *cache.RedisCache
+	if cfg.Redis.Enabled {
+		redisCache, err = cache.NewRedisCache(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+		if err != nil {
+			logger.Warn("Redis unavailable, running without cache",
+				zap.String("addr", cfg.Redis.Addr),
+				zap.Error(err))
+		} else {
+			defer redisCache.Close()
+			db.SetOrderCache(redisCache, time.Duration(cfg.Redis.OrderCacheTTL)*time.Second)
+		}
+	}
+
 	// 初始化服务
 	codepayService, err := service.NewCodePayService(cfg, db)
 	if err != nil {
 		logger.Fatal("Failed to initialize CodePay service", zap.Error(err))
 	}
 
+	// 收款码管理（合并管理后台保存的设置，修改后无需重启）
+	qrCodeManager, err := service.NewQRCodeManager(cfg, db, codepayService.GetQRCodeSelector())
+	if err != nil {
+		logger.Fatal("Failed to initialize QR code manager", zap.Error(err))
+	}
+
 	monitorService, err := service.NewMonitorService(cfg, db, codepayService)
 	if err != nil {
 		logger.Fatal("Failed to initialize Monitor service", zap.Error(err))
 	}
 
+	// 压测模式：生成模拟订单和账单，不调用支付宝接口（仅用于容量评估，勿在生产环境开启）
+	var loadTestService *service.LoadTestService
+	if cfg.LoadTest.Enabled {
+		loadTestService = service.NewLoadTestService(cfg, db, codepayService, monitorService)
+		logger.Warn("Load test mode is enabled, do not use in production")
+	}
+
+	// 多实例部署时通过Redis同步订单事件，使WebSocket推送覆盖所有节点
+	if cfg.Redis.ClusterBroadcast && redisCache.IsAvailable() {
+		eventBridge := events.NewRedisBridge(redisCache.Client(), cfg.Redis.EventChannel)
+		eventBridge.Start()
+		defer eventBridge.Stop()
+	}
+
+	// 多实例部署时使用Redis分布式锁，保证集群内只有一个实例执行监听周期
+	if cfg.Monitor.LockBackend == "redis" {
+		if redisCache.IsAvailable() {
+			monitorService.SetLocker(lock.NewRedisLock(
+				redisCache.Client(),
+				"alimpay:lock:monitor",
+				time.Duration(cfg.Monitor.LockTimeout)*time.Second,
+			))
+			logger.Info("Monitor using redis distributed lock")
+		} else {
+			logger.Warn("Redis lock backend requested but redis is unavailable, falling back to file lock")
+		}
+	}
+
+	// 多实例部署时选举主节点，定时任务（监听周期、自动回调、通知地址健康检查、订单归档）只在主节点执行
+	var leaderElector *service.LeaderElector
+	if cfg.Cluster.LeaderElection {
+		instanceID := cfg.Cluster.InstanceName()
+		leaseTTL := time.Duration(cfg.Cluster.LeaseTTL) * time.Second
+
+		var lease lock.Lease = lock.NewFileLease("./data/leader.lease", instanceID, leaseTTL)
+		if cfg.Monitor.LockBackend == "redis" && redisCache.IsAvailable() {
+			lease = lock.NewRedisLease(redisCache.Client(), "alimpay:leader", instanceID, leaseTTL)
+		}
+
+		leaderElector = service.NewLeaderElector(lease, instanceID, leaseTTL)
+		leaderElector.Start()
+		defer leaderElector.Stop()
+		monitorService.SetLeaderElector(leaderElector)
+	}
+
 	// 启动监控服务
 	if err := monitorService.Start(); err != nil {
 		logger.Fatal("Failed to start monitor service", zap.Error(err))
 	}
 	defer monitorService.Stop()
 
+	// 维护任务（清理、对账、统计汇总、备份按各自的cron表达式在监听调度器上执行）
+	housekeeping := service.NewHousekeeping(&cfg.Housekeeping, db, codepayService, monitorService)
+	housekeeping.SetLeaderElector(leaderElector)
+	if err := housekeeping.Start(); err != nil {
+		logger.Fatal("Failed to schedule housekeeping jobs", zap.Error(err))
+	}
+
+	// 监听配置文件变化，运行时应用可安全调整的配置项（无需重启）
+	configReloader := service.NewConfigReloader(*configPath, cfg, monitorService, codepayService, qrCodeManager)
+	if err := configReloader.Start(); err != nil {
+		logger.Warn("Config hot reload is unavailable", zap.Error(err))
+	}
+	defer configReloader.Stop()
+
 	// 启动自动回调服务
 	autoCallback := service.NewAutoCallbackService(db, codepayService)
+	autoCallback.SetLeaderElector(leaderElector)
 	autoCallback.Start()
 	defer autoCallback.Stop()
 
+	// 启动商户通知地址健康检查
+	var notifyHealth *service.NotifyHealthChecker
+	if cfg.NotifyHealth.Enabled {
+		notifyHealth = service.NewNotifyHealthChecker(db,
+			time.Duration(cfg.NotifyHealth.Interval)*time.Second,
+			time.Duration(cfg.NotifyHealth.Timeout)*time.Second,
+			time.Duration(cfg.NotifyHealth.LookbackDays)*24*time.Hour,
+		)
+		notifyHealth.SetLeaderElector(leaderElector)
+		notifyHealth.Start()
+		defer notifyHealth.Stop()
+	}
+
+	// 运营通知渠道（日报、收款码告警）
+	channels := make([]notifier.Channel, 0, len(cfg.Notifier.Channels))
+	for i, channelCfg := range cfg.Notifier.Channels {
+		channel, err := notifier.New(channelCfg.Type, notifier.Options{
+			URL:    channelCfg.URL,
+			Secret: channelCfg.Secret,
+			Client: httpclient.For(httpclient.Notifier),
+		})
+		if err != nil {
+			logger.Fatal("Failed to create notifier channel", zap.Int("index", i), zap.Error(err))
+		}
+		channels = append(channels, channel)
+	}
+	opsNotifier := notifier.NewNotifier(channels...)
+
+	// 收款码健康检查（经营码模式，不健康的收款码不再分配给新订单）
+	var qrCodeHealth *service.QRCodeHealthChecker
+	if cfg.QRCodeHealth.Enabled && codepayService.GetQRCodeSelector() != nil {
+		qrCodeHealth = service.NewQRCodeHealthChecker(&cfg.QRCodeHealth, db, codepayService.GetQRCodeSelector(),
+			time.Duration(cfg.Payment.OrderTimeout)*time.Second)
+		qrCodeHealth.SetMonitor(monitorService)
+		qrCodeHealth.SetNotifier(opsNotifier)
+		qrCodeHealth.SetLeaderElector(leaderElector)
+		codepayService.GetQRCodeSelector().SetHealthChecker(qrCodeHealth)
+		monitorService.SetQRCodeHealth(qrCodeHealth)
+		qrCodeHealth.Start()
+		defer qrCodeHealth.Stop()
+	}
+
+	// 订单归档（超过保留期的订单移入归档表）
+	var orderArchiver *service.OrderArchiver
+	if cfg.Archive.Enabled {
+		orderArchiver = service.NewOrderArchiver(db,
+			time.Duration(cfg.Archive.RetentionDays)*24*time.Hour,
+			time.Duration(cfg.Archive.Interval)*time.Second,
+			cfg.Archive.BatchSize,
+		)
+		orderArchiver.SetLeaderElector(leaderElector)
+		orderArchiver.Start()
+		defer orderArchiver.Stop()
+	}
+
+	// 事件发件箱（持久化订单事件，供外部消费者按序号补拉）
+	var eventOutbox *service.EventOutbox
+	if cfg.EventOutbox.Enabled {
+		eventOutbox = service.NewEventOutbox(db, time.Duration(cfg.EventOutbox.RetentionDays)*24*time.Hour)
+		eventOutbox.SetLeaderElector(leaderElector)
+		eventOutbox.Start()
+		defer eventOutbox.Stop()
+	}
+
+	// 运营日报（每天定时通过通知渠道发送前24小时的统计）
+	if cfg.DailyDigest.Enabled {
+		dailyDigest := service.NewDailyDigestService(db, opsNotifier, cfg.DailyDigest.Hour, cfg.DailyDigest.TopMerchants)
+		dailyDigest.SetMonitor(monitorService)
+		if notifyHealth != nil {
+			dailyDigest.SetNotifyHealth(notifyHealth)
+		}
+		dailyDigest.SetLeaderElector(leaderElector)
+		dailyDigest.Start()
+		defer dailyDigest.Stop()
+	}
+
 	// 初始化HTTP服务器
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -120,112 +344,267 @@ func main() {
 
 	// 使用自定义中间件（彩色日志）
 	router := gin.New()
-	router.Use(middleware.Recovery())
-	router.Use(middleware.Logger())
+	if len(cfg.Server.TrustedProxies) > 0 {
+		if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+			logger.Fatal("Invalid server.trusted_proxies", zap.Error(err))
+		}
+	}
+	redactor := newQueryRedactor(cfg.Logging)
+	router.Use(middleware.Recovery(db, redactor))
+	router.Use(middleware.Logger(redactor))
 	router.Use(middleware.PathNormalizer()) // 路径规范化，处理//submit等情况
 
-	// 从嵌入的文件系统加载HTML模板
-	tmpl := template.Must(template.New("").ParseFS(web.Templates, "templates/*.html"))
+	// 从嵌入的文件系统加载HTML模板，自定义模板目录中的同名模板优先
+	tmpl, customTemplates, err := web.ParseTemplates(cfg.Branding.TemplateDir, web.Branding{
+		SiteName:       cfg.Branding.SiteName,
+		LogoURL:        cfg.Branding.LogoURL,
+		PrimaryColor:   cfg.Branding.PrimaryColor,
+		Footer:         cfg.Branding.Footer,
+		SupportContact: cfg.Branding.SupportContact,
+	})
+	if err != nil {
+		logger.Fatal("Failed to parse templates", zap.Error(err))
+	}
 	router.SetHTMLTemplate(tmpl)
 
 	logger.Success("Templates loaded from embedded filesystem", zap.Int("count", len(tmpl.Templates())))
+	if len(customTemplates) > 0 {
+		logger.Info("Custom templates loaded",
+			zap.String("dir", cfg.Branding.TemplateDir),
+			zap.Strings("templates", customTemplates))
+	}
 
 	// 静态文件 - 使用嵌入的文件系统，并添加缓存控制
-	staticFS, err := web.GetStaticFS()
+	staticFS, err := web.GetFingerprintedStaticFS()
 	if err != nil {
 		logger.Fatal("Failed to get static filesystem", zap.Error(err))
 	}
 
-	// 静态资源路由组 - 添加长期缓存
+	// 静态资源路由组 - 带指纹的地址长期缓存，原始地址短期缓存
 	staticGroup := router.Group("/static")
-	staticGroup.Use(middleware.StaticCacheMiddleware())
+	staticGroup.Use(middleware.StaticCacheMiddleware(web.IsFingerprinted))
 	staticGroup.Use(middleware.CompressMiddleware())
 	staticGroup.StaticFS("/", http.FS(staticFS))
 
 	// 初始化handlers
 	apiHandler := handler.NewAPIHandler(codepayService, monitorService, cfg)
-	submitHandler := handler.NewSubmitHandler(codepayService, cfg)
+	submitHandler := handler.NewSubmitHandler(codepayService, cfg, qrCodeManager)
+	if cfg.SubmitCaptcha.Enabled {
+		submitCaptcha, err := captcha.New(cfg.SubmitCaptcha.Provider, captcha.Options{
+			SiteKey:   cfg.SubmitCaptcha.SiteKey,
+			SecretKey: cfg.SubmitCaptcha.SecretKey,
+			Client:    httpclient.For(httpclient.Captcha),
+		})
+		if err != nil {
+			logger.Fatal("Failed to create submit captcha", zap.Error(err))
+		}
+		submitHandler.SetCaptcha(submitCaptcha)
+		logger.Info("Submit captcha enabled",
+			zap.String("provider", submitCaptcha.Name()),
+			zap.Int("velocity_limit", cfg.SubmitCaptcha.VelocityLimit),
+			zap.Int("risky_ranges", len(cfg.SubmitCaptcha.RiskyRanges)))
+	}
+	if cfg.SubmitBotFilter.Enabled {
+		submitHandler.SetBotFilter(botfilter.New(cfg.SubmitBotFilter.Secret, cfg.SubmitBotFilter.Difficulty))
+		logger.Info("Submit bot filter enabled", zap.Int("difficulty", cfg.SubmitBotFilter.Difficulty))
+	}
 	healthHandler := handler.NewHealthHandler(db, codepayService, monitorService)
-	qrcodeHandler := handler.NewQRCodeHandler(cfg)
-	adminHandler := handler.NewAdminHandler(db, codepayService)
+	healthHandler.SetLeaderElector(leaderElector)
+	qrcodeHandler := handler.NewQRCodeHandler(cfg, qrCodeManager)
+	adminHandler := handler.NewAdminHandler(db, codepayService, cfg)
+	adminHandler.SetQRCodeManager(qrCodeManager)
+	adminHandler.SetMonitorService(monitorService)
+	adminHandler.SetHousekeeping(housekeeping)
+	if notifyHealth != nil {
+		adminHandler.SetNotifyHealthChecker(notifyHealth)
+	}
+	if orderArchiver != nil {
+		adminHandler.SetOrderArchiver(orderArchiver)
+	}
+	if qrCodeHealth != nil {
+		adminHandler.SetQRCodeHealthChecker(qrCodeHealth)
+	}
 	yipayHandler := handler.NewYiPayHandler(db, codepayService, cfg)
-	payHandler := handler.NewPayHandler(db, cfg)
-	wsHandler := handler.NewWebSocketHandler(db)
-	adminWsHandler := handler.NewAdminWebSocketHandler(db)
+	payHandler := handler.NewPayHandler(db, codepayService, cfg, qrCodeManager)
+	orderStatusHandler := handler.NewOrderStatusHandler(db)
+	alipayNotifyHandler := handler.NewAlipayNotifyHandler(codepayService)
+	wsHandler := handler.NewWebSocketHandler(db, cfg.WebSocket)
+	adminWsHandler := handler.NewAdminWebSocketHandler(db, cfg.WebSocket)
+	openAPIHandler := handler.NewOpenAPIHandler(cfg)
+	exportHandler := handler.NewExportHandler(db)
+	eventOutboxHandler := handler.NewEventOutboxHandler(eventOutbox)
+	var loadTestHandler *handler.LoadTestHandler
+	if loadTestService != nil {
+		loadTestHandler = handler.NewLoadTestHandler(loadTestService, monitorService, cfg)
+	}
+
+	// 初始化管理员认证中间件（会话存储于数据库或Redis时，重启、发布后无需重新登录）
+	var sessionStore middleware.SessionStore
+	switch cfg.Admin.SessionStore {
+	case middleware.SessionStoreDatabase:
+		sessionStore = db
+	case middleware.SessionStoreRedis:
+		if redisCache.IsAvailable() {
+			sessionStore = middleware.NewRedisSessionStore(redisCache.Client(), "alimpay:admin")
+		} else {
+			logger.Warn("Redis session store requested but redis is unavailable, falling back to database")
+			sessionStore = db
+		}
+	}
 
-	// 初始化管理员认证中间件
 	merchantInfo := codepayService.GetMerchantInfo()
-	adminAuth := middleware.NewAdminAuthMiddleware(
+	adminAuth, err := middleware.NewAdminAuthMiddleware(
 		merchantInfo["id"].(string),
 		merchantInfo["key"].(string),
+		db,
+		sessionStore,
+		middleware.SessionOptions{
+			Lifetime:         time.Duration(cfg.Admin.SessionLifetime) * time.Second,
+			IdleTimeout:      time.Duration(cfg.Admin.IdleTimeout) * time.Second,
+			RememberLifetime: time.Duration(cfg.Admin.RememberLifetime) * time.Second,
+		},
 	)
+	if err != nil {
+		logger.Fatal("Failed to initialize admin auth middleware", zap.Error(err))
+	}
+	adminAuth.SetLoginGuard(middleware.NewLoginGuard(middleware.LoginGuardOptions{
+		MaxAttempts:  cfg.Admin.LoginMaxAttempts,
+		Lockout:      time.Duration(cfg.Admin.LoginLockout) * time.Second,
+		CaptchaAfter: cfg.Admin.LoginCaptchaAfter,
+	}))
+	adminAuth.SetUserStore(db)
+	adminAuth.SetTokenStore(db)
+	adminAuth.SetTwoFactorStore(db)
+
+	// 初始化商户认证中间件（公开API，商户密钥轮换的过渡期内旧密钥仍可使用）
+	previousKey, previousKeyExpiry := codepayService.PreviousMerchantKey()
+	merchantAuth := middleware.NewMerchantAuth(middleware.MerchantCredential{
+		ID:                     merchantInfo["id"].(string),
+		Key:                    merchantInfo["key"].(string),
+		PreviousKey:            previousKey,
+		PreviousKeyExpiry:      previousKeyExpiry,
+		Methods:                cfg.Merchant.AuthMethods,
+		APITokens:              cfg.Merchant.APITokens,
+		ClientCertFingerprints: cfg.Merchant.ClientCertFingerprints,
+		AllowedIPs:             cfg.Merchant.AllowedIPs,
+		SignType:               cfg.Merchant.SignType,
+	})
+
+	// 已签发的沙箱凭据（管理后台重新签发时由 adminHandler 更新）
+	if sandboxID, sandboxKey := codepayService.SandboxCredentials(); sandboxKey != "" {
+		merchantAuth.SetCredential(middleware.MerchantCredential{ID: sandboxID, Key: sandboxKey, SignType: cfg.Merchant.SignType})
+	}
+	adminHandler.SetMerchantAuth(merchantAuth)
+	adminHandler.SetAdminAuth(adminAuth)
+
+	// 初始化限流中间件（未启用时为空操作）
+	var rateLimit gin.HandlerFunc = func(c *gin.Context) { c.Next() }
+	if cfg.RateLimit.Enabled {
+		rateLimit = middleware.NewRateLimiter(middleware.RateLimitOptions{
+			IPRate:        cfg.RateLimit.IPRate,
+			IPBurst:       cfg.RateLimit.IPBurst,
+			MerchantRate:  cfg.RateLimit.MerchantRate,
+			MerchantBurst: cfg.RateLimit.MerchantBurst,
+			KnownMerchant: merchantAuth.HasMerchant,
+		}).Limit()
+	}
+
+	// 二维码图片按IP软限流：短暂超限的请求排队等待，持续刷新的请求返回429
+	var qrcodeRateLimit gin.HandlerFunc = func(c *gin.Context) { c.Next() }
+	if cfg.RateLimit.Enabled {
+		qrcodeRateLimit = middleware.NewRateLimiter(middleware.RateLimitOptions{
+			IPRate:  cfg.RateLimit.QRCodeIPRate,
+			IPBurst: cfg.RateLimit.QRCodeIPBurst,
+			MaxWait: time.Duration(cfg.RateLimit.QRCodeMaxWait) * time.Millisecond,
+		}).Limit()
+	}
+
+	// 审计日志（管理操作及敏感接口）
+	audit := middleware.NewAuditTrail(db, redactor)
+
+	// 商户来源IP白名单（/submit 通常由买家浏览器跳转访问，只在 merchant.allowed_ips_submit 开启时检查）
+	restrictIPs := merchantAuth.RestrictIPs(len(cfg.Server.TrustedProxies) > 0)
+	var submitRestrictIPs gin.HandlerFunc = func(c *gin.Context) { c.Next() }
+	if cfg.Merchant.AllowedIPsSubmit {
+		submitRestrictIPs = restrictIPs
+	}
 
 	// 注册路由 - 易支付/码支付标准接口
+	// RegisterCompat 会同时注册 GET/POST（.php 后缀由 StripExtension 统一处理）
+	// JSONBody 使接口同时接受 application/json 请求体
+
+	// API接口（兼容模式）
+	approuter.RegisterCompat(router, "/api", rateLimit, restrictIPs, merchantAuth.Authenticate(), apiHandler.HandleAction)
+
+	// MAPI接口（码支付标准）
+	approuter.RegisterCompat(router, "/mapi", middleware.JSONBody(), rateLimit, restrictIPs, merchantAuth.Authenticate(), yipayHandler.HandleMAPI)
+
+	// Submit接口（创建支付）
+	approuter.RegisterCompat(router, "/submit", rateLimit, submitRestrictIPs, submitHandler.HandleSubmit)
+
+	// API提交接口（易支付标准）
+	approuter.RegisterCompat(router, "/api/submit", middleware.JSONBody(), rateLimit, restrictIPs, yipayHandler.HandleSubmitAPI)
+
+	// 查询接口
+	approuter.RegisterCompat(router, "/api/query", rateLimit, restrictIPs, merchantAuth.Require(), yipayHandler.HandleQueryMerchant)
+	approuter.RegisterCompat(router, "/api/order", rateLimit, restrictIPs, yipayHandler.HandleQueryOrder)
+	router.GET("/api/pay/order", rateLimit, payHandler.HandleOrderView)         // 支付页面数据（按系统交易号查询）
+	router.GET("/api/order/status", rateLimit, orderStatusHandler.HandleStatus) // 订单状态轮询（WebSocket降级，支持ETag）
+	router.GET("/badge/order/:file", rateLimit, orderStatusHandler.HandleBadge) // 订单状态徽章（/badge/order/<trade_no>.svg）
+
+	// 订单管理
+	approuter.RegisterCompat(router, "/api/close", rateLimit, restrictIPs, merchantAuth.Require(), audit.Record("order.close"), yipayHandler.HandleClose)
+	approuter.RegisterCompat(router, "/api/refund", restrictIPs, yipayHandler.HandleRefund)
+
+	// 退款申请（进入管理后台审核队列，商户按 refund_no 轮询审核结果）
+	approuter.RegisterCompat(router, "/api/refund_request", middleware.JSONBody(), rateLimit, restrictIPs, merchantAuth.Require(), audit.Record("refund.request"), yipayHandler.HandleRefundRequest)
+	approuter.RegisterCompat(router, "/api/refund_query", rateLimit, restrictIPs, merchantAuth.Require(), yipayHandler.HandleRefundQuery)
 
-	// API接口（兼容模式） - 支持.php后缀
-	router.GET("/api", apiHandler.HandleAction)
-	router.POST("/api", apiHandler.HandleAction)
-	router.GET("/api.php", apiHandler.HandleAction)
-	router.POST("/api.php", apiHandler.HandleAction)
-
-	// MAPI接口（码支付标准） - 支持.php后缀
-	router.GET("/mapi", yipayHandler.HandleMAPI)
-	router.POST("/mapi", yipayHandler.HandleMAPI)
-	router.GET("/mapi.php", yipayHandler.HandleMAPI)
-	router.POST("/mapi.php", yipayHandler.HandleMAPI)
-
-	// Submit接口（创建支付） - 支持.php后缀
-	router.GET("/submit", submitHandler.HandleSubmit)
-	router.POST("/submit", submitHandler.HandleSubmit)
-	router.GET("/submit.php", submitHandler.HandleSubmit)
-	router.POST("/submit.php", submitHandler.HandleSubmit)
-
-	// API提交接口（易支付标准） - 支持.php后缀
-	router.GET("/api/submit", yipayHandler.HandleSubmitAPI)
-	router.POST("/api/submit", yipayHandler.HandleSubmitAPI)
-	router.GET("/api/submit.php", yipayHandler.HandleSubmitAPI)
-	router.POST("/api/submit.php", yipayHandler.HandleSubmitAPI)
-
-	// 查询接口 - 支持.php后缀
-	router.GET("/api/query", yipayHandler.HandleQueryMerchant)
-	router.POST("/api/query", yipayHandler.HandleQueryMerchant)
-	router.GET("/api/query.php", yipayHandler.HandleQueryMerchant)
-	router.POST("/api/query.php", yipayHandler.HandleQueryMerchant)
-	router.GET("/api/order", yipayHandler.HandleQueryOrder)
-	router.POST("/api/order", yipayHandler.HandleQueryOrder)
-	router.GET("/api/order.php", yipayHandler.HandleQueryOrder)
-	router.POST("/api/order.php", yipayHandler.HandleQueryOrder)
-
-	// 订单管理 - 支持.php后缀
-	router.GET("/api/close", yipayHandler.HandleClose)
-	router.POST("/api/close", yipayHandler.HandleClose)
-	router.GET("/api/close.php", yipayHandler.HandleClose)
-	router.POST("/api/close.php", yipayHandler.HandleClose)
-	router.GET("/api/refund", yipayHandler.HandleRefund)
-	router.POST("/api/refund", yipayHandler.HandleRefund)
-	router.GET("/api/refund.php", yipayHandler.HandleRefund)
-	router.POST("/api/refund.php", yipayHandler.HandleRefund)
-
-	// 回调接口 - 支持.php后缀
-	router.GET("/notify", yipayHandler.HandleCallback)
-	router.POST("/notify", yipayHandler.HandleCallback)
-	router.GET("/notify.php", yipayHandler.HandleCallback)
-	router.POST("/notify.php", yipayHandler.HandleCallback)
-	router.GET("/callback", yipayHandler.HandleCallback)
-	router.POST("/callback", yipayHandler.HandleCallback)
-	router.GET("/callback.php", yipayHandler.HandleCallback)
-	router.POST("/callback.php", yipayHandler.HandleCallback)
-
-	// 签名验证接口 - 支持.php后缀
-	router.GET("/api/checksign", yipayHandler.HandleCheckSign)
-	router.POST("/api/checksign", yipayHandler.HandleCheckSign)
-	router.GET("/api/checksign.php", yipayHandler.HandleCheckSign)
-	router.POST("/api/checksign.php", yipayHandler.HandleCheckSign)
+	// 争议订单（用户拒付或投诉，冻结通知并从收入统计中排除）
+	approuter.RegisterCompat(router, "/api/dispute", middleware.JSONBody(), rateLimit, restrictIPs, merchantAuth.Require(), audit.Record("order.dispute"), yipayHandler.HandleDispute)
+
+	// 回调接口
+	approuter.RegisterCompat(router, "/notify", yipayHandler.HandleCallback)
+	approuter.RegisterCompat(router, "/callback", yipayHandler.HandleCallback)
+
+	// 签名验证接口
+	approuter.RegisterCompat(router, "/api/checksign", yipayHandler.HandleCheckSign)
+
+	// 支付结果通知验证（商户服务端提交收到的通知参数，确认签名和订单信息）
+	approuter.RegisterCompat(router, "/api/verify_notify", middleware.JSONBody(), rateLimit, restrictIPs, merchantAuth.Require(), yipayHandler.HandleVerifyNotify)
+
+	// 通知来源信息（出口IP和HMAC签名请求头，商户配置白名单和验签）
+	router.GET("/api/notify/ips", rateLimit, yipayHandler.HandleNotifyIPs)
+
+	// 审计日志与交易流水导出（NDJSON）
+	router.GET("/api/export/audit", restrictIPs, merchantAuth.Require(), audit.Record("audit.export"), exportHandler.HandleAuditLog)
+	router.GET("/api/export/trades", restrictIPs, merchantAuth.Require(), audit.Record("trades.export"), exportHandler.HandleTradeJournal)
+
+	// 事件发件箱补拉（外部消费者按序号恢复错过的订单事件）
+	router.GET("/api/events", restrictIPs, merchantAuth.Require(), eventOutboxHandler.HandleReplay)
 
 	// 系统接口
 	router.GET("/health", healthHandler.HandleHealth)
-	router.GET("/qrcode", qrcodeHandler.HandleQRCode)
-	router.GET("/pay", payHandler.HandlePayPage) // 支付页面（扫码后跳转）
+	router.GET("/metrics", healthHandler.HandleMetrics)
+	router.GET("/qrcode", qrcodeRateLimit, qrcodeHandler.HandleQRCode)
+	router.GET("/pay", payHandler.HandlePayPage)           // 支付页面（扫码后跳转）
+	router.GET("/pay/return", payHandler.HandleReturn)     // 支付完成后跳转回商户页面
+	router.GET("/openapi.json", openAPIHandler.HandleSpec) // OpenAPI 3.0 接口文档
+	if loadTestHandler != nil {
+		router.Any(service.LoadTestNotifyPath, loadTestHandler.HandleNotify) // 压测订单通知接收端
+	}
+
+	// 线下打印收款码短链接（首次扫码时创建订单并跳转支付页面）
+	router.GET("/s/:code", rateLimit, payHandler.HandlePrintedCode)
+
+	// 沙箱订单模拟支付（添加模拟账单后跳转回支付页面）
+	router.POST("/pay/sandbox", rateLimit, payHandler.HandleSandboxPay)
+
+	// 支付页面Service Worker（需从根路径提供，作用域覆盖 /pay、/submit）
+	router.GET("/pay-sw.js", payHandler.HandleServiceWorker)
+
+	// 支付宝异步通知（当面付、手机网站支付）
+	router.POST(service.AlipayNotifyPath, alipayNotifyHandler.HandleNotify)
 
 	// WebSocket接口 - 实时订单状态推送（用户支付页面）
 	router.GET("/ws/order", wsHandler.HandleWebSocket)
@@ -236,29 +615,135 @@ func main() {
 
 	// 公开路由 - 登录/登出（无需认证）
 	router.GET("/admin/login", adminAuth.HandleLogin)
-	router.POST("/admin/login", adminAuth.HandleLogin)
-	router.GET("/admin/logout", adminAuth.HandleLogout)
+	router.POST("/admin/login", audit.Record("admin.login"), adminAuth.HandleLogin)
+	router.GET("/admin/logout", audit.Record("admin.logout"), adminAuth.HandleLogout)
 
 	// 受保护路由组 - 所有 /admin/* 路由都需要认证（全局路由守卫）
+	// 查看类接口所有角色可用，操作订单需 operator，修改配置、会话和账号需 admin
 	adminGroup := router.Group("/admin")
 	adminGroup.Use(adminAuth.RequireAuth())
+	requireOperator := adminAuth.RequireRole(model.AdminRoleOperator)
+	requireAdmin := adminAuth.RequireRole(model.AdminRoleAdmin)
 	{
 		// 管理后台页面
 		adminGroup.GET("/dashboard", adminHandler.HandleDashboard)
 
 		// 订单管理API
-		adminGroup.GET("/orders", adminHandler.HandleGetOrders)    // 获取订单列表
-		adminGroup.POST("/action", adminHandler.HandleAdminAction) // 执行操作（新API）
+		adminGroup.GET("/orders", adminHandler.HandleGetOrders)                                                             // 获取订单列表
+		adminGroup.GET("/orders/export", audit.Record("order.export"), adminHandler.HandleExportOrders)                     // 导出订单（CSV/XLSX）
+		adminGroup.GET("/orders/detail", adminHandler.HandleOrderDetail)                                                    // 订单详情页面
+		adminGroup.GET("/orders/timeline", adminHandler.HandleOrderTimeline)                                                // 订单生命周期时间线
+		adminGroup.POST("/orders/create", audit.Record("order.create"), requireOperator, adminHandler.HandleCreateOrder)    // 手动创建订单（线下收款）
+		adminGroup.POST("/action", audit.Record("order.action"), requireOperator, adminHandler.HandleAdminAction)           // 执行操作（新API）
+		adminGroup.POST("/orders/dispute", audit.Record("order.dispute"), requireOperator, adminHandler.HandleOrderDispute) // 标记或解除订单争议
+		adminGroup.GET("/stats", adminHandler.HandleStats)                                                                  // 订单统计
+		adminGroup.GET("/stats/daily", adminHandler.HandleDailyStats)                                                       // 每日订单统计
+
+		// 订单归档
+		adminGroup.GET("/archive", adminHandler.HandleArchiveStatus)                                                 // 归档状态
+		adminGroup.GET("/archive/orders", adminHandler.HandleArchivedOrders)                                         // 查询已归档订单
+		adminGroup.POST("/archive/run", audit.Record("archive.run"), requireOperator, adminHandler.HandleRunArchive) // 立即执行归档
+
+		// 后台任务执行记录
+		adminGroup.GET("/jobs", adminHandler.HandleJobRuns)                                               // 维护任务、归档、日报的执行记录
+		adminGroup.GET("/jobs/:id", adminHandler.HandleJobRun)                                            // 单条执行记录（轮询手动触发的任务）
+		adminGroup.POST("/jobs/run", audit.Record("job.run"), requireOperator, adminHandler.HandleRunJob) // 后台执行一次维护任务
+
+		// 崩溃报告
+		adminGroup.GET("/crashes", requireAdmin, adminHandler.HandleCrashReports) // 最近的崩溃报告（含堆栈）
+
+		// 商户通知概览
+		adminGroup.GET("/notifications", adminHandler.HandleNotifySummary)                                                        // 按商户汇总
+		adminGroup.GET("/notifications/logs", adminHandler.HandleNotifyLogs)                                                      // 通知明细
+		adminGroup.POST("/notifications/replay", audit.Record("notify.replay"), requireOperator, adminHandler.HandleReplayNotify) // 重放通知到指定地址
+		adminGroup.GET("/notifications/health", adminHandler.HandleNotifyHealth)                                                  // 通知地址健康状态
+
+		// 收款码管理
+		adminGroup.GET("/qrcodes", adminHandler.HandleListQRCodes)                                                       // 收款码列表
+		adminGroup.GET("/qrcodes/image", adminHandler.HandleQRCodeImage)                                                 // 收款码图片预览
+		adminGroup.POST("/qrcodes/upload", audit.Record("qrcode.upload"), requireAdmin, adminHandler.HandleUploadQRCode) // 上传收款码图片
+		adminGroup.POST("/qrcodes/update", audit.Record("qrcode.update"), requireAdmin, adminHandler.HandleUpdateQRCode) // 启用/禁用、调整优先级
+
+		// 收款码健康检查
+		adminGroup.GET("/qrcodes/health", adminHandler.HandleQRCodeHealth)                                                                   // 健康状态
+		adminGroup.POST("/qrcodes/health/reset", audit.Record("qrcode.health_reset"), requireOperator, adminHandler.HandleResetQRCodeHealth) // 手动恢复不健康的收款码
+
+		// 线下打印收款码
+		adminGroup.POST("/qrcodes/print", audit.Record("qrcode.print"), requireOperator, adminHandler.HandleCreatePrintedCodes) // 批量生成
+		adminGroup.GET("/qrcodes/print/download", requireOperator, adminHandler.HandleDownloadPrintedCodes)                     // 下载ZIP（二维码图片 + 清单）
+
+		// 商户沙箱凭据
+		adminGroup.GET("/sandbox", requireAdmin, adminHandler.HandleGetSandbox)                                         // 查询沙箱凭据
+		adminGroup.POST("/sandbox/issue", audit.Record("sandbox.issue"), requireAdmin, adminHandler.HandleIssueSandbox) // 签发或重新签发沙箱密钥
+
+		// 商户密钥轮换
+		adminGroup.GET("/merchant/key", requireAdmin, adminHandler.HandleGetMerchantKey)                                                 // 轮换状态（旧密钥过渡期）
+		adminGroup.POST("/merchant/rotate-key", audit.Record("merchant.rotate_key"), requireAdmin, adminHandler.HandleRotateMerchantKey) // 生成新密钥
+
+		// 订单监听Worker池
+		adminGroup.GET("/monitor/pool", adminHandler.HandleWorkerPool)                                                      // Worker池状态
+		adminGroup.POST("/monitor/pool", audit.Record("monitor.resize"), requireAdmin, adminHandler.HandleResizeWorkerPool) // 调整Worker数量和队列大小
+
+		// 退款申请审核
+		adminGroup.GET("/refunds", adminHandler.HandleListRefundRequests)                                                           // 退款申请列表
+		adminGroup.POST("/refunds/review", audit.Record("refund.review"), requireOperator, adminHandler.HandleProcessRefundRequest) // 同意或拒绝退款申请
+
+		// 会话管理
+		adminGroup.GET("/sessions", requireAdmin, adminAuth.HandleListSessions)                                            // 活跃会话列表
+		adminGroup.POST("/sessions/revoke", audit.Record("session.revoke"), requireAdmin, adminAuth.HandleRevokeSession)   // 注销指定会话
+		adminGroup.POST("/session/rotate", audit.Record("session.rotate"), requireAdmin, adminAuth.HandleRotateSecret)     // 轮换签名密钥（注销所有会话）
+		adminGroup.GET("/login-attempts", requireAdmin, adminAuth.HandleLoginAttempts)                                     // 登录失败记录
+		adminGroup.POST("/login-attempts/unlock", audit.Record("login.unlock"), requireAdmin, adminAuth.HandleUnlockLogin) // 解除IP登录锁定
+
+		// 两步验证（每个账号管理自己的密钥和恢复码）
+		adminGroup.GET("/2fa", adminAuth.HandleTwoFactorStatus)                                                            // 两步验证状态
+		adminGroup.POST("/2fa/setup", audit.Record("2fa.setup"), adminAuth.HandleTwoFactorSetup)                           // 生成待确认的密钥和二维码
+		adminGroup.POST("/2fa/enable", audit.Record("2fa.enable"), adminAuth.HandleTwoFactorEnable)                        // 输入动态验证码确认开启
+		adminGroup.POST("/2fa/disable", audit.Record("2fa.disable"), adminAuth.HandleTwoFactorDisable)                     // 关闭两步验证
+		adminGroup.POST("/2fa/recovery-codes", audit.Record("2fa.recovery_codes"), adminAuth.HandleTwoFactorRecoveryCodes) // 重新签发恢复码
+
+		// 管理员账号
+		adminGroup.GET("/users", requireAdmin, adminAuth.HandleListUsers)                                       // 管理员账号列表
+		adminGroup.POST("/users", audit.Record("user.create"), requireAdmin, adminAuth.HandleCreateUser)        // 创建账号
+		adminGroup.POST("/users/update", audit.Record("user.update"), requireAdmin, adminAuth.HandleUpdateUser) // 修改角色、停用、重置密码或两步验证
+		adminGroup.POST("/users/delete", audit.Record("user.delete"), requireAdmin, adminAuth.HandleDeleteUser) // 删除账号
+
+		// API令牌（自动化程序使用 Authorization: Bearer 调用管理接口）
+		adminGroup.GET("/tokens", requireAdmin, adminAuth.HandleListTokens)                                        // 令牌列表
+		adminGroup.POST("/tokens", audit.Record("token.create"), requireAdmin, adminAuth.HandleCreateToken)        // 创建令牌（明文只返回一次）
+		adminGroup.POST("/tokens/revoke", audit.Record("token.revoke"), requireAdmin, adminAuth.HandleRevokeToken) // 注销令牌
 
 		// WebSocket实时推送（需要认证）
 		adminGroup.GET("/ws", adminWsHandler.HandleWebSocket)
+
+		// 压测（仅在 load_test.enabled 时注册）
+		if loadTestHandler != nil {
+			adminGroup.GET("/loadtest", loadTestHandler.HandleStatus)                                                           // 压测状态
+			adminGroup.POST("/loadtest", audit.Record("loadtest.start"), requireAdmin, loadTestHandler.HandleStart)             // 开始压测
+			adminGroup.POST("/loadtest/cleanup", audit.Record("loadtest.cleanup"), requireAdmin, loadTestHandler.HandleCleanup) // 删除压测订单
+		}
 	}
 
 	// 兼容旧API - 使用pid/key参数认证（不使用session）
-	router.GET("/admin", adminHandler.HandleAdmin)
-	router.POST("/admin", adminHandler.HandleAdmin)
-
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	router.GET("/admin", audit.Record("admin.legacy"), adminHandler.HandleAdmin)
+	router.POST("/admin", audit.Record("admin.legacy"), adminHandler.HandleAdmin)
+
+	// 路由匹配前去除 .php 后缀，所有路由自动兼容旧后缀（使用次数见 /health）
+	appHandler := middleware.StripExtension(router, approuter.LegacyExtension)
+
+	// 监听地址（未配置 server.listeners 时为 host:port）
+	listeners := cfg.Server.ListenAddresses()
+	addresses := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		scheme := "http://"
+		switch {
+		case listen.IsUnix(l.Address):
+			scheme = ""
+		case l.TLS:
+			scheme = "https://"
+		}
+		addresses = append(addresses, scheme+l.Address)
+	}
 
 	// 创建路径规范化的HTTP handler包装器
 	// 这个包装器在HTTP层面处理，早于Gin的路由匹配
@@ -278,34 +763,85 @@ func main() {
 		r.URL.Path = normalizedPath
 
 		// 传递给Gin处理
-		router.ServeHTTP(w, r)
+		appHandler.ServeHTTP(w, r)
 	})
 
 	server := &http.Server{
-		Addr:         addr,
 		Handler:      pathNormalizingHandler, // 使用包装后的handler
 		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
 	}
 
 	logger.Success("Server starting",
-		zap.String("address", addr),
+		zap.Strings("addresses", addresses),
 		zap.String("mode", cfg.Server.Mode),
 		zap.Bool("http2", true))
 
-	// 优雅退出
-	go func() {
+	// 配置客户端证书校验（mTLS认证）
+	if cfg.Server.ClientCAFile != "" {
+		tlsConfig, err := loadClientCATLSConfig(cfg.Server.ClientCAFile)
+		if err != nil {
+			logger.Fatal("Failed to load client CA", zap.Error(err))
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	// 关闭时通知仍未断开的WebSocket客户端重连（平滑升级时大部分客户端已收到 reconnect 消息）
+	server.RegisterOnShutdown(func() {
+		wsHandler.CloseAll()
+		adminWsHandler.CloseAll()
+	})
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Failed to start HTTP server", zap.Error(err))
+	// 监听管理（平滑升级启动时继承旧进程的监听socket）
+	upgrader := listen.NewUpgrader()
+
+	// 所有地址共用同一个服务，优雅退出时一起关闭
+	for _, l := range listeners {
+		ln, err := upgrader.Listen(l.Address, l.FileMode())
+		if err != nil {
+			logger.Fatal("Failed to listen", zap.String("address", l.Address), zap.Error(err))
 		}
-	}()
+		go func(l config.ListenerConfig, ln net.Listener) {
+			var err error
+			if l.TLS {
+				err = server.ServeTLS(ln, cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+			} else {
+				err = server.Serve(ln)
+			}
+			// 平滑升级后监听先于 Shutdown 关闭
+			if err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+				logger.Fatal("Failed to start HTTP server", zap.String("address", l.Address), zap.Error(err))
+			}
+		}(l, ln)
+	}
+
+	// 启动gRPC服务（独立端口）
+	var grpcServer *grpcapi.Server
+	if cfg.GRPC.Enabled {
+		grpcServer, err = grpcapi.NewServer(cfg, db, codepayService)
+		if err != nil {
+			logger.Fatal("Failed to create gRPC server", zap.Error(err))
+		}
+		grpcAddr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.GRPC.Port)
+		grpcListener, err := upgrader.Listen(grpcAddr, 0)
+		if err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.String("address", grpcAddr), zap.Error(err))
+		}
+		grpcServer.Serve(grpcListener)
+	}
+
 	merchantInfo = codepayService.GetMerchantInfo()
 
 	fmt.Println("\n╔════════════════════════════════════════════════════════╗")
 	fmt.Println("║         🚀 AliMPay Golang Version Started            ║")
 	fmt.Println("╠════════════════════════════════════════════════════════╣")
-	fmt.Printf("║  Server Address:  http://%-28s ║\n", addr)
+	for i, address := range addresses {
+		label := "Server Address:"
+		if i > 0 {
+			label = ""
+		}
+		fmt.Printf("║  %-16s %-35s ║\n", label, address)
+	}
 	fmt.Printf("║  Merchant ID:     %-35s ║\n", merchantInfo["id"])
 	fmt.Printf("║  Merchant Key:    %-35s ║\n", merchantInfo["key"])
 	fmt.Printf("║  Monitor:         %-35s ║\n", fmt.Sprintf("Enabled (Interval: %ds)", cfg.Monitor.Interval))
@@ -313,30 +849,105 @@ func main() {
 	fmt.Println("╚════════════════════════════════════════════════════════╝")
 
 	logger.Success("Server started successfully",
-		zap.String("address", addr),
-		zap.String("merchant_id", merchantInfo["id"].(string)))
+		zap.Strings("addresses", addresses),
+		zap.String("merchant_id", merchantInfo["id"].(string)),
+		zap.Bool("upgraded", upgrader.Inherited()))
 
-	// 等待中断信号
+	// 通知旧进程（平滑升级时）和systemd服务已就绪
+	if err := upgrader.Ready(cfg.Server.PIDFile); err != nil {
+		logger.Warn("Failed to signal readiness", zap.Error(err))
+	}
+
+	// 等待中断信号；收到升级信号时启动新进程，新进程就绪后当前进程优雅退出
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, listen.UpgradeSignals...)...)
+	upgraded := false
+	for sig := range quit {
+		if sig == syscall.SIGINT || sig == syscall.SIGTERM {
+			break
+		}
+		logger.Info("Received upgrade signal, starting new process")
+		if err := upgrader.Upgrade(time.Duration(cfg.Server.UpgradeTimeout) * time.Second); err != nil {
+			logger.Error("Upgrade failed, current process keeps serving", zap.Error(err))
+			continue
+		}
+		logger.Info("New process is ready, current process stops accepting connections")
+		upgraded = true
+		// 新进程已开始监听周期和定时任务，立即停止本进程的，避免同时查询支付宝
+		monitorService.StopScheduler()
+		// 先停止接受新连接：Shutdown 开始后才读到请求的连接会被直接关闭，
+		// 留出时间让已接受的连接发送请求
+		upgrader.Close()
+		// 监听已交给新进程，WebSocket客户端此时重连只会连到新进程
+		wsHandler.Reconnect()
+		adminWsHandler.Reconnect()
+		time.Sleep(time.Second)
+		break
+	}
 
 	fmt.Println()
 	logger.Warn("Received shutdown signal, gracefully stopping...")
 
 	// 优雅关闭服务器
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
 	defer cancel()
 
+	// 平滑升级时等待WebSocket客户端迁移到新进程，剩余的连接在 Shutdown 时收到关闭帧
+	if upgraded {
+		drainCtx, drainCancel := context.WithTimeout(ctx, websocketDrainTimeout)
+		remaining := wsHandler.Drain(drainCtx) + adminWsHandler.Drain(drainCtx)
+		drainCancel()
+		logger.Info("WebSocket clients drained", zap.Int("remaining", remaining))
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	if grpcServer != nil {
+		grpcServer.Stop(ctx)
+	}
+
 	// 停止监控服务
 	monitorService.Stop()
 
+	// 停止通知Worker池
+	codepayService.Close()
+
 	logger.Info("Server stopped gracefully")
 	if err := logger.Sync(); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to sync logger: %v\n", err)
 	}
 }
+
+// loadClientCATLSConfig 加载客户端CA证书，启用可选的客户端证书校验
+// 未携带证书的客户端仍可使用签名/密钥等方式认证
+func loadClientCATLSConfig(caFile string) (*tls.Config, error) {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no valid certificates found in %s", caFile)
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// newQueryRedactor 根据日志配置创建访问日志脱敏器
+func newQueryRedactor(cfg config.LoggingConfig) *middleware.QueryRedactor {
+	rules := make([]middleware.RedactRule, 0, len(cfg.RedactRules))
+	for _, rule := range cfg.RedactRules {
+		rules = append(rules, middleware.RedactRule{
+			Path:   rule.Path,
+			Params: rule.Params,
+		})
+	}
+	return middleware.NewQueryRedactor(cfg.RedactParams, rules)
+}
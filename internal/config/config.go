@@ -2,21 +2,58 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/botfilter"
+	"alimpay-go/internal/pkg/listen"
+	"alimpay-go/internal/pkg/utils"
+
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
 )
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Alipay   AlipayConfig   `yaml:"alipay"`
-	Database DatabaseConfig `yaml:"database"`
-	Payment  PaymentConfig  `yaml:"payment"`
-	Merchant MerchantConfig `yaml:"merchant"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Monitor  MonitorConfig  `yaml:"monitor"`
+	Server    ServerConfig    `yaml:"server"`
+	Alipay    AlipayConfig    `yaml:"alipay"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Payment   PaymentConfig   `yaml:"payment"`
+	Merchant  MerchantConfig  `yaml:"merchant"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Monitor   MonitorConfig   `yaml:"monitor"`
+	Redis     RedisConfig     `yaml:"redis"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Admin     AdminConfig     `yaml:"admin"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+
+	NotifyHealth NotifyHealthConfig `yaml:"notify_health"`
+	QRCodeHealth QRCodeHealthConfig `yaml:"qrcode_health"`
+	LoadTest     LoadTestConfig     `yaml:"load_test"`
+	Secrets      SecretsConfig      `yaml:"secrets"`
+	HTTPClient   HTTPClientConfig   `yaml:"http_client"`
+	Archive      ArchiveConfig      `yaml:"archive"`
+	EventOutbox  EventOutboxConfig  `yaml:"event_outbox"`
+	WebSocket    WebSocketConfig    `yaml:"websocket"`
+	Branding     BrandingConfig     `yaml:"branding"`
+	Cluster      ClusterConfig      `yaml:"cluster"`
+	GeoIP        GeoIPConfig        `yaml:"geoip"`
+
+	SubmitCaptcha   SubmitCaptchaConfig   `yaml:"submit_captcha"`
+	SubmitBotFilter SubmitBotFilterConfig `yaml:"submit_bot_filter"`
+
+	Notifier     NotifierConfig     `yaml:"notifier"`
+	DailyDigest  DailyDigestConfig  `yaml:"daily_digest"`
+	Housekeeping HousekeepingConfig `yaml:"housekeeping"`
+
+	envOverrides map[string]interface{} // 被环境变量覆盖的配置项及其在配置文件中的原值
+	secretRefs   map[string]interface{} // 从外部来源读取的密钥配置项及其引用
 }
 
 // ServerConfig 服务器配置
@@ -27,6 +64,81 @@ type ServerConfig struct {
 	ReadTimeout  int    `yaml:"read_timeout"`
 	WriteTimeout int    `yaml:"write_timeout"`
 	BaseURL      string `yaml:"base_url"` // 基础URL，留空则自动获取
+
+	ShutdownTimeout int `yaml:"shutdown_timeout"` // 退出（含平滑升级后旧进程退出）时等待进行中的请求完成的时间（秒）
+
+	// 可信反向代理IP或网段：只采信来自这些地址的 X-Forwarded-For/X-Real-IP 请求头；
+	// 留空时信任所有来源的请求头（兼容旧版），此时商户来源IP白名单按直连地址检查
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+
+	// HTTPS配置（可选）
+	TLSCertFile  string `yaml:"tls_cert_file,omitempty"`  // 服务端证书
+	TLSKeyFile   string `yaml:"tls_key_file,omitempty"`   // 服务端私钥
+	ClientCAFile string `yaml:"client_ca_file,omitempty"` // 客户端证书CA（启用mTLS认证）
+
+	// 监听地址列表（可选，设置后替代 host:port）：同时监听多个地址，或监听 Unix domain socket 供本机反向代理连接
+	Listeners []ListenerConfig `yaml:"listeners,omitempty"`
+
+	// 平滑升级：收到 SIGUSR2 时启动新进程并传递监听socket，新进程就绪后当前进程处理完进行中的请求再退出
+	UpgradeTimeout int    `yaml:"upgrade_timeout"`    // 等待新进程就绪的时间（秒），超时则终止新进程并继续运行
+	PIDFile        string `yaml:"pid_file,omitempty"` // 就绪后写入进程ID（升级后为新进程ID），便于脚本发送信号
+}
+
+// ListenerConfig HTTP服务监听地址
+type ListenerConfig struct {
+	Address    string `yaml:"address"`               // host:port，或 unix:/path/to/alimpay.sock
+	TLS        bool   `yaml:"tls,omitempty"`         // 使用 tls_cert_file/tls_key_file 提供HTTPS
+	SocketMode string `yaml:"socket_mode,omitempty"` // Unix socket 文件权限（八进制，默认 0660）
+}
+
+// ListenAddresses 实际的监听地址：未配置 listeners 时为 host:port（配置了证书时提供HTTPS）
+func (s *ServerConfig) ListenAddresses() []ListenerConfig {
+	if len(s.Listeners) > 0 {
+		return s.Listeners
+	}
+	return []ListenerConfig{{
+		Address: net.JoinHostPort(s.Host, strconv.Itoa(s.Port)),
+		TLS:     s.TLSCertFile != "" && s.TLSKeyFile != "",
+	}}
+}
+
+// FileMode Unix socket 文件权限（未配置或无效时为默认权限）
+func (l ListenerConfig) FileMode() os.FileMode {
+	mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+	if err != nil {
+		return listen.DefaultSocketMode
+	}
+	return os.FileMode(mode)
+}
+
+// validate 检查监听地址格式
+func (l ListenerConfig) validate() error {
+	if listen.IsUnix(l.Address) {
+		if strings.TrimPrefix(l.Address, listen.UnixPrefix) == "" {
+			return fmt.Errorf("unix socket path is empty")
+		}
+		if l.TLS {
+			return fmt.Errorf("tls is not supported on unix sockets")
+		}
+		if l.SocketMode != "" {
+			if mode, err := strconv.ParseUint(l.SocketMode, 8, 32); err != nil || mode > 0777 {
+				return fmt.Errorf("socket_mode must be an octal permission such as 0660, got %q", l.SocketMode)
+			}
+		}
+		return nil
+	}
+
+	if l.SocketMode != "" {
+		return fmt.Errorf("socket_mode only applies to unix sockets")
+	}
+	_, port, err := net.SplitHostPort(l.Address)
+	if err != nil {
+		return fmt.Errorf("address must be host:port or unix:/path, got %q", l.Address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port in %q", l.Address)
+	}
+	return nil
 }
 
 // AlipayConfig 支付宝配置
@@ -39,6 +151,14 @@ type AlipayConfig struct {
 	SignType        string `yaml:"sign_type"`
 	Charset         string `yaml:"charset"`
 	Format          string `yaml:"format"`
+
+	// 跳过支付宝响应验签（仅用于排查公钥配置问题，生产环境请勿开启）
+	SkipResponseVerify bool `yaml:"skip_response_verify,omitempty"`
+
+	// 接口限流（按app_id共享，多个二维码使用同一套凭据时共用配额）
+	QPS          float64 `yaml:"qps"`           // 每秒请求数（默认5，负数不限流）
+	Burst        int     `yaml:"burst"`         // 允许的突发请求数（默认1）
+	QueueTimeout int     `yaml:"queue_timeout"` // 超出配额的请求最多排队等待的秒数（默认10）
 }
 
 // DatabaseConfig 数据库配置
@@ -48,6 +168,7 @@ type DatabaseConfig struct {
 	MaxIdleConns    int    `yaml:"max_idle_conns"`
 	MaxOpenConns    int    `yaml:"max_open_conns"`
 	ConnMaxLifetime int    `yaml:"conn_max_lifetime"`
+	SchemaUpgrade   string `yaml:"schema_upgrade"` // 数据库结构版本低于程序版本时：auto 自动升级，manual 拒绝启动
 }
 
 // PaymentConfig 支付配置
@@ -60,19 +181,44 @@ type PaymentConfig struct {
 	QRCodeSize       int               `yaml:"qr_code_size"`
 	QRCodeMargin     int               `yaml:"qr_code_margin"`
 	BusinessQRMode   BusinessQRMode    `yaml:"business_qr_mode"`
+	PrecreateMode    PrecreateMode     `yaml:"precreate_mode"`
+	WapMode          WapMode           `yaml:"wap_mode"`
 	AntiRiskURL      AntiRiskURLConfig `yaml:"anti_risk_url"`
+	PartialPayment   PartialPayment    `yaml:"partial_payment"`
+}
+
+// PartialPayment 分笔支付配置
+// 支付宝单笔转账限额迫使买家拆分付款时，备注为商户订单号的多笔小额账单累计到支付金额后订单完成支付
+type PartialPayment struct {
+	Enabled   bool         `yaml:"enabled"`
+	MinAmount model.Amount `yaml:"min_amount"` // 允许分笔支付的最低订单金额（元），为0时所有订单均可分笔支付
+}
+
+// PrecreateMode 当面付收款模式配置（alipay.trade.precreate，需要应用开通当面付产品）
+// 每个订单由支付宝生成独立的收款二维码，按交易号确认支付，无需调整金额和匹配账单
+type PrecreateMode struct {
+	Enabled   bool   `yaml:"enabled"`
+	NotifyURL string `yaml:"notify_url"` // 支付宝异步通知地址，为空时使用 server.base_url（或请求域名）+ /alipay/notify
+}
+
+// WapMode 手机网站支付配置（alipay.trade.wap.pay，需要应用开通手机网站支付产品）
+// 下单请求携带 device=h5 时跳转支付宝收银台付款，其余请求仍使用当前收款模式
+type WapMode struct {
+	Enabled   bool   `yaml:"enabled"`
+	NotifyURL string `yaml:"notify_url"` // 支付宝异步通知地址，为空时使用 server.base_url（或请求域名）+ /alipay/notify
 }
 
 // BusinessQRMode 经营码收款模式配置
 type BusinessQRMode struct {
-	Enabled        bool     `yaml:"enabled"`
-	QRCodePath     string   `yaml:"qr_code_path"`  // 单个二维码路径（向后兼容）
-	QRCodePaths    []QRCode `yaml:"qr_code_paths"` // 多个二维码配置
-	QRCodeID       string   `yaml:"qr_code_id"`    // 支付宝收款码ID，用于手机端拉起支付宝（单个模式）
-	AmountOffset   float64  `yaml:"amount_offset"`
-	MatchTolerance int      `yaml:"match_tolerance"`
-	PaymentTimeout int      `yaml:"payment_timeout"`
-	PollingMode    string   `yaml:"polling_mode"` // 轮询模式: round_robin, random, least_used
+	Enabled        bool         `yaml:"enabled"`
+	QRCodePath     string       `yaml:"qr_code_path"`  // 单个二维码路径（向后兼容）
+	QRCodePaths    []QRCode     `yaml:"qr_code_paths"` // 多个二维码配置
+	QRCodeID       string       `yaml:"qr_code_id"`    // 支付宝收款码ID，用于手机端拉起支付宝（单个模式）
+	AmountOffset   model.Amount `yaml:"amount_offset"` // 同金额订单的递增金额（元，精确到分）
+	MatchTolerance int          `yaml:"match_tolerance"`
+	PaymentTimeout int          `yaml:"payment_timeout"`
+	PollingMode    string       `yaml:"polling_mode"` // 轮询模式: round_robin, random, least_used
+	UploadDir      string       `yaml:"upload_dir"`   // 管理后台上传的二维码图片保存目录
 }
 
 // QRCode 二维码配置
@@ -83,20 +229,32 @@ type QRCode struct {
 	Enabled  bool   `yaml:"enabled"`  // 是否启用
 	Priority int    `yaml:"priority"` // 优先级（数字越小优先级越高）
 
+	// 每日限额（按订单创建日期统计待支付和已支付订单，0 表示不限制），达到限额后当天不再分配，次日0点恢复
+	MaxDailyAmount model.Amount `yaml:"max_daily_amount,omitempty"` // 每日最多收款金额（元）
+	MaxDailyCount  int          `yaml:"max_daily_count,omitempty"`  // 每日最多订单数
+
+	// 金额区间（元，0 表示不限制）：优先分配实付金额在区间内的订单，例如大额订单分配给可接收大额转账的账号
+	MinAmount model.Amount `yaml:"min_amount,omitempty"` // 最小订单金额
+	MaxAmount model.Amount `yaml:"max_amount,omitempty"` // 最大订单金额
+
 	// 独立的支付宝API配置（可选，为空则使用全局配置）
 	AlipayAPI *QRCodeAlipayConfig `yaml:"alipay_api,omitempty"`
 }
 
 // QRCodeAlipayConfig 二维码专属的支付宝API配置
 type QRCodeAlipayConfig struct {
-	ServerURL       string `yaml:"server_url,omitempty"`        // 支付宝网关
-	AppID           string `yaml:"app_id,omitempty"`            // 应用ID
-	PrivateKey      string `yaml:"private_key,omitempty"`       // 应用私钥
-	AlipayPublicKey string `yaml:"alipay_public_key,omitempty"` // 支付宝公钥
-	TransferUserID  string `yaml:"transfer_user_id,omitempty"`  // 转账用户ID
-	SignType        string `yaml:"sign_type,omitempty"`         // 签名类型
-	Charset         string `yaml:"charset,omitempty"`           // 字符集
-	Format          string `yaml:"format,omitempty"`            // 格式
+	ServerURL       string  `yaml:"server_url,omitempty"`        // 支付宝网关
+	AppID           string  `yaml:"app_id,omitempty"`            // 应用ID
+	PrivateKey      string  `yaml:"private_key,omitempty"`       // 应用私钥
+	AlipayPublicKey string  `yaml:"alipay_public_key,omitempty"` // 支付宝公钥
+	TransferUserID  string  `yaml:"transfer_user_id,omitempty"`  // 转账用户ID
+	SignType        string  `yaml:"sign_type,omitempty"`         // 签名类型
+	Charset         string  `yaml:"charset,omitempty"`           // 字符集
+	Format          string  `yaml:"format,omitempty"`            // 格式
+	QPS             float64 `yaml:"qps,omitempty"`               // 每秒请求数（为空则使用全局配置）
+	Burst           int     `yaml:"burst,omitempty"`             // 允许的突发请求数
+
+	SkipResponseVerify bool `yaml:"skip_response_verify,omitempty"` // 跳过支付宝响应验签（全局配置开启时同样跳过）
 }
 
 // AntiRiskURLConfig 防风控URL配置
@@ -113,6 +271,26 @@ type MerchantConfig struct {
 	ID   string `yaml:"id"`
 	Key  string `yaml:"key"`
 	Rate int    `yaml:"rate"`
+
+	// 公开API认证配置
+	AuthMethods            []string `yaml:"auth_methods,omitempty"`             // 允许的认证方式: sign, key, token, mtls（留空为 sign, key）
+	APITokens              []string `yaml:"api_tokens,omitempty"`               // Bearer令牌列表
+	ClientCertFingerprints []string `yaml:"client_cert_fingerprints,omitempty"` // 客户端证书SHA-256指纹列表
+
+	// 未支付订单不自动过期（下单参数 auto_close 未指定时的默认行为），适用于买家可能数小时后付款的账单类业务
+	KeepUnpaidOrders bool `yaml:"keep_unpaid_orders,omitempty"`
+
+	// 通知来源验证（可选）
+	NotifySecret string   `yaml:"notify_secret,omitempty"` // 通知签名密钥，设置后通知请求附带 X-AliMPay-Signature 请求头
+	OutboundIPs  []string `yaml:"outbound_ips,omitempty"`  // 发送通知使用的出口IP或网段，通过 /api/notify/ips 公布给商户
+
+	// 签名类型: MD5（默认，同时接受 MD5 和 HMAC-SHA256 签名的请求）或 HMAC-SHA256（只接受 HMAC-SHA256 签名的请求）；
+	// 支付结果通知、同步跳转参数等按此类型签名
+	SignType string `yaml:"sign_type,omitempty"`
+
+	// 来源IP白名单（可选）：只接受来自这些IP或网段的商户接口请求，密钥泄露后无法在其他地方使用
+	AllowedIPs       []string `yaml:"allowed_ips,omitempty"`
+	AllowedIPsSubmit bool     `yaml:"allowed_ips_submit,omitempty"` // /submit 同样检查来源IP（仅适用于由商户服务端提交 /submit 的接入方式）
 }
 
 // LoggingConfig 日志配置
@@ -125,19 +303,250 @@ type LoggingConfig struct {
 	MaxBackups int    `yaml:"max_backups"`
 	MaxAge     int    `yaml:"max_age"`
 	Compress   bool   `yaml:"compress"`
+
+	// 访问日志脱敏
+	RedactParams []string     `yaml:"redact_params"` // 全局脱敏参数（留空使用默认列表）
+	RedactRules  []RedactRule `yaml:"redact_rules"`  // 按接口路径追加的脱敏参数
+}
+
+// RedactRule 按路径的日志脱敏规则
+type RedactRule struct {
+	Path   string   `yaml:"path"`   // 接口路径，支持 /admin/* 前缀匹配
+	Params []string `yaml:"params"` // 需要脱敏的参数名
 }
 
 // MonitorConfig 监控配置
 type MonitorConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Interval    int    `yaml:"interval"`
+	LockTimeout int    `yaml:"lock_timeout"`
+	LockBackend string `yaml:"lock_backend"` // 锁类型: file（默认）, redis（多实例部署）
+	WorkerCount int    `yaml:"worker_count"` // 处理订单的Worker数量
+	QueueSize   int    `yaml:"queue_size"`   // 待处理订单队列大小
+
+	NotifyWorkerCount int `yaml:"notify_worker_count"` // 发送商户通知的Worker数量（与账单匹配分开）
+	NotifyQueueSize   int `yaml:"notify_queue_size"`   // 待发送通知队列大小
+
+	DrainTimeout int `yaml:"drain_timeout"` // 关闭时等待排队任务执行完成的期限（秒，负数表示不等待）
+}
+
+// DrainTimeoutDuration 关闭Worker池的排空期限
+func (c *MonitorConfig) DrainTimeoutDuration() time.Duration {
+	if c.DrainTimeout < 0 {
+		return 0
+	}
+	return time.Duration(c.DrainTimeout) * time.Second
+}
+
+// RedisConfig Redis配置（可选，未启用时降级为无缓存模式）
+type RedisConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Addr          string `yaml:"addr"`
+	Password      string `yaml:"password"`
+	DB            int    `yaml:"db"`
+	OrderCacheTTL int    `yaml:"order_cache_ttl"` // 订单缓存有效期（秒）
+
+	// 集群事件广播（多实例部署时同步WebSocket推送）
+	ClusterBroadcast bool   `yaml:"cluster_broadcast"`
+	EventChannel     string `yaml:"event_channel"` // 发布订阅频道，默认 alimpay:events
+}
+
+// RateLimitConfig 限流配置（令牌桶，作用于 /submit、/api、/mapi）
+type RateLimitConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	IPRate        float64 `yaml:"ip_rate"`        // 每个IP每秒请求数
+	IPBurst       int     `yaml:"ip_burst"`       // 每个IP突发请求数
+	MerchantRate  float64 `yaml:"merchant_rate"`  // 每个商户每秒请求数
+	MerchantBurst int     `yaml:"merchant_burst"` // 每个商户突发请求数
+
+	QRCodeIPRate  float64 `yaml:"qrcode_ip_rate"`  // 二维码图片（/qrcode）每个IP每秒请求数
+	QRCodeIPBurst int     `yaml:"qrcode_ip_burst"` // 二维码图片每个IP突发请求数
+	QRCodeMaxWait int     `yaml:"qrcode_max_wait"` // 二维码图片超限请求最多排队等待的毫秒数，超过后返回429
+}
+
+// AdminConfig 管理后台配置
+type AdminConfig struct {
+	SessionLifetime  int `yaml:"session_lifetime"`  // session最长有效期（秒）
+	IdleTimeout      int `yaml:"idle_timeout"`      // 无操作超时（秒）
+	RememberLifetime int `yaml:"remember_lifetime"` // "记住我"有效期（秒）
+
+	// 会话存储: database（默认，重启后会话仍有效）, memory（重启后需重新登录）, redis（多实例部署共享会话，需启用redis）
+	SessionStore string `yaml:"session_store"`
+
+	// 登录防暴力破解（按客户端IP）
+	LoginMaxAttempts  int `yaml:"login_max_attempts"`  // 统计窗口内允许的登录失败次数，达到后锁定
+	LoginLockout      int `yaml:"login_lockout"`       // 锁定时长（秒），同时作为失败次数的统计窗口
+	LoginCaptchaAfter int `yaml:"login_captcha_after"` // 连续失败多少次后要求输入验证码（0 不启用）
+}
+
+// GRPCConfig gRPC接口配置（独立端口，监听地址与HTTP服务相同）
+type GRPCConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port"`
+}
+
+// NotifyHealthConfig 商户通知地址健康检查配置
+type NotifyHealthConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	Interval     int  `yaml:"interval"`      // 探测间隔（秒）
+	Timeout      int  `yaml:"timeout"`       // 单次探测超时（秒）
+	LookbackDays int  `yaml:"lookback_days"` // 探测最近多少天订单使用过的通知地址
+}
+
+// QRCodeHealthConfig 收款码健康检查配置（经营码模式）
+// 专属支付宝接口连续失败，或一段时间内有订单分配却没有任何支付的收款码标记为不健康，不再分配给新订单
+type QRCodeHealthConfig struct {
+	Enabled          bool `yaml:"enabled"`
+	Interval         int  `yaml:"interval"`          // 检查间隔（秒）
+	FailureThreshold int  `yaml:"failure_threshold"` // 专属接口连续失败多少次标记为不健康
+	NoPaymentHours   int  `yaml:"no_payment_hours"`  // 检查最近多少小时分配的订单
+	MinOrders        int  `yaml:"min_orders"`        // 该时间内至少分配多少笔订单且全部未支付时标记为不健康
+	RecoverAfter     int  `yaml:"recover_after"`     // 因无支付被标记后多少分钟自动恢复分配（0 表示只能手动恢复）
+}
+
+// ArchiveConfig 订单归档配置（超过保留期的订单移入归档表，保持订单表精简）
+type ArchiveConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	RetentionDays int  `yaml:"retention_days"` // 订单表保留最近多少天的订单
+	Interval      int  `yaml:"interval"`       // 归档任务执行间隔（秒）
+	BatchSize     int  `yaml:"batch_size"`     // 每个事务归档的订单数（分批执行，避免长时间锁表）
+}
+
+// EventOutboxConfig 事件发件箱配置（持久化订单事件，外部消费者通过 /api/events 按序号补拉）
+type EventOutboxConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	RetentionDays int  `yaml:"retention_days"` // 事件保留天数，消费者中断超过该时长需重新对账
+}
+
+// WebSocketConfig WebSocket推送配置（支付页面 /ws/order、管理后台 /admin/ws）
+type WebSocketConfig struct {
+	PingInterval   int `yaml:"ping_interval"`   // 心跳间隔（秒）
+	ReadTimeout    int `yaml:"read_timeout"`    // 超过此时间（秒）未收到消息或心跳回应则断开，需大于心跳间隔
+	MaxConnections int `yaml:"max_connections"` // 支付页面最大连接数，超过后拒绝新连接（负数不限制）
+	MaxPerOrder    int `yaml:"max_per_order"`   // 每个订单最多同时订阅的连接数（负数不限制）
+}
+
+// BrandingConfig 支付页面品牌配置（/pay、/submit 支付页面和错误页面）
+type BrandingConfig struct {
+	TemplateDir    string `yaml:"template_dir"`    // 自定义模板目录，其中的同名 .html 文件替换内置模板
+	SiteName       string `yaml:"site_name"`       // 站点名称（页面标题和页头）
+	LogoURL        string `yaml:"logo_url"`        // Logo图片地址，留空显示默认图标
+	PrimaryColor   string `yaml:"primary_color"`   // 主题色（#rgb 或 #rrggbb）
+	Footer         string `yaml:"footer"`          // 页脚文字
+	SupportContact string `yaml:"support_contact"` // 客服联系方式（电话、邮箱等）
+}
+
+// ClusterConfig 多实例部署配置
+type ClusterConfig struct {
+	InstanceID     string `yaml:"instance_id"`     // 实例标识（各实例不可相同），留空使用 主机名-进程号
+	LeaderElection bool   `yaml:"leader_election"` // 选举主节点，定时任务只在主节点执行（租约后端同 monitor.lock_backend）
+	LeaseTTL       int    `yaml:"lease_ttl"`       // 主节点租约有效期（秒），主节点异常退出后其他实例最长在此时间后接管
+}
+
+// GeoIPConfig IP归属地配置（离线IP库，记录买家下单IP和管理后台登录IP的国家/省份）
+type GeoIPConfig struct {
+	Enabled  bool        `yaml:"enabled"`
+	Database string      `yaml:"database"` // IP库文件（CSV：起始IP,结束IP,国家代码[,省份]）
+	Rules    []GeoIPRule `yaml:"rules"`    // 下单风控规则，按买家IP归属地拒绝下单（依次检查，任一规则拒绝即拒绝）
+}
+
+// GeoIPRule 按买家IP归属地限制下单
+// 局域网地址和未提供买家IP的订单（服务端下单未传 clientip）不受规则限制
+type GeoIPRule struct {
+	Name             string   `yaml:"name"`              // 规则名称（记录在日志中）
+	NameKeywords     []string `yaml:"name_keywords"`     // 商品名称包含任一关键词时适用，为空时适用于全部订单
+	AllowedCountries []string `yaml:"allowed_countries"` // 只允许这些国家/地区代码的买家下单，为空时不限制
+	BlockedCountries []string `yaml:"blocked_countries"` // 拒绝这些国家/地区代码的买家下单
+	BlockUnknown     bool     `yaml:"block_unknown"`     // 拒绝IP库中查不到归属地的买家
+}
+
+// SubmitCaptchaConfig 下单人机验证配置
+// 页面跳转下单（/submit）命中风控规则时，买家需先完成人机验证才会创建订单
+type SubmitCaptchaConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Provider       string   `yaml:"provider"`        // 验证方式：slider（内置滑块）、hcaptcha、turnstile
+	SiteKey        string   `yaml:"site_key"`        // hCaptcha/Turnstile 站点密钥
+	SecretKey      string   `yaml:"secret_key"`      // hCaptcha/Turnstile 服务端密钥
+	VelocityWindow int      `yaml:"velocity_window"` // 下单频率统计窗口（秒）
+	VelocityLimit  int      `yaml:"velocity_limit"`  // 同一买家IP在统计窗口内的订单数达到该值后要求验证（<=0 不按频率验证）
+	RiskyRanges    []string `yaml:"risky_ranges"`    // 要求验证的IP或网段（如机房、代理IP段）
+}
+
+// SubmitBotFilterConfig 下单机器人过滤配置
+// 页面跳转下单（/submit）前浏览器需自动完成工作量证明，且不能填写蜜罐字段
+type SubmitBotFilterConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Difficulty int    `yaml:"difficulty"` // 工作量证明难度（前导零比特数，1-24），每加1浏览器平均计算量翻倍
+	Secret     string `yaml:"secret"`     // 题目签名密钥（多实例部署时需相同，留空时随机生成）
+}
+
+// NotifierConfig 运营通知渠道配置（日报等运营消息发送到全部渠道）
+type NotifierConfig struct {
+	Channels []NotifierChannel `yaml:"channels"`
+}
+
+// NotifierChannel 通知渠道
+type NotifierChannel struct {
+	Type   string `yaml:"type"`   // 渠道类型：webhook（通用JSON）、dingtalk（钉钉群机器人）、wecom（企业微信群机器人）
+	URL    string `yaml:"url"`    // Webhook地址
+	Secret string `yaml:"secret"` // 钉钉机器人加签密钥（未启用加签时留空）
+}
+
+// DailyDigestConfig 运营日报配置（每天定时汇总前24小时的订单、收入、通知失败和监控异常，通过通知渠道发送）
+type DailyDigestConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	Hour         int  `yaml:"hour"`          // 发送时间（0-23点，北京时间）
+	TopMerchants int  `yaml:"top_merchants"` // 列出收入最高的商户数
+}
+
+// HousekeepingConfig 维护任务配置
+// 各任务使用独立的cron表达式（5段格式或 @daily、@every 1h 等），在监听服务的定时任务调度器上执行；留空不定时执行
+type HousekeepingConfig struct {
+	Cleanup        string `yaml:"cleanup"`        // 清理过期订单和过期的匹配记录，留空时随每个监听周期执行
+	Reconciliation string `yaml:"reconciliation"` // 对账：重新通知近24小时已支付但商户未确认的订单
+	Stats          string `yaml:"stats"`          // 汇总当天和前一天的每日订单统计
+	Backup         string `yaml:"backup"`         // 备份数据库
+	BackupDir      string `yaml:"backup_dir"`     // 备份目录
+	BackupKeep     int    `yaml:"backup_keep"`    // 保留最近多少个备份
+}
+
+// InstanceName 当前实例标识（未配置时使用 主机名-进程号，不写回配置文件）
+func (c *ClusterConfig) InstanceName() string {
+	if c.InstanceID != "" {
+		return c.InstanceID
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// LoadTestConfig 压测模式配置（生成模拟订单和模拟账单，生产环境请保持关闭）
+type LoadTestConfig struct {
 	Enabled     bool `yaml:"enabled"`
-	Interval    int  `yaml:"interval"`
-	LockTimeout int  `yaml:"lock_timeout"`
+	MaxOrders   int  `yaml:"max_orders"`  // 单次最多生成的订单数
+	Concurrency int  `yaml:"concurrency"` // 最大并发创建数
 }
 
 var globalConfig *Config
 
+// brandColorPattern 主题色格式（颜色直接写入页面样式，只允许十六进制颜色）
+var brandColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// countryCodePattern 国家/地区代码格式（ISO 3166-1 两位字母，与IP库一致）
+var countryCodePattern = regexp.MustCompile(`^[A-Za-z]{2}$`)
+
 // Load 加载配置文件
 func Load(configPath string) (*Config, error) {
+	cfg, err := Parse(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	globalConfig = cfg
+	return cfg, nil
+}
+
+// Parse 读取、解析并校验配置文件（不替换全局配置，供热加载使用）
+func Parse(configPath string) (*Config, error) {
 	// 读取配置文件
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -150,6 +559,16 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// 环境变量覆盖（容器部署时无需将密钥写入配置文件）
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, err
+	}
+
+	// 读取外部密钥（文件、环境变量、Vault等）
+	if err := resolveSecrets(&cfg); err != nil {
+		return nil, err
+	}
+
 	// 设置默认值
 	setDefaults(&cfg)
 
@@ -158,7 +577,6 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	globalConfig = &cfg
 	return &cfg, nil
 }
 
@@ -184,6 +602,22 @@ func setDefaults(cfg *Config) {
 	if cfg.Server.WriteTimeout == 0 {
 		cfg.Server.WriteTimeout = 60
 	}
+	if cfg.Server.UpgradeTimeout <= 0 {
+		cfg.Server.UpgradeTimeout = 60
+	}
+	if cfg.Server.ShutdownTimeout <= 0 {
+		cfg.Server.ShutdownTimeout = 30
+	}
+
+	if cfg.Alipay.QPS == 0 {
+		cfg.Alipay.QPS = 5
+	}
+	if cfg.Alipay.Burst <= 0 {
+		cfg.Alipay.Burst = 1
+	}
+	if cfg.Alipay.QueueTimeout <= 0 {
+		cfg.Alipay.QueueTimeout = 10
+	}
 
 	if cfg.Database.Type == "" {
 		cfg.Database.Type = "sqlite3"
@@ -197,6 +631,9 @@ func setDefaults(cfg *Config) {
 	if cfg.Database.MaxOpenConns == 0 {
 		cfg.Database.MaxOpenConns = 100
 	}
+	if cfg.Database.SchemaUpgrade == "" {
+		cfg.Database.SchemaUpgrade = "auto"
+	}
 
 	if cfg.Logging.Level == "" {
 		cfg.Logging.Level = "info"
@@ -218,11 +655,183 @@ func setDefaults(cfg *Config) {
 		cfg.Payment.QRCodeMargin = 10
 	}
 
+	if cfg.RateLimit.IPRate == 0 {
+		cfg.RateLimit.IPRate = 10
+	}
+	if cfg.RateLimit.IPBurst == 0 {
+		cfg.RateLimit.IPBurst = 20
+	}
+	if cfg.RateLimit.MerchantRate == 0 {
+		cfg.RateLimit.MerchantRate = 50
+	}
+	if cfg.RateLimit.MerchantBurst == 0 {
+		cfg.RateLimit.MerchantBurst = 100
+	}
+	if cfg.RateLimit.QRCodeIPRate == 0 {
+		cfg.RateLimit.QRCodeIPRate = 5
+	}
+	if cfg.RateLimit.QRCodeIPBurst == 0 {
+		cfg.RateLimit.QRCodeIPBurst = 10
+	}
+	if cfg.RateLimit.QRCodeMaxWait == 0 {
+		cfg.RateLimit.QRCodeMaxWait = 1000
+	}
+
+	if cfg.Admin.SessionLifetime == 0 {
+		cfg.Admin.SessionLifetime = 86400
+	}
+	if cfg.Admin.IdleTimeout == 0 {
+		cfg.Admin.IdleTimeout = 86400
+	}
+	if cfg.Admin.RememberLifetime == 0 {
+		cfg.Admin.RememberLifetime = 30 * 86400
+	}
+	if cfg.Admin.SessionStore == "" {
+		cfg.Admin.SessionStore = "database"
+	}
+	if cfg.Admin.LoginMaxAttempts == 0 {
+		cfg.Admin.LoginMaxAttempts = 5
+	}
+	if cfg.Admin.LoginLockout == 0 {
+		cfg.Admin.LoginLockout = 900
+	}
+
+	if cfg.GRPC.Port == 0 {
+		cfg.GRPC.Port = 9090
+	}
+
+	if cfg.NotifyHealth.Interval == 0 {
+		cfg.NotifyHealth.Interval = 300
+	}
+	if cfg.NotifyHealth.Timeout == 0 {
+		cfg.NotifyHealth.Timeout = 5
+	}
+	if cfg.NotifyHealth.LookbackDays == 0 {
+		cfg.NotifyHealth.LookbackDays = 7
+	}
+
+	if cfg.QRCodeHealth.Interval <= 0 {
+		cfg.QRCodeHealth.Interval = 300
+	}
+	if cfg.QRCodeHealth.FailureThreshold <= 0 {
+		cfg.QRCodeHealth.FailureThreshold = 5
+	}
+	if cfg.QRCodeHealth.NoPaymentHours <= 0 {
+		cfg.QRCodeHealth.NoPaymentHours = 6
+	}
+	if cfg.QRCodeHealth.MinOrders <= 0 {
+		cfg.QRCodeHealth.MinOrders = 10
+	}
+
+	if cfg.Archive.RetentionDays <= 0 {
+		cfg.Archive.RetentionDays = 90
+	}
+	if cfg.Archive.Interval <= 0 {
+		cfg.Archive.Interval = 3600
+	}
+	if cfg.Archive.BatchSize <= 0 {
+		cfg.Archive.BatchSize = 500
+	}
+
+	if cfg.EventOutbox.RetentionDays <= 0 {
+		cfg.EventOutbox.RetentionDays = 7
+	}
+
+	if cfg.WebSocket.PingInterval <= 0 {
+		cfg.WebSocket.PingInterval = 30
+	}
+	if cfg.WebSocket.ReadTimeout <= 0 {
+		cfg.WebSocket.ReadTimeout = 2 * cfg.WebSocket.PingInterval
+	}
+	if cfg.WebSocket.MaxConnections == 0 {
+		cfg.WebSocket.MaxConnections = 10000
+	}
+	if cfg.WebSocket.MaxPerOrder == 0 {
+		cfg.WebSocket.MaxPerOrder = 10
+	}
+
+	if cfg.Branding.SiteName == "" {
+		cfg.Branding.SiteName = "AliMPay"
+	}
+
+	if cfg.Cluster.LeaseTTL <= 0 {
+		cfg.Cluster.LeaseTTL = 15
+	}
+
+	if cfg.GeoIP.Database == "" {
+		cfg.GeoIP.Database = "./data/geoip.csv"
+	}
+
+	if signType, ok := utils.NormalizeSignType(cfg.Merchant.SignType); ok {
+		cfg.Merchant.SignType = signType
+	}
+
+	if cfg.SubmitCaptcha.Provider == "" {
+		cfg.SubmitCaptcha.Provider = "slider"
+	}
+	if cfg.SubmitCaptcha.VelocityWindow <= 0 {
+		cfg.SubmitCaptcha.VelocityWindow = 600
+	}
+
+	if cfg.SubmitBotFilter.Difficulty == 0 {
+		cfg.SubmitBotFilter.Difficulty = 16
+	}
+
+	if cfg.DailyDigest.TopMerchants <= 0 {
+		cfg.DailyDigest.TopMerchants = 5
+	}
+
+	if cfg.Housekeeping.BackupDir == "" {
+		cfg.Housekeeping.BackupDir = "./data/backups"
+	}
+	if cfg.Housekeeping.BackupKeep <= 0 {
+		cfg.Housekeeping.BackupKeep = 7
+	}
+
+	if cfg.LoadTest.MaxOrders == 0 {
+		cfg.LoadTest.MaxOrders = 5000
+	}
+	if cfg.LoadTest.Concurrency == 0 {
+		cfg.LoadTest.Concurrency = 50
+	}
+
+	setHTTPClientDefaults(&cfg.HTTPClient)
+
+	if cfg.Monitor.WorkerCount <= 0 {
+		cfg.Monitor.WorkerCount = 5
+	}
+	if cfg.Monitor.QueueSize <= 0 {
+		cfg.Monitor.QueueSize = 100
+	}
+	if cfg.Monitor.NotifyWorkerCount <= 0 {
+		cfg.Monitor.NotifyWorkerCount = 10
+	}
+	if cfg.Monitor.NotifyQueueSize <= 0 {
+		cfg.Monitor.NotifyQueueSize = 500
+	}
+	if cfg.Monitor.DrainTimeout == 0 {
+		cfg.Monitor.DrainTimeout = 10
+	}
+	if cfg.Monitor.LockBackend == "" {
+		cfg.Monitor.LockBackend = "file"
+	}
+
+	if cfg.Redis.Addr == "" {
+		cfg.Redis.Addr = "127.0.0.1:6379"
+	}
+	if cfg.Redis.OrderCacheTTL == 0 {
+		cfg.Redis.OrderCacheTTL = 60
+	}
+
 	// 设置默认轮询模式
 	if cfg.Payment.BusinessQRMode.PollingMode == "" {
 		cfg.Payment.BusinessQRMode.PollingMode = "round_robin"
 	}
 
+	if cfg.Payment.BusinessQRMode.UploadDir == "" {
+		cfg.Payment.BusinessQRMode.UploadDir = "./qrcode/uploads"
+	}
+
 	// 如果配置了单个二维码路径但没有配置多个二维码，自动转换为多二维码模式
 	if cfg.Payment.BusinessQRMode.QRCodePath != "" && len(cfg.Payment.BusinessQRMode.QRCodePaths) == 0 {
 		cfg.Payment.BusinessQRMode.QRCodePaths = []QRCode{
@@ -239,6 +848,131 @@ func setDefaults(cfg *Config) {
 
 // validate 验证配置
 func validate(cfg *Config) error {
+	if cfg.Payment.PrecreateMode.Enabled && cfg.Payment.BusinessQRMode.Enabled {
+		return fmt.Errorf("payment.precreate_mode and payment.business_qr_mode cannot be enabled at the same time")
+	}
+
+	if cfg.WebSocket.ReadTimeout <= cfg.WebSocket.PingInterval {
+		return fmt.Errorf("websocket.read_timeout (%ds) must be greater than websocket.ping_interval (%ds)",
+			cfg.WebSocket.ReadTimeout, cfg.WebSocket.PingInterval)
+	}
+
+	seenListeners := make(map[string]bool, len(cfg.Server.Listeners))
+	for i, l := range cfg.Server.Listeners {
+		if err := l.validate(); err != nil {
+			return fmt.Errorf("server.listeners[%d]: %w", i, err)
+		}
+		if l.TLS && (cfg.Server.TLSCertFile == "" || cfg.Server.TLSKeyFile == "") {
+			return fmt.Errorf("server.listeners[%d]: tls requires server.tls_cert_file and server.tls_key_file", i)
+		}
+		if seenListeners[l.Address] {
+			return fmt.Errorf("server.listeners[%d]: duplicate address %q", i, l.Address)
+		}
+		seenListeners[l.Address] = true
+	}
+
+	if cfg.Database.SchemaUpgrade != "auto" && cfg.Database.SchemaUpgrade != "manual" {
+		return fmt.Errorf("database.schema_upgrade must be auto or manual, got %q", cfg.Database.SchemaUpgrade)
+	}
+
+	switch cfg.Admin.SessionStore {
+	case "database", "memory", "redis":
+	default:
+		return fmt.Errorf("admin.session_store must be database, memory or redis, got %q", cfg.Admin.SessionStore)
+	}
+
+	for i, qr := range cfg.Payment.BusinessQRMode.QRCodePaths {
+		if qr.MaxDailyAmount < 0 || qr.MaxDailyCount < 0 {
+			return fmt.Errorf("payment.business_qr_mode.qr_code_paths[%d]: max_daily_amount and max_daily_count must not be negative", i)
+		}
+		if qr.MinAmount < 0 || qr.MaxAmount < 0 || (qr.MaxAmount > 0 && qr.MinAmount > qr.MaxAmount) {
+			return fmt.Errorf("payment.business_qr_mode.qr_code_paths[%d]: min_amount and max_amount must not be negative and min_amount must not exceed max_amount", i)
+		}
+	}
+	if cfg.QRCodeHealth.RecoverAfter < 0 {
+		return fmt.Errorf("qrcode_health.recover_after must not be negative, got %d", cfg.QRCodeHealth.RecoverAfter)
+	}
+
+	if cfg.Cluster.LeaseTTL < 3 {
+		return fmt.Errorf("cluster.lease_ttl must be at least 3 seconds, got %d", cfg.Cluster.LeaseTTL)
+	}
+
+	for i, rule := range cfg.GeoIP.Rules {
+		for _, code := range append(append([]string{}, rule.AllowedCountries...), rule.BlockedCountries...) {
+			if !countryCodePattern.MatchString(code) {
+				return fmt.Errorf("geoip.rules[%d]: %q is not a two-letter country code", i, code)
+			}
+		}
+	}
+
+	if captcha := cfg.SubmitCaptcha; captcha.Enabled && (captcha.Provider == "hcaptcha" || captcha.Provider == "turnstile") {
+		if captcha.SiteKey == "" || captcha.SecretKey == "" {
+			return fmt.Errorf("submit_captcha.site_key and submit_captcha.secret_key are required for provider %s", captcha.Provider)
+		}
+	}
+	if difficulty := cfg.SubmitBotFilter.Difficulty; difficulty < 1 || difficulty > botfilter.MaxDifficulty {
+		return fmt.Errorf("submit_bot_filter.difficulty must be between 1 and %d, got %d", botfilter.MaxDifficulty, difficulty)
+	}
+
+	for i, channel := range cfg.Notifier.Channels {
+		if channel.Type != "webhook" && channel.Type != "dingtalk" && channel.Type != "wecom" {
+			return fmt.Errorf("notifier.channels[%d].type must be webhook, dingtalk or wecom, got %q", i, channel.Type)
+		}
+		if channel.URL == "" {
+			return fmt.Errorf("notifier.channels[%d].url is required", i)
+		}
+	}
+	if cfg.DailyDigest.Enabled {
+		if cfg.DailyDigest.Hour < 0 || cfg.DailyDigest.Hour > 23 {
+			return fmt.Errorf("daily_digest.hour must be between 0 and 23, got %d", cfg.DailyDigest.Hour)
+		}
+		if len(cfg.Notifier.Channels) == 0 {
+			return fmt.Errorf("daily_digest requires at least one notifier channel")
+		}
+	}
+
+	jobs := map[string]string{
+		"housekeeping.cleanup":        cfg.Housekeeping.Cleanup,
+		"housekeeping.reconciliation": cfg.Housekeeping.Reconciliation,
+		"housekeeping.stats":          cfg.Housekeeping.Stats,
+		"housekeeping.backup":         cfg.Housekeeping.Backup,
+	}
+	for field, spec := range jobs {
+		if spec == "" {
+			continue
+		}
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return fmt.Errorf("%s: invalid cron expression %q: %w", field, spec, err)
+		}
+	}
+
+	if _, ok := utils.NormalizeSignType(cfg.Merchant.SignType); !ok {
+		return fmt.Errorf("merchant.sign_type must be %s or %s, got %q", utils.SignTypeMD5, utils.SignTypeHMACSHA256, cfg.Merchant.SignType)
+	}
+
+	ipLists := map[string][]string{
+		"server.trusted_proxies":      cfg.Server.TrustedProxies,
+		"merchant.outbound_ips":       cfg.Merchant.OutboundIPs,
+		"merchant.allowed_ips":        cfg.Merchant.AllowedIPs,
+		"submit_captcha.risky_ranges": cfg.SubmitCaptcha.RiskyRanges,
+	}
+	for field, ips := range ipLists {
+		for _, ip := range ips {
+			if !utils.ValidIPOrCIDR(ip) {
+				return fmt.Errorf("%s: %q is not an IP address or CIDR", field, ip)
+			}
+		}
+	}
+
+	if cfg.Branding.PrimaryColor != "" && !brandColorPattern.MatchString(cfg.Branding.PrimaryColor) {
+		return fmt.Errorf("branding.primary_color must be a hex color like #1677ff, got %q", cfg.Branding.PrimaryColor)
+	}
+	if cfg.Branding.TemplateDir != "" {
+		if info, err := os.Stat(cfg.Branding.TemplateDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("branding.template_dir %s is not a directory", cfg.Branding.TemplateDir)
+		}
+	}
+
 	// 创建必要的目录
 	dirs := []string{
 		filepath.Dir(cfg.Database.Path),
@@ -255,9 +989,14 @@ func validate(cfg *Config) error {
 	return nil
 }
 
-// Save 保存配置到文件
+// Save 保存配置到文件（被环境变量覆盖的配置项保存配置文件中的原值，外部密钥保存引用）
 func Save(cfg *Config, configPath string) error {
-	data, err := yaml.Marshal(cfg)
+	fileCfg, err := fileValues(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	data, err := yaml.Marshal(fileCfg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -286,6 +1025,11 @@ func (qr *QRCode) GetEffectiveAlipayConfig(globalConfig *AlipayConfig) *AlipayCo
 		SignType:        qr.AlipayAPI.SignType,
 		Charset:         qr.AlipayAPI.Charset,
 		Format:          qr.AlipayAPI.Format,
+		QPS:             qr.AlipayAPI.QPS,
+		Burst:           qr.AlipayAPI.Burst,
+		QueueTimeout:    globalConfig.QueueTimeout,
+
+		SkipResponseVerify: globalConfig.SkipResponseVerify || qr.AlipayAPI.SkipResponseVerify,
 	}
 
 	// 填充缺失的字段
@@ -313,6 +1057,12 @@ func (qr *QRCode) GetEffectiveAlipayConfig(globalConfig *AlipayConfig) *AlipayCo
 	if merged.Format == "" {
 		merged.Format = globalConfig.Format
 	}
+	if merged.QPS == 0 {
+		merged.QPS = globalConfig.QPS
+	}
+	if merged.Burst == 0 {
+		merged.Burst = globalConfig.Burst
+	}
 
 	return merged
 }
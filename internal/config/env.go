@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量前缀
+// 变量名由前缀和配置项的yaml路径组成，例如 server.port → ALIMPAY_SERVER_PORT，
+// alipay.app_id → ALIMPAY_ALIPAY_APP_ID；列表中的二维码按下标访问，
+// 例如 ALIMPAY_PAYMENT_BUSINESS_QR_MODE_QR_CODE_PATHS_0_ALIPAY_API_PRIVATE_KEY
+const EnvPrefix = "ALIMPAY"
+
+// applyEnvOverrides 使用环境变量覆盖配置项，并记录被覆盖项的原值（保存配置时写回原值，避免密钥写入配置文件）
+func applyEnvOverrides(cfg *Config) error {
+	overrides := make(map[string]interface{})
+
+	err := walkEnv(reflect.ValueOf(cfg).Elem(), EnvPrefix, true, func(name string, field reflect.Value) error {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+
+		original := field.Interface()
+		if err := setFromEnv(field, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		overrides[name] = original
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.envOverrides = overrides
+	return nil
+}
+
+// EnvOverrides 获取被环境变量覆盖的配置项（变量名）
+func (c *Config) EnvOverrides() []string {
+	names := make([]string, 0, len(c.envOverrides))
+	for name := range c.envOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileValues 复制配置并还原为配置文件中的写法（外部密钥还原为引用，被环境变量覆盖的配置项还原为原值）
+func fileValues(cfg *Config) (*Config, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var clone Config
+	if err := yaml.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+
+	err = walkEnv(reflect.ValueOf(&clone).Elem(), EnvPrefix, false, func(name string, field reflect.Value) error {
+		if ref, ok := cfg.secretRefs[name]; ok {
+			field.Set(reflect.ValueOf(ref))
+		}
+		if original, ok := cfg.envOverrides[name]; ok {
+			field.Set(reflect.ValueOf(original))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &clone, nil
+}
+
+// walkEnv 遍历配置中的每个叶子字段及其对应的环境变量名
+// allocate 为true时，若存在以该结构体为前缀的环境变量，则为nil的结构体指针分配空间
+func walkEnv(v reflect.Value, name string, allocate bool, fn func(name string, field reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue // 未导出字段
+			}
+			tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			if err := walkEnv(v.Field(i), name+"_"+strings.ToUpper(tag), allocate, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Ptr:
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		if v.IsNil() {
+			if !allocate || !hasEnvWithPrefix(name+"_") {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return walkEnv(v.Elem(), name, allocate, fn)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < v.Len(); i++ {
+				if err := walkEnv(v.Index(i), fmt.Sprintf("%s_%d", name, i), allocate, fn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		return fn(name, v)
+
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return fn(name, v)
+	}
+
+	return nil
+}
+
+// hasEnvWithPrefix 是否存在以指定前缀开头的环境变量
+func hasEnvWithPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFromEnv 将环境变量的值写入字段（列表使用逗号分隔）
+func setFromEnv(field reflect.Value, value string) error {
+	// 自定义类型（如金额）按其文本格式解析
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(strings.TrimSpace(value)))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"time"
+
+	"alimpay-go/internal/pkg/httpclient"
+)
+
+// HTTPClientConfig 出站HTTP请求配置（支付宝网关、商户通知、在线二维码API共享连接池）
+type HTTPClientConfig struct {
+	Proxy               string `yaml:"proxy"`                   // 代理地址（http://、https://、socks5://），为空时读取 HTTP_PROXY/HTTPS_PROXY，"none" 不使用代理
+	MaxIdleConns        int    `yaml:"max_idle_conns"`          // 最大空闲连接数
+	MaxIdleConnsPerHost int    `yaml:"max_idle_conns_per_host"` // 每个主机最大空闲连接数
+	IdleConnTimeout     int    `yaml:"idle_conn_timeout"`       // 空闲连接超时（秒）
+	DNSCacheTTL         int    `yaml:"dns_cache_ttl"`           // DNS缓存有效期（秒），负数表示不缓存
+
+	DialTimeout           int    `yaml:"dial_timeout"`            // 建立连接超时（秒）
+	TLSHandshakeTimeout   int    `yaml:"tls_handshake_timeout"`   // TLS握手超时（秒）
+	ResponseHeaderTimeout int    `yaml:"response_header_timeout"` // 等待响应头超时（秒），0 表示只受各目标的请求超时限制
+	CAFile                string `yaml:"ca_file"`                 // 额外信任的CA证书文件（PEM），用于解密HTTPS流量的企业代理
+	LocalAddress          string `yaml:"local_address"`           // 出站请求使用的本机IP或网卡名，为空时由系统按路由选择
+
+	Alipay    HTTPDestinationConfig `yaml:"alipay"`     // 支付宝网关
+	Notify    HTTPDestinationConfig `yaml:"notify"`     // 商户异步通知
+	QRCodeAPI HTTPDestinationConfig `yaml:"qrcode_api"` // 在线二维码API
+	Captcha   HTTPDestinationConfig `yaml:"captcha"`    // 人机验证服务
+	Notifier  HTTPDestinationConfig `yaml:"notifier"`   // 运营通知渠道
+}
+
+// HTTPDestinationConfig 单个出站目标的配置
+type HTTPDestinationConfig struct {
+	Timeout      int    `yaml:"timeout"`       // 请求超时（秒）
+	Proxy        string `yaml:"proxy"`         // 为空时使用 http_client.proxy
+	LocalAddress string `yaml:"local_address"` // 为空时使用 http_client.local_address
+}
+
+// setHTTPClientDefaults 设置出站HTTP请求默认值
+func setHTTPClientDefaults(c *HTTPClientConfig) {
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = 100
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = 10
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = 90
+	}
+	if c.DNSCacheTTL == 0 {
+		c.DNSCacheTTL = 60
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = 30
+	}
+	if c.TLSHandshakeTimeout == 0 {
+		c.TLSHandshakeTimeout = 10
+	}
+	if c.Alipay.Timeout == 0 {
+		c.Alipay.Timeout = 30
+	}
+	if c.Notify.Timeout == 0 {
+		c.Notify.Timeout = 10
+	}
+	if c.QRCodeAPI.Timeout == 0 {
+		c.QRCodeAPI.Timeout = 10
+	}
+	if c.Captcha.Timeout == 0 {
+		c.Captcha.Timeout = 10
+	}
+	if c.Notifier.Timeout == 0 {
+		c.Notifier.Timeout = 10
+	}
+}
+
+// Options 转换为共享HTTP客户端配置
+func (c *HTTPClientConfig) Options() httpclient.Options {
+	opts := httpclient.Options{
+		Proxy:               c.Proxy,
+		MaxIdleConns:        c.MaxIdleConns,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(c.IdleConnTimeout) * time.Second,
+
+		DialTimeout:           time.Duration(c.DialTimeout) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(c.TLSHandshakeTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(c.ResponseHeaderTimeout) * time.Second,
+		CAFile:                c.CAFile,
+		LocalAddress:          c.LocalAddress,
+
+		Destinations: map[string]httpclient.Destination{
+			httpclient.Alipay:    c.Alipay.destination(),
+			httpclient.Notify:    c.Notify.destination(),
+			httpclient.QRCodeAPI: c.QRCodeAPI.destination(),
+			httpclient.Captcha:   c.Captcha.destination(),
+			httpclient.Notifier:  c.Notifier.destination(),
+		},
+	}
+	if c.DNSCacheTTL > 0 {
+		opts.DNSCacheTTL = time.Duration(c.DNSCacheTTL) * time.Second
+	}
+	return opts
+}
+
+// destination 转换为目标配置
+func (d HTTPDestinationConfig) destination() httpclient.Destination {
+	return httpclient.Destination{
+		Timeout:      time.Duration(d.Timeout) * time.Second,
+		Proxy:        d.Proxy,
+		LocalAddress: d.LocalAddress,
+	}
+}
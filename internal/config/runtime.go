@@ -0,0 +1,75 @@
+package config
+
+import "sync"
+
+// runtimeMu 保护运行时可调整的配置项
+// 配置热加载和管理后台调整Worker池时写入，处理请求和监听周期中读取，其他配置项启动后只读
+// 金额偏移 payment.business_qr_mode.amount_offset 由金额锁（lock.GetAmountLock）保护
+var runtimeMu sync.RWMutex
+
+// MonitorSnapshot 获取监听配置的副本（监听间隔、Worker池大小可在运行时调整）
+func (c *Config) MonitorSnapshot() MonitorConfig {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return c.Monitor
+}
+
+// SetMonitorInterval 更新监听间隔（秒）
+func (c *Config) SetMonitorInterval(seconds int) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	c.Monitor.Interval = seconds
+}
+
+// SetWorkerPoolSize 更新账单匹配Worker池大小
+func (c *Config) SetWorkerPoolSize(workerCount, queueSize int) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	c.Monitor.WorkerCount = workerCount
+	c.Monitor.QueueSize = queueSize
+}
+
+// SetNotifyPoolSize 更新商户通知Worker池大小
+func (c *Config) SetNotifyPoolSize(workerCount, queueSize int) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	c.Monitor.NotifyWorkerCount = workerCount
+	c.Monitor.NotifyQueueSize = queueSize
+}
+
+// LoggingLevel 获取日志级别
+func (c *Config) LoggingLevel() string {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return c.Logging.Level
+}
+
+// SetLoggingLevel 更新日志级别
+func (c *Config) SetLoggingLevel(level string) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	c.Logging.Level = level
+}
+
+// BusinessQRCodes 获取经营码列表的副本
+func (c *Config) BusinessQRCodes() []QRCode {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return append([]QRCode(nil), c.Payment.BusinessQRMode.QRCodePaths...)
+}
+
+// DefaultBusinessQRCode 获取默认经营码的图片路径和收款码ID
+func (c *Config) DefaultBusinessQRCode() (string, string) {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return c.Payment.BusinessQRMode.QRCodePath, c.Payment.BusinessQRMode.QRCodeID
+}
+
+// SetBusinessQRCodes 更新经营码列表和默认经营码
+func (c *Config) SetBusinessQRCodes(qrCodes []QRCode, path, codeID string) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	c.Payment.BusinessQRMode.QRCodePaths = append([]QRCode(nil), qrCodes...)
+	c.Payment.BusinessQRMode.QRCodePath = path
+	c.Payment.BusinessQRMode.QRCodeID = codeID
+}
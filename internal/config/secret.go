@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/pkg/secret"
+)
+
+// SecretsConfig 外部密钥来源配置
+// 密钥类配置项可写为引用而非明文：file:/run/secrets/alipay_key、env:ALIPAY_KEY、vault:secret/data/alimpay#private_key
+type SecretsConfig struct {
+	Vault VaultConfig `yaml:"vault"`
+}
+
+// VaultConfig Vault配置（address 为空时不启用 vault: 引用）
+type VaultConfig struct {
+	Address string `yaml:"address"` // 例如 https://vault.example.com:8200
+	Token   string `yaml:"token"`   // 为空时读取 VAULT_TOKEN 环境变量
+	Timeout int    `yaml:"timeout"` // 请求超时（秒）
+}
+
+// resolveSecrets 将密钥类配置项中的引用替换为实际值，并记录原引用（保存配置时写回引用）
+func resolveSecrets(cfg *Config) error {
+	if vault := cfg.Secrets.Vault; vault.Address != "" {
+		token := vault.Token
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		timeout := time.Duration(vault.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		secret.Register("vault", secret.NewVaultProvider(vault.Address, token, timeout))
+	}
+
+	fields := map[string]*string{
+		"alipay.private_key":        &cfg.Alipay.PrivateKey,
+		"alipay.alipay_public_key":  &cfg.Alipay.AlipayPublicKey,
+		"merchant.key":              &cfg.Merchant.Key,
+		"merchant.notify_secret":    &cfg.Merchant.NotifySecret,
+		"submit_captcha.secret_key": &cfg.SubmitCaptcha.SecretKey,
+		"submit_bot_filter.secret":  &cfg.SubmitBotFilter.Secret,
+	}
+	for i := range cfg.Notifier.Channels {
+		prefix := fmt.Sprintf("notifier.channels.%d.", i)
+		fields[prefix+"url"] = &cfg.Notifier.Channels[i].URL
+		fields[prefix+"secret"] = &cfg.Notifier.Channels[i].Secret
+	}
+	for i := range cfg.Payment.BusinessQRMode.QRCodePaths {
+		api := cfg.Payment.BusinessQRMode.QRCodePaths[i].AlipayAPI
+		if api == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("payment.business_qr_mode.qr_code_paths.%d.alipay_api.", i)
+		fields[prefix+"private_key"] = &api.PrivateKey
+		fields[prefix+"alipay_public_key"] = &api.AlipayPublicKey
+	}
+
+	refs := make(map[string]interface{})
+	for path, value := range fields {
+		resolved, isRef, err := secret.Resolve(*value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if isRef {
+			refs[envName(path)] = *value
+			*value = resolved
+		}
+	}
+
+	cfg.secretRefs = refs
+	return nil
+}
+
+// envName 配置项路径对应的环境变量名（也用作配置项的唯一标识）
+func envName(path string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// adminAPITokenColumns 管理后台API令牌查询列
+const adminAPITokenColumns = `id, name, token_hash, prefix, scope, created_by, created_at, expires_at, last_used_at, last_used_ip`
+
+// initAdminAPITokenTable 创建管理后台API令牌表
+func (db *DB) initAdminAPITokenTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS admin_api_tokens (
+		id VARCHAR(32) PRIMARY KEY,
+		name VARCHAR(64) NOT NULL,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		prefix VARCHAR(16) NOT NULL,
+		scope VARCHAR(16) NOT NULL,
+		created_by VARCHAR(32) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		last_used_ip VARCHAR(64) NOT NULL DEFAULT ''
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create admin_api_tokens table: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAdminAPIToken 保存API令牌
+func (db *DB) CreateAdminAPIToken(token *model.AdminAPIToken) error {
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO admin_api_tokens (id, name, token_hash, prefix, scope, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.ID, token.Name, token.TokenHash, token.Prefix, token.Scope, token.CreatedBy, token.CreatedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create admin api token: %w", err)
+	}
+	return nil
+}
+
+// GetAdminAPITokenByHash 按令牌摘要查询API令牌，不存在时返回nil
+func (db *DB) GetAdminAPITokenByHash(tokenHash string) (*model.AdminAPIToken, error) {
+	token, err := scanAdminAPIToken(db.QueryRow(
+		"SELECT "+adminAPITokenColumns+" FROM admin_api_tokens WHERE token_hash = ?", tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin api token: %w", err)
+	}
+	return token, nil
+}
+
+// ListAdminAPITokens 获取全部API令牌（按创建时间排序）
+func (db *DB) ListAdminAPITokens() ([]*model.AdminAPIToken, error) {
+	rows, err := db.Query("SELECT " + adminAPITokenColumns + " FROM admin_api_tokens ORDER BY created_at")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]*model.AdminAPIToken, 0)
+	for rows.Next() {
+		token, err := scanAdminAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan admin api token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAdminAPIToken 删除API令牌，令牌不存在时返回false
+func (db *DB) DeleteAdminAPIToken(id string) (bool, error) {
+	result, err := db.Exec("DELETE FROM admin_api_tokens WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete admin api token: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// UpdateAdminAPITokenLastUsed 记录API令牌的最后使用时间和客户端IP
+func (db *DB) UpdateAdminAPITokenLastUsed(id string, usedAt time.Time, ip string) error {
+	if _, err := db.Exec("UPDATE admin_api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?", usedAt, ip, id); err != nil {
+		return fmt.Errorf("failed to update admin api token last used: %w", err)
+	}
+	return nil
+}
+
+// scanAdminAPIToken 扫描一行API令牌记录
+func scanAdminAPIToken(row rowScanner) (*model.AdminAPIToken, error) {
+	var token model.AdminAPIToken
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.Name, &token.TokenHash, &token.Prefix, &token.Scope, &token.CreatedBy,
+		&token.CreatedAt, &expiresAt, &lastUsedAt, &token.LastUsedIP)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return &token, nil
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initAdminSessionTables 创建管理后台会话表和刷新令牌表（重启、发布后已登录的会话仍有效）
+func (db *DB) initAdminSessionTables() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS admin_sessions (
+		token VARCHAR(160) PRIMARY KEY,
+		id VARCHAR(32) NOT NULL,
+		merchant_id VARCHAR(32) NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		last_access DATETIME NOT NULL,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent VARCHAR(512) NOT NULL DEFAULT '',
+		refresh_token VARCHAR(160) NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS admin_refresh_tokens (
+		token VARCHAR(160) PRIMARY KEY,
+		merchant_id VARCHAR(32) NOT NULL,
+		expires_at DATETIME NOT NULL,
+		session_id VARCHAR(32) NOT NULL DEFAULT ''
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create admin session tables: %w", err)
+	}
+
+	// 管理员账号登录（已存在的列忽略错误）
+	_, _ = db.Exec(`ALTER TABLE admin_sessions ADD COLUMN username VARCHAR(32) NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE admin_sessions ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE admin_refresh_tokens ADD COLUMN username VARCHAR(32) NOT NULL DEFAULT '';`)
+
+	return nil
+}
+
+// SaveAdminSession 保存会话（存在则覆盖）
+func (db *DB) SaveAdminSession(session *model.AdminSession) error {
+	_, err := db.Exec(`
+		INSERT INTO admin_sessions (token, id, merchant_id, username, role, created_at, expires_at, last_access, ip, user_agent, refresh_token)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(token) DO UPDATE SET
+			last_access = excluded.last_access,
+			ip = excluded.ip,
+			refresh_token = excluded.refresh_token`,
+		session.Token, session.ID, session.MerchantID, session.Username, session.Role, session.CreatedAt, session.ExpiresAt,
+		session.LastAccess, session.IP, session.UserAgent, session.RefreshToken,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save admin session: %w", err)
+	}
+	return nil
+}
+
+// GetAdminSession 获取会话，不存在时返回nil
+func (db *DB) GetAdminSession(token string) (*model.AdminSession, error) {
+	session, err := scanAdminSession(db.QueryRow(`
+		SELECT token, id, merchant_id, username, role, created_at, expires_at, last_access, ip, user_agent, refresh_token
+		FROM admin_sessions WHERE token = ?`, token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin session: %w", err)
+	}
+	return session, nil
+}
+
+// ListAdminSessions 获取全部会话（含已过期但尚未清理的会话）
+func (db *DB) ListAdminSessions() ([]*model.AdminSession, error) {
+	rows, err := db.Query(`
+		SELECT token, id, merchant_id, username, role, created_at, expires_at, last_access, ip, user_agent, refresh_token
+		FROM admin_sessions`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]*model.AdminSession, 0)
+	for rows.Next() {
+		session, err := scanAdminSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan admin session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteAdminSession 删除会话
+func (db *DB) DeleteAdminSession(token string) error {
+	if _, err := db.Exec("DELETE FROM admin_sessions WHERE token = ?", token); err != nil {
+		return fmt.Errorf("failed to delete admin session: %w", err)
+	}
+	return nil
+}
+
+// SaveAdminRefreshToken 保存刷新令牌（存在则覆盖）
+func (db *DB) SaveAdminRefreshToken(refresh *model.AdminRefreshToken) error {
+	_, err := db.Exec(`
+		INSERT INTO admin_refresh_tokens (token, merchant_id, username, expires_at, session_id)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(token) DO UPDATE SET session_id = excluded.session_id`,
+		refresh.Token, refresh.MerchantID, refresh.Username, refresh.ExpiresAt, refresh.SessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save admin refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetAdminRefreshToken 获取刷新令牌，不存在时返回nil
+func (db *DB) GetAdminRefreshToken(token string) (*model.AdminRefreshToken, error) {
+	var refresh model.AdminRefreshToken
+	err := db.QueryRow(`
+		SELECT token, merchant_id, username, expires_at, session_id
+		FROM admin_refresh_tokens WHERE token = ?`, token).
+		Scan(&refresh.Token, &refresh.MerchantID, &refresh.Username, &refresh.ExpiresAt, &refresh.SessionID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin refresh token: %w", err)
+	}
+	return &refresh, nil
+}
+
+// DeleteAdminRefreshToken 删除刷新令牌
+func (db *DB) DeleteAdminRefreshToken(token string) error {
+	if _, err := db.Exec("DELETE FROM admin_refresh_tokens WHERE token = ?", token); err != nil {
+		return fmt.Errorf("failed to delete admin refresh token: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredAdminSessions 删除已过期（超过最长有效期或无操作超时）的会话和已过期的刷新令牌
+// 返回删除的会话数
+func (db *DB) DeleteExpiredAdminSessions(idleTimeout time.Duration) (int64, error) {
+	now := time.Now()
+	result, err := db.Exec("DELETE FROM admin_sessions WHERE expires_at < ? OR last_access < ?",
+		now, now.Add(-idleTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired admin sessions: %w", err)
+	}
+	if _, err := db.Exec("DELETE FROM admin_refresh_tokens WHERE expires_at < ?", now); err != nil {
+		return 0, fmt.Errorf("failed to delete expired admin refresh tokens: %w", err)
+	}
+
+	count, _ := result.RowsAffected()
+	return count, nil
+}
+
+// ClearAdminSessions 删除全部会话和刷新令牌（签名密钥轮换后）
+func (db *DB) ClearAdminSessions() error {
+	if _, err := db.Exec("DELETE FROM admin_sessions; DELETE FROM admin_refresh_tokens;"); err != nil {
+		return fmt.Errorf("failed to clear admin sessions: %w", err)
+	}
+	return nil
+}
+
+// scanAdminSession 扫描一行会话记录
+func scanAdminSession(row rowScanner) (*model.AdminSession, error) {
+	var session model.AdminSession
+	err := row.Scan(&session.Token, &session.ID, &session.MerchantID, &session.Username, &session.Role, &session.CreatedAt, &session.ExpiresAt,
+		&session.LastAccess, &session.IP, &session.UserAgent, &session.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initAdminTwoFactorTable 创建管理后台两步验证表（每个账号独立的密钥和恢复码）和已使用的登录令牌表
+func (db *DB) initAdminTwoFactorTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS admin_two_factor (
+		account VARCHAR(32) PRIMARY KEY,
+		secret VARCHAR(64) NOT NULL DEFAULT '',
+		pending_secret VARCHAR(64) NOT NULL DEFAULT '',
+		recovery_codes TEXT NOT NULL DEFAULT '',
+		last_step INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS admin_login_challenges (
+		nonce VARCHAR(64) PRIMARY KEY,
+		expires_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_admin_login_challenges_expires_at ON admin_login_challenges(expires_at);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create admin_two_factor table: %w", err)
+	}
+
+	return db.migrateAdminTwoFactorSettings()
+}
+
+// migrateAdminTwoFactorSettings 旧版本的两步验证密钥保存在系统设置中（全部账号共用），
+// 迁移为商户ID和密钥登录（账号名为空）的密钥后删除
+func (db *DB) migrateAdminTwoFactorSettings() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO admin_two_factor (account, secret, recovery_codes, last_step, updated_at)
+		SELECT '', value,
+			COALESCE((SELECT value FROM system_settings WHERE key = ?), ''),
+			CAST(COALESCE((SELECT value FROM system_settings WHERE key = ?), '0') AS INTEGER),
+			?
+		FROM system_settings WHERE key = ? AND value != ''`,
+		SettingAdminRecoveryCodes, SettingAdminTOTPLastStep, time.Now(), SettingAdminTOTPSecret,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to migrate admin two-factor settings: %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM system_settings WHERE key IN (?, ?, ?, ?)",
+		SettingAdminTOTPSecret, SettingAdminTOTPPending, SettingAdminTOTPLastStep, SettingAdminRecoveryCodes)
+	if err != nil {
+		return fmt.Errorf("failed to delete admin two-factor settings: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit admin two-factor migration: %w", err)
+	}
+	return nil
+}
+
+// GetAdminTwoFactor 查询账号的两步验证，不存在时返回nil
+func (db *DB) GetAdminTwoFactor(account string) (*model.AdminTwoFactor, error) {
+	var tf model.AdminTwoFactor
+	err := db.QueryRow(`
+		SELECT account, secret, pending_secret, recovery_codes, last_step, updated_at
+		FROM admin_two_factor WHERE account = ?`, account,
+	).Scan(&tf.Account, &tf.Secret, &tf.PendingSecret, &tf.RecoveryCodes, &tf.LastStep, &tf.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin two-factor: %w", err)
+	}
+	return &tf, nil
+}
+
+// SaveAdminTwoFactor 保存账号的两步验证（存在则覆盖）
+func (db *DB) SaveAdminTwoFactor(tf *model.AdminTwoFactor) error {
+	tf.UpdatedAt = time.Now()
+	_, err := db.Exec(`
+		INSERT INTO admin_two_factor (account, secret, pending_secret, recovery_codes, last_step, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account) DO UPDATE SET
+			secret = excluded.secret, pending_secret = excluded.pending_secret,
+			recovery_codes = excluded.recovery_codes, last_step = excluded.last_step, updated_at = excluded.updated_at`,
+		tf.Account, tf.Secret, tf.PendingSecret, tf.RecoveryCodes, tf.LastStep, tf.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save admin two-factor: %w", err)
+	}
+	return nil
+}
+
+// DeleteAdminTwoFactor 删除账号的两步验证（关闭或重置），不存在时返回false
+func (db *DB) DeleteAdminTwoFactor(account string) (bool, error) {
+	result, err := db.Exec("DELETE FROM admin_two_factor WHERE account = ?", account)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete admin two-factor: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// UseAdminTOTPStep 记录账号使用的动态验证码时间步，不晚于已使用的时间步时不修改并返回false（防止重放）
+func (db *DB) UseAdminTOTPStep(account string, step int64) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE admin_two_factor SET last_step = ?, updated_at = ?
+		WHERE account = ? AND secret != '' AND last_step < ?`,
+		step, time.Now(), account, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to use admin totp step: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// UseAdminRecoveryCode 将账号的恢复码从 previous 替换为 remaining，
+// 恢复码已被修改（同一恢复码被并发使用）时不修改并返回false
+func (db *DB) UseAdminRecoveryCode(account, previous, remaining string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE admin_two_factor SET recovery_codes = ?, updated_at = ?
+		WHERE account = ? AND secret != '' AND recovery_codes = ?`,
+		remaining, time.Now(), account, previous,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to use admin recovery code: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// ConsumeAdminLoginChallenge 记录已使用的两步验证登录令牌，已使用过时返回false（同时删除已过期的记录）
+func (db *DB) ConsumeAdminLoginChallenge(nonce string, expiresAt time.Time) (bool, error) {
+	if _, err := db.Exec("DELETE FROM admin_login_challenges WHERE expires_at < ?", time.Now()); err != nil {
+		return false, fmt.Errorf("failed to delete expired admin login challenges: %w", err)
+	}
+
+	result, err := db.Exec("INSERT OR IGNORE INTO admin_login_challenges (nonce, expires_at) VALUES (?, ?)", nonce, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume admin login challenge: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
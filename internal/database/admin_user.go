@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// adminUserColumns 管理员账号查询列（含是否已开启两步验证）
+const adminUserColumns = `username, password_hash, role, disabled, created_at, last_login_at,
+	EXISTS (SELECT 1 FROM admin_two_factor t WHERE t.account = admin_users.username AND t.secret != '')`
+
+// initAdminUserTable 创建管理员账号表
+func (db *DB) initAdminUserTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS admin_users (
+		username VARCHAR(32) PRIMARY KEY,
+		password_hash VARCHAR(128) NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		disabled TINYINT(1) NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		last_login_at DATETIME
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create admin_users table: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAdminUser 创建管理员账号，用户名已存在时不保存并返回false
+func (db *DB) CreateAdminUser(user *model.AdminUser) (bool, error) {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+
+	result, err := db.Exec(`
+		INSERT OR IGNORE INTO admin_users (username, password_hash, role, disabled, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		user.Username, user.PasswordHash, user.Role, user.Disabled, user.CreatedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create admin user: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// GetAdminUser 按用户名查询管理员账号，不存在时返回nil
+func (db *DB) GetAdminUser(username string) (*model.AdminUser, error) {
+	user, err := scanAdminUser(db.QueryRow(
+		"SELECT "+adminUserColumns+" FROM admin_users WHERE username = ?", username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin user: %w", err)
+	}
+	return user, nil
+}
+
+// ListAdminUsers 获取全部管理员账号（按创建时间排序）
+func (db *DB) ListAdminUsers() ([]*model.AdminUser, error) {
+	rows, err := db.Query("SELECT " + adminUserColumns + " FROM admin_users ORDER BY created_at")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*model.AdminUser, 0)
+	for rows.Next() {
+		user, err := scanAdminUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan admin user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateAdminUser 更新管理员账号的密码哈希、角色和停用状态，账号不存在时返回false
+func (db *DB) UpdateAdminUser(user *model.AdminUser) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE admin_users SET password_hash = ?, role = ?, disabled = ?
+		WHERE username = ?`,
+		user.PasswordHash, user.Role, user.Disabled, user.Username,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update admin user: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// DeleteAdminUser 删除管理员账号及其两步验证，账号不存在时返回false
+func (db *DB) DeleteAdminUser(username string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM admin_users WHERE username = ?", username)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete admin user: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM admin_two_factor WHERE account = ?", username); err != nil {
+		return false, fmt.Errorf("failed to delete admin user two-factor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit admin user deletion: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// UpdateAdminUserLastLogin 记录管理员账号的最后登录时间
+func (db *DB) UpdateAdminUserLastLogin(username string, loginAt time.Time) error {
+	if _, err := db.Exec("UPDATE admin_users SET last_login_at = ? WHERE username = ?", loginAt, username); err != nil {
+		return fmt.Errorf("failed to update admin user last login: %w", err)
+	}
+	return nil
+}
+
+// scanAdminUser 扫描一行管理员账号记录
+func scanAdminUser(row rowScanner) (*model.AdminUser, error) {
+	var user model.AdminUser
+	var lastLoginAt sql.NullTime
+	err := row.Scan(&user.Username, &user.PasswordHash, &user.Role, &user.Disabled, &user.CreatedAt, &lastLoginAt, &user.TwoFactor)
+	if err != nil {
+		return nil, err
+	}
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+	return &user, nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initAuditLogTable 创建审计日志表
+func (db *DB) initAuditLogTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS audit_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action VARCHAR(64) NOT NULL,
+		actor VARCHAR(128) NOT NULL,
+		ip VARCHAR(64),
+		user_agent VARCHAR(255),
+		method VARCHAR(10),
+		path VARCHAR(255),
+		detail TEXT,
+		status INTEGER DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create audit_logs table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_audit_created_at ON audit_logs(created_at);"); err != nil {
+		return fmt.Errorf("failed to create audit_logs index: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAuditLog 写入审计日志
+func (db *DB) CreateAuditLog(log *model.AuditLog) error {
+	result, err := db.Exec(`
+		INSERT INTO audit_logs (action, actor, ip, user_agent, method, path, detail, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, log.Action, log.Actor, log.IP, log.UserAgent, log.Method, log.Path, log.Detail, log.Status, log.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	log.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// ExportAuditLogs 按时间顺序逐行读取 [start, end) 内的审计日志
+func (db *DB) ExportAuditLogs(start, end time.Time, fn func(*model.AuditLog) error) error {
+	rows, err := db.Query(`
+		SELECT id, action, actor, ip, user_agent, method, path, detail, status, created_at
+		FROM audit_logs
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY id ASC
+	`, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to export audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log model.AuditLog
+		err := rows.Scan(&log.ID, &log.Action, &log.Actor, &log.IP, &log.UserAgent,
+			&log.Method, &log.Path, &log.Detail, &log.Status, &log.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit log: %w", err)
+		}
+
+		if err := fn(&log); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Backup 将数据库在线备份到指定文件（VACUUM INTO，备份期间不阻塞读写）
+// 目标文件已存在时返回错误
+func (db *DB) Backup(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create backup dir: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file already exists: %s", path)
+	}
+
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to backup database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// maxCrashReports 崩溃报告保留条数（超出后删除最早的记录）
+const maxCrashReports = 500
+
+// initCrashReportTable 创建崩溃报告表
+func (db *DB) initCrashReportTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS crash_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		request_id VARCHAR(32),
+		method VARCHAR(10),
+		path VARCHAR(255),
+		query TEXT,
+		ip VARCHAR(64),
+		user_agent VARCHAR(255),
+		error TEXT NOT NULL,
+		stack TEXT,
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create crash_reports table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_crash_created_at ON crash_reports(created_at);"); err != nil {
+		return fmt.Errorf("failed to create crash_reports index: %w", err)
+	}
+
+	return nil
+}
+
+// CreateCrashReport 写入崩溃报告，并只保留最近 maxCrashReports 条
+func (db *DB) CreateCrashReport(report *model.CrashReport) error {
+	result, err := db.Exec(`
+		INSERT INTO crash_reports (request_id, method, path, query, ip, user_agent, error, stack, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, report.RequestID, report.Method, report.Path, report.Query, report.IP, report.UserAgent,
+		report.Error, report.Stack, report.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create crash report: %w", err)
+	}
+
+	report.ID, _ = result.LastInsertId()
+
+	if _, err := db.Exec("DELETE FROM crash_reports WHERE id <= ?", report.ID-maxCrashReports); err != nil {
+		return fmt.Errorf("failed to prune crash reports: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecentCrashReports 获取最近的崩溃报告（按时间倒序），withStack 为false时不读取堆栈
+func (db *DB) GetRecentCrashReports(limit int, withStack bool) ([]*model.CrashReport, error) {
+	stackColumn := "''"
+	if withStack {
+		stackColumn = "stack"
+	}
+
+	rows, err := db.Query(`
+		SELECT id, request_id, method, path, query, ip, user_agent, error, `+stackColumn+`, created_at
+		FROM crash_reports
+		ORDER BY id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get crash reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]*model.CrashReport, 0)
+	for rows.Next() {
+		var report model.CrashReport
+		err := rows.Scan(&report.ID, &report.RequestID, &report.Method, &report.Path, &report.Query,
+			&report.IP, &report.UserAgent, &report.Error, &report.Stack, &report.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan crash report: %w", err)
+		}
+		reports = append(reports, &report)
+	}
+
+	return reports, rows.Err()
+}
+
+// CountCrashReportsSince 统计指定时间之后的崩溃次数
+func (db *DB) CountCrashReportsSince(since time.Time) (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM crash_reports WHERE created_at >= ?", since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count crash reports: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// DailyStats 每日订单统计（按订单创建日期和商户汇总，含已归档订单）
+type DailyStats struct {
+	Date      string       `json:"date"` // 2006-01-02
+	PID       string       `json:"pid"`
+	Total     int          `json:"total"`
+	Paid      int          `json:"paid"`
+	Expired   int          `json:"expired"`
+	Disputed  int          `json:"disputed"`
+	Revenue   model.Amount `json:"revenue"` // 已支付订单实收金额合计（不含争议订单）
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// initDailyStatsTable 创建每日订单统计表
+func (db *DB) initDailyStatsTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS order_daily_stats (
+		date VARCHAR(10) NOT NULL,
+		pid VARCHAR(20) NOT NULL,
+		total INTEGER NOT NULL DEFAULT 0,
+		paid INTEGER NOT NULL DEFAULT 0,
+		expired INTEGER NOT NULL DEFAULT 0,
+		disputed INTEGER NOT NULL DEFAULT 0,
+		revenue INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (date, pid)
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create order_daily_stats table: %w", err)
+	}
+	return nil
+}
+
+// RebuildDailyStats 重新汇总指定日期（本地时区）的订单统计，返回汇总的商户数
+// 订单表和归档表一起统计，订单归档后统计不变
+func (db *DB) RebuildDailyStats(day time.Time) (int, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	date := start.Format("2006-01-02")
+
+	rows, err := db.Query(`
+		SELECT
+			pid,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(disputed), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND disputed = 0 THEN payment_amount END), 0)
+		FROM (
+			SELECT pid, status, disputed, payment_amount FROM codepay_orders WHERE add_time >= ? AND add_time < ?
+			UNION ALL
+			SELECT pid, status, disputed, payment_amount FROM codepay_orders_archive WHERE add_time >= ? AND add_time < ?
+		)
+		GROUP BY pid
+	`, model.OrderStatusPaid, model.OrderStatusExpired, model.OrderStatusPaid, start, end, start, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate daily stats: %w", err)
+	}
+
+	var list []*DailyStats
+	for rows.Next() {
+		stats := DailyStats{Date: date}
+		if err := rows.Scan(&stats.PID, &stats.Total, &stats.Paid, &stats.Expired, &stats.Disputed, &stats.Revenue); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan daily stats: %w", err)
+		}
+		list = append(list, &stats)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM order_daily_stats WHERE date = ?", date); err != nil {
+		return 0, fmt.Errorf("failed to clear daily stats: %w", err)
+	}
+	now := time.Now()
+	for _, stats := range list {
+		_, err := tx.Exec(`
+			INSERT INTO order_daily_stats (date, pid, total, paid, expired, disputed, revenue, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, stats.Date, stats.PID, stats.Total, stats.Paid, stats.Expired, stats.Disputed, stats.Revenue, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save daily stats: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit daily stats: %w", err)
+	}
+	return len(list), nil
+}
+
+// GetDailyStats 获取指定日期之后（含）的每日订单统计（按日期倒序），pid 为空时返回全部商户
+func (db *DB) GetDailyStats(pid, since string) ([]*DailyStats, error) {
+	query := `
+		SELECT date, pid, total, paid, expired, disputed, revenue, updated_at
+		FROM order_daily_stats
+		WHERE date >= ?`
+	args := []interface{}{since}
+	if pid != "" {
+		query += " AND pid = ?"
+		args = append(args, pid)
+	}
+	query += " ORDER BY date DESC, pid"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily stats: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]*DailyStats, 0)
+	for rows.Next() {
+		var stats DailyStats
+		if err := rows.Scan(&stats.Date, &stats.PID, &stats.Total, &stats.Paid, &stats.Expired, &stats.Disputed,
+			&stats.Revenue, &stats.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan daily stats: %w", err)
+		}
+		list = append(list, &stats)
+	}
+	return list, rows.Err()
+}
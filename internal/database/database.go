@@ -2,19 +2,21 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 )
 
 // DB 数据库实例
 type DB struct {
 	*sql.DB
+	orderCache *orderCache // 订单缓存（可选）
 }
 
 // Config 数据库配置
@@ -24,6 +26,9 @@ type Config struct {
 	MaxIdleConns    int
 	MaxOpenConns    int
 	ConnMaxLifetime int
+
+	SchemaUpgrade string // 数据库结构版本低于程序版本时的处理方式（SchemaUpgradeAuto / SchemaUpgradeManual）
+	AppVersion    string // 程序版本（记录在结构版本表中）
 }
 
 var globalDB *DB
@@ -58,17 +63,30 @@ func Init(cfg *Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	globalDB = &DB{db}
+	globalDB = &DB{DB: db}
 
 	// 优化SQLite设置
 	if err := globalDB.optimizeSQLite(); err != nil {
 		logger.Warn("Failed to optimize SQLite settings", zap.Error(err))
 	}
 
+	// 检查数据库结构版本（多实例滚动升级时，不兼容的版本拒绝启动）
+	upgrade, err := globalDB.checkSchemaVersion(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	// 初始化表结构
 	if err := globalDB.initTables(); err != nil {
 		return nil, fmt.Errorf("failed to initialize tables: %w", err)
 	}
+	if upgrade {
+		if err := globalDB.recordSchemaVersion(cfg.AppVersion); err != nil {
+			return nil, err
+		}
+		logger.Info("Database schema version recorded", zap.Int("schema_version", SchemaVersion))
+	}
 
 	logger.Info("Database initialized successfully",
 		zap.String("path", cfg.Path),
@@ -117,25 +135,149 @@ func (db *DB) initTables() error {
 		type VARCHAR(10) NOT NULL,
 		pid VARCHAR(20) NOT NULL,
 		name VARCHAR(255) NOT NULL,
-		price DECIMAL(10, 2) NOT NULL,
-		payment_amount DECIMAL(10, 2) DEFAULT 0,
+		price INTEGER NOT NULL,
+		payment_amount INTEGER DEFAULT 0,
 		status TINYINT(1) DEFAULT 0,
 		add_time DATETIME NOT NULL,
 		pay_time DATETIME,
 		notify_url VARCHAR(255),
 		return_url VARCHAR(255),
 		sitename VARCHAR(255),
-		qr_code_id VARCHAR(32) DEFAULT ''
+		qr_code_id VARCHAR(32) DEFAULT '',
+		alipay_trade_no VARCHAR(64) DEFAULT '',
+		keep_open TINYINT(1) NOT NULL DEFAULT 0,
+		disputed TINYINT(1) NOT NULL DEFAULT 0
 	);`
 
 	if _, err := db.Exec(createOrderTableSQL); err != nil {
 		return fmt.Errorf("failed to create orders table: %w", err)
 	}
 
+	// 创建系统设置表（键值存储，如session签名密钥）
+	createSettingsTableSQL := `
+	CREATE TABLE IF NOT EXISTS system_settings (
+		key VARCHAR(64) PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSettingsTableSQL); err != nil {
+		return fmt.Errorf("failed to create settings table: %w", err)
+	}
+
+	// 旧版本以元（浮点数）存储的订单金额转换为整数分
+	if err := db.migrateAmountsToFen(); err != nil {
+		return err
+	}
+
+	// 创建商户通知记录表
+	if err := db.initNotifyLogTable(); err != nil {
+		return err
+	}
+
+	// 创建审计日志表
+	if err := db.initAuditLogTable(); err != nil {
+		return err
+	}
+
+	// 创建订单生命周期事件表
+	if err := db.initOrderEventTable(); err != nil {
+		return err
+	}
+
+	// 创建收款码设置表
+	if err := db.initQRCodeTable(); err != nil {
+		return err
+	}
+
+	// 创建订单归档表
+	if err := db.initOrderArchiveTable(); err != nil {
+		return err
+	}
+
+	// 创建崩溃报告表
+	if err := db.initCrashReportTable(); err != nil {
+		return err
+	}
+
+	// 创建已匹配账单表
+	if err := db.initMatchedBillTable(); err != nil {
+		return err
+	}
+
+	// 创建当面付二维码表
+	if err := db.initPrecreateOrderTable(); err != nil {
+		return err
+	}
+
+	// 创建线下打印收款码表
+	if err := db.initPrintedCodeTable(); err != nil {
+		return err
+	}
+
+	// 创建事件发件箱表
+	if err := db.initOutboxTable(); err != nil {
+		return err
+	}
+
+	// 创建分笔支付表
+	if err := db.initPartialPaymentTable(); err != nil {
+		return err
+	}
+
+	// 创建管理后台会话表
+	if err := db.initAdminSessionTables(); err != nil {
+		return err
+	}
+
+	// 创建退款申请表
+	if err := db.initRefundRequestTable(); err != nil {
+		return err
+	}
+
+	// 创建管理员账号表
+	if err := db.initAdminUserTable(); err != nil {
+		return err
+	}
+
+	// 创建管理后台两步验证表（迁移旧版本共用的密钥）
+	if err := db.initAdminTwoFactorTable(); err != nil {
+		return err
+	}
+
+	// 创建管理后台API令牌表
+	if err := db.initAdminAPITokenTable(); err != nil {
+		return err
+	}
+
+	// 创建订单买家IP表
+	if err := db.initOrderClientTable(); err != nil {
+		return err
+	}
+
+	// 创建每日订单统计表
+	if err := db.initDailyStatsTable(); err != nil {
+		return err
+	}
+
+	// 创建任务执行记录表
+	if err := db.initJobRunTable(); err != nil {
+		return err
+	}
+
 	// 为已存在的表添加qr_code_id列（如果不存在）
 	addColumnSQL := `ALTER TABLE codepay_orders ADD COLUMN qr_code_id VARCHAR(32) DEFAULT '';`
 	_, _ = db.Exec(addColumnSQL) // 忽略错误，因为列可能已存在
 
+	// 为已存在的表添加alipay_trade_no列（账单匹配到的支付宝交易号，用于对账）
+	_, _ = db.Exec(`ALTER TABLE codepay_orders ADD COLUMN alipay_trade_no VARCHAR(64) DEFAULT '';`)
+
+	// 为已存在的表添加keep_open列（下单参数 auto_close=0 的订单不自动过期）
+	_, _ = db.Exec(`ALTER TABLE codepay_orders ADD COLUMN keep_open TINYINT(1) NOT NULL DEFAULT 0;`)
+
+	// 为已存在的表添加disputed列（拒付/投诉中的争议订单）
+	_, _ = db.Exec(`ALTER TABLE codepay_orders ADD COLUMN disputed TINYINT(1) NOT NULL DEFAULT 0;`)
+
 	// 创建索引
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_out_trade_no ON codepay_orders(out_trade_no);",
@@ -143,6 +285,7 @@ func (db *DB) initTables() error {
 		"CREATE INDEX IF NOT EXISTS idx_payment_amount ON codepay_orders(payment_amount);",
 		"CREATE INDEX IF NOT EXISTS idx_add_time ON codepay_orders(add_time);",
 		"CREATE INDEX IF NOT EXISTS idx_qr_code_id ON codepay_orders(qr_code_id);",
+		"CREATE INDEX IF NOT EXISTS idx_alipay_trade_no ON codepay_orders(alipay_trade_no);",
 	}
 
 	for _, indexSQL := range indexes {
@@ -151,38 +294,169 @@ func (db *DB) initTables() error {
 		}
 	}
 
+	// 同一商户的商户订单号唯一（防止并发重复提交创建多个订单）
+	if err := db.createOrderUniqueIndex(); err != nil {
+		return err
+	}
+
 	logger.Info("Database tables initialized successfully")
 	return nil
 }
 
-// CreateOrder 创建订单
-func (db *DB) CreateOrder(order *model.Order) error {
-	query := `
+// migrateAmountsToFen 将订单金额从元转换为分（只执行一次，完成后记录在系统设置中）
+func (db *DB) migrateAmountsToFen() error {
+	unit, err := db.GetSetting(SettingAmountUnit)
+	if err != nil {
+		return err
+	}
+	if unit == amountUnitFen {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin amount migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE codepay_orders
+		SET price = CAST(ROUND(price * 100) AS INTEGER),
+		    payment_amount = CAST(ROUND(payment_amount * 100) AS INTEGER)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate order amounts: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO system_settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		SettingAmountUnit, amountUnitFen, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save amount unit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit amount migration: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		logger.Info("Order amounts migrated to fen", zap.Int64("orders", n))
+	}
+	return nil
+}
+
+// createOrderUniqueIndex 创建 (out_trade_no, pid) 唯一索引
+// 旧版本可能已存在重复订单，此时无法创建唯一索引，仅记录警告（创建订单时仍在事务中检查重复）
+func (db *DB) createOrderUniqueIndex() error {
+	_, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_out_trade_no_pid ON codepay_orders(out_trade_no, pid);")
+	if err == nil {
+		return nil
+	}
+	if !isUniqueViolation(err) {
+		return fmt.Errorf("failed to create unique index: %w", err)
+	}
+
+	var duplicates int
+	_ = db.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM codepay_orders GROUP BY out_trade_no, pid HAVING COUNT(*) > 1
+		)`).Scan(&duplicates)
+	logger.Warn("Duplicate orders exist, unique index on (out_trade_no, pid) not created",
+		zap.Int("duplicate_out_trade_nos", duplicates))
+	return nil
+}
+
+// isUniqueViolation 是否为唯一约束冲突
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// CreateOrderOrGetExisting 创建订单，同一商户的商户订单号已存在时不创建并返回已有订单
+// 插入和重复检查在同一事务中完成（先写入以获取写锁，避免并发提交在检查后同时插入）
+// @return *model.Order 已有订单（新建成功时为nil）
+func (db *DB) CreateOrderOrGetExisting(order *model.Order) (*model.Order, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		INSERT INTO codepay_orders (
 			id, out_trade_no, type, pid, name, price, payment_amount,
-			status, add_time, notify_url, return_url, sitename, qr_code_id
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	_, err := db.Exec(query,
+			status, add_time, notify_url, return_url, sitename, qr_code_id, keep_open
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		order.ID, order.OutTradeNo, order.Type, order.PID, order.Name,
 		order.Price, order.PaymentAmount, order.Status, order.AddTime,
-		order.NotifyURL, order.ReturnURL, order.Sitename, order.QRCodeID,
+		order.NotifyURL, order.ReturnURL, order.Sitename, order.QRCodeID, order.KeepOpen,
 	)
+	if err != nil && !isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
 
+	// 最早的同号订单为有效订单（未创建唯一索引的旧数据库也能识别并发重复）
+	existing, err := scanOrderRow(tx.QueryRow(`
+		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
+		FROM codepay_orders
+		WHERE out_trade_no = ? AND pid = ?
+		ORDER BY add_time ASC, rowid ASC
+		LIMIT 1`,
+		order.OutTradeNo, order.PID,
+	))
 	if err != nil {
-		return fmt.Errorf("failed to create order: %w", err)
+		return nil, fmt.Errorf("failed to check existing order: %w", err)
+	}
+	if existing != nil && existing.ID != order.ID {
+		logger.Info("Duplicate order submission, returning existing order",
+			zap.String("out_trade_no", order.OutTradeNo),
+			zap.String("trade_no", existing.ID))
+		return existing, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit order: %w", err)
 	}
 
 	logger.Info("Order created", zap.String("order_id", order.ID), zap.String("out_trade_no", order.OutTradeNo))
-	return nil
+	return nil, nil
+}
+
+// scanOrderRow 扫描单个订单，不存在时返回nil
+func scanOrderRow(row *sql.Row) (*model.Order, error) {
+	var order model.Order
+	var payTime sql.NullTime
+
+	err := row.Scan(
+		&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
+		&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
+		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if payTime.Valid {
+		order.PayTime = &payTime.Time
+	}
+	return &order, nil
 }
 
 // GetOrderByOutTradeNo 根据商户订单号获取订单
 func (db *DB) GetOrderByOutTradeNo(outTradeNo, pid string) (*model.Order, error) {
+	if order := db.orderCache.getByOutTradeNo(outTradeNo, pid); order != nil {
+		return order, nil
+	}
+
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		WHERE out_trade_no = ? AND pid = ?
 	`
@@ -193,7 +467,7 @@ func (db *DB) GetOrderByOutTradeNo(outTradeNo, pid string) (*model.Order, error)
 	err := db.QueryRow(query, outTradeNo, pid).Scan(
 		&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 		&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 	)
 
 	if err == sql.ErrNoRows {
@@ -207,14 +481,19 @@ func (db *DB) GetOrderByOutTradeNo(outTradeNo, pid string) (*model.Order, error)
 		order.PayTime = &payTime.Time
 	}
 
+	db.orderCache.set(&order)
 	return &order, nil
 }
 
 // GetOrderByID 根据订单ID获取订单
 func (db *DB) GetOrderByID(id string) (*model.Order, error) {
+	if order := db.orderCache.getByID(id); order != nil {
+		return order, nil
+	}
+
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		WHERE id = ?
 	`
@@ -225,7 +504,7 @@ func (db *DB) GetOrderByID(id string) (*model.Order, error) {
 	err := db.QueryRow(query, id).Scan(
 		&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 		&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 	)
 
 	if err == sql.ErrNoRows {
@@ -239,14 +518,15 @@ func (db *DB) GetOrderByID(id string) (*model.Order, error) {
 		order.PayTime = &payTime.Time
 	}
 
+	db.orderCache.set(&order)
 	return &order, nil
 }
 
 // GetPendingOrderByAmount 根据金额获取待支付订单（经营码模式）
-func (db *DB) GetPendingOrderByAmount(amount float64) (*model.Order, error) {
+func (db *DB) GetPendingOrderByAmount(amount model.Amount) (*model.Order, error) {
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		WHERE payment_amount = ? AND status = ?
 		ORDER BY add_time ASC
@@ -259,7 +539,7 @@ func (db *DB) GetPendingOrderByAmount(amount float64) (*model.Order, error) {
 	err := db.QueryRow(query, amount, model.OrderStatusPending).Scan(
 		&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 		&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 	)
 
 	if err == sql.ErrNoRows {
@@ -276,11 +556,11 @@ func (db *DB) GetPendingOrderByAmount(amount float64) (*model.Order, error) {
 	return &order, nil
 }
 
-// CheckAmountExists 检查金额是否已存在（用于金额分配）
-func (db *DB) CheckAmountExists(amount float64, sinceTime time.Time) (bool, error) {
+// CheckAmountExists 检查金额是否已存在（用于金额分配，不自动过期的待支付订单始终占用金额）
+func (db *DB) CheckAmountExists(amount model.Amount, sinceTime time.Time) (bool, error) {
 	query := `
 		SELECT COUNT(*) FROM codepay_orders
-		WHERE payment_amount = ? AND status = ? AND add_time >= ?
+		WHERE payment_amount = ? AND status = ? AND (add_time >= ? OR keep_open = 1)
 	`
 
 	var count int
@@ -292,33 +572,57 @@ func (db *DB) CheckAmountExists(amount float64, sinceTime time.Time) (bool, erro
 	return count > 0, nil
 }
 
-// UpdateOrderStatus 更新订单状态
-func (db *DB) UpdateOrderStatus(id string, status int, payTime time.Time) error {
+// TransitionOrderStatus 条件更新订单状态（仅当当前状态为from时更新为to）
+// payTime 不为nil时同时写入支付时间，alipayTradeNo 不为空时同时写入支付宝交易号；返回false表示订单不存在或状态已被其他请求修改
+func (db *DB) TransitionOrderStatus(id string, from, to int, payTime *time.Time, alipayTradeNo string) (bool, error) {
 	query := `
 		UPDATE codepay_orders
-		SET status = ?, pay_time = ?
-		WHERE id = ?
+		SET status = ?, pay_time = COALESCE(?, pay_time), alipay_trade_no = COALESCE(NULLIF(?, ''), alipay_trade_no)
+		WHERE id = ? AND status = ?
 	`
 
-	result, err := db.Exec(query, status, payTime, id)
+	result, err := db.Exec(query, to, payTime, alipayTradeNo, id, from)
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+		return false, fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("order not found: %s", id)
+		return false, nil
 	}
 
-	logger.Info("Order status updated", zap.String("order_id", id), zap.Int("status", status))
-	return nil
+	// 状态变化后使缓存失效
+	db.invalidateOrderCache(id)
+
+	logger.Info("Order status updated",
+		zap.String("order_id", id),
+		zap.Int("from", from),
+		zap.Int("to", to))
+	return true, nil
+}
+
+// SetOrderDisputed 标记或解除订单争议
+// 返回false表示订单不存在或争议标记未变化（重复提交）
+func (db *DB) SetOrderDisputed(id string, disputed bool) (bool, error) {
+	result, err := db.Exec("UPDATE codepay_orders SET disputed = ? WHERE id = ? AND disputed != ?", disputed, id, disputed)
+	if err != nil {
+		return false, fmt.Errorf("failed to update order dispute flag: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	db.invalidateOrderCache(id)
+	return true, nil
 }
 
 // GetOrders 获取订单列表
 func (db *DB) GetOrders(pid string, limit int) ([]*model.Order, error) {
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		WHERE pid = ?
 		ORDER BY add_time DESC
@@ -339,7 +643,7 @@ func (db *DB) GetOrders(pid string, limit int) ([]*model.Order, error) {
 		err := rows.Scan(
 			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
@@ -369,7 +673,7 @@ GetOrdersByStatus 根据状态获取订单列表
 func (db *DB) GetOrdersByStatus(status int) ([]*model.Order, error) {
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		WHERE status = ?
 		ORDER BY add_time DESC
@@ -389,7 +693,7 @@ func (db *DB) GetOrdersByStatus(status int) ([]*model.Order, error) {
 		err := rows.Scan(
 			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
@@ -419,7 +723,7 @@ GetTodayOrdersByStatus 获取今日指定状态的订单
 func (db *DB) GetTodayOrdersByStatus(status int) ([]*model.Order, error) {
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		WHERE status = ? AND DATE(add_time) = DATE('now', 'localtime')
 		ORDER BY add_time DESC
@@ -439,7 +743,7 @@ func (db *DB) GetTodayOrdersByStatus(status int) ([]*model.Order, error) {
 		err := rows.Scan(
 			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
@@ -459,14 +763,19 @@ func (db *DB) GetTodayOrdersByStatus(status int) ([]*model.Order, error) {
 	return orders, nil
 }
 
-// DeleteExpiredOrders 删除过期订单
+// DeleteExpiredOrders 删除指定时间之前创建的已过期订单
 func (db *DB) DeleteExpiredOrders(expiredTime time.Time) (int64, error) {
+	// 启用缓存时先使即将删除的订单缓存失效
+	if db.orderCache != nil {
+		db.invalidateExpiredOrderCache(expiredTime)
+	}
+
 	query := `
 		DELETE FROM codepay_orders
 		WHERE status = ? AND add_time < ?
 	`
 
-	result, err := db.Exec(query, model.OrderStatusPending, expiredTime)
+	result, err := db.Exec(query, model.OrderStatusExpired, expiredTime)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete expired orders: %w", err)
 	}
@@ -474,6 +783,7 @@ func (db *DB) DeleteExpiredOrders(expiredTime time.Time) (int64, error) {
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected > 0 {
 		logger.Info("Expired orders deleted", zap.Int64("count", rowsAffected))
+		db.deleteOrphanOrderEvents()
 	}
 
 	return rowsAffected, nil
@@ -504,7 +814,7 @@ func (db *DB) CountOrders(status *int) (int, error) {
 func (db *DB) GetRecentOrders(limit int) ([]*model.Order, error) {
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
 		ORDER BY add_time DESC
 		LIMIT ?
@@ -524,7 +834,7 @@ func (db *DB) GetRecentOrders(limit int) ([]*model.Order, error) {
 		err := rows.Scan(
 			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
@@ -540,13 +850,13 @@ func (db *DB) GetRecentOrders(limit int) ([]*model.Order, error) {
 	return orders, nil
 }
 
-// GetPendingOrdersSince 获取指定时间之后的待支付订单
+// GetPendingOrdersSince 获取指定时间之后的待支付订单（含更早创建的不自动过期订单）
 func (db *DB) GetPendingOrdersSince(since time.Time) ([]*model.Order, error) {
 	query := `
 		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
-		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
 		FROM codepay_orders
-		WHERE status = ? AND add_time >= ?
+		WHERE status = ? AND (add_time >= ? OR keep_open = 1)
 		ORDER BY add_time DESC
 	`
 
@@ -564,7 +874,47 @@ func (db *DB) GetPendingOrdersSince(since time.Time) ([]*model.Order, error) {
 		err := rows.Scan(
 			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
 			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
-			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		if payTime.Valid {
+			order.PayTime = &payTime.Time
+		}
+
+		orders = append(orders, &order)
+	}
+
+	return orders, nil
+}
+
+// GetPendingOrdersBefore 获取指定时间之前创建的待支付订单（即已超时的订单，不含不自动过期的订单）
+func (db *DB) GetPendingOrdersBefore(before time.Time) ([]*model.Order, error) {
+	query := `
+		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
+		FROM codepay_orders
+		WHERE status = ? AND add_time < ? AND keep_open = 0
+		ORDER BY add_time
+	`
+
+	rows, err := db.Query(query, model.OrderStatusPending, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.Order
+	for rows.Next() {
+		var order model.Order
+		var payTime sql.NullTime
+
+		err := rows.Scan(
+			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
+			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
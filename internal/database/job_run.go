@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"alimpay-go/internal/model"
+)
+
+// maxJobRuns 任务执行记录保留条数（超出后删除最早的记录）
+const maxJobRuns = 5000
+
+// initJobRunTable 创建任务执行记录表
+func (db *DB) initJobRunTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(32) NOT NULL,
+		trigger VARCHAR(16) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		result TEXT,
+		error TEXT,
+		started_at DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL DEFAULT 0
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create job_runs table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_job_runs_name ON job_runs(name, id);"); err != nil {
+		return fmt.Errorf("failed to create job_runs index: %w", err)
+	}
+
+	return nil
+}
+
+// CreateJobRun 写入任务执行记录，并只保留最近 maxJobRuns 条
+func (db *DB) CreateJobRun(run *model.JobRun) error {
+	result, err := db.Exec(`
+		INSERT INTO job_runs (name, trigger, status, result, error, started_at, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, run.Name, run.Trigger, run.Status, run.Result, run.Error, run.StartedAt, run.DurationMs)
+	if err != nil {
+		return fmt.Errorf("failed to create job run: %w", err)
+	}
+
+	run.ID, _ = result.LastInsertId()
+
+	if _, err := db.Exec("DELETE FROM job_runs WHERE id <= ?", run.ID-maxJobRuns); err != nil {
+		return fmt.Errorf("failed to prune job runs: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateJobRun 更新任务执行记录的状态、结果和耗时（手动触发的任务执行完成后调用）
+func (db *DB) UpdateJobRun(run *model.JobRun) error {
+	if _, err := db.Exec(`
+		UPDATE job_runs SET status = ?, result = ?, error = ?, duration_ms = ? WHERE id = ?
+	`, run.Status, run.Result, run.Error, run.DurationMs, run.ID); err != nil {
+		return fmt.Errorf("failed to update job run: %w", err)
+	}
+	return nil
+}
+
+// GetJobRun 获取单条任务执行记录，不存在时返回nil
+func (db *DB) GetJobRun(id int64) (*model.JobRun, error) {
+	runs, err := db.queryJobRuns(`
+		SELECT id, name, trigger, status, result, error, started_at, duration_ms FROM job_runs WHERE id = ?
+	`, id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// GetJobRuns 获取任务执行记录（按时间倒序），name、status 为空时不过滤
+func (db *DB) GetJobRuns(name, status string, limit int) ([]*model.JobRun, error) {
+	query := "SELECT id, name, trigger, status, result, error, started_at, duration_ms FROM job_runs WHERE 1 = 1"
+	var args []interface{}
+	if name != "" {
+		query += " AND name = ?"
+		args = append(args, name)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	return db.queryJobRuns(query, args...)
+}
+
+// GetLatestJobRuns 获取每个任务最近一次的执行记录（按任务名称排序）
+func (db *DB) GetLatestJobRuns() ([]*model.JobRun, error) {
+	return db.queryJobRuns(`
+		SELECT id, name, trigger, status, result, error, started_at, duration_ms FROM job_runs
+		WHERE id IN (SELECT MAX(id) FROM job_runs GROUP BY name)
+		ORDER BY name
+	`)
+}
+
+// queryJobRuns 查询任务执行记录
+func (db *DB) queryJobRuns(query string, args ...interface{}) ([]*model.JobRun, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*model.JobRun, 0)
+	for rows.Next() {
+		var run model.JobRun
+		var result, runErr sql.NullString
+		if err := rows.Scan(&run.ID, &run.Name, &run.Trigger, &run.Status, &result, &runErr,
+			&run.StartedAt, &run.DurationMs); err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+		run.Result = result.String
+		run.Error = runErr.String
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+
+	"alimpay-go/internal/model"
+)
+
+// CountNotifyLogsByOutTradeNoPrefix 统计商户订单号以指定前缀开头的订单的通知结果
+func (db *DB) CountNotifyLogsByOutTradeNoPrefix(prefix string) (success, failed int, err error) {
+	rows, err := db.Query(`
+		SELECT status, COUNT(*)
+		FROM notify_logs
+		WHERE out_trade_no LIKE ? ESCAPE '\'
+		GROUP BY status
+	`, escapeLike(prefix)+"%")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count notify logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status, count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return 0, 0, fmt.Errorf("failed to scan notify count: %w", err)
+		}
+		if status == model.NotifyStatusSuccess {
+			success = count
+		} else {
+			failed += count
+		}
+	}
+
+	return success, failed, rows.Err()
+}
+
+// DeleteOrdersByOutTradeNoPrefix 删除商户订单号以指定前缀开头的订单及其通知记录、生命周期事件、已匹配账单
+func (db *DB) DeleteOrdersByOutTradeNoPrefix(prefix string) (int64, error) {
+	pattern := escapeLike(prefix) + "%"
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 在同一事务中读取要删除的订单，提交后删除其缓存
+	rows, err := tx.Query(
+		`SELECT id, out_trade_no, pid FROM codepay_orders WHERE out_trade_no LIKE ? ESCAPE '\'`, pattern)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select orders: %w", err)
+	}
+	keys, err := scanOrderCacheKeys(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM notify_logs WHERE out_trade_no LIKE ? ESCAPE '\'`, pattern); err != nil {
+		return 0, fmt.Errorf("failed to delete notify logs: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM order_events
+		WHERE order_id IN (SELECT id FROM codepay_orders WHERE out_trade_no LIKE ? ESCAPE '\')
+	`, pattern); err != nil {
+		return 0, fmt.Errorf("failed to delete order events: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM matched_bills
+		WHERE order_id IN (SELECT id FROM codepay_orders WHERE out_trade_no LIKE ? ESCAPE '\')
+	`, pattern); err != nil {
+		return 0, fmt.Errorf("failed to delete matched bills: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM codepay_orders WHERE out_trade_no LIKE ? ESCAPE '\'`, pattern)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orders: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	db.invalidateOrderKeys(keys)
+
+	count, _ := result.RowsAffected()
+	return count, nil
+}
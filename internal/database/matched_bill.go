@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initMatchedBillTable 创建已匹配账单表
+// 每个监听周期都会重新查询最近的账单，已被订单使用的账单记录在此表中，避免同一笔收入匹配多个同金额订单
+func (db *DB) initMatchedBillTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS matched_bills (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		alipay_order_no VARCHAR(64) NOT NULL,
+		account_log_id VARCHAR(64) NOT NULL DEFAULT '',
+		order_id VARCHAR(32) NOT NULL,
+		amount INTEGER NOT NULL,
+		matched_at DATETIME NOT NULL,
+		UNIQUE(alipay_order_no, account_log_id)
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create matched_bills table: %w", err)
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_matched_bill_order_id ON matched_bills(order_id);",
+		"CREATE INDEX IF NOT EXISTS idx_matched_bill_matched_at ON matched_bills(matched_at);",
+	}
+	for _, indexSQL := range indexes {
+		if _, err := db.Exec(indexSQL); err != nil {
+			return fmt.Errorf("failed to create matched_bills index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ClaimBill 将账单标记为已被订单使用
+// 账单已被其他订单使用时返回false；已被同一订单使用（上次更新订单状态失败）时返回true
+func (db *DB) ClaimBill(alipayOrderNo, accountLogID, orderID string, amount model.Amount) (bool, error) {
+	result, err := db.Exec(`
+		INSERT OR IGNORE INTO matched_bills (alipay_order_no, account_log_id, order_id, amount, matched_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, alipayOrderNo, accountLogID, orderID, amount, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim bill: %w", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		return true, nil
+	}
+
+	var owner string
+	err = db.QueryRow(
+		"SELECT order_id FROM matched_bills WHERE alipay_order_no = ? AND account_log_id = ?",
+		alipayOrderNo, accountLogID,
+	).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("claimed bill disappeared: %s", alipayOrderNo)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get bill owner: %w", err)
+	}
+
+	return owner == orderID, nil
+}
+
+// ReleaseBill 释放订单占用的账单（订单状态更新失败时调用）
+func (db *DB) ReleaseBill(alipayOrderNo, accountLogID, orderID string) error {
+	_, err := db.Exec(
+		"DELETE FROM matched_bills WHERE alipay_order_no = ? AND account_log_id = ? AND order_id = ?",
+		alipayOrderNo, accountLogID, orderID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release bill: %w", err)
+	}
+	return nil
+}
+
+// DeleteMatchedBillsBefore 删除指定时间之前匹配的账单记录（早于账单查询窗口的账单不会再被查到）
+func (db *DB) DeleteMatchedBillsBefore(before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM matched_bills WHERE matched_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete matched bills: %w", err)
+	}
+
+	count, _ := result.RowsAffected()
+	return count, nil
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initNotifyLogTable 创建商户通知记录表
+func (db *DB) initNotifyLogTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS notify_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id VARCHAR(32) NOT NULL,
+		out_trade_no VARCHAR(64) NOT NULL,
+		pid VARCHAR(20) NOT NULL,
+		notify_url VARCHAR(255) NOT NULL,
+		payload TEXT NOT NULL,
+		status TINYINT(1) NOT NULL,
+		http_status INTEGER DEFAULT 0,
+		response TEXT,
+		error TEXT,
+		duration_ms INTEGER DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create notify_logs table: %w", err)
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_notify_order_id ON notify_logs(order_id);",
+		"CREATE INDEX IF NOT EXISTS idx_notify_pid_created ON notify_logs(pid, created_at);",
+	}
+	for _, indexSQL := range indexes {
+		if _, err := db.Exec(indexSQL); err != nil {
+			return fmt.Errorf("failed to create notify_logs index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CreateNotifyLog 记录一次商户通知
+func (db *DB) CreateNotifyLog(log *model.NotifyLog) error {
+	query := `
+		INSERT INTO notify_logs (order_id, out_trade_no, pid, notify_url, payload,
+			status, http_status, response, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.Exec(query,
+		log.OrderID, log.OutTradeNo, log.PID, log.NotifyURL, log.Payload,
+		log.Status, log.HTTPStatus, log.Response, log.Error, log.DurationMs, log.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create notify log: %w", err)
+	}
+
+	log.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetNotifySummary 按商户汇总指定时间之后的通知情况
+func (db *DB) GetNotifySummary(since time.Time) ([]*model.NotifySummary, error) {
+	summaries := make(map[string]*model.NotifySummary)
+	get := func(pid string) *model.NotifySummary {
+		if s, ok := summaries[pid]; ok {
+			return s
+		}
+		s := &model.NotifySummary{PID: pid}
+		summaries[pid] = s
+		return s
+	}
+
+	// 成功/失败次数
+	rows, err := db.Query(`
+		SELECT pid, status, COUNT(*)
+		FROM notify_logs
+		WHERE created_at >= ?
+		GROUP BY pid, status
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize notify logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid string
+		var status, count int
+		if err := rows.Scan(&pid, &status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan notify summary: %w", err)
+		}
+		if status == model.NotifyStatusSuccess {
+			get(pid).Success = count
+		} else {
+			get(pid).Failed = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 已支付但尚未通知成功的订单
+	pendingRows, err := db.Query(`
+		SELECT o.pid, COUNT(*)
+		FROM codepay_orders o
+		WHERE o.status = ? AND o.pay_time >= ? AND o.notify_url != ''
+		  AND NOT EXISTS (
+			SELECT 1 FROM notify_logs n WHERE n.order_id = o.id AND n.status = ?
+		  )
+		GROUP BY o.pid
+	`, model.OrderStatusPaid, since, model.NotifyStatusSuccess)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pending notifications: %w", err)
+	}
+	defer pendingRows.Close()
+
+	for pendingRows.Next() {
+		var pid string
+		var count int
+		if err := pendingRows.Scan(&pid, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan pending notifications: %w", err)
+		}
+		get(pid).Pending = count
+	}
+	if err := pendingRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 最近一次失败
+	for pid, summary := range summaries {
+		if summary.Failed == 0 {
+			continue
+		}
+		var failedAt time.Time
+		var lastError sql.NullString
+		err := db.QueryRow(`
+			SELECT created_at, error FROM notify_logs
+			WHERE pid = ? AND status = ? AND created_at >= ?
+			ORDER BY created_at DESC LIMIT 1
+		`, pid, model.NotifyStatusFailed, since).Scan(&failedAt, &lastError)
+		if err == nil {
+			summary.LastFailureAt = &failedAt
+			summary.LastError = lastError.String
+		}
+	}
+
+	result := make([]*model.NotifySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, summary)
+	}
+	// 失败多的商户排在前面
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Failed != result[j].Failed {
+			return result[i].Failed > result[j].Failed
+		}
+		return result[i].PID < result[j].PID
+	})
+
+	return result, nil
+}
+
+// GetNotifyLogs 查询通知记录（pid为空查询全部，status为nil不过滤状态）
+func (db *DB) GetNotifyLogs(pid string, status *int, since time.Time, limit int) ([]*model.NotifyLog, error) {
+	query := `
+		SELECT id, order_id, out_trade_no, pid, notify_url, payload, status,
+		       http_status, response, error, duration_ms, created_at
+		FROM notify_logs
+		WHERE created_at >= ?
+	`
+	args := []interface{}{since}
+
+	if pid != "" {
+		query += " AND pid = ?"
+		args = append(args, pid)
+	}
+	if status != nil {
+		query += " AND status = ?"
+		args = append(args, *status)
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notify logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*model.NotifyLog
+	for rows.Next() {
+		log, err := scanNotifyLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+// GetNotifyLogByID 根据ID获取通知记录
+func (db *DB) GetNotifyLogByID(id int64) (*model.NotifyLog, error) {
+	row := db.QueryRow(`
+		SELECT id, order_id, out_trade_no, pid, notify_url, payload, status,
+		       http_status, response, error, duration_ms, created_at
+		FROM notify_logs
+		WHERE id = ?
+	`, id)
+
+	log, err := scanNotifyLog(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return log, err
+}
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNotifyLog 扫描一条通知记录
+func scanNotifyLog(row rowScanner) (*model.NotifyLog, error) {
+	var log model.NotifyLog
+	var response, errMsg sql.NullString
+
+	err := row.Scan(
+		&log.ID, &log.OrderID, &log.OutTradeNo, &log.PID, &log.NotifyURL, &log.Payload,
+		&log.Status, &log.HTTPStatus, &response, &errMsg, &log.DurationMs, &log.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan notify log: %w", err)
+	}
+
+	log.Response = response.String
+	log.Error = errMsg.String
+	return &log, nil
+}
+
+// GetRecentNotifyURLs 获取指定时间之后订单使用过的通知地址（按商户去重）
+func (db *DB) GetRecentNotifyURLs(since time.Time) (map[string][]string, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT pid, notify_url
+		FROM codepay_orders
+		WHERE add_time >= ? AND notify_url != ''
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notify urls: %w", err)
+	}
+	defer rows.Close()
+
+	urls := make(map[string][]string)
+	for rows.Next() {
+		var pid, notifyURL string
+		if err := rows.Scan(&pid, &notifyURL); err != nil {
+			return nil, fmt.Errorf("failed to scan notify url: %w", err)
+		}
+		urls[pid] = append(urls[pid], notifyURL)
+	}
+
+	return urls, rows.Err()
+}
+
+// GetUnnotifiedPaidOrders 获取指定时间之后支付、商户尚未确认通知的订单（不含争议订单，按支付时间排序）
+func (db *DB) GetUnnotifiedPaidOrders(since time.Time, limit int) ([]*model.Order, error) {
+	rows, err := db.Query(`
+		SELECT `+orderColumns+`
+		FROM codepay_orders o
+		WHERE o.status = ? AND o.pay_time >= ? AND o.notify_url != '' AND o.disputed = 0
+		  AND NOT EXISTS (
+			SELECT 1 FROM notify_logs n WHERE n.order_id = o.id AND n.status = ?
+		  )
+		ORDER BY o.pay_time
+		LIMIT ?
+	`, model.OrderStatusPaid, since, model.NotifyStatusSuccess, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unnotified orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.Order
+	for rows.Next() {
+		var order model.Order
+		var payTime sql.NullTime
+		err := rows.Scan(
+			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
+			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		if payTime.Valid {
+			order.PayTime = &payTime.Time
+		}
+		orders = append(orders, &order)
+	}
+	return orders, rows.Err()
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 订单表名（归档表与订单表结构相同，另加归档时间）
+const (
+	orderTable        = "codepay_orders"
+	orderArchiveTable = "codepay_orders_archive"
+)
+
+// orderColumns 订单表字段（归档时按相同顺序复制）
+const orderColumns = `id, out_trade_no, type, pid, name, price, payment_amount,
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed`
+
+// initOrderArchiveTable 创建订单归档表
+func (db *DB) initOrderArchiveTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS codepay_orders_archive (
+		id VARCHAR(32) PRIMARY KEY,
+		out_trade_no VARCHAR(64) NOT NULL,
+		type VARCHAR(10) NOT NULL,
+		pid VARCHAR(20) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		price INTEGER NOT NULL,
+		payment_amount INTEGER DEFAULT 0,
+		status TINYINT(1) DEFAULT 0,
+		add_time DATETIME NOT NULL,
+		pay_time DATETIME,
+		notify_url VARCHAR(255),
+		return_url VARCHAR(255),
+		sitename VARCHAR(255),
+		qr_code_id VARCHAR(32) DEFAULT '',
+		alipay_trade_no VARCHAR(64) DEFAULT '',
+		keep_open TINYINT(1) NOT NULL DEFAULT 0,
+		disputed TINYINT(1) NOT NULL DEFAULT 0,
+		archived_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create orders archive table: %w", err)
+	}
+
+	// 为已存在的归档表添加alipay_trade_no列（忽略错误，因为列可能已存在）
+	_, _ = db.Exec(`ALTER TABLE codepay_orders_archive ADD COLUMN alipay_trade_no VARCHAR(64) DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE codepay_orders_archive ADD COLUMN keep_open TINYINT(1) NOT NULL DEFAULT 0;`)
+	_, _ = db.Exec(`ALTER TABLE codepay_orders_archive ADD COLUMN disputed TINYINT(1) NOT NULL DEFAULT 0;`)
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_archive_out_trade_no ON codepay_orders_archive(out_trade_no, pid);",
+		"CREATE INDEX IF NOT EXISTS idx_archive_add_time ON codepay_orders_archive(add_time);",
+	}
+	for _, indexSQL := range indexes {
+		if _, err := db.Exec(indexSQL); err != nil {
+			return fmt.Errorf("failed to create orders archive index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// archivableOrdersSQL 可归档的订单：已结束（已支付、关闭、退款、过期）且非保持开放、无争议、
+// 无待审核退款申请；未支付的订单不能有已收到的分笔支付。
+// 归档后下单查重和账单匹配不再查询该订单，未结束的订单归档后迟到的付款会丢失
+const archivableOrdersSQL = `SELECT id, out_trade_no, pid FROM codepay_orders o
+	WHERE add_time < ? AND status IN (?, ?, ?, ?) AND keep_open = 0 AND disputed = 0
+	  AND NOT EXISTS (SELECT 1 FROM refund_requests r WHERE r.order_id = o.id AND r.status = ?)
+	  AND (status IN (?, ?) OR NOT EXISTS (SELECT 1 FROM partial_payments p WHERE p.order_id = o.id))
+	ORDER BY add_time LIMIT ?`
+
+// ArchiveOrders 将指定时间之前创建的已结束订单移入归档表（每次最多limit条，在同一事务中复制并删除）
+// 返回本次归档的订单数，为0表示没有需要归档的订单
+func (db *DB) ArchiveOrders(before time.Time, limit int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(archivableOrdersSQL,
+		before,
+		model.OrderStatusPaid, model.OrderStatusClosed, model.OrderStatusRefund, model.OrderStatusExpired,
+		model.RefundStatusPending,
+		model.OrderStatusPaid, model.OrderStatusRefund,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select orders to archive: %w", err)
+	}
+
+	keys, err := scanOrderCacheKeys(rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key.id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(keys)), ",")
+
+	insertSQL := `INSERT OR REPLACE INTO codepay_orders_archive (` + orderColumns + `, archived_at)
+		SELECT ` + orderColumns + `, ? FROM codepay_orders WHERE id IN (` + placeholders + `)`
+	if _, err := tx.Exec(insertSQL, append([]interface{}{time.Now()}, args...)...); err != nil {
+		return 0, fmt.Errorf("failed to copy orders to archive: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM codepay_orders WHERE id IN ("+placeholders+")", args...); err != nil {
+		return 0, fmt.Errorf("failed to delete archived orders: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archive: %w", err)
+	}
+
+	// 归档的订单已不在订单表中，删除缓存避免查询和匹配仍读到旧订单
+	db.invalidateOrderKeys(keys)
+
+	logger.Info("Orders archived",
+		zap.Int("count", len(keys)),
+		zap.Time("before", before))
+
+	return len(keys), nil
+}
+
+// SearchArchivedOrders 按条件分页搜索已归档订单，返回当前页订单和符合条件的总数
+func (db *DB) SearchArchivedOrders(filter OrderFilter) ([]*model.Order, int, error) {
+	return db.searchOrders(orderArchiveTable, filter)
+}
+
+// GetArchivedOrderByID 根据ID获取已归档订单（不存在时返回nil）
+func (db *DB) GetArchivedOrderByID(id string) (*model.Order, error) {
+	var order model.Order
+	var payTime sql.NullTime
+
+	err := db.QueryRow("SELECT "+orderColumns+" FROM codepay_orders_archive WHERE id = ?", id).Scan(
+		&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
+		&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
+		&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived order: %w", err)
+	}
+
+	if payTime.Valid {
+		order.PayTime = &payTime.Time
+	}
+	return &order, nil
+}
+
+// CountArchivedOrders 统计已归档订单数量
+func (db *DB) CountArchivedOrders() (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM codepay_orders_archive").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count archived orders: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/cache"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 订单缓存键前缀
+const (
+	orderCacheKeyByID         = "alimpay:order:id:"
+	orderCacheKeyByOutTradeNo = "alimpay:order:out:"
+)
+
+// orderCache 订单缓存（Redis）
+// 只缓存单个订单的查询结果，状态变更时主动失效
+type orderCache struct {
+	redis *cache.RedisCache
+	ttl   time.Duration
+}
+
+// SetOrderCache 启用订单缓存
+// redisCache 为 nil 或不可用时保持无缓存模式
+func (db *DB) SetOrderCache(redisCache *cache.RedisCache, ttl time.Duration) {
+	if !redisCache.IsAvailable() {
+		db.orderCache = nil
+		return
+	}
+
+	db.orderCache = &orderCache{
+		redis: redisCache,
+		ttl:   ttl,
+	}
+
+	logger.Info("Order cache enabled", zap.Duration("ttl", ttl))
+}
+
+// idKey 按订单号缓存的键
+func (c *orderCache) idKey(id string) string {
+	return orderCacheKeyByID + id
+}
+
+// outTradeNoKey 按商户订单号缓存的键
+func (c *orderCache) outTradeNoKey(outTradeNo, pid string) string {
+	return fmt.Sprintf("%s%s:%s", orderCacheKeyByOutTradeNo, pid, outTradeNo)
+}
+
+// getByID 从缓存获取订单，未命中返回nil
+func (c *orderCache) getByID(id string) *model.Order {
+	if c == nil {
+		return nil
+	}
+	return c.get(c.idKey(id))
+}
+
+// getByOutTradeNo 从缓存获取订单，未命中返回nil
+func (c *orderCache) getByOutTradeNo(outTradeNo, pid string) *model.Order {
+	if c == nil {
+		return nil
+	}
+	return c.get(c.outTradeNoKey(outTradeNo, pid))
+}
+
+// get 读取并反序列化缓存
+func (c *orderCache) get(key string) *model.Order {
+	data, err := c.redis.Get(key)
+	if err != nil || data == "" {
+		return nil
+	}
+
+	var order model.Order
+	if err := json.Unmarshal([]byte(data), &order); err != nil {
+		logger.Warn("Failed to decode cached order", zap.String("key", key), zap.Error(err))
+		return nil
+	}
+
+	return &order
+}
+
+// set 写入缓存（同时写入两种键）
+func (c *orderCache) set(order *model.Order) {
+	if c == nil || order == nil {
+		return
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return
+	}
+
+	if err := c.redis.Set(c.idKey(order.ID), data, c.ttl); err != nil {
+		logger.Debug("Failed to cache order", zap.String("order_id", order.ID), zap.Error(err))
+		return
+	}
+	if err := c.redis.Set(c.outTradeNoKey(order.OutTradeNo, order.PID), data, c.ttl); err != nil {
+		logger.Debug("Failed to cache order", zap.String("order_id", order.ID), zap.Error(err))
+	}
+}
+
+// delete 删除订单的所有缓存键
+func (c *orderCache) delete(id, outTradeNo, pid string) {
+	if c == nil {
+		return
+	}
+
+	if err := c.redis.Del(c.idKey(id), c.outTradeNoKey(outTradeNo, pid)); err != nil {
+		logger.Warn("Failed to invalidate order cache", zap.String("order_id", id), zap.Error(err))
+	}
+}
+
+// orderCacheKey 删除订单时用于使缓存失效的字段
+type orderCacheKey struct{ id, outTradeNo, pid string }
+
+// scanOrderCacheKeys 读取 id, out_trade_no, pid 三列的查询结果（读取后关闭rows）
+func scanOrderCacheKeys(rows *sql.Rows) ([]orderCacheKey, error) {
+	defer rows.Close()
+
+	var keys []orderCacheKey
+	for rows.Next() {
+		var key orderCacheKey
+		if err := rows.Scan(&key.id, &key.outTradeNo, &key.pid); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return keys, nil
+}
+
+// invalidateOrderKeys 使已删除订单的缓存失效（在删除事务提交后调用，避免并发读取把旧数据写回缓存）
+func (db *DB) invalidateOrderKeys(keys []orderCacheKey) {
+	for _, key := range keys {
+		db.orderCache.delete(key.id, key.outTradeNo, key.pid)
+	}
+}
+
+// invalidateOrderCache 使指定订单的缓存失效
+func (db *DB) invalidateOrderCache(id string) {
+	if db.orderCache == nil {
+		return
+	}
+
+	var outTradeNo, pid string
+	err := db.QueryRow("SELECT out_trade_no, pid FROM codepay_orders WHERE id = ?", id).Scan(&outTradeNo, &pid)
+	if err != nil {
+		// 查不到商户订单号时至少删除ID键
+		db.orderCache.delete(id, "", "")
+		return
+	}
+
+	db.orderCache.delete(id, outTradeNo, pid)
+}
+
+// invalidateExpiredOrderCache 使即将被清理的过期订单缓存失效
+func (db *DB) invalidateExpiredOrderCache(expiredTime time.Time) {
+	rows, err := db.Query(
+		"SELECT id, out_trade_no, pid FROM codepay_orders WHERE status = ? AND add_time < ?",
+		model.OrderStatusExpired, expiredTime,
+	)
+	if err != nil {
+		logger.Warn("Failed to load expired orders for cache invalidation", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, outTradeNo, pid string
+		if err := rows.Scan(&id, &outTradeNo, &pid); err != nil {
+			continue
+		}
+		db.orderCache.delete(id, outTradeNo, pid)
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initOrderClientTable 创建订单买家IP表
+func (db *DB) initOrderClientTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS order_clients (
+		order_id VARCHAR(32) PRIMARY KEY,
+		ip VARCHAR(45) NOT NULL,
+		country VARCHAR(8) NOT NULL DEFAULT '',
+		province VARCHAR(64) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create order_clients table: %w", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_order_clients_ip ON order_clients(ip, created_at);"); err != nil {
+		return fmt.Errorf("failed to create order_clients index: %w", err)
+	}
+
+	return nil
+}
+
+// SaveOrderClient 保存订单的买家IP及归属地
+func (db *DB) SaveOrderClient(client *model.OrderClient) error {
+	if client.CreatedAt.IsZero() {
+		client.CreatedAt = time.Now()
+	}
+
+	_, err := db.Exec(
+		"INSERT OR REPLACE INTO order_clients (order_id, ip, country, province, created_at) VALUES (?, ?, ?, ?, ?)",
+		client.OrderID, client.IP, client.Country, client.Province, client.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save order client: %w", err)
+	}
+	return nil
+}
+
+// GetOrderClient 获取订单的买家IP及归属地，未记录时返回nil
+func (db *DB) GetOrderClient(orderID string) (*model.OrderClient, error) {
+	var client model.OrderClient
+	err := db.QueryRow(
+		"SELECT order_id, ip, country, province, created_at FROM order_clients WHERE order_id = ?", orderID,
+	).Scan(&client.OrderID, &client.IP, &client.Country, &client.Province, &client.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order client: %w", err)
+	}
+	return &client, nil
+}
+
+// CountOrderClientsSince 统计买家IP在指定时间之后创建的订单数
+func (db *DB) CountOrderClientsSince(ip string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM order_clients WHERE ip = ? AND created_at >= ?", ip, since,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count order clients: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// initOrderEventTable 创建订单生命周期事件表
+func (db *DB) initOrderEventTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS order_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id VARCHAR(32) NOT NULL,
+		event VARCHAR(32) NOT NULL,
+		detail TEXT,
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create order_events table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events(order_id);"); err != nil {
+		return fmt.Errorf("failed to create order_events index: %w", err)
+	}
+
+	return nil
+}
+
+// RecordOrderEvent 记录订单生命周期事件（失败仅记录日志，不影响主流程）
+func (db *DB) RecordOrderEvent(orderID, event, detail string) {
+	_, err := db.Exec(`
+		INSERT INTO order_events (order_id, event, detail, created_at)
+		VALUES (?, ?, ?, ?)
+	`, orderID, event, detail, time.Now())
+	if err != nil {
+		logger.Warn("Failed to record order event",
+			zap.String("order_id", orderID),
+			zap.String("event", event),
+			zap.Error(err))
+	}
+}
+
+// GetOrderEvents 获取订单的全部生命周期事件（按时间正序）
+func (db *DB) GetOrderEvents(orderID string) ([]*model.OrderEvent, error) {
+	rows, err := db.Query(`
+		SELECT id, order_id, event, COALESCE(detail, ''), created_at
+		FROM order_events
+		WHERE order_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order events: %w", err)
+	}
+	defer rows.Close()
+
+	var orderEvents []*model.OrderEvent
+	for rows.Next() {
+		var e model.OrderEvent
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.Event, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		orderEvents = append(orderEvents, &e)
+	}
+
+	return orderEvents, rows.Err()
+}
+
+// GetNotifyLogsByOrder 获取订单的全部通知记录（按时间正序）
+func (db *DB) GetNotifyLogsByOrder(orderID string) ([]*model.NotifyLog, error) {
+	rows, err := db.Query(`
+		SELECT id, order_id, out_trade_no, pid, notify_url, payload, status,
+		       http_status, response, error, duration_ms, created_at
+		FROM notify_logs
+		WHERE order_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notify logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*model.NotifyLog
+	for rows.Next() {
+		log, err := scanNotifyLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+// GetAuditLogsForOrder 获取请求参数中引用了该订单号的审计日志（按时间正序）
+func (db *DB) GetAuditLogsForOrder(tradeNo, outTradeNo string) ([]*model.AuditLog, error) {
+	// 审计详情为JSON对象，参数值以 "value" 形式出现
+	query := `
+		SELECT id, action, actor, ip, user_agent, method, path, detail, status, created_at
+		FROM audit_logs
+		WHERE detail LIKE ? ESCAPE '\'
+	`
+	args := []interface{}{`%"` + escapeLike(tradeNo) + `"%`}
+	if outTradeNo != "" {
+		query += ` OR detail LIKE ? ESCAPE '\'`
+		args = append(args, `%"`+escapeLike(outTradeNo)+`"%`)
+	}
+	query += " ORDER BY created_at ASC, id ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*model.AuditLog
+	for rows.Next() {
+		var log model.AuditLog
+		err := rows.Scan(&log.ID, &log.Action, &log.Actor, &log.IP, &log.UserAgent,
+			&log.Method, &log.Path, &log.Detail, &log.Status, &log.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		logs = append(logs, &log)
+	}
+
+	return logs, rows.Err()
+}
+
+// deleteOrphanOrderEvents 删除订单已不存在的生命周期事件（已归档订单的事件保留）
+func (db *DB) deleteOrphanOrderEvents() {
+	_, err := db.Exec(`
+		DELETE FROM order_events
+		WHERE order_id NOT IN (SELECT id FROM codepay_orders)
+		  AND order_id NOT IN (SELECT id FROM codepay_orders_archive)
+	`)
+	if err != nil {
+		logger.Warn("Failed to delete orphan order events", zap.Error(err))
+	}
+}
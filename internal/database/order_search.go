@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// OrderFilter 订单搜索条件（零值字段不参与过滤）
+type OrderFilter struct {
+	PID       string
+	Status    *int
+	StartTime *time.Time    // 创建时间 >= StartTime
+	EndTime   *time.Time    // 创建时间 < EndTime
+	MinAmount *model.Amount // 订单金额 >= MinAmount
+	MaxAmount *model.Amount // 订单金额 <= MaxAmount
+	Keyword   string        // 模糊匹配订单号/商户订单号
+	Page      int           // 从1开始
+	PageSize  int
+}
+
+// where 构建过滤条件
+func (f *OrderFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if f.PID != "" {
+		conds = append(conds, "pid = ?")
+		args = append(args, f.PID)
+	}
+	if f.Status != nil {
+		conds = append(conds, "status = ?")
+		args = append(args, *f.Status)
+	}
+	if f.StartTime != nil {
+		conds = append(conds, "add_time >= ?")
+		args = append(args, *f.StartTime)
+	}
+	if f.EndTime != nil {
+		conds = append(conds, "add_time < ?")
+		args = append(args, *f.EndTime)
+	}
+	if f.MinAmount != nil {
+		conds = append(conds, "price >= ?")
+		args = append(args, *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		conds = append(conds, "price <= ?")
+		args = append(args, *f.MaxAmount)
+	}
+	if f.Keyword != "" {
+		pattern := "%" + escapeLike(f.Keyword) + "%"
+		conds = append(conds, `(id LIKE ? ESCAPE '\' OR out_trade_no LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// SearchOrders 按条件分页搜索订单，返回当前页订单和符合条件的总数
+func (db *DB) SearchOrders(filter OrderFilter) ([]*model.Order, int, error) {
+	return db.searchOrders(orderTable, filter)
+}
+
+// searchOrders 在订单表或归档表中分页搜索订单
+func (db *DB) searchOrders(table string, filter OrderFilter) ([]*model.Order, int, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+
+	where, args := filter.where()
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+table+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	query := `
+		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
+		FROM ` + table + where + `
+		ORDER BY add_time DESC
+		LIMIT ? OFFSET ?
+	`
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*model.Order, 0, filter.PageSize)
+	for rows.Next() {
+		var order model.Order
+		var payTime sql.NullTime
+
+		err := rows.Scan(
+			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
+			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		if payTime.Valid {
+			order.PayTime = &payTime.Time
+		}
+
+		orders = append(orders, &order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return orders, total, nil
+}
+
+// ExportOrders 按条件逐行读取订单（忽略分页参数），用于导出大量订单时不整体加载到内存
+func (db *DB) ExportOrders(filter OrderFilter, fn func(*model.Order) error) error {
+	where, args := filter.where()
+
+	query := `
+		SELECT id, out_trade_no, type, pid, name, price, payment_amount,
+		       status, add_time, pay_time, notify_url, return_url, sitename, qr_code_id, alipay_trade_no, keep_open, disputed
+		FROM codepay_orders` + where + `
+		ORDER BY add_time DESC
+	`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to export orders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order model.Order
+		var payTime sql.NullTime
+
+		err := rows.Scan(
+			&order.ID, &order.OutTradeNo, &order.Type, &order.PID, &order.Name,
+			&order.Price, &order.PaymentAmount, &order.Status, &order.AddTime,
+			&payTime, &order.NotifyURL, &order.ReturnURL, &order.Sitename, &order.QRCodeID, &order.AlipayTradeNo, &order.KeepOpen, &order.Disputed,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		if payTime.Valid {
+			order.PayTime = &payTime.Time
+		}
+
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// escapeLike 转义LIKE通配符
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
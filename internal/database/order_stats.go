@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// OrderStats 订单统计
+type OrderStats struct {
+	Total          int          `json:"total"`
+	Pending        int          `json:"pending"`
+	Paid           int          `json:"paid"`
+	Closed         int          `json:"closed"`
+	Refund         int          `json:"refund"`
+	Expired        int          `json:"expired"`
+	Disputed       int          `json:"disputed"`        // 争议订单数（不区分状态）
+	Revenue        model.Amount `json:"revenue"`         // 已支付订单实收金额合计（不含争议订单）
+	DisputedAmount model.Amount `json:"disputed_amount"` // 争议中的已支付订单实收金额合计
+	AverageAmount  model.Amount `json:"average_amount"`  // 已支付订单平均金额（不含争议订单）
+	ConversionRate float64      `json:"conversion_rate"` // 支付转化率（已支付/全部）
+}
+
+// GetOrderStats 统计指定时间之后创建的订单（聚合查询，不加载订单明细）
+func (db *DB) GetOrderStats(pid string, since time.Time) (*OrderStats, error) {
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(disputed), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND disputed = 0 THEN payment_amount END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND disputed = 1 THEN payment_amount END), 0),
+			COALESCE(AVG(CASE WHEN status = ? AND disputed = 0 THEN payment_amount END), 0)
+		FROM codepay_orders
+		WHERE pid = ? AND add_time >= ?
+	`
+
+	var stats OrderStats
+	var average float64
+	err := db.QueryRow(query,
+		model.OrderStatusPending, model.OrderStatusPaid, model.OrderStatusClosed, model.OrderStatusRefund,
+		model.OrderStatusExpired,
+		model.OrderStatusPaid, model.OrderStatusPaid, model.OrderStatusPaid,
+		pid, since,
+	).Scan(
+		&stats.Total, &stats.Pending, &stats.Paid, &stats.Closed, &stats.Refund, &stats.Expired, &stats.Disputed,
+		&stats.Revenue, &stats.DisputedAmount, &average,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order stats: %w", err)
+	}
+
+	// 平均金额四舍五入到分，转化率保留四位小数
+	stats.AverageAmount = model.Amount(math.Round(average))
+	if stats.Total > 0 {
+		stats.ConversionRate = math.Round(float64(stats.Paid)/float64(stats.Total)*10000) / 10000
+	}
+
+	return &stats, nil
+}
+
+// MerchantOrderStats 单个商户的订单统计
+type MerchantOrderStats struct {
+	PID     string       `json:"pid"`
+	Total   int          `json:"total"`
+	Paid    int          `json:"paid"`
+	Revenue model.Amount `json:"revenue"` // 已支付订单实收金额合计（不含争议订单）
+}
+
+// GetMerchantOrderStats 按商户统计指定时间之后创建的订单（按实收金额从高到低）
+func (db *DB) GetMerchantOrderStats(since time.Time) ([]*MerchantOrderStats, error) {
+	rows, err := db.Query(`
+		SELECT
+			pid,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND disputed = 0 THEN payment_amount END), 0) AS revenue
+		FROM codepay_orders
+		WHERE add_time >= ?
+		GROUP BY pid
+		ORDER BY revenue DESC, pid
+	`, model.OrderStatusPaid, model.OrderStatusPaid, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merchant order stats: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*MerchantOrderStats
+	for rows.Next() {
+		var stats MerchantOrderStats
+		if err := rows.Scan(&stats.PID, &stats.Total, &stats.Paid, &stats.Revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant order stats: %w", err)
+		}
+		list = append(list, &stats)
+	}
+	return list, rows.Err()
+}
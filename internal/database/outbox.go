@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initOutboxTable 创建事件发件箱表（seq 为事件序号，外部消费者按序号补拉）
+func (db *DB) initOutboxTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS event_outbox (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type VARCHAR(32) NOT NULL,
+		order_id VARCHAR(32) NOT NULL DEFAULT '',
+		pid VARCHAR(32) NOT NULL DEFAULT '',
+		payload TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create event_outbox table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_event_outbox_created_at ON event_outbox(created_at);"); err != nil {
+		return fmt.Errorf("failed to create event_outbox index: %w", err)
+	}
+
+	return nil
+}
+
+// AppendOutboxEvent 写入发件箱，写入后 event.Seq 为分配的序号
+func (db *DB) AppendOutboxEvent(event *model.OutboxEvent) error {
+	result, err := db.Exec(`
+		INSERT INTO event_outbox (event_type, order_id, pid, payload, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, event.Type, event.OrderID, event.PID, event.Payload, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to append outbox event: %w", err)
+	}
+
+	event.Seq, _ = result.LastInsertId()
+	return nil
+}
+
+// GetOutboxEvents 获取序号大于 after 的事件（按序号正序），pid、types 为空时不过滤
+func (db *DB) GetOutboxEvents(after int64, pid string, types []string, limit int) ([]*model.OutboxEvent, error) {
+	where := []string{"seq > ?"}
+	args := []interface{}{after}
+	if pid != "" {
+		where = append(where, "pid = ?")
+		args = append(args, pid)
+	}
+	if len(types) > 0 {
+		where = append(where, "event_type IN (?"+strings.Repeat(", ?", len(types)-1)+")")
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(`
+		SELECT seq, event_type, order_id, pid, payload, created_at
+		FROM event_outbox
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY seq ASC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*model.OutboxEvent, 0)
+	for rows.Next() {
+		var e model.OutboxEvent
+		if err := rows.Scan(&e.Seq, &e.Type, &e.OrderID, &e.PID, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	return events, rows.Err()
+}
+
+// GetOutboxRange 获取发件箱中最小和最大的事件序号（为空时均为0）
+// 消费者保存的序号小于最小序号时，说明中间的事件已超过保留期被清理
+func (db *DB) GetOutboxRange() (int64, int64, error) {
+	var first, last int64
+	err := db.QueryRow("SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM event_outbox").Scan(&first, &last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get outbox range: %w", err)
+	}
+	return first, last, nil
+}
+
+// PruneOutboxEvents 删除指定时间之前发布的事件，返回删除条数
+func (db *DB) PruneOutboxEvents(before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM event_outbox WHERE created_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox events: %w", err)
+	}
+	return result.RowsAffected()
+}
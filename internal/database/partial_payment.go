@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initPartialPaymentTable 创建分笔支付表
+// 每笔账单只记录一次（账单在查询窗口内会被重复查到），订单的已收金额为该订单全部记录之和
+func (db *DB) initPartialPaymentTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS partial_payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id VARCHAR(32) NOT NULL,
+		alipay_trade_no VARCHAR(64) NOT NULL,
+		account_log_id VARCHAR(64) NOT NULL DEFAULT '',
+		amount INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE(alipay_trade_no, account_log_id)
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create partial_payments table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_partial_payment_order_id ON partial_payments(order_id);"); err != nil {
+		return fmt.Errorf("failed to create partial_payments index: %w", err)
+	}
+
+	return nil
+}
+
+// AddPartialPayment 记录一笔分笔支付，账单已记录过时返回false
+func (db *DB) AddPartialPayment(payment *model.PartialPayment) (bool, error) {
+	if payment.CreatedAt.IsZero() {
+		payment.CreatedAt = time.Now()
+	}
+
+	result, err := db.Exec(`
+		INSERT OR IGNORE INTO partial_payments (order_id, alipay_trade_no, account_log_id, amount, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, payment.OrderID, payment.AlipayTradeNo, payment.AccountLogID, payment.Amount, payment.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to add partial payment: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return false, nil
+	}
+	payment.ID, _ = result.LastInsertId()
+	return true, nil
+}
+
+// GetPartialPayments 获取订单的分笔支付记录（按匹配时间正序）
+func (db *DB) GetPartialPayments(orderID string) ([]*model.PartialPayment, error) {
+	rows, err := db.Query(`
+		SELECT id, order_id, alipay_trade_no, account_log_id, amount, created_at
+		FROM partial_payments
+		WHERE order_id = ?
+		ORDER BY id ASC
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partial payments: %w", err)
+	}
+	defer rows.Close()
+
+	payments := make([]*model.PartialPayment, 0)
+	for rows.Next() {
+		var p model.PartialPayment
+		if err := rows.Scan(&p.ID, &p.OrderID, &p.AlipayTradeNo, &p.AccountLogID, &p.Amount, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan partial payment: %w", err)
+		}
+		payments = append(payments, &p)
+	}
+	return payments, rows.Err()
+}
+
+// GetPartialPaidAmount 获取订单已收到的分笔支付金额
+func (db *DB) GetPartialPaidAmount(orderID string) (model.Amount, error) {
+	var total model.Amount
+	err := db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM partial_payments WHERE order_id = ?", orderID).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get partial paid amount: %w", err)
+	}
+	return total, nil
+}
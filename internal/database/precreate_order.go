@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// initPrecreateOrderTable 创建当面付二维码表
+// 保存 alipay.trade.precreate 返回的二维码内容，重复提交同一订单时直接返回，无需再次调用支付宝
+func (db *DB) initPrecreateOrderTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS precreate_orders (
+		trade_no VARCHAR(32) PRIMARY KEY,
+		qr_code VARCHAR(256) NOT NULL,
+		created_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create precreate_orders table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_precreate_created_at ON precreate_orders(created_at);"); err != nil {
+		return fmt.Errorf("failed to create precreate_orders index: %w", err)
+	}
+
+	return nil
+}
+
+// SavePrecreateQRCode 保存订单的当面付二维码内容
+func (db *DB) SavePrecreateQRCode(tradeNo, qrCode string) error {
+	_, err := db.Exec(
+		"INSERT OR REPLACE INTO precreate_orders (trade_no, qr_code, created_at) VALUES (?, ?, ?)",
+		tradeNo, qrCode, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save precreate qr code: %w", err)
+	}
+	return nil
+}
+
+// GetPrecreateQRCode 获取订单的当面付二维码内容，不存在时返回空字符串
+func (db *DB) GetPrecreateQRCode(tradeNo string) (string, error) {
+	var qrCode string
+	err := db.QueryRow("SELECT qr_code FROM precreate_orders WHERE trade_no = ?", tradeNo).Scan(&qrCode)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get precreate qr code: %w", err)
+	}
+	return qrCode, nil
+}
+
+// DeletePrecreateOrdersBefore 删除指定时间之前创建的当面付二维码（订单早已支付或过期）
+func (db *DB) DeletePrecreateOrdersBefore(before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM precreate_orders WHERE created_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete precreate orders: %w", err)
+	}
+
+	count, _ := result.RowsAffected()
+	return count, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// initPrintedCodeTable 创建线下打印收款码表
+func (db *DB) initPrintedCodeTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS printed_codes (
+		code VARCHAR(16) PRIMARY KEY,
+		batch_id VARCHAR(32) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		price INTEGER NOT NULL,
+		trade_no VARCHAR(32) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		scanned_at DATETIME
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create printed_codes table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_printed_codes_batch ON printed_codes(batch_id);"); err != nil {
+		return fmt.Errorf("failed to create printed_codes index: %w", err)
+	}
+
+	return nil
+}
+
+// CreatePrintedCodes 批量保存收款码（同一事务，短码冲突时整批失败）
+func (db *DB) CreatePrintedCodes(codes []*model.PrintedCode) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO printed_codes (code, batch_id, name, price, trade_no, created_at)
+		VALUES (?, ?, ?, ?, '', ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare printed code insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, code := range codes {
+		if _, err := stmt.Exec(code.Code, code.BatchID, code.Name, code.Price, code.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert printed code %s: %w", code.Code, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit printed codes: %w", err)
+	}
+	return nil
+}
+
+// GetPrintedCode 按短码查询收款码，不存在时返回nil
+func (db *DB) GetPrintedCode(code string) (*model.PrintedCode, error) {
+	row := db.QueryRow(`
+		SELECT code, batch_id, name, price, trade_no, created_at, scanned_at
+		FROM printed_codes
+		WHERE code = ?
+	`, code)
+
+	printed, err := scanPrintedCode(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get printed code: %w", err)
+	}
+	return printed, nil
+}
+
+// GetPrintedCodesByBatch 查询批次内的全部收款码
+func (db *DB) GetPrintedCodesByBatch(batchID string) ([]*model.PrintedCode, error) {
+	rows, err := db.Query(`
+		SELECT code, batch_id, name, price, trade_no, created_at, scanned_at
+		FROM printed_codes
+		WHERE batch_id = ?
+		ORDER BY rowid ASC
+	`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get printed codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []*model.PrintedCode
+	for rows.Next() {
+		printed, err := scanPrintedCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan printed code: %w", err)
+		}
+		codes = append(codes, printed)
+	}
+
+	return codes, rows.Err()
+}
+
+// BindPrintedCodeOrder 将收款码关联到新订单
+// 仅当当前关联订单仍为 prevTradeNo 时更新（并发扫码时只有一个请求的订单生效），返回是否更新
+func (db *DB) BindPrintedCodeOrder(code, prevTradeNo, tradeNo string) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE printed_codes SET trade_no = ?, scanned_at = ? WHERE code = ? AND trade_no = ?",
+		tradeNo, time.Now(), code, prevTradeNo,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to bind printed code order: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// scanPrintedCode 扫描收款码行
+func scanPrintedCode(row rowScanner) (*model.PrintedCode, error) {
+	var printed model.PrintedCode
+	var scannedAt sql.NullTime
+	if err := row.Scan(&printed.Code, &printed.BatchID, &printed.Name, &printed.Price,
+		&printed.TradeNo, &printed.CreatedAt, &scannedAt); err != nil {
+		return nil, err
+	}
+	if scannedAt.Valid {
+		printed.ScannedAt = &scannedAt.Time
+	}
+	return &printed, nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+
+	"alimpay-go/internal/model"
+)
+
+// initQRCodeTable 创建收款码设置表
+func (db *DB) initQRCodeTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS qr_codes (
+		id VARCHAR(32) PRIMARY KEY,
+		path VARCHAR(255) NOT NULL DEFAULT '',
+		code_id VARCHAR(64) NOT NULL DEFAULT '',
+		enabled TINYINT(1) NOT NULL DEFAULT 1,
+		priority INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create qr_codes table: %w", err)
+	}
+
+	return nil
+}
+
+// GetQRCodeSettings 获取全部收款码设置
+func (db *DB) GetQRCodeSettings() ([]*model.QRCodeSetting, error) {
+	rows, err := db.Query(`
+		SELECT id, path, code_id, enabled, priority, updated_at
+		FROM qr_codes
+		ORDER BY priority ASC, id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get qr code settings: %w", err)
+	}
+	defer rows.Close()
+
+	var settings []*model.QRCodeSetting
+	for rows.Next() {
+		var s model.QRCodeSetting
+		if err := rows.Scan(&s.ID, &s.Path, &s.CodeID, &s.Enabled, &s.Priority, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan qr code setting: %w", err)
+		}
+		settings = append(settings, &s)
+	}
+
+	return settings, rows.Err()
+}
+
+// SaveQRCodeSetting 保存收款码设置（存在则覆盖）
+func (db *DB) SaveQRCodeSetting(setting *model.QRCodeSetting) error {
+	_, err := db.Exec(`
+		INSERT INTO qr_codes (id, path, code_id, enabled, priority, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			path = excluded.path,
+			code_id = excluded.code_id,
+			enabled = excluded.enabled,
+			priority = excluded.priority,
+			updated_at = excluded.updated_at`,
+		setting.ID, setting.Path, setting.CodeID, setting.Enabled, setting.Priority, setting.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save qr code setting %s: %w", setting.ID, err)
+	}
+	return nil
+}
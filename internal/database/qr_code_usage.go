@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// QRCodeUsage 二维码的分配情况（待支付和已支付订单）
+type QRCodeUsage struct {
+	Count  int          `json:"count"`  // 订单数
+	Amount model.Amount `json:"amount"` // 实付金额合计
+}
+
+// GetQRCodeUsage 统计指定时间之后创建、分配到各二维码的待支付和已支付订单（用于每日限额）
+func (db *DB) GetQRCodeUsage(since time.Time) (map[string]*QRCodeUsage, error) {
+	rows, err := db.Query(`
+		SELECT qr_code_id, COUNT(*), COALESCE(SUM(payment_amount), 0)
+		FROM codepay_orders
+		WHERE add_time >= ? AND qr_code_id != '' AND status IN (?, ?)
+		GROUP BY qr_code_id
+	`, since, model.OrderStatusPending, model.OrderStatusPaid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get qr code usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]*QRCodeUsage)
+	for rows.Next() {
+		var id string
+		var u QRCodeUsage
+		if err := rows.Scan(&id, &u.Count, &u.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan qr code usage: %w", err)
+		}
+		usage[id] = &u
+	}
+	return usage, rows.Err()
+}
+
+// CountQRCodeOrders 统计指定时间区间内创建、分配到该二维码的订单数和其中已支付的订单数（用于收款码健康检查）
+func (db *DB) CountQRCodeOrders(qrCodeID string, since, until time.Time) (total, paid int, err error) {
+	err = db.QueryRow(`
+		SELECT COUNT(*), COUNT(pay_time)
+		FROM codepay_orders
+		WHERE qr_code_id = ? AND add_time >= ? AND add_time < ?
+	`, qrCodeID, since, until).Scan(&total, &paid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count qr code orders: %w", err)
+	}
+	return total, paid, nil
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// refundRequestColumns 退款申请查询列
+const refundRequestColumns = `refund_no, out_refund_no, order_id, out_trade_no, pid, amount, reason, status, admin_note, created_at, processed_at`
+
+// initRefundRequestTable 创建退款申请表
+// 商户退款单号在同一商户内唯一（未传时为空，不参与唯一约束）
+func (db *DB) initRefundRequestTable() error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS refund_requests (
+		refund_no VARCHAR(32) PRIMARY KEY,
+		out_refund_no VARCHAR(64) NOT NULL DEFAULT '',
+		order_id VARCHAR(32) NOT NULL,
+		out_trade_no VARCHAR(64) NOT NULL,
+		pid VARCHAR(32) NOT NULL,
+		amount INTEGER NOT NULL,
+		reason VARCHAR(256) NOT NULL DEFAULT '',
+		status VARCHAR(16) NOT NULL,
+		admin_note VARCHAR(256) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		processed_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_refund_requests_order_id ON refund_requests(order_id);
+	CREATE INDEX IF NOT EXISTS idx_refund_requests_status ON refund_requests(status, created_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_refund_requests_out_refund_no ON refund_requests(pid, out_refund_no) WHERE out_refund_no != '';`
+
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create refund_requests table: %w", err)
+	}
+
+	return nil
+}
+
+// CreateRefundRequest 保存退款申请
+// 订单待审核和已同意的退款金额加上本次金额超过 limit 时不保存并返回false（检查与插入在同一条语句中完成）
+func (db *DB) CreateRefundRequest(request *model.RefundRequest, limit model.Amount) (bool, error) {
+	if request.CreatedAt.IsZero() {
+		request.CreatedAt = time.Now()
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO refund_requests (refund_no, out_refund_no, order_id, out_trade_no, pid, amount, reason, status, admin_note, created_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, '', ?
+		WHERE (
+			SELECT COALESCE(SUM(amount), 0) FROM refund_requests
+			WHERE order_id = ? AND status IN (?, ?)
+		) + ? <= ?
+	`, request.RefundNo, request.OutRefundNo, request.OrderID, request.OutTradeNo, request.PID,
+		request.Amount, request.Reason, request.Status, request.CreatedAt,
+		request.OrderID, model.RefundStatusPending, model.RefundStatusApproved,
+		request.Amount, limit,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create refund request: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// GetRefundRequest 按退款申请号查询，不存在时返回nil
+func (db *DB) GetRefundRequest(refundNo string) (*model.RefundRequest, error) {
+	request, err := scanRefundRequest(db.QueryRow(
+		"SELECT "+refundRequestColumns+" FROM refund_requests WHERE refund_no = ?", refundNo))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refund request: %w", err)
+	}
+	return request, nil
+}
+
+// GetRefundRequestByOutRefundNo 按商户退款单号查询，不存在时返回nil
+func (db *DB) GetRefundRequestByOutRefundNo(pid, outRefundNo string) (*model.RefundRequest, error) {
+	request, err := scanRefundRequest(db.QueryRow(
+		"SELECT "+refundRequestColumns+" FROM refund_requests WHERE pid = ? AND out_refund_no = ?", pid, outRefundNo))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refund request: %w", err)
+	}
+	return request, nil
+}
+
+// ListRefundRequests 获取退款申请列表（按申请时间倒序，status 为空时返回全部状态）
+func (db *DB) ListRefundRequests(status string, limit int) ([]*model.RefundRequest, error) {
+	query := "SELECT " + refundRequestColumns + " FROM refund_requests"
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refund requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]*model.RefundRequest, 0)
+	for rows.Next() {
+		request, err := scanRefundRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund request: %w", err)
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// GetApprovedRefundAmount 获取订单已同意的退款金额
+func (db *DB) GetApprovedRefundAmount(orderID string) (model.Amount, error) {
+	var total model.Amount
+	err := db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM refund_requests WHERE order_id = ? AND status = ?",
+		orderID, model.RefundStatusApproved).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get approved refund amount: %w", err)
+	}
+	return total, nil
+}
+
+// ProcessRefundRequest 审核待审核的退款申请
+// 申请已被审核（并发审核或重复提交）时返回false
+func (db *DB) ProcessRefundRequest(refundNo, status, note string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE refund_requests SET status = ?, admin_note = ?, processed_at = ?
+		WHERE refund_no = ? AND status = ?
+	`, status, note, time.Now(), refundNo, model.RefundStatusPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to process refund request: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// scanRefundRequest 扫描一行退款申请记录
+func scanRefundRequest(row rowScanner) (*model.RefundRequest, error) {
+	var request model.RefundRequest
+	var processedAt sql.NullTime
+	err := row.Scan(&request.RefundNo, &request.OutRefundNo, &request.OrderID, &request.OutTradeNo, &request.PID,
+		&request.Amount, &request.Reason, &request.Status, &request.AdminNote, &request.CreatedAt, &processedAt)
+	if err != nil {
+		return nil, err
+	}
+	if processedAt.Valid {
+		request.ProcessedAt = &processedAt.Time
+	}
+	return &request, nil
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// SchemaVersion 当前程序使用的数据库结构版本，修改表结构（新增表、列、索引或数据迁移）时递增
+const SchemaVersion = 13
+
+// SchemaCompatibleFrom 当前数据库结构仍可运行的最低程序结构版本
+// 新增表、列等向后兼容的修改保持不变，旧版本实例可继续运行（滚动升级、蓝绿部署）；
+// 删除、重命名列等不兼容的修改需提升至 SchemaVersion，迫使旧版本实例拒绝启动
+// （13：两步验证密钥从系统设置迁移至 admin_two_factor 表，旧版本实例会视为未开启两步验证）
+const SchemaCompatibleFrom = 13
+
+// 数据库结构升级方式（配置 database.schema_upgrade）
+const (
+	SchemaUpgradeAuto   = "auto"   // 启动时自动升级
+	SchemaUpgradeManual = "manual" // 拒绝启动，需使用 -migrate 参数单独执行升级
+)
+
+// ErrSchemaUpgradeRequired 数据库结构版本低于程序版本且未开启自动升级
+var ErrSchemaUpgradeRequired = errors.New("database schema upgrade required")
+
+// ErrSchemaIncompatible 数据库已被不兼容的新版本升级，当前程序无法运行
+var ErrSchemaIncompatible = errors.New("database schema incompatible with this binary")
+
+// SchemaInfo 数据库结构版本记录
+type SchemaInfo struct {
+	Version        int       `json:"version"`
+	CompatibleFrom int       `json:"compatible_from"`
+	AppVersion     string    `json:"app_version"` // 执行升级的程序版本
+	AppliedAt      time.Time `json:"applied_at"`
+}
+
+// initSchemaVersionTable 创建数据库结构版本表（每次升级追加一行）
+func (db *DB) initSchemaVersionTable() error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		compatible_from INTEGER NOT NULL,
+		app_version VARCHAR(64) NOT NULL DEFAULT '',
+		applied_at DATETIME NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	return nil
+}
+
+// GetSchemaInfo 获取数据库当前的结构版本，未记录时返回nil
+func (db *DB) GetSchemaInfo() (*SchemaInfo, error) {
+	var info SchemaInfo
+	err := db.QueryRow(`
+		SELECT version, compatible_from, app_version, applied_at
+		FROM schema_version ORDER BY version DESC LIMIT 1`).
+		Scan(&info.Version, &info.CompatibleFrom, &info.AppVersion, &info.AppliedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema version: %w", err)
+	}
+	return &info, nil
+}
+
+// checkSchemaVersion 比较数据库与程序的结构版本，不满足运行条件时返回错误
+// @return bool 是否需要在建表后记录当前结构版本（新数据库、或数据库版本低于程序版本且允许升级）
+func (db *DB) checkSchemaVersion(cfg *Config) (bool, error) {
+	if err := db.initSchemaVersionTable(); err != nil {
+		return false, err
+	}
+
+	info, err := db.GetSchemaInfo()
+	if err != nil {
+		return false, err
+	}
+
+	current := 0
+	if info != nil {
+		current = info.Version
+	} else if exists, err := db.tableExists("codepay_orders"); err != nil {
+		return false, err
+	} else if !exists {
+		// 新数据库直接建表
+		return true, nil
+	}
+	// 未记录版本但已有订单表的数据库来自引入版本记录之前的程序，视为版本0
+
+	switch {
+	case current == SchemaVersion:
+		return false, nil
+
+	case current > SchemaVersion:
+		if SchemaVersion < info.CompatibleFrom {
+			return false, fmt.Errorf("%w: database schema version %d (upgraded by %s) requires schema version %d or later, this binary uses %d",
+				ErrSchemaIncompatible, info.Version, info.AppVersion, info.CompatibleFrom, SchemaVersion)
+		}
+		logger.Warn("Database schema is newer than this binary, running in compatibility mode",
+			zap.Int("database_schema_version", info.Version),
+			zap.Int("binary_schema_version", SchemaVersion),
+			zap.String("upgraded_by", info.AppVersion))
+		return false, nil
+
+	default:
+		if cfg.SchemaUpgrade == SchemaUpgradeManual {
+			return false, fmt.Errorf("%w: database schema version %d is older than %d, run with -migrate to upgrade",
+				ErrSchemaUpgradeRequired, current, SchemaVersion)
+		}
+		logger.Info("Upgrading database schema",
+			zap.Int("from_version", current),
+			zap.Int("to_version", SchemaVersion))
+		return true, nil
+	}
+}
+
+// recordSchemaVersion 记录建表或升级完成后的结构版本
+func (db *DB) recordSchemaVersion(appVersion string) error {
+	_, err := db.Exec(`
+		INSERT INTO schema_version (version, compatible_from, app_version, applied_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(version) DO UPDATE SET
+			compatible_from = excluded.compatible_from,
+			app_version = excluded.app_version,
+			applied_at = excluded.applied_at`,
+		SchemaVersion, SchemaCompatibleFrom, appVersion, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
+
+// tableExists 判断表是否存在
+func (db *DB) tableExists(name string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", name, err)
+	}
+	return count > 0, nil
+}
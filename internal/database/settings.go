@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// 系统设置键
+const (
+	SettingAdminSessionSecret = "admin_session_secret" // 管理后台session签名密钥
+	SettingAdminTOTPSecret    = "admin_totp_secret"    // 旧版本全部账号共用的两步验证密钥（启动时迁移至 admin_two_factor 表）
+	SettingAdminTOTPPending   = "admin_totp_pending"   // 旧版本两步验证待确认的密钥（迁移时删除）
+	SettingAdminTOTPLastStep  = "admin_totp_last_step" // 旧版本最近一次使用的动态验证码时间步（迁移至 admin_two_factor 表）
+	SettingAdminRecoveryCodes = "admin_recovery_codes" // 旧版本两步验证恢复码（迁移至 admin_two_factor 表）
+	SettingAmountUnit         = "amount_unit"          // 订单金额单位（fen 表示已从元迁移为整数分）
+	SettingSandboxMerchantID  = "sandbox_merchant_id"  // 沙箱商户ID（签发后不变）
+	SettingSandboxMerchantKey = "sandbox_merchant_key" // 沙箱商户密钥（可重新签发）
+
+	SettingMerchantKey               = "merchant_key"                 // 管理后台轮换生成的商户密钥（优先于配置文件）
+	SettingMerchantKeyOrigin         = "merchant_key_origin"          // 首次轮换时配置文件中商户密钥的SHA-256摘要（配置文件修改后轮换的密钥作废）
+	SettingMerchantPreviousKey       = "merchant_previous_key"        // 轮换前的商户密钥（过渡期内仍可验证签名）
+	SettingMerchantPreviousKeyExpiry = "merchant_previous_key_expiry" // 旧密钥过渡期截止时间（Unix秒）
+)
+
+// amountUnitFen 订单金额以整数分存储
+const amountUnitFen = "fen"
+
+// GetSetting 获取系统设置，不存在时返回空字符串
+func (db *DB) GetSetting(key string) (string, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM system_settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetSetting 保存系统设置（存在则覆盖）
+func (db *DB) SetSetting(key, value string) error {
+	_, err := db.Exec(`
+		INSERT INTO system_settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// SetSettings 在一个事务中保存多个系统设置（全部成功或全部不保存）
+func (db *DB) SetSettings(values map[string]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for key, value := range values {
+		_, err := tx.Exec(`
+			INSERT INTO system_settings (key, value, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			key, value, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save setting %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit settings: %w", err)
+	}
+	return nil
+}
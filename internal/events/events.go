@@ -23,7 +23,11 @@ Description: 提供应用级事件发布/订阅机制
 package events
 
 import (
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
@@ -38,6 +42,8 @@ const (
 	EventOrderPaid    = "order:paid"    // 订单支付成功
 	EventOrderExpired = "order:expired" // 订单过期
 	EventOrderCreated = "order:created" // 订单创建
+	EventOrderClosed  = "order:closed"  // 订单关闭
+	EventOrderRefund  = "order:refund"  // 订单退款
 )
 
 /*
@@ -46,23 +52,70 @@ EventHandler 事件处理函数类型
 */
 type EventHandler func(data interface{})
 
+/*
+RecordFunc 事件记录函数类型
+功能: 在处理器执行前持久化本节点发布的事件（如事件发件箱）
+*/
+type RecordFunc func(eventType string, data interface{})
+
+/*
+ForwardFunc 事件转发函数类型
+功能: 将本节点发布的事件转发到其他节点（如Redis发布订阅）
+*/
+type ForwardFunc func(eventType string, data interface{})
+
+/*
+subscription 事件订阅
+字段:
+  - name: 处理函数名称（如 alimpay-go/internal/handler.(*WebSocketHandler).Start.func1），用于排查订阅是否生效
+  - handler: 处理函数
+*/
+type subscription struct {
+	name    string
+	handler EventHandler
+}
+
+/*
+eventCounters 单个事件类型的计数
+字段:
+  - published: 本节点发布次数
+  - received: 收到其他节点转发的次数
+  - unhandled: 发布时没有订阅者的次数
+  - handled: 处理器执行完成次数（每个处理器各计一次）
+  - failed: 处理器panic次数
+*/
+type eventCounters struct {
+	published atomic.Int64
+	received  atomic.Int64
+	unhandled atomic.Int64
+	handled   atomic.Int64
+	failed    atomic.Int64
+}
+
 /*
 EventBus 事件总线
 功能: 管理事件订阅和发布
 字段:
-  - handlers: 事件处理器映射 (eventType -> []handler)
+  - handlers: 事件处理器映射 (eventType -> []subscription)
+  - counters: 事件计数 (eventType -> counters)，进程启动后累计，取消订阅不清零
+  - forwarder: 集群事件转发器（可选）
+  - recorder: 事件记录器（可选）
   - mu: 读写锁保护
 */
 type EventBus struct {
-	handlers map[string][]EventHandler
-	mu       sync.RWMutex
+	handlers  map[string][]subscription
+	counters  map[string]*eventCounters
+	forwarder ForwardFunc
+	recorder  RecordFunc
+	mu        sync.RWMutex
 }
 
 /*
 全局事件总线实例
 */
 var globalBus = &EventBus{
-	handlers: make(map[string][]EventHandler),
+	handlers: make(map[string][]subscription),
+	counters: make(map[string]*eventCounters),
 }
 
 /*
@@ -76,26 +129,85 @@ func Subscribe(eventType string, handler EventHandler) {
 	globalBus.mu.Lock()
 	defer globalBus.mu.Unlock()
 
-	globalBus.handlers[eventType] = append(globalBus.handlers[eventType], handler)
+	name := handlerName(handler)
+	globalBus.handlers[eventType] = append(globalBus.handlers[eventType], subscription{name: name, handler: handler})
 
 	logger.Info("Event handler subscribed",
 		zap.String("event_type", eventType),
+		zap.String("handler", name),
 		zap.Int("total_handlers", len(globalBus.handlers[eventType])))
 }
 
 /*
 Publish 发布事件
-功能: 触发所有订阅该事件的处理器
+功能: 记录事件后触发所有订阅该事件的处理器，并转发到集群中的其他节点
 参数:
   - eventType: 事件类型
   - data: 事件数据
 */
 func Publish(eventType string, data interface{}) {
+	globalBus.mu.RLock()
+	forwarder := globalBus.forwarder
+	recorder := globalBus.recorder
+	globalBus.mu.RUnlock()
+
+	// 只记录本节点发布的事件，其他节点转发来的事件由发布节点记录
+	if recorder != nil {
+		recorder(eventType, data)
+	}
+	if forwarder != nil {
+		forwarder(eventType, data)
+	}
+
+	countersFor(eventType).published.Add(1)
+	dispatch(eventType, data)
+}
+
+/*
+PublishRemote 发布来自其他节点的事件
+功能: 仅触发本节点的处理器，不再转发（避免集群内循环广播）
+参数:
+  - eventType: 事件类型
+  - data: 事件数据
+*/
+func PublishRemote(eventType string, data interface{}) {
+	countersFor(eventType).received.Add(1)
+	dispatch(eventType, data)
+}
+
+/*
+SetForwarder 设置集群事件转发器
+参数:
+  - forwarder: 转发函数，为nil则关闭转发
+*/
+func SetForwarder(forwarder ForwardFunc) {
+	globalBus.mu.Lock()
+	defer globalBus.mu.Unlock()
+
+	globalBus.forwarder = forwarder
+}
+
+/*
+SetRecorder 设置事件记录器
+参数:
+  - recorder: 记录函数，为nil则关闭记录
+*/
+func SetRecorder(recorder RecordFunc) {
+	globalBus.mu.Lock()
+	defer globalBus.mu.Unlock()
+
+	globalBus.recorder = recorder
+}
+
+// dispatch 异步执行本节点订阅的处理器
+func dispatch(eventType string, data interface{}) {
 	globalBus.mu.RLock()
 	handlers := globalBus.handlers[eventType]
 	globalBus.mu.RUnlock()
 
+	counters := countersFor(eventType)
 	if len(handlers) == 0 {
+		counters.unhandled.Add(1)
 		return
 	}
 
@@ -104,20 +216,50 @@ func Publish(eventType string, data interface{}) {
 		zap.Int("handlers_count", len(handlers)))
 
 	// 异步执行所有处理器
-	for _, handler := range handlers {
-		go func(h EventHandler) {
+	for _, sub := range handlers {
+		go func(sub subscription) {
 			defer func() {
 				if r := recover(); r != nil {
+					counters.failed.Add(1)
 					logger.Error("Event handler panicked",
 						zap.String("event_type", eventType),
+						zap.String("handler", sub.name),
 						zap.Any("panic", r))
 				}
 			}()
-			h(data)
-		}(handler)
+			sub.handler(data)
+			counters.handled.Add(1)
+		}(sub)
 	}
 }
 
+// countersFor 获取事件类型的计数（不存在时创建）
+func countersFor(eventType string) *eventCounters {
+	globalBus.mu.RLock()
+	counters, ok := globalBus.counters[eventType]
+	globalBus.mu.RUnlock()
+	if ok {
+		return counters
+	}
+
+	globalBus.mu.Lock()
+	defer globalBus.mu.Unlock()
+	if counters, ok = globalBus.counters[eventType]; !ok {
+		counters = &eventCounters{}
+		globalBus.counters[eventType] = counters
+	}
+	return counters
+}
+
+// handlerName 处理函数名称（方法值去掉编译器添加的 -fm 后缀）
+func handlerName(handler EventHandler) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return strings.TrimSuffix(fn.Name(), "-fm")
+}
+
 /*
 PublishOrderPaid 发布订单支付成功事件
 便捷方法: 发布订单支付事件
@@ -148,6 +290,26 @@ func PublishOrderExpired(order *model.Order) {
 	Publish(EventOrderExpired, order)
 }
 
+/*
+PublishOrderClosed 发布订单关闭事件
+便捷方法: 发布订单关闭事件
+参数:
+  - order: 订单信息
+*/
+func PublishOrderClosed(order *model.Order) {
+	Publish(EventOrderClosed, order)
+}
+
+/*
+PublishOrderRefund 发布订单退款事件
+便捷方法: 发布订单退款事件
+参数:
+  - order: 订单信息
+*/
+func PublishOrderRefund(order *model.Order) {
+	Publish(EventOrderRefund, order)
+}
+
 /*
 Unsubscribe 取消所有订阅
 功能: 清理事件处理器（用于测试或重置）
@@ -159,7 +321,7 @@ func Unsubscribe(eventType string) {
 	defer globalBus.mu.Unlock()
 
 	if eventType == "" {
-		globalBus.handlers = make(map[string][]EventHandler)
+		globalBus.handlers = make(map[string][]subscription)
 	} else {
 		delete(globalBus.handlers, eventType)
 	}
@@ -167,20 +329,45 @@ func Unsubscribe(eventType string) {
 
 /*
 GetStats 获取事件系统统计信息
+功能: 各事件类型的订阅数、订阅的处理函数和发布/处理计数，用于确认WebSocket推送等处理器已订阅
 返回:
   - map[string]interface{}: 统计数据
+    subscriptions: 订阅数 (eventType -> count)
+    handlers: 处理函数名称 (eventType -> []name)
+    counters: 计数 (eventType -> published、received、unhandled、handled、failed)
+    forwarding: 是否已启用集群事件转发
+    recording: 是否已启用事件记录（事件发件箱）
 */
 func GetStats() map[string]interface{} {
 	globalBus.mu.RLock()
 	defer globalBus.mu.RUnlock()
 
 	stats := make(map[string]int)
+	names := make(map[string][]string)
 	for eventType, handlers := range globalBus.handlers {
 		stats[eventType] = len(handlers)
+		for _, sub := range handlers {
+			names[eventType] = append(names[eventType], sub.name)
+		}
+	}
+
+	counters := make(map[string]map[string]int64)
+	for eventType, c := range globalBus.counters {
+		counters[eventType] = map[string]int64{
+			"published": c.published.Load(),
+			"received":  c.received.Load(),
+			"unhandled": c.unhandled.Load(),
+			"handled":   c.handled.Load(),
+			"failed":    c.failed.Load(),
+		}
 	}
 
 	return map[string]interface{}{
 		"subscriptions": stats,
 		"event_types":   len(globalBus.handlers),
+		"handlers":      names,
+		"counters":      counters,
+		"forwarding":    globalBus.forwarder != nil,
+		"recording":     globalBus.recorder != nil,
 	}
 }
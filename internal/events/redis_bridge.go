@@ -0,0 +1,184 @@
+/*
+Package events Redis事件桥接
+Author: AliMPay Team
+Description: 通过Redis发布订阅在集群节点间同步订单事件
+
+功能:
+  - 本节点发布的订单事件转发到Redis频道
+  - 接收其他节点的订单事件并在本节点分发
+  - 使WebSocket推送覆盖连接在任意节点上的客户端
+
+使用示例:
+
+	bridge := events.NewRedisBridge(redisClient, "alimpay:events")
+	bridge.Start()
+	defer bridge.Stop()
+*/
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// DefaultBridgeChannel 默认的事件频道名
+const DefaultBridgeChannel = "alimpay:events"
+
+/*
+bridgeMessage 集群事件消息
+字段:
+  - Node: 发布节点标识（用于忽略自己发出的消息）
+  - Type: 事件类型
+  - Order: 订单数据
+*/
+type bridgeMessage struct {
+	Node  string       `json:"node"`
+	Type  string       `json:"type"`
+	Order *model.Order `json:"order"`
+}
+
+/*
+RedisBridge Redis事件桥接器
+字段:
+  - client: Redis客户端
+  - channel: 发布订阅频道
+  - nodeID: 本节点标识
+  - cancel: 停止订阅
+*/
+type RedisBridge struct {
+	client  *redis.Client
+	channel string
+	nodeID  string
+	cancel  context.CancelFunc
+}
+
+/*
+NewRedisBridge 创建Redis事件桥接器
+参数:
+  - client: Redis客户端
+  - channel: 频道名（为空使用 DefaultBridgeChannel）
+
+返回:
+  - *RedisBridge: 桥接器实例
+*/
+func NewRedisBridge(client *redis.Client, channel string) *RedisBridge {
+	if channel == "" {
+		channel = DefaultBridgeChannel
+	}
+
+	return &RedisBridge{
+		client:  client,
+		channel: channel,
+		nodeID:  newNodeID(),
+	}
+}
+
+/*
+Start 启动桥接
+功能: 注册事件转发器并开始订阅Redis频道
+*/
+func (b *RedisBridge) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	SetForwarder(b.forward)
+
+	go b.listen(ctx, pubsub)
+
+	logger.Info("Redis event bridge started",
+		zap.String("channel", b.channel),
+		zap.String("node_id", b.nodeID))
+}
+
+/*
+Stop 停止桥接
+功能: 取消事件转发并关闭订阅
+*/
+func (b *RedisBridge) Stop() {
+	SetForwarder(nil)
+	if b.cancel != nil {
+		b.cancel()
+	}
+	logger.Info("Redis event bridge stopped")
+}
+
+// forward 将本节点事件发布到Redis
+func (b *RedisBridge) forward(eventType string, data interface{}) {
+	order, ok := data.(*model.Order)
+	if !ok {
+		return
+	}
+
+	payload, err := json.Marshal(bridgeMessage{
+		Node:  b.nodeID,
+		Type:  eventType,
+		Order: order,
+	})
+	if err != nil {
+		logger.Error("Failed to marshal bridge message", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		logger.Warn("Failed to publish event to redis",
+			zap.String("event_type", eventType),
+			zap.Error(err))
+	}
+}
+
+// listen 接收其他节点的事件并在本节点分发
+func (b *RedisBridge) listen(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var message bridgeMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+				logger.Warn("Invalid bridge message", zap.Error(err))
+				continue
+			}
+
+			// 忽略本节点发出的消息（本地已分发）
+			if message.Node == b.nodeID || message.Order == nil {
+				continue
+			}
+
+			logger.Debug("Received remote event",
+				zap.String("event_type", message.Type),
+				zap.String("from_node", message.Node),
+				zap.String("order_id", message.Order.ID))
+
+			PublishRemote(message.Type, message.Order)
+		}
+	}
+}
+
+// newNodeID 生成随机节点标识
+func newNodeID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
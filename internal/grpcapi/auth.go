@@ -0,0 +1,112 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/service"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// 商户凭据 metadata 键
+const (
+	MetadataMerchantPID = "x-merchant-pid"
+	MetadataMerchantKey = "x-merchant-key"
+)
+
+// merchantKey context中保存已认证商户ID的键
+type merchantKey struct{}
+
+// merchantFromContext 获取已认证的商户ID
+func merchantFromContext(ctx context.Context) string {
+	pid, _ := ctx.Value(merchantKey{}).(string)
+	return pid
+}
+
+// authenticate 校验metadata中的商户凭据
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	pid := firstValue(md, MetadataMerchantPID)
+	key := firstValue(md, MetadataMerchantKey)
+
+	if err := s.codepay.VerifyMerchant(pid, key); err != nil {
+		var credErr *service.CredentialError
+		if errors.As(err, &credErr) {
+			return nil, status.Error(codes.Unauthenticated, credErr.Msg)
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	// 来源IP白名单（merchant.allowed_ips，与HTTP接口相同）
+	if allowed := s.cfg.Merchant.AllowedIPs; pid == s.cfg.Merchant.ID && len(allowed) > 0 {
+		ip := peerIP(ctx)
+		if !utils.IPInList(ip, allowed) {
+			logger.Warn("Merchant request from disallowed ip",
+				zap.String("path", "grpc"),
+				zap.String("pid", pid),
+				zap.String("ip", ip))
+			return nil, status.Error(codes.PermissionDenied, "request IP is not allowed for this merchant")
+		}
+	}
+
+	return context.WithValue(ctx, merchantKey{}, pid), nil
+}
+
+// peerIP 客户端IP（无法获取时为空）
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// unaryAuth 一元调用认证拦截器
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamAuth 流式调用认证拦截器
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream 携带认证信息的ServerStream
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 返回附带商户ID的context
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
+
+// firstValue 获取metadata中的第一个值
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,268 @@
+// AliMPay gRPC 接口
+//
+// 供内部服务通过 protobuf 调用，与 /mapi、/api 等 HTTP 接口共用同一套下单与查询逻辑。
+// 认证方式：每次调用在 metadata 中携带 x-merchant-pid 和 x-merchant-key。
+//
+// 重新生成代码：
+//   buf generate api/proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: alimpay/v1/alimpay.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	AliMPay_CreatePayment_FullMethodName = "/alimpay.v1.AliMPay/CreatePayment"
+	AliMPay_QueryOrder_FullMethodName    = "/alimpay.v1.AliMPay/QueryOrder"
+	AliMPay_CloseOrder_FullMethodName    = "/alimpay.v1.AliMPay/CloseOrder"
+	AliMPay_OrderStatus_FullMethodName   = "/alimpay.v1.AliMPay/OrderStatus"
+)
+
+// AliMPayClient is the client API for AliMPay service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AliMPayClient interface {
+	// 创建支付订单（out_trade_no 重复时返回已有订单）
+	CreatePayment(ctx context.Context, in *CreatePaymentRequest, opts ...grpc.CallOption) (*CreatePaymentResponse, error)
+	// 查询订单
+	QueryOrder(ctx context.Context, in *QueryOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// 关闭未支付订单
+	CloseOrder(ctx context.Context, in *CloseOrderRequest, opts ...grpc.CallOption) (*CloseOrderResponse, error)
+	// 订阅订单状态：先推送当前状态，之后每次变化推送一次，订单终结后结束
+	OrderStatus(ctx context.Context, in *OrderStatusRequest, opts ...grpc.CallOption) (AliMPay_OrderStatusClient, error)
+}
+
+type aliMPayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAliMPayClient(cc grpc.ClientConnInterface) AliMPayClient {
+	return &aliMPayClient{cc}
+}
+
+func (c *aliMPayClient) CreatePayment(ctx context.Context, in *CreatePaymentRequest, opts ...grpc.CallOption) (*CreatePaymentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreatePaymentResponse)
+	err := c.cc.Invoke(ctx, AliMPay_CreatePayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aliMPayClient) QueryOrder(ctx context.Context, in *QueryOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Order)
+	err := c.cc.Invoke(ctx, AliMPay_QueryOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aliMPayClient) CloseOrder(ctx context.Context, in *CloseOrderRequest, opts ...grpc.CallOption) (*CloseOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CloseOrderResponse)
+	err := c.cc.Invoke(ctx, AliMPay_CloseOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aliMPayClient) OrderStatus(ctx context.Context, in *OrderStatusRequest, opts ...grpc.CallOption) (AliMPay_OrderStatusClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AliMPay_ServiceDesc.Streams[0], AliMPay_OrderStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aliMPayOrderStatusClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AliMPay_OrderStatusClient interface {
+	Recv() (*OrderStatusUpdate, error)
+	grpc.ClientStream
+}
+
+type aliMPayOrderStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *aliMPayOrderStatusClient) Recv() (*OrderStatusUpdate, error) {
+	m := new(OrderStatusUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AliMPayServer is the server API for AliMPay service.
+// All implementations must embed UnimplementedAliMPayServer
+// for forward compatibility
+type AliMPayServer interface {
+	// 创建支付订单（out_trade_no 重复时返回已有订单）
+	CreatePayment(context.Context, *CreatePaymentRequest) (*CreatePaymentResponse, error)
+	// 查询订单
+	QueryOrder(context.Context, *QueryOrderRequest) (*Order, error)
+	// 关闭未支付订单
+	CloseOrder(context.Context, *CloseOrderRequest) (*CloseOrderResponse, error)
+	// 订阅订单状态：先推送当前状态，之后每次变化推送一次，订单终结后结束
+	OrderStatus(*OrderStatusRequest, AliMPay_OrderStatusServer) error
+	mustEmbedUnimplementedAliMPayServer()
+}
+
+// UnimplementedAliMPayServer must be embedded to have forward compatible implementations.
+type UnimplementedAliMPayServer struct {
+}
+
+func (UnimplementedAliMPayServer) CreatePayment(context.Context, *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePayment not implemented")
+}
+func (UnimplementedAliMPayServer) QueryOrder(context.Context, *QueryOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryOrder not implemented")
+}
+func (UnimplementedAliMPayServer) CloseOrder(context.Context, *CloseOrderRequest) (*CloseOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseOrder not implemented")
+}
+func (UnimplementedAliMPayServer) OrderStatus(*OrderStatusRequest, AliMPay_OrderStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method OrderStatus not implemented")
+}
+func (UnimplementedAliMPayServer) mustEmbedUnimplementedAliMPayServer() {}
+
+// UnsafeAliMPayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AliMPayServer will
+// result in compilation errors.
+type UnsafeAliMPayServer interface {
+	mustEmbedUnimplementedAliMPayServer()
+}
+
+func RegisterAliMPayServer(s grpc.ServiceRegistrar, srv AliMPayServer) {
+	s.RegisterService(&AliMPay_ServiceDesc, srv)
+}
+
+func _AliMPay_CreatePayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AliMPayServer).CreatePayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AliMPay_CreatePayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AliMPayServer).CreatePayment(ctx, req.(*CreatePaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AliMPay_QueryOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AliMPayServer).QueryOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AliMPay_QueryOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AliMPayServer).QueryOrder(ctx, req.(*QueryOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AliMPay_CloseOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AliMPayServer).CloseOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AliMPay_CloseOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AliMPayServer).CloseOrder(ctx, req.(*CloseOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AliMPay_OrderStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(OrderStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AliMPayServer).OrderStatus(m, &aliMPayOrderStatusServer{ServerStream: stream})
+}
+
+type AliMPay_OrderStatusServer interface {
+	Send(*OrderStatusUpdate) error
+	grpc.ServerStream
+}
+
+type aliMPayOrderStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *aliMPayOrderStatusServer) Send(m *OrderStatusUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// AliMPay_ServiceDesc is the grpc.ServiceDesc for AliMPay service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AliMPay_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "alimpay.v1.AliMPay",
+	HandlerType: (*AliMPayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreatePayment",
+			Handler:    _AliMPay_CreatePayment_Handler,
+		},
+		{
+			MethodName: "QueryOrder",
+			Handler:    _AliMPay_QueryOrder_Handler,
+		},
+		{
+			MethodName: "CloseOrder",
+			Handler:    _AliMPay_CloseOrder_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "OrderStatus",
+			Handler:       _AliMPay_OrderStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "alimpay/v1/alimpay.proto",
+}
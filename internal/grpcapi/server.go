@@ -0,0 +1,415 @@
+/*
+Package grpcapi gRPC接口服务
+Author: AliMPay Team
+Description: 在独立端口上提供gRPC接口，供偏好protobuf的内部服务调用
+
+功能:
+  - CreatePayment: 创建支付订单
+  - QueryOrder: 查询订单
+  - CloseOrder: 关闭未支付订单
+  - OrderStatus: 服务端流式推送订单状态变化
+
+认证:
+
+	每次调用在metadata中携带 x-merchant-pid 和 x-merchant-key
+
+接口定义见 api/proto/alimpay/v1/alimpay.proto
+*/
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/grpcapi/pb"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/service"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// statusPollInterval 订单状态兜底轮询间隔（覆盖未发布事件的状态变化，如关闭订单）
+const statusPollInterval = 5 * time.Second
+
+/*
+Server gRPC服务
+字段:
+  - cfg: 配置
+  - db: 数据库实例
+  - codepay: 码支付服务
+  - grpcServer: 底层gRPC服务器
+  - watchers: 订单状态订阅者 (order_id -> []chan)
+  - mu: 读写锁，保护watchers
+*/
+type Server struct {
+	pb.UnimplementedAliMPayServer
+
+	cfg        *config.Config
+	db         *database.DB
+	codepay    *service.CodePayService
+	grpcServer *grpc.Server
+	watchers   map[string][]chan *model.Order
+	mu         sync.RWMutex
+}
+
+/*
+NewServer 创建gRPC服务
+参数:
+  - cfg: 配置
+  - db: 数据库实例
+  - codepay: 码支付服务
+
+返回:
+  - *Server: gRPC服务实例
+  - error: 加载TLS证书失败时返回错误
+*/
+func NewServer(cfg *config.Config, db *database.DB, codepay *service.CodePayService) (*Server, error) {
+	s := &Server{
+		cfg:      cfg,
+		db:       db,
+		codepay:  codepay,
+		watchers: make(map[string][]chan *model.Order),
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	}
+
+	// 与HTTP服务共用证书
+	if cfg.Server.TLSCertFile != "" && cfg.Server.TLSKeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s.grpcServer = grpc.NewServer(opts...)
+	pb.RegisterAliMPayServer(s.grpcServer, s)
+
+	// 订单支付/关闭/过期时通知状态订阅者
+	notify := func(data interface{}) {
+		if order, ok := data.(*model.Order); ok {
+			s.notifyWatchers(order)
+		}
+	}
+	events.Subscribe(events.EventOrderPaid, notify)
+	events.Subscribe(events.EventOrderClosed, notify)
+	events.Subscribe(events.EventOrderExpired, notify)
+
+	return s, nil
+}
+
+/*
+Start 在独立端口上启动gRPC服务（非阻塞）
+参数:
+  - addr: 监听地址
+*/
+func (s *Server) Start(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.Serve(lis)
+	return nil
+}
+
+/*
+Serve 在已创建的监听上启动gRPC服务（非阻塞）
+用途: 平滑升级时使用继承自旧进程的监听
+参数:
+  - lis: 监听
+*/
+func (s *Server) Serve(lis net.Listener) {
+	go func() {
+		if err := s.grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) && !errors.Is(err, net.ErrClosed) {
+			logger.Error("gRPC server stopped unexpectedly", zap.Error(err))
+		}
+	}()
+
+	logger.Success("gRPC server started", zap.String("address", lis.Addr().String()))
+}
+
+/*
+Stop 优雅停止gRPC服务
+功能: 等待进行中的调用结束，超时后强制关闭
+*/
+func (s *Server) Stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+	}
+}
+
+// CreatePayment 创建支付订单
+// 调用方已通过商户密钥认证，由服务端代为签名后复用HTTP接口的下单流程
+func (s *Server) CreatePayment(ctx context.Context, req *pb.CreatePaymentRequest) (*pb.CreatePaymentResponse, error) {
+	pid := merchantFromContext(ctx)
+
+	paymentType := req.GetType()
+	if paymentType == "" {
+		paymentType = model.PaymentTypeAlipay
+	}
+
+	params := map[string]string{
+		"pid":          pid,
+		"type":         paymentType,
+		"out_trade_no": req.GetOutTradeNo(),
+		"notify_url":   req.GetNotifyUrl(),
+		"return_url":   req.GetReturnUrl(),
+		"name":         req.GetName(),
+		"money":        req.GetMoney(),
+		"sitename":     req.GetSitename(),
+		"sign_type":    s.codepay.SignType(),
+	}
+	params["sign"] = utils.GenerateSign(params, s.codepay.GetMerchantKey())
+
+	result, err := s.codepay.CreatePayment(params, s.baseURL(), "")
+	if err != nil {
+		logger.Warn("gRPC CreatePayment failed",
+			zap.String("out_trade_no", req.GetOutTradeNo()),
+			zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &pb.CreatePaymentResponse{}
+	resp.TradeNo, _ = result["trade_no"].(string)
+	resp.OutTradeNo, _ = result["out_trade_no"].(string)
+	resp.Money, _ = result["money"].(string)
+	if amount, ok := result["payment_amount"].(model.Amount); ok {
+		resp.PaymentAmount = amount.Yuan()
+	}
+	resp.PaymentUrl, _ = result["payment_url"].(string)
+	resp.QrCode, _ = result["qr_code"].(string)
+	resp.CreateTime, _ = result["create_time"].(string)
+	resp.AmountAdjusted, _ = result["amount_adjusted"].(bool)
+
+	return resp, nil
+}
+
+// QueryOrder 查询订单
+func (s *Server) QueryOrder(ctx context.Context, req *pb.QueryOrderRequest) (*pb.Order, error) {
+	order, err := s.findOrder(ctx, req.GetOutTradeNo())
+	if err != nil {
+		return nil, err
+	}
+	return toPBOrder(order), nil
+}
+
+// CloseOrder 关闭未支付订单
+func (s *Server) CloseOrder(ctx context.Context, req *pb.CloseOrderRequest) (*pb.CloseOrderResponse, error) {
+	if req.GetOutTradeNo() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing required parameters")
+	}
+
+	order, err := s.codepay.CloseOrder(merchantFromContext(ctx), req.GetOutTradeNo())
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return nil, status.Error(codes.NotFound, "Order not found")
+	case errors.Is(err, service.ErrOrderAlreadyPaid):
+		return nil, status.Error(codes.FailedPrecondition, "Order already paid, cannot close")
+	case err != nil:
+		logger.Error("gRPC CloseOrder failed", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Failed to close order")
+	}
+
+	s.notifyWatchers(order)
+
+	return &pb.CloseOrderResponse{
+		TradeNo: order.ID,
+		State:   pb.OrderState(order.Status),
+	}, nil
+}
+
+// OrderStatus 推送订单状态变化，订单进入终态或客户端断开后结束
+func (s *Server) OrderStatus(req *pb.OrderStatusRequest, stream pb.AliMPay_OrderStatusServer) error {
+	ctx := stream.Context()
+
+	order, err := s.findOrder(ctx, req.GetOutTradeNo())
+	if err != nil {
+		return err
+	}
+
+	updates := s.watch(order.ID)
+	defer s.unwatch(order.ID, updates)
+
+	// 先推送当前状态
+	if err := stream.Send(toStatusUpdate(order, pb.OrderState(order.Status))); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+
+	lastState := pb.OrderState(order.Status)
+	for lastState == pb.OrderState_ORDER_STATE_PENDING {
+		var state pb.OrderState
+		select {
+		case <-ctx.Done():
+			return nil
+		case updated := <-updates:
+			order, state = updated, pb.OrderState(updated.Status)
+		case <-ticker.C:
+			current, err := s.db.GetOrderByID(order.ID)
+			if err != nil {
+				logger.Warn("Failed to poll order status", zap.String("trade_no", order.ID), zap.Error(err))
+				continue
+			}
+			if current == nil {
+				// 订单已被清理
+				state = pb.OrderState_ORDER_STATE_EXPIRED
+			} else {
+				order, state = current, pb.OrderState(current.Status)
+			}
+		}
+
+		if state == lastState {
+			continue
+		}
+		if err := stream.Send(toStatusUpdate(order, state)); err != nil {
+			return err
+		}
+		lastState = state
+	}
+
+	return nil
+}
+
+// findOrder 按商户订单号查找当前商户的订单
+func (s *Server) findOrder(ctx context.Context, outTradeNo string) (*model.Order, error) {
+	if outTradeNo == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing required parameters")
+	}
+
+	order, err := s.db.GetOrderByOutTradeNo(outTradeNo, merchantFromContext(ctx))
+	if err != nil {
+		logger.Error("gRPC failed to query order", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Failed to query order")
+	}
+	if order == nil {
+		return nil, status.Error(codes.NotFound, "Order not found")
+	}
+
+	return order, nil
+}
+
+// watch 订阅订单状态变化
+func (s *Server) watch(orderID string) chan *model.Order {
+	ch := make(chan *model.Order, 1)
+
+	s.mu.Lock()
+	s.watchers[orderID] = append(s.watchers[orderID], ch)
+	s.mu.Unlock()
+
+	return ch
+}
+
+// unwatch 取消订阅
+func (s *Server) unwatch(orderID string, ch chan *model.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watchers := s.watchers[orderID]
+	for i, w := range watchers {
+		if w == ch {
+			watchers = append(watchers[:i], watchers[i+1:]...)
+			break
+		}
+	}
+
+	if len(watchers) == 0 {
+		delete(s.watchers, orderID)
+	} else {
+		s.watchers[orderID] = watchers
+	}
+}
+
+// notifyWatchers 通知订单的所有订阅者（不阻塞，订阅者未取走的旧消息被覆盖）
+func (s *Server) notifyWatchers(order *model.Order) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ch := range s.watchers[order.ID] {
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- order:
+		default:
+		}
+	}
+}
+
+// baseURL 生成支付链接使用的基础URL（未配置base_url时使用监听地址）
+func (s *Server) baseURL() string {
+	if s.cfg.Server.BaseURL != "" {
+		return strings.TrimSuffix(s.cfg.Server.BaseURL, "/")
+	}
+
+	scheme := "http"
+	if s.cfg.Server.TLSCertFile != "" && s.cfg.Server.TLSKeyFile != "" {
+		scheme = "https"
+	}
+	host := s.cfg.Server.Host
+	if host == "" || host == "0.0.0.0" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, s.cfg.Server.Port)
+}
+
+// toPBOrder 转换为protobuf订单
+func toPBOrder(order *model.Order) *pb.Order {
+	o := &pb.Order{
+		TradeNo:       order.ID,
+		OutTradeNo:    order.OutTradeNo,
+		Type:          order.Type,
+		Pid:           order.PID,
+		Name:          order.Name,
+		Money:         order.Price.String(),
+		PaymentAmount: order.PaymentAmount.Yuan(),
+		State:         pb.OrderState(order.Status),
+		AddTime:       utils.FormatTime(order.AddTime),
+	}
+	if order.PayTime != nil {
+		o.PayTime = utils.FormatTime(*order.PayTime)
+	}
+	return o
+}
+
+// toStatusUpdate 构建订单状态推送消息
+func toStatusUpdate(order *model.Order, state pb.OrderState) *pb.OrderStatusUpdate {
+	update := &pb.OrderStatusUpdate{
+		TradeNo:    order.ID,
+		OutTradeNo: order.OutTradeNo,
+		State:      state,
+		Timestamp:  time.Now().Unix(),
+	}
+	if order.PayTime != nil && state == pb.OrderState_ORDER_STATE_PAID {
+		update.PayTime = utils.FormatTime(*order.PayTime)
+	}
+	return update
+}
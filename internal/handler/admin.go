@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
+	"alimpay-go/internal/middleware"
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/service"
 	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/money"
+	"alimpay-go/internal/validator"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -15,17 +22,27 @@ import (
 
 // AdminHandler 管理操作处理器
 type AdminHandler struct {
-	db         *database.DB
-	codepay    *service.CodePayService
-	merchantID string
+	db           *database.DB
+	codepay      *service.CodePayService
+	cfg          *config.Config
+	merchantID   string
+	notifyHealth *service.NotifyHealthChecker
+	qrCodes      *service.QRCodeManager
+	monitor      *service.MonitorService
+	archiver     *service.OrderArchiver
+	housekeeping *service.Housekeeping
+	qrCodeHealth *service.QRCodeHealthChecker
+	merchantAuth *middleware.MerchantAuth
+	adminAuth    *middleware.AdminAuthMiddleware
 }
 
 // NewAdminHandler 创建管理处理器
-func NewAdminHandler(db *database.DB, codepay *service.CodePayService) *AdminHandler {
+func NewAdminHandler(db *database.DB, codepay *service.CodePayService, cfg *config.Config) *AdminHandler {
 	merchantInfo := codepay.GetMerchantInfo()
 	return &AdminHandler{
 		db:         db,
 		codepay:    codepay,
+		cfg:        cfg,
 		merchantID: merchantInfo["id"].(string),
 	}
 }
@@ -68,46 +85,70 @@ func (h *AdminHandler) HandleAdminAction(c *gin.Context) {
 		return
 	}
 
-	// 解析请求
-	var req struct {
-		Action     string `json:"action" binding:"required"`
-		TradeNo    string `json:"trade_no"`
-		OutTradeNo string `json:"out_trade_no"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Invalid request: " + err.Error(),
-		})
+	// 严格解析请求（拒绝未知字段和不支持的操作）
+	req, err := validator.ParseAdminActionRequest(c.Request.Body)
+	if err != nil {
+		respondValidationError(c, err)
 		return
 	}
 
 	// 执行操作
 	switch req.Action {
-	case "pay", "mark_paid":
+	case validator.AdminActionPay, validator.AdminActionMarkPaid:
 		h.markOrderPaid(c, merchantID.(string), req.TradeNo, req.OutTradeNo)
-	case "cancel":
+	case validator.AdminActionCancel:
 		h.cancelOrder(c, merchantID.(string), req.TradeNo)
-	case "refund":
+	case validator.AdminActionRefund:
 		h.refundOrder(c, merchantID.(string), req.TradeNo)
-	default:
+	}
+}
+
+// respondValidationError 返回请求校验错误，details 中列出各字段的错误
+func respondValidationError(c *gin.Context, err error) {
+	var verr *validator.ValidationError
+	if !errors.As(err, &verr) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Invalid action. Supported: pay, cancel, refund",
+			"error":   "Invalid request: " + err.Error(),
 		})
+		return
 	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"error":   verr.Error(),
+		"details": verr.Errors,
+	})
 }
 
 // HandleDashboard 渲染管理后台页面
 func (h *AdminHandler) HandleDashboard(c *gin.Context) {
-	c.HTML(http.StatusOK, "admin_dashboard.html", nil)
+	c.HTML(http.StatusOK, "admin_dashboard.html", gin.H{
+		"username": c.GetString("admin_username"),
+		"role":     c.GetString("admin_role"),
+	})
 }
 
+// 订单列表分页参数
+const (
+	defaultOrderPageSize = 20
+	maxOrderPageSize     = 100
+)
+
 // HandleGetOrders 获取订单列表（API）
+// 支持参数: status, start_date, end_date, min_amount, max_amount, keyword, page, page_size
 func (h *AdminHandler) HandleGetOrders(c *gin.Context) {
-	// 获取最近100个订单
-	orders, err := h.db.GetOrders(h.codepay.GetMerchantID(), 100)
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	filter.PID = h.codepay.GetMerchantID()
+
+	orders, total, err := h.db.SearchOrders(filter)
 	if err != nil {
 		logger.Error("Failed to get orders", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -118,7 +159,7 @@ func (h *AdminHandler) HandleGetOrders(c *gin.Context) {
 	}
 
 	// 转换为API格式
-	var orderList []map[string]interface{}
+	orderList := make([]map[string]interface{}, 0, len(orders))
 	for _, order := range orders {
 		orderList = append(orderList, map[string]interface{}{
 			"trade_no":       order.ID,
@@ -129,16 +170,101 @@ func (h *AdminHandler) HandleGetOrders(c *gin.Context) {
 			"status":         order.Status,
 			"add_time":       order.AddTime,
 			"pay_time":       order.PayTime,
+			"disputed":       order.Disputed,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code":   1,
-		"msg":    "success",
-		"orders": orderList,
+		"code":      1,
+		"msg":       "success",
+		"orders":    orderList,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
 	})
 }
 
+// parseOrderFilter 解析订单搜索参数
+func parseOrderFilter(c *gin.Context) (database.OrderFilter, error) {
+	filter := database.OrderFilter{
+		Keyword:  strings.TrimSpace(c.Query("keyword")),
+		Page:     1,
+		PageSize: defaultOrderPageSize,
+	}
+
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("Invalid status")
+		}
+		filter.Status = &status
+	}
+
+	if v := c.Query("start_date"); v != "" {
+		start, _, err := parseDateParam(v)
+		if err != nil {
+			return filter, errors.New("Invalid start_date")
+		}
+		filter.StartTime = &start
+	}
+	if v := c.Query("end_date"); v != "" {
+		end, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			return filter, errors.New("Invalid end_date")
+		}
+		// 仅日期时包含当天
+		if dateOnly {
+			end = end.AddDate(0, 0, 1)
+		}
+		filter.EndTime = &end
+	}
+
+	if v := c.Query("min_amount"); v != "" {
+		amount, err := money.Parse(v)
+		if err != nil || amount < 0 {
+			return filter, errors.New("Invalid min_amount")
+		}
+		filter.MinAmount = &amount
+	}
+	if v := c.Query("max_amount"); v != "" {
+		amount, err := money.Parse(v)
+		if err != nil || amount < 0 {
+			return filter, errors.New("Invalid max_amount")
+		}
+		filter.MaxAmount = &amount
+	}
+
+	if v := c.Query("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return filter, errors.New("Invalid page")
+		}
+		filter.Page = page
+	}
+	if v := c.Query("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 {
+			return filter, errors.New("Invalid page_size")
+		}
+		if size > maxOrderPageSize {
+			size = maxOrderPageSize
+		}
+		filter.PageSize = size
+	}
+
+	return filter, nil
+}
+
+// parseDateParam 解析日期参数，支持 2006-01-02 和 2006-01-02 15:04:05
+// 返回值dateOnly表示是否只包含日期
+func parseDateParam(v string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", v, time.Local)
+	return t, false, err
+}
+
 // handleMarkPaid 手动标记订单为已支付
 func (h *AdminHandler) handleMarkPaid(c *gin.Context) {
 	// 获取参数
@@ -147,12 +273,12 @@ func (h *AdminHandler) handleMarkPaid(c *gin.Context) {
 	tradeNo := c.Query("trade_no")
 	outTradeNo := c.Query("out_trade_no")
 
-	// 验证必需参数
-	if pid == "" || key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Missing required parameters: pid, key",
-		})
+	// 验证商户凭据
+	if err := h.codepay.VerifyMerchant(pid, key); err != nil {
+		logger.Warn("Invalid admin credentials",
+			zap.String("pid", pid),
+			zap.String("ip", c.ClientIP()))
+		respondAdminCredentialError(c, err)
 		return
 	}
 
@@ -164,19 +290,6 @@ func (h *AdminHandler) handleMarkPaid(c *gin.Context) {
 		return
 	}
 
-	// 验证商户密钥
-	merchantInfo := h.codepay.GetMerchantInfo()
-	if pid != merchantInfo["id"].(string) || key != merchantInfo["key"].(string) {
-		logger.Warn("Invalid admin credentials",
-			zap.String("pid", pid),
-			zap.String("ip", c.ClientIP()))
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"error":   "Invalid merchant credentials",
-		})
-		return
-	}
-
 	// 查询订单
 	var order *model.Order
 	var err error
@@ -219,8 +332,14 @@ func (h *AdminHandler) handleMarkPaid(c *gin.Context) {
 	}
 
 	// 更新订单状态为已支付
-	payTime := time.Now()
-	if err := h.db.UpdateOrderStatus(order.ID, model.OrderStatusPaid, payTime); err != nil {
+	if err := h.codepay.OrderStates().MarkPaid(order, model.OrderEventMarkedPaid, "管理后台标记已支付"); err != nil {
+		if errors.Is(err, service.ErrIllegalTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "Order cannot be marked as paid: " + err.Error(),
+			})
+			return
+		}
 		logger.Error("Failed to update order status", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -257,7 +376,7 @@ func (h *AdminHandler) handleMarkPaid(c *gin.Context) {
 			"trade_no":       order.ID,
 			"out_trade_no":   order.OutTradeNo,
 			"status":         "paid",
-			"pay_time":       payTime.Format("2006-01-02 15:04:05"),
+			"pay_time":       order.PayTime.Format("2006-01-02 15:04:05"),
 			"payment_amount": order.PaymentAmount,
 		},
 	}
@@ -280,21 +399,19 @@ func (h *AdminHandler) handleCancelOrder(c *gin.Context) {
 	key := c.Query("key")
 	tradeNo := c.Query("trade_no")
 
-	// 验证必需参数
-	if pid == "" || key == "" || tradeNo == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Missing required parameters: pid, key, trade_no",
-		})
+	// 验证商户凭据
+	if err := h.codepay.VerifyMerchant(pid, key); err != nil {
+		logger.Warn("Invalid admin credentials",
+			zap.String("pid", pid),
+			zap.String("ip", c.ClientIP()))
+		respondAdminCredentialError(c, err)
 		return
 	}
 
-	// 验证商户密钥
-	merchantInfo := h.codepay.GetMerchantInfo()
-	if pid != merchantInfo["id"].(string) || key != merchantInfo["key"].(string) {
-		c.JSON(http.StatusUnauthorized, gin.H{
+	if tradeNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Invalid merchant credentials",
+			"error":   "Missing required parameters: trade_no",
 		})
 		return
 	}
@@ -309,8 +426,15 @@ func (h *AdminHandler) handleCancelOrder(c *gin.Context) {
 		return
 	}
 
-	// 更新订单状态为已关闭
-	if err := h.db.UpdateOrderStatus(order.ID, model.OrderStatusClosed, time.Now()); err != nil {
+	// 更新订单状态为已关闭（仅待支付订单可取消）
+	if err := h.codepay.OrderStates().Close(order, "管理后台取消订单"); err != nil {
+		if errors.Is(err, service.ErrIllegalTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "Order cannot be cancelled: " + err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to cancel order: " + err.Error(),
@@ -335,11 +459,20 @@ func (h *AdminHandler) handleCancelOrder(c *gin.Context) {
 
 // handleRefundOrder 退款订单
 func (h *AdminHandler) handleRefundOrder(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"success": false,
-		"error":   "Refund function not implemented yet",
-		"message": "Please process refunds manually through Alipay",
-	})
+	// 获取参数
+	pid := c.Query("pid")
+	key := c.Query("key")
+
+	// 验证商户凭据
+	if err := h.codepay.VerifyMerchant(pid, key); err != nil {
+		logger.Warn("Invalid admin credentials",
+			zap.String("pid", pid),
+			zap.String("ip", c.ClientIP()))
+		respondAdminCredentialError(c, err)
+		return
+	}
+
+	h.refundOrder(c, pid, c.Query("trade_no"))
 }
 
 // markOrderPaid 标记订单为已支付（基于session，简化版）
@@ -392,8 +525,14 @@ func (h *AdminHandler) markOrderPaid(c *gin.Context, merchantID, tradeNo, outTra
 	}
 
 	// 更新订单状态为已支付
-	payTime := time.Now()
-	if err := h.db.UpdateOrderStatus(order.ID, model.OrderStatusPaid, payTime); err != nil {
+	if err := h.codepay.OrderStates().MarkPaid(order, model.OrderEventMarkedPaid, "管理后台标记已支付"); err != nil {
+		if errors.Is(err, service.ErrIllegalTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "Order cannot be marked as paid: " + err.Error(),
+			})
+			return
+		}
 		logger.Error("Failed to update order status", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -430,7 +569,7 @@ func (h *AdminHandler) markOrderPaid(c *gin.Context, merchantID, tradeNo, outTra
 			"trade_no":       order.ID,
 			"out_trade_no":   order.OutTradeNo,
 			"status":         "paid",
-			"pay_time":       payTime.Format("2006-01-02 15:04:05"),
+			"pay_time":       order.PayTime.Format("2006-01-02 15:04:05"),
 			"payment_amount": order.PaymentAmount,
 		},
 	}
@@ -466,8 +605,15 @@ func (h *AdminHandler) cancelOrder(c *gin.Context, merchantID, tradeNo string) {
 		return
 	}
 
-	// 更新订单状态为已关闭
-	if err := h.db.UpdateOrderStatus(order.ID, model.OrderStatusClosed, time.Now()); err != nil {
+	// 更新订单状态为已关闭（仅待支付订单可取消）
+	if err := h.codepay.OrderStates().Close(order, "管理后台取消订单"); err != nil {
+		if errors.Is(err, service.ErrIllegalTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "Order cannot be cancelled: " + err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to cancel order: " + err.Error(),
@@ -490,11 +636,53 @@ func (h *AdminHandler) cancelOrder(c *gin.Context, merchantID, tradeNo string) {
 	})
 }
 
-// refundOrder 退款订单（基于session，简化版）
+// refundOrder 将已支付订单标记为已退款（退款本身需在支付宝中手动处理）
 func (h *AdminHandler) refundOrder(c *gin.Context, merchantID, tradeNo string) {
+	if tradeNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameter: trade_no",
+		})
+		return
+	}
+
+	// 查询订单
+	order, err := h.db.GetOrderByID(tradeNo)
+	if err != nil || order == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Order not found",
+		})
+		return
+	}
+
+	// 更新订单状态为已退款（仅已支付订单可退款）
+	if err := h.codepay.OrderStates().Refund(order, "管理后台标记已退款"); err != nil {
+		if errors.Is(err, service.ErrIllegalTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   "Order cannot be refunded: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to refund order: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Order marked as refunded",
+		zap.String("trade_no", order.ID),
+		zap.String("operator_ip", c.ClientIP()))
+
 	c.JSON(http.StatusOK, gin.H{
-		"success": false,
-		"error":   "Refund function not implemented yet",
-		"message": "Please process refunds manually through Alipay",
+		"success": true,
+		"message": "Order marked as refunded, please process the refund manually through Alipay",
+		"order": gin.H{
+			"trade_no":     order.ID,
+			"out_trade_no": order.OutTradeNo,
+			"status":       "refunded",
+		},
 	})
 }
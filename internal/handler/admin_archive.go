@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SetOrderArchiver 设置订单归档任务（未设置时仍可查询归档表，但不能手动触发归档）
+func (h *AdminHandler) SetOrderArchiver(archiver *service.OrderArchiver) {
+	h.archiver = archiver
+}
+
+// HandleArchiveStatus 获取订单归档状态
+// GET /admin/archive
+func (h *AdminHandler) HandleArchiveStatus(c *gin.Context) {
+	count, err := h.db.CountArchivedOrders()
+	if err != nil {
+		logger.Error("Failed to count archived orders", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to count archived orders",
+		})
+		return
+	}
+
+	response := gin.H{
+		"success":        true,
+		"enabled":        h.archiver != nil,
+		"archived_total": count,
+	}
+	if h.archiver != nil {
+		response["archiver"] = h.archiver.Status()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleRunArchive 立即执行一次订单归档
+// POST /admin/archive/run
+func (h *AdminHandler) HandleRunArchive(c *gin.Context) {
+	if h.archiver == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Order archiving is not enabled",
+		})
+		return
+	}
+
+	start := time.Now()
+	archived, started, err := h.archiver.RunOnce()
+	if started {
+		service.RecordJobRun(h.db, service.JobArchive, model.JobTriggerManual, start, fmt.Sprintf("archived %d orders", archived), true, err)
+	}
+	if !started {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Archiving is already in progress",
+		})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to archive orders", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":  false,
+			"error":    "Failed to archive orders: " + err.Error(),
+			"archived": archived,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"archived": archived,
+	})
+}
+
+// HandleArchivedOrders 查询已归档订单（参数与订单列表相同）
+// GET /admin/archive/orders?trade_no=xxx 查询单个归档订单
+func (h *AdminHandler) HandleArchivedOrders(c *gin.Context) {
+	if tradeNo := c.Query("trade_no"); tradeNo != "" {
+		order, err := h.db.GetArchivedOrderByID(tradeNo)
+		if err != nil {
+			logger.Error("Failed to get archived order", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to get archived order",
+			})
+			return
+		}
+		if order == nil || order.PID != h.codepay.GetMerchantID() {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "Order not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"order":   order,
+		})
+		return
+	}
+
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	filter.PID = h.codepay.GetMerchantID()
+
+	orders, total, err := h.db.SearchArchivedOrders(filter)
+	if err != nil {
+		logger.Error("Failed to search archived orders", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to search archived orders",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"orders":    orders,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 崩溃报告查询条数
+const (
+	defaultCrashReportLimit = 20
+	maxCrashReportLimit     = 100
+)
+
+// HandleCrashReports 获取最近的崩溃报告（含堆栈）
+// GET /admin/crashes?limit=20
+func (h *AdminHandler) HandleCrashReports(c *gin.Context) {
+	limit := defaultCrashReportLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid limit",
+			})
+			return
+		}
+		limit = n
+	}
+	if limit > maxCrashReportLimit {
+		limit = maxCrashReportLimit
+	}
+
+	reports, err := h.db.GetRecentCrashReports(limit, true)
+	if err != nil {
+		logger.Error("Failed to get crash reports", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get crash reports",
+		})
+		return
+	}
+
+	lastDay, err := h.db.CountCrashReportsSince(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		logger.Error("Failed to count crash reports", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to count crash reports",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"last_24h": lastDay,
+		"crashes":  reports,
+	})
+}
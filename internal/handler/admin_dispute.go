@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleOrderDispute 标记或解除订单争议
+// POST /admin/orders/dispute {"trade_no": "...", "disputed": true, "reason": "..."}
+func (h *AdminHandler) HandleOrderDispute(c *gin.Context) {
+	var req struct {
+		TradeNo  string `json:"trade_no"`
+		Disputed bool   `json:"disputed"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TradeNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameter: trade_no",
+		})
+		return
+	}
+
+	order, changed, err := h.codepay.SetOrderDispute(req.TradeNo, req.Disputed, strings.TrimSpace(req.Reason))
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrDisputeOrderNotPaid):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrInvalidDispute):
+		default:
+			logger.Error("Failed to update order dispute", zap.String("trade_no", req.TradeNo), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to update order dispute",
+			})
+			return
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if changed {
+		logger.Info("Order dispute updated by admin",
+			zap.String("trade_no", order.ID),
+			zap.Bool("disputed", order.Disputed),
+			zap.String("ip", c.ClientIP()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"changed":  changed,
+		"trade_no": order.ID,
+		"disputed": order.Disputed,
+	})
+}
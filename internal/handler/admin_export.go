@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+)
+
+// 导出表头
+var orderExportHeader = []string{
+	"订单号", "商户订单号", "商户ID", "商品名称", "支付方式",
+	"订单金额", "实付金额", "状态", "创建时间", "支付时间",
+	"支付宝交易号", "争议",
+}
+
+// orderStatusText 订单状态显示文本
+var orderStatusText = map[int]string{
+	model.OrderStatusPending: "待支付",
+	model.OrderStatusPaid:    "已支付",
+	model.OrderStatusClosed:  "已关闭",
+	model.OrderStatusRefund:  "已退款",
+	model.OrderStatusExpired: "已过期",
+}
+
+// HandleExportOrders 导出订单（筛选参数与订单列表相同）
+// GET /admin/orders/export?format=csv|xlsx&status=&start_date=&end_date=...
+func (h *AdminHandler) HandleExportOrders(c *gin.Context) {
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	filter.PID = h.codepay.GetMerchantID()
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "Invalid format, supported: csv, xlsx",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("orders_%s.%s", time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+	c.Header("Cache-Control", "no-store")
+
+	if format == "xlsx" {
+		err = h.exportOrdersXLSX(c, filter)
+	} else {
+		err = h.exportOrdersCSV(c, filter)
+	}
+
+	// 响应已开始写入，只能记录日志
+	if err != nil {
+		logger.Error("Failed to export orders",
+			zap.String("format", format),
+			zap.Error(err))
+	}
+}
+
+// exportOrdersCSV 流式输出CSV（UTF-8 BOM，Excel直接打开中文不乱码）
+func (h *AdminHandler) exportOrdersCSV(c *gin.Context, filter database.OrderFilter) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	if _, err := c.Writer.WriteString("\ufeff"); err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(orderExportHeader); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.ExportOrders(filter, func(order *model.Order) error {
+		count++
+		if err := w.Write(csvSafeRow(orderExportRow(order))); err != nil {
+			return err
+		}
+		// 分批刷新，避免大量数据积压在缓冲区
+		if count%500 == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		return w.Error()
+	})
+
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// exportOrdersXLSX 使用流式写入生成XLSX
+func (h *AdminHandler) exportOrdersXLSX(c *gin.Context, filter database.OrderFilter) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	if err := sw.SetRow("A1", toCells(orderExportHeader)); err != nil {
+		return err
+	}
+
+	row := 1
+	err = h.db.ExportOrders(filter, func(order *model.Order) error {
+		row++
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		values := orderExportRow(order)
+		cells := toCells(values)
+		// 金额列写入数值，便于表格中直接求和
+		cells[5] = order.Price.Yuan()
+		cells[6] = order.PaymentAmount.Yuan()
+		return sw.SetRow(cell, cells)
+	})
+	if err != nil {
+		c.Header("Content-Disposition", "")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "Failed to export orders",
+		})
+		return err
+	}
+
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Status(http.StatusOK)
+	return f.Write(c.Writer)
+}
+
+// orderExportRow 构建导出行
+func orderExportRow(order *model.Order) []string {
+	payTime := ""
+	if order.PayTime != nil {
+		payTime = utils.FormatTime(*order.PayTime)
+	}
+
+	status, ok := orderStatusText[order.Status]
+	if !ok {
+		status = fmt.Sprintf("%d", order.Status)
+	}
+
+	disputed := ""
+	if order.Disputed {
+		disputed = "是"
+	}
+
+	return []string{
+		order.ID,
+		order.OutTradeNo,
+		order.PID,
+		order.Name,
+		order.Type,
+		order.Price.String(),
+		order.PaymentAmount.String(),
+		status,
+		utils.FormatTime(order.AddTime),
+		payTime,
+		order.AlipayTradeNo,
+		disputed,
+	}
+}
+
+// csvSafeRow 防止CSV公式注入（内容以 = + - @ 开头时加单引号前缀）
+// XLSX单元格按字符串类型写入，不需要处理
+func csvSafeRow(values []string) []string {
+	for i, v := range values {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			values[i] = "'" + v
+		}
+	}
+	return values
+}
+
+// toCells 转换为XLSX行数据
+func toCells(values []string) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 任务执行记录查询条数
+const (
+	defaultJobRunLimit = 50
+	maxJobRunLimit     = 500
+)
+
+// SetHousekeeping 设置维护任务（未设置时不能手动触发维护任务）
+func (h *AdminHandler) SetHousekeeping(housekeeping *service.Housekeeping) {
+	h.housekeeping = housekeeping
+}
+
+// HandleJobRuns 查询后台任务执行记录（维护任务、订单归档、运营日报）
+// GET /admin/jobs?name=backup&status=failed&limit=50
+func (h *AdminHandler) HandleJobRuns(c *gin.Context) {
+	limit := defaultJobRunLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid limit",
+			})
+			return
+		}
+		limit = n
+	}
+	if limit > maxJobRunLimit {
+		limit = maxJobRunLimit
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", model.JobStatusRunning, model.JobStatusSuccess, model.JobStatusFailed, model.JobStatusSkipped:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid status",
+		})
+		return
+	}
+
+	runs, err := h.db.GetJobRuns(c.Query("name"), status, limit)
+	if err != nil {
+		logger.Error("Failed to get job runs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get job runs",
+		})
+		return
+	}
+
+	latest, err := h.db.GetLatestJobRuns()
+	if err != nil {
+		logger.Error("Failed to get latest job runs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get job runs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"latest":  latest,
+		"runs":    runs,
+	})
+}
+
+// HandleRunJob 在后台执行一次维护任务，返回执行记录ID（按ID查询执行状态）
+// POST /admin/jobs/run {"job": "backup"}
+func (h *AdminHandler) HandleRunJob(c *gin.Context) {
+	if h.housekeeping == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Housekeeping jobs are not available",
+		})
+		return
+	}
+
+	var req struct {
+		Job string `json:"job" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	run, err := h.housekeeping.Trigger(req.Job)
+	switch {
+	case errors.Is(err, service.ErrUnknownJob):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Unknown job",
+			"jobs":    h.housekeeping.Jobs(),
+		})
+		return
+	case errors.Is(err, service.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Job is already running",
+		})
+		return
+	case err != nil:
+		logger.Error("Failed to trigger job", zap.String("job", req.Job), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to trigger job",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"run":     run,
+	})
+}
+
+// HandleJobRun 查询单条任务执行记录（手动触发后轮询执行状态）
+// GET /admin/jobs/:id
+func (h *AdminHandler) HandleJobRun(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid id",
+		})
+		return
+	}
+
+	run, err := h.db.GetJobRun(id)
+	if err != nil {
+		logger.Error("Failed to get job run", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get job run",
+		})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Job run not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"run":     run,
+	})
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SetAdminAuth 设置管理员认证中间件（轮换商户密钥后同步更新管理后台登录）
+func (h *AdminHandler) SetAdminAuth(auth *middleware.AdminAuthMiddleware) {
+	h.adminAuth = auth
+}
+
+// HandleGetMerchantKey 查询商户密钥轮换状态 GET /admin/merchant/key
+// 不返回密钥本身，新密钥只在轮换时返回一次
+func (h *AdminHandler) HandleGetMerchantKey(c *gin.Context) {
+	previousKey, expiresAt := h.codepay.PreviousMerchantKey()
+
+	response := gin.H{
+		"success":             true,
+		"pid":                 h.merchantID,
+		"previous_key_active": previousKey != "",
+	}
+	if previousKey != "" {
+		response["previous_key_expires_at"] = expiresAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleRotateMerchantKey 轮换商户密钥 POST /admin/merchant/rotate-key {"grace": 86400}
+// grace 为旧密钥的过渡期（秒，默认24小时，0表示旧密钥立即失效）；
+// 轮换后管理后台只能使用新密钥登录，其他使用商户密钥登录的会话被注销
+func (h *AdminHandler) HandleRotateMerchantKey(c *gin.Context) {
+	var req struct {
+		Grace *int64 `json:"grace"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+		return
+	}
+
+	grace := service.DefaultKeyRotationGrace
+	if req.Grace != nil {
+		grace = time.Duration(*req.Grace) * time.Second
+	}
+
+	rotation, err := h.codepay.RotateMerchantKey(grace)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidKeyRotationGrace) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		logger.Error("Failed to rotate merchant key", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to rotate merchant key",
+		})
+		return
+	}
+
+	previousKey, expiresAt := h.codepay.PreviousMerchantKey()
+	if h.merchantAuth != nil {
+		h.merchantAuth.SetKey(h.merchantID, rotation.Key, previousKey, expiresAt)
+	}
+	revoked := 0
+	if h.adminAuth != nil {
+		revoked = h.adminAuth.SetMerchantKey(rotation.Key, c.GetString("admin_session_id"))
+	}
+
+	logger.Info("Merchant key rotated by admin",
+		zap.String("merchant_id", h.merchantID),
+		zap.Duration("grace", grace),
+		zap.Int("revoked_sessions", revoked),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"pid":              h.merchantID,
+		"rotation":         rotation,
+		"revoked_sessions": revoked,
+	})
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetMonitorService 设置监听服务
+func (h *AdminHandler) SetMonitorService(monitor *service.MonitorService) {
+	h.monitor = monitor
+}
+
+// HandleWorkerPool 获取订单监听和商户通知Worker池状态
+// GET /admin/monitor/pool
+func (h *AdminHandler) HandleWorkerPool(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"pool":        h.monitor.GetWorkerPoolStats(),
+		"notify_pool": h.codepay.GetNotifyPoolStats(),
+	})
+}
+
+// HandleResizeWorkerPool 运行时调整Worker数量和队列大小（重启或配置文件修改后以配置文件为准）
+// POST /admin/monitor/pool {"worker_count": 10, "queue_size": 500, "notify_worker_count": 20, "notify_queue_size": 1000}
+func (h *AdminHandler) HandleResizeWorkerPool(c *gin.Context) {
+	var req struct {
+		WorkerCount       int `json:"worker_count"`
+		QueueSize         int `json:"queue_size"`
+		NotifyWorkerCount int `json:"notify_worker_count"`
+		NotifyQueueSize   int `json:"notify_queue_size"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	// 未提供的参数保持不变
+	current := h.cfg.MonitorSnapshot()
+	if req.WorkerCount == 0 {
+		req.WorkerCount = current.WorkerCount
+	}
+	if req.QueueSize == 0 {
+		req.QueueSize = current.QueueSize
+	}
+	if req.NotifyWorkerCount == 0 {
+		req.NotifyWorkerCount = current.NotifyWorkerCount
+	}
+	if req.NotifyQueueSize == 0 {
+		req.NotifyQueueSize = current.NotifyQueueSize
+	}
+
+	if req.WorkerCount != current.WorkerCount || req.QueueSize != current.QueueSize {
+		if err := h.monitor.ResizeWorkerPool(req.WorkerCount, req.QueueSize); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+	if req.NotifyWorkerCount != current.NotifyWorkerCount || req.NotifyQueueSize != current.NotifyQueueSize {
+		if err := h.codepay.ResizeNotifyPool(req.NotifyWorkerCount, req.NotifyQueueSize); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "notify pool: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"pool":        h.monitor.GetWorkerPoolStats(),
+		"notify_pool": h.codepay.GetNotifyPoolStats(),
+	})
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 通知概览默认统计窗口
+const (
+	defaultNotifyWindowHours = 24
+	maxNotifyWindowHours     = 24 * 30
+)
+
+// HandleNotifySummary 按商户汇总通知成功/失败/待通知情况
+// GET /admin/notifications?hours=24
+func (h *AdminHandler) HandleNotifySummary(c *gin.Context) {
+	since := notifyWindowStart(c)
+
+	summaries, err := h.db.GetNotifySummary(since)
+	if err != nil {
+		logger.Error("Failed to get notify summary", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get notification summary",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"since":     since.Format("2006-01-02 15:04:05"),
+		"merchants": summaries,
+	})
+}
+
+// HandleNotifyLogs 查询通知明细（按商户、状态下钻）
+// GET /admin/notifications/logs?pid=&status=&hours=24&limit=100
+func (h *AdminHandler) HandleNotifyLogs(c *gin.Context) {
+	since := notifyWindowStart(c)
+	pid := c.Query("pid")
+
+	var status *int
+	if statusStr := c.Query("status"); statusStr != "" {
+		s, err := strconv.Atoi(statusStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid status parameter",
+			})
+			return
+		}
+		status = &s
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	logs, err := h.db.GetNotifyLogs(pid, status, since, limit)
+	if err != nil {
+		logger.Error("Failed to get notify logs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get notification logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"logs":    logs,
+	})
+}
+
+// SetNotifyHealthChecker 设置通知地址健康检查服务（未设置时健康接口返回空列表）
+func (h *AdminHandler) SetNotifyHealthChecker(checker *service.NotifyHealthChecker) {
+	h.notifyHealth = checker
+}
+
+// HandleNotifyHealth 获取商户通知地址健康状态
+// GET /admin/notifications/health
+func (h *AdminHandler) HandleNotifyHealth(c *gin.Context) {
+	if h.notifyHealth == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"enabled":   false,
+			"endpoints": []interface{}{},
+		})
+		return
+	}
+
+	endpoints := h.notifyHealth.Results()
+	unhealthy := 0
+	for _, e := range endpoints {
+		if !e.Healthy {
+			unhealthy++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"enabled":   true,
+		"unhealthy": unhealthy,
+		"endpoints": endpoints,
+	})
+}
+
+// notifyWindowStart 解析统计窗口（hours参数，默认24小时）
+func notifyWindowStart(c *gin.Context) time.Time {
+	hours, err := strconv.Atoi(c.Query("hours"))
+	if err != nil || hours <= 0 {
+		hours = defaultNotifyWindowHours
+	}
+	if hours > maxNotifyWindowHours {
+		hours = maxNotifyWindowHours
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour)
+}
+
+// HandleReplayNotify 将历史通知的原始参数重新发送到指定地址
+// POST /admin/notifications/replay {"id": 1, "url": "https://staging.example.com/notify"}
+func (h *AdminHandler) HandleReplayNotify(c *gin.Context) {
+	var req struct {
+		ID  int64  `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing notification id",
+		})
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL != "" {
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid url: must be an absolute http(s) URL",
+			})
+			return
+		}
+	}
+
+	original, err := h.db.GetNotifyLogByID(req.ID)
+	if err != nil {
+		logger.Error("Failed to get notify log", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get notification",
+		})
+		return
+	}
+	if original == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Notification not found",
+		})
+		return
+	}
+
+	result, err := h.codepay.ReplayNotification(original, req.URL)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, service.ErrOrderDisputed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Notification replayed by admin",
+		zap.Int64("notify_log_id", original.ID),
+		zap.String("target_url", result.NotifyURL),
+		zap.Int("http_status", result.HTTPStatus),
+		zap.String("ip", c.ClientIP()))
+
+	// success表示重放已执行，是否被商户确认见result.status
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
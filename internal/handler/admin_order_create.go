@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleCreateOrder 管理后台手动创建订单（线下收款）
+// POST /admin/orders/create {"name": "线下商品", "money": "10.00", "notify_url": ""}
+func (h *AdminHandler) HandleCreateOrder(c *gin.Context) {
+	var req struct {
+		Name      string `json:"name"`
+		Money     string `json:"money"`
+		NotifyURL string `json:"notify_url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Money = strings.TrimSpace(req.Money)
+	req.NotifyURL = strings.TrimSpace(req.NotifyURL)
+
+	if req.Name == "" || req.Money == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameters: name, money",
+		})
+		return
+	}
+
+	if req.NotifyURL != "" {
+		u, err := url.Parse(req.NotifyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid notify_url: must be an absolute http(s) URL",
+			})
+			return
+		}
+	}
+
+	result, err := h.codepay.CreateManualPayment(req.Name, req.Money, req.NotifyURL, utils.GetBaseURL(c, h.cfg.Server.BaseURL))
+	if err != nil {
+		logger.Warn("Failed to create manual order", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Manual order created by admin",
+		zap.Any("trade_no", result["trade_no"]),
+		zap.String("money", req.Money),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"order": gin.H{
+			"trade_no":       result["trade_no"],
+			"out_trade_no":   result["out_trade_no"],
+			"money":          result["money"],
+			"payment_amount": result["payment_amount"],
+			"payment_url":    result["payment_url"],
+			"qr_code":        result["qr_code"],
+		},
+	})
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleOrderDetail 渲染订单详情页面（时间线由前端调用 /admin/orders/timeline 加载）
+func (h *AdminHandler) HandleOrderDetail(c *gin.Context) {
+	c.HTML(http.StatusOK, "admin_order.html", nil)
+}
+
+// HandleOrderTimeline 获取订单生命周期时间线
+// GET /admin/orders/timeline?trade_no=xxx
+func (h *AdminHandler) HandleOrderTimeline(c *gin.Context) {
+	tradeNo := c.Query("trade_no")
+	if tradeNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameter: trade_no",
+		})
+		return
+	}
+
+	order, err := h.db.GetOrderByID(tradeNo)
+	if err != nil || order == nil || order.PID != h.codepay.GetMerchantID() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Order not found",
+		})
+		return
+	}
+
+	timeline, err := h.buildOrderTimeline(order)
+	if err != nil {
+		logger.Error("Failed to build order timeline",
+			zap.String("trade_no", tradeNo),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get order timeline",
+		})
+		return
+	}
+
+	detail := gin.H{
+		"trade_no":       order.ID,
+		"out_trade_no":   order.OutTradeNo,
+		"name":           order.Name,
+		"price":          order.Price,
+		"payment_amount": order.PaymentAmount,
+		"status":         order.Status,
+		"add_time":       order.AddTime,
+		"pay_time":       order.PayTime,
+		"notify_url":     order.NotifyURL,
+		"return_url":     order.ReturnURL,
+		"qr_code_id":     order.QRCodeID,
+	}
+
+	// 买家IP及归属地（下单时记录，旧订单和未提供买家IP的订单没有）
+	if client, err := h.db.GetOrderClient(order.ID); err != nil {
+		logger.Warn("Failed to get order client ip", zap.String("trade_no", tradeNo), zap.Error(err))
+	} else if client != nil {
+		detail["client_ip"] = client.IP
+		detail["ip_country"] = client.Country
+		detail["ip_province"] = client.Province
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"order":      detail,
+		"timeline":   timeline,
+		"next_stage": h.nextOrderStage(order, timeline),
+	})
+}
+
+// buildOrderTimeline 汇总订单事件、通知记录和审计日志，按时间排序
+func (h *AdminHandler) buildOrderTimeline(order *model.Order) ([]*model.OrderTimelineEntry, error) {
+	orderEvents, err := h.db.GetOrderEvents(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	notifyLogs, err := h.db.GetNotifyLogsByOrder(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	auditLogs, err := h.db.GetAuditLogsForOrder(order.ID, order.OutTradeNo)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := make([]*model.OrderTimelineEntry, 0, len(orderEvents)+len(notifyLogs)+len(auditLogs)+1)
+	hasEvent := func(event string) bool {
+		for _, e := range orderEvents {
+			if e.Event == event {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 早于事件记录功能的订单，根据订单字段补齐关键节点
+	if !hasEvent(model.OrderEventCreated) {
+		timeline = append(timeline, &model.OrderTimelineEntry{
+			Time:   order.AddTime,
+			Stage:  model.OrderEventCreated,
+			Source: "order",
+		})
+	}
+	if order.PayTime != nil && order.Status == model.OrderStatusPaid &&
+		!hasEvent(model.OrderEventBillMatched) && !hasEvent(model.OrderEventMarkedPaid) && !hasEvent(model.OrderEventTradePaid) {
+		timeline = append(timeline, &model.OrderTimelineEntry{
+			Time:   *order.PayTime,
+			Stage:  model.OrderEventPaid,
+			Source: "order",
+		})
+	}
+
+	for _, e := range orderEvents {
+		timeline = append(timeline, &model.OrderTimelineEntry{
+			Time:   e.CreatedAt,
+			Stage:  e.Event,
+			Source: "event",
+			Detail: e.Detail,
+		})
+	}
+
+	for _, log := range notifyLogs {
+		success := log.Status == model.NotifyStatusSuccess
+		detail := fmt.Sprintf("%s HTTP %d", log.NotifyURL, log.HTTPStatus)
+		if log.Error != "" {
+			detail += ": " + log.Error
+		}
+		timeline = append(timeline, &model.OrderTimelineEntry{
+			Time:    log.CreatedAt,
+			Stage:   model.OrderEventNotified,
+			Source:  "notify",
+			Detail:  detail,
+			Success: &success,
+		})
+	}
+
+	for _, log := range auditLogs {
+		success := log.Status < http.StatusBadRequest
+		timeline = append(timeline, &model.OrderTimelineEntry{
+			Time:    log.CreatedAt,
+			Stage:   model.OrderEventAdminAction,
+			Source:  "audit",
+			Detail:  fmt.Sprintf("%s (%s, HTTP %d)", log.Action, log.Actor, log.Status),
+			Success: &success,
+		})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time.Before(timeline[j].Time)
+	})
+
+	return timeline, nil
+}
+
+// nextOrderStage 推断订单当前停留在哪个阶段（返回下一个尚未到达的阶段，流程结束时为空）
+func (h *AdminHandler) nextOrderStage(order *model.Order, timeline []*model.OrderTimelineEntry) string {
+	reached := func(stage string, requireSuccess bool) bool {
+		for _, entry := range timeline {
+			if entry.Stage == stage && (!requireSuccess || entry.Success == nil || *entry.Success) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch order.Status {
+	case model.OrderStatusPending:
+		// 当面付、手机网站支付订单由支付宝通知或交易查询确认
+		if order.IsAlipayTrade() {
+			return model.OrderEventTradePaid
+		}
+		// 沙箱订单需在支付页面模拟支付
+		if order.IsSandbox() && !reached(model.OrderEventSandboxPaid, false) {
+			return model.OrderEventSandboxPaid
+		}
+		// 经营码模式下用户需先打开支付页面
+		if h.cfg.Payment.BusinessQRMode.Enabled && !reached(model.OrderEventPageViewed, false) {
+			return model.OrderEventPageViewed
+		}
+		return model.OrderEventBillMatched
+	case model.OrderStatusPaid:
+		if order.NotifyURL != "" && !reached(model.OrderEventNotified, true) {
+			return model.OrderEventNotified
+		}
+		if order.ReturnURL != "" && !reached(model.OrderEventReturned, false) {
+			return model.OrderEventReturned
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleCreatePrintedCodes 批量生成线下打印收款码
+// POST /admin/qrcodes/print {"name": "活动门票", "money": "20.00", "count": 100}
+// 每个收款码为固定金额的短链接，顾客首次扫码时创建订单；生成后通过 download_url 下载ZIP
+func (h *AdminHandler) HandleCreatePrintedCodes(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name"`
+		Money string `json:"money"`
+		Count int    `json:"count"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Money = strings.TrimSpace(req.Money)
+
+	if req.Name == "" || req.Money == "" || req.Count <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameters: name, money, count",
+		})
+		return
+	}
+
+	batch, err := h.codepay.CreatePrintedCodes(req.Name, req.Money, req.Count)
+	if err != nil {
+		logger.Warn("Failed to create printed QR codes", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Printed QR codes created by admin",
+		zap.String("batch_id", batch.ID),
+		zap.Int("count", len(batch.Codes)),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"batch": gin.H{
+			"id":    batch.ID,
+			"name":  batch.Name,
+			"money": batch.Price.String(),
+			"count": len(batch.Codes),
+		},
+		"download_url": "/admin/qrcodes/print/download?batch=" + url.QueryEscape(batch.ID),
+	})
+}
+
+// HandleDownloadPrintedCodes 下载收款码批次的ZIP压缩包（PNG二维码 + codes.csv）
+// GET /admin/qrcodes/print/download?batch=...
+func (h *AdminHandler) HandleDownloadPrintedCodes(c *gin.Context) {
+	batchID := c.Query("batch")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameter: batch",
+		})
+		return
+	}
+
+	batch, err := h.codepay.GetPrintedCodeBatch(batchID)
+	if err != nil {
+		logger.Error("Failed to get printed QR code batch", zap.String("batch_id", batchID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get batch",
+		})
+		return
+	}
+	if batch == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Batch not found",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("qrcodes_%s_%s.zip", batch.ID, batch.Price)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// 响应已开始写入，只能记录日志
+	if err := h.codepay.WritePrintedCodeArchive(c.Writer, batch, utils.GetBaseURL(c, h.cfg.Server.BaseURL)); err != nil {
+		logger.Error("Failed to write printed QR code archive",
+			zap.String("batch_id", batch.ID),
+			zap.Error(err))
+	}
+}
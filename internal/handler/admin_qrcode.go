@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SetQRCodeManager 设置收款码管理服务
+func (h *AdminHandler) SetQRCodeManager(manager *service.QRCodeManager) {
+	h.qrCodes = manager
+}
+
+// HandleListQRCodes 获取全部收款码（含未启用的）
+// GET /admin/qrcodes
+func (h *AdminHandler) HandleListQRCodes(c *gin.Context) {
+	usage := h.codepay.GetQRCodeSelector().GetUsageCounts()
+	today, err := h.codepay.GetQRCodeSelector().TodayUsage()
+	if err != nil {
+		logger.Error("Failed to get QR code daily usage", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get QR code daily usage",
+		})
+		return
+	}
+
+	qrCodes := h.qrCodes.List()
+	list := make([]gin.H, 0, len(qrCodes))
+	for _, qr := range qrCodes {
+		_, statErr := os.Stat(qr.Path)
+		todayUsage := today[qr.ID]
+		if todayUsage == nil {
+			todayUsage = &database.QRCodeUsage{}
+		}
+		list = append(list, gin.H{
+			"id":              qr.ID,
+			"code_id":         qr.CodeID,
+			"path":            qr.Path,
+			"enabled":         qr.Enabled,
+			"priority":        qr.Priority,
+			"independent_api": qr.HasIndependentAPI(),
+			"image_exists":    statErr == nil,
+			"usage_count":     usage[qr.ID],
+			// 每日限额（0 表示不限制）和当天已分配的待支付、已支付订单
+			"max_daily_amount": qr.MaxDailyAmount,
+			"max_daily_count":  qr.MaxDailyCount,
+			"today":            todayUsage,
+			"healthy":          h.qrCodeHealth.IsHealthy(qr.ID),
+			// 金额区间（0 表示不限制）
+			"min_amount": qr.MinAmount,
+			"max_amount": qr.MaxAmount,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"business_qr_mode": h.cfg.Payment.BusinessQRMode.Enabled,
+		"polling_mode":     h.cfg.Payment.BusinessQRMode.PollingMode,
+		"qrcodes":          list,
+	})
+}
+
+// HandleQRCodeImage 预览收款码图片
+// GET /admin/qrcodes/image?id=xxx
+func (h *AdminHandler) HandleQRCodeImage(c *gin.Context) {
+	qr, found := h.qrCodes.Get(c.Query("id"))
+	if !found {
+		c.String(http.StatusNotFound, "QR code not found")
+		return
+	}
+
+	data, err := os.ReadFile(qr.Path)
+	if err != nil {
+		c.String(http.StatusNotFound, "QR code image not found")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, http.DetectContentType(data), data)
+}
+
+// HandleUploadQRCode 上传收款码图片（新建或替换已有二维码的图片）
+// POST /admin/qrcodes/upload (multipart: id, code_id, priority, enabled, file)
+func (h *AdminHandler) HandleUploadQRCode(c *gin.Context) {
+	// 预留表单字段的空间
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxQRCodeImageSize+64<<10)
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing or oversized QR code image (field: file)",
+		})
+		return
+	}
+	defer file.Close()
+
+	image, err := io.ReadAll(io.LimitReader(file, service.MaxQRCodeImageSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Failed to read QR code image",
+		})
+		return
+	}
+
+	priority := 0
+	if v := strings.TrimSpace(c.PostForm("priority")); v != "" {
+		if priority, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid priority",
+			})
+			return
+		}
+	}
+
+	enabled := true
+	if v := strings.TrimSpace(c.PostForm("enabled")); v != "" {
+		if enabled, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid enabled",
+			})
+			return
+		}
+	}
+
+	qr, err := h.qrCodes.Upload(strings.TrimSpace(c.PostForm("id")), strings.TrimSpace(c.PostForm("code_id")),
+		priority, enabled, image)
+	if err != nil {
+		h.respondQRCodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"qrcode": gin.H{
+			"id":       qr.ID,
+			"code_id":  qr.CodeID,
+			"path":     qr.Path,
+			"enabled":  qr.Enabled,
+			"priority": qr.Priority,
+		},
+	})
+}
+
+// HandleUpdateQRCode 启用/禁用收款码或调整优先级
+// POST /admin/qrcodes/update {"id": "main", "enabled": false, "priority": 2}
+func (h *AdminHandler) HandleUpdateQRCode(c *gin.Context) {
+	var req struct {
+		ID       string `json:"id"`
+		Enabled  *bool  `json:"enabled"`
+		Priority *int   `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request: id is required",
+		})
+		return
+	}
+	if req.Enabled == nil && req.Priority == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Nothing to update: provide enabled and/or priority",
+		})
+		return
+	}
+
+	qr, err := h.qrCodes.Update(req.ID, req.Enabled, req.Priority)
+	if err != nil {
+		h.respondQRCodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"qrcode": gin.H{
+			"id":       qr.ID,
+			"code_id":  qr.CodeID,
+			"path":     qr.Path,
+			"enabled":  qr.Enabled,
+			"priority": qr.Priority,
+		},
+	})
+}
+
+// SetQRCodeHealthChecker 设置收款码健康检查（未设置时健康接口返回空列表）
+func (h *AdminHandler) SetQRCodeHealthChecker(checker *service.QRCodeHealthChecker) {
+	h.qrCodeHealth = checker
+}
+
+// HandleQRCodeHealth 获取启用的收款码的健康状态
+// GET /admin/qrcodes/health
+func (h *AdminHandler) HandleQRCodeHealth(c *gin.Context) {
+	if h.qrCodeHealth == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"enabled": false,
+			"qrcodes": []interface{}{},
+		})
+		return
+	}
+
+	statuses := h.qrCodeHealth.Statuses()
+	unhealthy := 0
+	for _, status := range statuses {
+		if !status.Healthy {
+			unhealthy++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"enabled":   true,
+		"unhealthy": unhealthy,
+		"qrcodes":   statuses,
+	})
+}
+
+// HandleResetQRCodeHealth 手动恢复不健康的收款码（重新分配给新订单）
+// POST /admin/qrcodes/health/reset {"id": "main"}
+func (h *AdminHandler) HandleResetQRCodeHealth(c *gin.Context) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request: id is required",
+		})
+		return
+	}
+	if h.qrCodeHealth == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "QR code health check is not enabled",
+		})
+		return
+	}
+
+	if err := h.qrCodeHealth.Reset(req.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      req.ID,
+	})
+}
+
+// respondQRCodeError 将收款码管理错误转换为HTTP响应
+func (h *AdminHandler) respondQRCodeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrQRCodeNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidQRCodeID), errors.Is(err, service.ErrInvalidQRCodeImage):
+		status = http.StatusBadRequest
+	default:
+		logger.Error("Failed to save QR code", zap.Error(err))
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 退款申请列表默认和最大条数
+const (
+	defaultRefundRequestLimit = 100
+	maxRefundRequestLimit     = 500
+)
+
+// HandleListRefundRequests 查询退款申请
+// GET /admin/refunds?status=pending&limit=100（status 为空时返回全部状态）
+func (h *AdminHandler) HandleListRefundRequests(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", model.RefundStatusPending, model.RefundStatusApproved, model.RefundStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid status parameter",
+		})
+		return
+	}
+
+	limit := defaultRefundRequestLimit
+	if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 {
+		limit = min(n, maxRefundRequestLimit)
+	}
+
+	requests, err := h.db.ListRefundRequests(status, limit)
+	if err != nil {
+		logger.Error("Failed to list refund requests", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to list refund requests",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"requests": requests,
+	})
+}
+
+// HandleProcessRefundRequest 审核退款申请
+// POST /admin/refunds/review {"refund_no": "...", "approve": true, "note": "..."}
+// 同意后需在支付宝中手动退款；同意的金额累计达到订单金额时订单标记为已退款
+func (h *AdminHandler) HandleProcessRefundRequest(c *gin.Context) {
+	var req struct {
+		RefundNo string `json:"refund_no"`
+		Approve  bool   `json:"approve"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefundNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing required parameter: refund_no",
+		})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	var request *model.RefundRequest
+	var err error
+	if req.Approve {
+		request, err = h.codepay.ApproveRefundRequest(req.RefundNo, req.Note)
+	} else {
+		request, err = h.codepay.RejectRefundRequest(req.RefundNo, req.Note)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrRefundRequestNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrRefundRequestProcessed):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrInvalidRefundRequest):
+		default:
+			logger.Error("Failed to process refund request", zap.String("refund_no", req.RefundNo), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to process refund request",
+			})
+			return
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Refund request reviewed by admin",
+		zap.String("refund_no", request.RefundNo),
+		zap.String("trade_no", request.OrderID),
+		zap.String("status", request.Status),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"request": request,
+	})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SetMerchantAuth 设置商户认证器（签发沙箱凭据、轮换商户密钥后同步更新公开API的认证）
+func (h *AdminHandler) SetMerchantAuth(auth *middleware.MerchantAuth) {
+	h.merchantAuth = auth
+}
+
+// HandleGetSandbox 查询沙箱凭据 GET /admin/sandbox
+func (h *AdminHandler) HandleGetSandbox(c *gin.Context) {
+	pid, key := h.codepay.SandboxCredentials()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"issued":  key != "",
+		"pid":     pid,
+		"key":     key,
+	})
+}
+
+// HandleIssueSandbox 签发沙箱凭据 POST /admin/sandbox/issue
+// 首次签发生成沙箱商户ID和密钥，再次签发只更换密钥，旧密钥立即失效
+func (h *AdminHandler) HandleIssueSandbox(c *gin.Context) {
+	pid, key, err := h.codepay.IssueSandboxCredentials()
+	if err != nil {
+		logger.Error("Failed to issue sandbox credentials", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to issue sandbox credentials",
+		})
+		return
+	}
+
+	// 沙箱商户只允许签名和密钥参数认证（签名类型与正式商户相同）
+	if h.merchantAuth != nil {
+		h.merchantAuth.SetCredential(middleware.MerchantCredential{ID: pid, Key: key, SignType: h.codepay.SignType()})
+	}
+
+	logger.Info("Sandbox credentials issued by admin",
+		zap.String("sandbox_merchant_id", pid),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"issued":  true,
+		"pid":     pid,
+		"key":     key,
+	})
+}
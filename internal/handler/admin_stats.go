@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// statsPeriods 统计周期（按自然日，含今天）
+var statsPeriods = []struct {
+	Name string
+	Days int
+}{
+	{"today", 1},
+	{"7d", 7},
+	{"30d", 30},
+}
+
+// HandleStats 订单统计（今日/近7天/近30天）
+// GET /admin/stats
+func (h *AdminHandler) HandleStats(c *gin.Context) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	pid := h.codepay.GetMerchantID()
+
+	result := make(map[string]*database.OrderStats, len(statsPeriods))
+	for _, period := range statsPeriods {
+		stats, err := h.db.GetOrderStats(pid, today.AddDate(0, 0, 1-period.Days))
+		if err != nil {
+			logger.Error("Failed to get order stats",
+				zap.String("period", period.Name),
+				zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to get statistics",
+			})
+			return
+		}
+		result[period.Name] = stats
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"generated_at": now.Format("2006-01-02 15:04:05"),
+		"stats":        result,
+	})
+}
+
+// maxDailyStatsDays 每日统计最多返回的天数
+const maxDailyStatsDays = 366
+
+// HandleDailyStats 每日订单统计（由维护任务 housekeeping.stats 定时汇总，含已归档订单）
+// GET /admin/stats/daily?days=30&pid=
+func (h *AdminHandler) HandleDailyStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 || days > maxDailyStatsDays {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "days must be between 1 and 366",
+		})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, 1-days).Format("2006-01-02")
+	stats, err := h.db.GetDailyStats(c.Query("pid"), since)
+	if err != nil {
+		logger.Error("Failed to get daily stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get statistics",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"since":   since,
+		"stats":   stats,
+	})
+}
@@ -23,11 +23,13 @@ Description: 提供管理后台实时订单推送功能
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
 	"time"
 
+	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
 	"alimpay-go/internal/events"
 	"alimpay-go/internal/model"
@@ -42,28 +44,34 @@ import (
 AdminWebSocketHandler 管理后台WebSocket处理器
 字段:
   - db: 数据库实例
+  - cfg: 心跳配置
   - upgrader: WebSocket升级器
   - connections: 连接池
   - mu: 读写锁
+  - writeMu: 写入锁（连接不支持并发写，推送、心跳、重连通知互斥）
 */
 type AdminWebSocketHandler struct {
 	db          *database.DB
+	cfg         config.WebSocketConfig
 	upgrader    websocket.Upgrader
 	connections map[*websocket.Conn]bool
 	mu          sync.RWMutex
+	writeMu     sync.Mutex
 }
 
 /*
 NewAdminWebSocketHandler 创建管理后台WebSocket处理器
 参数:
   - db: 数据库实例
+  - cfg: WebSocket配置（心跳间隔、读取超时）
 
 返回:
   - *AdminWebSocketHandler: WebSocket处理器实例
 */
-func NewAdminWebSocketHandler(db *database.DB) *AdminWebSocketHandler {
+func NewAdminWebSocketHandler(db *database.DB, cfg config.WebSocketConfig) *AdminWebSocketHandler {
 	handler := &AdminWebSocketHandler{
-		db: db,
+		db:  db,
+		cfg: cfg,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -127,17 +135,18 @@ func (h *AdminWebSocketHandler) HandleWebSocket(c *gin.Context) {
 			logger.Info("Admin WebSocket client disconnected", zap.String("remote_addr", conn.RemoteAddr().String()))
 		}()
 
-		if err := conn.SetReadDeadline(time.Now().Add(60 * time.Second)); err != nil {
+		readTimeout := time.Duration(h.cfg.ReadTimeout) * time.Second
+		if err := conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
 			logger.Error("Failed to set read deadline", zap.Error(err))
 		}
 		conn.SetPongHandler(func(string) error {
-			if err := conn.SetReadDeadline(time.Now().Add(60 * time.Second)); err != nil {
+			if err := conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
 				logger.Error("Failed to set read deadline in pong handler", zap.Error(err))
 			}
 			return nil
 		})
 
-		ticker := time.NewTicker(30 * time.Second)
+		ticker := time.NewTicker(time.Duration(h.cfg.PingInterval) * time.Second)
 		defer ticker.Stop()
 
 		// 定期发送统计信息
@@ -147,7 +156,7 @@ func (h *AdminWebSocketHandler) HandleWebSocket(c *gin.Context) {
 		for {
 			select {
 			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
+				if err := h.writeMessage(conn, websocket.PingMessage, []byte{}); err != nil {
 					logger.Error("Failed to send ping to admin client", zap.Error(err))
 					return
 				}
@@ -197,7 +206,7 @@ func (h *AdminWebSocketHandler) sendStats(conn *websocket.Conn) {
 	}
 
 	// 计算今日总金额
-	var totalAmount float64
+	var totalAmount model.Amount
 	for _, order := range paidOrders {
 		totalAmount += order.PaymentAmount
 	}
@@ -223,7 +232,7 @@ func (h *AdminWebSocketHandler) sendStats(conn *websocket.Conn) {
 	logger.Debug("Stats sent",
 		zap.Int("pending", len(pendingOrders)),
 		zap.Int("paid", len(paidOrders)),
-		zap.Float64("amount", totalAmount))
+		zap.Stringer("amount", totalAmount))
 }
 
 /*
@@ -305,7 +314,7 @@ func (h *AdminWebSocketHandler) broadcast(message map[string]interface{}) {
 	}
 
 	for _, conn := range connections {
-		if err := conn.WriteMessage(websocket.TextMessage, jsonMessage); err != nil {
+		if err := h.writeMessage(conn, websocket.TextMessage, jsonMessage); err != nil {
 			logger.Error("Failed to send broadcast message", zap.Error(err))
 			h.removeConnection(conn)
 			conn.Close()
@@ -326,11 +335,24 @@ func (h *AdminWebSocketHandler) sendMessage(conn *websocket.Conn, message map[st
 		return
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, jsonMessage); err != nil {
+	if err := h.writeMessage(conn, websocket.TextMessage, jsonMessage); err != nil {
 		logger.Error("Failed to send message", zap.Error(err))
 	}
 }
 
+/*
+writeMessage 向连接写入消息（持有写入锁）
+参数:
+  - conn: WebSocket连接
+  - messageType: 消息类型
+  - data: 消息内容
+*/
+func (h *AdminWebSocketHandler) writeMessage(conn *websocket.Conn, messageType int, data []byte) error {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+	return conn.WriteMessage(messageType, data)
+}
+
 /*
 addConnection 添加连接
 参数:
@@ -355,6 +377,48 @@ func (h *AdminWebSocketHandler) removeConnection(conn *websocket.Conn) {
 	logger.Debug("Admin WebSocket connection removed", zap.Int("total_connections", len(h.connections)))
 }
 
+/*
+Reconnect 通知全部连接重连（平滑升级后新进程已在接受连接）
+功能: 发送 reconnect 消息，管理后台先连接新进程再关闭当前连接
+*/
+func (h *AdminWebSocketHandler) Reconnect() {
+	h.broadcast(map[string]interface{}{
+		"type":      "reconnect",
+		"timestamp": time.Now().Unix(),
+	})
+	logger.Info("Admin WebSocket connections asked to reconnect", zap.Int("connections", h.GetConnectionCount()))
+}
+
+/*
+Drain 等待全部连接断开
+参数:
+  - ctx: 等待期限
+
+返回:
+  - int: 期限到达时仍未断开的连接数
+*/
+func (h *AdminWebSocketHandler) Drain(ctx context.Context) int {
+	return waitDrained(ctx, h.GetConnectionCount)
+}
+
+/*
+CloseAll 通知全部连接服务即将重启
+功能: 发送关闭帧（1012 Service Restart），管理后台收到后自动重连到新进程
+*/
+func (h *AdminWebSocketHandler) CloseAll() {
+	h.mu.RLock()
+	connections := make([]*websocket.Conn, 0, len(h.connections))
+	for conn := range h.connections {
+		connections = append(connections, conn)
+	}
+	h.mu.RUnlock()
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	for _, conn := range connections {
+		_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+	}
+}
+
 /*
 GetConnectionCount 获取当前连接数
 返回:
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AlipayNotifyHandler 支付宝异步通知处理器（当面付、手机网站支付）
+type AlipayNotifyHandler struct {
+	codepay *service.CodePayService
+}
+
+// NewAlipayNotifyHandler 创建支付宝异步通知处理器
+func NewAlipayNotifyHandler(codepay *service.CodePayService) *AlipayNotifyHandler {
+	return &AlipayNotifyHandler{codepay: codepay}
+}
+
+// HandleNotify 处理支付宝异步通知
+// 处理成功应答 "success"，否则应答 "fail"，支付宝会按策略重发通知
+func (h *AlipayNotifyHandler) HandleNotify(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "fail")
+		return
+	}
+
+	params := make(map[string]string, len(c.Request.PostForm))
+	for key := range c.Request.PostForm {
+		params[key] = c.Request.PostForm.Get(key)
+	}
+
+	if err := h.codepay.HandleTradeNotify(params); err != nil {
+		logger.Error("Failed to handle alipay notify",
+			zap.String("out_trade_no", params["out_trade_no"]),
+			zap.String("trade_no", params["trade_no"]),
+			zap.String("notify_id", params["notify_id"]),
+			zap.Error(err))
+		c.String(http.StatusOK, "fail")
+		return
+	}
+
+	c.String(http.StatusOK, "success")
+}
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"alimpay-go/internal/config"
+	"alimpay-go/internal/middleware"
 	"alimpay-go/internal/service"
 	"alimpay-go/internal/validator"
+	"alimpay-go/internal/pkg/i18n"
 	"alimpay-go/internal/pkg/logger"
 	"alimpay-go/internal/pkg/utils"
 
@@ -75,27 +77,13 @@ func (h *APIHandler) HandleAction(c *gin.Context) {
 
 // handleQueryMerchant 查询商户信息
 func (h *APIHandler) handleQueryMerchant(c *gin.Context) {
-	pid := h.getParam(c, "pid")
-	key := h.getParam(c, "key")
-
-	if pid == "" || key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code": -1,
-			"msg":  "Missing required parameters: pid, key",
-		})
+	if err := merchantAuthError(c); err != nil {
+		respondCredentialError(c, err)
 		return
 	}
 
 	merchantInfo := h.codepay.GetMerchantInfo()
 
-	if pid != merchantInfo["id"] || key != merchantInfo["key"] {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Invalid merchant credentials",
-		})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"code":     1,
 		"pid":      merchantInfo["id"],
@@ -113,7 +101,6 @@ func (h *APIHandler) handleQueryMerchant(c *gin.Context) {
 // handleQueryOrder 查询单个订单
 func (h *APIHandler) handleQueryOrder(c *gin.Context) {
 	pid := h.getParam(c, "pid")
-	key := h.getParam(c, "key")
 	outTradeNo := h.getParam(c, "out_trade_no")
 
 	if pid == "" || outTradeNo == "" {
@@ -124,9 +111,13 @@ func (h *APIHandler) handleQueryOrder(c *gin.Context) {
 		return
 	}
 
-	// 允许不验证key的查询（用于前端状态检查）
-	validateKey := key != ""
-	result, err := h.codepay.QueryOrder(pid, key, outTradeNo, validateKey)
+	// 允许不携带凭据的查询（用于前端状态检查），但携带了错误凭据则拒绝
+	if err := merchantAuthError(c); err == service.ErrCredentialsInvalid {
+		respondCredentialError(c, err)
+		return
+	}
+
+	result, err := h.codepay.QueryOrder(pid, outTradeNo)
 	if err != nil {
 		logger.Error("Failed to query order", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -141,18 +132,14 @@ func (h *APIHandler) handleQueryOrder(c *gin.Context) {
 
 // handleQueryOrders 查询订单列表
 func (h *APIHandler) handleQueryOrders(c *gin.Context) {
-	pid := h.getParam(c, "pid")
-	key := h.getParam(c, "key")
-	limitStr := h.getParam(c, "limit")
-
-	if pid == "" || key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code": -1,
-			"msg":  "Missing required parameters: pid, key",
-		})
+	if err := merchantAuthError(c); err != nil {
+		respondCredentialError(c, err)
 		return
 	}
 
+	auth := middleware.GetMerchantAuth(c)
+	limitStr := h.getParam(c, "limit")
+
 	limit := 20
 	if limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil {
@@ -160,7 +147,7 @@ func (h *APIHandler) handleQueryOrders(c *gin.Context) {
 		}
 	}
 
-	result, err := h.codepay.QueryOrders(pid, key, limit)
+	result, err := h.codepay.QueryOrders(auth.MerchantID, limit)
 	if err != nil {
 		logger.Error("Failed to query orders", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -213,7 +200,7 @@ func (h *APIHandler) handleCreatePayment(c *gin.Context) {
 		logger.Warn("Invalid order parameters", zap.Error(err), zap.String("out_trade_no", params["out_trade_no"]))
 		c.JSON(http.StatusOK, gin.H{
 			"code": -1,
-			"msg":  "参数错误: " + err.Error(),
+			"msg":  i18n.T(i18n.Lang(params["lang"]), "api.invalid_params", err.Error()),
 		})
 		return
 	}
@@ -226,7 +213,7 @@ func (h *APIHandler) handleCreatePayment(c *gin.Context) {
 			zap.String("ip", c.ClientIP()))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": -1,
-			"msg":  "签名验证失败",
+			"msg":  i18n.T(i18n.Lang(params["lang"]), "api.invalid_sign"),
 		})
 		return
 	}
@@ -234,7 +221,8 @@ func (h *APIHandler) handleCreatePayment(c *gin.Context) {
 	// 获取基础URL
 	baseURL := utils.GetBaseURL(c, h.cfg.Server.BaseURL)
 
-	result, err := h.codepay.CreatePayment(params, baseURL)
+	// 服务端下单，买家IP由商户通过 clientip 参数传入
+	result, err := h.codepay.CreatePayment(params, baseURL, params["clientip"])
 	if err != nil {
 		logger.Error("Failed to create payment", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// merchantAuthError 获取当前请求的商户认证错误（已通过认证返回nil）
+func merchantAuthError(c *gin.Context) error {
+	auth := middleware.GetMerchantAuth(c)
+	switch {
+	case auth.Authenticated():
+		return nil
+	case !auth.Presented:
+		return service.ErrCredentialsMissing
+	default:
+		return service.ErrCredentialsInvalid
+	}
+}
+
+// respondCredentialError 以易支付格式返回凭据错误
+func respondCredentialError(c *gin.Context, err error) {
+	c.JSON(http.StatusOK, gin.H{
+		"code": -1,
+		"msg":  err.Error(),
+	})
+}
+
+// respondAdminCredentialError 以管理接口格式返回凭据错误
+func respondAdminCredentialError(c *gin.Context, err error) {
+	status := http.StatusUnauthorized
+	if credErr, ok := err.(*service.CredentialError); ok {
+		status = credErr.Status
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
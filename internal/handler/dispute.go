@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleDispute 将订单标记为争议（用户拒付或投诉）
+// POST /api/dispute（trade_no 或 out_trade_no、reason）
+// 争议期间不再发送异步通知，订单不计入收入统计；争议由管理员在后台解除
+func (h *YiPayHandler) HandleDispute(c *gin.Context) {
+	// 商户已由认证中间件验证
+	pid := middleware.GetMerchantAuth(c).MerchantID
+
+	order, changed, err := h.codepay.DisputeOrder(pid, h.getParam(c, "trade_no"), h.getParam(c, "out_trade_no"), h.getParam(c, "reason"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  disputeErrorMessage(err),
+		})
+		return
+	}
+
+	msg := "Order marked as disputed"
+	if !changed {
+		msg = "Order already disputed"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":         1,
+		"msg":          msg,
+		"trade_no":     order.ID,
+		"out_trade_no": order.OutTradeNo,
+		"status":       order.Status,
+		"disputed":     order.Disputed,
+	})
+}
+
+// disputeErrorMessage 争议请求错误信息（内部错误不返回细节）
+func disputeErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return "Order not found"
+	case errors.Is(err, service.ErrInvalidDispute),
+		errors.Is(err, service.ErrDisputeOrderNotPaid):
+		return err.Error()
+	}
+
+	logger.Error("Failed to handle dispute request", zap.Error(err))
+	return "Failed to handle dispute request"
+}
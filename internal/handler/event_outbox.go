@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// EventOutboxHandler 事件补拉接口（外部消费者按序号补拉错过的订单事件）
+type EventOutboxHandler struct {
+	outbox *service.EventOutbox // 未启用事件发件箱时为nil
+}
+
+// NewEventOutboxHandler 创建事件补拉处理器
+func NewEventOutboxHandler(outbox *service.EventOutbox) *EventOutboxHandler {
+	return &EventOutboxHandler{outbox: outbox}
+}
+
+// outboxEventEntry 补拉的事件（data 为发布时的订单）
+type outboxEventEntry struct {
+	*model.OutboxEvent
+	Data json.RawMessage `json:"data"`
+}
+
+// HandleReplay 补拉事件
+// GET /api/events?after=0&limit=100&type=order:paid,order:closed
+func (h *EventOutboxHandler) HandleReplay(c *gin.Context) {
+	if h.outbox == nil {
+		c.JSON(http.StatusOK, gin.H{"code": -1, "msg": "Event outbox is disabled"})
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil || after < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "Invalid after"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "Invalid limit"})
+		return
+	}
+
+	var types []string
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	pid := middleware.GetMerchantAuth(c).MerchantID
+	replay, err := h.outbox.Replay(after, pid, types, limit)
+	if err != nil {
+		logger.Error("Failed to replay outbox events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "msg": "Failed to query events"})
+		return
+	}
+
+	entries := make([]outboxEventEntry, 0, len(replay.Events))
+	for _, event := range replay.Events {
+		entries = append(entries, outboxEventEntry{OutboxEvent: event, Data: json.RawMessage(event.Payload)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       1,
+		"msg":        "SUCCESS",
+		"events":     entries,
+		"next_after": replay.NextAfter,
+		"has_more":   replay.HasMore,
+		"first_seq":  replay.FirstSeq,
+		"last_seq":   replay.LastSeq,
+		"gap":        replay.Gap,
+	})
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ndjsonFlushEvery 每写入多少行刷新一次响应
+const ndjsonFlushEvery = 500
+
+// ExportHandler 审计日志与交易流水导出（NDJSON，供外部SIEM/财务系统采集）
+type ExportHandler struct {
+	db *database.DB
+}
+
+// NewExportHandler 创建导出处理器
+func NewExportHandler(db *database.DB) *ExportHandler {
+	return &ExportHandler{db: db}
+}
+
+// auditLogEntry 审计日志行（detail 以JSON对象输出）
+type auditLogEntry struct {
+	*model.AuditLog
+	Detail json.RawMessage `json:"detail,omitempty"`
+}
+
+// tradeJournalEntry 交易流水行
+type tradeJournalEntry struct {
+	TradeNo       string     `json:"trade_no"`
+	OutTradeNo    string     `json:"out_trade_no"`
+	PID           string     `json:"pid"`
+	Type          string     `json:"type"`
+	Name          string     `json:"name"`
+	Money         string     `json:"money"`
+	PaymentAmount string     `json:"payment_amount"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+	AlipayTradeNo string     `json:"alipay_trade_no,omitempty"`
+	Disputed      bool       `json:"disputed,omitempty"` // 争议订单（拒付/投诉），财务系统不应计入收入
+}
+
+// HandleAuditLog 导出审计日志
+// GET /api/export/audit?start_date=2024-01-01&end_date=2024-01-31
+func (h *ExportHandler) HandleAuditLog(c *gin.Context) {
+	start, end, ok := exportRange(c)
+	if !ok {
+		return
+	}
+
+	writeNDJSON(c, "audit", func(emit func(interface{}) error) error {
+		return h.db.ExportAuditLogs(start, end, func(log *model.AuditLog) error {
+			entry := &auditLogEntry{AuditLog: log}
+			if json.Valid([]byte(log.Detail)) {
+				entry.Detail = json.RawMessage(log.Detail)
+			}
+			return emit(entry)
+		})
+	})
+}
+
+// HandleTradeJournal 导出交易流水（按订单创建时间）
+// GET /api/export/trades?start_date=2024-01-01&end_date=2024-01-31
+func (h *ExportHandler) HandleTradeJournal(c *gin.Context) {
+	start, end, ok := exportRange(c)
+	if !ok {
+		return
+	}
+
+	filter := database.OrderFilter{
+		PID:       middleware.GetMerchantAuth(c).MerchantID,
+		StartTime: &start,
+		EndTime:   &end,
+	}
+
+	writeNDJSON(c, "trades", func(emit func(interface{}) error) error {
+		return h.db.ExportOrders(filter, func(order *model.Order) error {
+			status, ok := orderStatusText[order.Status]
+			if !ok {
+				status = "unknown"
+			}
+			return emit(&tradeJournalEntry{
+				TradeNo:       order.ID,
+				OutTradeNo:    order.OutTradeNo,
+				PID:           order.PID,
+				Type:          order.Type,
+				Name:          order.Name,
+				Money:         order.Price.String(),
+				PaymentAmount: order.PaymentAmount.String(),
+				Status:        status,
+				CreatedAt:     order.AddTime,
+				PaidAt:        order.PayTime,
+				AlipayTradeNo: order.AlipayTradeNo,
+				Disputed:      order.Disputed,
+			})
+		})
+	})
+}
+
+// exportRange 解析导出时间范围，默认今天
+// end_date 仅日期时包含当天
+func exportRange(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, 1)
+
+	if v := c.Query("start_date"); v != "" {
+		t, _, err := parseDateParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "Invalid start_date"})
+			return start, end, false
+		}
+		start = t
+	}
+	if v := c.Query("end_date"); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "Invalid end_date"})
+			return start, end, false
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		end = t
+	}
+
+	if !end.After(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "end_date must be after start_date"})
+		return start, end, false
+	}
+
+	return start, end, true
+}
+
+// writeNDJSON 流式输出NDJSON（每行一个JSON对象）
+func writeNDJSON(c *gin.Context, name string, produce func(emit func(interface{}) error) error) {
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	count := 0
+	err := produce(func(v interface{}) error {
+		count++
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		if count%ndjsonFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	// 响应已开始写入，只能记录日志
+	if err != nil {
+		logger.Error("Failed to export NDJSON",
+			zap.String("export", name),
+			zap.Int("written", count),
+			zap.Error(err))
+	}
+}
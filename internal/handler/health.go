@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/middleware"
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/service"
 
@@ -16,6 +18,7 @@ type HealthHandler struct {
 	db      *database.DB
 	codepay *service.CodePayService
 	monitor *service.MonitorService
+	leader  *service.LeaderElector // 主节点选举（未启用时为nil）
 }
 
 // NewHealthHandler 创建健康检查处理器
@@ -27,6 +30,11 @@ func NewHealthHandler(db *database.DB, codepay *service.CodePayService, monitor
 	}
 }
 
+// SetLeaderElector 设置主节点选举（状态中显示实例标识和主节点）
+func (h *HealthHandler) SetLeaderElector(leader *service.LeaderElector) {
+	h.leader = leader
+}
+
 // HandleHealth 处理健康检查请求
 func (h *HealthHandler) HandleHealth(c *gin.Context) {
 	action := c.Query("action")
@@ -61,6 +69,20 @@ func (h *HealthHandler) handleStatus(c *gin.Context) {
 	// 获取监控状态
 	monitorStatus := h.monitor.GetStatus()
 
+	// 数据库结构版本（滚动升级时确认各实例与数据库版本兼容）
+	schema, _ := h.db.GetSchemaInfo()
+
+	// 最近的崩溃（不含堆栈，完整报告见管理后台）
+	crashesLastDay, _ := h.db.CountCrashReportsSince(time.Now().Add(-24 * time.Hour))
+	recentCrashes, _ := h.db.GetRecentCrashReports(5, false)
+
+	// 多实例部署的主节点状态
+	cluster := map[string]interface{}{"leader_election": false, "leader": true}
+	if h.leader != nil {
+		cluster = h.leader.Status()
+		cluster["leader_election"] = true
+	}
+
 	// 构建响应
 	response := gin.H{
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
@@ -71,8 +93,27 @@ func (h *HealthHandler) handleStatus(c *gin.Context) {
 				"status":        "healthy",
 				"total_orders":  totalOrders,
 				"unpaid_orders": unpaidOrders,
+				"schema": gin.H{
+					"binary_version":   database.SchemaVersion,
+					"database_version": schema,
+				},
 			},
 			"monitoring": monitorStatus,
+			"cluster":    cluster,
+			// 账单匹配与商户通知使用独立的Worker池
+			"worker_pools": gin.H{
+				"monitor": h.monitor.GetWorkerPoolStats(),
+				"notify":  h.codepay.GetNotifyPoolStats(),
+			},
+			// 按app_id的支付宝接口限流状态
+			"alipay_rate_limit": service.AlipayRateLimitStats(),
+			// 旧版 .php 后缀的使用次数（用于评估何时停止兼容）
+			"legacy_extension": middleware.LegacyExtensionStats(),
+			// 请求处理中发生的panic
+			"crashes": gin.H{
+				"last_24h": crashesLastDay,
+				"recent":   recentCrashes,
+			},
 		},
 		"counters": gin.H{
 			"total_orders":  totalOrders,
@@ -122,7 +163,7 @@ func (h *HealthHandler) handleCleanup(c *gin.Context) {
 	})
 }
 
-// handleDebug 调试信息
+// handleDebug 调试信息（最近订单、监听状态、事件订阅和发布/处理计数）
 func (h *HealthHandler) handleDebug(c *gin.Context) {
 	// 获取最近的订单（使用数据库提供的方法）
 	recentOrders, err := h.db.GetRecentOrders(10)
@@ -139,6 +180,7 @@ func (h *HealthHandler) handleDebug(c *gin.Context) {
 		"data": gin.H{
 			"recent_orders": recentOrders,
 			"monitor":       h.monitor.GetStatus(),
+			"events":        events.GetStats(),
 			"timestamp":     time.Now().Format("2006-01-02 15:04:05"),
 		},
 	})
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoadTestHandler 压测处理器（仅在 load_test.enabled 时注册路由）
+type LoadTestHandler struct {
+	loadTest *service.LoadTestService
+	monitor  *service.MonitorService
+	cfg      *config.Config
+}
+
+// NewLoadTestHandler 创建压测处理器
+func NewLoadTestHandler(loadTest *service.LoadTestService, monitor *service.MonitorService, cfg *config.Config) *LoadTestHandler {
+	return &LoadTestHandler{
+		loadTest: loadTest,
+		monitor:  monitor,
+		cfg:      cfg,
+	}
+}
+
+// HandleStart 开始压测
+// POST /admin/loadtest {"orders": 1000, "pay_ratio": 0.8}
+func (h *LoadTestHandler) HandleStart(c *gin.Context) {
+	var req struct {
+		Orders   int     `json:"orders"`
+		PayRatio float64 `json:"pay_ratio"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	run, err := h.loadTest.Start(req.Orders, req.PayRatio, utils.GetBaseURL(c, h.cfg.Server.BaseURL))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrLoadTestRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"run":     run,
+	})
+}
+
+// HandleStatus 获取压测状态（包括Worker池和事件总线统计）
+// GET /admin/loadtest
+func (h *LoadTestHandler) HandleStatus(c *gin.Context) {
+	run, err := h.loadTest.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get load test status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"run":     run,
+		"monitor": h.monitor.GetMonitorStatus(),
+		"events":  events.GetStats(),
+	})
+}
+
+// HandleCleanup 删除全部压测订单
+// POST /admin/loadtest/cleanup
+func (h *LoadTestHandler) HandleCleanup(c *gin.Context) {
+	count, err := h.loadTest.Cleanup()
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrLoadTestRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"deleted": count,
+	})
+}
+
+// HandleNotify 压测订单的通知接收端，直接返回成功
+// GET/POST /loadtest/notify
+func (h *LoadTestHandler) HandleNotify(c *gin.Context) {
+	c.String(http.StatusOK, "success")
+}
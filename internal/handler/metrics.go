@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"alimpay-go/internal/worker"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poolMetric Worker池指标（Prometheus 文本格式中的一个指标族）
+type poolMetric struct {
+	name  string
+	help  string
+	kind  string // gauge/counter
+	value func(m worker.Metrics) int64
+}
+
+// poolMetrics Worker池的数值指标
+var poolMetrics = []poolMetric{
+	{"alimpay_worker_pool_workers", "Current number of workers.", "gauge", func(m worker.Metrics) int64 { return int64(m.Workers) }},
+	{"alimpay_worker_pool_queue_capacity", "Task queue capacity.", "gauge", func(m worker.Metrics) int64 { return int64(m.QueueSize) }},
+	{"alimpay_worker_pool_queue_length", "Tasks waiting in the queue.", "gauge", func(m worker.Metrics) int64 { return int64(m.QueueLength) }},
+	{"alimpay_worker_pool_tasks_submitted_total", "Tasks accepted into the queue.", "counter", func(m worker.Metrics) int64 { return m.Submitted }},
+	{"alimpay_worker_pool_tasks_completed_total", "Tasks executed successfully.", "counter", func(m worker.Metrics) int64 { return m.Completed }},
+	{"alimpay_worker_pool_tasks_failed_total", "Tasks that returned an error.", "counter", func(m worker.Metrics) int64 { return m.Failed }},
+	{"alimpay_worker_pool_tasks_rejected_total", "Tasks rejected because the queue was full or the pool was stopped.", "counter", func(m worker.Metrics) int64 { return m.Rejected }},
+	{"alimpay_worker_pool_tasks_dropped_total", "Queued tasks dropped on shutdown or queue shrink.", "counter", func(m worker.Metrics) int64 { return m.Dropped }},
+}
+
+// HandleMetrics Prometheus 指标（账单匹配和商户通知Worker池）
+// GET /metrics
+func (h *HealthHandler) HandleMetrics(c *gin.Context) {
+	pools := []struct {
+		name    string
+		metrics worker.Metrics
+	}{
+		{"monitor", h.monitor.GetWorkerPoolMetrics()},
+		{"notify", h.codepay.GetNotifyPoolMetrics()},
+	}
+
+	var b strings.Builder
+	for _, metric := range poolMetrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, pool := range pools {
+			fmt.Fprintf(&b, "%s{pool=%q} %d\n", metric.name, pool.name, metric.value(pool.metrics))
+		}
+	}
+
+	writeHistogram(&b, "alimpay_worker_pool_task_wait_seconds", "Time tasks spent waiting in the queue.")
+	for _, pool := range pools {
+		writeHistogramSeries(&b, "alimpay_worker_pool_task_wait_seconds", pool.name, pool.metrics.Wait)
+	}
+	writeHistogram(&b, "alimpay_worker_pool_task_duration_seconds", "Task execution time.")
+	for _, pool := range pools {
+		writeHistogramSeries(&b, "alimpay_worker_pool_task_duration_seconds", pool.name, pool.metrics.Execution)
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// writeHistogram 写入直方图的 HELP 和 TYPE
+func writeHistogram(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+}
+
+// writeHistogramSeries 写入一个池的直方图（累计桶、_sum、_count）
+func writeHistogramSeries(w io.Writer, name, pool string, s worker.HistogramSnapshot) {
+	for i, le := range worker.LatencyBuckets {
+		fmt.Fprintf(w, "%s_bucket{pool=%q,le=%q} %d\n", name, pool, strconv.FormatFloat(le, 'g', -1, 64), s.Buckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{pool=%q,le=\"+Inf\"} %d\n", name, pool, s.Count)
+	fmt.Fprintf(w, "%s_sum{pool=%q} %s\n", name, pool, strconv.FormatFloat(s.Sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{pool=%q} %d\n", name, pool, s.Count)
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleNotifyIPs 公布通知来源信息 GET /api/notify/ips
+// 返回发送商户通知的出口IP（配置 merchant.outbound_ips）和通知签名请求头，商户可据此配置IP白名单和验签
+func (h *YiPayHandler) HandleNotifyIPs(c *gin.Context) {
+	info := h.codepay.NotifySourceInfo()
+	c.JSON(http.StatusOK, gin.H{
+		"code":      1,
+		"msg":       "SUCCESS",
+		"ips":       info["ips"],
+		"signature": info["signature"],
+	})
+}
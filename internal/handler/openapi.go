@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/web"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OpenAPIHandler OpenAPI文档处理器
+type OpenAPIHandler struct {
+	cfg  *config.Config
+	spec map[string]interface{}
+}
+
+// NewOpenAPIHandler 创建OpenAPI文档处理器
+func NewOpenAPIHandler(cfg *config.Config) *OpenAPIHandler {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(web.OpenAPISpec, &spec); err != nil {
+		logger.Error("Failed to parse embedded OpenAPI spec", zap.Error(err))
+	}
+
+	return &OpenAPIHandler{
+		cfg:  cfg,
+		spec: spec,
+	}
+}
+
+// HandleSpec 返回OpenAPI 3.0文档（servers按请求地址填充）
+func (h *OpenAPIHandler) HandleSpec(c *gin.Context) {
+	if h.spec == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "OpenAPI spec unavailable",
+		})
+		return
+	}
+
+	// 浅拷贝顶层，避免并发请求修改共享文档
+	spec := make(map[string]interface{}, len(h.spec)+1)
+	for k, v := range h.spec {
+		spec[k] = v
+	}
+	spec["servers"] = []map[string]string{
+		{"url": utils.GetBaseURL(c, h.cfg.Server.BaseURL)},
+	}
+
+	c.JSON(http.StatusOK, spec)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"alimpay-go/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 状态徽章
+const (
+	badgeLabel      = "支付状态"
+	badgeLabelColor = "#555"
+	badgeCharWidth  = 12 // 中文字符宽度（font-size 11）
+	badgePadding    = 8
+	badgeHeight     = 20
+)
+
+// badgeColors 订单状态对应的徽章颜色
+var badgeColors = map[int]string{
+	model.OrderStatusPending: "#faad14",
+	model.OrderStatusPaid:    "#52c41a",
+	model.OrderStatusClosed:  "#9f9f9f",
+	model.OrderStatusRefund:  "#1677ff",
+	model.OrderStatusExpired: "#9f9f9f",
+}
+
+// HandleBadge 订单状态徽章 GET /badge/order/:trade_no.svg
+// 返回SVG图片（待支付/已支付等），商户可嵌入邮件或论坛帖子展示实时支付状态；
+// 与状态轮询共用缓存，支持ETag/304
+func (h *OrderStatusHandler) HandleBadge(c *gin.Context) {
+	tradeNo, ok := strings.CutSuffix(c.Param("file"), ".svg")
+	if !ok || tradeNo == "" {
+		c.String(http.StatusNotFound, "Not found")
+		return
+	}
+
+	entry, err := h.load(tradeNo)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to query order")
+		return
+	}
+
+	// 订单不存在时同样返回徽章，避免嵌入处显示为破损图片
+	text, color := "订单不存在", badgeColors[model.OrderStatusClosed]
+	if entry != nil {
+		text, color = orderStatusText[entry.status], badgeColors[entry.status]
+		c.Header("ETag", entry.etag)
+		if c.GetHeader("If-None-Match") == entry.etag {
+			c.Header("Cache-Control", "no-cache")
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	// 嵌入处（邮件客户端、论坛）每次展示都需重新验证才能反映最新状态
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", []byte(renderBadge(badgeLabel, text, color)))
+}
+
+// renderBadge 生成徽章SVG（左侧标签，右侧状态）
+func renderBadge(label, text, color string) string {
+	labelWidth := utf8.RuneCountInString(label)*badgeCharWidth + badgePadding*2
+	textWidth := utf8.RuneCountInString(text)*badgeCharWidth + badgePadding*2
+	width := labelWidth + textWidth
+	label, text = html.EscapeString(label), html.EscapeString(text)
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[2]d" role="img" aria-label="%[3]s: %[4]s">`+
+		`<title>%[3]s: %[4]s</title>`+
+		`<clipPath id="r"><rect width="%[1]d" height="%[2]d" rx="3"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[5]d" height="%[2]d" fill="%[7]s"/><rect x="%[5]d" width="%[6]d" height="%[2]d" fill="%[8]s"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,'PingFang SC','Microsoft YaHei',sans-serif" font-size="11">`+
+		`<text x="%[9]d" y="14">%[3]s</text><text x="%[10]d" y="14">%[4]s</text></g></svg>`,
+		width, badgeHeight, label, text, labelWidth, textWidth, badgeLabelColor, color,
+		labelWidth/2, labelWidth+textWidth/2)
+}
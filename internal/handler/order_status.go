@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// orderStatusCacheTTL 订单状态缓存时间（支付页面每隔数秒轮询，短时缓存合并同一订单的重复查询）
+	orderStatusCacheTTL = 2 * time.Second
+	// orderStatusCacheMax 缓存条目数超过此值时清理已过期条目
+	orderStatusCacheMax = 10000
+)
+
+// OrderStatusHandler 订单状态轮询处理器
+// 供无法使用WebSocket的客户端降级轮询：只返回状态和支付时间，支持ETag/304，
+// 查询结果在内存中短时缓存，订单状态变更事件到达时立即失效
+type OrderStatusHandler struct {
+	db *database.DB
+
+	entries map[string]*orderStatusEntry
+	mu      sync.Mutex
+}
+
+// orderStatusEntry 缓存的订单状态
+type orderStatusEntry struct {
+	status  int
+	payTime string
+	etag    string
+	expires time.Time
+}
+
+// NewOrderStatusHandler 创建订单状态轮询处理器
+func NewOrderStatusHandler(db *database.DB) *OrderStatusHandler {
+	h := &OrderStatusHandler{
+		db:      db,
+		entries: make(map[string]*orderStatusEntry),
+	}
+
+	// 订单状态变更时使缓存失效，轮询无需等待缓存过期即可获得新状态
+	invalidate := func(data interface{}) {
+		if order, ok := data.(*model.Order); ok {
+			h.invalidate(order.ID)
+		}
+	}
+	events.Subscribe(events.EventOrderPaid, invalidate)
+	events.Subscribe(events.EventOrderClosed, invalidate)
+	events.Subscribe(events.EventOrderExpired, invalidate)
+	events.Subscribe(events.EventOrderRefund, invalidate)
+
+	return h
+}
+
+// HandleStatus 查询订单状态 GET /api/order/status?trade_no=...
+// 响应 {"status": 0, "pay_time": ""}；请求头 If-None-Match 与当前状态一致时返回 304
+func (h *OrderStatusHandler) HandleStatus(c *gin.Context) {
+	tradeNo := c.Query("trade_no")
+	if tradeNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "Missing required parameter: trade_no",
+		})
+		return
+	}
+
+	entry, err := h.load(tradeNo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "Failed to query order",
+		})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "Order not found",
+		})
+		return
+	}
+
+	// 每次请求都需向服务器确认（no-cache），状态未变化时只返回304
+	c.Header("Cache-Control", "no-cache")
+	c.Header("ETag", entry.etag)
+
+	if c.GetHeader("If-None-Match") == entry.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   entry.status,
+		"pay_time": entry.payTime,
+	})
+}
+
+// load 获取订单状态（优先使用缓存），订单不存在时返回nil
+func (h *OrderStatusHandler) load(tradeNo string) (*orderStatusEntry, error) {
+	now := time.Now()
+
+	h.mu.Lock()
+	entry := h.entries[tradeNo]
+	h.mu.Unlock()
+	if entry != nil && now.Before(entry.expires) {
+		return entry, nil
+	}
+
+	order, err := h.db.GetOrderByID(tradeNo)
+	if err != nil || order == nil {
+		return nil, err
+	}
+
+	payTime := ""
+	var payUnix int64
+	if order.PayTime != nil && !order.PayTime.IsZero() {
+		payTime = order.PayTime.Format("2006-01-02 15:04:05")
+		payUnix = order.PayTime.Unix()
+	}
+	entry = &orderStatusEntry{
+		status:  order.Status,
+		payTime: payTime,
+		etag:    fmt.Sprintf(`"%d-%d"`, order.Status, payUnix),
+		expires: now.Add(orderStatusCacheTTL),
+	}
+
+	h.mu.Lock()
+	if len(h.entries) >= orderStatusCacheMax {
+		for key, cached := range h.entries {
+			if !now.Before(cached.expires) {
+				delete(h.entries, key)
+			}
+		}
+	}
+	h.entries[tradeNo] = entry
+	h.mu.Unlock()
+
+	return entry, nil
+}
+
+// invalidate 删除订单的缓存状态
+func (h *OrderStatusHandler) invalidate(tradeNo string) {
+	h.mu.Lock()
+	delete(h.entries, tradeNo)
+	h.mu.Unlock()
+}
@@ -2,15 +2,23 @@ package handler
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/geoip"
+	"alimpay-go/internal/pkg/i18n"
 	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/money"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/service"
+	"alimpay-go/internal/web"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -18,15 +26,19 @@ import (
 
 // PayHandler 支付页面处理器
 type PayHandler struct {
-	db  *database.DB
-	cfg *config.Config
+	db      *database.DB
+	codepay *service.CodePayService
+	cfg     *config.Config
+	qrCodes *service.QRCodeManager
 }
 
 // NewPayHandler 创建支付页面处理器
-func NewPayHandler(db *database.DB, cfg *config.Config) *PayHandler {
+func NewPayHandler(db *database.DB, codepay *service.CodePayService, cfg *config.Config, qrCodes *service.QRCodeManager) *PayHandler {
 	return &PayHandler{
-		db:  db,
-		cfg: cfg,
+		db:      db,
+		codepay: codepay,
+		cfg:     cfg,
+		qrCodes: qrCodes,
 	}
 }
 
@@ -43,20 +55,14 @@ func (h *PayHandler) HandlePayPage(c *gin.Context) {
 		logger.Warn("Missing parameters",
 			zap.String("trade_no", tradeNo),
 			zap.String("amount", amountStr))
-		c.HTML(http.StatusOK, "error.html", gin.H{
-			"title":   "参数错误",
-			"message": "缺少必要参数",
-		})
+		renderErrorPage(c, http.StatusOK, "error.invalid_params", "error.missing_params")
 		return
 	}
 
 	// 解析金额
-	amount, err := strconv.ParseFloat(amountStr, 64)
+	amount, err := money.Parse(amountStr)
 	if err != nil {
-		c.HTML(http.StatusOK, "error.html", gin.H{
-			"title":   "参数错误",
-			"message": "金额格式错误",
-		})
+		renderErrorPage(c, http.StatusOK, "error.invalid_params", "error.invalid_amount")
 		return
 	}
 
@@ -67,19 +73,13 @@ func (h *PayHandler) HandlePayPage(c *gin.Context) {
 		logger.Error("Failed to query order",
 			zap.String("trade_no", tradeNo),
 			zap.Error(err))
-		c.HTML(http.StatusOK, "error.html", gin.H{
-			"title":   "订单不存在",
-			"message": "订单未找到或已失效",
-		})
+		renderErrorPage(c, http.StatusOK, "error.order_not_found", "error.order_expired")
 		return
 	}
 
 	if order == nil {
 		logger.Warn("Order is nil", zap.String("trade_no", tradeNo))
-		c.HTML(http.StatusOK, "error.html", gin.H{
-			"title":   "订单不存在",
-			"message": "订单未找到或已失效",
-		})
+		renderErrorPage(c, http.StatusOK, "error.order_not_found", "error.order_expired")
 		return
 	}
 
@@ -87,105 +87,226 @@ func (h *PayHandler) HandlePayPage(c *gin.Context) {
 		zap.String("trade_no", tradeNo),
 		zap.String("out_trade_no", order.OutTradeNo),
 		zap.Int("status", order.Status),
-		zap.Float64("payment_amount", order.PaymentAmount))
+		zap.Stringer("payment_amount", order.PaymentAmount))
 
-	// 检查订单状态
+	// 检查订单状态（已支付且设置了跳转地址时直接返回商户页面）
+	if order.Status == model.OrderStatusPaid && order.ReturnURL != "" {
+		c.Redirect(http.StatusFound, "/pay/return?trade_no="+url.QueryEscape(order.ID))
+		return
+	}
 	if order.Status == 1 {
 		logger.Warn("Order already paid", zap.String("trade_no", tradeNo))
-		c.HTML(http.StatusOK, "error.html", gin.H{
-			"title":   "订单已支付",
-			"message": "该订单已完成支付",
-		})
+		renderErrorPage(c, http.StatusOK, "error.order_paid", "error.order_paid_desc")
 		return
 	}
 
 	logger.Info("Payment page accessed",
 		zap.String("trade_no", tradeNo),
-		zap.Float64("amount", amount))
-
-	// 读取经营码图片
-	var qrCodePath string
-	var qrCodeID string
-
-	// 如果订单有分配的二维码ID，使用对应的二维码
-	if order.QRCodeID != "" && len(h.cfg.Payment.BusinessQRMode.QRCodePaths) > 0 {
-		found := false
-		for _, qr := range h.cfg.Payment.BusinessQRMode.QRCodePaths {
-			if qr.ID == order.QRCodeID {
-				qrCodePath = qr.Path
-				qrCodeID = qr.CodeID
-				found = true
-				logger.Info("Using assigned QR code",
-					zap.String("qr_id", order.QRCodeID),
-					zap.String("path", qrCodePath))
-				break
+		zap.Stringer("amount", amount))
+
+	baseURL := utils.GetBaseURL(c, h.cfg.Server.BaseURL)
+	lang := requestLang(c)
+	view := newPayView(h.cfg, h.qrCodes, order, h.codepay.OrderPaymentInfo(order, baseURL, lang), lang)
+
+	// 当面付、手机网站支付订单展示支付宝返回的二维码，沙箱订单不展示收款码，其他订单展示经营码图片
+	if !order.IsAlipayTrade() && !order.IsSandbox() {
+		// 读取经营码图片
+		qrCodePath, _ := businessQRCode(h.cfg, h.qrCodes, order)
+
+		logger.Info("Reading QR code file", zap.String("path", qrCodePath))
+
+		qrCodeData, err := os.ReadFile(qrCodePath)
+		if err != nil {
+			logger.Error("Failed to read QR code",
+				zap.String("path", qrCodePath),
+				zap.Error(err))
+			renderErrorPage(c, http.StatusOK, "error.system", "error.qrcode_unavailable")
+			return
+		}
+
+		logger.Info("QR code file read successfully",
+			zap.String("path", qrCodePath),
+			zap.Int("size", len(qrCodeData)))
+
+		// 检测文件类型
+		contentType := "image/png"
+		if len(qrCodeData) > 2 {
+			if qrCodeData[0] == 0xFF && qrCodeData[1] == 0xD8 {
+				contentType = "image/jpeg"
 			}
 		}
-		if !found {
-			logger.Warn("Assigned QR code not found, using default",
-				zap.String("qr_id", order.QRCodeID))
-			qrCodePath = h.cfg.Payment.BusinessQRMode.QRCodePath
-			qrCodeID = h.cfg.Payment.BusinessQRMode.QRCodeID
+
+		// 生成 Data URI（需要使用 template.URL 类型避免被转义）
+		view.QRCode = template.URL(fmt.Sprintf("data:%s;base64,%s", contentType,
+			encodeBase64(qrCodeData)))
+	}
+
+	logger.Info("Rendering payment page",
+		zap.String("trade_no", tradeNo),
+		zap.String("mode", view.Mode))
+
+	clientIP := c.ClientIP()
+	if location := geoip.Describe(clientIP); location != "" {
+		clientIP = fmt.Sprintf("%s (%s)", clientIP, location)
+	}
+	h.db.RecordOrderEvent(order.ID, model.OrderEventPageViewed,
+		fmt.Sprintf("IP: %s, UA: %s", clientIP, c.Request.UserAgent()))
+
+	// 渲染支付页面
+	c.HTML(http.StatusOK, "pay.html", gin.H{
+		"View": view,
+	})
+}
+
+// businessQRCode 获取订单使用的经营码图片路径和收款码ID（未分配或已删除时使用默认经营码）
+func businessQRCode(cfg *config.Config, qrCodes *service.QRCodeManager, order *model.Order) (string, string) {
+	if order.QRCodeID != "" && qrCodes != nil {
+		if qr, found := qrCodes.Get(order.QRCodeID); found {
+			logger.Debug("Using assigned QR code",
+				zap.String("qr_id", order.QRCodeID),
+				zap.String("path", qr.Path))
+			return qr.Path, qr.CodeID
 		}
-	} else {
-		// 使用默认二维码
-		qrCodePath = h.cfg.Payment.BusinessQRMode.QRCodePath
-		qrCodeID = h.cfg.Payment.BusinessQRMode.QRCodeID
+		logger.Warn("Assigned QR code not found, using default",
+			zap.String("qr_id", order.QRCodeID))
 	}
+	return cfg.DefaultBusinessQRCode()
+}
 
-	logger.Info("Reading QR code file", zap.String("path", qrCodePath))
+// HandleOrderView 查询支付页面数据（支付页面脚本轮询，返回结构见 PayView）
+func (h *PayHandler) HandleOrderView(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
 
-	qrCodeData, err := os.ReadFile(qrCodePath)
-	if err != nil {
-		logger.Error("Failed to read QR code",
-			zap.String("path", qrCodePath),
-			zap.Error(err))
-		c.HTML(http.StatusOK, "error.html", gin.H{
-			"title":   "系统错误",
-			"message": "无法加载收款码",
+	tradeNo := c.Query("trade_no")
+	if tradeNo == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "Missing required parameter: trade_no",
 		})
 		return
 	}
 
-	logger.Info("QR code file read successfully",
-		zap.String("path", qrCodePath),
-		zap.Int("size", len(qrCodeData)))
+	order, err := h.db.GetOrderByID(tradeNo)
+	if err != nil || order == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "Order not found",
+		})
+		return
+	}
+
+	// 仅待支付订单需要支付信息
+	lang := requestLang(c)
+	var payment map[string]interface{}
+	if order.Status == model.OrderStatusPending {
+		payment = h.codepay.OrderPaymentInfo(order, utils.GetBaseURL(c, h.cfg.Server.BaseURL), lang)
+	}
+	view := newPayView(h.cfg, h.qrCodes, order, payment, lang)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":  1,
+		"msg":   "SUCCESS",
+		"order": view,
+	})
+}
+
+// HandleServiceWorker 提供支付页面的Service Worker脚本
+// 脚本从根路径提供才能控制 /pay、/submit 页面；浏览器检查更新时需重新获取，因此不缓存
+func (h *PayHandler) HandleServiceWorker(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", web.PayServiceWorker)
+}
 
-	// 检测文件类型
-	contentType := "image/png"
-	if len(qrCodeData) > 2 {
-		if qrCodeData[0] == 0xFF && qrCodeData[1] == 0xD8 {
-			contentType = "image/jpeg"
+// HandleReturn 支付完成后跳转回商户页面
+// 已支付订单按易支付标准在 return_url 后附加签名的支付结果（trade_no、out_trade_no、money、trade_status、sign 等）
+func (h *PayHandler) HandleReturn(c *gin.Context) {
+	tradeNo := c.Query("trade_no")
+	if tradeNo == "" {
+		renderErrorPage(c, http.StatusOK, "error.invalid_params", "error.missing_params")
+		return
+	}
+
+	order, err := h.db.GetOrderByID(tradeNo)
+	if err != nil || order == nil || order.ReturnURL == "" {
+		renderErrorPage(c, http.StatusOK, "error.order_not_found", "error.no_return_url")
+		return
+	}
+
+	// 从支付宝收银台返回时异步通知可能尚未到达，先查询交易状态
+	if order.Status == model.OrderStatusPending && order.IsAlipayTrade() {
+		if err := h.codepay.SyncTradeOrder(order); err != nil {
+			logger.Warn("Failed to sync alipay trade on return",
+				zap.String("trade_no", order.ID),
+				zap.Error(err))
+		}
+		if synced, err := h.db.GetOrderByID(order.ID); err == nil && synced != nil {
+			order = synced
 		}
 	}
 
-	// 生成 Data URI（需要使用 template.URL 类型避免被转义）
-	dataURI := template.URL(fmt.Sprintf("data:%s;base64,%s", contentType,
-		encodeBase64(qrCodeData)))
+	returnURL, err := h.codepay.BuildReturnURL(order)
+	if err != nil {
+		logger.Warn("Invalid return url",
+			zap.String("trade_no", order.ID),
+			zap.String("return_url", order.ReturnURL),
+			zap.Error(err))
+		renderErrorPage(c, http.StatusOK, "error.redirect_failed", "error.invalid_return_url")
+		return
+	}
+
+	h.db.RecordOrderEvent(order.ID, model.OrderEventReturned, order.ReturnURL)
 
-	logger.Info("Rendering payment page",
-		zap.String("trade_no", tradeNo),
-		zap.Int("qr_code_size", len(qrCodeData)))
+	c.Redirect(http.StatusFound, returnURL)
+}
 
-	// 渲染支付页面
-	c.HTML(http.StatusOK, "pay.html", gin.H{
-		"order": gin.H{
-			"trade_no":       tradeNo,
-			"out_trade_no":   order.OutTradeNo,
-			"name":           order.Name,
-			"amount":         amount,
-			"payment_amount": order.PaymentAmount,
-			"create_time":    order.AddTime.Format("2006-01-02 15:04:05"),
-			"pid":            order.PID,
-		},
-		"qr_code_data": dataURI,
-		"qr_code_id":   qrCodeID, // 支付宝收款码ID
-		"instructions": gin.H{
-			"step1": "打开支付宝，点击「扫一扫」",
-			"step2": fmt.Sprintf("扫描下方二维码，输入金额 %.2f 元", amount),
-			"step3": "确认支付后，页面将自动跳转",
-		},
-	})
+// HandlePrintedCode 扫描线下打印的收款码 GET /s/:code
+// 首次扫码时创建订单（关联订单关闭或过期后重新创建），然后跳转到订单的支付页面
+func (h *PayHandler) HandlePrintedCode(c *gin.Context) {
+	code := c.Param("code")
+
+	order, err := h.codepay.ResolvePrintedCode(code, utils.GetBaseURL(c, h.cfg.Server.BaseURL))
+	if err != nil {
+		logger.Error("Failed to resolve printed QR code",
+			zap.String("code", code),
+			zap.Error(err))
+		renderErrorPage(c, http.StatusOK, "error.create_failed", "error.rescan")
+		return
+	}
+	if order == nil {
+		renderErrorPage(c, http.StatusNotFound, "error.printed_invalid", "error.printed_invalid_desc")
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprintf("/pay?trade_no=%s&amount=%s", url.QueryEscape(order.ID), order.PaymentAmount))
+}
+
+// HandleSandboxPay 模拟支付沙箱订单 POST /pay/sandbox
+// 为订单添加模拟账单后跳转回支付页面，监听服务下一周期匹配后按正常流程通知商户
+func (h *PayHandler) HandleSandboxPay(c *gin.Context) {
+	tradeNo := c.PostForm("trade_no")
+	if tradeNo == "" {
+		renderErrorPage(c, http.StatusBadRequest, "error.invalid_params", "error.missing_params")
+		return
+	}
+
+	order, err := h.codepay.ConfirmSandboxOrder(tradeNo)
+	if errors.Is(err, service.ErrOrderNotFound) || errors.Is(err, service.ErrNotSandboxOrder) {
+		renderErrorPage(c, http.StatusNotFound, "error.order_not_found", "error.sandbox_not_found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to confirm sandbox order",
+			zap.String("trade_no", tradeNo),
+			zap.Error(err))
+		renderErrorPage(c, http.StatusOK, "error.system", "error.sandbox_failed")
+		return
+	}
+
+	payURL := fmt.Sprintf("/pay?trade_no=%s&amount=%s", url.QueryEscape(order.ID), order.PaymentAmount)
+	if lang := i18n.Normalize(c.PostForm("lang")); lang != "" {
+		payURL += "&lang=" + lang
+	}
+	c.Redirect(http.StatusSeeOther, payURL)
 }
 
 // encodeBase64 编码为base64
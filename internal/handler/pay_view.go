@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/i18n"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PayView 支付页面数据
+// 支付页面模板（pay.html、submit.html）与 GET /api/pay/order 使用同一结构：模板通过 .View 渲染首屏
+// （未启用JavaScript时页面同样可用），页面脚本（static/js/pay-view.js）定期请求接口刷新状态和倒计时。
+// 自定义主题只需修改模板，无需调整处理器；页面文本按 Lang 从 internal/pkg/i18n 获取
+type PayView struct {
+	TradeNo        string       `json:"trade_no"`
+	OutTradeNo     string       `json:"out_trade_no"`
+	PID            string       `json:"pid"`
+	Name           string       `json:"name"`
+	SiteName       string       `json:"sitename"`
+	Amount         model.Amount `json:"amount"`          // 订单金额
+	PaymentAmount  model.Amount `json:"payment_amount"`  // 实际支付金额
+	AmountAdjusted bool         `json:"amount_adjusted"` // 经营码模式下同金额订单的支付金额已调整
+	PaidAmount     model.Amount `json:"paid_amount"`     // 已收到的分笔支付金额（payment.partial_payment）
+	Status         int          `json:"status"`
+	StatusText     string       `json:"status_text"`
+	CreateTime     string       `json:"create_time"`
+	ExpireTime     string       `json:"expire_time"`
+	ExpiresIn      int          `json:"expires_in"` // 剩余支付时间（秒，按服务器时间计算，非待支付订单为0）
+	KeepOpen       bool         `json:"keep_open"`  // 未支付订单不自动关闭（下单参数 auto_close=0），页面不显示倒计时
+	Mode           string       `json:"mode"`       // 收款模式：business、transfer、precreate、wap、sandbox
+	PaymentURL     string       `json:"payment_url,omitempty"`
+	QRCodeID       string       `json:"qr_code_id,omitempty"` // 支付宝收款码ID（经营码模式手机端拉起支付宝）
+	ReturnURL      string       `json:"return_url,omitempty"` // 支付完成后的跳转地址（经 /pay/return 记录后跳转商户）
+	Tips           []string     `json:"tips"`
+	Lang           string       `json:"lang"` // 页面语言（zh-CN、en-US），状态文本和支付提示使用该语言
+
+	// QRCode 二维码图片（data URI 或图片地址），只用于服务端渲染，接口不返回以免轮询时重复生成
+	QRCode template.URL `json:"-"`
+}
+
+// 收款模式
+const (
+	payModeBusiness  = "business"
+	payModeTransfer  = "transfer"
+	payModePrecreate = "precreate"
+	payModeWap       = "wap"
+	payModeSandbox   = "sandbox"
+)
+
+// newPayView 构建支付页面数据
+// @param cfg 配置
+// @param qrCodes 经营码管理器（解析订单分配的收款码ID）
+// @param order 订单
+// @param payment 支付信息（下单结果或 CodePayService.OrderPaymentInfo），为nil时不填充支付链接和二维码
+// @param lang 页面语言
+// @return *PayView 支付页面数据
+func newPayView(cfg *config.Config, qrCodes *service.QRCodeManager, order *model.Order, payment map[string]interface{}, lang string) *PayView {
+	expireAt := order.AddTime.Add(time.Duration(cfg.Payment.OrderTimeout) * time.Second)
+	expiresIn := 0
+	if order.Status == model.OrderStatusPending {
+		expiresIn = max(0, int(time.Until(expireAt).Seconds()))
+	}
+	expireTime := expireAt.Format("2006-01-02 15:04:05")
+	if order.KeepOpen {
+		expiresIn = 0
+		expireTime = ""
+	}
+
+	view := &PayView{
+		TradeNo:        order.ID,
+		OutTradeNo:     order.OutTradeNo,
+		PID:            order.PID,
+		Name:           order.Name,
+		SiteName:       order.Sitename,
+		Amount:         order.Price,
+		PaymentAmount:  order.PaymentAmount,
+		AmountAdjusted: order.PaymentAmount != order.Price,
+		Status:         order.Status,
+		StatusText:     i18n.T(lang, fmt.Sprintf("status.%d", order.Status)),
+		CreateTime:     order.AddTime.Format("2006-01-02 15:04:05"),
+		ExpireTime:     expireTime,
+		ExpiresIn:      expiresIn,
+		KeepOpen:       order.KeepOpen,
+		Tips:           []string{},
+		Lang:           lang,
+	}
+
+	switch {
+	case order.IsSandbox():
+		view.Mode = payModeSandbox
+	case order.QRCodeID == model.QRCodeIDWap:
+		view.Mode = payModeWap
+	case order.QRCodeID == model.QRCodeIDPrecreate:
+		view.Mode = payModePrecreate
+	case cfg.Payment.BusinessQRMode.Enabled:
+		view.Mode = payModeBusiness
+		_, view.QRCodeID = businessQRCode(cfg, qrCodes, order)
+	default:
+		view.Mode = payModeTransfer
+	}
+
+	if order.ReturnURL != "" {
+		view.ReturnURL = "/pay/return?trade_no=" + url.QueryEscape(order.ID)
+	}
+
+	if payment != nil {
+		view.PaymentURL = getString(payment, "payment_url")
+		if qrCode := getString(payment, "qr_code"); qrCode != "" {
+			view.QRCode = template.URL("data:image/png;base64," + qrCode)
+		} else if qrCodeURL := getString(payment, "qr_code_url"); qrCodeURL != "" {
+			view.QRCode = template.URL(qrCodeURL)
+		}
+
+		view.PaidAmount, _ = payment["paid_amount"].(model.Amount)
+		view.Tips = getSlice(payment, "payment_tips")
+		if instruction := getString(payment, "payment_instruction"); len(view.Tips) == 0 && instruction != "" {
+			view.Tips = []string{instruction}
+		}
+	}
+
+	return view
+}
+
+// requestLang 页面语言：优先使用 lang 参数，未指定或不支持时按浏览器的 Accept-Language 选择
+func requestLang(c *gin.Context) string {
+	if lang := i18n.Normalize(c.Query("lang")); lang != "" {
+		return lang
+	}
+	if lang := i18n.Normalize(c.PostForm("lang")); lang != "" {
+		return lang
+	}
+	return i18n.Detect(c.GetHeader("Accept-Language"))
+}
+
+// renderErrorPage 按页面语言渲染错误页面
+// @param status HTTP状态码
+// @param titleKey 标题的文本键名
+// @param messageKey 说明的文本键名
+func renderErrorPage(c *gin.Context, status int, titleKey, messageKey string) {
+	lang := requestLang(c)
+	c.HTML(status, "error.html", gin.H{
+		"Lang":    lang,
+		"title":   i18n.T(lang, titleKey),
+		"message": i18n.T(lang, messageKey),
+	})
+}
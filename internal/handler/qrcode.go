@@ -6,24 +6,45 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// qrImageRevalidate 缓存的二维码图片多久检查一次文件是否被替换
+const qrImageRevalidate = 10 * time.Second
+
 // QRCodeHandler 二维码处理器
 type QRCodeHandler struct {
-	cfg *config.Config
+	cfg     *config.Config
+	qrCodes *service.QRCodeManager
+
+	// 二维码图片内存缓存（按文件路径），避免每次请求都读取磁盘
+	images   map[string]*qrImage
+	imagesMu sync.Mutex
+}
+
+// qrImage 缓存的二维码图片
+type qrImage struct {
+	data    []byte
+	etag    string
+	modTime time.Time
+	size    int64
+	checked time.Time // 上次检查文件的时间
 }
 
 // NewQRCodeHandler 创建二维码处理器
-func NewQRCodeHandler(cfg *config.Config) *QRCodeHandler {
+func NewQRCodeHandler(cfg *config.Config, qrCodes *service.QRCodeManager) *QRCodeHandler {
 	return &QRCodeHandler{
-		cfg: cfg,
+		cfg:     cfg,
+		qrCodes: qrCodes,
+		images:  make(map[string]*qrImage),
 	}
 }
 
@@ -53,20 +74,14 @@ func (h *QRCodeHandler) handleBusinessQRCode(c *gin.Context, qrID string) {
 	var qrCodePath string
 
 	// 如果配置了多个二维码
-	if len(h.cfg.Payment.BusinessQRMode.QRCodePaths) > 0 {
+	if qrCodes := h.qrCodes.List(); len(qrCodes) > 0 {
 		if qrID == "" {
-			// 未指定ID，使用第一个
-			qrCodePath = h.cfg.Payment.BusinessQRMode.QRCodePaths[0].Path
+			// 未指定ID，使用优先级最高的
+			qrCodePath = qrCodes[0].Path
 		} else {
 			// 根据ID查找对应的二维码
-			found := false
-			for _, qr := range h.cfg.Payment.BusinessQRMode.QRCodePaths {
-				if qr.ID == qrID {
-					qrCodePath = qr.Path
-					found = true
-					break
-				}
-			}
+			qr, found := h.qrCodes.Get(qrID)
+			qrCodePath = qr.Path
 			if !found {
 				logger.Error("QR code not found", zap.String("id", qrID))
 				c.String(http.StatusNotFound, "QR code not found")
@@ -75,18 +90,15 @@ func (h *QRCodeHandler) handleBusinessQRCode(c *gin.Context, qrID string) {
 		}
 	} else {
 		// 传统单二维码模式
-		qrCodePath = h.cfg.Payment.BusinessQRMode.QRCodePath
+		qrCodePath, _ = h.cfg.DefaultBusinessQRCode()
 	}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(qrCodePath); os.IsNotExist(err) {
+	image, err := h.loadImage(qrCodePath)
+	if os.IsNotExist(err) {
 		logger.Error("Business QR code file not found", zap.String("path", qrCodePath))
 		c.String(http.StatusNotFound, "Business QR code file not found")
 		return
 	}
-
-	// 读取文件
-	data, err := os.ReadFile(qrCodePath)
 	if err != nil {
 		logger.Error("Failed to read QR code file", zap.Error(err))
 		c.String(http.StatusInternalServerError, "Failed to read QR code file")
@@ -96,9 +108,56 @@ func (h *QRCodeHandler) handleBusinessQRCode(c *gin.Context, qrID string) {
 	// 设置响应头
 	c.Header("Content-Type", "image/png")
 	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("ETag", image.etag)
+
+	if c.GetHeader("If-None-Match") == image.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
 
 	// 返回文件
-	c.Data(http.StatusOK, "image/png", data)
+	c.Data(http.StatusOK, "image/png", image.data)
+}
+
+// loadImage 获取二维码图片（优先使用内存缓存）
+// 缓存超过 qrImageRevalidate 后检查文件修改时间和大小，文件被替换时重新读取；
+// 读取在锁内进行，并发请求不会同时读取磁盘
+func (h *QRCodeHandler) loadImage(path string) (*qrImage, error) {
+	h.imagesMu.Lock()
+	defer h.imagesMu.Unlock()
+
+	now := time.Now()
+	cached := h.images[path]
+	if cached != nil && now.Sub(cached.checked) < qrImageRevalidate {
+		return cached, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(h.images, path)
+		return nil, err
+	}
+	if cached != nil && info.ModTime().Equal(cached.modTime) && info.Size() == cached.size {
+		cached.checked = now
+		return cached, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		delete(h.images, path)
+		return nil, err
+	}
+
+	hash := md5.Sum(data)
+	image := &qrImage{
+		data:    data,
+		etag:    `"` + hex.EncodeToString(hash[:]) + `"`,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		checked: now,
+	}
+	h.images[path] = image
+	return image, nil
 }
 
 // generateToken 生成访问token
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleRefundRequest 提交退款申请
+// POST /api/refund_request（trade_no 或 out_trade_no、money、reason、可选 out_refund_no）
+// 申请进入管理后台审核队列，返回 refund_no 供商户通过 /api/refund_query 轮询审核结果
+func (h *YiPayHandler) HandleRefundRequest(c *gin.Context) {
+	// 商户已由认证中间件验证
+	pid := middleware.GetMerchantAuth(c).MerchantID
+
+	request, created, err := h.codepay.RequestRefund(pid, service.RefundRequestParams{
+		TradeNo:     h.getParam(c, "trade_no"),
+		OutTradeNo:  h.getParam(c, "out_trade_no"),
+		OutRefundNo: h.getParam(c, "out_refund_no"),
+		Money:       h.getParam(c, "money"),
+		Reason:      h.getParam(c, "reason"),
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  refundErrorMessage(err),
+		})
+		return
+	}
+
+	msg := "Refund request submitted, pending review"
+	if !created {
+		msg = "Refund request already exists"
+	}
+	c.JSON(http.StatusOK, refundRequestResponse(request, msg))
+}
+
+// HandleRefundQuery 查询退款申请审核结果
+// GET /api/refund_query（refund_no 或 out_refund_no）
+func (h *YiPayHandler) HandleRefundQuery(c *gin.Context) {
+	// 商户已由认证中间件验证
+	pid := middleware.GetMerchantAuth(c).MerchantID
+
+	request, err := h.codepay.GetRefundRequest(pid, h.getParam(c, "refund_no"), h.getParam(c, "out_refund_no"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  refundErrorMessage(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, refundRequestResponse(request, "success"))
+}
+
+// refundRequestResponse 退款申请响应（易支付格式）
+func refundRequestResponse(request *model.RefundRequest, msg string) gin.H {
+	response := gin.H{
+		"code":          1,
+		"msg":           msg,
+		"refund_no":     request.RefundNo,
+		"out_refund_no": request.OutRefundNo,
+		"trade_no":      request.OrderID,
+		"out_trade_no":  request.OutTradeNo,
+		"money":         request.Amount.String(),
+		"reason":        request.Reason,
+		"status":        request.Status,
+		"admin_note":    request.AdminNote,
+		"created_at":    request.CreatedAt.Format("2006-01-02 15:04:05"),
+		"processed_at":  "",
+	}
+
+	if request.ProcessedAt != nil {
+		response["processed_at"] = request.ProcessedAt.Format("2006-01-02 15:04:05")
+	}
+	return response
+}
+
+// refundErrorMessage 退款申请错误信息（内部错误不返回细节）
+func refundErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return "Order not found"
+	case errors.Is(err, service.ErrInvalidRefundRequest),
+		errors.Is(err, service.ErrRefundOrderNotPaid),
+		errors.Is(err, service.ErrRefundAmountExceeded),
+		errors.Is(err, service.ErrRefundRequestNotFound),
+		errors.Is(err, service.ErrOutRefundNoConflict):
+		return err.Error()
+	}
+
+	logger.Error("Failed to handle refund request", zap.Error(err))
+	return "Failed to handle refund request"
+}
@@ -5,6 +5,9 @@ import (
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/service"
+	"alimpay-go/internal/pkg/botfilter"
+	"alimpay-go/internal/pkg/captcha"
+	"alimpay-go/internal/pkg/i18n"
 	"alimpay-go/internal/pkg/logger"
 	"alimpay-go/internal/pkg/utils"
 
@@ -16,13 +19,18 @@ import (
 type SubmitHandler struct {
 	codepay *service.CodePayService
 	cfg     *config.Config
+	qrCodes *service.QRCodeManager
+	captcha captcha.Provider // 下单人机验证（未启用时为nil）
+
+	botFilter *botfilter.Filter // 下单机器人过滤（未启用时为nil）
 }
 
 // NewSubmitHandler 创建支付页面处理器
-func NewSubmitHandler(codepay *service.CodePayService, cfg *config.Config) *SubmitHandler {
+func NewSubmitHandler(codepay *service.CodePayService, cfg *config.Config, qrCodes *service.QRCodeManager) *SubmitHandler {
 	return &SubmitHandler{
 		codepay: codepay,
 		cfg:     cfg,
+		qrCodes: qrCodes,
 	}
 }
 
@@ -52,6 +60,16 @@ func (h *SubmitHandler) HandleSubmit(c *gin.Context) {
 		}
 	}
 
+	// 过滤机器人提交（先于人机验证：检查页面原样重新提交人机验证字段）
+	if !h.checkBotFilter(c, params) {
+		return
+	}
+
+	// 命中下单风控规则时先完成人机验证
+	if !h.checkCaptcha(c, params) {
+		return
+	}
+
 	// 设置默认签名类型
 	if params["sign_type"] == "" {
 		params["sign_type"] = "MD5"
@@ -60,47 +78,37 @@ func (h *SubmitHandler) HandleSubmit(c *gin.Context) {
 	// 获取基础URL
 	baseURL := utils.GetBaseURL(c, h.cfg.Server.BaseURL)
 
-	// 创建支付
-	result, err := h.codepay.CreatePayment(params, baseURL)
+	// 创建支付（页面跳转下单，请求来源即买家）
+	result, err := h.codepay.CreatePayment(params, baseURL, c.ClientIP())
 	if err != nil {
 		logger.Error("Failed to create payment", zap.Error(err))
 		h.renderError(c, err.Error())
 		return
 	}
 
+	// 手机网站支付：直接跳转支付宝收银台
+	if getBool(result, "wap_mode") {
+		c.Redirect(http.StatusFound, getString(result, "payment_url"))
+		return
+	}
+
 	// 渲染支付页面
-	h.renderPaymentPage(c, result, params)
+	h.renderPaymentPage(c, result)
 }
 
-// renderPaymentPage 渲染支付页面
-func (h *SubmitHandler) renderPaymentPage(c *gin.Context, result map[string]interface{}, params map[string]string) {
-	// 准备模板数据
-	templateData := gin.H{
-		// 基本信息
-		"PID":        params["pid"],
-		"OutTradeNo": params["out_trade_no"],
-		"Name":       params["name"],
-		"SiteName":   params["sitename"],
-		"ReturnURL":  params["return_url"],
-
-		// 支付信息
-		"TradeNo":       getString(result, "trade_no"),
-		"PaymentAmount": getFloat(result, "payment_amount"),
-		"PaymentURL":    getString(result, "payment_url"),
-		"QrCode":        getString(result, "qr_code"),
-		"QrCodeURL":     getString(result, "qr_code_url"),
-		"QRCodeID":      h.cfg.Payment.BusinessQRMode.QRCodeID, // 支付宝收款码ID（用于拉起APP）
-		"CreateTime":    getString(result, "create_time"),      // 订单创建时间
-
-		// 模式和提示
-		"BusinessQrMode": getBool(result, "business_qr_mode"),
-		"AmountAdjusted": getBool(result, "amount_adjusted"),
-		"AdjustmentNote": getString(result, "adjustment_note"),
-		"PaymentTips":    getSlice(result, "payment_tips"),
+// renderPaymentPage 渲染支付页面（页面数据与 GET /api/pay/order 返回的结构相同）
+func (h *SubmitHandler) renderPaymentPage(c *gin.Context, result map[string]interface{}) {
+	tradeNo := getString(result, "trade_no")
+	order, err := h.codepay.GetOrder(tradeNo)
+	if err != nil || order == nil {
+		logger.Error("Failed to load created order", zap.String("trade_no", tradeNo), zap.Error(err))
+		h.renderError(c, i18n.T(requestLang(c), "error.order_expired"))
+		return
 	}
 
-	// 渲染模板
-	c.HTML(http.StatusOK, "submit.html", templateData)
+	c.HTML(http.StatusOK, "submit.html", gin.H{
+		"View": newPayView(h.cfg, h.qrCodes, order, result, requestLang(c)),
+	})
 }
 
 // 辅助函数：安全获取字符串
@@ -123,23 +131,6 @@ func getBool(m map[string]interface{}, key string) bool {
 	return false
 }
 
-// 辅助函数：安全获取浮点数
-func getFloat(m map[string]interface{}, key string) float64 {
-	if v, ok := m[key]; ok {
-		switch val := v.(type) {
-		case float64:
-			return val
-		case float32:
-			return float64(val)
-		case int:
-			return float64(val)
-		case int64:
-			return float64(val)
-		}
-	}
-	return 0.0
-}
-
 // 辅助函数：安全获取切片
 func getSlice(m map[string]interface{}, key string) []string {
 	if v, ok := m[key]; ok {
@@ -153,6 +144,7 @@ func getSlice(m map[string]interface{}, key string) []string {
 // renderError 渲染错误页面
 func (h *SubmitHandler) renderError(c *gin.Context, errorMsg string) {
 	c.HTML(http.StatusOK, "error.html", gin.H{
+		"Lang":  requestLang(c),
 		"error": errorMsg,
 	})
 }
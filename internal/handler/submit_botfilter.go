@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"alimpay-go/internal/pkg/botfilter"
+	"alimpay-go/internal/pkg/i18n"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// botFilterFields 机器人过滤随表单提交的字段
+var botFilterFields = []string{botfilter.FieldToken, botfilter.FieldNonce, botfilter.FieldHoneypot}
+
+// SetBotFilter 设置下单机器人过滤（浏览器需先自动完成工作量证明才会创建订单）
+func (h *SubmitHandler) SetBotFilter(filter *botfilter.Filter) {
+	h.botFilter = filter
+}
+
+// checkBotFilter 校验机器人过滤字段
+// 过滤字段不参与签名，先从下单参数中去除；未携带或未通过工作量证明时渲染检查页面（由页面脚本计算后自动重新提交原参数），
+// 填写了蜜罐字段时直接拒绝；返回false表示已响应，不创建订单
+func (h *SubmitHandler) checkBotFilter(c *gin.Context, params map[string]string) bool {
+	if h.botFilter == nil {
+		return true
+	}
+
+	form := make(map[string]string)
+	for _, field := range botFilterFields {
+		if value, ok := params[field]; ok {
+			form[field] = value
+			delete(params, field)
+		}
+	}
+
+	ip := c.ClientIP()
+	err := h.botFilter.Verify(ip, form)
+	if err == nil {
+		return true
+	}
+
+	lang := requestLang(c)
+	switch {
+	case errors.Is(err, botfilter.ErrHoneypot):
+		logger.Warn("Submit rejected by bot filter",
+			zap.String("ip", ip),
+			zap.String("out_trade_no", params["out_trade_no"]),
+			zap.Error(err))
+		h.renderError(c, i18n.T(lang, "botcheck.rejected"))
+		return false
+	case !errors.Is(err, botfilter.ErrMissingToken):
+		logger.Warn("Submit bot check failed",
+			zap.String("ip", ip),
+			zap.String("out_trade_no", params["out_trade_no"]),
+			zap.Error(err))
+	}
+
+	c.HTML(http.StatusOK, "botcheck.html", gin.H{
+		"Lang":      lang,
+		"Action":    c.Request.URL.Path,
+		"Params":    params,
+		"Challenge": h.botFilter.Challenge(ip),
+	})
+	return false
+}
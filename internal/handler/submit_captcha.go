@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/pkg/captcha"
+	"alimpay-go/internal/pkg/i18n"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SetCaptcha 设置下单人机验证（命中下单风控规则的请求需先完成验证才会创建订单）
+func (h *SubmitHandler) SetCaptcha(provider captcha.Provider) {
+	h.captcha = provider
+}
+
+// checkCaptcha 命中下单风控规则时校验人机验证结果
+// 验证组件提交的字段不参与签名，先从下单参数中去除；未通过验证时渲染验证页面（原参数作为隐藏字段重新提交）并返回false
+func (h *SubmitHandler) checkCaptcha(c *gin.Context, params map[string]string) bool {
+	if h.captcha == nil {
+		return true
+	}
+
+	form := make(map[string]string)
+	for _, field := range h.captcha.Fields() {
+		if value, ok := params[field]; ok {
+			form[field] = value
+			delete(params, field)
+		}
+	}
+
+	ip := c.ClientIP()
+	reason := h.codepay.SubmitRiskReason(ip)
+	if reason == "" {
+		return true
+	}
+
+	failed := false
+	if len(form) > 0 {
+		passed, err := h.captcha.Verify(ip, form)
+		if err != nil {
+			logger.Error("Failed to verify submit captcha", zap.String("provider", h.captcha.Name()), zap.Error(err))
+		}
+		if passed {
+			logger.Info("Submit captcha passed", zap.String("ip", ip), zap.String("reason", reason))
+			return true
+		}
+		failed = true
+		logger.Warn("Submit captcha failed",
+			zap.String("ip", ip),
+			zap.String("reason", reason),
+			zap.String("out_trade_no", params["out_trade_no"]))
+	} else {
+		logger.Info("Submit requires captcha",
+			zap.String("ip", ip),
+			zap.String("reason", reason),
+			zap.String("out_trade_no", params["out_trade_no"]))
+	}
+
+	lang := requestLang(c)
+	challenge, err := h.captcha.Challenge(ip)
+	if err != nil {
+		logger.Error("Failed to create submit captcha", zap.String("provider", h.captcha.Name()), zap.Error(err))
+		h.renderError(c, i18n.T(lang, "captcha.unavailable"))
+		return false
+	}
+
+	c.HTML(http.StatusOK, "captcha.html", gin.H{
+		"Lang":      lang,
+		"Action":    c.Request.URL.Path,
+		"Params":    params,
+		"Challenge": challenge,
+		"Failed":    failed,
+	})
+	return false
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HandleVerifyNotify 验证支付结果通知 GET/POST /api/verify_notify
+// 商户服务端将收到的异步通知（或同步跳转）参数原样提交，确认签名正确且与已支付订单一致；
+// 响应附带按收到的参数生成的待签名字符串，便于对照排查验签实现；
+// 需先通过商户认证（签名错误时用 key 参数或 Bearer 令牌认证），且只能验证本商户的通知
+func (h *YiPayHandler) HandleVerifyNotify(c *gin.Context) {
+	// 收集全部参数（多出的参数同样参与签名，需一并检查）
+	params := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "Invalid form data"})
+		return
+	}
+	for key, values := range c.Request.PostForm {
+		if len(values) > 0 && params[key] == "" {
+			params[key] = values[0]
+		}
+	}
+
+	// 商户已由认证中间件验证；key 参数只用于认证，不属于通知参数
+	auth := middleware.GetMerchantAuth(c)
+	if auth.Method == middleware.AuthMethodKey {
+		delete(params, "key")
+	}
+	if params["pid"] != auth.MerchantID {
+		c.JSON(http.StatusOK, gin.H{"code": -1, "msg": "pid does not match authenticated merchant"})
+		return
+	}
+
+	result, err := h.codepay.VerifyNotification(params)
+	if err != nil {
+		logger.Error("Failed to verify notification",
+			zap.String("trade_no", params["trade_no"]),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "msg": "Failed to verify notification"})
+		return
+	}
+
+	code, msg := 1, "Notification authentic"
+	if !result.Valid {
+		code, msg = -1, "Notification not authentic"
+		logger.Info("Notification verification failed",
+			zap.String("trade_no", params["trade_no"]),
+			zap.Strings("errors", result.Errors),
+			zap.String("ip", c.ClientIP()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":         code,
+		"msg":          msg,
+		"valid":        result.Valid,
+		"sign_valid":   result.SignValid,
+		"order_match":  result.OrderMatch,
+		"sign_content": result.SignContent,
+		"errors":       result.Errors,
+	})
+}
@@ -8,6 +8,7 @@ Description: 提供WebSocket连接管理，用于实时推送订单支付状态
   - 实时推送订单状态变化
   - 替代HTTP轮询，降低客户端压力
   - 支持多客户端订阅同一订单
+  - 心跳间隔、读取超时、总连接数和单订单订阅数上限可配置（websocket 配置段）
 
 连接流程:
  1. 客户端通过 /ws/order?order_id=xxx 建立连接
@@ -28,11 +29,14 @@ Description: 提供WebSocket连接管理，用于实时推送订单支付状态
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
+	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
 	"alimpay-go/internal/events"
 	"alimpay-go/internal/model"
@@ -48,14 +52,18 @@ WebSocketHandler WebSocket处理器
 功能: 管理WebSocket连接和消息推送
 字段:
   - db: 数据库实例
+  - cfg: 心跳和连接数配置
   - upgrader: WebSocket升级器
   - subscribers: 订单订阅者映射表 (order_id -> []*websocket.Conn)
-  - mu: 读写锁，保护subscribers
+  - total: 当前连接总数
+  - mu: 读写锁，保护subscribers和total
 */
 type WebSocketHandler struct {
 	db          *database.DB
+	cfg         config.WebSocketConfig
 	upgrader    websocket.Upgrader
 	subscribers map[string][]*websocket.Conn // order_id -> connections
+	total       int
 	mu          sync.RWMutex
 }
 
@@ -81,13 +89,15 @@ type OrderStatusMessage struct {
 NewWebSocketHandler 创建WebSocket处理器
 参数:
   - db: 数据库实例
+  - cfg: WebSocket配置（心跳间隔、读取超时、连接数上限）
 
 返回:
   - *WebSocketHandler: WebSocket处理器实例
 */
-func NewWebSocketHandler(db *database.DB) *WebSocketHandler {
+func NewWebSocketHandler(db *database.DB, cfg config.WebSocketConfig) *WebSocketHandler {
 	handler := &WebSocketHandler{
-		db: db,
+		db:  db,
+		cfg: cfg,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -133,6 +143,16 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
+	// 超过连接数上限时在升级前拒绝（客户端改为轮询）
+	if err := h.checkLimits(orderID); err != nil {
+		logger.Warn("WebSocket connection rejected",
+			zap.String("order_id", orderID),
+			zap.String("remote_addr", c.ClientIP()),
+			zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 升级为WebSocket连接
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -146,8 +166,17 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		zap.String("order_id", orderID),
 		zap.String("remote_addr", c.ClientIP()))
 
-	// 添加到订阅列表
-	h.subscribe(orderID, conn)
+	// 添加到订阅列表（并发连接可能在检查后达到上限）
+	if err := h.subscribe(orderID, conn); err != nil {
+		logger.Warn("WebSocket connection rejected",
+			zap.String("order_id", orderID),
+			zap.String("remote_addr", c.ClientIP()),
+			zap.Error(err))
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error())
+		_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
 
 	// 发送初始状态
 	h.sendInitialStatus(conn, orderID)
@@ -174,21 +203,23 @@ func (h *WebSocketHandler) handleConnection(conn *websocket.Conn, orderID string
 		logger.Info("WebSocket disconnected", zap.String("order_id", orderID))
 	}()
 
+	readTimeout := time.Duration(h.cfg.ReadTimeout) * time.Second
+
 	// 设置读取超时
-	if err := conn.SetReadDeadline(time.Now().Add(60 * time.Second)); err != nil {
+	if err := conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
 		logger.Error("Failed to set read deadline", zap.Error(err))
 	}
 
 	// 设置pong处理器
 	conn.SetPongHandler(func(string) error {
-		if err := conn.SetReadDeadline(time.Now().Add(60 * time.Second)); err != nil {
+		if err := conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
 			logger.Error("Failed to set read deadline in pong handler", zap.Error(err))
 		}
 		return nil
 	})
 
 	// 启动心跳发送
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(time.Duration(h.cfg.PingInterval) * time.Second)
 	defer ticker.Stop()
 
 	done := make(chan struct{})
@@ -210,7 +241,7 @@ func (h *WebSocketHandler) handleConnection(conn *websocket.Conn, orderID string
 		case <-done:
 			return
 		case <-ticker.C:
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := h.writeMessage(conn, websocket.PingMessage, nil); err != nil {
 				return
 			}
 		}
@@ -239,11 +270,25 @@ func (h *WebSocketHandler) sendInitialStatus(conn *websocket.Conn, orderID strin
 	}
 
 	data, _ := json.Marshal(message)
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+	if err := h.writeMessage(conn, websocket.TextMessage, data); err != nil {
 		logger.Error("Failed to write message to websocket", zap.Error(err))
 	}
 }
 
+/*
+writeMessage 向连接写入消息
+功能: 连接不支持并发写，初始状态、心跳与广播、重连通知（持有 h.mu 写入）互斥
+参数:
+  - conn: WebSocket连接
+  - messageType: 消息类型
+  - data: 消息内容
+*/
+func (h *WebSocketHandler) writeMessage(conn *websocket.Conn, messageType int, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return conn.WriteMessage(messageType, data)
+}
+
 /*
 BroadcastOrderUpdate 广播订单状态更新
 功能: 当订单状态变化时，通知所有订阅者
@@ -290,25 +335,61 @@ func (h *WebSocketHandler) BroadcastOrderUpdate(order *model.Order) {
 		}
 	}
 	// 更新有效连接列表
+	h.total -= len(connections) - len(validConns)
 	h.subscribers[order.ID] = validConns
 	h.mu.Unlock()
 }
 
+/*
+checkLimits 检查连接数上限
+参数:
+  - orderID: 订单号
+
+返回:
+  - error: 已达到总连接数或该订单订阅数上限时返回错误
+*/
+func (h *WebSocketHandler) checkLimits(orderID string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.checkLimitsLocked(orderID)
+}
+
+// checkLimitsLocked 检查连接数上限（调用方需持有锁）
+func (h *WebSocketHandler) checkLimitsLocked(orderID string) error {
+	if h.cfg.MaxConnections > 0 && h.total >= h.cfg.MaxConnections {
+		return fmt.Errorf("too many websocket connections (max %d)", h.cfg.MaxConnections)
+	}
+	if h.cfg.MaxPerOrder > 0 && len(h.subscribers[orderID]) >= h.cfg.MaxPerOrder {
+		return fmt.Errorf("too many connections for this order (max %d)", h.cfg.MaxPerOrder)
+	}
+	return nil
+}
+
 /*
 subscribe 订阅订单状态更新
 功能: 将WebSocket连接添加到订阅列表
 参数:
   - orderID: 订单号
   - conn: WebSocket连接
+
+返回:
+  - error: 已达到连接数上限时返回错误，连接未加入订阅列表
 */
-func (h *WebSocketHandler) subscribe(orderID string, conn *websocket.Conn) {
+func (h *WebSocketHandler) subscribe(orderID string, conn *websocket.Conn) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if err := h.checkLimitsLocked(orderID); err != nil {
+		return err
+	}
+
 	h.subscribers[orderID] = append(h.subscribers[orderID], conn)
+	h.total++
 	logger.Info("Subscribed to order",
 		zap.String("order_id", orderID),
 		zap.Int("total_subscribers", len(h.subscribers[orderID])))
+	return nil
 }
 
 /*
@@ -326,6 +407,7 @@ func (h *WebSocketHandler) unsubscribe(orderID string, conn *websocket.Conn) {
 	for i, c := range connections {
 		if c == conn {
 			h.subscribers[orderID] = append(connections[:i], connections[i+1:]...)
+			h.total--
 			break
 		}
 	}
@@ -336,6 +418,86 @@ func (h *WebSocketHandler) unsubscribe(orderID string, conn *websocket.Conn) {
 	}
 }
 
+// websocketDrainPoll 等待WebSocket连接断开时检查连接数的间隔
+const websocketDrainPoll = 100 * time.Millisecond
+
+/*
+Reconnect 通知全部连接重连（平滑升级后新进程已在接受连接）
+功能: 发送 reconnect 消息，支付页面先连接新进程再关闭当前连接，订阅不中断
+*/
+func (h *WebSocketHandler) Reconnect() {
+	data, err := json.Marshal(gin.H{"type": "reconnect", "timestamp": time.Now().Unix()})
+	if err != nil {
+		return
+	}
+
+	// 与 BroadcastOrderUpdate 相同，持有锁写入避免并发写同一连接
+	h.mu.Lock()
+	count := 0
+	for _, conns := range h.subscribers {
+		for _, conn := range conns {
+			if err := conn.WriteMessage(websocket.TextMessage, data); err == nil {
+				count++
+			}
+		}
+	}
+	h.mu.Unlock()
+	logger.Info("WebSocket connections asked to reconnect", zap.Int("connections", count))
+}
+
+/*
+Drain 等待全部连接断开
+参数:
+  - ctx: 等待期限
+
+返回:
+  - int: 期限到达时仍未断开的连接数
+*/
+func (h *WebSocketHandler) Drain(ctx context.Context) int {
+	return waitDrained(ctx, func() int {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return h.total
+	})
+}
+
+// waitDrained 等待连接数降为0或期限到达，返回剩余连接数
+func waitDrained(ctx context.Context, count func() int) int {
+	ticker := time.NewTicker(websocketDrainPoll)
+	defer ticker.Stop()
+
+	for {
+		remaining := count()
+		if remaining == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return remaining
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+CloseAll 通知全部连接服务即将重启
+功能: 发送关闭帧（1012 Service Restart），支付页面收到后自动重连到新进程
+*/
+func (h *WebSocketHandler) CloseAll() {
+	h.mu.RLock()
+	connections := make([]*websocket.Conn, 0, h.total)
+	for _, conns := range h.subscribers {
+		connections = append(connections, conns...)
+	}
+	h.mu.RUnlock()
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	for _, conn := range connections {
+		_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+	}
+	logger.Info("WebSocket connections notified of restart", zap.Int("connections", len(connections)))
+}
+
 /*
 formatPayTime 格式化支付时间
 参数:
@@ -359,13 +521,10 @@ func (h *WebSocketHandler) GetStats() map[string]interface{} {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	totalConnections := 0
-	for _, conns := range h.subscribers {
-		totalConnections += len(conns)
-	}
-
 	return map[string]interface{}{
 		"total_subscribed_orders": len(h.subscribers),
-		"total_connections":       totalConnections,
+		"total_connections":       h.total,
+		"max_connections":         h.cfg.MaxConnections,
+		"max_per_order":           h.cfg.MaxPerOrder,
 	}
 }
@@ -1,13 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
+	"alimpay-go/internal/middleware"
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/service"
+	"alimpay-go/internal/pkg/i18n"
 	"alimpay-go/internal/pkg/logger"
 	"alimpay-go/internal/pkg/utils"
 
@@ -111,10 +114,14 @@ func (h *YiPayHandler) HandleQueryOrder(c *gin.Context) {
 		"type":         order.Type,
 		"pid":          order.PID,
 		"name":         order.Name,
-		"money":        utils.FormatAmount(order.Price),
+		"money":        order.Price.String(),
 		"addtime":      order.AddTime.Format("2006-01-02 15:04:05"),
 		"endtime":      "",
 		"status":       order.Status, // 0=待支付, 1=已支付
+		// 账单匹配到的支付宝交易号（手动标记等情况为空）
+		"alipay_trade_no": order.AlipayTradeNo,
+		// 争议订单（拒付/投诉），争议期间不发送异步通知
+		"disputed": order.Disputed,
 	}
 
 	if order.PayTime != nil {
@@ -126,24 +133,9 @@ func (h *YiPayHandler) HandleQueryOrder(c *gin.Context) {
 
 // handleQueryOrders 查询订单列表
 func (h *YiPayHandler) handleQueryOrders(c *gin.Context) {
-	pid := h.getParam(c, "pid")
-	key := h.getParam(c, "key")
-
-	if pid == "" || key == "" {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Missing required parameters: pid, key",
-		})
-		return
-	}
-
-	// 验证商户
-	merchantInfo := h.codepay.GetMerchantInfo()
-	if pid != merchantInfo["id"].(string) || key != merchantInfo["key"].(string) {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Invalid merchant credentials",
-		})
+	// 验证商户（由认证中间件完成）
+	if err := merchantAuthError(c); err != nil {
+		respondCredentialError(c, err)
 		return
 	}
 
@@ -166,7 +158,7 @@ func (h *YiPayHandler) handleQueryOrders(c *gin.Context) {
 			"out_trade_no": order.OutTradeNo,
 			"type":         order.Type,
 			"name":         order.Name,
-			"money":        utils.FormatAmount(order.Price),
+			"money":        order.Price.String(),
 			"addtime":      order.AddTime.Format("2006-01-02 15:04:05"),
 			"status":       order.Status,
 		}
@@ -189,7 +181,7 @@ func (h *YiPayHandler) HandleSubmitAPI(c *gin.Context) {
 	// 获取所有参数
 	params := make(map[string]string)
 	fields := []string{"pid", "type", "out_trade_no", "notify_url", "return_url",
-		"name", "money", "price", "sitename", "sign", "sign_type", "param"}
+		"name", "money", "price", "sitename", "sign", "sign_type", "param", "device", "lang", "auto_close", "clientip"}
 
 	for _, field := range fields {
 		params[field] = h.getParam(c, field)
@@ -220,7 +212,7 @@ func (h *YiPayHandler) HandleSubmitAPI(c *gin.Context) {
 			zap.String("out_trade_no", params["out_trade_no"]))
 		c.JSON(http.StatusOK, gin.H{
 			"code": -1,
-			"msg":  "签名验证失败",
+			"msg":  i18n.T(i18n.Lang(params["lang"]), "api.invalid_sign"),
 		})
 		return
 	}
@@ -229,7 +221,7 @@ func (h *YiPayHandler) HandleSubmitAPI(c *gin.Context) {
 	baseURL := utils.GetBaseURL(c, h.cfg.Server.BaseURL)
 
 	// 创建订单
-	result, err := h.codepay.CreatePayment(params, baseURL)
+	result, err := h.codepay.CreatePayment(params, baseURL, params["clientip"])
 	if err != nil {
 		logger.Error("Failed to create payment", zap.Error(err))
 		c.JSON(http.StatusOK, gin.H{
@@ -245,11 +237,11 @@ func (h *YiPayHandler) HandleSubmitAPI(c *gin.Context) {
 
 // HandleClose 关闭订单
 func (h *YiPayHandler) HandleClose(c *gin.Context) {
-	pid := h.getParam(c, "pid")
-	key := h.getParam(c, "key")
+	// 商户已由认证中间件验证
+	pid := middleware.GetMerchantAuth(c).MerchantID
 	outTradeNo := h.getParam(c, "out_trade_no")
 
-	if pid == "" || key == "" || outTradeNo == "" {
+	if outTradeNo == "" {
 		c.JSON(http.StatusOK, gin.H{
 			"code": -1,
 			"msg":  "Missing required parameters",
@@ -257,61 +249,35 @@ func (h *YiPayHandler) HandleClose(c *gin.Context) {
 		return
 	}
 
-	// 验证商户
-	merchantInfo := h.codepay.GetMerchantInfo()
-	if pid != merchantInfo["id"].(string) || key != merchantInfo["key"].(string) {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Invalid merchant credentials",
-		})
-		return
-	}
-
-	// 查询订单（注意参数顺序：outTradeNo, pid）
-	order, err := h.db.GetOrderByOutTradeNo(outTradeNo, pid)
-	if err != nil || order == nil {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Order not found",
-		})
-		return
-	}
-
-	// 检查订单状态
-	if order.Status == model.OrderStatusPaid {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Order already paid, cannot close",
-		})
-		return
-	}
-
 	// 关闭订单
-	err = h.db.UpdateOrderStatus(order.ID, model.OrderStatusClosed, time.Now())
-	if err != nil {
-		logger.Error("Failed to close order", zap.Error(err))
+	if _, err := h.codepay.CloseOrder(pid, outTradeNo); err != nil {
+		msg := "Failed to close order"
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			msg = "Order not found"
+		case errors.Is(err, service.ErrOrderAlreadyPaid):
+			msg = "Order already paid, cannot close"
+		default:
+			logger.Error("Failed to close order", zap.Error(err))
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"code": -1,
-			"msg":  "Failed to close order",
+			"msg":  msg,
 		})
 		return
 	}
 
-	logger.Info("Order closed",
-		zap.String("trade_no", order.ID),
-		zap.String("out_trade_no", outTradeNo))
-
 	c.JSON(http.StatusOK, gin.H{
 		"code": 1,
 		"msg":  "Order closed successfully",
 	})
 }
 
-// HandleRefund 退款接口（仅返回提示）
+// HandleRefund 退款接口（不支持自动退款，仅返回提示；退款申请见 HandleRefundRequest）
 func (h *YiPayHandler) HandleRefund(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code": -1,
-		"msg":  "Refund is not supported, please process manually via Alipay",
+		"msg":  "Automatic refund is not supported, please submit a refund request via /api/refund_request",
 	})
 }
 
@@ -326,27 +292,9 @@ func (h *YiPayHandler) getParam(c *gin.Context, key string) string {
 
 // HandleQueryMerchant 查询商户信息
 func (h *YiPayHandler) HandleQueryMerchant(c *gin.Context) {
-	pid := h.getParam(c, "pid")
-	key := h.getParam(c, "key")
-
-	if pid == "" || key == "" {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Missing required parameters: pid, key",
-		})
-		return
-	}
-
+	// 商户已由认证中间件验证
 	merchantInfo := h.codepay.GetMerchantInfo()
 
-	if pid != merchantInfo["id"].(string) || key != merchantInfo["key"].(string) {
-		c.JSON(http.StatusOK, gin.H{
-			"code": -1,
-			"msg":  "Invalid merchant credentials",
-		})
-		return
-	}
-
 	// 返回易支付标准格式
 	c.JSON(http.StatusOK, gin.H{
 		"code":     1,
@@ -413,8 +361,7 @@ func (h *YiPayHandler) HandleCallback(c *gin.Context) {
 	}
 
 	// 更新订单状态
-	payTime := time.Now()
-	err = h.db.UpdateOrderStatus(order.ID, model.OrderStatusPaid, payTime)
+	err = h.codepay.OrderStates().MarkPaid(order, model.OrderEventMarkedPaid, "支付回调确认")
 	if err != nil {
 		logger.Error("Failed to update order status", zap.Error(err))
 		c.String(http.StatusOK, "fail")
@@ -427,13 +374,7 @@ func (h *YiPayHandler) HandleCallback(c *gin.Context) {
 
 	// 发送商户回调
 	if order.NotifyURL != "" {
-		go func() {
-			if err := h.codepay.SendNotification(order); err != nil {
-				logger.Error("Failed to send notification",
-					zap.String("trade_no", order.ID),
-					zap.Error(err))
-			}
-		}()
+		_ = h.codepay.QueueNotification(order)
 	}
 
 	c.String(http.StatusOK, "success")
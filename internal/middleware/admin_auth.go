@@ -8,52 +8,134 @@ Description: 提供管理后台的认证和鉴权功能
   - 登录验证
   - 访问控制
   - 操作日志
+  - Session令牌HMAC签名（签名密钥持久化，支持轮换）
+  - 记住我（独立的刷新令牌Cookie，过期后自动续期session）
+  - 活跃会话列表与单个会话注销
+  - 会话持久化（内存、数据库或Redis，见 SessionStore）
+  - 登录防暴力破解（失败锁定、验证码，见 LoginGuard）
+  - 两步验证（TOTP动态验证码、恢复码，见 TwoFactor）
+  - 管理员账号与角色（用户名密码登录，按角色限制操作，见 RequireRole）
+  - API令牌（Authorization: Bearer 调用管理接口，按权限范围限制操作，见 AdminTokenStore）
 */
 package middleware
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/geoip"
 	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// Cookie名称
+const (
+	sessionCookieName = "admin_session"
+	refreshCookieName = "admin_refresh"
+)
+
+// 默认会话时长
+const (
+	DefaultSessionLifetime  = 24 * time.Hour
+	DefaultSessionIdle      = 24 * time.Hour
+	DefaultRememberLifetime = 30 * 24 * time.Hour
+)
+
+// sessionTouchInterval 最后访问时间写入存储的最小间隔（避免每个请求都写存储）
+const sessionTouchInterval = time.Minute
+
+/*
+SecretStore session签名密钥存储
+功能: 持久化签名密钥，重启后已签发的令牌仍可校验
+*/
+type SecretStore interface {
+	GetSetting(key string) (string, error)
+	SetSetting(key, value string) error
+}
+
+/*
+SessionOptions 会话时长配置
+字段:
+  - Lifetime: session最长有效期
+  - IdleTimeout: 无操作超时时间
+  - RememberLifetime: "记住我"刷新令牌有效期
+*/
+type SessionOptions struct {
+	Lifetime         time.Duration
+	IdleTimeout      time.Duration
+	RememberLifetime time.Duration
+}
+
 /*
 AdminAuthMiddleware 管理员认证中间件配置
 字段:
   - merchantID: 商户ID
   - merchantKey: 商户密钥
-  - sessions: session存储
-  - mu: 读写锁
+  - secret: session令牌签名密钥
+  - store: 签名密钥存储（可为nil，此时密钥仅保存在内存中）
+  - options: 会话时长配置
+  - sessions: session和刷新令牌存储
+  - guard: 登录保护（可为nil，此时不限制登录尝试）
+  - twoFactor: 两步验证（账号开启后登录需输入动态验证码，见 SetTwoFactorStore）
+  - users: 管理员账号存储（可为nil，此时只能使用商户ID和密钥登录）
+  - tokens: API令牌存储（可为nil，此时不接受API令牌）
+  - mu: 读写锁（保护签名密钥和商户密钥）
 */
 type AdminAuthMiddleware struct {
 	merchantID  string
 	merchantKey string
-	sessions    map[string]*Session
+	secret      []byte
+	store       SecretStore
+	options     SessionOptions
+	sessions    SessionStore
+	guard       *LoginGuard
+	twoFactor   *TwoFactor
+	users       AdminUserStore
+	tokens      AdminTokenStore
 	mu          sync.RWMutex
 }
 
 /*
-Session 会话信息
-字段:
-  - Token: 会话令牌
-  - MerchantID: 商户ID
-  - CreatedAt: 创建时间
-  - LastAccess: 最后访问时间
-  - IP: 客户端IP
+Session 会话信息（字段见 model.AdminSession）
+*/
+type Session = model.AdminSession
+
+/*
+RefreshToken 刷新令牌（记住我，字段见 model.AdminRefreshToken）
+*/
+type RefreshToken = model.AdminRefreshToken
+
+/*
+SessionInfo 会话展示信息
 */
-type Session struct {
-	Token      string
-	MerchantID string
-	CreatedAt  time.Time
-	LastAccess time.Time
-	IP         string
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	IP         string    `json:"ip"`
+	Location   string    `json:"location,omitempty"` // IP归属地（未启用IP库时为空）
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastAccess time.Time `json:"last_access"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Remember   bool      `json:"remember"`
+	Current    bool      `json:"current"`
 }
 
 /*
@@ -61,21 +143,66 @@ NewAdminAuthMiddleware 创建管理员认证中间件
 参数:
   - merchantID: 商户ID
   - merchantKey: 商户密钥
+  - store: 签名密钥存储
+  - sessions: 会话存储（为nil时使用内存存储，重启后需重新登录）
+  - options: 会话时长配置（零值使用默认值）
 
 返回:
   - *AdminAuthMiddleware: 认证中间件实例
+  - error: 无法生成session签名密钥时返回错误
 */
-func NewAdminAuthMiddleware(merchantID, merchantKey string) *AdminAuthMiddleware {
+func NewAdminAuthMiddleware(merchantID, merchantKey string, store SecretStore, sessions SessionStore, options SessionOptions) (*AdminAuthMiddleware, error) {
+	if options.Lifetime <= 0 {
+		options.Lifetime = DefaultSessionLifetime
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = DefaultSessionIdle
+	}
+	if options.RememberLifetime <= 0 {
+		options.RememberLifetime = DefaultRememberLifetime
+	}
+	if sessions == nil {
+		sessions = NewMemorySessionStore()
+	}
+
 	middleware := &AdminAuthMiddleware{
 		merchantID:  merchantID,
 		merchantKey: merchantKey,
-		sessions:    make(map[string]*Session),
+		store:       store,
+		options:     options,
+		sessions:    sessions,
+		twoFactor:   NewTwoFactor(nil, merchantID),
+	}
+
+	// 加载或生成session签名密钥
+	secret, err := middleware.loadSecret()
+	if err != nil {
+		return nil, err
 	}
+	middleware.secret = secret
 
 	// 启动session清理任务
 	go middleware.cleanupExpiredSessions()
 
-	return middleware
+	return middleware, nil
+}
+
+/*
+SetLoginGuard 设置登录保护
+参数:
+  - guard: 登录保护（失败锁定、验证码）
+*/
+func (m *AdminAuthMiddleware) SetLoginGuard(guard *LoginGuard) {
+	m.guard = guard
+}
+
+/*
+LoginGuard 获取登录保护
+返回:
+  - *LoginGuard: 登录保护（未设置时为nil）
+*/
+func (m *AdminAuthMiddleware) LoginGuard() *LoginGuard {
+	return m.guard
 }
 
 /*
@@ -86,30 +213,39 @@ RequireAuth 要求认证的中间件
 */
 func (m *AdminAuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 检查session cookie
-		token, err := c.Cookie("admin_session")
-		if err != nil || token == "" {
-			// 未登录，重定向到登录页
-			c.Redirect(http.StatusFound, "/admin/login")
-			c.Abort()
+		// 自动化程序使用API令牌（不使用session）
+		if token := bearerToken(c); token != "" {
+			m.authenticateAPIToken(c, token)
 			return
 		}
 
-		// 验证session
-		session := m.getSession(token)
+		// 检查session cookie
+		var session *Session
+		if token, err := c.Cookie(sessionCookieName); err == nil && token != "" {
+			session = m.getSession(token)
+		}
+
+		// session无效时尝试使用刷新令牌续期（记住我）
 		if session == nil {
-			// session无效
-			c.SetCookie("admin_session", "", -1, "/", "", false, true)
+			session = m.refreshSession(c)
+		}
+
+		if session == nil {
+			// 未登录或session无效，重定向到登录页
+			c.SetCookie(sessionCookieName, "", -1, "/", "", false, true)
 			c.Redirect(http.StatusFound, "/admin/login")
 			c.Abort()
 			return
 		}
 
 		// 更新最后访问时间
-		m.updateSessionAccess(token, c.ClientIP())
+		m.updateSessionAccess(session, c.ClientIP())
 
 		// 设置上下文
 		c.Set("admin_merchant_id", session.MerchantID)
+		c.Set("admin_session_id", session.ID)
+		c.Set("admin_username", session.Username)
+		c.Set("admin_role", sessionRole(session))
 		c.Set("admin_logged_in", true)
 
 		c.Next()
@@ -120,12 +256,15 @@ func (m *AdminAuthMiddleware) RequireAuth() gin.HandlerFunc {
 HandleLogin 处理登录请求
 POST /admin/login
 参数:
-  - pid: 商户ID
-  - key: 商户密钥
+  - pid: 商户ID或管理员用户名
+  - key: 商户密钥或管理员密码
+  - remember: 记住我（可选）
+  - captcha_id, captcha: 验证码ID和答案（连续登录失败后必填）
+  - two_factor, otp: 两步验证令牌和动态验证码或恢复码（已开启两步验证时，凭据通过后的第二步提交）
 */
 func (m *AdminAuthMiddleware) HandleLogin(c *gin.Context) {
 	// 已登录用户跳转到后台
-	if token, err := c.Cookie("admin_session"); err == nil && token != "" {
+	if token, err := c.Cookie(sessionCookieName); err == nil && token != "" {
 		if session := m.getSession(token); session != nil {
 			c.Redirect(http.StatusFound, "/admin/dashboard")
 			return
@@ -134,45 +273,220 @@ func (m *AdminAuthMiddleware) HandleLogin(c *gin.Context) {
 
 	// GET请求显示登录页面
 	if c.Request.Method == "GET" {
-		c.HTML(http.StatusOK, "admin_login.html", gin.H{
-			"error": c.Query("error"),
-		})
+		m.renderLogin(c, http.StatusOK, c.Query("error"))
 		return
 	}
 
 	// POST请求处理登录
+	ip := c.ClientIP()
 	pid := c.PostForm("pid")
 	key := c.PostForm("key")
+	remember := c.PostForm("remember") != ""
+
+	// 锁定期间拒绝登录（不校验凭据）
+	if m.guard != nil {
+		if remaining := m.guard.Locked(ip); remaining > 0 {
+			m.renderLogin(c, http.StatusTooManyRequests, lockoutMessage(remaining))
+			return
+		}
+	}
+
+	// 第二步：凭据已通过，校验动态验证码
+	if challenge := c.PostForm("two_factor"); challenge != "" {
+		m.handleTwoFactorLogin(c, challenge)
+		return
+	}
 
 	// 验证参数
 	if pid == "" || key == "" {
-		c.HTML(http.StatusOK, "admin_login.html", gin.H{
-			"error": "请输入商户ID和密钥",
-		})
+		m.renderLogin(c, http.StatusOK, "请输入账号和密码")
 		return
 	}
 
-	// 验证凭据
-	if pid != m.merchantID || key != m.merchantKey {
-		logger.Warn("Failed admin login attempt",
-			zap.String("pid", pid),
+	// 连续失败后需先通过验证码（答错同样计入失败次数）
+	if m.guard != nil && m.guard.CaptchaRequired(ip) &&
+		!m.guard.VerifyCaptcha(ip, c.PostForm("captcha_id"), c.PostForm("captcha")) {
+		m.loginFailed(c, pid, "验证码错误")
+		return
+	}
+
+	// 验证凭据：商户ID和密钥，或管理员账号
+	m.mu.RLock()
+	merchantKey := m.merchantKey
+	m.mu.RUnlock()
+	idMatch := utils.SecureCompare(pid, m.merchantID)
+	keyMatch := utils.SecureCompare(key, merchantKey)
+	if !idMatch || !keyMatch {
+		user, err := m.authenticateUser(pid, key)
+		if err != nil {
+			logger.Error("Failed to authenticate admin user", zap.Error(err))
+			m.renderLogin(c, http.StatusInternalServerError, "登录失败，请稍后重试")
+			return
+		}
+		if user == nil {
+			m.loginFailed(c, pid, "账号或密码错误")
+			return
+		}
+		m.loginWithTwoFactor(c, user.Username, user.Role, remember)
+		return
+	}
+
+	m.loginWithTwoFactor(c, "", model.AdminRoleAdmin, remember)
+}
+
+/*
+loginWithTwoFactor 凭据已通过：账号已开启两步验证时进入第二步，否则直接登录
+功能: 任何角色的账号开启两步验证后都需通过第二步（登录失败次数在通过两步验证后才清零）
+参数:
+  - username: 管理员用户名（商户ID和密钥登录时为空）
+  - role: 角色
+  - remember: 是否记住我
+*/
+func (m *AdminAuthMiddleware) loginWithTwoFactor(c *gin.Context, username, role string, remember bool) {
+	enabled, err := m.twoFactor.Enabled(username)
+	if err != nil {
+		logger.Error("Failed to load two-factor status", zap.Error(err))
+		m.renderLogin(c, http.StatusInternalServerError, "登录失败，请稍后重试")
+		return
+	}
+	if enabled {
+		challenge, err := m.newLoginChallenge(username, remember)
+		if err != nil {
+			logger.Error("Failed to create two-factor challenge", zap.Error(err))
+			m.renderLogin(c, http.StatusInternalServerError, "登录失败，请稍后重试")
+			return
+		}
+		m.renderTwoFactor(c, http.StatusOK, challenge, "")
+		return
+	}
+
+	m.completeLogin(c, username, role, remember)
+}
+
+/*
+handleTwoFactorLogin 处理两步验证登录（第二步）
+参数:
+  - challenge: 第一步签发的两步验证令牌
+*/
+func (m *AdminAuthMiddleware) handleTwoFactorLogin(c *gin.Context, challenge string) {
+	login, ok := m.verifyLoginChallenge(challenge)
+	if !ok {
+		m.renderLogin(c, http.StatusOK, "验证已超时，请重新登录")
+		return
+	}
+
+	// 管理员账号在第一步之后被停用、删除或降级时不再放行
+	username := login.username
+	role := model.AdminRoleAdmin
+	account := m.merchantID
+	if username != "" {
+		if m.users == nil {
+			m.renderLogin(c, http.StatusOK, "验证已超时，请重新登录")
+			return
+		}
+		user, err := m.users.GetAdminUser(username)
+		if err != nil {
+			logger.Error("Failed to load admin user", zap.Error(err))
+			m.renderLogin(c, http.StatusInternalServerError, "登录失败，请稍后重试")
+			return
+		}
+		if user == nil || user.Disabled {
+			m.renderLogin(c, http.StatusOK, "验证已超时，请重新登录")
+			return
+		}
+		role = user.Role
+		account = username
+	}
+
+	code := c.PostForm("otp")
+	if strings.TrimSpace(code) == "" {
+		m.renderTwoFactor(c, http.StatusOK, challenge, "请输入动态验证码")
+		return
+	}
+
+	if err := m.twoFactor.Verify(username, code); err != nil {
+		if !errors.Is(err, ErrTwoFactorInvalidCode) {
+			logger.Error("Failed to verify two-factor code", zap.Error(err))
+			m.renderTwoFactor(c, http.StatusInternalServerError, challenge, "登录失败，请稍后重试")
+			return
+		}
+
+		logger.Warn("Invalid admin two-factor code",
+			zap.String("event", LoginEventTwoFactorFailed),
+			zap.String("username", username),
 			zap.String("ip", c.ClientIP()))
+		if m.guard != nil {
+			if lockout := m.guard.RecordFailure(c.ClientIP(), account); lockout > 0 {
+				m.renderLogin(c, http.StatusTooManyRequests, lockoutMessage(lockout))
+				return
+			}
+		}
+		m.renderTwoFactor(c, http.StatusOK, challenge, "动态验证码错误")
+		return
+	}
 
-		c.HTML(http.StatusOK, "admin_login.html", gin.H{
-			"error": "商户ID或密钥错误",
-		})
+	// 令牌只能使用一次：截获的令牌加上新的动态验证码不能再次登录
+	consumed, err := m.twoFactor.store.ConsumeAdminLoginChallenge(login.nonce, login.expiresAt)
+	if err != nil {
+		logger.Error("Failed to consume two-factor challenge", zap.Error(err))
+		m.renderLogin(c, http.StatusInternalServerError, "登录失败，请稍后重试")
+		return
+	}
+	if !consumed {
+		logger.Warn("Admin two-factor challenge reused",
+			zap.String("event", LoginEventTwoFactorFailed),
+			zap.String("username", username),
+			zap.String("ip", c.ClientIP()))
+		m.renderLogin(c, http.StatusOK, "验证已超时，请重新登录")
 		return
 	}
 
+	m.completeLogin(c, username, role, login.remember)
+}
+
+/*
+completeLogin 登录成功：创建session并跳转到后台
+参数:
+  - username: 管理员用户名（商户ID和密钥登录时为空）
+  - role: 角色
+  - remember: 是否记住我
+*/
+func (m *AdminAuthMiddleware) completeLogin(c *gin.Context, username, role string, remember bool) {
+	if m.guard != nil {
+		m.guard.RecordSuccess(c.ClientIP())
+	}
+
 	// 创建session
-	token := m.createSession(pid, c.ClientIP())
+	session, err := m.createSession(username, role, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		logger.Error("Failed to create admin session", zap.Error(err))
+		m.renderLogin(c, http.StatusInternalServerError, "登录失败，请稍后重试")
+		return
+	}
+	m.setSessionCookie(c, session)
+
+	// 记住我：签发刷新令牌（保存失败时仅本次会话有效）
+	if remember {
+		if refreshToken, err := m.createRefreshToken(session); err != nil {
+			logger.Warn("Failed to create admin refresh token", zap.Error(err))
+		} else {
+			c.SetCookie(refreshCookieName, refreshToken, int(m.options.RememberLifetime.Seconds()), "/admin", "", false, true)
+		}
+	}
 
-	// 设置cookie（24小时有效）
-	c.SetCookie("admin_session", token, 86400, "/", "", false, true)
+	if username != "" {
+		if err := m.users.UpdateAdminUserLastLogin(username, session.CreatedAt); err != nil {
+			logger.Warn("Failed to update admin user last login", zap.Error(err))
+		}
+	}
 
 	logger.Info("Admin logged in successfully",
-		zap.String("pid", pid),
-		zap.String("ip", c.ClientIP()))
+		zap.String("pid", m.merchantID),
+		zap.String("username", username),
+		zap.String("role", role),
+		zap.String("ip", c.ClientIP()),
+		zap.String("location", geoip.Describe(c.ClientIP())),
+		zap.Bool("remember", remember))
 
 	// 重定向到后台
 	c.Redirect(http.StatusFound, "/admin/dashboard")
@@ -183,14 +497,20 @@ HandleLogout 处理登出请求
 GET /admin/logout
 */
 func (m *AdminAuthMiddleware) HandleLogout(c *gin.Context) {
-	// 获取并删除session
-	token, err := c.Cookie("admin_session")
+	// 获取并删除session（同时删除关联的刷新令牌）
+	token, err := c.Cookie(sessionCookieName)
 	if err == nil && token != "" {
 		m.deleteSession(token)
 	}
+	if refreshToken, err := c.Cookie(refreshCookieName); err == nil && refreshToken != "" {
+		if err := m.sessions.DeleteAdminRefreshToken(refreshToken); err != nil {
+			logger.Warn("Failed to delete admin refresh token", zap.Error(err))
+		}
+	}
 
 	// 清除cookie
-	c.SetCookie("admin_session", "", -1, "/", "", false, true)
+	c.SetCookie(sessionCookieName, "", -1, "/", "", false, true)
+	c.SetCookie(refreshCookieName, "", -1, "/admin", "", false, true)
 
 	logger.Info("Admin logged out",
 		zap.String("ip", c.ClientIP()))
@@ -200,33 +520,417 @@ func (m *AdminAuthMiddleware) HandleLogout(c *gin.Context) {
 }
 
 /*
-createSession 创建新session
+HandleListSessions 获取活跃会话列表
+GET /admin/sessions
+*/
+func (m *AdminAuthMiddleware) HandleListSessions(c *gin.Context) {
+	currentID := c.GetString("admin_session_id")
+
+	stored, err := m.sessions.ListAdminSessions()
+	if err != nil {
+		logger.Error("Failed to list admin sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to list sessions",
+		})
+		return
+	}
+
+	sessions := make([]SessionInfo, 0, len(stored))
+	for _, session := range stored {
+		if m.isExpired(session) {
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:         session.ID,
+			Username:   session.Username,
+			Role:       sessionRole(session),
+			IP:         session.IP,
+			Location:   geoip.Describe(session.IP),
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastAccess: session.LastAccess,
+			ExpiresAt:  session.ExpiresAt,
+			Remember:   session.RefreshToken != "",
+			Current:    session.ID == currentID,
+		})
+	}
+
+	// 最近活跃的排在前面
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastAccess.After(sessions[j].LastAccess)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"sessions": sessions,
+	})
+}
+
+/*
+HandleRevokeSession 注销指定会话
+POST /admin/sessions/revoke
+参数:
+  - id: 会话标识
+*/
+func (m *AdminAuthMiddleware) HandleRevokeSession(c *gin.Context) {
+	var req struct {
+		ID string `json:"id" form:"id"`
+	}
+	if err := c.ShouldBind(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing session id",
+		})
+		return
+	}
+
+	if !m.revokeSession(req.ID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Session not found",
+		})
+		return
+	}
+
+	logger.Info("Admin session revoked",
+		zap.String("session_id", req.ID),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"current": req.ID == c.GetString("admin_session_id"),
+	})
+}
+
+/*
+HandleLoginAttempts 获取登录失败记录
+GET /admin/login-attempts
+*/
+func (m *AdminAuthMiddleware) HandleLoginAttempts(c *gin.Context) {
+	attempts := []LoginStatus{}
+	if m.guard != nil {
+		attempts = m.guard.Statuses()
+	}
+
+	// 失败次数多的排在前面
+	sort.Slice(attempts, func(i, j int) bool {
+		return attempts[i].Failures > attempts[j].Failures
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"attempts": attempts,
+	})
+}
+
+/*
+HandleUnlockLogin 解除IP的登录锁定
+POST /admin/login-attempts/unlock
 参数:
-  - merchantID: 商户ID
   - ip: 客户端IP
+*/
+func (m *AdminAuthMiddleware) HandleUnlockLogin(c *gin.Context) {
+	var req struct {
+		IP string `json:"ip" form:"ip"`
+	}
+	if err := c.ShouldBind(&req); err != nil || req.IP == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing ip",
+		})
+		return
+	}
+
+	if m.guard == nil || !m.guard.Unlock(req.IP) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "No failed login attempts for this IP",
+		})
+		return
+	}
+
+	logger.Info("Admin login lockout cleared",
+		zap.String("target_ip", req.IP),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// loginFailed 记录登录失败并重新显示登录页面（失败次数达到上限时提示已锁定）
+func (m *AdminAuthMiddleware) loginFailed(c *gin.Context, pid, errMsg string) {
+	if m.guard == nil {
+		logger.Warn("Failed admin login attempt",
+			zap.String("pid", pid),
+			zap.String("ip", c.ClientIP()))
+		m.renderLogin(c, http.StatusOK, errMsg)
+		return
+	}
+
+	if lockout := m.guard.RecordFailure(c.ClientIP(), pid); lockout > 0 {
+		m.renderLogin(c, http.StatusTooManyRequests, lockoutMessage(lockout))
+		return
+	}
+	m.renderLogin(c, http.StatusOK, errMsg)
+}
+
+// lockoutMessage 锁定提示
+func lockoutMessage(remaining time.Duration) string {
+	minutes := int((remaining + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("登录失败次数过多，请%d分钟后再试", minutes)
+}
+
+// renderLogin 渲染登录页面（需要验证码时附带新的验证码）
+func (m *AdminAuthMiddleware) renderLogin(c *gin.Context, status int, errMsg string) {
+	data := gin.H{
+		"error":        errMsg,
+		"rememberDays": int(m.options.RememberLifetime.Hours() / 24),
+	}
+	if m.guard != nil && status == http.StatusOK && m.guard.CaptchaRequired(c.ClientIP()) {
+		data["captcha"] = m.guard.NewCaptcha(c.ClientIP())
+	}
+	c.HTML(status, "admin_login.html", data)
+}
+
+// renderTwoFactor 渲染两步验证页面（输入动态验证码）
+func (m *AdminAuthMiddleware) renderTwoFactor(c *gin.Context, status int, challenge, errMsg string) {
+	c.HTML(status, "admin_login.html", gin.H{
+		"error":     errMsg,
+		"twoFactor": challenge,
+	})
+}
+
+/*
+newLoginChallenge 签发两步验证令牌（凭据已通过，等待输入动态验证码）
+格式: 过期时间.记住我.用户名.随机数.HMAC-SHA256(secret, 2fa|过期时间|记住我|用户名|随机数|商户ID)
+参数:
+  - username: 管理员用户名（base64url编码，商户ID和密钥登录时为空）
+  - remember: 是否记住我
 
 返回:
-  - string: session令牌
+  - string: 令牌（随第二步表单提交，签发时无需服务端保存，多实例部署时任一实例均可校验；登录成功后记录随机数，不能再次使用）
+  - error: 随机数生成失败时返回错误
 */
-func (m *AdminAuthMiddleware) createSession(merchantID, ip string) string {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *AdminAuthMiddleware) newLoginChallenge(username string, remember bool) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
 
-	// 生成token
-	token := m.generateToken(merchantID, ip)
+	fields := []string{
+		strconv.FormatInt(time.Now().Add(twoFactorLoginLifetime).Unix(), 10),
+		strconv.FormatBool(remember),
+		base64.RawURLEncoding.EncodeToString([]byte(username)),
+		hex.EncodeToString(nonce),
+	}
 
-	// 创建session
+	m.mu.RLock()
+	signature := m.sign("2fa|" + strings.Join(fields, "|") + "|" + m.merchantID)
+	m.mu.RUnlock()
+	return strings.Join(fields, ".") + "." + signature, nil
+}
+
+/*
+loginChallenge 已校验的两步验证令牌
+字段:
+  - username: 管理员用户名（商户ID和密钥登录时为空）
+  - remember: 是否记住我
+  - nonce: 随机数（登录成功后记录为已使用）
+  - expiresAt: 过期时间
+*/
+type loginChallenge struct {
+	username  string
+	remember  bool
+	nonce     string
+	expiresAt time.Time
+}
+
+/*
+verifyLoginChallenge 校验两步验证令牌
+参数:
+  - challenge: 令牌
+
+返回:
+  - *loginChallenge: 令牌内容
+  - bool: 令牌是否有效（签名正确且未过期；是否已使用在通过动态验证码后检查）
+*/
+func (m *AdminAuthMiddleware) verifyLoginChallenge(challenge string) (*loginChallenge, bool) {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 5 {
+		return nil, false
+	}
+
+	payload := strings.Join(parts[:4], "|")
+	m.mu.RLock()
+	expected := m.sign("2fa|" + payload + "|" + m.merchantID)
+	m.mu.RUnlock()
+	if !hmac.Equal([]byte(parts[4]), []byte(expected)) {
+		return nil, false
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, false
+	}
+	remember, err := strconv.ParseBool(parts[1])
+	if err != nil {
+		return nil, false
+	}
+	username, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, false
+	}
+	return &loginChallenge{
+		username:  string(username),
+		remember:  remember,
+		nonce:     parts[3],
+		expiresAt: time.Unix(expiresAt, 0),
+	}, true
+}
+
+// setSessionCookie 写入session cookie
+func (m *AdminAuthMiddleware) setSessionCookie(c *gin.Context, session *Session) {
+	c.SetCookie(sessionCookieName, session.Token, int(m.options.Lifetime.Seconds()), "/", "", false, true)
+}
+
+/*
+createSession 创建新session并保存到存储
+参数:
+  - username: 管理员用户名（商户ID和密钥登录时为空）
+  - role: 角色
+  - ip: 客户端IP
+  - userAgent: 客户端标识
+
+返回:
+  - *Session: 新建的session
+  - error: 生成令牌或保存失败时返回错误
+*/
+func (m *AdminAuthMiddleware) createSession(username, role, ip, userAgent string) (*Session, error) {
+	m.mu.RLock()
+	token, err := m.generateToken(m.merchantID)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	session := &Session{
+		ID:         sessionID(token),
 		Token:      token,
-		MerchantID: merchantID,
-		CreatedAt:  time.Now(),
-		LastAccess: time.Now(),
+		MerchantID: m.merchantID,
+		Username:   username,
+		Role:       role,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(m.options.Lifetime),
+		LastAccess: now,
 		IP:         ip,
+		UserAgent:  userAgent,
+	}
+
+	if err := m.sessions.SaveAdminSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+/*
+createRefreshToken 为session签发刷新令牌
+参数:
+  - session: 关联的session
+
+返回:
+  - string: 刷新令牌
+  - error: 生成令牌或保存失败时返回错误
+*/
+func (m *AdminAuthMiddleware) createRefreshToken(session *Session) (string, error) {
+	m.mu.RLock()
+	token, err := m.generateToken(session.MerchantID)
+	m.mu.RUnlock()
+	if err != nil {
+		return "", err
 	}
 
-	m.sessions[token] = session
+	refresh := &RefreshToken{
+		Token:      token,
+		MerchantID: session.MerchantID,
+		Username:   session.Username,
+		ExpiresAt:  time.Now().Add(m.options.RememberLifetime),
+		SessionID:  session.ID,
+	}
+	if err := m.sessions.SaveAdminRefreshToken(refresh); err != nil {
+		return "", err
+	}
+
+	session.RefreshToken = token
+	if err := m.sessions.SaveAdminSession(session); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+/*
+refreshSession 使用刷新令牌创建新session
+功能: session过期后，若刷新令牌仍有效则自动签发新session
+返回:
+  - *Session: 新session（刷新令牌无效时返回nil）
+*/
+func (m *AdminAuthMiddleware) refreshSession(c *gin.Context) *Session {
+	refreshToken, err := c.Cookie(refreshCookieName)
+	if err != nil || refreshToken == "" {
+		return nil
+	}
+
+	refresh, err := m.sessions.GetAdminRefreshToken(refreshToken)
+	if err != nil {
+		logger.Warn("Failed to load admin refresh token", zap.Error(err))
+		return nil
+	}
+	m.mu.RLock()
+	valid := m.verifyToken(refreshToken)
+	m.mu.RUnlock()
+	if refresh != nil && (!valid || time.Now().After(refresh.ExpiresAt)) {
+		_ = m.sessions.DeleteAdminRefreshToken(refreshToken)
+		refresh = nil
+	}
+	if refresh == nil {
+		c.SetCookie(refreshCookieName, "", -1, "/admin", "", false, true)
+		return nil
+	}
+
+	// 管理员账号已删除或停用时刷新令牌失效，角色以账号当前设置为准
+	role, ok := m.currentUserRole(refresh.Username)
+	if !ok {
+		_ = m.sessions.DeleteAdminRefreshToken(refreshToken)
+		c.SetCookie(refreshCookieName, "", -1, "/admin", "", false, true)
+		return nil
+	}
+
+	// 删除旧session，避免会话列表中残留
+	if old := m.findSession(refresh.SessionID); old != nil {
+		_ = m.sessions.DeleteAdminSession(old.Token)
+	}
+
+	session, err := m.createSession(refresh.Username, role, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		logger.Error("Failed to create admin session", zap.Error(err))
+		return nil
+	}
 
-	return token
+	refresh.SessionID = session.ID
+	session.RefreshToken = refreshToken
+	if err := m.sessions.SaveAdminRefreshToken(refresh); err != nil {
+		logger.Warn("Failed to update admin refresh token", zap.Error(err))
+	}
+	if err := m.sessions.SaveAdminSession(session); err != nil {
+		logger.Warn("Failed to update admin session", zap.Error(err))
+	}
+
+	m.setSessionCookie(c, session)
+
+	logger.Info("Admin session refreshed by remember-me token",
+		zap.String("ip", c.ClientIP()))
+
+	return session
 }
 
 /*
@@ -238,42 +942,94 @@ getSession 获取session
   - *Session: session信息
 */
 func (m *AdminAuthMiddleware) getSession(token string) *Session {
+	// 校验令牌签名（密钥轮换后旧令牌全部失效）
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	valid := m.verifyToken(token)
+	m.mu.RUnlock()
+	if !valid {
+		return nil
+	}
 
-	session, exists := m.sessions[token]
-	if !exists {
+	session, err := m.sessions.GetAdminSession(token)
+	if err != nil {
+		logger.Warn("Failed to load admin session", zap.Error(err))
+		return nil
+	}
+	if session == nil {
 		return nil
 	}
 
-	// 检查是否过期（24小时）
-	if time.Since(session.LastAccess) > 24*time.Hour {
+	// 检查是否过期（最长有效期或无操作超时）
+	if m.isExpired(session) {
 		return nil
 	}
 
 	return session
 }
 
+/*
+findSession 按会话标识查找session
+参数:
+  - id: 会话标识
+
+返回:
+  - *Session: session信息（不存在时返回nil）
+*/
+func (m *AdminAuthMiddleware) findSession(id string) *Session {
+	sessions, err := m.sessions.ListAdminSessions()
+	if err != nil {
+		logger.Warn("Failed to list admin sessions", zap.Error(err))
+		return nil
+	}
+
+	for _, session := range sessions {
+		if session.ID == id {
+			return session
+		}
+	}
+	return nil
+}
+
+// sessionRole 会话角色（升级前创建的会话未记录角色，均为商户ID和密钥登录）
+func sessionRole(session *Session) string {
+	if session.Role == "" {
+		return model.AdminRoleAdmin
+	}
+	return session.Role
+}
+
+// isExpired session是否已过期
+func (m *AdminAuthMiddleware) isExpired(session *Session) bool {
+	now := time.Now()
+	return now.After(session.ExpiresAt) || now.Sub(session.LastAccess) > m.options.IdleTimeout
+}
+
 /*
 updateSessionAccess 更新session最后访问时间
+功能: 距上次写入超过 sessionTouchInterval 或IP变化时写入存储
 参数:
-  - token: session令牌
+  - session: 当前session
   - ip: 客户端IP
 */
-func (m *AdminAuthMiddleware) updateSessionAccess(token, ip string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if session, exists := m.sessions[token]; exists {
-		session.LastAccess = time.Now()
-		// 检查IP变化
-		if session.IP != ip {
-			logger.Warn("Session IP changed",
-				zap.String("token", token[:8]+"..."),
-				zap.String("old_ip", session.IP),
-				zap.String("new_ip", ip))
-			session.IP = ip
-		}
+func (m *AdminAuthMiddleware) updateSessionAccess(session *Session, ip string) {
+	now := time.Now()
+	ipChanged := session.IP != ip
+	if !ipChanged && now.Sub(session.LastAccess) < sessionTouchInterval {
+		return
+	}
+
+	session.LastAccess = now
+	// 检查IP变化
+	if ipChanged {
+		logger.Warn("Session IP changed",
+			zap.String("token", session.Token[:8]+"..."),
+			zap.String("old_ip", session.IP),
+			zap.String("new_ip", ip))
+		session.IP = ip
+	}
+
+	if err := m.sessions.SaveAdminSession(session); err != nil {
+		logger.Warn("Failed to update admin session", zap.Error(err))
 	}
 }
 
@@ -283,25 +1039,214 @@ deleteSession 删除session
   - token: session令牌
 */
 func (m *AdminAuthMiddleware) deleteSession(token string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	if err := m.sessions.DeleteAdminSession(token); err != nil {
+		logger.Warn("Failed to delete admin session", zap.Error(err))
+	}
+}
+
+/*
+revokeSession 注销指定会话及其刷新令牌
+参数:
+  - id: 会话标识
 
-	delete(m.sessions, token)
+返回:
+  - bool: 是否找到该会话
+*/
+func (m *AdminAuthMiddleware) revokeSession(id string) bool {
+	session := m.findSession(id)
+	if session == nil {
+		return false
+	}
+
+	if session.RefreshToken != "" {
+		if err := m.sessions.DeleteAdminRefreshToken(session.RefreshToken); err != nil {
+			logger.Warn("Failed to delete admin refresh token", zap.Error(err))
+		}
+	}
+	m.deleteSession(session.Token)
+	return true
 }
 
 /*
 generateToken 生成session令牌
+格式: 随机数.HMAC-SHA256(secret, 随机数|商户ID)
 参数:
   - merchantID: 商户ID
-  - ip: 客户端IP
 
 返回:
   - string: 令牌
+  - error: 随机数生成失败时返回错误（不签发可预测的令牌）
 */
-func (m *AdminAuthMiddleware) generateToken(merchantID, ip string) string {
-	data := merchantID + ip + time.Now().String()
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+func (m *AdminAuthMiddleware) generateToken(merchantID string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	payload := hex.EncodeToString(nonce) + "|" + merchantID
+	return hex.EncodeToString(nonce) + "." + m.sign(payload), nil
+}
+
+/*
+verifyToken 校验session令牌签名
+参数:
+  - token: session令牌
+
+返回:
+  - bool: 签名是否有效
+*/
+func (m *AdminAuthMiddleware) verifyToken(token string) bool {
+	nonce, signature, found := strings.Cut(token, ".")
+	if !found || nonce == "" {
+		return false
+	}
+
+	expected := m.sign(nonce + "|" + m.merchantID)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// sign 使用当前密钥计算HMAC-SHA256签名
+func (m *AdminAuthMiddleware) sign(payload string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sessionID 根据令牌生成可公开的会话标识
+func sessionID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:8])
+}
+
+/*
+loadSecret 加载session签名密钥
+功能: 优先读取持久化的密钥，不存在时生成新密钥并保存
+返回:
+  - []byte: 签名密钥
+  - error: 随机数生成失败时返回错误（调用方应中止启动）
+*/
+func (m *AdminAuthMiddleware) loadSecret() ([]byte, error) {
+	if m.store != nil {
+		stored, err := m.store.GetSetting(database.SettingAdminSessionSecret)
+		if err != nil {
+			logger.Warn("Failed to load session secret", zap.Error(err))
+		} else if secret, err := hex.DecodeString(stored); err == nil && len(secret) >= 32 {
+			return secret, nil
+		}
+	}
+
+	secret, err := newSessionSecret()
+	if err != nil {
+		return nil, err
+	}
+	m.saveSecret(secret)
+	logger.Info("Generated new admin session secret")
+	return secret, nil
+}
+
+// saveSecret 持久化签名密钥
+func (m *AdminAuthMiddleware) saveSecret(secret []byte) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SetSetting(database.SettingAdminSessionSecret, hex.EncodeToString(secret)); err != nil {
+		logger.Warn("Failed to persist session secret, sessions will not survive restart", zap.Error(err))
+	}
+}
+
+/*
+SetMerchantKey 更新商户密钥
+功能: 商户密钥轮换后调用，只能使用新密钥登录（旧密钥的过渡期不适用于管理后台），
+并注销其他使用商户密钥登录的会话（管理员账号的会话不受影响）
+参数:
+  - key: 新的商户密钥
+  - currentSessionID: 执行轮换的会话ID（保留该会话）
+
+返回:
+  - int: 注销的会话数
+*/
+func (m *AdminAuthMiddleware) SetMerchantKey(key, currentSessionID string) int {
+	m.mu.Lock()
+	m.merchantKey = key
+	m.mu.Unlock()
+
+	sessions, err := m.sessions.ListAdminSessions()
+	if err != nil {
+		logger.Warn("Failed to list admin sessions", zap.Error(err))
+		return 0
+	}
+
+	count := 0
+	for _, session := range sessions {
+		if session.Username == "" && session.ID != currentSessionID && m.revokeSession(session.ID) {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+RotateSecret 轮换session签名密钥
+功能: 生成并保存新密钥，同时使所有已登录的session和刷新令牌失效
+返回:
+  - error: 随机数生成失败时返回错误（原密钥和session保持不变）
+*/
+func (m *AdminAuthMiddleware) RotateSecret() error {
+	secret, err := newSessionSecret()
+	if err != nil {
+		return err
+	}
+	m.saveSecret(secret)
+
+	m.mu.Lock()
+	m.secret = secret
+	m.mu.Unlock()
+
+	count := m.GetActiveSessions()
+	if err := m.sessions.ClearAdminSessions(); err != nil {
+		// 旧令牌签名已失效，残留记录无法再使用，由定时清理删除
+		logger.Warn("Failed to clear admin sessions", zap.Error(err))
+	}
+
+	logger.Warn("Admin session secret rotated, all sessions invalidated",
+		zap.Int("invalidated_sessions", count))
+	return nil
+}
+
+/*
+HandleRotateSecret 处理密钥轮换请求
+POST /admin/session/rotate
+*/
+func (m *AdminAuthMiddleware) HandleRotateSecret(c *gin.Context) {
+	if err := m.RotateSecret(); err != nil {
+		logger.Error("Failed to rotate session secret", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to rotate session secret",
+		})
+		return
+	}
+
+	logger.Info("Session secret rotated by admin",
+		zap.String("ip", c.ClientIP()))
+
+	// 当前session也已失效
+	c.SetCookie(sessionCookieName, "", -1, "/", "", false, true)
+	c.SetCookie(refreshCookieName, "", -1, "/admin", "", false, true)
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "所有会话已注销，请重新登录",
+		"redirect": "/admin/login",
+	})
+}
+
+// newSessionSecret 生成随机签名密钥
+func newSessionSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
 }
 
 /*
@@ -313,18 +1258,14 @@ func (m *AdminAuthMiddleware) cleanupExpiredSessions() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		m.mu.Lock()
-		count := 0
-		for token, session := range m.sessions {
-			if time.Since(session.LastAccess) > 24*time.Hour {
-				delete(m.sessions, token)
-				count++
-			}
+		count, err := m.sessions.DeleteExpiredAdminSessions(m.options.IdleTimeout)
+		if err != nil {
+			logger.Warn("Failed to clean up expired admin sessions", zap.Error(err))
+			continue
 		}
-		m.mu.Unlock()
 
 		if count > 0 {
-			logger.Info("Cleaned up expired admin sessions", zap.Int("count", count))
+			logger.Info("Cleaned up expired admin sessions", zap.Int64("count", count))
 		}
 	}
 }
@@ -335,12 +1276,15 @@ GetActiveSessions 获取活跃session数量
   - int: 活跃session数量
 */
 func (m *AdminAuthMiddleware) GetActiveSessions() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	sessions, err := m.sessions.ListAdminSessions()
+	if err != nil {
+		logger.Warn("Failed to list admin sessions", zap.Error(err))
+		return 0
+	}
 
 	count := 0
-	for _, session := range m.sessions {
-		if time.Since(session.LastAccess) <= 24*time.Hour {
+	for _, session := range sessions {
+		if !m.isExpired(session) {
 			count++
 		}
 	}
@@ -0,0 +1,305 @@
+/*
+Package middleware 管理后台API令牌
+Author: AliMPay Team
+Description: 监控脚本等自动化程序使用长期有效的API令牌（Authorization: Bearer）调用管理接口，无需模拟登录
+
+权限范围:
+  - read: 只读，等同 viewer 角色
+  - orders: 订单操作，等同 operator 角色
+
+功能:
+  - 令牌明文只在创建时返回一次，存储SHA-256摘要
+  - 可设置有效天数，过期或注销后立即失效
+  - 不能访问需要 admin 角色的接口（不能管理令牌、账号和配置）
+  - 记录最后使用时间和IP，审计日志的操作人记录为 token:令牌标识
+*/
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// API令牌参数
+const (
+	adminTokenPrefix     = "amp_" // 令牌前缀（便于在配置和日志中识别）
+	maxAdminTokenName    = 64
+	maxAdminTokenExpires = 3650 // 最长有效天数
+)
+
+// adminTokenScopeRoles 权限范围对应的角色
+var adminTokenScopeRoles = map[string]string{
+	model.AdminTokenScopeRead:   model.AdminRoleViewer,
+	model.AdminTokenScopeOrders: model.AdminRoleOperator,
+}
+
+// API令牌错误（错误信息直接返回给管理后台）
+var (
+	ErrAdminTokenName     = errors.New("name must be 1-64 characters")
+	ErrAdminTokenScope    = errors.New("scope must be read or orders")
+	ErrAdminTokenExpires  = errors.New("expires_days must be between 0 and 3650")
+	ErrAdminTokenNotFound = errors.New("api token not found")
+)
+
+/*
+AdminTokenStore API令牌存储（*database.DB 实现）
+功能: GetAdminAPITokenByHash 在令牌不存在时返回 nil, nil；Delete 返回是否有记录被删除
+*/
+type AdminTokenStore interface {
+	CreateAdminAPIToken(token *model.AdminAPIToken) error
+	GetAdminAPITokenByHash(tokenHash string) (*model.AdminAPIToken, error)
+	ListAdminAPITokens() ([]*model.AdminAPIToken, error)
+	DeleteAdminAPIToken(id string) (bool, error)
+	UpdateAdminAPITokenLastUsed(id string, usedAt time.Time, ip string) error
+}
+
+/*
+SetTokenStore 设置API令牌存储
+参数:
+  - tokens: API令牌存储（未设置时携带Bearer令牌的请求均被拒绝）
+*/
+func (m *AdminAuthMiddleware) SetTokenStore(tokens AdminTokenStore) {
+	m.tokens = tokens
+}
+
+/*
+authenticateAPIToken 使用API令牌认证（令牌无效时返回401，不跳转登录页）
+参数:
+  - c: 请求上下文
+  - token: Bearer令牌
+*/
+func (m *AdminAuthMiddleware) authenticateAPIToken(c *gin.Context, token string) {
+	var apiToken *model.AdminAPIToken
+	if m.tokens != nil {
+		var err error
+		if apiToken, err = m.tokens.GetAdminAPITokenByHash(hashAdminToken(token)); err != nil {
+			logger.Error("Failed to load admin api token", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to verify API token",
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	if apiToken == nil || (apiToken.ExpiresAt != nil && now.After(*apiToken.ExpiresAt)) {
+		logger.Warn("Invalid admin api token",
+			zap.Bool("expired", apiToken != nil),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("ip", c.ClientIP()))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Invalid or expired API token",
+		})
+		return
+	}
+
+	// 最后使用时间与会话相同，距上次写入超过 sessionTouchInterval 或IP变化时写入
+	ip := c.ClientIP()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= sessionTouchInterval || apiToken.LastUsedIP != ip {
+		if err := m.tokens.UpdateAdminAPITokenLastUsed(apiToken.ID, now, ip); err != nil {
+			logger.Warn("Failed to update admin api token", zap.Error(err))
+		}
+	}
+
+	c.Set("admin_merchant_id", m.merchantID)
+	c.Set("admin_token_id", apiToken.ID)
+	c.Set("admin_role", adminTokenScopeRoles[apiToken.Scope])
+	c.Set("admin_logged_in", true)
+
+	c.Next()
+}
+
+/*
+HandleListTokens 获取API令牌列表（不含令牌明文）
+GET /admin/tokens
+*/
+func (m *AdminAuthMiddleware) HandleListTokens(c *gin.Context) {
+	tokens := []*model.AdminAPIToken{}
+	if m.tokens != nil {
+		var err error
+		if tokens, err = m.tokens.ListAdminAPITokens(); err != nil {
+			logger.Error("Failed to list admin api tokens", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to list API tokens",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"tokens":  tokens,
+	})
+}
+
+/*
+HandleCreateToken 创建API令牌（令牌明文只在响应中返回一次）
+POST /admin/tokens
+参数:
+  - name: 名称（用途说明）
+  - scope: 权限范围（read、orders）
+  - expires_days: 有效天数（可选，0或不填表示长期有效）
+*/
+func (m *AdminAuthMiddleware) HandleCreateToken(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" form:"name"`
+		Scope       string `json:"scope" form:"scope"`
+		ExpiresDays int    `json:"expires_days" form:"expires_days"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	apiToken, token, err := m.createToken(strings.TrimSpace(req.Name), req.Scope, req.ExpiresDays, c.GetString("admin_username"))
+	if err != nil {
+		adminTokenError(c, "Failed to create API token", err)
+		return
+	}
+
+	logger.Info("Admin api token created",
+		zap.String("id", apiToken.ID),
+		zap.String("name", apiToken.Name),
+		zap.String("scope", apiToken.Scope),
+		zap.String("operator", c.GetString("admin_username")),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"api_token": apiToken,
+		"token":     token,
+	})
+}
+
+/*
+HandleRevokeToken 注销API令牌（立即失效）
+POST /admin/tokens/revoke
+参数:
+  - id: 令牌标识
+*/
+func (m *AdminAuthMiddleware) HandleRevokeToken(c *gin.Context) {
+	var req struct {
+		ID string `json:"id" form:"id"`
+	}
+	if err := c.ShouldBind(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing id",
+		})
+		return
+	}
+
+	if m.tokens == nil {
+		adminTokenError(c, "Failed to revoke API token", ErrAdminTokenNotFound)
+		return
+	}
+	deleted, err := m.tokens.DeleteAdminAPIToken(req.ID)
+	if err == nil && !deleted {
+		err = ErrAdminTokenNotFound
+	}
+	if err != nil {
+		adminTokenError(c, "Failed to revoke API token", err)
+		return
+	}
+
+	logger.Info("Admin api token revoked",
+		zap.String("id", req.ID),
+		zap.String("operator", c.GetString("admin_username")),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// createToken 校验参数并生成API令牌，返回令牌记录和令牌明文
+func (m *AdminAuthMiddleware) createToken(name, scope string, expiresDays int, createdBy string) (*model.AdminAPIToken, string, error) {
+	if m.tokens == nil {
+		return nil, "", ErrAdminTokenNotFound
+	}
+	if name == "" || utf8.RuneCountInString(name) > maxAdminTokenName {
+		return nil, "", ErrAdminTokenName
+	}
+	if _, ok := adminTokenScopeRoles[scope]; !ok {
+		return nil, "", ErrAdminTokenScope
+	}
+	if expiresDays < 0 || expiresDays > maxAdminTokenExpires {
+		return nil, "", ErrAdminTokenExpires
+	}
+
+	secret := make([]byte, 32)
+	id := make([]byte, 8)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	token := adminTokenPrefix + hex.EncodeToString(secret)
+
+	apiToken := &model.AdminAPIToken{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		TokenHash: hashAdminToken(token),
+		Prefix:    token[:len(adminTokenPrefix)+8],
+		Scope:     scope,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if expiresDays > 0 {
+		expiresAt := apiToken.CreatedAt.AddDate(0, 0, expiresDays)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	if err := m.tokens.CreateAdminAPIToken(apiToken); err != nil {
+		return nil, "", err
+	}
+	return apiToken, token, nil
+}
+
+// hashAdminToken 令牌的SHA-256摘要（令牌为高熵随机数，无需加盐）
+func hashAdminToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// adminTokenError 返回API令牌操作错误（参数错误返回400，令牌不存在返回404，其他返回500）
+func adminTokenError(c *gin.Context, msg string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrAdminTokenNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAdminTokenName), errors.Is(err, ErrAdminTokenScope), errors.Is(err, ErrAdminTokenExpires):
+		status = http.StatusBadRequest
+	default:
+		logger.Error(msg, zap.Error(err))
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   msg,
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
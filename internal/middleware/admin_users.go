@@ -0,0 +1,471 @@
+/*
+Package middleware 管理员账号与角色
+Author: AliMPay Team
+Description: 商户ID和密钥登录拥有全部权限，另可创建使用用户名和密码登录的管理员账号，按角色限制可执行的操作
+
+角色（权限依次递增）:
+  - viewer: 只读，查看订单、统计、通知、退款申请
+  - operator: 另可标记支付、取消订单、审核退款、重放通知、生成线下收款码
+  - admin: 另可修改配置、管理收款码、会话、两步验证和管理员账号
+
+功能:
+  - 密码使用bcrypt哈希保存
+  - 修改角色、停用、删除账号或重置密码后，该账号的会话立即注销
+  - 每个账号（含商户ID和密钥登录）可独立开启两步验证，开启后登录需输入动态验证码，与角色无关；
+    admin 角色可为丢失手机的账号重置两步验证
+*/
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 管理员账号参数
+const (
+	minAdminPasswordLength = 8
+	maxAdminPasswordLength = 72 // bcrypt 只使用前72字节
+)
+
+// adminUsernamePattern 用户名格式（3-32位字母、数字、下划线、点、横线）
+var adminUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// adminRoleLevels 角色权限等级
+var adminRoleLevels = map[string]int{
+	model.AdminRoleViewer:   1,
+	model.AdminRoleOperator: 2,
+	model.AdminRoleAdmin:    3,
+}
+
+// 管理员账号错误（错误信息直接返回给管理后台）
+var (
+	ErrAdminUserInvalid  = errors.New("username must be 3-32 letters, digits, '_', '.' or '-'")
+	ErrAdminUserReserved = errors.New("username must not be the merchant id")
+	ErrAdminUserExists   = errors.New("username already exists")
+	ErrAdminUserNotFound = errors.New("admin user not found")
+	ErrAdminRoleInvalid  = errors.New("role must be viewer, operator or admin")
+	ErrAdminPasswordWeak = errors.New("password must be 8-72 characters")
+)
+
+/*
+AdminUserStore 管理员账号存储（*database.DB 实现）
+功能: Get 方法在账号不存在时返回 nil, nil；Create、Update、Delete 返回是否有记录被修改
+*/
+type AdminUserStore interface {
+	CreateAdminUser(user *model.AdminUser) (bool, error)
+	GetAdminUser(username string) (*model.AdminUser, error)
+	ListAdminUsers() ([]*model.AdminUser, error)
+	UpdateAdminUser(user *model.AdminUser) (bool, error)
+	DeleteAdminUser(username string) (bool, error)
+	UpdateAdminUserLastLogin(username string, loginAt time.Time) error
+}
+
+// dummyPasswordHash 账号不存在时用于比较的哈希（使响应时间与账号存在时一致，避免枚举用户名）
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("alimpay-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+/*
+SetUserStore 设置管理员账号存储
+参数:
+  - users: 管理员账号存储（未设置时只能使用商户ID和密钥登录）
+*/
+func (m *AdminAuthMiddleware) SetUserStore(users AdminUserStore) {
+	m.users = users
+}
+
+/*
+RequireRole 要求指定角色的中间件（需在 RequireAuth 之后使用）
+参数:
+  - role: 最低角色
+
+返回:
+  - gin.HandlerFunc: 角色不足时返回403
+*/
+func (m *AdminAuthMiddleware) RequireRole(role string) gin.HandlerFunc {
+	required := adminRoleLevels[role]
+	return func(c *gin.Context) {
+		if adminRoleLevels[c.GetString("admin_role")] < required {
+			logger.Warn("Admin permission denied",
+				zap.String("username", c.GetString("admin_username")),
+				zap.String("role", c.GetString("admin_role")),
+				zap.String("required", role),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Permission denied",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+/*
+authenticateUser 校验管理员账号密码
+参数:
+  - username: 用户名
+  - password: 密码
+
+返回:
+  - *model.AdminUser: 账号（用户名或密码错误、账号已停用时返回nil）
+  - error: 读取账号失败时返回错误
+*/
+func (m *AdminAuthMiddleware) authenticateUser(username, password string) (*model.AdminUser, error) {
+	if m.users == nil {
+		return nil, nil
+	}
+
+	user, err := m.users.GetAdminUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := dummyPasswordHash()
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil || user.Disabled {
+		return nil, nil
+	}
+	return user, nil
+}
+
+/*
+currentUserRole 读取管理员账号的当前角色（会话续期时使用，账号已删除或停用时返回false）
+参数:
+  - username: 用户名（为空表示商户ID和密钥登录）
+
+返回:
+  - string: 角色
+  - bool: 账号是否仍可登录
+*/
+func (m *AdminAuthMiddleware) currentUserRole(username string) (string, bool) {
+	if username == "" {
+		return model.AdminRoleAdmin, true
+	}
+	if m.users == nil {
+		return "", false
+	}
+
+	user, err := m.users.GetAdminUser(username)
+	if err != nil {
+		logger.Warn("Failed to load admin user", zap.String("username", username), zap.Error(err))
+		return "", false
+	}
+	if user == nil || user.Disabled {
+		return "", false
+	}
+	return user.Role, true
+}
+
+/*
+revokeUserSessions 注销管理员账号的全部会话
+参数:
+  - username: 用户名
+
+返回:
+  - int: 注销的会话数
+*/
+func (m *AdminAuthMiddleware) revokeUserSessions(username string) int {
+	sessions, err := m.sessions.ListAdminSessions()
+	if err != nil {
+		logger.Warn("Failed to list admin sessions", zap.Error(err))
+		return 0
+	}
+
+	count := 0
+	for _, session := range sessions {
+		if session.Username == username && m.revokeSession(session.ID) {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+HandleListUsers 获取管理员账号列表
+GET /admin/users
+*/
+func (m *AdminAuthMiddleware) HandleListUsers(c *gin.Context) {
+	users := []*model.AdminUser{}
+	if m.users != nil {
+		var err error
+		if users, err = m.users.ListAdminUsers(); err != nil {
+			logger.Error("Failed to list admin users", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to list admin users",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"users":   users,
+	})
+}
+
+/*
+HandleCreateUser 创建管理员账号
+POST /admin/users
+参数:
+  - username: 用户名
+  - password: 密码（8-72位）
+  - role: 角色（viewer、operator、admin）
+*/
+func (m *AdminAuthMiddleware) HandleCreateUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" form:"username"`
+		Password string `json:"password" form:"password"`
+		Role     string `json:"role" form:"role"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	user, err := m.createUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		adminUserError(c, "Failed to create admin user", err)
+		return
+	}
+
+	logger.Info("Admin user created",
+		zap.String("username", user.Username),
+		zap.String("role", user.Role),
+		zap.String("operator", c.GetString("admin_username")),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user":    user,
+	})
+}
+
+/*
+HandleUpdateUser 修改管理员账号（修改后该账号的会话全部注销）
+POST /admin/users/update
+参数:
+  - username: 用户名
+  - role: 新角色（可选）
+  - disabled: 是否停用（可选）
+  - password: 新密码（可选）
+  - reset_two_factor: 关闭该账号的两步验证（可选，丢失手机和恢复码时由管理员重置）
+*/
+func (m *AdminAuthMiddleware) HandleUpdateUser(c *gin.Context) {
+	var req struct {
+		Username       string `json:"username" form:"username"`
+		Role           string `json:"role" form:"role"`
+		Disabled       *bool  `json:"disabled" form:"disabled"`
+		Password       string `json:"password" form:"password"`
+		ResetTwoFactor bool   `json:"reset_two_factor" form:"reset_two_factor"`
+	}
+	if err := c.ShouldBind(&req); err != nil || req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing username",
+		})
+		return
+	}
+
+	user, err := m.updateUser(req.Username, req.Role, req.Disabled, req.Password)
+	if err != nil {
+		adminUserError(c, "Failed to update admin user", err)
+		return
+	}
+	if req.ResetTwoFactor && user.TwoFactor {
+		if err := m.twoFactor.Reset(user.Username); err != nil && !errors.Is(err, ErrTwoFactorDisabled) {
+			adminUserError(c, "Failed to reset two-factor authentication", err)
+			return
+		}
+		user.TwoFactor = false
+	}
+	revoked := m.revokeUserSessions(user.Username)
+
+	logger.Info("Admin user updated",
+		zap.String("username", user.Username),
+		zap.String("role", user.Role),
+		zap.Bool("disabled", user.Disabled),
+		zap.Bool("password_changed", req.Password != ""),
+		zap.Bool("two_factor_reset", req.ResetTwoFactor),
+		zap.Int("revoked_sessions", revoked),
+		zap.String("operator", c.GetString("admin_username")),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user":    user,
+		"current": user.Username == c.GetString("admin_username"),
+	})
+}
+
+/*
+HandleDeleteUser 删除管理员账号（同时注销该账号的会话）
+POST /admin/users/delete
+参数:
+  - username: 用户名
+*/
+func (m *AdminAuthMiddleware) HandleDeleteUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" form:"username"`
+	}
+	if err := c.ShouldBind(&req); err != nil || req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing username",
+		})
+		return
+	}
+
+	if m.users == nil {
+		adminUserError(c, "Failed to delete admin user", ErrAdminUserNotFound)
+		return
+	}
+	deleted, err := m.users.DeleteAdminUser(req.Username)
+	if err == nil && !deleted {
+		err = ErrAdminUserNotFound
+	}
+	if err != nil {
+		adminUserError(c, "Failed to delete admin user", err)
+		return
+	}
+	revoked := m.revokeUserSessions(req.Username)
+
+	logger.Info("Admin user deleted",
+		zap.String("username", req.Username),
+		zap.Int("revoked_sessions", revoked),
+		zap.String("operator", c.GetString("admin_username")),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"current": req.Username == c.GetString("admin_username"),
+	})
+}
+
+// createUser 校验参数并创建管理员账号
+func (m *AdminAuthMiddleware) createUser(username, password, role string) (*model.AdminUser, error) {
+	if m.users == nil {
+		return nil, ErrAdminUserNotFound
+	}
+	if !adminUsernamePattern.MatchString(username) {
+		return nil, ErrAdminUserInvalid
+	}
+	if username == m.merchantID {
+		return nil, ErrAdminUserReserved
+	}
+	if _, ok := adminRoleLevels[role]; !ok {
+		return nil, ErrAdminRoleInvalid
+	}
+	hash, err := hashAdminPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.AdminUser{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	created, err := m.users.CreateAdminUser(user)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrAdminUserExists
+	}
+	return user, nil
+}
+
+// updateUser 修改管理员账号的角色、停用状态或密码（参数为空表示不修改）
+func (m *AdminAuthMiddleware) updateUser(username, role string, disabled *bool, password string) (*model.AdminUser, error) {
+	if m.users == nil {
+		return nil, ErrAdminUserNotFound
+	}
+	user, err := m.users.GetAdminUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrAdminUserNotFound
+	}
+
+	if role != "" {
+		if _, ok := adminRoleLevels[role]; !ok {
+			return nil, ErrAdminRoleInvalid
+		}
+		user.Role = role
+	}
+	if disabled != nil {
+		user.Disabled = *disabled
+	}
+	if password != "" {
+		if user.PasswordHash, err = hashAdminPassword(password); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := m.users.UpdateAdminUser(user)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrAdminUserNotFound
+	}
+	return user, nil
+}
+
+// hashAdminPassword 校验密码长度并生成bcrypt哈希
+func hashAdminPassword(password string) (string, error) {
+	if len(password) < minAdminPasswordLength || len(password) > maxAdminPasswordLength {
+		return "", ErrAdminPasswordWeak
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// adminUserError 返回管理员账号操作错误（参数错误返回400，账号不存在返回404，用户名已存在返回409，其他返回500）
+func adminUserError(c *gin.Context, msg string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrAdminUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAdminUserExists):
+		status = http.StatusConflict
+	case errors.Is(err, ErrAdminUserInvalid), errors.Is(err, ErrAdminUserReserved),
+		errors.Is(err, ErrAdminRoleInvalid), errors.Is(err, ErrAdminPasswordWeak):
+		status = http.StatusBadRequest
+	default:
+		logger.Error(msg, zap.Error(err))
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   msg,
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
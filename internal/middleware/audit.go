@@ -0,0 +1,156 @@
+/*
+Package middleware 审计日志
+Author: AliMPay Team
+Description: 记录管理操作及敏感接口的调用（操作人、来源、参数、结果）
+
+功能:
+  - 按路由指定审计动作名
+  - 记录查询参数及表单/JSON请求体（敏感参数脱敏）
+  - 请求处理完成后写入，包含响应状态码
+*/
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxAuditBodySize 审计记录的请求体上限
+const maxAuditBodySize = 64 * 1024
+
+// AuditRecorder 审计日志存储
+type AuditRecorder interface {
+	CreateAuditLog(log *model.AuditLog) error
+}
+
+/*
+AuditTrail 审计日志中间件
+字段:
+  - recorder: 审计日志存储
+  - redactor: 参数脱敏器（与访问日志共用规则）
+*/
+type AuditTrail struct {
+	recorder AuditRecorder
+	redactor *QueryRedactor
+}
+
+/*
+NewAuditTrail 创建审计日志中间件
+参数:
+  - recorder: 审计日志存储
+  - redactor: 参数脱敏器，为nil使用默认脱敏参数
+
+返回:
+  - *AuditTrail: 审计日志中间件实例
+*/
+func NewAuditTrail(recorder AuditRecorder, redactor *QueryRedactor) *AuditTrail {
+	if redactor == nil {
+		redactor = NewQueryRedactor(nil, nil)
+	}
+	return &AuditTrail{
+		recorder: recorder,
+		redactor: redactor,
+	}
+}
+
+/*
+Record 记录指定动作的审计日志
+参数:
+  - action: 动作名（如 order.action、session.rotate）
+
+返回:
+  - gin.HandlerFunc: 中间件处理函数
+*/
+func (a *AuditTrail) Record(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 读取JSON请求体后放回，供后续处理器使用
+		var jsonBody []byte
+		if c.Request.Body != nil && strings.Contains(c.ContentType(), "json") {
+			jsonBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(jsonBody), c.Request.Body))
+		}
+
+		c.Next()
+
+		path := c.Request.URL.Path
+		detail := make(map[string]interface{})
+		for name, values := range c.Request.URL.Query() {
+			detail[name] = a.redactValue(path, name, strings.Join(values, ","))
+		}
+		if c.Request.PostForm != nil {
+			for name, values := range c.Request.PostForm {
+				detail[name] = a.redactValue(path, name, strings.Join(values, ","))
+			}
+		}
+		if len(jsonBody) > 0 {
+			var body map[string]interface{}
+			if err := json.Unmarshal(jsonBody, &body); err == nil {
+				for name, value := range body {
+					detail[name] = a.redactValue(path, name, value)
+				}
+			}
+		}
+
+		detailJSON, _ := json.Marshal(detail)
+
+		log := &model.AuditLog{
+			Action:    action,
+			Actor:     auditActor(c),
+			IP:        c.ClientIP(),
+			UserAgent: truncateString(c.Request.UserAgent(), 255),
+			Method:    c.Request.Method,
+			Path:      path,
+			Detail:    string(detailJSON),
+			Status:    c.Writer.Status(),
+			CreatedAt: time.Now(),
+		}
+
+		if err := a.recorder.CreateAuditLog(log); err != nil {
+			logger.Error("Failed to write audit log",
+				zap.String("action", action),
+				zap.Error(err))
+		}
+	}
+}
+
+// redactValue 敏感参数替换为占位值
+func (a *AuditTrail) redactValue(path, name string, value interface{}) interface{} {
+	if a.redactor.shouldRedact(strings.TrimSuffix(path, ".php"), strings.ToLower(name)) {
+		return redactedValue
+	}
+	return value
+}
+
+// auditActor 识别操作人（管理员账号登录时记录用户名）
+func auditActor(c *gin.Context) string {
+	if username := c.GetString("admin_username"); username != "" {
+		return "user:" + username
+	}
+	if tokenID := c.GetString("admin_token_id"); tokenID != "" {
+		return "token:" + tokenID
+	}
+	if sessionID := c.GetString("admin_session_id"); sessionID != "" {
+		return "session:" + sessionID
+	}
+	if auth := GetMerchantAuth(c); auth.Authenticated() {
+		return "merchant:" + auth.MerchantID
+	}
+	return "anonymous"
+}
+
+// truncateString 截断过长的字符串
+func truncateString(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
+}
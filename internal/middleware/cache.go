@@ -64,43 +64,43 @@ func CacheMiddleware(cacheType CacheType) gin.HandlerFunc {
 
 /*
 StaticCacheMiddleware 静态资源缓存中间件
+参数:
+  - isFingerprinted: 判断资源路径（相对静态目录）是否带内容指纹
+
 功能:
-  - 根据文件扩展名设置不同的缓存策略
-  - CSS/JS: 长期缓存（1年）
-  - 图片: 长期缓存（1年）
-  - 字体: 长期缓存（1年）
-  - HTML: 短期缓存（5分钟）
+  - 根据文件扩展名设置Content-Type和缓存策略
+  - 带指纹的资源: 长期缓存（1年），内容变化后文件名随之变化
+  - 其他地址: 短期缓存（5分钟），避免升级后浏览器继续使用旧脚本
+
+使用示例:
+
+	staticGroup.Use(middleware.StaticCacheMiddleware(web.IsFingerprinted))
 */
-func StaticCacheMiddleware() gin.HandlerFunc {
+func StaticCacheMiddleware(isFingerprinted func(name string) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 
 		// 判断文件类型
 		switch {
 		case strings.HasSuffix(path, ".css"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
 			c.Header("Content-Type", "text/css; charset=utf-8")
 		case strings.HasSuffix(path, ".js"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
 			c.Header("Content-Type", "application/javascript; charset=utf-8")
 		case strings.HasSuffix(path, ".png"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
 			c.Header("Content-Type", "image/png")
 		case strings.HasSuffix(path, ".jpg"), strings.HasSuffix(path, ".jpeg"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
 			c.Header("Content-Type", "image/jpeg")
 		case strings.HasSuffix(path, ".gif"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
 			c.Header("Content-Type", "image/gif")
 		case strings.HasSuffix(path, ".svg"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
 			c.Header("Content-Type", "image/svg+xml")
-		case strings.HasSuffix(path, ".woff"), strings.HasSuffix(path, ".woff2"):
-			c.Header("Cache-Control", "public, max-age=31536000, immutable")
-		case strings.HasSuffix(path, ".html"):
+		}
+
+		// 带指纹的资源内容不会变化，可长期缓存；原始地址的内容可能随升级变化，仅短期缓存
+		if isFingerprinted != nil && isFingerprinted(strings.TrimPrefix(c.Param("filepath"), "/")) {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable") // 1年
+		} else {
 			c.Header("Cache-Control", "public, max-age=300") // 5分钟
-		default:
-			c.Header("Cache-Control", "public, max-age=3600") // 1小时
 		}
 
 		c.Next()
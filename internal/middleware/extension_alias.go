@@ -0,0 +1,109 @@
+/*
+Package middleware 扩展名别名中间件
+Author: AliMPay Team
+Description: 在路由匹配前去除旧版扩展名，使所有路由自动兼容旧后缀
+
+功能:
+  - /xxx.php 在路由匹配前改写为 /xxx，路由表只需注册不带后缀的路径
+  - 新增的路由无需额外声明即可兼容旧后缀
+  - 统计旧后缀的使用次数（按路径），用于评估何时停止兼容
+
+使用示例:
+
+	handler := middleware.StripExtension(engine, ".php")
+	server := &http.Server{Handler: handler}
+*/
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxLegacyPaths 单独统计的旧后缀路径数量上限，超出后计入 otherLegacyPath（防止随机路径撑大统计表）
+const maxLegacyPaths = 100
+
+// otherLegacyPath 超出统计上限的路径汇总键
+const otherLegacyPath = "(other)"
+
+// legacyUsage 旧后缀使用统计
+var legacyUsage = struct {
+	mu    sync.Mutex
+	total uint64
+	paths map[string]uint64
+}{paths: make(map[string]uint64)}
+
+/*
+StripExtension 扩展名去除中间件
+功能:
+  - 包装HTTP处理器，在Gin路由匹配之前执行
+  - 路径以别名扩展名结尾时去除扩展名，并计入旧后缀使用统计
+
+参数:
+  - next: 下一个处理器（通常为Gin引擎）
+  - extensions: 需要去除的扩展名列表（如 ".php"）
+*/
+func StripExtension(next http.Handler, extensions ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		for _, ext := range extensions {
+			if ext == "" || !strings.HasSuffix(path, ext) || len(path) == len(ext) {
+				continue
+			}
+
+			r.URL.Path = strings.TrimSuffix(path, ext)
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = strings.TrimSuffix(r.URL.RawPath, ext)
+			}
+			recordLegacyUsage(r.URL.Path)
+			break
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordLegacyUsage 记录一次旧后缀访问
+func recordLegacyUsage(path string) {
+	legacyUsage.mu.Lock()
+	defer legacyUsage.mu.Unlock()
+
+	legacyUsage.total++
+	if _, ok := legacyUsage.paths[path]; !ok && len(legacyUsage.paths) >= maxLegacyPaths {
+		path = otherLegacyPath
+	}
+	legacyUsage.paths[path]++
+}
+
+/*
+LegacyExtensionStats 旧后缀使用统计
+返回:
+  - map[string]interface{}: total 为自启动以来的总次数，paths 为按次数降序的各路径（去除后缀后）次数
+*/
+func LegacyExtensionStats() map[string]interface{} {
+	legacyUsage.mu.Lock()
+	defer legacyUsage.mu.Unlock()
+
+	type pathCount struct {
+		Path  string `json:"path"`
+		Count uint64 `json:"count"`
+	}
+	paths := make([]pathCount, 0, len(legacyUsage.paths))
+	for path, count := range legacyUsage.paths {
+		paths = append(paths, pathCount{Path: path, Count: count})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Count != paths[j].Count {
+			return paths[i].Count > paths[j].Count
+		}
+		return paths[i].Path < paths[j].Path
+	})
+
+	return map[string]interface{}{
+		"total": legacyUsage.total,
+		"paths": paths,
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Package middleware JSON请求体中间件
+Author: AliMPay Team
+Description: 将 application/json 请求体转换为表单参数，供易支付接口统一读取
+
+功能:
+  - 自动识别 Content-Type: application/json
+  - 扁平JSON对象转换为表单参数（数字保留原始文本，保证签名一致）
+  - 后续处理器和中间件仍通过 Query/PostForm 读取参数，签名规则不变
+
+使用示例:
+
+	router.RegisterCompat(engine, "/api/submit", middleware.JSONBody(), handler)
+*/
+package middleware
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxJSONBodySize JSON请求体大小上限（1MB）
+const maxJSONBodySize = 1 << 20
+
+/*
+JSONBody JSON请求体中间件
+功能: 非JSON请求直接放行；JSON请求解析失败时返回易支付标准错误响应
+*/
+func JSONBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isJSONRequest(c.Request) {
+			c.Next()
+			return
+		}
+
+		params, err := decodeJSONParams(c.Request)
+		if err != nil {
+			logger.Warn("Invalid JSON request body",
+				zap.String("path", c.Request.URL.Path),
+				zap.String("ip", c.ClientIP()),
+				zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "Invalid JSON body",
+			})
+			return
+		}
+
+		// 写入表单缓存，后续 PostForm/ParseForm 不会再读取请求体
+		form := make(url.Values, len(params))
+		for key, value := range c.Request.URL.Query() {
+			form[key] = value
+		}
+		for key, value := range params {
+			form[key] = value
+		}
+		c.Request.PostForm = params
+		c.Request.Form = form
+
+		c.Next()
+	}
+}
+
+// isJSONRequest 判断请求体是否为JSON
+func isJSONRequest(r *http.Request) bool {
+	if r.Body == nil || r.Method == http.MethodGet {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// decodeJSONParams 解析JSON对象为参数表
+func decodeJSONParams(r *http.Request) (url.Values, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxJSONBodySize))
+	decoder.UseNumber()
+
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	params := make(url.Values, len(body))
+	for key, value := range body {
+		params.Set(key, jsonValueToString(value))
+	}
+	return params, nil
+}
+
+// jsonValueToString 将JSON值转换为参数字符串
+func jsonValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		// 嵌套对象/数组保留JSON文本
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
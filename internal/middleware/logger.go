@@ -24,11 +24,12 @@ func generateRequestID() string {
 }
 
 // Logger 日志中间件
-func Logger() gin.HandlerFunc {
+// redactor 用于在记录前脱敏查询参数（为nil时原样记录）
+func Logger(redactor *QueryRedactor) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactor.Redact(path, c.Request.URL.RawQuery)
 
 		// 生成并设置请求ID
 		requestID := c.GetHeader("X-Request-ID")
@@ -103,26 +104,6 @@ func shouldSkipLog(path string) bool {
 	return false
 }
 
-// Recovery 恢复中间件（带彩色日志）
-func Recovery() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				requestID, _ := c.Get(RequestIDKey)
-				logger.Error("Panic recovered",
-					zap.Any("request_id", requestID),
-					zap.Any("error", err),
-					zap.String("path", c.Request.URL.Path),
-					zap.String("method", c.Request.Method),
-					zap.String("ip", c.ClientIP()),
-				)
-				c.AbortWithStatus(500)
-			}
-		}()
-		c.Next()
-	}
-}
-
 // GetRequestID 从上下文获取请求ID
 func GetRequestID(c *gin.Context) string {
 	if requestID, exists := c.Get(RequestIDKey); exists {
@@ -0,0 +1,432 @@
+/*
+Package middleware 管理后台登录防暴力破解
+Author: AliMPay Team
+Description: 按客户端IP统计登录失败次数，失败过多时临时锁定，并可在连续失败后要求输入算术验证码
+
+功能:
+  - 按IP统计窗口内的登录失败次数
+  - 达到上限后锁定该IP，锁定期间拒绝登录
+  - 连续失败达到阈值后要求输入验证码（SVG图片中的算术题）
+  - 锁定、验证码错误等事件写入日志（event字段），便于告警
+*/
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/pkg/geoip"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 默认登录保护参数
+const (
+	DefaultLoginMaxAttempts = 5
+	DefaultLoginLockout     = 15 * time.Minute
+)
+
+// captchaLifetime 验证码有效期
+const captchaLifetime = 5 * time.Minute
+
+// 登录保护日志事件（用于日志告警规则匹配）
+const (
+	LoginEventFailed          = "admin_login_failed"
+	LoginEventLocked          = "admin_login_locked"
+	LoginEventLockedAttempt   = "admin_login_locked_attempt"
+	LoginEventCaptchaFailed   = "admin_login_captcha_failed"
+	LoginEventCaptchaEnabled  = "admin_login_captcha_required"
+	LoginEventTwoFactorFailed = "admin_login_2fa_failed"
+)
+
+/*
+LoginGuardOptions 登录保护配置
+字段:
+  - MaxAttempts: 统计窗口内允许的失败次数，达到后锁定（<=0 使用默认值）
+  - Lockout: 锁定时长，同时作为失败次数的统计窗口（<=0 使用默认值）
+  - CaptchaAfter: 连续失败多少次后要求验证码（<=0 不启用验证码）
+*/
+type LoginGuardOptions struct {
+	MaxAttempts  int
+	Lockout      time.Duration
+	CaptchaAfter int
+}
+
+/*
+LoginGuard 登录保护
+字段:
+  - options: 登录保护配置
+  - attempts: 按IP的失败记录
+  - captchas: 待验证的验证码（按验证码ID）
+  - mu: 互斥锁
+*/
+type LoginGuard struct {
+	options  LoginGuardOptions
+	attempts map[string]*loginAttempts
+	captchas map[string]*captchaChallenge
+	mu       sync.Mutex
+}
+
+// loginAttempts 单个IP的登录失败记录
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// captchaChallenge 验证码题目
+type captchaChallenge struct {
+	ip        string
+	answer    string
+	expiresAt time.Time
+}
+
+/*
+Captcha 验证码（渲染到登录页面）
+字段:
+  - ID: 验证码ID（随表单提交）
+  - Image: SVG图片（data URI）
+*/
+type Captcha struct {
+	ID    string
+	Image template.URL
+}
+
+/*
+LoginStatus IP的登录保护状态
+字段:
+  - IP: 客户端IP
+  - Location: IP归属地（未启用IP库时为空）
+  - Failures: 统计窗口内的失败次数
+  - LockedUntil: 锁定截止时间（未锁定时为零值）
+*/
+type LoginStatus struct {
+	IP          string    `json:"ip"`
+	Location    string    `json:"location,omitempty"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+}
+
+/*
+NewLoginGuard 创建登录保护
+参数:
+  - options: 登录保护配置
+
+返回:
+  - *LoginGuard: 登录保护实例
+*/
+func NewLoginGuard(options LoginGuardOptions) *LoginGuard {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultLoginMaxAttempts
+	}
+	if options.Lockout <= 0 {
+		options.Lockout = DefaultLoginLockout
+	}
+
+	guard := &LoginGuard{
+		options:  options,
+		attempts: make(map[string]*loginAttempts),
+		captchas: make(map[string]*captchaChallenge),
+	}
+
+	go guard.cleanup()
+
+	return guard
+}
+
+/*
+Locked 检查IP是否处于锁定状态（锁定期间的登录尝试会记录日志）
+参数:
+  - ip: 客户端IP
+
+返回:
+  - time.Duration: 剩余锁定时间（未锁定时为0）
+*/
+func (g *LoginGuard) Locked(ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record := g.current(ip)
+	if record == nil {
+		return 0
+	}
+	remaining := time.Until(record.lockedUntil)
+	if remaining <= 0 {
+		return 0
+	}
+
+	logger.Warn("Admin login attempt from locked IP",
+		zap.String("event", LoginEventLockedAttempt),
+		zap.String("ip", ip),
+		zap.Duration("remaining", remaining))
+	return remaining
+}
+
+/*
+CaptchaRequired 该IP登录是否需要验证码
+参数:
+  - ip: 客户端IP
+
+返回:
+  - bool: 需要验证码时为true
+*/
+func (g *LoginGuard) CaptchaRequired(ip string) bool {
+	if g.options.CaptchaAfter <= 0 {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record := g.current(ip)
+	return record != nil && record.failures >= g.options.CaptchaAfter
+}
+
+/*
+RecordFailure 记录一次登录失败
+功能: 失败次数达到上限时锁定该IP
+参数:
+  - ip: 客户端IP
+  - pid: 提交的商户ID（仅用于日志）
+
+返回:
+  - time.Duration: 本次失败触发锁定时返回锁定时长，否则为0
+*/
+func (g *LoginGuard) RecordFailure(ip, pid string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record := g.current(ip)
+	if record == nil {
+		record = &loginAttempts{}
+		g.attempts[ip] = record
+	}
+	record.failures++
+	record.lastFailure = time.Now()
+
+	logger.Warn("Failed admin login attempt",
+		zap.String("event", LoginEventFailed),
+		zap.String("ip", ip),
+		zap.String("pid", pid),
+		zap.Int("failures", record.failures))
+
+	if g.options.CaptchaAfter > 0 && record.failures == g.options.CaptchaAfter {
+		logger.Warn("Admin login captcha required",
+			zap.String("event", LoginEventCaptchaEnabled),
+			zap.String("ip", ip),
+			zap.Int("failures", record.failures))
+	}
+
+	if record.failures < g.options.MaxAttempts {
+		return 0
+	}
+
+	record.lockedUntil = time.Now().Add(g.options.Lockout)
+	logger.Warn("Admin login locked",
+		zap.String("event", LoginEventLocked),
+		zap.String("ip", ip),
+		zap.Int("failures", record.failures),
+		zap.Duration("lockout", g.options.Lockout))
+	return g.options.Lockout
+}
+
+/*
+RecordSuccess 登录成功后清除该IP的失败记录
+参数:
+  - ip: 客户端IP
+*/
+func (g *LoginGuard) RecordSuccess(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.attempts, ip)
+}
+
+/*
+NewCaptcha 为IP生成新的算术验证码
+参数:
+  - ip: 客户端IP
+
+返回:
+  - *Captcha: 验证码
+*/
+func (g *LoginGuard) NewCaptcha(ip string) *Captcha {
+	a, b := randomInt(1, 20), randomInt(1, 9)
+	question := fmt.Sprintf("%d + %d = ?", a, b)
+	answer := strconv.Itoa(a + b)
+	if randomInt(0, 1) == 1 && a > b {
+		question = fmt.Sprintf("%d - %d = ?", a, b)
+		answer = strconv.Itoa(a - b)
+	}
+
+	id := randomHex(16)
+
+	g.mu.Lock()
+	g.captchas[id] = &captchaChallenge{
+		ip:        ip,
+		answer:    answer,
+		expiresAt: time.Now().Add(captchaLifetime),
+	}
+	g.mu.Unlock()
+
+	return &Captcha{
+		ID:    id,
+		Image: template.URL("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(captchaSVG(question)))),
+	}
+}
+
+/*
+VerifyCaptcha 校验验证码（每个验证码只能使用一次）
+参数:
+  - ip: 客户端IP
+  - id: 验证码ID
+  - answer: 用户输入的答案
+
+返回:
+  - bool: 验证码正确时为true
+*/
+func (g *LoginGuard) VerifyCaptcha(ip, id, answer string) bool {
+	g.mu.Lock()
+	challenge, exists := g.captchas[id]
+	delete(g.captchas, id)
+	g.mu.Unlock()
+
+	valid := exists && challenge.ip == ip && time.Now().Before(challenge.expiresAt) &&
+		strings.TrimSpace(answer) == challenge.answer
+	if !valid {
+		logger.Warn("Admin login captcha failed",
+			zap.String("event", LoginEventCaptchaFailed),
+			zap.String("ip", ip))
+	}
+	return valid
+}
+
+/*
+Statuses 当前有失败记录的IP
+返回:
+  - []LoginStatus: IP的失败次数和锁定状态
+*/
+func (g *LoginGuard) Statuses() []LoginStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	statuses := make([]LoginStatus, 0, len(g.attempts))
+	for ip := range g.attempts {
+		record := g.current(ip)
+		if record == nil {
+			continue
+		}
+		status := LoginStatus{IP: ip, Location: geoip.Describe(ip), Failures: record.failures}
+		if time.Now().Before(record.lockedUntil) {
+			status.LockedUntil = record.lockedUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+/*
+Unlock 清除IP的失败记录和锁定
+参数:
+  - ip: 客户端IP
+
+返回:
+  - bool: 该IP是否有失败记录
+*/
+func (g *LoginGuard) Unlock(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, exists := g.attempts[ip]
+	delete(g.attempts, ip)
+	return exists
+}
+
+// current 获取IP仍在统计窗口内的失败记录（锁定结束或超出统计窗口的记录被清除），调用方需持有锁
+func (g *LoginGuard) current(ip string) *loginAttempts {
+	record, exists := g.attempts[ip]
+	if !exists {
+		return nil
+	}
+
+	now := time.Now()
+	if now.After(record.lastFailure.Add(g.options.Lockout)) && now.After(record.lockedUntil) {
+		delete(g.attempts, ip)
+		return nil
+	}
+	return record
+}
+
+/*
+cleanup 清理过期的失败记录和验证码
+定时任务，每分钟运行一次
+*/
+func (g *LoginGuard) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		g.mu.Lock()
+		for ip := range g.attempts {
+			g.current(ip)
+		}
+		now := time.Now()
+		for id, challenge := range g.captchas {
+			if now.After(challenge.expiresAt) {
+				delete(g.captchas, id)
+			}
+		}
+		g.mu.Unlock()
+	}
+}
+
+// captchaSVG 生成验证码图片：字符随机偏移旋转并叠加干扰线，避免直接从页面文本读取答案
+func captchaSVG(question string) string {
+	var b strings.Builder
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="150" height="48" viewBox="0 0 150 48">`)
+	b.WriteString(`<rect width="150" height="48" fill="#f7fafc"/>`)
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#cbd5e0" stroke-width="1"/>`,
+			randomInt(0, 40), randomInt(0, 48), randomInt(110, 150), randomInt(0, 48))
+	}
+	x := 10
+	for _, ch := range question {
+		if ch == ' ' {
+			x += 6
+			continue
+		}
+		y := randomInt(28, 36)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" transform="rotate(%d %d %d)" font-family="monospace" font-size="22" font-weight="bold" fill="#4a5568">%c</text>`,
+			x, y, randomInt(-20, 20), x, y, ch)
+		x += 16
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// randomInt 生成 [low, high] 范围内的随机整数
+func randomInt(low, high int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(high-low+1)))
+	if err != nil {
+		return low
+	}
+	return low + int(n.Int64())
+}
+
+// randomHex 生成随机十六进制字符串
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
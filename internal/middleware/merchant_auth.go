@@ -0,0 +1,484 @@
+/*
+Package middleware 商户认证中间件
+Author: AliMPay Team
+Description: 为公开API提供统一的商户认证层
+
+功能:
+  - 兼容易支付的 MD5 签名认证（sign）
+  - 兼容 pid/key 参数认证（key）
+  - API令牌认证（Authorization: Bearer xxx）
+  - mTLS 客户端证书认证（按证书SHA-256指纹匹配）
+  - 每个商户可单独选择允许的认证方式
+  - 每个商户可配置来源IP白名单，拒绝其他地址的请求
+
+使用示例:
+
+	auth := middleware.NewMerchantAuth(credentials...)
+	router.RegisterCompat(engine, "/api/query", auth.Require(), handler)
+*/
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 认证方式
+const (
+	AuthMethodSign  = "sign"  // MD5签名
+	AuthMethodKey   = "key"   // pid + key 参数
+	AuthMethodToken = "token" // Bearer令牌
+	AuthMethodMTLS  = "mtls"  // 客户端证书
+)
+
+// MerchantAuthKey 认证结果在上下文中的键名
+const MerchantAuthKey = "merchant_auth"
+
+// DefaultAuthMethods 未配置时默认允许的认证方式（与旧版行为一致）
+var DefaultAuthMethods = []string{AuthMethodSign, AuthMethodKey}
+
+/*
+MerchantCredential 商户凭据
+字段:
+  - ID: 商户ID
+  - Key: 商户密钥
+  - PreviousKey: 轮换前的商户密钥（过渡期内签名和 key 参数认证仍可使用）
+  - PreviousKeyExpiry: 旧密钥过渡期截止时间
+  - Methods: 允许的认证方式（为空则使用 DefaultAuthMethods）
+  - APITokens: 允许的Bearer令牌
+  - ClientCertFingerprints: 允许的客户端证书SHA-256指纹（十六进制）
+  - AllowedIPs: 允许的来源IP或网段（为空不限制，见 RestrictIPs）
+  - SignType: 签名类型（MD5 同时接受 MD5 和 HMAC-SHA256 签名；HMAC-SHA256 只接受 HMAC-SHA256 签名；为空视为 MD5）
+*/
+type MerchantCredential struct {
+	ID                     string
+	Key                    string
+	PreviousKey            string
+	PreviousKeyExpiry      time.Time
+	Methods                []string
+	APITokens              []string
+	ClientCertFingerprints []string
+	AllowedIPs             []string
+	SignType               string
+}
+
+/*
+MerchantAuthResult 认证结果
+字段:
+  - MerchantID: 通过认证的商户ID（未通过则为空）
+  - Method: 通过认证使用的方式
+  - Presented: 请求是否携带了任何凭据
+*/
+type MerchantAuthResult struct {
+	MerchantID string
+	Method     string
+	Presented  bool
+}
+
+// Authenticated 是否已通过认证
+func (r *MerchantAuthResult) Authenticated() bool {
+	return r != nil && r.MerchantID != ""
+}
+
+/*
+MerchantAuth 商户认证器
+字段:
+  - merchants: 商户凭据映射 (merchant_id -> credential)
+  - mu: 读写锁保护（沙箱凭据可在运行时重新签发）
+*/
+type MerchantAuth struct {
+	merchants map[string]*MerchantCredential
+	mu        sync.RWMutex
+}
+
+/*
+NewMerchantAuth 创建商户认证器
+参数:
+  - credentials: 商户凭据列表
+
+返回:
+  - *MerchantAuth: 认证器实例
+*/
+func NewMerchantAuth(credentials ...MerchantCredential) *MerchantAuth {
+	auth := &MerchantAuth{
+		merchants: make(map[string]*MerchantCredential),
+	}
+
+	for _, cred := range credentials {
+		auth.SetCredential(cred)
+	}
+
+	return auth
+}
+
+/*
+SetCredential 添加或替换商户凭据
+功能: 运行时更新商户凭据（如重新签发沙箱密钥），旧凭据立即失效
+参数:
+  - cred: 商户凭据（Methods 为空则使用 DefaultAuthMethods）
+*/
+func (a *MerchantAuth) SetCredential(cred MerchantCredential) {
+	if len(cred.Methods) == 0 {
+		cred.Methods = DefaultAuthMethods
+	}
+	for j, fp := range cred.ClientCertFingerprints {
+		cred.ClientCertFingerprints[j] = normalizeFingerprint(fp)
+	}
+
+	a.mu.Lock()
+	a.merchants[cred.ID] = &cred
+	a.mu.Unlock()
+
+	logger.Info("Merchant auth configured",
+		zap.String("merchant_id", cred.ID),
+		zap.Strings("methods", cred.Methods),
+		zap.Strings("allowed_ips", cred.AllowedIPs),
+		zap.String("sign_type", cred.responseSignType()))
+}
+
+/*
+SetKey 更新商户密钥
+功能: 商户密钥轮换后调用，新密钥立即生效，旧密钥在过渡期内仍可使用；其他凭据（认证方式、令牌、证书）不变
+参数:
+  - id: 商户ID
+  - key: 新密钥
+  - previousKey: 旧密钥（为空表示旧密钥立即失效）
+  - previousKeyExpiry: 旧密钥过渡期截止时间
+*/
+func (a *MerchantAuth) SetKey(id, key, previousKey string, previousKeyExpiry time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cred, ok := a.merchants[id]
+	if !ok {
+		return
+	}
+	// 复制后替换，避免与正在认证的请求竞争
+	updated := *cred
+	updated.Key = key
+	updated.PreviousKey = previousKey
+	updated.PreviousKeyExpiry = previousKeyExpiry
+	a.merchants[id] = &updated
+}
+
+/*
+HasMerchant 是否为已配置的商户（含运行时签发的沙箱商户）
+参数:
+  - id: 商户ID
+
+返回:
+  - bool: 已配置返回true
+*/
+func (a *MerchantAuth) HasMerchant(id string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.merchants[id]
+	return ok
+}
+
+/*
+Authenticate 认证中间件（不拦截）
+功能: 解析请求凭据并将结果写入上下文，由后续处理器决定是否需要认证
+*/
+func (a *MerchantAuth) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a.resolve(c)
+		c.Next()
+	}
+}
+
+/*
+Require 要求认证的中间件
+功能: 未通过认证时直接返回易支付标准错误响应
+*/
+func (a *MerchantAuth) Require() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := a.resolve(c)
+		if !result.Authenticated() {
+			msg := "Invalid merchant credentials"
+			if !result.Presented {
+				msg = "Missing merchant credentials"
+			}
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  msg,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+/*
+RestrictIPs 来源IP白名单中间件
+功能: 按请求参数 pid（未传时按令牌、证书认证的商户）查找商户，商户配置了来源IP白名单且请求IP不在其中时拒绝请求。
+错误响应按商户的签名类型使用商户密钥签名（sign 覆盖 code、msg、pid、ip），商户可据此确认拒绝来自本系统
+参数:
+  - trustProxy: 是否按 X-Forwarded-For 等请求头确定来源IP（已配置可信代理时为true；
+    否则任何人都能伪造请求头绕过白名单，只按直连地址检查）
+*/
+func (a *MerchantAuth) RestrictIPs(trustProxy bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred := a.requestMerchant(c)
+		ip := c.RemoteIP()
+		if trustProxy {
+			ip = c.ClientIP()
+		}
+		if cred == nil || len(cred.AllowedIPs) == 0 || utils.IPInList(ip, cred.AllowedIPs) {
+			c.Next()
+			return
+		}
+
+		logger.Warn("Merchant request from disallowed ip",
+			zap.String("path", c.Request.URL.Path),
+			zap.String("pid", cred.ID),
+			zap.String("ip", ip))
+
+		response := map[string]string{
+			"code":      "-1",
+			"msg":       "Request IP is not allowed for this merchant",
+			"pid":       cred.ID,
+			"ip":        ip,
+			"sign_type": cred.responseSignType(),
+		}
+		c.AbortWithStatusJSON(http.StatusOK, gin.H{
+			"code":      -1,
+			"msg":       response["msg"],
+			"pid":       cred.ID,
+			"ip":        ip,
+			"sign":      utils.GenerateSign(response, cred.Key),
+			"sign_type": response["sign_type"],
+		})
+	}
+}
+
+/*
+GetMerchantAuth 从上下文获取认证结果
+返回:
+  - *MerchantAuthResult: 认证结果（未经过认证中间件时返回空结果）
+*/
+func GetMerchantAuth(c *gin.Context) *MerchantAuthResult {
+	if v, exists := c.Get(MerchantAuthKey); exists {
+		if result, ok := v.(*MerchantAuthResult); ok {
+			return result
+		}
+	}
+	return &MerchantAuthResult{}
+}
+
+// resolve 解析认证结果（同一请求只解析一次）
+func (a *MerchantAuth) resolve(c *gin.Context) *MerchantAuthResult {
+	if v, exists := c.Get(MerchantAuthKey); exists {
+		if result, ok := v.(*MerchantAuthResult); ok {
+			return result
+		}
+	}
+
+	result := a.authenticate(c)
+	c.Set(MerchantAuthKey, result)
+
+	if result.Presented && !result.Authenticated() {
+		logger.Warn("Merchant authentication failed",
+			zap.String("path", c.Request.URL.Path),
+			zap.String("pid", requestParam(c, "pid")),
+			zap.String("ip", c.ClientIP()))
+	}
+
+	return result
+}
+
+// authenticate 依次尝试各种认证方式
+func (a *MerchantAuth) authenticate(c *gin.Context) *MerchantAuthResult {
+	result := &MerchantAuthResult{}
+
+	// 1. Bearer令牌（令牌本身即可确定商户）
+	if token := bearerToken(c); token != "" {
+		result.Presented = true
+		if cred := a.matchToken(token); cred != nil {
+			result.MerchantID = cred.ID
+			result.Method = AuthMethodToken
+			return result
+		}
+	}
+
+	// 2. mTLS客户端证书（证书指纹即可确定商户）
+	if fp := clientCertFingerprint(c); fp != "" {
+		if cred := a.matchCertificate(fp); cred != nil {
+			result.Presented = true
+			result.MerchantID = cred.ID
+			result.Method = AuthMethodMTLS
+			return result
+		}
+	}
+
+	// 3. 基于pid的参数认证
+	pid := requestParam(c, "pid")
+	a.mu.RLock()
+	cred := a.merchants[pid]
+	a.mu.RUnlock()
+
+	if key := requestParam(c, "key"); key != "" {
+		result.Presented = true
+		if cred != nil && cred.allows(AuthMethodKey) && cred.matchKey(func(k string) bool { return utils.SecureCompare(key, k) }) {
+			result.MerchantID = cred.ID
+			result.Method = AuthMethodKey
+			return result
+		}
+	}
+
+	if requestParam(c, "sign") != "" {
+		result.Presented = true
+		params := requestParams(c)
+		if cred != nil && cred.allows(AuthMethodSign) && cred.acceptsSignType(params["sign_type"]) && cred.matchKey(func(k string) bool { return utils.VerifySign(params, k) }) {
+			result.MerchantID = cred.ID
+			result.Method = AuthMethodSign
+			return result
+		}
+	}
+
+	return result
+}
+
+// requestMerchant 请求所属商户的凭据（未知商户返回nil）
+func (a *MerchantAuth) requestMerchant(c *gin.Context) *MerchantCredential {
+	pid := requestParam(c, "pid")
+	if pid == "" {
+		pid = a.resolve(c).MerchantID
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.merchants[pid]
+}
+
+// matchToken 根据令牌查找商户
+func (a *MerchantAuth) matchToken(token string) *MerchantCredential {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, cred := range a.merchants {
+		if !cred.allows(AuthMethodToken) {
+			continue
+		}
+		for _, t := range cred.APITokens {
+			if t != "" && utils.SecureCompare(token, t) {
+				return cred
+			}
+		}
+	}
+	return nil
+}
+
+// matchCertificate 根据证书指纹查找商户
+func (a *MerchantAuth) matchCertificate(fingerprint string) *MerchantCredential {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, cred := range a.merchants {
+		if !cred.allows(AuthMethodMTLS) {
+			continue
+		}
+		for _, fp := range cred.ClientCertFingerprints {
+			if utils.SecureCompare(fp, fingerprint) {
+				return cred
+			}
+		}
+	}
+	return nil
+}
+
+// allows 商户是否允许该认证方式
+func (m *MerchantCredential) allows(method string) bool {
+	for _, allowed := range m.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsSignType 商户是否接受请求的签名类型（要求 HMAC-SHA256 的商户不接受MD5签名）
+func (m *MerchantCredential) acceptsSignType(signType string) bool {
+	normalized, ok := utils.NormalizeSignType(signType)
+	return ok && (!utils.IsHMACSignType(m.SignType) || normalized == utils.SignTypeHMACSHA256)
+}
+
+// responseSignType 签名响应使用的签名类型
+func (m *MerchantCredential) responseSignType() string {
+	if utils.IsHMACSignType(m.SignType) {
+		return utils.SignTypeHMACSHA256
+	}
+	return utils.SignTypeMD5
+}
+
+// matchKey 使用商户密钥验证请求，过渡期内也尝试旧密钥（使用旧密钥时记录日志，便于确认商户是否已更换密钥）
+func (m *MerchantCredential) matchKey(verify func(key string) bool) bool {
+	if verify(m.Key) {
+		return true
+	}
+	if m.PreviousKey == "" || !time.Now().Before(m.PreviousKeyExpiry) || !verify(m.PreviousKey) {
+		return false
+	}
+
+	logger.Info("Request authenticated with previous merchant key", zap.String("pid", m.ID))
+	return true
+}
+
+// bearerToken 从Authorization头获取Bearer令牌
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// clientCertFingerprint 获取已验证客户端证书的SHA-256指纹
+func clientCertFingerprint(c *gin.Context) string {
+	tlsState := c.Request.TLS
+	if tlsState == nil || len(tlsState.VerifiedChains) == 0 || len(tlsState.PeerCertificates) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(tlsState.PeerCertificates[0].Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint 规范化指纹格式（去除冒号、转小写）
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+}
+
+// requestParam 获取参数（支持GET和POST）
+func requestParam(c *gin.Context, key string) string {
+	value := c.Query(key)
+	if value == "" {
+		value = c.PostForm(key)
+	}
+	return value
+}
+
+// requestParams 获取全部请求参数（POST表单覆盖查询参数）
+func requestParams(c *gin.Context) map[string]string {
+	params := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	if err := c.Request.ParseForm(); err == nil {
+		for key, values := range c.Request.PostForm {
+			if len(values) > 0 {
+				params[key] = values[0]
+			}
+		}
+	}
+	return params
+}
@@ -0,0 +1,266 @@
+/*
+Package middleware 限流中间件
+Author: AliMPay Team
+Description: 基于令牌桶的请求限流，保护SQLite后端免受滥用请求冲击
+
+功能:
+  - 按客户端IP限流
+  - 按商户ID限流（已认证的商户或已配置商户的 pid 参数；未配置的 pid 只受IP限额约束）
+  - 可选的软限流：短暂超限的请求排队等待，而不是立即拒绝
+  - 超限返回 429 Too Many Requests
+  - 自动清理长时间未活动的令牌桶
+
+使用示例:
+
+	limiter := middleware.NewRateLimiter(middleware.RateLimitOptions{
+	    IPRate: 10, IPBurst: 20,
+	    MerchantRate: 50, MerchantBurst: 100,
+	    KnownMerchant: merchantAuth.HasMerchant,
+	})
+	router.RegisterCompat(engine, "/submit", limiter.Limit(), handler)
+*/
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// bucketIdleTimeout 令牌桶空闲多久后被清理
+const bucketIdleTimeout = 10 * time.Minute
+
+/*
+RateLimitOptions 限流配置
+字段:
+  - IPRate: 每个IP每秒允许的请求数（<=0 不限制）
+  - IPBurst: 每个IP允许的突发请求数
+  - MerchantRate: 每个商户每秒允许的请求数（<=0 不限制）
+  - MerchantBurst: 每个商户允许的突发请求数
+  - MaxWait: 超限请求最多排队等待的时间（0 表示立即拒绝），等待更久的请求返回429
+  - KnownMerchant: 判断 pid 是否为已配置的商户（为nil时只按已认证的商户限流）
+*/
+type RateLimitOptions struct {
+	IPRate        float64
+	IPBurst       int
+	MerchantRate  float64
+	MerchantBurst int
+	MaxWait       time.Duration
+	KnownMerchant func(pid string) bool
+}
+
+/*
+RateLimiter 限流器
+字段:
+  - ip: 按IP的令牌桶集合
+  - merchant: 按商户的令牌桶集合
+  - maxWait: 超限请求最多排队等待的时间
+  - knownMerchant: 判断 pid 是否为已配置的商户
+*/
+type RateLimiter struct {
+	ip            *bucketSet
+	merchant      *bucketSet
+	maxWait       time.Duration
+	knownMerchant func(pid string) bool
+}
+
+/*
+NewRateLimiter 创建限流器
+参数:
+  - opts: 限流配置
+
+返回:
+  - *RateLimiter: 限流器实例
+*/
+func NewRateLimiter(opts RateLimitOptions) *RateLimiter {
+	limiter := &RateLimiter{
+		ip:            newBucketSet(opts.IPRate, opts.IPBurst),
+		merchant:      newBucketSet(opts.MerchantRate, opts.MerchantBurst),
+		maxWait:       opts.MaxWait,
+		knownMerchant: opts.KnownMerchant,
+	}
+
+	// 启动空闲令牌桶清理任务
+	go limiter.cleanupIdleBuckets()
+
+	logger.Info("Rate limiter configured",
+		zap.Float64("ip_rate", opts.IPRate),
+		zap.Int("ip_burst", opts.IPBurst),
+		zap.Float64("merchant_rate", opts.MerchantRate),
+		zap.Int("merchant_burst", opts.MerchantBurst),
+		zap.Duration("max_wait", opts.MaxWait))
+
+	return limiter
+}
+
+/*
+Limit 限流中间件
+功能: 依次检查IP和商户限额，任一超限即返回429；配置了 MaxWait 时，
+需等待的时间不超过 MaxWait 的请求预留令牌后排队等待，再继续处理
+*/
+func (l *RateLimiter) Limit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		ok, wait := l.ip.reserve(ip, l.maxWait)
+		if !ok {
+			l.reject(c, "ip", ip, wait)
+			return
+		}
+
+		if merchant := l.merchantKey(c); merchant != "" {
+			ok, merchantWait := l.merchant.reserve(merchant, l.maxWait)
+			if !ok {
+				l.reject(c, "merchant", merchant, merchantWait)
+				return
+			}
+			wait = max(wait, merchantWait)
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				// 客户端已断开
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// merchantKey 商户限额的键：已通过认证时为认证的商户，否则为已配置商户的 pid 参数。
+// 未配置的 pid 不计入商户限额（只受IP限额约束），避免轮换随机 pid 绕过限额并不断创建令牌桶
+func (l *RateLimiter) merchantKey(c *gin.Context) string {
+	if auth := GetMerchantAuth(c); auth.Authenticated() {
+		return auth.MerchantID
+	}
+
+	pid := requestParam(c, "pid")
+	if pid == "" || l.knownMerchant == nil || !l.knownMerchant(pid) {
+		return ""
+	}
+	return pid
+}
+
+// reject 返回429响应
+func (l *RateLimiter) reject(c *gin.Context, scope, key string, retryAfter time.Duration) {
+	logger.Warn("Rate limit exceeded",
+		zap.String("scope", scope),
+		zap.String("key", key),
+		zap.String("path", c.Request.URL.Path))
+
+	c.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"code": -1,
+		"msg":  "Too many requests, please retry later",
+	})
+}
+
+// cleanupIdleBuckets 定期清理空闲的令牌桶
+func (l *RateLimiter) cleanupIdleBuckets() {
+	ticker := time.NewTicker(bucketIdleTimeout)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.ip.cleanup(bucketIdleTimeout)
+		l.merchant.cleanup(bucketIdleTimeout)
+	}
+}
+
+/*
+tokenBucket 令牌桶
+字段:
+  - tokens: 当前令牌数
+  - last: 上次补充令牌的时间
+*/
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+/*
+bucketSet 按键区分的令牌桶集合
+字段:
+  - rate: 每秒补充的令牌数
+  - burst: 桶容量
+  - buckets: 令牌桶映射 (key -> bucket)
+  - mu: 互斥锁
+*/
+type bucketSet struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	mu      sync.Mutex
+}
+
+// newBucketSet 创建令牌桶集合
+func newBucketSet(rate float64, burst int) *bucketSet {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &bucketSet{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// reserve 预留一个令牌
+// 有可用令牌时立即允许；否则需等待的时间不超过 maxWait 时预留令牌（令牌数可为负，之后的请求顺延），
+// 返回 true 和需等待的时间；超过 maxWait 时不消耗令牌，返回 false 和建议的重试时间
+func (s *bucketSet) reserve(key string, maxWait time.Duration) (bool, time.Duration) {
+	if s.rate <= 0 {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	bucket, exists := s.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: s.burst, last: now}
+		s.buckets[key] = bucket
+	}
+
+	// 按流逝时间补充令牌
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(s.burst, bucket.tokens+elapsed*s.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / s.rate * float64(time.Second))
+	if maxWait > 0 && wait <= maxWait {
+		bucket.tokens--
+		return true, wait
+	}
+	return false, wait
+}
+
+// cleanup 删除空闲超过 idle 的令牌桶
+func (s *bucketSet) cleanup(idle time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.last) > idle {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Package middleware 崩溃恢复
+Author: AliMPay Team
+Description: 捕获请求处理中的panic，记录堆栈并持久化为崩溃报告
+
+功能:
+  - 捕获panic并返回500，避免单个请求导致进程退出
+  - 记录panic值、完整堆栈及请求上下文（请求ID、方法、路径、脱敏后的查询参数、来源）
+  - 崩溃报告写入存储，供 /health 与管理后台查看最近的崩溃
+*/
+package middleware
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxCrashStackSize 崩溃报告保存的堆栈上限
+const maxCrashStackSize = 64 * 1024
+
+// CrashRecorder 崩溃报告存储
+type CrashRecorder interface {
+	CreateCrashReport(report *model.CrashReport) error
+}
+
+/*
+Recovery 恢复中间件
+参数:
+  - recorder: 崩溃报告存储，为nil时只写日志
+  - redactor: 参数脱敏器（与访问日志共用规则），为nil使用默认脱敏参数
+
+返回:
+  - gin.HandlerFunc: 中间件处理函数
+*/
+func Recovery(recorder CrashRecorder, redactor *QueryRedactor) gin.HandlerFunc {
+	if redactor == nil {
+		redactor = NewQueryRedactor(nil, nil)
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			stack := debug.Stack()
+			if len(stack) > maxCrashStackSize {
+				stack = stack[:maxCrashStackSize]
+			}
+
+			report := &model.CrashReport{
+				RequestID: GetRequestID(c),
+				Method:    c.Request.Method,
+				Path:      c.Request.URL.Path,
+				Query:     redactor.Redact(c.Request.URL.Path, c.Request.URL.RawQuery),
+				IP:        c.ClientIP(),
+				UserAgent: c.Request.UserAgent(),
+				Error:     fmt.Sprint(err),
+				Stack:     string(stack),
+				CreatedAt: time.Now(),
+			}
+
+			logger.Error("Panic recovered",
+				zap.String("request_id", report.RequestID),
+				zap.String("error", report.Error),
+				zap.String("path", report.Path),
+				zap.String("query", report.Query),
+				zap.String("method", report.Method),
+				zap.String("ip", report.IP),
+				zap.String("stack", report.Stack),
+			)
+
+			if recorder != nil {
+				if recordErr := recorder.CreateCrashReport(report); recordErr != nil {
+					logger.Error("Failed to save crash report",
+						zap.String("request_id", report.RequestID),
+						zap.Error(recordErr))
+				}
+			}
+
+			c.AbortWithStatus(500)
+		}()
+		c.Next()
+	}
+}
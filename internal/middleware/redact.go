@@ -0,0 +1,147 @@
+/*
+Package middleware 日志参数脱敏
+Author: AliMPay Team
+Description: 访问日志记录前去除查询字符串中的敏感参数
+
+功能:
+  - 全局敏感参数列表（key、sign、动态验证码、私钥等）
+  - 按接口路径追加额外的敏感参数
+  - 保持原查询字符串的参数顺序，仅替换参数值
+*/
+package middleware
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactedValue 脱敏后的占位值
+const redactedValue = "***"
+
+// DefaultRedactParams 默认脱敏的参数名
+var DefaultRedactParams = []string{
+	"key",
+	"sign",
+	"token",
+	"password",
+	"otp",
+	"private_key",
+	"app_private_key",
+	"alipay_public_key",
+}
+
+/*
+RedactRule 按路径的脱敏规则
+字段:
+  - Path: 接口路径，支持以 "/*" 结尾的前缀匹配（如 /admin/*）
+  - Params: 该路径额外需要脱敏的参数名
+*/
+type RedactRule struct {
+	Path   string
+	Params []string
+}
+
+/*
+QueryRedactor 查询字符串脱敏器
+字段:
+  - global: 所有路径都脱敏的参数
+  - rules: 按路径的脱敏规则
+*/
+type QueryRedactor struct {
+	global map[string]bool
+	rules  []compiledRedactRule
+}
+
+// compiledRedactRule 预处理后的路径规则
+type compiledRedactRule struct {
+	path   string
+	prefix bool
+	params map[string]bool
+}
+
+/*
+NewQueryRedactor 创建查询字符串脱敏器
+参数:
+  - params: 全局脱敏参数（为空使用 DefaultRedactParams）
+  - rules: 按路径的脱敏规则
+
+返回:
+  - *QueryRedactor: 脱敏器实例
+*/
+func NewQueryRedactor(params []string, rules []RedactRule) *QueryRedactor {
+	if len(params) == 0 {
+		params = DefaultRedactParams
+	}
+
+	redactor := &QueryRedactor{
+		global: toParamSet(params),
+	}
+
+	for _, rule := range rules {
+		compiled := compiledRedactRule{
+			path:   strings.TrimSuffix(rule.Path, "/*"),
+			prefix: strings.HasSuffix(rule.Path, "/*"),
+			params: toParamSet(rule.Params),
+		}
+		redactor.rules = append(redactor.rules, compiled)
+	}
+
+	return redactor
+}
+
+/*
+Redact 脱敏查询字符串
+参数:
+  - path: 请求路径
+  - rawQuery: 原始查询字符串
+
+返回:
+  - string: 敏感参数值被替换为 *** 的查询字符串
+*/
+func (r *QueryRedactor) Redact(path, rawQuery string) string {
+	if r == nil || rawQuery == "" {
+		return rawQuery
+	}
+
+	path = strings.TrimSuffix(path, ".php")
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		name, _, hasValue := strings.Cut(pair, "=")
+		if !hasValue {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if r.shouldRedact(path, strings.ToLower(name)) {
+			pairs[i] = pair[:strings.Index(pair, "=")+1] + redactedValue
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// shouldRedact 判断参数是否需要脱敏
+func (r *QueryRedactor) shouldRedact(path, name string) bool {
+	if r.global[name] {
+		return true
+	}
+
+	for _, rule := range r.rules {
+		matched := path == rule.path || (rule.prefix && strings.HasPrefix(path, rule.path+"/"))
+		if matched && rule.params[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toParamSet 参数名列表转为集合（大小写不敏感）
+func toParamSet(params []string) map[string]bool {
+	set := make(map[string]bool, len(params))
+	for _, p := range params {
+		set[strings.ToLower(strings.TrimSpace(p))] = true
+	}
+	return set
+}
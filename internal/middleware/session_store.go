@@ -0,0 +1,417 @@
+/*
+Package middleware 管理后台会话存储
+Author: AliMPay Team
+Description: 管理后台session和刷新令牌的存储后端，内存存储重启后会话丢失，数据库、Redis存储重启或发布后已登录的会话仍有效
+
+后端:
+  - memory: 进程内存（单实例，重启后需重新登录）
+  - database: SQLite 表 admin_sessions、admin_refresh_tokens（*database.DB 实现 SessionStore）
+  - redis: Redis键（多实例部署共享会话）
+*/
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 会话存储后端（配置 admin.session_store）
+const (
+	SessionStoreMemory   = "memory"
+	SessionStoreDatabase = "database"
+	SessionStoreRedis    = "redis"
+)
+
+/*
+SessionStore 会话存储
+功能: 保存session和刷新令牌，过期判断（最长有效期、无操作超时）由 AdminAuthMiddleware 完成，
+存储只需按 DeleteExpiredAdminSessions 定期清理；Get 方法在记录不存在时返回 nil, nil
+*/
+type SessionStore interface {
+	SaveAdminSession(session *model.AdminSession) error
+	GetAdminSession(token string) (*model.AdminSession, error)
+	ListAdminSessions() ([]*model.AdminSession, error)
+	DeleteAdminSession(token string) error
+	SaveAdminRefreshToken(refresh *model.AdminRefreshToken) error
+	GetAdminRefreshToken(token string) (*model.AdminRefreshToken, error)
+	DeleteAdminRefreshToken(token string) error
+	DeleteExpiredAdminSessions(idleTimeout time.Duration) (int64, error)
+	ClearAdminSessions() error
+}
+
+/*
+MemorySessionStore 内存会话存储
+字段:
+  - sessions: session（按令牌）
+  - refreshTokens: 刷新令牌（按令牌）
+  - mu: 读写锁
+*/
+type MemorySessionStore struct {
+	sessions      map[string]model.AdminSession
+	refreshTokens map[string]model.AdminRefreshToken
+	mu            sync.RWMutex
+}
+
+/*
+NewMemorySessionStore 创建内存会话存储
+返回:
+  - *MemorySessionStore: 内存会话存储
+*/
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions:      make(map[string]model.AdminSession),
+		refreshTokens: make(map[string]model.AdminRefreshToken),
+	}
+}
+
+// SaveAdminSession 保存session（保存副本，调用方修改后需再次保存）
+func (s *MemorySessionStore) SaveAdminSession(session *model.AdminSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.Token] = *session
+	return nil
+}
+
+// GetAdminSession 获取session
+func (s *MemorySessionStore) GetAdminSession(token string) (*model.AdminSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[token]
+	if !exists {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// ListAdminSessions 获取全部session
+func (s *MemorySessionStore) ListAdminSessions() ([]*model.AdminSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]*model.AdminSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+// DeleteAdminSession 删除session
+func (s *MemorySessionStore) DeleteAdminSession(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+	return nil
+}
+
+// SaveAdminRefreshToken 保存刷新令牌
+func (s *MemorySessionStore) SaveAdminRefreshToken(refresh *model.AdminRefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[refresh.Token] = *refresh
+	return nil
+}
+
+// GetAdminRefreshToken 获取刷新令牌
+func (s *MemorySessionStore) GetAdminRefreshToken(token string) (*model.AdminRefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	refresh, exists := s.refreshTokens[token]
+	if !exists {
+		return nil, nil
+	}
+	return &refresh, nil
+}
+
+// DeleteAdminRefreshToken 删除刷新令牌
+func (s *MemorySessionStore) DeleteAdminRefreshToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.refreshTokens, token)
+	return nil
+}
+
+// DeleteExpiredAdminSessions 删除过期的session和刷新令牌，返回删除的session数
+func (s *MemorySessionStore) DeleteExpiredAdminSessions(idleTimeout time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	for token, session := range s.sessions {
+		if now.After(session.ExpiresAt) || now.Sub(session.LastAccess) > idleTimeout {
+			delete(s.sessions, token)
+			count++
+		}
+	}
+	for token, refresh := range s.refreshTokens {
+		if now.After(refresh.ExpiresAt) {
+			delete(s.refreshTokens, token)
+		}
+	}
+	return count, nil
+}
+
+// ClearAdminSessions 删除全部session和刷新令牌
+func (s *MemorySessionStore) ClearAdminSessions() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = make(map[string]model.AdminSession)
+	s.refreshTokens = make(map[string]model.AdminRefreshToken)
+	return nil
+}
+
+// redisSessionTimeout Redis会话存储单次操作超时
+const redisSessionTimeout = 3 * time.Second
+
+/*
+RedisSessionStore Redis会话存储（多实例部署共享会话）
+键:
+  - {prefix}:session:{令牌}: session（JSON，TTL为最长有效期）
+  - {prefix}:refresh:{令牌}: 刷新令牌（JSON，TTL为有效期）
+  - {prefix}:sessions: session令牌集合（会话列表）
+*/
+type RedisSessionStore struct {
+	client *redis.Client
+	prefix string
+}
+
+/*
+NewRedisSessionStore 创建Redis会话存储
+参数:
+  - client: Redis客户端
+  - prefix: 键前缀
+
+返回:
+  - *RedisSessionStore: Redis会话存储
+*/
+func NewRedisSessionStore(client *redis.Client, prefix string) *RedisSessionStore {
+	return &RedisSessionStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// SaveAdminSession 保存session
+func (s *RedisSessionStore) SaveAdminSession(session *model.AdminSession) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return s.DeleteAdminSession(session.Token)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode admin session: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.sessionKey(session.Token), data, ttl)
+	pipe.SAdd(ctx, s.indexKey(), session.Token)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save admin session: %w", err)
+	}
+	return nil
+}
+
+// GetAdminSession 获取session
+func (s *RedisSessionStore) GetAdminSession(token string) (*model.AdminSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, s.sessionKey(token)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin session: %w", err)
+	}
+
+	var session model.AdminSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode admin session: %w", err)
+	}
+	return &session, nil
+}
+
+// ListAdminSessions 获取全部session（已过期的令牌同时从集合中移除）
+func (s *RedisSessionStore) ListAdminSessions() ([]*model.AdminSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	tokens, err := s.client.SMembers(ctx, s.indexKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin sessions: %w", err)
+	}
+
+	sessions := make([]*model.AdminSession, 0, len(tokens))
+	if len(tokens) == 0 {
+		return sessions, nil
+	}
+
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		keys[i] = s.sessionKey(token)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin sessions: %w", err)
+	}
+
+	var stale []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, tokens[i])
+			continue
+		}
+		var session model.AdminSession
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			stale = append(stale, tokens[i])
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+	if len(stale) > 0 {
+		s.client.SRem(ctx, s.indexKey(), stale...)
+	}
+	return sessions, nil
+}
+
+// DeleteAdminSession 删除session
+func (s *RedisSessionStore) DeleteAdminSession(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, s.sessionKey(token))
+	pipe.SRem(ctx, s.indexKey(), token)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete admin session: %w", err)
+	}
+	return nil
+}
+
+// SaveAdminRefreshToken 保存刷新令牌
+func (s *RedisSessionStore) SaveAdminRefreshToken(refresh *model.AdminRefreshToken) error {
+	ttl := time.Until(refresh.ExpiresAt)
+	if ttl <= 0 {
+		return s.DeleteAdminRefreshToken(refresh.Token)
+	}
+
+	data, err := json.Marshal(refresh)
+	if err != nil {
+		return fmt.Errorf("failed to encode admin refresh token: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	if err := s.client.Set(ctx, s.refreshKey(refresh.Token), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save admin refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetAdminRefreshToken 获取刷新令牌
+func (s *RedisSessionStore) GetAdminRefreshToken(token string) (*model.AdminRefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, s.refreshKey(token)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin refresh token: %w", err)
+	}
+
+	var refresh model.AdminRefreshToken
+	if err := json.Unmarshal(data, &refresh); err != nil {
+		return nil, fmt.Errorf("failed to decode admin refresh token: %w", err)
+	}
+	return &refresh, nil
+}
+
+// DeleteAdminRefreshToken 删除刷新令牌
+func (s *RedisSessionStore) DeleteAdminRefreshToken(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	if err := s.client.Del(ctx, s.refreshKey(token)).Err(); err != nil {
+		return fmt.Errorf("failed to delete admin refresh token: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredAdminSessions 删除无操作超时的session（超过最长有效期的session和刷新令牌由键TTL自动删除）
+func (s *RedisSessionStore) DeleteExpiredAdminSessions(idleTimeout time.Duration) (int64, error) {
+	sessions, err := s.ListAdminSessions()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, session := range sessions {
+		if time.Since(session.LastAccess) <= idleTimeout {
+			continue
+		}
+		if err := s.DeleteAdminSession(session.Token); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ClearAdminSessions 删除全部session和刷新令牌
+func (s *RedisSessionStore) ClearAdminSessions() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisSessionTimeout)
+	defer cancel()
+
+	var keys []string
+	iter := s.client.Scan(ctx, 0, s.prefix+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to clear admin sessions: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to clear admin sessions: %w", err)
+	}
+	return nil
+}
+
+// sessionKey session键
+func (s *RedisSessionStore) sessionKey(token string) string {
+	return s.prefix + ":session:" + token
+}
+
+// refreshKey 刷新令牌键
+func (s *RedisSessionStore) refreshKey(token string) string {
+	return s.prefix + ":refresh:" + token
+}
+
+// indexKey session令牌集合键
+func (s *RedisSessionStore) indexKey() string {
+	return s.prefix + ":sessions"
+}
@@ -0,0 +1,685 @@
+/*
+Package middleware 管理后台两步验证
+Author: AliMPay Team
+Description: 商户ID和密钥可完全控制资金，管理员账号可标记支付、退款；每个账号可独立开启两步验证，
+开启后该账号登录还需输入身份验证器App生成的动态验证码（TOTP），与角色无关
+
+功能:
+  - 开启流程: 生成密钥和二维码，扫码后输入一次验证码确认
+  - 登录校验: 凭据通过后要求输入动态验证码或恢复码
+  - 恢复码: 开启时签发，每个只能使用一次，丢失手机时用于登录
+  - 每个账号独立的密钥、恢复码和已使用的时间步，开启、关闭或重置互不影响
+  - 同一个动态验证码只能使用一次（防止重放）
+  - 密钥和恢复码摘要保存在数据库中，多实例部署时共享
+*/
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/qrcode"
+	"alimpay-go/internal/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 两步验证参数
+const (
+	twoFactorIssuer        = "AliMPay"
+	twoFactorSkew          = 1  // 允许前后1个时间步（30秒）的时钟误差
+	recoveryCodeCount      = 10 // 恢复码数量
+	recoveryCodeLength     = 10 // 恢复码长度（不含分隔符）
+	recoveryCodeAlphabet   = "abcdefghjkmnpqrstuvwxyz23456789"
+	twoFactorLoginLifetime = 5 * time.Minute // 凭据通过后输入动态验证码的时限
+)
+
+// 两步验证错误
+var (
+	ErrTwoFactorUnavailable = errors.New("two-factor authentication requires a persistent store")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled    = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNoPending   = errors.New("two-factor setup has not been started")
+	ErrTwoFactorInvalidCode = errors.New("invalid verification code")
+)
+
+/*
+TwoFactorStore 两步验证存储（*database.DB 实现）
+功能: 账号名为管理员用户名，商户ID和密钥登录为空；Use 开头的方法原子地记录使用，已使用时返回false
+*/
+type TwoFactorStore interface {
+	GetAdminTwoFactor(account string) (*model.AdminTwoFactor, error)
+	SaveAdminTwoFactor(tf *model.AdminTwoFactor) error
+	DeleteAdminTwoFactor(account string) (bool, error)
+	UseAdminTOTPStep(account string, step int64) (bool, error)
+	UseAdminRecoveryCode(account, previous, remaining string) (bool, error)
+	ConsumeAdminLoginChallenge(nonce string, expiresAt time.Time) (bool, error)
+}
+
+/*
+TwoFactor 管理后台两步验证
+字段:
+  - store: 两步验证存储
+  - merchantID: 商户ID（商户ID和密钥登录时身份验证器App中显示的账户名）
+  - mu: 互斥锁（保护开启、关闭流程的读-改-写）
+*/
+type TwoFactor struct {
+	store      TwoFactorStore
+	merchantID string
+	mu         sync.Mutex
+}
+
+/*
+TwoFactorSetup 待确认的两步验证密钥
+字段:
+  - Secret: Base32密钥（无法扫码时手动输入）
+  - URL: otpauth:// 地址
+  - QRCode: 二维码图片（data URI）
+*/
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+	QRCode string `json:"qrcode"`
+}
+
+/*
+NewTwoFactor 创建两步验证
+参数:
+  - store: 两步验证存储（为nil时无法开启两步验证）
+  - merchantID: 商户ID
+
+返回:
+  - *TwoFactor: 两步验证实例
+*/
+func NewTwoFactor(store TwoFactorStore, merchantID string) *TwoFactor {
+	return &TwoFactor{
+		store:      store,
+		merchantID: merchantID,
+	}
+}
+
+/*
+SetTwoFactorStore 设置两步验证存储
+参数:
+  - store: 两步验证存储（未设置时无法开启两步验证）
+*/
+func (m *AdminAuthMiddleware) SetTwoFactorStore(store TwoFactorStore) {
+	m.twoFactor = NewTwoFactor(store, m.merchantID)
+}
+
+/*
+Enabled 账号是否已开启两步验证
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+
+返回:
+  - bool: 是否已开启
+  - error: 读取失败时返回错误（调用方应拒绝登录）
+*/
+func (t *TwoFactor) Enabled(account string) (bool, error) {
+	tf, err := t.load(account)
+	if err != nil {
+		return false, err
+	}
+	return tf != nil && tf.Secret != "", nil
+}
+
+/*
+Setup 生成账号待确认的密钥
+功能: 重复调用会替换之前未确认的密钥
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+
+返回:
+  - *TwoFactorSetup: 密钥和二维码
+  - error: 已开启或保存失败时返回错误
+*/
+func (t *TwoFactor) Setup(account string) (*TwoFactorSetup, error) {
+	if t.store == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tf, err := t.load(account)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		tf = &model.AdminTwoFactor{Account: account}
+	} else if tf.Secret != "" {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	tf.PendingSecret = secret
+	if err := t.store.SaveAdminTwoFactor(tf); err != nil {
+		return nil, err
+	}
+
+	setup := &TwoFactorSetup{
+		Secret: secret,
+		URL:    totp.URL(twoFactorIssuer, t.label(account), secret),
+	}
+	if image, err := qrcode.NewGenerator(256, 10).GenerateToBase64(setup.URL); err != nil {
+		// 二维码生成失败时仍可手动输入密钥
+		logger.Warn("Failed to generate two-factor QR code", zap.Error(err))
+	} else {
+		setup.QRCode = "data:image/png;base64," + image
+	}
+	return setup, nil
+}
+
+/*
+Enable 使用动态验证码确认待确认的密钥并开启两步验证
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+  - code: 动态验证码
+
+返回:
+  - []string: 恢复码（仅此时返回明文）
+  - error: 验证码错误或保存失败时返回错误
+*/
+func (t *TwoFactor) Enable(account, code string) ([]string, error) {
+	if t.store == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tf, err := t.load(account)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Secret != "" {
+		return nil, ErrTwoFactorEnabled
+	}
+	if tf == nil || tf.PendingSecret == "" {
+		return nil, ErrTwoFactorNoPending
+	}
+
+	step, ok := totp.Validate(tf.PendingSecret, normalizeCode(code), time.Now(), twoFactorSkew)
+	if !ok {
+		return nil, ErrTwoFactorInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tf.Secret = tf.PendingSecret
+	tf.PendingSecret = ""
+	tf.RecoveryCodes = hashes
+	tf.LastStep = step
+	if err := t.store.SaveAdminTwoFactor(tf); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+/*
+Disable 关闭两步验证
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+  - code: 动态验证码或恢复码
+
+返回:
+  - error: 未开启、验证码错误或保存失败时返回错误
+*/
+func (t *TwoFactor) Disable(account, code string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.verify(account, code); err != nil {
+		return err
+	}
+	_, err := t.store.DeleteAdminTwoFactor(account)
+	return err
+}
+
+/*
+Reset 重置账号的两步验证（管理员为丢失手机和恢复码的账号关闭两步验证，不需要验证码）
+参数:
+  - account: 管理员用户名
+
+返回:
+  - error: 未开启或删除失败时返回错误
+*/
+func (t *TwoFactor) Reset(account string) error {
+	if t.store == nil {
+		return ErrTwoFactorDisabled
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deleted, err := t.store.DeleteAdminTwoFactor(account)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTwoFactorDisabled
+	}
+	return nil
+}
+
+/*
+RegenerateRecoveryCodes 重新签发恢复码（旧恢复码全部失效）
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+  - code: 动态验证码
+
+返回:
+  - []string: 新的恢复码
+  - error: 未开启、验证码错误或保存失败时返回错误
+*/
+func (t *TwoFactor) RegenerateRecoveryCodes(account, code string) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tf, err := t.enabled(account)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := t.verifyTOTP(tf, code); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrTwoFactorInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := t.store.UseAdminRecoveryCode(account, tf.RecoveryCodes, hashes); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrTwoFactorDisabled
+	}
+	return codes, nil
+}
+
+/*
+Verify 校验登录时输入的动态验证码或恢复码（恢复码使用后失效）
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+  - code: 动态验证码或恢复码
+
+返回:
+  - error: 验证码错误时返回 ErrTwoFactorInvalidCode
+*/
+func (t *TwoFactor) Verify(account, code string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.verify(account, code)
+}
+
+/*
+RecoveryCodesRemaining 账号剩余可用的恢复码数量
+参数:
+  - account: 管理员用户名（商户ID和密钥登录时为空）
+
+返回:
+  - int: 剩余数量
+*/
+func (t *TwoFactor) RecoveryCodesRemaining(account string) int {
+	tf, err := t.load(account)
+	if err != nil || tf == nil || tf.RecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(tf.RecoveryCodes, ","))
+}
+
+// load 读取账号的两步验证（未设置存储或不存在时返回nil）
+func (t *TwoFactor) load(account string) (*model.AdminTwoFactor, error) {
+	if t.store == nil {
+		return nil, nil
+	}
+	return t.store.GetAdminTwoFactor(account)
+}
+
+// enabled 读取已开启的两步验证
+func (t *TwoFactor) enabled(account string) (*model.AdminTwoFactor, error) {
+	tf, err := t.load(account)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || tf.Secret == "" {
+		return nil, ErrTwoFactorDisabled
+	}
+	return tf, nil
+}
+
+// label 身份验证器App中显示的账户名
+func (t *TwoFactor) label(account string) string {
+	if account == "" {
+		return t.merchantID
+	}
+	return account
+}
+
+// verify 校验动态验证码，6位数字以外的输入按恢复码校验（调用方持有锁）
+func (t *TwoFactor) verify(account, code string) error {
+	tf, err := t.enabled(account)
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	if code = normalizeCode(code); len(code) == totp.Digits {
+		ok, err = t.verifyTOTP(tf, code)
+	} else {
+		ok, err = t.useRecoveryCode(tf, code)
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTwoFactorInvalidCode
+	}
+	return nil
+}
+
+// verifyTOTP 校验动态验证码并记录时间步，已使用过的时间步不再接受（多实例部署时同样只接受一次）
+func (t *TwoFactor) verifyTOTP(tf *model.AdminTwoFactor, code string) (bool, error) {
+	step, ok := totp.Validate(tf.Secret, normalizeCode(code), time.Now(), twoFactorSkew)
+	if !ok {
+		return false, nil
+	}
+
+	used, err := t.store.UseAdminTOTPStep(tf.Account, step)
+	if err != nil {
+		return false, err
+	}
+	if !used {
+		logger.Warn("Two-factor code reused",
+			zap.String("account", t.label(tf.Account)),
+			zap.Int64("step", step))
+		return false, nil
+	}
+	return true, nil
+}
+
+// useRecoveryCode 使用恢复码（匹配后删除，同一恢复码并发使用时只有一个成功）
+func (t *TwoFactor) useRecoveryCode(tf *model.AdminTwoFactor, code string) (bool, error) {
+	if tf.RecoveryCodes == "" || code == "" {
+		return false, nil
+	}
+
+	digest := hashRecoveryCode(code)
+	hashes := strings.Split(tf.RecoveryCodes, ",")
+	for i, hash := range hashes {
+		if hash != digest {
+			continue
+		}
+
+		remaining := append(hashes[:i:i], hashes[i+1:]...)
+		used, err := t.store.UseAdminRecoveryCode(tf.Account, tf.RecoveryCodes, strings.Join(remaining, ","))
+		if err != nil || !used {
+			return false, err
+		}
+		logger.Warn("Admin recovery code used",
+			zap.String("account", t.label(tf.Account)),
+			zap.Int("remaining", len(remaining)))
+		return true, nil
+	}
+	return false, nil
+}
+
+// newRecoveryCodes 生成新的恢复码，返回明文和摘要（逗号分隔，只保存摘要）
+func newRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, "", err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(normalizeCode(code))
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+// newRecoveryCode 生成恢复码（格式 xxxxx-xxxxx，不含易混淆字符）
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	var b strings.Builder
+	for i, v := range buf {
+		if i == recoveryCodeLength/2 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)])
+	}
+	return b.String(), nil
+}
+
+// hashRecoveryCode 计算恢复码摘要
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// normalizeCode 去除空白和分隔符，统一小写
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '\t':
+			return -1
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, strings.TrimSpace(code))
+}
+
+/*
+HandleTwoFactorStatus 获取当前账号的两步验证状态
+GET /admin/2fa
+*/
+func (m *AdminAuthMiddleware) HandleTwoFactorStatus(c *gin.Context) {
+	account, ok := twoFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	enabled, err := m.twoFactor.Enabled(account)
+	if err != nil {
+		logger.Error("Failed to load two-factor status", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to load two-factor status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"available":      m.twoFactor.store != nil,
+		"enabled":        enabled,
+		"recovery_codes": m.twoFactor.RecoveryCodesRemaining(account),
+	})
+}
+
+/*
+HandleTwoFactorSetup 为当前账号生成待确认的两步验证密钥
+POST /admin/2fa/setup
+*/
+func (m *AdminAuthMiddleware) HandleTwoFactorSetup(c *gin.Context) {
+	account, ok := twoFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	setup, err := m.twoFactor.Setup(account)
+	if err != nil {
+		twoFactorError(c, "Failed to start two-factor setup", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"setup":   setup,
+	})
+}
+
+/*
+HandleTwoFactorEnable 确认并为当前账号开启两步验证
+POST /admin/2fa/enable
+参数:
+  - otp: 身份验证器App显示的动态验证码
+*/
+func (m *AdminAuthMiddleware) HandleTwoFactorEnable(c *gin.Context) {
+	account, ok := twoFactorAccount(c)
+	if !ok {
+		return
+	}
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := m.twoFactor.Enable(account, code)
+	if err != nil {
+		twoFactorError(c, "Failed to enable two-factor authentication", err)
+		return
+	}
+
+	logger.Warn("Admin two-factor authentication enabled",
+		zap.String("account", m.twoFactor.label(account)),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"recovery_codes": codes,
+	})
+}
+
+/*
+HandleTwoFactorDisable 关闭当前账号的两步验证
+POST /admin/2fa/disable
+参数:
+  - otp: 动态验证码或恢复码
+*/
+func (m *AdminAuthMiddleware) HandleTwoFactorDisable(c *gin.Context) {
+	account, ok := twoFactorAccount(c)
+	if !ok {
+		return
+	}
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	if err := m.twoFactor.Disable(account, code); err != nil {
+		twoFactorError(c, "Failed to disable two-factor authentication", err)
+		return
+	}
+
+	logger.Warn("Admin two-factor authentication disabled",
+		zap.String("account", m.twoFactor.label(account)),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+/*
+HandleTwoFactorRecoveryCodes 重新签发当前账号的恢复码
+POST /admin/2fa/recovery-codes
+参数:
+  - otp: 动态验证码
+*/
+func (m *AdminAuthMiddleware) HandleTwoFactorRecoveryCodes(c *gin.Context) {
+	account, ok := twoFactorAccount(c)
+	if !ok {
+		return
+	}
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := m.twoFactor.RegenerateRecoveryCodes(account, code)
+	if err != nil {
+		twoFactorError(c, "Failed to regenerate recovery codes", err)
+		return
+	}
+
+	logger.Info("Admin recovery codes regenerated",
+		zap.String("account", m.twoFactor.label(account)),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// twoFactorAccount 当前登录的账号（商户ID和密钥登录时为空）；API令牌不属于任何账号，不能管理两步验证
+func twoFactorAccount(c *gin.Context) (string, bool) {
+	if c.GetString("admin_token_id") != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Two-factor authentication cannot be managed with an API token",
+		})
+		return "", false
+	}
+	return c.GetString("admin_username"), true
+}
+
+// bindTwoFactorCode 读取请求中的验证码
+func bindTwoFactorCode(c *gin.Context) (string, bool) {
+	var req struct {
+		OTP string `json:"otp" form:"otp"`
+	}
+	if err := c.ShouldBind(&req); err != nil || strings.TrimSpace(req.OTP) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Missing verification code",
+		})
+		return "", false
+	}
+	return req.OTP, true
+}
+
+// twoFactorError 返回两步验证操作错误（状态错误和验证码错误返回400，其他返回500）
+func twoFactorError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrTwoFactorInvalidCode),
+		errors.Is(err, ErrTwoFactorEnabled),
+		errors.Is(err, ErrTwoFactorDisabled),
+		errors.Is(err, ErrTwoFactorNoPending),
+		errors.Is(err, ErrTwoFactorUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	default:
+		logger.Error(message, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   message,
+		})
+	}
+}
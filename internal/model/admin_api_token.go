@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// 管理后台API令牌权限范围
+const (
+	AdminTokenScopeRead   = "read"   // 只读：查询订单、统计、通知等（等同 viewer 角色）
+	AdminTokenScopeOrders = "orders" // 订单操作：另可标记支付、取消订单、审核退款、重放通知等（等同 operator 角色）
+)
+
+// AdminAPIToken 管理后台API令牌（监控脚本等自动化程序使用 Authorization: Bearer 调用管理接口）
+type AdminAPIToken struct {
+	ID         string     `db:"id" json:"id"`                     // 令牌标识
+	Name       string     `db:"name" json:"name"`                 // 名称（用途说明）
+	TokenHash  string     `db:"token_hash" json:"-"`              // 令牌的SHA-256摘要（令牌明文只在创建时返回一次）
+	Prefix     string     `db:"prefix" json:"prefix"`             // 令牌前缀（便于识别）
+	Scope      string     `db:"scope" json:"scope"`               // 权限范围
+	CreatedBy  string     `db:"created_by" json:"created_by"`     // 创建人（管理员用户名，商户ID和密钥登录时为空）
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`     // 创建时间
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at"`     // 过期时间（为空时长期有效）
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"` // 最后使用时间
+	LastUsedIP string     `db:"last_used_ip" json:"last_used_ip"` // 最后使用的客户端IP
+}
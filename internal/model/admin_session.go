@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// AdminSession 管理后台会话
+type AdminSession struct {
+	Token        string    `db:"token" json:"token"`                 // 会话令牌（Cookie值）
+	ID           string    `db:"id" json:"id"`                       // 会话标识（令牌摘要，可安全展示给前端）
+	MerchantID   string    `db:"merchant_id" json:"merchant_id"`     // 商户ID
+	Username     string    `db:"username" json:"username"`           // 管理员用户名（商户ID和密钥登录时为空）
+	Role         string    `db:"role" json:"role"`                   // 角色（为空时为管理员，兼容升级前的会话）
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`       // 创建时间
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`       // 过期时间
+	LastAccess   time.Time `db:"last_access" json:"last_access"`     // 最后访问时间
+	IP           string    `db:"ip" json:"ip"`                       // 客户端IP
+	UserAgent    string    `db:"user_agent" json:"user_agent"`       // 客户端标识
+	RefreshToken string    `db:"refresh_token" json:"refresh_token"` // 关联的刷新令牌（未勾选记住我时为空）
+}
+
+// AdminRefreshToken 管理后台刷新令牌（记住我）
+type AdminRefreshToken struct {
+	Token      string    `db:"token" json:"token"`             // 刷新令牌（Cookie值）
+	MerchantID string    `db:"merchant_id" json:"merchant_id"` // 商户ID
+	Username   string    `db:"username" json:"username"`       // 管理员用户名（续期时重新读取账号角色）
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`   // 过期时间
+	SessionID  string    `db:"session_id" json:"session_id"`   // 当前关联的会话标识
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+// 管理员角色（权限依次递增，高级角色拥有低级角色的全部权限）
+const (
+	AdminRoleViewer   = "viewer"   // 只读：查看订单、统计、通知、退款申请
+	AdminRoleOperator = "operator" // 操作员：标记支付、取消订单、审核退款、重放通知、生成线下收款码
+	AdminRoleAdmin    = "admin"    // 管理员：修改配置、管理收款码、会话、两步验证和管理员账号
+)
+
+// AdminUser 管理员账号（商户ID和密钥登录时不使用账号，拥有管理员角色）
+type AdminUser struct {
+	Username     string     `db:"username" json:"username"`           // 用户名
+	PasswordHash string     `db:"password_hash" json:"-"`             // 密码哈希（bcrypt）
+	Role         string     `db:"role" json:"role"`                   // 角色
+	Disabled     bool       `db:"disabled" json:"disabled"`           // 是否已停用
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`       // 创建时间
+	LastLoginAt  *time.Time `db:"last_login_at" json:"last_login_at"` // 最后登录时间
+	TwoFactor    bool       `db:"-" json:"two_factor"`                // 是否已开启两步验证
+}
+
+// AdminTwoFactor 管理后台账号的两步验证（每个账号独立的密钥和恢复码）
+type AdminTwoFactor struct {
+	Account       string    `db:"account"`        // 用户名（商户ID和密钥登录时为空）
+	Secret        string    `db:"secret"`         // TOTP密钥（为空表示未开启）
+	PendingSecret string    `db:"pending_secret"` // 待确认的密钥（扫码后输入验证码确认）
+	RecoveryCodes string    `db:"recovery_codes"` // 恢复码（SHA-256摘要，逗号分隔）
+	LastStep      int64     `db:"last_step"`      // 最近一次使用的动态验证码时间步（防止重放）
+	UpdatedAt     time.Time `db:"updated_at"`     // 更新时间
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount 金额（单位：分）
+// 订单金额以整数分存储、分配和比较，避免浮点误差导致相同金额匹配失败；
+// 对外接口（JSON、易支付参数、支付宝账单）仍使用保留两位小数的元，由 ParseAmount/String 转换
+type Amount int64
+
+// MaxOrderAmount 单笔订单最大金额（99999.99元）
+const MaxOrderAmount Amount = 9999999
+
+// ParseAmount 解析以元为单位的金额字符串（如 "12.34"、"12.3"、"12"），最多两位小数
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	negative := false
+	digits := s
+	switch digits[0] {
+	case '-':
+		negative = true
+		digits = digits[1:]
+	case '+':
+		digits = digits[1:]
+	}
+
+	intPart, fracPart := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, fracPart = digits[:i], digits[i+1:]
+	}
+	// 超出两位的小数只允许为0（如支付宝返回的 "12.300"）
+	if len(fracPart) > 2 {
+		if strings.Trim(fracPart[2:], "0") != "" {
+			return 0, fmt.Errorf("invalid amount %q: more than 2 decimal places", s)
+		}
+		fracPart = fracPart[:2]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	yuan, err := strconv.ParseUint(intPart, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	fen, err := strconv.ParseUint(fracPart, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if yuan > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+
+	a := Amount(yuan*100 + fen)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+// AmountFromYuan 将以元为单位的浮点数转换为金额（四舍五入到分）
+func AmountFromYuan(yuan float64) Amount {
+	return Amount(math.Round(yuan * 100))
+}
+
+// Yuan 转换为以元为单位的浮点数（仅用于统计展示和gRPC等只接受浮点数的接口）
+func (a Amount) Yuan() float64 {
+	return float64(a) / 100
+}
+
+// String 格式化为保留两位小数的元（如 "12.30"）
+func (a Amount) String() string {
+	sign := ""
+	n := int64(a)
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
+
+// MarshalJSON 输出为以元为单位的数字（如 12.30），与改用整数分之前的接口保持一致
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON 解析以元为单位的数字或字符串
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if string(data) == "null" || len(data) == 0 {
+		*a = 0
+		return nil
+	}
+
+	v, err := ParseAmount(string(data))
+	if err != nil {
+		// 兼容浮点误差产生的多位小数（如缓存中的 12.299999999999999）
+		f, ferr := strconv.ParseFloat(string(data), 64)
+		if ferr != nil {
+			return err
+		}
+		v = AmountFromYuan(f)
+	}
+	*a = v
+	return nil
+}
+
+// MarshalText 输出为以元为单位的字符串（用于YAML配置，如 amount_offset: "0.01"）
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText 解析以元为单位的字符串（YAML配置和环境变量）
+func (a *Amount) UnmarshalText(data []byte) error {
+	v, err := ParseAmount(string(data))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// AuditLog 审计日志（管理操作及敏感接口调用）
+type AuditLog struct {
+	ID        int64     `db:"id" json:"id"`
+	Action    string    `db:"action" json:"action"`
+	Actor     string    `db:"actor" json:"actor"` // session:<id> / merchant:<pid> / anonymous
+	IP        string    `db:"ip" json:"ip"`
+	UserAgent string    `db:"user_agent" json:"user_agent"`
+	Method    string    `db:"method" json:"method"`
+	Path      string    `db:"path" json:"path"`
+	Detail    string    `db:"detail" json:"detail"` // 请求参数（JSON，已脱敏）
+	Status    int       `db:"status" json:"status"` // HTTP响应状态码
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
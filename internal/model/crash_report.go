@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// CrashReport 崩溃报告（处理请求时发生的panic及其堆栈）
+type CrashReport struct {
+	ID        int64     `db:"id" json:"id"`
+	RequestID string    `db:"request_id" json:"request_id"`
+	Method    string    `db:"method" json:"method"`
+	Path      string    `db:"path" json:"path"`
+	Query     string    `db:"query" json:"query"` // 查询参数（已脱敏）
+	IP        string    `db:"ip" json:"ip"`
+	UserAgent string    `db:"user_agent" json:"user_agent"`
+	Error     string    `db:"error" json:"error"`
+	Stack     string    `db:"stack" json:"stack,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// JobRun 定时任务执行记录
+type JobRun struct {
+	ID         int64     `db:"id" json:"id"`
+	Name       string    `db:"name" json:"name"`       // 任务名称（如 reconciliation、archive）
+	Trigger    string    `db:"trigger" json:"trigger"` // 触发方式
+	Status     string    `db:"status" json:"status"`
+	Result     string    `db:"result" json:"result"` // 执行结果摘要
+	Error      string    `db:"error" json:"error,omitempty"`
+	StartedAt  time.Time `db:"started_at" json:"started_at"`
+	DurationMs int64     `db:"duration_ms" json:"duration_ms"`
+}
+
+// 任务执行状态
+const (
+	JobStatusRunning = "running" // 手动触发后正在执行
+	JobStatusSuccess = "success"
+	JobStatusFailed  = "failed"
+	JobStatusSkipped = "skipped" // 上次执行尚未完成，本次跳过
+)
+
+// 任务触发方式
+const (
+	JobTriggerSchedule = "schedule" // 定时执行
+	JobTriggerManual   = "manual"   // 管理后台手动触发
+)
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+)
+
+// NotifyLog 商户通知记录（每次发送一条）
+type NotifyLog struct {
+	ID         int64     `db:"id" json:"id"`
+	OrderID    string    `db:"order_id" json:"trade_no"`
+	OutTradeNo string    `db:"out_trade_no" json:"out_trade_no"`
+	PID        string    `db:"pid" json:"pid"`
+	NotifyURL  string    `db:"notify_url" json:"notify_url"`
+	Payload    string    `db:"payload" json:"payload"` // 通知参数（JSON，含签名）
+	Status     int       `db:"status" json:"status"`
+	HTTPStatus int       `db:"http_status" json:"http_status"`
+	Response   string    `db:"response" json:"response"`
+	Error      string    `db:"error" json:"error"`
+	DurationMs int64     `db:"duration_ms" json:"duration_ms"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// NotifyStatus 通知状态
+const (
+	NotifyStatusFailed  = 0 // 发送失败
+	NotifyStatusSuccess = 1 // 商户已确认
+)
+
+// NotifySummary 商户通知汇总
+type NotifySummary struct {
+	PID           string     `json:"pid"`
+	Success       int        `json:"success"`         // 成功次数
+	Failed        int        `json:"failed"`          // 失败次数
+	Pending       int        `json:"pending"`         // 已支付但尚未通知成功的订单数
+	LastFailureAt *time.Time `json:"last_failure_at"` // 最近一次失败时间
+	LastError     string     `json:"last_error"`      // 最近一次失败原因
+}
+
+// NotifyEndpointHealth 商户通知地址健康状态
+type NotifyEndpointHealth struct {
+	PID                 string     `json:"pid"`
+	URL                 string     `json:"url"` // 探测地址（去除查询参数）
+	Healthy             bool       `json:"healthy"`
+	StatusCode          int        `json:"status_code"`
+	Error               string     `json:"error,omitempty"`
+	LatencyMs           int64      `json:"latency_ms"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	CheckedAt           time.Time  `json:"checked_at"`
+	LastHealthyAt       *time.Time `json:"last_healthy_at,omitempty"`
+}
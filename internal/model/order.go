@@ -11,15 +11,18 @@ type Order struct {
 	Type          string     `db:"type" json:"type"`
 	PID           string     `db:"pid" json:"pid"`
 	Name          string     `db:"name" json:"name"`
-	Price         float64    `db:"price" json:"price"`
-	PaymentAmount float64    `db:"payment_amount" json:"payment_amount"`
+	Price         Amount     `db:"price" json:"price"`                   // 订单金额（分）
+	PaymentAmount Amount     `db:"payment_amount" json:"payment_amount"` // 实际支付金额（分，经营码模式可能有偏移）
 	Status        int        `db:"status" json:"status"`
 	AddTime       time.Time  `db:"add_time" json:"add_time"`
 	PayTime       *time.Time `db:"pay_time" json:"pay_time,omitempty"`
 	NotifyURL     string     `db:"notify_url" json:"notify_url"`
 	ReturnURL     string     `db:"return_url" json:"return_url"`
 	Sitename      string     `db:"sitename" json:"sitename"`
-	QRCodeID      string     `db:"qr_code_id" json:"qr_code_id"` // 分配的二维码ID
+	QRCodeID      string     `db:"qr_code_id" json:"qr_code_id"`           // 分配的二维码ID
+	AlipayTradeNo string     `db:"alipay_trade_no" json:"alipay_trade_no"` // 账单匹配到的支付宝交易号（用于对账）
+	KeepOpen      bool       `db:"keep_open" json:"keep_open"`             // 不自动过期（下单参数 auto_close=0），未支付时保留到商户关闭
+	Disputed      bool       `db:"disputed" json:"disputed"`               // 争议订单（拒付/投诉），冻结商户通知且不计入收入
 }
 
 // OrderStatus 订单状态
@@ -28,8 +31,28 @@ const (
 	OrderStatusPaid    = 1 // 已支付
 	OrderStatusClosed  = 2 // 已关闭
 	OrderStatusRefund  = 3 // 已退款
+	OrderStatusExpired = 4 // 已过期（超时未支付）
 )
 
+// QRCodeIDPrecreate 当面付订单的二维码ID（订单使用支付宝生成的二维码，按交易号确认支付而不是匹配账单）
+const QRCodeIDPrecreate = "alipay_precreate"
+
+// QRCodeIDWap 手机网站支付订单的二维码ID（用户跳转支付宝收银台付款，同样按交易号确认支付）
+const QRCodeIDWap = "alipay_wap"
+
+// QRCodeIDSandbox 沙箱订单的二维码ID（不分配真实收款码，只与模拟账单匹配）
+const QRCodeIDSandbox = "sandbox"
+
+// IsAlipayTrade 订单是否通过支付宝交易接口收款（当面付、手机网站支付），按交易号而不是账单确认支付
+func (o *Order) IsAlipayTrade() bool {
+	return o.QRCodeID == QRCodeIDPrecreate || o.QRCodeID == QRCodeIDWap
+}
+
+// IsSandbox 订单是否由沙箱商户创建
+func (o *Order) IsSandbox() bool {
+	return o.QRCodeID == QRCodeIDSandbox
+}
+
 // PaymentType 支付类型
 const (
 	PaymentTypeAlipay = "alipay"
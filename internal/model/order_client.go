@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// OrderClient 订单的买家IP及归属地（下单时记录，归属地需启用 geoip）
+type OrderClient struct {
+	OrderID   string    `db:"order_id" json:"trade_no"`
+	IP        string    `db:"ip" json:"ip"`                 // 买家IP（浏览器提交时为请求来源IP，服务端下单时为参数 clientip）
+	Country   string    `db:"country" json:"country"`       // 国家/地区代码，未启用IP库或查询不到时为空
+	Province  string    `db:"province" json:"province"`     // 省份/州
+	CreatedAt time.Time `db:"created_at" json:"created_at"` // 记录时间
+}
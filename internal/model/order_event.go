@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+// OrderEvent 订单生命周期事件
+type OrderEvent struct {
+	ID        int64     `db:"id" json:"id"`
+	OrderID   string    `db:"order_id" json:"trade_no"`
+	Event     string    `db:"event" json:"event"`
+	Detail    string    `db:"detail" json:"detail"` // 补充说明（如二维码ID、支付宝交易号）
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// OrderEvent 事件类型
+const (
+	OrderEventCreated         = "created"          // 订单创建
+	OrderEventQRAssigned      = "qr_assigned"      // 分配收款码
+	OrderEventPageViewed      = "page_viewed"      // 用户打开支付页面
+	OrderEventSandboxPaid     = "sandbox_paid"     // 沙箱订单模拟支付（等待匹配模拟账单）
+	OrderEventBillMatched     = "bill_matched"     // 账单匹配成功
+	OrderEventPartialPaid     = "partial_paid"     // 收到分笔支付（未达到支付金额）
+	OrderEventMarkedPaid      = "marked_paid"      // 手动/回调确认支付
+	OrderEventTradePaid       = "trade_paid"       // 当面付、手机网站支付交易成功（支付宝异步通知或交易查询）
+	OrderEventPaid            = "paid"             // 支付完成（无事件记录的旧订单，由支付时间推断）
+	OrderEventClosed          = "closed"           // 订单关闭
+	OrderEventExpired         = "expired"          // 订单超时过期
+	OrderEventRefunded        = "refunded"         // 订单退款
+	OrderEventRefundRequested = "refund_requested" // 商户提交退款申请
+	OrderEventRefundApproved  = "refund_approved"  // 退款申请审核通过
+	OrderEventRefundRejected  = "refund_rejected"  // 退款申请被拒绝
+	OrderEventDisputed        = "disputed"         // 订单标记为争议（拒付/投诉）
+	OrderEventDisputeResolved = "dispute_resolved" // 订单争议解除
+	OrderEventReturned        = "returned"         // 用户跳转回商户页面
+	OrderEventNotified        = "notified"         // 商户通知（来自通知记录）
+	OrderEventAdminAction     = "admin_action"     // 管理操作（来自审计日志）
+)
+
+// OrderTimelineEntry 订单时间线条目
+type OrderTimelineEntry struct {
+	Time    time.Time `json:"time"`
+	Stage   string    `json:"stage"`
+	Source  string    `json:"source"` // event / notify / audit / order
+	Detail  string    `json:"detail,omitempty"`
+	Success *bool     `json:"success,omitempty"` // 仅通知和管理操作有结果
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+)
+
+// OutboxEvent 事件发件箱记录（已发布的订单事件，按序号供外部消费者补拉）
+type OutboxEvent struct {
+	Seq       int64     `db:"seq" json:"seq"`               // 序号（单调递增）
+	Type      string    `db:"event_type" json:"type"`       // 事件类型（order:paid 等）
+	OrderID   string    `db:"order_id" json:"order_id"`     // 系统订单号
+	PID       string    `db:"pid" json:"pid"`               // 商户ID
+	Payload   string    `db:"payload" json:"-"`             // 事件数据（发布时的订单JSON）
+	CreatedAt time.Time `db:"created_at" json:"created_at"` // 发布时间
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+)
+
+// PartialPayment 分笔支付记录（开启 payment.partial_payment 时，同一订单的多笔小额转账累计到支付金额后订单才完成支付）
+type PartialPayment struct {
+	ID            int64     `db:"id" json:"id"`
+	OrderID       string    `db:"order_id" json:"trade_no"`               // 系统订单号
+	AlipayTradeNo string    `db:"alipay_trade_no" json:"alipay_trade_no"` // 支付宝交易号
+	AccountLogID  string    `db:"account_log_id" json:"-"`                // 账务流水号（与交易号共同唯一标识一笔账单）
+	Amount        Amount    `db:"amount" json:"amount"`                   // 本笔金额
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`           // 匹配时间
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// PrintedCode 线下打印的固定金额收款码
+// 二维码内容为短链接 /s/{code}，首次扫码时创建订单；关联订单关闭或过期后再次扫码会创建新订单
+type PrintedCode struct {
+	Code      string     `db:"code" json:"code"`             // 短码
+	BatchID   string     `db:"batch_id" json:"batch_id"`     // 生成批次
+	Name      string     `db:"name" json:"name"`             // 商品名称
+	Price     Amount     `db:"price" json:"price"`           // 固定金额（分）
+	TradeNo   string     `db:"trade_no" json:"trade_no"`     // 当前关联的订单号（未扫码时为空）
+	CreatedAt time.Time  `db:"created_at" json:"created_at"` // 生成时间
+	ScannedAt *time.Time `db:"scanned_at" json:"scanned_at"` // 最近一次创建订单的时间
+}
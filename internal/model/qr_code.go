@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+// QRCodeSetting 管理后台修改的收款码设置（覆盖配置文件中同ID的二维码）
+type QRCodeSetting struct {
+	ID        string    `db:"id" json:"id"`
+	Path      string    `db:"path" json:"path"`
+	CodeID    string    `db:"code_id" json:"code_id"`
+	Enabled   bool      `db:"enabled" json:"enabled"`
+	Priority  int       `db:"priority" json:"priority"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// 收款码不健康的原因
+const (
+	QRCodeUnhealthyAPIFailures = "api_failures" // 专属支付宝接口连续查询失败
+	QRCodeUnhealthyNoPayment   = "no_payment"   // 有订单分配但长时间没有匹配到支付
+)
+
+// QRCodeHealth 收款码健康状态（不健康的收款码不再分配给新订单）
+type QRCodeHealth struct {
+	ID                  string     `json:"id"`
+	Healthy             bool       `json:"healthy"`
+	Reason              string     `json:"reason,omitempty"`          // 不健康的原因
+	Detail              string     `json:"detail,omitempty"`          // 原因说明（接口错误、订单数）
+	UnhealthySince      *time.Time `json:"unhealthy_since,omitempty"` // 标记为不健康的时间
+	ConsecutiveFailures int        `json:"consecutive_failures"`      // 专属接口连续失败次数
+	LastError           string     `json:"last_error,omitempty"`      // 专属接口最近一次错误
+	CheckedAt           *time.Time `json:"checked_at,omitempty"`      // 最近一次检查支付情况的时间
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// RefundRequest 商户发起的退款申请
+// 目前无法自动原路退款，申请由管理员审核；同意后管理员在支付宝中手动退款
+type RefundRequest struct {
+	RefundNo    string     `db:"refund_no" json:"refund_no"`         // 退款申请号
+	OutRefundNo string     `db:"out_refund_no" json:"out_refund_no"` // 商户退款单号（可选，同一商户内唯一，重复提交返回已有申请）
+	OrderID     string     `db:"order_id" json:"trade_no"`           // 订单号
+	OutTradeNo  string     `db:"out_trade_no" json:"out_trade_no"`   // 商户订单号
+	PID         string     `db:"pid" json:"pid"`                     // 商户ID
+	Amount      Amount     `db:"amount" json:"money"`                // 退款金额（分）
+	Reason      string     `db:"reason" json:"reason"`               // 退款原因
+	Status      string     `db:"status" json:"status"`               // 审核状态
+	AdminNote   string     `db:"admin_note" json:"admin_note"`       // 审核备注（拒绝原因等，商户可见）
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`       // 申请时间
+	ProcessedAt *time.Time `db:"processed_at" json:"processed_at"`   // 审核时间
+}
+
+// RefundRequest 审核状态
+const (
+	RefundStatusPending  = "pending"  // 待审核
+	RefundStatusApproved = "approved" // 已同意（需在支付宝中手动退款）
+	RefundStatusRejected = "rejected" // 已拒绝
+)
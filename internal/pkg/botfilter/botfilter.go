@@ -0,0 +1,169 @@
+// Package botfilter 页面下单的轻量机器人过滤
+// 浏览器在提交下单表单前需完成工作量证明（由页面脚本自动计算，买家无感知），
+// 并且不能填写对真人隐藏的蜜罐字段；不执行脚本或自动填写全部字段的机器人无法创建订单
+package botfilter
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 随下单表单提交的字段（验证后从下单参数中去除，不参与签名）
+const (
+	FieldToken    = "bf_token"   // 工作量证明题目
+	FieldNonce    = "bf_nonce"   // 工作量证明答案
+	FieldHoneypot = "bf_website" // 蜜罐字段（对真人隐藏，必须为空）
+)
+
+// MaxDifficulty 允许的最大难度（前导零比特数），过高时买家浏览器计算耗时过长
+const MaxDifficulty = 24
+
+// tokenLifetime 题目有效期
+const tokenLifetime = 5 * time.Minute
+
+// 验证失败原因
+var (
+	ErrHoneypot      = errors.New("honeypot field is filled")
+	ErrMissingToken  = errors.New("missing proof-of-work token")
+	ErrInvalidToken  = errors.New("invalid proof-of-work token")
+	ErrExpiredToken  = errors.New("proof-of-work token expired")
+	ErrReusedToken   = errors.New("proof-of-work token already used")
+	ErrWrongSolution = errors.New("proof-of-work solution is wrong")
+)
+
+// Challenge 渲染到页面的工作量证明题目
+type Challenge struct {
+	Token      string // 题目（随表单提交）
+	Difficulty int    // 要求 SHA-256(Token + ":" + Nonce) 的前导零比特数
+}
+
+// Filter 机器人过滤器
+// 题目由服务端密钥签名并与客户端IP绑定，不需要保存；已使用的题目在有效期内记录在内存中，防止重复使用
+type Filter struct {
+	secret     []byte
+	difficulty int
+	used       map[string]time.Time
+	mu         sync.Mutex
+}
+
+// New 创建机器人过滤器
+// @param secret 题目签名密钥（多实例部署时需相同，为空时随机生成，仅当前进程有效）
+// @param difficulty 工作量证明难度（前导零比特数，1 到 MaxDifficulty）
+func New(secret string, difficulty int) *Filter {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			key = []byte(strconv.FormatInt(time.Now().UnixNano(), 16))
+		}
+	}
+	filter := &Filter{
+		secret:     key,
+		difficulty: difficulty,
+		used:       make(map[string]time.Time),
+	}
+	go filter.cleanup()
+	return filter
+}
+
+// Challenge 为客户端IP生成题目
+func (f *Filter) Challenge(ip string) *Challenge {
+	salt := make([]byte, 8)
+	_, _ = rand.Read(salt)
+	payload := strconv.FormatInt(time.Now().Add(tokenLifetime).Unix(), 10) + "." + hex.EncodeToString(salt)
+	return &Challenge{
+		Token:      payload + "." + f.sign(ip, payload),
+		Difficulty: f.difficulty,
+	}
+}
+
+// Verify 校验表单提交的字段
+// @param ip 客户端IP（须与生成题目时相同）
+// @param form 表单字段（FieldToken、FieldNonce、FieldHoneypot）
+// @return error 未通过的原因
+func (f *Filter) Verify(ip string, form map[string]string) error {
+	if form[FieldHoneypot] != "" {
+		return ErrHoneypot
+	}
+	token := form[FieldToken]
+	if token == "" {
+		return ErrMissingToken
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(f.sign(ip, payload))) {
+		return ErrInvalidToken
+	}
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	expiresAt := time.Unix(expiry, 0)
+	if time.Now().After(expiresAt) {
+		return ErrExpiredToken
+	}
+	if !Solves(token, form[FieldNonce], f.difficulty) {
+		return ErrWrongSolution
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.used[token]; exists {
+		return ErrReusedToken
+	}
+	f.used[token] = expiresAt
+	return nil
+}
+
+// Solves 答案是否满足难度要求：SHA-256(token + ":" + nonce) 至少有 difficulty 个前导零比特
+func Solves(token, nonce string, difficulty int) bool {
+	if nonce == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token + ":" + nonce))
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			zeros += bits.LeadingZeros8(b)
+			break
+		}
+		zeros += 8
+	}
+	return zeros >= difficulty
+}
+
+// sign 题目签名（绑定客户端IP）
+func (f *Filter) sign(ip, payload string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(ip + "|" + payload))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// cleanup 每分钟清理已过期的已使用题目
+func (f *Filter) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		f.mu.Lock()
+		for token, expiresAt := range f.used {
+			if now.After(expiresAt) {
+				delete(f.used, token)
+			}
+		}
+		f.mu.Unlock()
+	}
+}
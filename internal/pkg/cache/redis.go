@@ -84,3 +84,11 @@ func (r *RedisCache) Close() error {
 func (r *RedisCache) IsAvailable() bool {
 	return r != nil && r.client != nil
 }
+
+// Client 获取底层Redis客户端（供分布式锁、发布订阅等功能使用）
+func (r *RedisCache) Client() *redis.Client {
+	if r == nil {
+		return nil
+	}
+	return r.client
+}
@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+)
+
+// Challenge 渲染到验证页面的验证组件
+// 第三方验证服务填写 ScriptURL、Widget、SiteKey，由页面加载其脚本；内置滑块填写 ID、Image、Piece
+type Challenge struct {
+	Provider string // 验证方式
+
+	ScriptURL string // 第三方组件脚本地址
+	Widget    string // 第三方组件容器的 class（如 h-captcha、cf-turnstile）
+	SiteKey   string // 第三方站点密钥
+
+	ID     string       // 滑块题目ID（随表单提交）
+	Image  template.URL // 滑块背景图片（含缺口，data URI）
+	Piece  template.URL // 滑块拼图块图片（data URI）
+	Top    int          // 拼图块纵坐标（像素）
+	Width  int          // 背景图片宽度（像素）
+	Height int          // 背景图片高度（像素）
+	Range  int          // 拼图块可滑动的最大距离（像素）
+}
+
+// Provider 人机验证方式
+type Provider interface {
+	// Name 验证方式名称
+	Name() string
+	// Challenge 为客户端IP生成验证组件
+	Challenge(ip string) (*Challenge, error)
+	// Fields 验证组件随表单提交的字段名（验证后从下单参数中去除，不参与签名）
+	Fields() []string
+	// Verify 校验表单提交的验证结果（每个验证结果只能使用一次）
+	Verify(ip string, form map[string]string) (bool, error)
+}
+
+// Options 创建验证方式的参数
+type Options struct {
+	SiteKey   string       // 第三方站点密钥
+	SecretKey string       // 第三方服务端密钥
+	Client    *http.Client // 调用第三方验证接口的HTTP客户端
+}
+
+// Factory 按参数创建验证方式
+type Factory func(options Options) (Provider, error)
+
+var (
+	factories = map[string]Factory{
+		"slider":    func(Options) (Provider, error) { return NewSlider(), nil },
+		"hcaptcha":  NewHCaptcha,
+		"turnstile": NewTurnstile,
+	}
+	mu sync.RWMutex
+)
+
+// Register 注册验证方式（同名时替换内置实现）
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// New 按名称创建验证方式
+func New(name string, options Options) (Provider, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown captcha provider %q", name)
+	}
+	return factory(options)
+}
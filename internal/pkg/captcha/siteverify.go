@@ -0,0 +1,139 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// siteVerify 通过第三方 siteverify 接口校验验证结果（hCaptcha 与 Turnstile 接口格式相同）
+type siteVerify struct {
+	name      string
+	scriptURL string
+	widget    string
+	verifyURL string
+	fields    []string // 第一个字段为验证结果，其余为组件附带提交的兼容字段
+	siteKey   string
+	secretKey string
+	client    *http.Client
+}
+
+// siteVerifyResponse siteverify 接口响应
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// NewHCaptcha 创建 hCaptcha 验证
+func NewHCaptcha(options Options) (Provider, error) {
+	return newSiteVerify(siteVerify{
+		name:      "hcaptcha",
+		scriptURL: "https://js.hcaptcha.com/1/api.js",
+		widget:    "h-captcha",
+		verifyURL: "https://api.hcaptcha.com/siteverify",
+		fields:    []string{"h-captcha-response", "g-recaptcha-response"},
+	}, options)
+}
+
+// NewTurnstile 创建 Cloudflare Turnstile 验证
+func NewTurnstile(options Options) (Provider, error) {
+	return newSiteVerify(siteVerify{
+		name:      "turnstile",
+		scriptURL: "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		widget:    "cf-turnstile",
+		verifyURL: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		fields:    []string{"cf-turnstile-response"},
+	}, options)
+}
+
+// newSiteVerify 填写密钥和HTTP客户端
+func newSiteVerify(provider siteVerify, options Options) (Provider, error) {
+	if options.SiteKey == "" || options.SecretKey == "" {
+		return nil, fmt.Errorf("%s requires site key and secret key", provider.name)
+	}
+	provider.siteKey = options.SiteKey
+	provider.secretKey = options.SecretKey
+	provider.client = options.Client
+	if provider.client == nil {
+		provider.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &provider, nil
+}
+
+// Name 验证方式名称
+func (p *siteVerify) Name() string {
+	return p.name
+}
+
+// Challenge 第三方组件由页面脚本渲染，只需要站点密钥
+func (p *siteVerify) Challenge(ip string) (*Challenge, error) {
+	return &Challenge{
+		Provider:  p.name,
+		ScriptURL: p.scriptURL,
+		Widget:    p.widget,
+		SiteKey:   p.siteKey,
+	}, nil
+}
+
+// Fields 验证组件随表单提交的字段名
+func (p *siteVerify) Fields() []string {
+	return p.fields
+}
+
+// Verify 调用 siteverify 接口校验验证结果
+func (p *siteVerify) Verify(ip string, form map[string]string) (bool, error) {
+	token := strings.TrimSpace(form[p.fields[0]])
+	if token == "" {
+		return false, nil
+	}
+
+	values := url.Values{
+		"secret":   {p.secretKey},
+		"response": {token},
+		"sitekey":  {p.siteKey},
+	}
+	if ip != "" {
+		values.Set("remoteip", ip)
+	}
+
+	resp, err := p.client.PostForm(p.verifyURL, values)
+	if err != nil {
+		return false, fmt.Errorf("%s siteverify request failed: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return false, fmt.Errorf("%s siteverify response read failed: %w", p.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s siteverify returned HTTP %d", p.name, resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false, fmt.Errorf("%s siteverify response invalid: %w", p.name, err)
+	}
+	if !result.Success && len(result.ErrorCodes) > 0 && !onlyTokenErrors(result.ErrorCodes) {
+		return false, errors.New(p.name + " siteverify error: " + strings.Join(result.ErrorCodes, ","))
+	}
+	return result.Success, nil
+}
+
+// onlyTokenErrors 错误码是否都是验证结果本身无效（买家未通过验证），而不是密钥等配置错误
+func onlyTokenErrors(codes []string) bool {
+	for _, code := range codes {
+		switch code {
+		case "invalid-input-response", "timeout-or-duplicate", "missing-input-response",
+			"invalid-or-already-seen-response", "already-seen-response", "expired-input-response":
+		default:
+			return false
+		}
+	}
+	return true
+}
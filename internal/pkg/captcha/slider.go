@@ -0,0 +1,180 @@
+package captcha
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内置滑块参数
+const (
+	sliderWidth     = 300             // 背景图片宽度
+	sliderHeight    = 150             // 背景图片高度
+	sliderPiece     = 44              // 拼图块边长
+	sliderTolerance = 5               // 允许的对齐误差（像素）
+	sliderLifetime  = 5 * time.Minute // 题目有效期
+)
+
+// 内置滑块随表单提交的字段
+const (
+	SliderFieldID     = "captcha_id"
+	SliderFieldOffset = "captcha_offset"
+)
+
+// Slider 内置滑块验证：将拼图块拖动到背景图片的缺口处
+// 题目保存在内存中，只能使用一次并与生成时的客户端IP绑定；不依赖第三方服务，强度低于 hCaptcha/Turnstile
+type Slider struct {
+	challenges map[string]*sliderChallenge
+	mu         sync.Mutex
+}
+
+// sliderChallenge 滑块题目
+type sliderChallenge struct {
+	ip        string
+	offset    int
+	expiresAt time.Time
+}
+
+// NewSlider 创建内置滑块验证
+func NewSlider() *Slider {
+	slider := &Slider{challenges: make(map[string]*sliderChallenge)}
+	go slider.cleanup()
+	return slider
+}
+
+// Name 验证方式名称
+func (s *Slider) Name() string {
+	return "slider"
+}
+
+// Challenge 生成滑块题目（缺口位置随机）
+func (s *Slider) Challenge(ip string) (*Challenge, error) {
+	offset := randomInt(sliderPiece+20, sliderWidth-sliderPiece-10)
+	top := randomInt(10, sliderHeight-sliderPiece-10)
+	hue := randomInt(0, 359)
+
+	id := randomHex(16)
+	s.mu.Lock()
+	s.challenges[id] = &sliderChallenge{
+		ip:        ip,
+		offset:    offset,
+		expiresAt: time.Now().Add(sliderLifetime),
+	}
+	s.mu.Unlock()
+
+	return &Challenge{
+		Provider: s.Name(),
+		ID:       id,
+		Image:    svgDataURI(sliderBackground(hue, offset, top)),
+		Piece:    svgDataURI(sliderPieceSVG(hue)),
+		Top:      top,
+		Width:    sliderWidth,
+		Height:   sliderHeight,
+		Range:    sliderWidth - sliderPiece,
+	}, nil
+}
+
+// Fields 滑块随表单提交的字段名
+func (s *Slider) Fields() []string {
+	return []string{SliderFieldID, SliderFieldOffset}
+}
+
+// Verify 校验拼图块位置（误差在 sliderTolerance 以内）
+func (s *Slider) Verify(ip string, form map[string]string) (bool, error) {
+	id := form[SliderFieldID]
+	if id == "" {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	challenge, exists := s.challenges[id]
+	delete(s.challenges, id)
+	s.mu.Unlock()
+
+	if !exists || challenge.ip != ip || time.Now().After(challenge.expiresAt) {
+		return false, nil
+	}
+	offset, err := strconv.ParseFloat(strings.TrimSpace(form[SliderFieldOffset]), 64)
+	if err != nil {
+		return false, nil
+	}
+	diff := int(offset+0.5) - challenge.offset
+	return diff >= -sliderTolerance && diff <= sliderTolerance, nil
+}
+
+// cleanup 每分钟清理过期题目
+func (s *Slider) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		s.mu.Lock()
+		for id, challenge := range s.challenges {
+			if now.After(challenge.expiresAt) {
+				delete(s.challenges, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// sliderBackground 生成背景图片：随机色调的渐变和干扰图形，缺口位于 (offset, top)
+func sliderBackground(hue, offset, top int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		sliderWidth, sliderHeight, sliderWidth, sliderHeight)
+	fmt.Fprintf(&b, `<defs><linearGradient id="g" x1="0" y1="0" x2="1" y2="1">`+
+		`<stop offset="0" stop-color="hsl(%d,60%%,70%%)"/><stop offset="1" stop-color="hsl(%d,60%%,45%%)"/>`+
+		`</linearGradient></defs>`, hue, (hue+60)%360)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="url(#g)"/>`, sliderWidth, sliderHeight)
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="hsl(%d,50%%,%d%%)" fill-opacity="0.35"/>`,
+			randomInt(0, sliderWidth), randomInt(0, sliderHeight), randomInt(8, 40), randomInt(0, 359), randomInt(40, 85))
+	}
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#fff" stroke-opacity="0.3" stroke-width="2"/>`,
+			randomInt(0, sliderWidth), randomInt(0, sliderHeight), randomInt(0, sliderWidth), randomInt(0, sliderHeight))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="8" fill="#000" fill-opacity="0.45" stroke="#fff" stroke-width="2"/>`,
+		offset, top, sliderPiece, sliderPiece)
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// sliderPieceSVG 生成拼图块图片
+func sliderPieceSVG(hue int) string {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+
+		`<rect x="1" y="1" width="%d" height="%d" rx="8" fill="hsl(%d,65%%,60%%)" stroke="#fff" stroke-width="2"/></svg>`,
+		sliderPiece, sliderPiece, sliderPiece, sliderPiece, sliderPiece-2, sliderPiece-2, hue)
+}
+
+// svgDataURI 将SVG转换为可直接用于 img src 的 data URI
+func svgDataURI(svg string) template.URL {
+	return template.URL("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg)))
+}
+
+// randomInt 生成 [low, high] 范围内的随机整数
+func randomInt(low, high int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(high-low+1)))
+	if err != nil {
+		return low
+	}
+	return low + int(n.Int64())
+}
+
+// randomHex 生成随机十六进制字符串
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,169 @@
+package geoip
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// CountryLAN 局域网、本机等非公网地址的国家代码（不需要查询IP库）
+const CountryLAN = "LAN"
+
+// Location IP归属地
+type Location struct {
+	Country  string `json:"country"`            // 国家/地区代码（ISO 3166-1 两位大写字母）
+	Province string `json:"province,omitempty"` // 省份/州（IP库未提供时为空）
+}
+
+// String 显示文本（如 "CN 广东"）
+func (l Location) String() string {
+	if l.Province == "" {
+		return l.Country
+	}
+	return l.Country + " " + l.Province
+}
+
+// ipRange IP库中的一个地址段
+type ipRange struct {
+	start, end netip.Addr
+	location   Location
+}
+
+// DB 离线IP库（全部加载到内存，按起始地址二分查找）
+type DB struct {
+	ranges []ipRange
+}
+
+// Open 加载IP库文件
+// 文件为CSV格式，每行 起始IP,结束IP,国家代码[,省份]，支持IPv4和IPv6，# 开头的行为注释；
+// 与 DB-IP 免费版国家库（dbip-country-lite.csv）格式兼容
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	defer f.Close()
+
+	db, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load geoip database %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Parse 从CSV内容加载IP库
+func Parse(r io.Reader) (*DB, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	db := &DB{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected start_ip,end_ip,country[,province]", line)
+		}
+
+		start, err := netip.ParseAddr(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid start ip %q", line, record[0])
+		}
+		end, err := netip.ParseAddr(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid end ip %q", line, record[1])
+		}
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, start, end)
+		}
+
+		location := Location{Country: strings.ToUpper(strings.TrimSpace(record[2]))}
+		if len(record) > 3 {
+			location.Province = strings.TrimSpace(record[3])
+		}
+		db.ranges = append(db.ranges, ipRange{start: start, end: end, location: location})
+	}
+
+	if len(db.ranges) == 0 {
+		return nil, errors.New("no ip ranges found")
+	}
+
+	// 按起始地址排序（地址段不应重叠，重叠时查询结果以起始地址较大的为准）
+	sort.SliceStable(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+	return db, nil
+}
+
+// Len 地址段数量
+func (db *DB) Len() int {
+	return len(db.ranges)
+}
+
+// Lookup 查询IP归属地
+// 局域网、本机等非公网地址返回 CountryLAN；IP格式错误或IP库中不存在时返回false
+func (db *DB) Lookup(ip string) (Location, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return Location{}, false
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return Location{Country: CountryLAN}, true
+	}
+
+	// 最后一个起始地址不大于ip的地址段（IPv4地址排在IPv6之前，两者不会混淆）
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	}) - 1
+	if i < 0 || db.ranges[i].end.Less(addr) {
+		return Location{}, false
+	}
+	return db.ranges[i].location, true
+}
+
+// defaultDB 全局IP库（未启用时为nil）
+var defaultDB atomic.Pointer[DB]
+
+// SetDefault 设置全局IP库（nil表示不启用IP归属地）
+func SetDefault(db *DB) {
+	defaultDB.Store(db)
+}
+
+// Enabled 是否已加载全局IP库
+func Enabled() bool {
+	return defaultDB.Load() != nil
+}
+
+// Lookup 使用全局IP库查询IP归属地，未启用时返回false
+func Lookup(ip string) (Location, bool) {
+	db := defaultDB.Load()
+	if db == nil {
+		return Location{}, false
+	}
+	return db.Lookup(ip)
+}
+
+// Describe 归属地显示文本，未启用或查询不到时为空
+func Describe(ip string) string {
+	location, ok := Lookup(ip)
+	if !ok {
+		return ""
+	}
+	return location.String()
+}
@@ -0,0 +1,104 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsCache DNS解析缓存
+// 通知等出站请求会频繁访问相同的域名，缓存解析结果以减少DNS查询延迟和DNS故障的影响；
+// 各连接池共享解析结果，使用各自的拨号器（出站地址不同）
+type dnsCache struct {
+	ttl     time.Duration
+	entries map[string]dnsEntry
+	mu      sync.RWMutex
+}
+
+// dnsEntry 缓存的解析结果
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// newDNSCache 创建DNS缓存
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{
+		ttl:     ttl,
+		entries: make(map[string]dnsEntry),
+	}
+}
+
+// dial 使用缓存的解析结果建立连接（依次尝试各个地址）
+func (c *dnsCache) dial(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		// 指定了出站地址时跳过与其地址族不同的地址（IPv4出站地址无法连接IPv6目标）
+		if !sameFamily(dialer.LocalAddr, addr) {
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("no %s address matches local address %s", host, dialer.LocalAddr)
+	}
+
+	// 地址可能已失效，下次重新解析
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+
+	return nil, lastErr
+}
+
+// lookup 解析域名（优先使用缓存）
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[host]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		// 解析失败时继续使用过期的结果
+		if ok {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return addrs, nil
+}
+
+// sameFamily 目标地址与出站地址是否同为IPv4或IPv6（未指定出站地址时总是true）
+func sameFamily(local net.Addr, addr string) bool {
+	tcpAddr, ok := local.(*net.TCPAddr)
+	if !ok || tcpAddr.IP == nil {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip == nil || (ip.To4() != nil) == (tcpAddr.IP.To4() != nil)
+}
@@ -0,0 +1,365 @@
+// Package httpclient 出站HTTP请求的共享客户端
+// @description 统一管理连接池、DNS缓存、代理和按目标区分的超时，替代各处临时创建的 http.Client
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// 出站请求目标
+const (
+	Alipay    = "alipay"     // 支付宝网关
+	Notify    = "notify"     // 商户异步通知
+	QRCodeAPI = "qrcode_api" // 在线二维码API
+	Captcha   = "captcha"    // 人机验证服务（hCaptcha/Turnstile）
+	Notifier  = "notifier"   // 运营通知渠道（Webhook/钉钉/企业微信）
+)
+
+// ProxyNone 不使用代理（忽略 HTTP_PROXY/HTTPS_PROXY 环境变量）
+const ProxyNone = "none"
+
+// proxySchemes 支持的代理协议（socks5 由代理服务器解析目标域名，与 socks5h 相同）
+var proxySchemes = map[string]bool{
+	"http":    true,
+	"https":   true,
+	"socks5":  true,
+	"socks5h": true,
+}
+
+// Destination 目标配置
+type Destination struct {
+	Timeout      time.Duration
+	Proxy        string // 为空时使用全局代理
+	LocalAddress string // 为空时使用全局出站地址
+}
+
+// Options 客户端配置
+type Options struct {
+	Proxy               string // 全局代理URL（http/https/socks5），为空时使用 HTTP_PROXY/HTTPS_PROXY 环境变量，"none" 不使用代理
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DNSCacheTTL         time.Duration // 0 表示不缓存
+	Destinations        map[string]Destination
+
+	// 连接参数（0 使用 http.DefaultTransport 的默认值）
+	DialTimeout           time.Duration // 建立TCP连接超时（经过代理时为连接代理的超时）
+	TLSHandshakeTimeout   time.Duration // TLS握手超时
+	ResponseHeaderTimeout time.Duration // 发送请求后等待响应头的超时，0 表示只受请求超时限制
+	CAFile                string        // 额外信任的CA证书（PEM），用于解密HTTPS流量的企业代理
+
+	// 出站地址：本机IP或网卡名（使用网卡的第一个地址），为空时由系统按路由选择。
+	// 多网卡服务器的默认路由IP不在支付宝应用的IP白名单中时，指定白名单中的IP
+	LocalAddress string
+}
+
+// defaultTimeouts 未配置时各目标的超时
+var defaultTimeouts = map[string]time.Duration{
+	Alipay:    30 * time.Second,
+	Notify:    10 * time.Second,
+	QRCodeAPI: 10 * time.Second,
+	Captcha:   10 * time.Second,
+	Notifier:  10 * time.Second,
+}
+
+var (
+	options    = Options{MaxIdleConns: 100, MaxIdleConnsPerHost: 10, IdleConnTimeout: 90 * time.Second}
+	transports = make(map[transportKey]*http.Transport) // 按代理和出站地址区分的连接池
+	localIPs   = make(map[string]net.IP)                // 出站地址配置解析出的本机IP
+	resolver   *dnsCache
+	rootCAs    *x509.CertPool // 系统CA加 CAFile，为空时使用系统CA
+	mu         sync.RWMutex
+)
+
+// transportKey 连接池的区分条件
+type transportKey struct {
+	proxy        string
+	localAddress string
+}
+
+// Init 初始化共享客户端（启动时调用一次，未调用时使用默认配置）
+func Init(opts Options) error {
+	for _, proxy := range proxies(opts) {
+		if _, err := proxyFunc(proxy); err != nil {
+			return err
+		}
+	}
+
+	ips := make(map[string]net.IP)
+	for _, address := range localAddresses(opts) {
+		if address == "" {
+			continue
+		}
+		ip, err := resolveLocalAddress(address)
+		if err != nil {
+			return err
+		}
+		ips[address] = ip
+	}
+
+	var pool *x509.CertPool
+	if opts.CAFile != "" {
+		var err error
+		if pool, err = loadCAFile(opts.CAFile); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, t := range transports {
+		t.CloseIdleConnections()
+	}
+
+	options = opts
+	transports = make(map[transportKey]*http.Transport)
+	localIPs = ips
+	rootCAs = pool
+	resolver = nil
+	if opts.DNSCacheTTL > 0 {
+		resolver = newDNSCache(opts.DNSCacheTTL)
+	}
+
+	return nil
+}
+
+// loadCAFile 读取额外信任的CA证书，与系统CA合并
+func loadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificate found in ca file: %s", path)
+	}
+	return pool, nil
+}
+
+// resolveLocalAddress 解析出站地址：本机IP，或网卡名（优先使用网卡的第一个IPv4地址）
+func resolveLocalAddress(address string) (net.IP, error) {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local address %q: not an IP or network interface", address)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read addresses of interface %s: %w", address, err)
+	}
+
+	var ipv6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if ipv6 == nil {
+			ipv6 = ipNet.IP
+		}
+	}
+	if ipv6 == nil {
+		return nil, fmt.Errorf("network interface %s has no usable address", address)
+	}
+	return ipv6, nil
+}
+
+// newDialer 创建拨号器（超时为0时与 http.DefaultTransport 一致，localIP 为空时由系统选择出站地址）
+func newDialer(timeout time.Duration, localIP net.IP) *net.Dialer {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if localIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+	return dialer
+}
+
+// For 获取指定目标的客户端
+func For(destination string) *http.Client {
+	mu.RLock()
+	dest, ok := options.Destinations[destination]
+	mu.RUnlock()
+
+	timeout := dest.Timeout
+	if !ok || timeout <= 0 {
+		timeout = defaultTimeouts[destination]
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transportFor(dest.Proxy, dest.LocalAddress),
+	}
+}
+
+// New 获取使用全局代理、全局出站地址和共享连接池的客户端
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transportFor("", ""),
+	}
+}
+
+// transportFor 获取代理和出站地址对应的连接池（按需创建）
+func transportFor(proxy, localAddress string) *http.Transport {
+	mu.RLock()
+	if proxy == "" {
+		proxy = options.Proxy
+	}
+	if localAddress == "" {
+		localAddress = options.LocalAddress
+	}
+	key := transportKey{proxy: proxy, localAddress: localAddress}
+	t, ok := transports[key]
+	mu.RUnlock()
+	if ok {
+		return t
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if t, ok := transports[key]; ok {
+		return t
+	}
+
+	// 代理地址和出站地址已在 Init 中校验
+	pf, _ := proxyFunc(proxy)
+	dialer := newDialer(options.DialTimeout, localIPs[localAddress])
+
+	t = http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = pf
+	t.MaxIdleConns = options.MaxIdleConns
+	t.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	t.IdleConnTimeout = options.IdleConnTimeout
+	if resolver != nil {
+		cache := resolver
+		t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return cache.dial(ctx, dialer, network, address)
+		}
+	} else {
+		t.DialContext = dialer.DialContext
+	}
+	if options.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = options.TLSHandshakeTimeout
+	}
+	t.ResponseHeaderTimeout = options.ResponseHeaderTimeout
+	if rootCAs != nil {
+		t.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	}
+
+	transports[key] = t
+	return t
+}
+
+// proxyFunc 解析代理设置
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case ProxyNone:
+		return nil, nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url: %q", Redact(proxy))
+	}
+	if !proxySchemes[u.Scheme] {
+		return nil, fmt.Errorf("unsupported proxy scheme %q (supported: http, https, socks5, socks5h)", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// Proxies 各目标实际使用的代理（用于启动日志，密码已隐藏）
+// 未配置代理的目标为 "environment"（读取 HTTP_PROXY/HTTPS_PROXY）或 "none"
+func Proxies() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make(map[string]string, len(defaultTimeouts))
+	for destination := range defaultTimeouts {
+		proxy := options.Destinations[destination].Proxy
+		if proxy == "" {
+			proxy = options.Proxy
+		}
+		if proxy == "" {
+			proxy = "environment"
+		}
+		result[destination] = Redact(proxy)
+	}
+	return result
+}
+
+// LocalAddresses 指定了出站地址的目标及其地址（用于启动日志）
+func LocalAddresses() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make(map[string]string)
+	for destination := range defaultTimeouts {
+		address := options.Destinations[destination].LocalAddress
+		if address == "" {
+			address = options.LocalAddress
+		}
+		if address == "" {
+			continue
+		}
+		if ip := localIPs[address]; ip != nil && ip.String() != address {
+			address += " (" + ip.String() + ")"
+		}
+		result[destination] = address
+	}
+	return result
+}
+
+// Redact 隐藏代理URL中的密码
+func Redact(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	return u.Redacted()
+}
+
+// proxies 配置中出现的全部代理设置
+func proxies(opts Options) []string {
+	list := []string{opts.Proxy}
+	for _, dest := range opts.Destinations {
+		list = append(list, dest.Proxy)
+	}
+	return list
+}
+
+// localAddresses 配置中出现的全部出站地址
+func localAddresses(opts Options) []string {
+	list := []string{opts.LocalAddress}
+	for _, dest := range opts.Destinations {
+		list = append(list, dest.LocalAddress)
+	}
+	return list
+}
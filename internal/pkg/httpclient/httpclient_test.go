@@ -0,0 +1,52 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOutboundLocalAddress 出站请求按目标使用指定的本机地址；域名解析出的地址与出站地址族不同时跳过
+func TestOutboundLocalAddress(t *testing.T) {
+	defer Init(Options{MaxIdleConns: 100, MaxIdleConnsPerHost: 10, IdleConnTimeout: 90 * time.Second})
+
+	if err := Init(Options{LocalAddress: "no-such-iface0"}); err == nil {
+		t.Fatalf("unknown interface accepted as local address")
+	}
+	err := Init(Options{
+		DNSCacheTTL: time.Minute,
+		Destinations: map[string]Destination{
+			Notifier: {LocalAddress: "127.0.0.2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var remote atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		remote.Store(host)
+	}))
+	defer server.Close()
+	// localhost 可能先解析为 ::1，IPv4出站地址应跳过该地址
+	target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	for destination, want := range map[string]string{Notifier: "127.0.0.2", Alipay: "127.0.0.1"} {
+		resp, err := For(destination).Get(target)
+		if err != nil {
+			t.Fatalf("%s request failed: %v", destination, err)
+		}
+		resp.Body.Close()
+		if got := remote.Load(); got != want {
+			t.Fatalf("%s request from %v, want %s", destination, got, want)
+		}
+	}
+	if addresses := LocalAddresses(); len(addresses) != 1 || addresses[Notifier] != "127.0.0.2" {
+		t.Fatalf("local addresses = %v, want notifier only", addresses)
+	}
+}
@@ -0,0 +1,91 @@
+// Package i18n 支付页面和错误提示的多语言文本
+// 文本按语言保存在 messages 中，缺少翻译时使用默认语言（简体中文），仍缺少时返回键名
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的语言
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+
+	// Default 默认语言（未指定或不支持时使用）
+	Default = ZhCN
+)
+
+// Normalize 将语言标签规范化为支持的语言
+// zh、zh-TW、zh_Hans 等归为 zh-CN，en、en-GB 等归为 en-US，不支持的语言返回空字符串
+func Normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	primary, _, _ := strings.Cut(strings.ReplaceAll(lang, "_", "-"), "-")
+
+	switch primary {
+	case "zh":
+		return ZhCN
+	case "en":
+		return EnUS
+	default:
+		return ""
+	}
+}
+
+// Detect 按浏览器的 Accept-Language 请求头选择语言（按q值从高到低取第一个支持的语言）
+// 请求头为空或均不支持时返回默认语言
+func Detect(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if lang := Normalize(tag); lang != "" && q > 0 {
+			candidates = append(candidates, candidate{lang: lang, q: q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].lang
+}
+
+// T 获取文本，有参数时按 fmt.Sprintf 格式化
+// @param lang 语言（不支持时使用默认语言）
+// @param key 文本键名
+func T(lang, key string, args ...interface{}) string {
+	text, ok := messages[lang][key]
+	if !ok {
+		if text, ok = messages[Default][key]; !ok {
+			text = key
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Lang 规范化后的语言，不支持时返回默认语言
+func Lang(lang string) string {
+	if lang = Normalize(lang); lang != "" {
+		return lang
+	}
+	return Default
+}
@@ -0,0 +1,282 @@
+package i18n
+
+// messages 各语言的文本（语言 -> 键名 -> 文本）
+// 键名前缀：status 订单状态，common 通用，tip 支付说明和提示，error 错误页面，api 接口错误，
+// pay 支付页面（pay.html，submit.html 共用），submit 收银台页面，captcha 下单人机验证页面，
+// botcheck 下单机器人过滤页面
+var messages = map[string]map[string]string{
+	ZhCN: {
+		// 订单状态
+		"status.0": "待支付",
+		"status.1": "已支付",
+		"status.2": "已关闭",
+		"status.3": "已退款",
+		"status.4": "已过期",
+
+		// 通用
+		"common.separator": "：",
+
+		// 支付说明和提示
+		"tip.business.instruction": "请使用支付宝扫描二维码，支付金额：%s 元",
+		"tip.business.amount":      "请务必支付准确金额：%s 元",
+		"tip.business.no_remark":   "支付时无需填写备注信息",
+		"tip.business.timeout":     "请在%d分钟内完成支付，超时订单将被自动删除",
+		"tip.business.keep_open":   "订单长期有效，可稍后完成支付",
+		"tip.partial.remark":       "如需分笔支付，请在每笔付款备注中填写订单号 %s",
+		"tip.partial.received":     "已收到 %s 元，还需支付 %s 元",
+		"tip.business.detect":      "支付完成后系统会自动检测到账",
+		"tip.business.contact":     "如长时间未到账，请联系客服",
+		"tip.adjustment_note":      "检测到相同金额订单，实际支付金额已调整为 %s 元",
+		"tip.precreate":            "请使用支付宝扫描二维码，支付 %s 元",
+		"tip.wap":                  "请在支付宝收银台完成支付 %s 元",
+		"tip.sandbox.instruction":  "沙箱测试订单，请在支付页面点击「模拟支付」",
+		"tip.sandbox.no_charge":    "这是沙箱测试订单，不会产生真实扣款",
+		"tip.sandbox.confirm":      "在支付页面点击「模拟支付」后，系统将在下一个监听周期确认支付并通知商户",
+
+		// 错误页面
+		"error.title":                "支付失败",
+		"error.default":              "订单处理失败，请稍后重试",
+		"error.code":                 "错误代码",
+		"error.retry":                "返回重试",
+		"error.contact":              "如有疑问，请联系客服",
+		"error.invalid_params":       "参数错误",
+		"error.missing_params":       "缺少必要参数",
+		"error.invalid_amount":       "金额格式错误",
+		"error.order_not_found":      "订单不存在",
+		"error.order_expired":        "订单未找到或已失效",
+		"error.order_paid":           "订单已支付",
+		"error.order_paid_desc":      "该订单已完成支付",
+		"error.system":               "系统错误",
+		"error.qrcode_unavailable":   "无法加载收款码",
+		"error.no_return_url":        "订单未找到或未设置跳转地址",
+		"error.redirect_failed":      "跳转失败",
+		"error.invalid_return_url":   "商户跳转地址无效",
+		"error.create_failed":        "创建订单失败",
+		"error.rescan":               "请稍后重新扫码",
+		"error.printed_invalid":      "收款码无效",
+		"error.printed_invalid_desc": "收款码不存在或已失效",
+		"error.sandbox_not_found":    "沙箱订单未找到",
+		"error.sandbox_failed":       "模拟支付失败，请稍后重试",
+
+		// 接口错误
+		"api.invalid_params": "参数错误: %s",
+		"api.invalid_sign":   "签名验证失败",
+
+		// 支付页面
+		"pay.title":            "扫码支付",
+		"pay.heading":          "支付宝扫码支付",
+		"pay.loading":          "正在加载支付页面...",
+		"pay.scan_hint":        "请使用支付宝APP扫描下方二维码完成支付",
+		"pay.trade_no":         "订单号",
+		"pay.name":             "商品名称",
+		"pay.amount":           "订单金额",
+		"pay.payment_amount":   "应付金额",
+		"pay.create_time":      "创建时间",
+		"pay.status":           "支付状态",
+		"pay.qrcode_label":     "付款二维码",
+		"pay.qrcode_alt":       "支付宝付款二维码，应付金额 %s 元",
+		"pay.sandbox_tip":      "沙箱测试订单，不会产生真实扣款",
+		"pay.sandbox_button":   "模拟支付",
+		"pay.launch_alipay":    "手机端拉起支付宝",
+		"pay.launch_tip":       "适用于手机浏览器，点击直接打开支付宝APP",
+		"pay.open_alipay":      "打开支付宝",
+		"pay.tips_title":       "支付提示：",
+		"pay.steps_title":      "支付步骤",
+		"pay.step1":            "打开支付宝APP，点击首页「扫一扫」",
+		"pay.step2":            "扫描上方二维码",
+		"pay.step2_amount":     "，输入金额",
+		"pay.step3":            "确认支付后，页面将自动跳转",
+		"pay.noscript":         "当前浏览器未启用JavaScript，支付状态不会自动刷新，请在 %s 前完成支付后手动刷新页面",
+		"pay.noscript_open":    "当前浏览器未启用JavaScript，支付状态不会自动刷新，完成支付后请手动刷新页面",
+		"pay.noscript_return":  "，或",
+		"pay.return_merchant":  "返回商户页面",
+		"pay.period":           "。",
+		"pay.countdown_before": "请在",
+		"pay.countdown_after":  "内完成支付",
+		"pay.countdown_label":  "支付剩余时间",
+		"pay.keep_open":        "订单长期有效，可稍后完成支付",
+		"pay.expired":          "已过期",
+		"pay.done":             "已完成",
+		"pay.checking":         "正在检测支付状态...",
+		"pay.help":             "支付遇到问题？",
+		"pay.contact":          "联系客服",
+		"pay.support":          "客服：",
+		"pay.footer":           "Powered by AliMPay · 安全支付保障",
+		"pay.help_alert":       "如需帮助，请联系商户客服",
+		"pay.use_phone":        "请使用手机扫描二维码支付",
+		"pay.missing_qrcode":   "系统配置错误：缺少收款码ID",
+		"pay.wechat_browser":   "请点击右上角，选择\"在浏览器中打开\"",
+		"pay.opening":          "正在打开支付宝...",
+		"pay.open_failed":      "如未打开支付宝，请手动打开APP扫码",
+		"pay.open_in_browser":  "在浏览器中打开",
+		"pay.pc_title":         "电脑端访问",
+		"pay.pc_hint":          "请使用手机扫描上方二维码完成支付",
+		"pay.paid":             "支付成功！",
+		"pay.paid_redirect":    "支付成功！正在跳转...",
+		"pay.offline":          "网络连接已断开，恢复后将自动继续检测支付状态",
+
+		// 收银台页面（/submit）
+		"submit.title":           "支付中心",
+		"submit.subtitle":        "安全快捷的支付体验",
+		"submit.amount":          "支付金额",
+		"submit.adjusted":        "检测到相同金额订单，实际支付金额已调整为",
+		"submit.adjusted_order":  "（订单金额",
+		"submit.adjusted_pay":    "），请按实际支付金额付款",
+		"submit.noscript":        "当前浏览器未启用JavaScript，支付状态不会自动刷新。请在 %s 前完成支付",
+		"submit.noscript_open":   "当前浏览器未启用JavaScript，支付状态不会自动刷新",
+		"submit.noscript_return": "，付款后",
+		"submit.qr_hint":         "使用支付宝扫码完成支付",
+		"submit.footer":          "由码支付提供技术支持",
+		"submit.pc_warning":      "提示：您可能在使用电脑，正在尝试打开支付宝...",
+		"submit.open_failed":     "如未打开支付宝，请手动扫码",
+		"submit.scan":            "请使用支付宝扫描二维码完成支付",
+
+		// 下单人机验证页面（/submit）
+		"captcha.title":       "安全验证",
+		"captcha.hint":        "当前网络环境存在风险，请完成验证后继续支付",
+		"captcha.slider_hint": "拖动下方滑块，将拼图块移动到缺口处",
+		"captcha.failed":      "验证未通过，请重试",
+		"captcha.submit":      "继续支付",
+		"captcha.unavailable": "安全验证暂不可用，请稍后重试",
+
+		// 下单机器人过滤页面（/submit）
+		"botcheck.title":    "安全检查",
+		"botcheck.hint":     "正在检查浏览器环境，完成后将自动继续支付，请稍候",
+		"botcheck.noscript": "请启用浏览器的JavaScript后刷新页面",
+		"botcheck.submit":   "继续支付",
+		"botcheck.rejected": "请求异常，请返回商户页面重新下单",
+	},
+
+	EnUS: {
+		"status.0": "Pending",
+		"status.1": "Paid",
+		"status.2": "Closed",
+		"status.3": "Refunded",
+		"status.4": "Expired",
+
+		"common.separator": ": ",
+
+		"tip.business.instruction": "Scan the QR code with Alipay and pay %s CNY",
+		"tip.business.amount":      "Please pay the exact amount: %s CNY",
+		"tip.business.no_remark":   "No payment note is needed",
+		"tip.business.timeout":     "Please pay within %d minutes, unpaid orders are closed automatically",
+		"tip.business.keep_open":   "This order stays open, you can pay later",
+		"tip.partial.remark":       "To pay in several transfers, enter the order number %s as the note of each transfer",
+		"tip.partial.received":     "%s CNY received, %s CNY remaining",
+		"tip.business.detect":      "Your payment will be detected automatically",
+		"tip.business.contact":     "If your payment is not confirmed after a while, please contact support",
+		"tip.adjustment_note":      "Another order has the same amount, the amount to pay has been adjusted to %s CNY",
+		"tip.precreate":            "Scan the QR code with Alipay and pay %s CNY",
+		"tip.wap":                  "Complete the payment of %s CNY on the Alipay checkout page",
+		"tip.sandbox.instruction":  "Sandbox test order, click \"Simulate payment\" on the payment page",
+		"tip.sandbox.no_charge":    "This is a sandbox test order, no real money will be charged",
+		"tip.sandbox.confirm":      "After you click \"Simulate payment\", the payment is confirmed in the next monitoring cycle and the merchant is notified",
+
+		"error.title":                "Payment Failed",
+		"error.default":              "Failed to process the order, please try again later",
+		"error.code":                 "Error code",
+		"error.retry":                "Go back and retry",
+		"error.contact":              "If you have any questions, please contact support",
+		"error.invalid_params":       "Invalid Parameters",
+		"error.missing_params":       "Required parameters are missing",
+		"error.invalid_amount":       "Invalid amount",
+		"error.order_not_found":      "Order Not Found",
+		"error.order_expired":        "The order does not exist or has expired",
+		"error.order_paid":           "Order Paid",
+		"error.order_paid_desc":      "This order has already been paid",
+		"error.system":               "System Error",
+		"error.qrcode_unavailable":   "Unable to load the payment QR code",
+		"error.no_return_url":        "The order does not exist or has no return URL",
+		"error.redirect_failed":      "Redirect Failed",
+		"error.invalid_return_url":   "The merchant return URL is invalid",
+		"error.create_failed":        "Failed to Create Order",
+		"error.rescan":               "Please scan the code again later",
+		"error.printed_invalid":      "Invalid Payment Code",
+		"error.printed_invalid_desc": "The payment code does not exist or is no longer valid",
+		"error.sandbox_not_found":    "Sandbox order not found",
+		"error.sandbox_failed":       "Simulated payment failed, please try again later",
+
+		"api.invalid_params": "Invalid parameters: %s",
+		"api.invalid_sign":   "Signature verification failed",
+
+		"pay.title":            "Scan to Pay",
+		"pay.heading":          "Alipay QR Code Payment",
+		"pay.loading":          "Loading payment page...",
+		"pay.scan_hint":        "Scan the QR code below with the Alipay app to pay",
+		"pay.trade_no":         "Order No.",
+		"pay.name":             "Product",
+		"pay.amount":           "Order amount",
+		"pay.payment_amount":   "Amount due",
+		"pay.create_time":      "Created at",
+		"pay.status":           "Status",
+		"pay.qrcode_label":     "Payment QR code",
+		"pay.qrcode_alt":       "Alipay payment QR code, amount due %s CNY",
+		"pay.sandbox_tip":      "Sandbox test order, no real money will be charged",
+		"pay.sandbox_button":   "Simulate payment",
+		"pay.launch_alipay":    "Open in Alipay",
+		"pay.launch_tip":       "For mobile browsers, opens the Alipay app directly",
+		"pay.open_alipay":      "Open Alipay",
+		"pay.tips_title":       "Tips:",
+		"pay.steps_title":      "How to pay",
+		"pay.step1":            "Open the Alipay app and tap \"Scan\" on the home screen",
+		"pay.step2":            "Scan the QR code above",
+		"pay.step2_amount":     " and enter the amount",
+		"pay.step3":            "After you confirm the payment, this page redirects automatically",
+		"pay.noscript":         "JavaScript is disabled, so the payment status will not refresh automatically. Please pay before %s and then reload this page",
+		"pay.noscript_open":    "JavaScript is disabled, so the payment status will not refresh automatically. Please reload this page after paying",
+		"pay.noscript_return":  ", or ",
+		"pay.return_merchant":  "return to the merchant",
+		"pay.period":           ".",
+		"pay.countdown_before": "Please pay within",
+		"pay.countdown_after":  "",
+		"pay.countdown_label":  "Time remaining",
+		"pay.keep_open":        "This order stays open, you can pay later",
+		"pay.expired":          "Expired",
+		"pay.done":             "Done",
+		"pay.checking":         "Checking payment status...",
+		"pay.help":             "Having trouble? ",
+		"pay.contact":          "Contact support",
+		"pay.support":          "Support: ",
+		"pay.footer":           "Powered by AliMPay · Secure payment",
+		"pay.help_alert":       "For help, please contact the merchant's support",
+		"pay.use_phone":        "Please scan the QR code with your phone",
+		"pay.missing_qrcode":   "Configuration error: the QR code ID is missing",
+		"pay.wechat_browser":   "Tap the menu in the top right corner and choose \"Open in browser\"",
+		"pay.opening":          "Opening Alipay...",
+		"pay.open_failed":      "If Alipay did not open, please open the app and scan the QR code",
+		"pay.open_in_browser":  "Open in browser",
+		"pay.pc_title":         "Desktop browser",
+		"pay.pc_hint":          "Scan the QR code above with your phone to pay",
+		"pay.paid":             "Payment successful!",
+		"pay.paid_redirect":    "Payment successful! Redirecting...",
+		"pay.offline":          "You are offline. The payment status check resumes once the connection is back",
+
+		"submit.title":           "Checkout",
+		"submit.subtitle":        "Fast and secure payment",
+		"submit.amount":          "Amount to pay",
+		"submit.adjusted":        "Another order has the same amount, the amount to pay has been adjusted to",
+		"submit.adjusted_order":  "(order amount",
+		"submit.adjusted_pay":    "), please pay the adjusted amount",
+		"submit.noscript":        "JavaScript is disabled, so the payment status will not refresh automatically. Please pay before %s",
+		"submit.noscript_open":   "JavaScript is disabled, so the payment status will not refresh automatically",
+		"submit.noscript_return": ", then ",
+		"submit.qr_hint":         "Scan with Alipay to pay",
+		"submit.footer":          "Powered by AliMPay",
+		"submit.pc_warning":      "You seem to be on a computer, trying to open Alipay...",
+		"submit.open_failed":     "If Alipay did not open, please scan the QR code",
+		"submit.scan":            "Please scan the QR code with Alipay to pay",
+
+		"captcha.title":       "Security Check",
+		"captcha.hint":        "Your network looks unusual, please complete the check to continue",
+		"captcha.slider_hint": "Drag the slider to move the piece into the gap",
+		"captcha.failed":      "Verification failed, please try again",
+		"captcha.submit":      "Continue to pay",
+		"captcha.unavailable": "The security check is unavailable, please try again later",
+
+		"botcheck.title":    "Security Check",
+		"botcheck.hint":     "Checking your browser, you will continue to the payment automatically",
+		"botcheck.noscript": "Please enable JavaScript in your browser and reload the page",
+		"botcheck.submit":   "Continue to pay",
+		"botcheck.rejected": "The request looks abnormal, please go back to the merchant and place the order again",
+	},
+}
@@ -0,0 +1,97 @@
+// Package listen 创建HTTP服务的监听（TCP地址或Unix domain socket）
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// UnixPrefix Unix domain socket 地址前缀，例如 unix:/run/alimpay/alimpay.sock
+const UnixPrefix = "unix:"
+
+// DefaultSocketMode Unix socket 文件的默认权限（同组的反向代理可以连接）
+const DefaultSocketMode os.FileMode = 0660
+
+// IsUnix 是否为 Unix domain socket 地址
+func IsUnix(address string) bool {
+	return strings.HasPrefix(address, UnixPrefix)
+}
+
+// Open 按地址创建监听
+// host:port 监听TCP：IPv6地址（如 [::]:8080）只监听IPv6，可与 0.0.0.0:8080 同时配置；
+// 省略主机（:8080）时同时监听IPv4和IPv6。unix:/path 监听 Unix domain socket，socket 文件权限为 mode
+func Open(address string, mode os.FileMode) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, UnixPrefix); ok {
+		return openUnix(path, mode)
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	network := "tcp"
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		network = "tcp6"
+	}
+	return net.Listen(network, address)
+}
+
+// openUnix 监听 Unix domain socket
+// 上次未正常退出遗留的 socket 文件会被删除；文件仍有进程在监听或不是 socket 时返回错误
+func openUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("unix socket path is empty")
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return &unixListener{Listener: listener}, nil
+}
+
+// loopback Unix socket 连接的来源地址
+var loopback = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// unixListener Unix socket 连接的来源地址视为本机（127.0.0.1）
+// Unix socket 没有来源IP，视为本机后 trusted_proxies 可以采信本机反向代理传递的客户端IP
+type unixListener struct {
+	net.Listener
+}
+
+// Accept 接受连接
+func (l *unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &localConn{Conn: conn}, nil
+}
+
+// localConn 来源地址为本机的连接
+type localConn struct {
+	net.Conn
+}
+
+// RemoteAddr 来源地址
+func (c *localConn) RemoteAddr() net.Addr {
+	return loopback
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package listen
+
+import (
+	"os"
+	"syscall"
+)
+
+// UpgradeSignals 触发平滑升级的信号
+var UpgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// startProcess 使用当前的可执行文件和命令行参数启动新进程
+// 标准输入输出之后依次传递 fds（新进程中为文件描述符 3, 4, ...）
+func startProcess(env []string, fds []uintptr) (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	files := append([]uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}, fds...)
+	pid, err := syscall.ForkExec(executable, os.Args, &syscall.ProcAttr{
+		Env:   env,
+		Files: files,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return os.FindProcess(pid)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package listen
+
+import (
+	"errors"
+	"os"
+)
+
+// UpgradeSignals 触发平滑升级的信号（Windows 不支持传递监听socket，不启用平滑升级）
+var UpgradeSignals []os.Signal
+
+// startProcess Windows 不支持平滑升级
+func startProcess(env []string, fds []uintptr) (*os.Process, error) {
+	return nil, errors.New("graceful upgrade is not supported on windows")
+}
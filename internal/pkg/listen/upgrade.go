@@ -0,0 +1,264 @@
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 平滑升级（socket handoff）：旧进程收到升级信号后启动新进程，通过继承的文件描述符传递全部监听socket；
+// 新进程就绪后旧进程停止接受新连接，处理完进行中的请求后退出，监听socket始终有进程在接受连接
+const (
+	envListeners = "ALIMPAY_INHERIT_LISTENERS" // 继承的监听地址（换行分隔，依次对应文件描述符 3, 4, ...）
+	envReadyFD   = "ALIMPAY_READY_FD"          // 通知旧进程已就绪的管道的文件描述符
+)
+
+// ErrAlreadyUpgraded 已启动过新进程，当前进程正在退出
+var ErrAlreadyUpgraded = errors.New("already upgraded, this process is shutting down")
+
+// handoff 本进程的监听
+type handoff struct {
+	address  string
+	listener net.Listener
+}
+
+// Upgrader 监听管理和平滑升级
+type Upgrader struct {
+	inherited map[string]*os.File // 继承自旧进程、尚未使用的监听（地址 -> 文件）
+	ready     *os.File            // 通知旧进程已就绪的管道（不是升级启动时为nil）
+	listeners []handoff
+	upgraded  bool
+	mu        sync.Mutex
+}
+
+// NewUpgrader 创建监听管理，读取旧进程传递的监听socket
+func NewUpgrader() *Upgrader {
+	u := &Upgrader{inherited: make(map[string]*os.File)}
+	if addresses := os.Getenv(envListeners); addresses != "" {
+		for i, address := range strings.Split(addresses, "\n") {
+			u.inherited[address] = os.NewFile(uintptr(3+i), address)
+		}
+	}
+	if fd, err := strconv.Atoi(os.Getenv(envReadyFD)); err == nil {
+		u.ready = os.NewFile(uintptr(fd), "ready")
+	}
+
+	// 不传递给本进程启动的其他子进程
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReadyFD)
+	return u
+}
+
+// Inherited 是否由旧进程平滑升级启动
+func (u *Upgrader) Inherited() bool {
+	return u.ready != nil
+}
+
+// Listen 创建监听，旧进程传递了同一地址的监听时直接使用
+func (u *Upgrader) Listen(address string, mode os.FileMode) (net.Listener, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var ln net.Listener
+	var err error
+	if f, ok := u.inherited[address]; ok {
+		delete(u.inherited, address)
+		ln, err = fromFile(f)
+	} else {
+		ln, err = Open(address, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u.listeners = append(u.listeners, handoff{address: address, listener: ln})
+	return ln, nil
+}
+
+// fromFile 使用继承的监听socket
+func fromFile(f *os.File) (net.Listener, error) {
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inherit listener %s: %w", f.Name(), err)
+	}
+	if unixLn, ok := ln.(*net.UnixListener); ok {
+		// socket 文件由旧进程创建，本进程退出时（非升级）同样删除
+		unixLn.SetUnlinkOnClose(true)
+		return &unixListener{Listener: unixLn}, nil
+	}
+	return ln, nil
+}
+
+// Ready 服务已开始接受连接
+// @description 关闭新配置中不再使用的继承监听，写入PID文件，升级启动时通知旧进程退出，并通知systemd
+// @param pidFile PID文件路径（为空时不写入）
+// @return error 写入PID文件或通知旧进程失败
+func (u *Upgrader) Ready(pidFile string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for address, f := range u.inherited {
+		f.Close()
+		delete(u.inherited, address)
+	}
+
+	if pidFile != "" {
+		if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write pid file: %w", err)
+		}
+	}
+
+	if u.ready != nil {
+		_, err := u.ready.Write([]byte{1})
+		u.ready.Close()
+		u.ready = nil
+		if err != nil {
+			return fmt.Errorf("failed to notify previous process: %w", err)
+		}
+	}
+
+	// Type=notify 的 systemd 服务：新进程就绪后成为主进程（需要 NotifyAccess=all）
+	return notifySystemd(fmt.Sprintf("READY=1\nMAINPID=%d", os.Getpid()))
+}
+
+// Upgrade 启动新进程并传递全部监听，等待新进程就绪
+// @description 新进程使用当前的可执行文件路径和命令行参数启动（替换可执行文件后即可升级到新版本）。
+// 新进程就绪后返回nil，调用方应停止接受新连接并在处理完进行中的请求后退出
+// @param timeout 等待新进程就绪的时间
+// @return error 新进程启动失败、就绪前退出或超时（当前进程继续提供服务）
+func (u *Upgrader) Upgrade(timeout time.Duration) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.upgraded {
+		return ErrAlreadyUpgraded
+	}
+
+	// 直接传递监听socket的文件描述符：通过 os.File 传递会将共享的socket切换为阻塞模式，
+	// 当前进程阻塞在 accept 的线程会在关闭监听后仍取走一个连接
+	fds := make([]uintptr, 0, len(u.listeners)+1)
+	addresses := make([]string, 0, len(u.listeners))
+	for _, h := range u.listeners {
+		fd, err := listenerFD(h.listener)
+		if err != nil {
+			return fmt.Errorf("failed to hand off %s: %w", h.address, err)
+		}
+		fds = append(fds, fd)
+		addresses = append(addresses, h.address)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	fds = append(fds, readyW.Fd())
+
+	env := append(os.Environ(),
+		envListeners+"="+strings.Join(addresses, "\n"),
+		fmt.Sprintf("%s=%d", envReadyFD, 3+len(fds)-1),
+	)
+	process, err := startProcess(env, fds)
+	// 关闭本进程持有的写端，新进程退出时读取返回EOF
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		if _, err := readyR.Read(make([]byte, 1)); err != nil {
+			result <- fmt.Errorf("new process exited before it was ready")
+			return
+		}
+		result <- nil
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			go process.Wait()
+			return err
+		}
+	case <-time.After(timeout):
+		process.Kill()
+		go process.Wait()
+		return fmt.Errorf("new process (pid %d) not ready within %s", process.Pid, timeout)
+	}
+
+	// socket 文件已由新进程接管，本进程关闭监听时不删除
+	for _, h := range u.listeners {
+		if unixLn := unwrapUnix(h.listener); unixLn != nil {
+			unixLn.SetUnlinkOnClose(false)
+		}
+	}
+	u.upgraded = true
+	return process.Release()
+}
+
+// Close 关闭本进程的全部监听，停止接受新连接（平滑升级后新连接由新进程接受）
+func (u *Upgrader) Close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, h := range u.listeners {
+		h.listener.Close()
+	}
+}
+
+// listenerFD 监听socket的文件描述符（仅在监听关闭前有效）
+func listenerFD(ln net.Listener) (uintptr, error) {
+	if unixLn := unwrapUnix(ln); unixLn != nil {
+		ln = unixLn
+	}
+	conn, ok := ln.(syscall.Conn)
+	if !ok {
+		return 0, fmt.Errorf("unsupported listener type %T", ln)
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var fd uintptr
+	if err := raw.Control(func(f uintptr) { fd = f }); err != nil {
+		return 0, err
+	}
+	return fd, nil
+}
+
+// unwrapUnix Unix socket 监听（其他监听返回nil）
+func unwrapUnix(ln net.Listener) *net.UnixListener {
+	if wrapped, ok := ln.(*unixListener); ok {
+		ln = wrapped.Listener
+	}
+	unixLn, _ := ln.(*net.UnixListener)
+	return unixLn
+}
+
+// notifySystemd 向 systemd 发送状态通知（未由 Type=notify 的服务启动时忽略）
+func notifySystemd(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // 抽象命名空间
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,190 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireLeaseScript 无人持有时获取租约，由自己持有时续约
+var acquireLeaseScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if not holder then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// RedisLease Redis租约（多实例部署）
+type RedisLease struct {
+	client *redis.Client
+	key    string
+	owner  string
+	ttl    time.Duration
+}
+
+// NewRedisLease 创建Redis租约
+// owner 为当前实例标识，ttl 为租约有效期
+func NewRedisLease(client *redis.Client, key, owner string, ttl time.Duration) *RedisLease {
+	return &RedisLease{
+		client: client,
+		key:    key,
+		owner:  owner,
+		ttl:    ttl,
+	}
+}
+
+// Acquire 获取或续约租约
+func (rl *RedisLease) Acquire() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	acquired, err := acquireLeaseScript.Run(ctx, rl.client, []string{rl.key}, rl.owner, rl.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire redis lease: %w", err)
+	}
+	return acquired == 1, nil
+}
+
+// Holder 获取当前持有者
+func (rl *RedisLease) Holder() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	holder, err := rl.client.Get(ctx, rl.key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get redis lease holder: %w", err)
+	}
+	return holder, nil
+}
+
+// Release 释放租约
+func (rl *RedisLease) Release() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := unlockScript.Run(ctx, rl.client, []string{rl.key}, rl.owner).Err(); err != nil {
+		return fmt.Errorf("failed to release redis lease: %w", err)
+	}
+	return nil
+}
+
+// leaseInfo 文件租约内容
+type leaseInfo struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FileLease 文件租约
+// 适用于同一主机（或共享数据目录）上的多个实例；写入临时文件后重命名，
+// 写入后重新读取确认持有者，同时抢占时只有最后写入的实例获得租约
+type FileLease struct {
+	filePath string
+	owner    string
+	ttl      time.Duration
+	mu       sync.Mutex
+}
+
+// NewFileLease 创建文件租约
+func NewFileLease(filePath, owner string, ttl time.Duration) *FileLease {
+	return &FileLease{
+		filePath: filePath,
+		owner:    owner,
+		ttl:      ttl,
+	}
+}
+
+// Acquire 获取或续约租约
+func (fl *FileLease) Acquire() (bool, error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	current, err := fl.read()
+	if err != nil {
+		return false, err
+	}
+	if current != nil && current.Holder != fl.owner && time.Now().Before(current.ExpiresAt) {
+		return false, nil
+	}
+
+	data, err := json.Marshal(leaseInfo{Holder: fl.owner, ExpiresAt: time.Now().Add(fl.ttl)})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal lease: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(fl.filePath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create lease directory: %w", err)
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", fl.filePath, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write lease file: %w", err)
+	}
+	if err := os.Rename(tmp, fl.filePath); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("failed to write lease file: %w", err)
+	}
+
+	// 其他实例可能同时写入，以最终内容为准
+	written, err := fl.read()
+	if err != nil {
+		return false, err
+	}
+	return written != nil && written.Holder == fl.owner, nil
+}
+
+// Holder 获取当前持有者
+func (fl *FileLease) Holder() (string, error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	current, err := fl.read()
+	if err != nil || current == nil || time.Now().After(current.ExpiresAt) {
+		return "", err
+	}
+	return current.Holder, nil
+}
+
+// Release 释放租约
+func (fl *FileLease) Release() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	current, err := fl.read()
+	if err != nil || current == nil || current.Holder != fl.owner {
+		return err
+	}
+	if err := os.Remove(fl.filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lease file: %w", err)
+	}
+	return nil
+}
+
+// read 读取租约文件，不存在或内容无效时返回nil
+func (fl *FileLease) read() (*leaseInfo, error) {
+	data, err := os.ReadFile(fl.filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+
+	var info leaseInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, nil
+	}
+	return &info, nil
+}
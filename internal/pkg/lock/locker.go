@@ -0,0 +1,29 @@
+package lock
+
+// Locker 互斥锁接口
+// 监听周期等任务通过该接口获取互斥锁，可选择本地文件锁或Redis分布式锁
+type Locker interface {
+	// TryLock 尝试获取锁（非阻塞），返回是否获取成功
+	TryLock() (bool, error)
+	// Unlock 释放锁
+	Unlock() error
+}
+
+// Lease 租约接口
+// 用于多实例部署时的主节点选举：持有者在有效期内定期续约，异常退出后租约过期，由其他实例接管
+type Lease interface {
+	// Acquire 获取租约，已持有时续约，返回当前实例是否持有租约
+	Acquire() (bool, error)
+	// Holder 获取当前持有者标识（无人持有时为空）
+	Holder() (string, error)
+	// Release 释放租约（仅当仍由当前实例持有时）
+	Release() error
+}
+
+// 编译期检查
+var (
+	_ Locker = (*FileLock)(nil)
+	_ Locker = (*RedisLock)(nil)
+	_ Lease  = (*FileLease)(nil)
+	_ Lease  = (*RedisLease)(nil)
+)
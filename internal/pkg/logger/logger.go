@@ -18,6 +18,7 @@ import (
 var (
 	globalLogger *zap.Logger
 	sugarLogger  *zap.SugaredLogger
+	atomicLevel  = zap.NewAtomicLevel() // 运行时可调整的日志级别
 )
 
 // Config 日志配置
@@ -84,19 +85,7 @@ func customCallerEncoder(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayE
 // Init 初始化日志系统
 func Init(cfg *Config) error {
 	// 设置日志级别
-	level := zapcore.InfoLevel
-	switch strings.ToLower(cfg.Level) {
-	case "debug":
-		level = zapcore.DebugLevel
-	case "info":
-		level = zapcore.InfoLevel
-	case "warn", "warning":
-		level = zapcore.WarnLevel
-	case "error":
-		level = zapcore.ErrorLevel
-	case "fatal":
-		level = zapcore.FatalLevel
-	}
+	atomicLevel.SetLevel(parseLevel(cfg.Level))
 
 	// 文件输出的编码器配置（JSON格式）
 	fileEncoderConfig := zapcore.EncoderConfig{
@@ -158,7 +147,7 @@ func Init(cfg *Config) error {
 		// 文件使用JSON格式，便于解析
 		fileEncoder := zapcore.NewJSONEncoder(fileEncoderConfig)
 		fileWriter := zapcore.AddSync(lumberJackLogger)
-		fileCore := zapcore.NewCore(fileEncoder, fileWriter, level)
+		fileCore := zapcore.NewCore(fileEncoder, fileWriter, atomicLevel)
 		cores = append(cores, fileCore)
 	}
 
@@ -166,7 +155,7 @@ func Init(cfg *Config) error {
 	if cfg.Output == "stdout" || cfg.Output == "both" {
 		// 控制台使用彩色格式，便于查看
 		consoleEncoder := zapcore.NewConsoleEncoder(consoleEncoderConfig)
-		consoleCore := zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level)
+		consoleCore := zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), atomicLevel)
 		cores = append(cores, consoleCore)
 	}
 
@@ -178,6 +167,27 @@ func Init(cfg *Config) error {
 	return nil
 }
 
+// parseLevel 解析日志级别，无法识别时使用info
+func parseLevel(name string) zapcore.Level {
+	switch strings.ToLower(name) {
+	case "debug":
+		return zapcore.DebugLevel
+	case "warn", "warning":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	case "fatal":
+		return zapcore.FatalLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// SetLevel 运行时调整日志级别（配置热加载时使用）
+func SetLevel(name string) {
+	atomicLevel.SetLevel(parseLevel(name))
+}
+
 // GetLogger 获取全局logger
 func GetLogger() *zap.Logger {
 	if globalLogger == nil {
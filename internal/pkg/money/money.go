@@ -0,0 +1,72 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+
+	"alimpay-go/internal/model"
+)
+
+// 金额解析错误（错误信息直接返回给商户）
+var (
+	ErrInvalidFormat = errors.New("invalid money format")
+	ErrNotPositive   = errors.New("money must be greater than 0 (0 yuan purchase not allowed)")
+	ErrTooLarge      = fmt.Errorf("money exceeds maximum limit (%s)", model.MaxOrderAmount)
+)
+
+// Parse 严格解析外部传入的金额（元）
+// 只接受 "12"、"12.3"、"12.30"、"-1.00" 这样的写法：不允许空白、正号、科学计数法、千分位分隔符、
+// 全角数字以及超过两位的小数。支付宝账单等可信来源的宽松写法（如 "12.300"）仍使用 model.ParseAmount。
+// 格式化使用 model.Amount.String()，其输出总能被 Parse 解析
+func Parse(s string) (model.Amount, error) {
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+
+	intLen := 0
+	for intLen < len(digits) && isDigit(digits[intLen]) {
+		intLen++
+	}
+	if intLen == 0 {
+		return 0, ErrInvalidFormat
+	}
+
+	if rest := digits[intLen:]; rest != "" {
+		if rest[0] != '.' || len(rest) < 2 || len(rest) > 3 {
+			return 0, ErrInvalidFormat
+		}
+		for i := 1; i < len(rest); i++ {
+			if !isDigit(rest[i]) {
+				return 0, ErrInvalidFormat
+			}
+		}
+	}
+
+	amount, err := model.ParseAmount(s)
+	if err != nil {
+		// 整数部分过长
+		return 0, ErrInvalidFormat
+	}
+	return amount, nil
+}
+
+// ParseOrderAmount 解析订单金额，金额必须大于0且不超过 model.MaxOrderAmount
+func ParseOrderAmount(s string) (model.Amount, error) {
+	amount, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	if amount <= 0 {
+		return 0, ErrNotPositive
+	}
+	if amount > model.MaxOrderAmount {
+		return 0, ErrTooLarge
+	}
+	return amount, nil
+}
+
+// isDigit 是否为ASCII数字
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,33 @@
+package money
+
+import (
+	"testing"
+
+	"alimpay-go/internal/model"
+)
+
+// FuzzParse 严格解析只接受十进制数字和最多两位小数，结果格式化后能原样解析回来
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{"12.30", "0.01", "-1", "99999.99", "1e3", "1,000.00", "+5", " 5", "12.300", "１２"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		amount, err := Parse(s)
+		if err != nil {
+			return
+		}
+
+		for i := 0; i < len(s); i++ {
+			if c := s[i]; !isDigit(c) && c != '.' && c != '-' {
+				t.Fatalf("accepted money %q with character %q", s, c)
+			}
+		}
+		if roundTrip, err := Parse(amount.String()); err != nil || roundTrip != amount {
+			t.Fatalf("money %q does not round-trip: %s", s, amount)
+		}
+		if lenient, err := model.ParseAmount(s); err != nil || lenient != amount {
+			t.Fatalf("strict and lenient parsing disagree on %q: %s vs %s", s, amount, lenient)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// Package notifier 运营通知渠道
+// 将运营消息（日报、告警）发送到通用Webhook、钉钉或企业微信群机器人
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Message 通知消息
+type Message struct {
+	Title string // 标题
+	Text  string // 正文（Markdown，钉钉和企业微信按 Markdown 渲染）
+}
+
+// Channel 通知渠道
+type Channel interface {
+	// Name 渠道类型
+	Name() string
+	// Send 发送消息
+	Send(ctx context.Context, msg Message) error
+}
+
+// Options 创建通知渠道的参数
+type Options struct {
+	URL    string       // Webhook地址（钉钉/企业微信为群机器人地址）
+	Secret string       // 钉钉机器人加签密钥（未启用加签时留空）
+	Client *http.Client // 发送请求的HTTP客户端
+}
+
+// Factory 按参数创建通知渠道
+type Factory func(options Options) (Channel, error)
+
+var (
+	factories = map[string]Factory{
+		"webhook":  NewWebhook,
+		"dingtalk": NewDingTalk,
+		"wecom":    NewWeCom,
+	}
+	mu sync.RWMutex
+)
+
+// Register 注册通知渠道（同名时替换内置实现）
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// New 按类型创建通知渠道
+func New(name string, options Options) (Channel, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier channel %q", name)
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("%s channel requires url", name)
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return factory(options)
+}
+
+// Notifier 同时发送到多个渠道
+type Notifier struct {
+	channels []Channel
+}
+
+// NewNotifier 创建多渠道通知
+func NewNotifier(channels ...Channel) *Notifier {
+	return &Notifier{channels: channels}
+}
+
+// Len 渠道数
+func (n *Notifier) Len() int {
+	return len(n.channels)
+}
+
+// Send 发送到全部渠道（某个渠道失败不影响其他渠道），返回各渠道的错误
+func (n *Notifier) Send(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, channel := range n.channels {
+		if err := channel.Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postJSON 发送JSON请求，返回响应体
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("response read failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("returned HTTP %d", resp.StatusCode)
+	}
+	return data, nil
+}
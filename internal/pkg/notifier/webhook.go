@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// webhook 通用Webhook：POST JSON {"title": ..., "text": ..., "sent_at": ...}，2xx视为成功
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook 创建通用Webhook渠道
+func NewWebhook(options Options) (Channel, error) {
+	return &webhook{url: options.URL, client: options.Client}, nil
+}
+
+// Name 渠道类型
+func (w *webhook) Name() string {
+	return "webhook"
+}
+
+// Send 发送消息
+func (w *webhook) Send(ctx context.Context, msg Message) error {
+	_, err := postJSON(ctx, w.client, w.url, map[string]interface{}{
+		"title":   msg.Title,
+		"text":    msg.Text,
+		"sent_at": time.Now().Unix(),
+	})
+	return err
+}
+
+// robotResponse 钉钉/企业微信群机器人响应（HTTP 200 时以 errcode 判断是否成功）
+type robotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// check 检查群机器人响应
+func (r robotResponse) check(data []byte) error {
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("response invalid: %w", err)
+	}
+	if r.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+// dingTalk 钉钉群机器人（Markdown消息，可选加签）
+type dingTalk struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewDingTalk 创建钉钉群机器人渠道
+func NewDingTalk(options Options) (Channel, error) {
+	return &dingTalk{url: options.URL, secret: options.Secret, client: options.Client}, nil
+}
+
+// Name 渠道类型
+func (d *dingTalk) Name() string {
+	return "dingtalk"
+}
+
+// Send 发送消息
+func (d *dingTalk) Send(ctx context.Context, msg Message) error {
+	target, err := d.signedURL(time.Now())
+	if err != nil {
+		return err
+	}
+	data, err := postJSON(ctx, d.client, target, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": msg.Title,
+			"text":  "### " + msg.Title + "\n\n" + msg.Text,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return robotResponse{}.check(data)
+}
+
+// signedURL 启用加签时附加 timestamp 和 sign 参数
+// sign = Base64(HmacSHA256(secret, timestamp + "\n" + secret))
+func (d *dingTalk) signedURL(now time.Time) (string, error) {
+	if d.secret == "" {
+		return d.url, nil
+	}
+	u, err := url.Parse(d.url)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte(timestamp + "\n" + d.secret))
+
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// weCom 企业微信群机器人（Markdown消息）
+type weCom struct {
+	url    string
+	client *http.Client
+}
+
+// NewWeCom 创建企业微信群机器人渠道
+func NewWeCom(options Options) (Channel, error) {
+	return &weCom{url: options.URL, client: options.Client}, nil
+}
+
+// Name 渠道类型
+func (w *weCom) Name() string {
+	return "wecom"
+}
+
+// Send 发送消息
+func (w *weCom) Send(ctx context.Context, msg Message) error {
+	data, err := postJSON(ctx, w.client, w.url, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": "### " + msg.Title + "\n" + msg.Text,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return robotResponse{}.check(data)
+}
@@ -7,7 +7,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"time"
+
+	"alimpay-go/internal/pkg/httpclient"
 
 	"github.com/skip2/go-qrcode"
 )
@@ -93,13 +94,8 @@ func (g *Generator) GenerateFromURL(content string) ([]byte, error) {
 	apiURL := fmt.Sprintf("https://api.qrserver.com/v1/create-qr-code/?size=%dx%d&data=%s",
 		g.size, g.size, content)
 
-	// 创建HTTP客户端，设置超时
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// 发送请求
-	resp, err := client.Get(apiURL)
+	// 发送请求（共享连接池，超时见 http_client.qrcode_api）
+	resp, err := httpclient.For(httpclient.QRCodeAPI).Get(apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to request QR code API: %w", err)
 	}
@@ -0,0 +1,76 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Provider 密钥来源接口
+// 配置中以 "scheme:引用" 形式书写的值由对应的Provider读取，例如文件、环境变量、Vault或云KMS
+type Provider interface {
+	// Fetch 根据引用读取密钥
+	Fetch(ref string) (string, error)
+}
+
+var (
+	providers = map[string]Provider{
+		"file": FileProvider{},
+		"env":  EnvProvider{},
+	}
+	mu sync.RWMutex
+)
+
+// Register 注册密钥来源（scheme 为引用前缀，如 "vault"）
+func Register(scheme string, provider Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = provider
+}
+
+// Resolve 解析配置值
+// 以已注册的 "scheme:" 开头时从对应来源读取并返回 isRef=true，否则原样返回
+func Resolve(value string) (resolved string, isRef bool, err error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, false, nil
+	}
+
+	mu.RLock()
+	provider, found := providers[scheme]
+	mu.RUnlock()
+	if !found {
+		return value, false, nil
+	}
+
+	resolved, err = provider.Fetch(ref)
+	if err != nil {
+		return "", true, fmt.Errorf("%s secret: %w", scheme, err)
+	}
+	return resolved, true, nil
+}
+
+// FileProvider 从文件读取密钥（如 Docker/Kubernetes Secret 挂载的文件），引用为文件路径
+type FileProvider struct{}
+
+// Fetch 读取文件内容（去除首尾空白）
+func (FileProvider) Fetch(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// EnvProvider 从环境变量读取密钥，引用为变量名
+type EnvProvider struct{}
+
+// Fetch 读取环境变量（未设置时返回错误）
+func (EnvProvider) Fetch(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,76 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/pkg/httpclient"
+)
+
+// VaultProvider 从 HashiCorp Vault KV 引擎读取密钥
+// 引用格式为 "路径#字段"，例如 vault:secret/data/alimpay#private_key（KV v2）或 vault:secret/alimpay#private_key（KV v1）
+type VaultProvider struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+// NewVaultProvider 创建Vault密钥来源
+func NewVaultProvider(address, token string, timeout time.Duration) *VaultProvider {
+	return &VaultProvider{
+		address: strings.TrimRight(address, "/"),
+		token:   token,
+		client:  httpclient.New(timeout),
+	}
+}
+
+// Fetch 读取密钥
+func (p *VaultProvider) Fetch(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid reference %q, expected path#field", ref)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned HTTP %d for %s", resp.StatusCode, path)
+	}
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	// KV v2 的数据嵌套在 data.data 中
+	data := result.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("field %s not found in %s", field, path)
+	}
+	return value, nil
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+/*
+ * ValidIPOrCIDR 是否为IP地址或CIDR网段
+ * @param value string 配置值，如 203.0.113.10、198.51.100.0/24
+ * @return bool 格式是否正确
+ */
+func ValidIPOrCIDR(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(value)
+	return err == nil
+}
+
+/*
+ * IPInList IP是否属于列表中的IP地址或网段
+ * @param ip string 待检查的IP（格式错误时返回false）
+ * @param list []string IP地址或CIDR网段列表
+ * @return bool 是否属于列表
+ */
+func IPInList(ip string, list []string) bool {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return false
+	}
+	for _, item := range list {
+		if _, network, err := net.ParseCIDR(item); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(item); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
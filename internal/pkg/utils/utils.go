@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"sort"
@@ -10,6 +13,12 @@ import (
 	"time"
 )
 
+// 签名类型（请求参数 sign_type）
+const (
+	SignTypeMD5        = "MD5"         // 易支付标准MD5签名（默认，兼容旧版接入）
+	SignTypeHMACSHA256 = "HMAC-SHA256" // 以商户密钥为密钥的HMAC-SHA256签名
+)
+
 // GenerateTradeNo 生成交易号
 func GenerateTradeNo() string {
 	return fmt.Sprintf("%s%06d", time.Now().Format("20060102150405"), RandomInt(1, 999999))
@@ -52,19 +61,68 @@ func MD5(data string) string {
 
 /*
  * GenerateSign 生成签名（兼容易支付标准）
- * @description 按照易支付/码支付标准生成MD5签名
+ * @description 按参数 sign_type 选择签名算法：HMAC-SHA256 使用HMAC-SHA256，
+ * 其他值（含未传）按易支付/码支付标准使用MD5
  * @param params map[string]string 参数Map
  * @param key string 商户密钥
- * @return string 32位小写MD5签名
+ * @return string 小写十六进制签名（MD5为32位，HMAC-SHA256为64位）
  *
  * 签名算法：
  * 1. 过滤空值参数和 sign、sign_type
  * 2. 按参数名ASCII码升序排序
  * 3. 使用URL键值对格式拼接成字符串（key1=value1&key2=value2）
- * 4. 在字符串末尾拼接商户密钥
- * 5. MD5加密并转小写
+ * 4. MD5：在字符串末尾拼接商户密钥后MD5加密并转小写；
+ *    HMAC-SHA256：以商户密钥为密钥计算字符串的HMAC-SHA256（不拼接密钥）
  */
 func GenerateSign(params map[string]string, key string) string {
+	// 1-3. 过滤、排序并拼接参数（见 SignContent）
+	content := SignContent(params)
+
+	if IsHMACSignType(params["sign_type"]) {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(content))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	// 4. 拼接商户密钥并MD5加密（小写）
+	return strings.ToLower(MD5(content + key))
+}
+
+/*
+ * NormalizeSignType 规范化签名类型
+ * @description 大小写不敏感，未传时为 MD5
+ * @param signType string 请求参数 sign_type
+ * @return string 规范化的签名类型
+ * @return bool 是否为支持的签名类型（MD5、HMAC-SHA256）
+ */
+func NormalizeSignType(signType string) (string, bool) {
+	switch {
+	case signType == "" || strings.EqualFold(signType, SignTypeMD5):
+		return SignTypeMD5, true
+	case IsHMACSignType(signType):
+		return SignTypeHMACSHA256, true
+	default:
+		return signType, false
+	}
+}
+
+/*
+ * IsHMACSignType 签名类型是否为 HMAC-SHA256
+ * @param signType string 请求参数 sign_type
+ * @return bool 是否使用HMAC-SHA256签名
+ */
+func IsHMACSignType(signType string) bool {
+	return strings.EqualFold(signType, SignTypeHMACSHA256)
+}
+
+/*
+ * SignContent 生成待签名字符串（不含商户密钥）
+ * @description 过滤空值和 sign、sign_type 后按参数名升序拼接为 key1=value1&key2=value2，
+ * 用于签名计算和向商户展示签名排查信息
+ * @param params map[string]string 参数Map
+ * @return string 待签名字符串
+ */
+func SignContent(params map[string]string) string {
 	// 1. 移除空值和签名相关参数
 	filtered := make(map[string]string)
 	for k, v := range params {
@@ -85,13 +143,7 @@ func GenerateSign(params map[string]string, key string) string {
 	for _, k := range keys {
 		parts = append(parts, fmt.Sprintf("%s=%s", k, filtered[k]))
 	}
-
-	// 4. 拼接商户密钥
-	signStr := strings.Join(parts, "&")
-	signStrWithKey := signStr + key
-
-	// 5. MD5加密（小写）
-	return strings.ToLower(MD5(signStrWithKey))
+	return strings.Join(parts, "&")
 }
 
 /*
@@ -111,7 +163,29 @@ func VerifySign(params map[string]string, key string) bool {
 	expectedSign := GenerateSign(params, key)
 
 	// 大小写不敏感比对（易支付兼容性）
-	return strings.EqualFold(receivedSign, expectedSign)
+	return SecureCompareFold(receivedSign, expectedSign)
+}
+
+/*
+ * SecureCompare 常量时间字符串比较
+ * @description 比较耗时与内容无关，用于密钥、令牌等敏感数据，防止时序攻击
+ * @param a string 待比较字符串
+ * @param b string 待比较字符串
+ * @return bool 是否相等
+ */
+func SecureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+/*
+ * SecureCompareFold 大小写不敏感的常量时间比较
+ * @description 用于十六进制签名比对（易支付允许大写签名）
+ * @param a string 待比较字符串
+ * @param b string 待比较字符串
+ * @return bool 是否相等
+ */
+func SecureCompareFold(a, b string) bool {
+	return SecureCompare(strings.ToLower(a), strings.ToLower(b))
 }
 
 /*
@@ -129,50 +203,25 @@ func VerifySignDebug(params map[string]string, key string) (bool, string) {
 	}
 
 	// 构建签名字符串用于调试
-	filtered := make(map[string]string)
-	for k, v := range params {
-		if v != "" && k != "sign" && k != "sign_type" {
-			filtered[k] = v
-		}
-	}
-
-	keys := make([]string, 0, len(filtered))
-	for k := range filtered {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	var parts []string
-	for _, k := range keys {
-		parts = append(parts, fmt.Sprintf("%s=%s", k, filtered[k]))
-	}
-
-	signStr := strings.Join(parts, "&")
-	signStrWithKey := signStr + key
-	expectedSign := strings.ToLower(MD5(signStrWithKey))
+	signType, _ := NormalizeSignType(params["sign_type"])
+	signStr := SignContent(params)
+	expectedSign := GenerateSign(params, key)
 
 	debugInfo := fmt.Sprintf(
 		"签名验证详情:\n"+
-			"  参与签名的参数: %v\n"+
+			"  签名类型: %s\n"+
 			"  签名字符串: %s\n"+
-			"  加上密钥后: %s\n"+
 			"  计算出的签名: %s\n"+
 			"  接收到的签名: %s\n"+
 			"  验证结果: %v",
-		filtered,
+		signType,
 		signStr,
-		signStrWithKey,
 		expectedSign,
 		receivedSign,
-		strings.EqualFold(receivedSign, expectedSign),
+		SecureCompareFold(receivedSign, expectedSign),
 	)
 
-	return strings.EqualFold(receivedSign, expectedSign), debugInfo
-}
-
-// FormatAmount 格式化金额（保留2位小数）
-func FormatAmount(amount float64) string {
-	return fmt.Sprintf("%.2f", amount)
+	return SecureCompareFold(receivedSign, expectedSign), debugInfo
 }
 
 // ParseTime 解析时间字符串
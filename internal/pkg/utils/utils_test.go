@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fuzzMerchantKey 模糊测试商户密钥
+const fuzzMerchantKey = "fuzz0123456789abcdef0123456789ab"
+
+// fuzzParams 以查询字符串解析输入（与表单、URL参数一致，非法转义被忽略）
+func fuzzParams(query string) map[string]string {
+	values, _ := url.ParseQuery(query)
+	params := make(map[string]string, len(values))
+	for k := range values {
+		params[k] = values.Get(k)
+	}
+	return params
+}
+
+// FuzzVerifySign 任意参数不panic；空签名不能通过；重新签名后（含大写签名）必须通过；错误密钥不能通过
+func FuzzVerifySign(f *testing.F) {
+	f.Add("pid=1001&type=alipay&out_trade_no=A1&name=test&money=1.00&sign=0123456789abcdef0123456789abcdef&sign_type=MD5")
+	f.Add("pid=1001&sign=&sign_type=MD5")
+	f.Add("name=%E4%BC%9A%E5%91%98&money=0.01&sign=ABCDEF")
+
+	f.Fuzz(func(t *testing.T, query string) {
+		params := fuzzParams(query)
+		VerifySign(params, fuzzMerchantKey)
+
+		params["sign"] = ""
+		if VerifySign(params, fuzzMerchantKey) {
+			t.Fatalf("empty sign accepted: %v", params)
+		}
+
+		params["sign"] = GenerateSign(params, fuzzMerchantKey)
+		if !VerifySign(params, fuzzMerchantKey) {
+			t.Fatalf("freshly signed params rejected: %v", params)
+		}
+		params["sign"] = strings.ToUpper(params["sign"])
+		if !VerifySign(params, fuzzMerchantKey) {
+			t.Fatalf("upper-case sign rejected: %v", params)
+		}
+
+		if VerifySign(params, fuzzMerchantKey+"x") {
+			t.Fatalf("sign accepted with wrong key: %v", params)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+/*
+Package router 路由注册辅助
+Author: AliMPay Team
+Description: 统一易支付/码支付兼容路由的注册方式
+
+功能:
+  - 一次注册即可同时支持 GET/POST
+  - .php 后缀由 middleware.StripExtension 在路由匹配前统一去除，无需单独注册
+  - 避免 main.go 中大量重复的路由声明
+
+使用示例:
+
+	router.RegisterCompat(engine, "/submit", submitHandler.HandleSubmit)
+*/
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// LegacyExtension 易支付PHP版本使用的路径后缀（由 middleware.StripExtension 去除）
+const LegacyExtension = ".php"
+
+// compatMethods 兼容路由支持的HTTP方法
+var compatMethods = []string{"GET", "POST"}
+
+/*
+RegisterCompat 注册兼容路由
+功能:
+  - 为 path 注册 GET/POST 两种方法
+
+参数:
+  - r: 路由器或路由组
+  - path: 路由路径（不带后缀）
+  - handlers: 处理函数链
+
+注册结果示例（path="/api/query"）:
+
+	GET  /api/query
+	POST /api/query
+*/
+func RegisterCompat(r gin.IRoutes, path string, handlers ...gin.HandlerFunc) {
+	for _, method := range compatMethods {
+		r.Handle(method, path, handlers...)
+	}
+}
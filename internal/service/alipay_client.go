@@ -9,8 +9,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand"
 	"net/http"
 	"net/url"
 	"sort"
@@ -18,6 +20,7 @@ import (
 	"time"
 
 	"alimpay-go/internal/config"
+	"alimpay-go/internal/pkg/httpclient"
 	"alimpay-go/internal/pkg/logger"
 
 	"go.uber.org/zap"
@@ -31,12 +34,26 @@ func min(a, b int) int {
 	return b
 }
 
+// 查询类（幂等）接口的重试策略：网络错误和网关5xx时按指数退避重试，避免偶发抖动计入监听暂停的失败次数
+const (
+	alipayMaxAttempts    = 3                      // 最多请求次数（含首次）
+	alipayRetryBaseDelay = 200 * time.Millisecond // 首次重试的最大等待时间
+	alipayRetryMaxDelay  = 2 * time.Second        // 单次重试的等待上限
+)
+
+// ErrAlipayResponseSignature 支付宝响应验签失败（响应被篡改，或 alipay_public_key 不是该应用对应的支付宝公钥）
+var ErrAlipayResponseSignature = errors.New("invalid alipay response signature")
+
+// billQueryMethod 账单查询接口名
+const billQueryMethod = "alipay.data.bill.accountlog.query"
+
 // AlipayClient 支付宝客户端
 type AlipayClient struct {
 	cfg        *config.AlipayConfig
 	httpClient *http.Client
 	privateKey *rsa.PrivateKey
 	publicKey  *rsa.PublicKey
+	limiter    *alipayLimiter // 按app_id共享的限流器（nil表示不限流）
 }
 
 // BillQueryRequest 账单查询请求
@@ -76,10 +93,9 @@ type BillDetail struct {
 // NewAlipayClient 创建支付宝客户端
 func NewAlipayClient(cfg *config.AlipayConfig) (*AlipayClient, error) {
 	client := &AlipayClient{
-		cfg: cfg,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		cfg:        cfg,
+		httpClient: httpclient.For(httpclient.Alipay),
+		limiter:    alipayLimiterFor(cfg),
 	}
 
 	// 解析私钥
@@ -159,10 +175,22 @@ func (c *AlipayClient) parsePublicKey() error {
 		return nil
 	}
 
-	publicKeyInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		logger.Warn("Failed to parse public key, signature verification will be unavailable", zap.Error(err))
-		return nil
+	var publicKeyInterface interface{}
+	if block.Type == "CERTIFICATE" {
+		// 公钥证书模式：使用支付宝公钥证书（alipayCertPublicKey_RSA2.crt）中的公钥
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			logger.Warn("Failed to parse public key certificate, signature verification will be unavailable", zap.Error(err))
+			return nil
+		}
+		publicKeyInterface = cert.PublicKey
+	} else {
+		var err error
+		publicKeyInterface, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			logger.Warn("Failed to parse public key, signature verification will be unavailable", zap.Error(err))
+			return nil
+		}
 	}
 
 	publicKey, ok := publicKeyInterface.(*rsa.PublicKey)
@@ -177,6 +205,10 @@ func (c *AlipayClient) parsePublicKey() error {
 
 // Sign 签名
 func (c *AlipayClient) Sign(data string) (string, error) {
+	if c.privateKey == nil {
+		return "", fmt.Errorf("alipay private key not configured")
+	}
+
 	hash := crypto.SHA256.New()
 	hash.Write([]byte(data))
 	hashed := hash.Sum(nil)
@@ -264,7 +296,7 @@ func (c *AlipayClient) QueryBills(startTime, endTime string, pageNo, pageSize in
 	bizContentJSON, _ := json.Marshal(bizContent)
 
 	// 构建请求参数
-	params := c.buildRequestParams("alipay.data.bill.accountlog.query", string(bizContentJSON))
+	params := c.buildRequestParams(billQueryMethod, string(bizContentJSON))
 
 	// 生成签名
 	sign, err := c.generateSign(params)
@@ -273,19 +305,87 @@ func (c *AlipayClient) QueryBills(startTime, endTime string, pageNo, pageSize in
 	}
 	params["sign"] = sign
 
-	// 发送请求
-	resp, err := c.doRequest(params)
+	// 发送请求（账单查询为幂等请求，可安全重试）
+	resp, err := c.doQuery(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to do request: %w", err)
 	}
 
-	// 解析响应
+	if err := c.VerifyResponse(billQueryMethod, resp); err != nil {
+		return nil, err
+	}
+
+	return ParseBillQueryResponse(resp)
+}
+
+// VerifyResponse 校验网关响应签名，拒绝被篡改的响应
+// 未配置有效的支付宝公钥或配置了 skip_response_verify 时跳过；未签名的业务错误响应（如请求验签失败）不校验，由调用方按错误码处理
+func (c *AlipayClient) VerifyResponse(method string, body []byte) error {
+	if c.cfg.SkipResponseVerify || c.publicKey == nil {
+		return nil
+	}
+
+	content, sign, err := ExtractResponseSignContent(method, body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAlipayResponseSignature, err)
+	}
+
+	if sign == "" {
+		var result struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(content, &result); err == nil && result.Code != "10000" {
+			return nil
+		}
+		return fmt.Errorf("%w: response is not signed", ErrAlipayResponseSignature)
+	}
+
+	if err := c.Verify(string(content), sign); err != nil {
+		logger.Error("Alipay response signature verification failed",
+			zap.String("method", method),
+			zap.String("app_id", c.cfg.AppID),
+			zap.Error(err))
+		return fmt.Errorf("%w: %v", ErrAlipayResponseSignature, err)
+	}
+
+	return nil
+}
+
+// ExtractResponseSignContent 提取响应中参与签名的原文和签名
+// 签名原文为 <method>_response 节点（网关错误时为 error_response）在响应中的原始JSON文本，不能重新序列化；
+// 公钥证书模式的响应额外包含 alipay_cert_sn，不参与签名
+func ExtractResponseSignContent(method string, body []byte) ([]byte, string, error) {
+	var nodes map[string]json.RawMessage
+	if err := json.Unmarshal(body, &nodes); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	content, ok := nodes[strings.ReplaceAll(method, ".", "_")+"_response"]
+	if !ok {
+		content, ok = nodes["error_response"]
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("response node not found")
+	}
+
+	var sign string
+	if raw, ok := nodes["sign"]; ok {
+		if err := json.Unmarshal(raw, &sign); err != nil {
+			return nil, "", fmt.Errorf("invalid sign: %w", err)
+		}
+	}
+
+	return content, sign, nil
+}
+
+// ParseBillQueryResponse 解析账单查询接口的响应
+func ParseBillQueryResponse(body []byte) (*BillQueryResponse, error) {
 	var response struct {
 		AlipayDataBillAccountlogQueryResponse BillQueryResponse `json:"alipay_data_bill_accountlog_query_response"`
 		Sign                                  string            `json:"sign"`
 	}
 
-	if err := json.Unmarshal(resp, &response); err != nil {
+	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
@@ -306,6 +406,49 @@ func (c *AlipayClient) QueryBills(startTime, endTime string, pageNo, pageSize in
 	return &response.AlipayDataBillAccountlogQueryResponse, nil
 }
 
+// doQuery 发送查询类请求，失败时重试
+// 只能用于幂等接口：doRequest 的错误均为网络错误或网关5xx，重试前等待随机时间（full jitter），避免多实例同时重试；
+// 每次请求（含重试）都先经过app_id限流器排队，超出配额的请求等待而不是被支付宝限流
+func (c *AlipayClient) doQuery(params map[string]string) ([]byte, error) {
+	var lastErr error
+	for attempt := 1; attempt <= alipayMaxAttempts; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(); err != nil {
+				return nil, err
+			}
+		}
+
+		body, err := c.doRequest(params)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		if attempt == alipayMaxAttempts {
+			break
+		}
+
+		delay := retryDelay(attempt)
+		logger.Warn("Alipay request failed, retrying",
+			zap.String("method", params["method"]),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+		time.Sleep(delay)
+	}
+
+	return nil, fmt.Errorf("after %d attempts: %w", alipayMaxAttempts, lastErr)
+}
+
+// retryDelay 第attempt次失败后的等待时间：[0, min(上限, 基数*2^(attempt-1))) 内随机
+func retryDelay(attempt int) time.Duration {
+	backoff := alipayRetryBaseDelay << (attempt - 1)
+	if backoff > alipayRetryMaxDelay {
+		backoff = alipayRetryMaxDelay
+	}
+	return time.Duration(mathrand.Int63n(int64(backoff)))
+}
+
 // doRequest 发送HTTP请求
 func (c *AlipayClient) doRequest(params map[string]string) ([]byte, error) {
 	// 构建请求URL
@@ -328,6 +471,11 @@ func (c *AlipayClient) doRequest(params map[string]string) ([]byte, error) {
 	}
 	defer resp.Body.Close()
 
+	// 网关故障（业务错误仍以200返回，由调用方解析）
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("alipay gateway returned status %d", resp.StatusCode)
+	}
+
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"alimpay-go/internal/model"
+)
+
+// BenchmarkParseBills 解析账单查询响应（JSON → 账单记录）
+func BenchmarkParseBills(b *testing.B) {
+	body, err := json.Marshal(map[string]interface{}{
+		"alipay_data_bill_accountlog_query_response": BillQueryResponse{
+			Code:       "10000",
+			Msg:        "Success",
+			DetailList: benchBills(benchOrder()),
+			PageNo:     "1",
+			PageSize:   fmt.Sprint(benchBillCount),
+			TotalSize:  fmt.Sprint(benchBillCount),
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := ParseBillQueryResponse(body)
+		if err != nil {
+			b.Fatal(err)
+		}
+		bills := ParseBillRecords(map[string]interface{}{
+			"success": true,
+			"data":    FormatBillData(resp),
+		})
+		if len(bills) != benchBillCount {
+			b.Fatalf("parsed %d bills, want %d", len(bills), benchBillCount)
+		}
+	}
+}
+
+// FuzzParseBillQueryResponse 任意响应不panic；解析出的账单只包含收入且金额可用于匹配
+func FuzzParseBillQueryResponse(f *testing.F) {
+	f.Add([]byte(`{"alipay_data_bill_accountlog_query_response":{"code":"10000","msg":"Success","detail_list":[` +
+		`{"account_log_id":"L1","alipay_order_no":"2026001","trans_amount":"12.30","trans_memo":"A1",` +
+		`"trans_dt":"2026-01-01 12:00:00","direction":"收入"},` +
+		`{"account_log_id":"L2","alipay_order_no":"2026002","trans_amount":"-1.00","direction":"支出"}],` +
+		`"page_no":"1","page_size":"2000","total_size":"2"},"sign":"x"}`))
+	f.Add([]byte(`{"alipay_data_bill_accountlog_query_response":{"code":"40004","msg":"Business Failed","sub_code":"isv.invalid"}}`))
+	f.Add([]byte(`{}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		resp, err := ParseBillQueryResponse(body)
+		if err != nil {
+			return
+		}
+
+		bills := ParseBillRecords(map[string]interface{}{
+			"success": true,
+			"data":    FormatBillData(resp),
+		})
+		for _, bill := range bills {
+			if bill.Direction != "收入" {
+				t.Fatalf("non-income bill parsed: %+v", bill)
+			}
+			if roundTrip, err := model.ParseAmount(bill.Amount.String()); err != nil || roundTrip != bill.Amount {
+				t.Fatalf("bill amount does not round-trip: %+v", bill)
+			}
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrAlipayRateLimited 请求排队超过等待上限，未发送到支付宝
+var ErrAlipayRateLimited = errors.New("alipay rate limit exceeded, request not sent")
+
+// alipayLimiters 按app_id共享的限流器
+// 多个二维码使用同一套凭据时，即使各自创建了客户端也共用同一份配额
+var alipayLimiters = struct {
+	mu       sync.Mutex
+	limiters map[string]*alipayLimiter
+}{limiters: make(map[string]*alipayLimiter)}
+
+// alipayLimiter 令牌桶限流器
+// @description 令牌不足时不直接拒绝，而是为请求预留未来的令牌并排队等待，
+// 按到达顺序以配置的速率依次发出；预计等待超过上限的请求才会被拒绝
+type alipayLimiter struct {
+	mu       sync.Mutex
+	appID    string
+	rate     float64       // 每秒生成的令牌数
+	burst    float64       // 令牌桶容量
+	tokens   float64       // 当前令牌数（为负表示已被排队请求预留）
+	last     time.Time     // 上次计算令牌的时间
+	maxWait  time.Duration // 排队等待上限
+	queued   int           // 正在排队的请求数
+	rejected int64         // 因等待超限被拒绝的请求数
+}
+
+// alipayLimiterFor 获取app_id对应的限流器（不存在则按配置创建）
+// @param cfg 支付宝配置
+// @return *alipayLimiter 限流器，未配置app_id或QPS不为正数时返回nil（不限流）
+func alipayLimiterFor(cfg *config.AlipayConfig) *alipayLimiter {
+	if cfg.AppID == "" || cfg.QPS <= 0 {
+		return nil
+	}
+
+	alipayLimiters.mu.Lock()
+	defer alipayLimiters.mu.Unlock()
+
+	if limiter, ok := alipayLimiters.limiters[cfg.AppID]; ok {
+		// 同一app_id以首次创建时的配置为准
+		if limiter.rate != cfg.QPS || limiter.burst != float64(cfg.Burst) {
+			logger.Warn("Conflicting rate limit settings for shared Alipay app_id, keeping the first",
+				zap.String("app_id", cfg.AppID),
+				zap.Float64("qps", limiter.rate),
+				zap.Float64("burst", limiter.burst))
+		}
+		return limiter
+	}
+
+	limiter := newAlipayLimiter(cfg.AppID, cfg.QPS, cfg.Burst, time.Duration(cfg.QueueTimeout)*time.Second)
+	alipayLimiters.limiters[cfg.AppID] = limiter
+
+	logger.Info("Alipay rate limiter configured",
+		zap.String("app_id", cfg.AppID),
+		zap.Float64("qps", cfg.QPS),
+		zap.Int("burst", cfg.Burst),
+		zap.Duration("max_wait", limiter.maxWait))
+
+	return limiter
+}
+
+// newAlipayLimiter 创建令牌桶限流器（桶初始为满）
+func newAlipayLimiter(appID string, rate float64, burst int, maxWait time.Duration) *alipayLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &alipayLimiter{
+		appID:   appID,
+		rate:    rate,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+		maxWait: maxWait,
+	}
+}
+
+// Wait 等待直到允许发送一个请求
+// @return error 预计等待时间超过上限时返回 ErrAlipayRateLimited（不占用配额）
+func (l *alipayLimiter) Wait() error {
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	var delay time.Duration
+	if l.tokens < 1 {
+		delay = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		if delay > l.maxWait {
+			l.rejected++
+			queued := l.queued
+			l.mu.Unlock()
+
+			logger.Warn("Alipay request rejected by rate limiter",
+				zap.String("app_id", l.appID),
+				zap.Duration("expected_wait", delay),
+				zap.Int("queued", queued))
+			return ErrAlipayRateLimited
+		}
+	}
+
+	// 预留令牌，后到的请求排在其后
+	l.tokens--
+	if delay > 0 {
+		l.queued++
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	logger.Debug("Alipay request queued by rate limiter",
+		zap.String("app_id", l.appID),
+		zap.Duration("wait", delay))
+	time.Sleep(delay)
+
+	l.mu.Lock()
+	l.queued--
+	l.mu.Unlock()
+	return nil
+}
+
+// Stats 限流器状态
+func (l *alipayLimiter) Stats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return map[string]interface{}{
+		"qps":      l.rate,
+		"burst":    int(l.burst),
+		"queued":   l.queued,
+		"rejected": l.rejected,
+	}
+}
+
+// AlipayRateLimitStats 获取各app_id限流器的状态
+// @return map[string]interface{} app_id（脱敏）到状态的映射
+func AlipayRateLimitStats() map[string]interface{} {
+	alipayLimiters.mu.Lock()
+	defer alipayLimiters.mu.Unlock()
+
+	stats := make(map[string]interface{}, len(alipayLimiters.limiters))
+	for appID, limiter := range alipayLimiters.limiters {
+		stats[maskAppID(appID)] = limiter.Stats()
+	}
+	return stats
+}
+
+// maskAppID 脱敏app_id（只显示前10位，与请求日志一致）
+func maskAppID(appID string) string {
+	return appID[:min(len(appID), 10)] + "..."
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 交易接口
+const (
+	tradePrecreateMethod = "alipay.trade.precreate"
+	tradeQueryMethod     = "alipay.trade.query"
+	tradeWapPayMethod    = "alipay.trade.wap.pay"
+)
+
+// wapProductCode 手机网站支付的销售产品码
+const wapProductCode = "QUICK_WAP_WAY"
+
+// 支付宝交易状态
+const (
+	TradeStatusWaitBuyerPay = "WAIT_BUYER_PAY" // 交易创建，等待买家付款
+	TradeStatusClosed       = "TRADE_CLOSED"   // 未付款交易超时关闭，或支付完成后全额退款
+	TradeStatusSuccess      = "TRADE_SUCCESS"  // 交易支付成功
+	TradeStatusFinished     = "TRADE_FINISHED" // 交易结束，不可退款
+)
+
+// ErrTradeNotExist 支付宝交易不存在（当面付订单的用户尚未扫码）
+var ErrTradeNotExist = errors.New("alipay trade not exist")
+
+// TradePrecreateResponse 当面付预下单响应
+type TradePrecreateResponse struct {
+	Code       string `json:"code"`
+	Msg        string `json:"msg"`
+	SubCode    string `json:"sub_code"`
+	SubMsg     string `json:"sub_msg"`
+	OutTradeNo string `json:"out_trade_no"`
+	QRCode     string `json:"qr_code"` // 二维码内容（https://qr.alipay.com/...）
+}
+
+// TradeQueryResponse 交易查询响应
+type TradeQueryResponse struct {
+	Code         string `json:"code"`
+	Msg          string `json:"msg"`
+	SubCode      string `json:"sub_code"`
+	SubMsg       string `json:"sub_msg"`
+	TradeNo      string `json:"trade_no"`       // 支付宝交易号
+	OutTradeNo   string `json:"out_trade_no"`   // 商户订单号（本系统的交易号）
+	TradeStatus  string `json:"trade_status"`   // 交易状态
+	TotalAmount  string `json:"total_amount"`   // 订单金额（元）
+	BuyerLogonID string `json:"buyer_logon_id"` // 买家支付宝账号（脱敏）
+	SendPayDate  string `json:"send_pay_date"`  // 支付时间
+}
+
+// IsTradePaid 交易状态是否表示已支付
+func IsTradePaid(status string) bool {
+	return status == TradeStatusSuccess || status == TradeStatusFinished
+}
+
+// TradePrecreate 当面付预下单，返回二维码内容
+// 支付宝对相同 out_trade_no 和金额的重复预下单返回同一个二维码，因此可以安全重试
+// @param outTradeNo 支付宝侧的商户订单号（使用本系统交易号）
+// @param amount 支付金额
+// @param subject 订单标题
+// @param notifyURL 支付宝异步通知地址
+// @param timeout 未支付交易的关闭时间
+// @return string 二维码内容
+func (c *AlipayClient) TradePrecreate(outTradeNo string, amount model.Amount, subject, notifyURL string, timeout time.Duration) (string, error) {
+	bizContent := map[string]interface{}{
+		"out_trade_no":    outTradeNo,
+		"total_amount":    amount.String(),
+		"subject":         subject,
+		"timeout_express": timeoutExpress(timeout),
+	}
+
+	var resp TradePrecreateResponse
+	if err := c.execute(tradePrecreateMethod, bizContent, map[string]string{"notify_url": notifyURL}, &resp); err != nil {
+		return "", err
+	}
+	if resp.Code != "10000" {
+		return "", fmt.Errorf("alipay precreate failed: %s %s (%s %s)", resp.Code, resp.Msg, resp.SubCode, resp.SubMsg)
+	}
+	if resp.QRCode == "" {
+		return "", fmt.Errorf("alipay precreate returned empty qr_code")
+	}
+
+	logger.Info("Alipay trade precreated",
+		zap.String("out_trade_no", outTradeNo),
+		zap.Stringer("amount", amount))
+
+	return resp.QRCode, nil
+}
+
+// TradeWapPayURL 生成手机网站支付的收银台地址
+// 该接口由用户浏览器直接访问支付宝网关，只在本地签名生成跳转地址，不请求支付宝；
+// 用户在收银台付款后才产生交易，之后与当面付一样通过异步通知和交易查询确认
+// @param outTradeNo 支付宝侧的商户订单号（使用本系统交易号）
+// @param amount 支付金额
+// @param subject 订单标题
+// @param notifyURL 支付宝异步通知地址
+// @param returnURL 付款完成后浏览器跳转的地址
+// @param quitURL 用户中途退出收银台时跳转的地址
+// @param timeout 未支付交易的关闭时间
+// @return string 收银台地址（GET 请求支付宝网关）
+func (c *AlipayClient) TradeWapPayURL(outTradeNo string, amount model.Amount, subject, notifyURL, returnURL, quitURL string, timeout time.Duration) (string, error) {
+	bizContent, err := json.Marshal(map[string]interface{}{
+		"out_trade_no":    outTradeNo,
+		"total_amount":    amount.String(),
+		"subject":         subject,
+		"product_code":    wapProductCode,
+		"quit_url":        quitURL,
+		"timeout_express": timeoutExpress(timeout),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal biz_content: %w", err)
+	}
+
+	params := c.buildRequestParams(tradeWapPayMethod, string(bizContent))
+	params["notify_url"] = notifyURL
+	params["return_url"] = returnURL
+
+	sign, err := c.generateSign(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate sign: %w", err)
+	}
+	params["sign"] = sign
+
+	query := url.Values{}
+	for k, v := range params {
+		if v != "" {
+			query.Set(k, v)
+		}
+	}
+
+	separator := "?"
+	if strings.Contains(c.cfg.ServerURL, "?") {
+		separator = "&"
+	}
+	return c.cfg.ServerURL + separator + query.Encode(), nil
+}
+
+// timeoutExpress 将关闭时间换算为支付宝的 timeout_express（分钟，向上取整，至少1分钟）
+func timeoutExpress(timeout time.Duration) string {
+	minutes := int((timeout + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// TradeQuery 按商户订单号查询交易
+// @param outTradeNo 预下单时使用的商户订单号
+// @return *TradeQueryResponse 交易信息
+// @return error 用户尚未扫码时返回 ErrTradeNotExist
+func (c *AlipayClient) TradeQuery(outTradeNo string) (*TradeQueryResponse, error) {
+	var resp TradeQueryResponse
+	if err := c.execute(tradeQueryMethod, map[string]interface{}{"out_trade_no": outTradeNo}, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.SubCode == "ACQ.TRADE_NOT_EXIST" {
+		return nil, ErrTradeNotExist
+	}
+	if resp.Code != "10000" {
+		return nil, fmt.Errorf("alipay trade query failed: %s %s (%s %s)", resp.Code, resp.Msg, resp.SubCode, resp.SubMsg)
+	}
+	return &resp, nil
+}
+
+// execute 调用幂等的开放平台接口：签名、限流排队并重试、校验响应签名，解析响应节点到 result
+func (c *AlipayClient) execute(method string, bizContent map[string]interface{}, extra map[string]string, result interface{}) error {
+	bizContentJSON, err := json.Marshal(bizContent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal biz_content: %w", err)
+	}
+
+	params := c.buildRequestParams(method, string(bizContentJSON))
+	for k, v := range extra {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	sign, err := c.generateSign(params)
+	if err != nil {
+		return fmt.Errorf("failed to generate sign: %w", err)
+	}
+	params["sign"] = sign
+
+	body, err := c.doQuery(params)
+	if err != nil {
+		return fmt.Errorf("failed to do request: %w", err)
+	}
+
+	if err := c.VerifyResponse(method, body); err != nil {
+		return err
+	}
+
+	content, _, err := ExtractResponseSignContent(method, body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", method, err)
+	}
+	return nil
+}
+
+// VerifyNotify 校验支付宝异步通知签名
+// 除 sign、sign_type 外的非空参数按参数名排序后以 key=value 用 & 连接作为签名原文；
+// 通知会触发订单支付，未配置支付宝公钥时一律拒绝
+func (c *AlipayClient) VerifyNotify(params map[string]string) error {
+	if c.publicKey == nil {
+		return fmt.Errorf("%w: alipay public key not configured", ErrAlipayResponseSignature)
+	}
+	if params["app_id"] != c.cfg.AppID {
+		return fmt.Errorf("notify app_id mismatch: %s", params["app_id"])
+	}
+
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k != "sign" && k != "sign_type" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			content.WriteString("&")
+		}
+		content.WriteString(k)
+		content.WriteString("=")
+		content.WriteString(params[k])
+	}
+
+	if err := c.Verify(content.String(), params["sign"]); err != nil {
+		return fmt.Errorf("%w: %v", ErrAlipayResponseSignature, err)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"alimpay-go/internal/config"
+	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
 
 	"go.uber.org/zap"
@@ -24,7 +25,7 @@ func NewAlipayTransfer(cfg *config.AlipayConfig) *AlipayTransfer {
 }
 
 // GenerateTransferURL 生成转账URL
-func (at *AlipayTransfer) GenerateTransferURL(amount float64, memo, userID string) string {
+func (at *AlipayTransfer) GenerateTransferURL(amount model.Amount, memo, userID string) string {
 	// 如果未指定userID，使用配置中的默认值
 	if userID == "" {
 		userID = at.cfg.TransferUserID
@@ -41,19 +42,19 @@ func (at *AlipayTransfer) GenerateTransferURL(amount float64, memo, userID strin
 }
 
 // generateSimpleURL 生成简单转账URL
-func (at *AlipayTransfer) generateSimpleURL(amount float64, memo, userID string) string {
+func (at *AlipayTransfer) generateSimpleURL(amount model.Amount, memo, userID string) string {
 	params := url.Values{}
 	params.Set("appId", "09999988")
 	params.Set("actionType", "toAccount")
 	params.Set("goBack", "NO")
-	params.Set("amount", fmt.Sprintf("%.2f", amount))
+	params.Set("amount", amount.String())
 	params.Set("userId", userID)
 	params.Set("memo", memo)
 
 	transferURL := fmt.Sprintf("alipays://platformapi/startapp?%s", params.Encode())
 
 	logger.Info("Generated simple transfer URL",
-		zap.Float64("amount", amount),
+		zap.Stringer("amount", amount),
 		zap.String("memo", memo),
 		zap.String("user_id", userID))
 
@@ -61,13 +62,13 @@ func (at *AlipayTransfer) generateSimpleURL(amount float64, memo, userID string)
 }
 
 // generateAntiRiskURL 生成防风控转账URL（多层嵌套）
-func (at *AlipayTransfer) generateAntiRiskURL(amount float64, memo, userID string, cfg *config.AntiRiskURLConfig) string {
+func (at *AlipayTransfer) generateAntiRiskURL(amount model.Amount, memo, userID string, cfg *config.AntiRiskURLConfig) string {
 	// 第1层：最内层转账URL
 	innerParams := url.Values{}
 	innerParams.Set("appId", cfg.InnerAppID)
 	innerParams.Set("actionType", "toAccount")
 	innerParams.Set("goBack", "NO")
-	innerParams.Set("amount", fmt.Sprintf("%.2f", amount))
+	innerParams.Set("amount", amount.String())
 	innerParams.Set("userId", userID)
 	innerParams.Set("memo", memo)
 
@@ -90,7 +91,7 @@ func (at *AlipayTransfer) generateAntiRiskURL(amount float64, memo, userID strin
 	finalURL := fmt.Sprintf("%s?scheme=%s", cfg.MdeductLandingURL, url.QueryEscape(layer4URL))
 
 	logger.Info("Generated anti-risk transfer URL",
-		zap.Float64("amount", amount),
+		zap.Stringer("amount", amount),
 		zap.String("memo", memo),
 		zap.String("user_id", userID),
 		zap.String("outer_app_id", cfg.OuterAppID),
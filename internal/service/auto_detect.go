@@ -98,7 +98,7 @@ func (s *AutoDetectService) checkPendingOrders() {
 	for _, order := range orders {
 		// 检查订单是否超时
 		orderAge := time.Since(order.AddTime)
-		if orderAge > time.Duration(s.codepay.cfg.Payment.OrderTimeout)*time.Second {
+		if !order.KeepOpen && orderAge > time.Duration(s.codepay.cfg.Payment.OrderTimeout)*time.Second {
 			logger.Info("Order timeout, will be cleaned up",
 				zap.String("order_id", order.ID),
 				zap.String("out_trade_no", order.OutTradeNo),
@@ -135,8 +135,7 @@ func (s *AutoDetectService) MarkOrderPaidManually(outTradeNo, pid string) error
 	}
 
 	// 更新订单状态
-	payTime := time.Now()
-	if err := s.db.UpdateOrderStatus(order.ID, model.OrderStatusPaid, payTime); err != nil {
+	if err := s.codepay.OrderStates().MarkPaid(order, model.OrderEventMarkedPaid, "手动标记已支付"); err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
@@ -145,13 +144,7 @@ func (s *AutoDetectService) MarkOrderPaidManually(outTradeNo, pid string) error
 		zap.String("out_trade_no", order.OutTradeNo))
 
 	// 发送通知
-	go func() {
-		if err := s.codepay.SendNotification(order); err != nil {
-			logger.Error("Failed to send notification",
-				zap.String("order_id", order.ID),
-				zap.Error(err))
-		}
-	}()
+	_ = s.codepay.QueueNotification(order)
 
 	return nil
 }
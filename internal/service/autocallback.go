@@ -16,6 +16,7 @@ type AutoCallbackService struct {
 	db      *database.DB
 	codepay *CodePayService
 	stopCh  chan struct{}
+	leader  *LeaderElector // 主节点选举（未启用时为nil）
 }
 
 // NewAutoCallbackService 创建自动回调服务
@@ -27,6 +28,11 @@ func NewAutoCallbackService(db *database.DB, codepay *CodePayService) *AutoCallb
 	}
 }
 
+// SetLeaderElector 设置主节点选举（多实例部署时只在主节点发送自动回调，避免重复通知）
+func (s *AutoCallbackService) SetLeaderElector(leader *LeaderElector) {
+	s.leader = leader
+}
+
 // Start 启动自动回调服务
 func (s *AutoCallbackService) Start() {
 	go s.run()
@@ -47,7 +53,9 @@ func (s *AutoCallbackService) run() {
 	for {
 		select {
 		case <-ticker.C:
-			s.processAutoCallback()
+			if s.leader.IsLeader() {
+				s.processAutoCallback()
+			}
 		case <-s.stopCh:
 			return
 		}
@@ -64,26 +72,20 @@ func (s *AutoCallbackService) processAutoCallback() {
 	}
 
 	for _, order := range orders {
-		// 只处理已支付的订单
-		if order.Status == model.OrderStatusPaid && order.NotifyURL != "" {
+		// 只处理已支付的订单（争议订单冻结通知）
+		if order.Status == model.OrderStatusPaid && order.NotifyURL != "" && !order.Disputed {
 			// 检查是否已发送过回调（简单检查：支付时间距现在超过10秒）
 			if order.PayTime != nil && time.Since(*order.PayTime) < 10*time.Second {
-				// 发送商户回调
-				go func(o *model.Order) {
-					logger.Info("Auto callback triggered",
-						zap.String("trade_no", o.ID),
-						zap.String("out_trade_no", o.OutTradeNo))
+				// 发送商户回调（通知Worker池，慢速商户地址不影响账单匹配）
+				logger.Info("Auto callback triggered",
+					zap.String("trade_no", order.ID),
+					zap.String("out_trade_no", order.OutTradeNo))
 
-					err := s.codepay.SendNotification(o)
-					if err != nil {
-						logger.Error("Auto callback failed",
-							zap.String("trade_no", o.ID),
-							zap.Error(err))
-					} else {
-						logger.Info("Auto callback sent",
-							zap.String("trade_no", o.ID))
-					}
-				}(order)
+				if err := s.codepay.QueueNotification(order); err != nil {
+					logger.Error("Auto callback failed",
+						zap.String("trade_no", order.ID),
+						zap.Error(err))
+				}
 			}
 		}
 	}
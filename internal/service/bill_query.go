@@ -2,14 +2,22 @@ package service
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"alimpay-go/internal/config"
+	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
 
 	"go.uber.org/zap"
 )
 
+// recentBillsPageSize 监听查询账单的每页条数
+const recentBillsPageSize = 100
+
+// maxBillPages 单次查询最多翻页数（防止 total_size 异常时无限翻页）
+const maxBillPages = 50
+
 // BillQueryService 账单查询服务
 type BillQueryService struct {
 	alipayClient *AlipayClient
@@ -65,7 +73,7 @@ func (s *BillQueryService) QueryBills(startTime, endTime string, pageNo, pageSiz
 	// 格式化返回结果
 	result := map[string]interface{}{
 		"success":   true,
-		"data":      s.formatBillData(resp),
+		"data":      FormatBillData(resp),
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
 	}
 
@@ -110,12 +118,72 @@ func (s *BillQueryService) QueryRecentBills(hoursBack int) (map[string]interface
 		zap.Int("查询时长(小时)", hoursBack),
 		zap.String("查询范围说明", fmt.Sprintf("过去%d小时的支付记录", hoursBack)))
 
-	return s.QueryBills(startTime, endTime, 1, 100)
+	return s.QueryAllBills(startTime, endTime, recentBillsPageSize)
 }
 
 // QueryBillsInTimeRange 查询指定时间范围的账单
 func (s *BillQueryService) QueryBillsInTimeRange(startTime, endTime string) (map[string]interface{}, error) {
-	return s.QueryBills(startTime, endTime, 1, 100)
+	return s.QueryAllBills(startTime, endTime, recentBillsPageSize)
+}
+
+// QueryAllBills 查询时间范围内的全部账单
+// 按 total_size 逐页查询并合并（最多 maxBillPages 页），任一页失败则返回错误，避免以不完整的账单匹配订单
+func (s *BillQueryService) QueryAllBills(startTime, endTime string, pageSize int) (map[string]interface{}, error) {
+	if pageSize < 1 || pageSize > 2000 {
+		pageSize = 2000
+	}
+
+	// 验证时间格式
+	if err := s.validateTimeFormat(startTime); err != nil {
+		return nil, fmt.Errorf("invalid start_time: %w", err)
+	}
+	if err := s.validateTimeFormat(endTime); err != nil {
+		return nil, fmt.Errorf("invalid end_time: %w", err)
+	}
+
+	var merged *BillQueryResponse
+	for pageNo := 1; ; pageNo++ {
+		resp, err := s.alipayClient.QueryBills(startTime, endTime, pageNo, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query bills page %d: %w", pageNo, err)
+		}
+
+		if merged == nil {
+			merged = resp
+		} else {
+			merged.DetailList = append(merged.DetailList, resp.DetailList...)
+		}
+
+		total, _ := strconv.Atoi(resp.TotalSize)
+		if len(resp.DetailList) < pageSize || len(merged.DetailList) >= total {
+			break
+		}
+		if pageNo >= maxBillPages {
+			logger.Warn("Bill query reached page limit, remaining bills skipped",
+				zap.String("start_time", startTime),
+				zap.String("end_time", endTime),
+				zap.Int("pages", pageNo),
+				zap.Int("fetched", len(merged.DetailList)),
+				zap.Int("total_size", total))
+			break
+		}
+	}
+
+	if pages := (len(merged.DetailList) + pageSize - 1) / pageSize; pages > 1 {
+		logger.Info("Fetched multiple bill pages",
+			zap.Int("pages", pages),
+			zap.Int("bill_count", len(merged.DetailList)))
+	}
+
+	// 合并后作为一页返回
+	merged.PageNo = "1"
+	merged.PageSize = strconv.Itoa(len(merged.DetailList))
+
+	return map[string]interface{}{
+		"success":   true,
+		"data":      FormatBillData(merged),
+		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+	}, nil
 }
 
 // validateTimeFormat 验证时间格式
@@ -124,8 +192,8 @@ func (s *BillQueryService) validateTimeFormat(timeStr string) error {
 	return err
 }
 
-// formatBillData 格式化账单数据
-func (s *BillQueryService) formatBillData(resp *BillQueryResponse) map[string]interface{} {
+// FormatBillData 格式化账单数据（QueryBills 返回结果中的 data）
+func FormatBillData(resp *BillQueryResponse) map[string]interface{} {
 	// 转换详细列表
 	detailList := make([]map[string]interface{}, 0, len(resp.DetailList))
 	for _, detail := range resp.DetailList {
@@ -152,7 +220,7 @@ func (s *BillQueryService) formatBillData(resp *BillQueryResponse) map[string]in
 }
 
 // FindPaymentByMemo 根据备注查找支付记录
-func (s *BillQueryService) FindPaymentByMemo(billData map[string]interface{}, orderNo string, expectedAmount float64) map[string]interface{} {
+func (s *BillQueryService) FindPaymentByMemo(billData map[string]interface{}, orderNo string, expectedAmount model.Amount) map[string]interface{} {
 	// 提取账单列表
 	detailList, ok := billData["detail_list"].([]map[string]interface{})
 	if !ok {
@@ -177,27 +245,27 @@ func (s *BillQueryService) FindPaymentByMemo(billData map[string]interface{}, or
 		}
 
 		// 解析金额
-		var amount float64
-		if _, err := fmt.Sscanf(amountStr, "%f", &amount); err != nil {
+		amount, err := model.ParseAmount(amountStr)
+		if err != nil {
 			logger.Warn("Failed to parse amount",
 				zap.String("amount_str", amountStr),
 				zap.Error(err))
 			continue
 		}
 
-		// 匹配金额（允许0.01的误差）
-		if amount < expectedAmount-0.01 || amount > expectedAmount+0.01 {
+		// 匹配金额（整数分精确比较）
+		if amount != expectedAmount {
 			logger.Debug("Order matched but amount mismatch",
 				zap.String("order_no", orderNo),
-				zap.Float64("expected", expectedAmount),
-				zap.Float64("actual", amount))
+				zap.Stringer("expected", expectedAmount),
+				zap.Stringer("actual", amount))
 			continue
 		}
 
 		// 找到匹配的支付记录
 		logger.Info("Payment match found",
 			zap.String("order_no", orderNo),
-			zap.Float64("amount", amount))
+			zap.Stringer("amount", amount))
 
 		return bill
 	}
@@ -0,0 +1,105 @@
+// Package service 买家IP归属地
+// @author AliMPay Team
+// @description 下单时记录买家IP及归属地（离线IP库，见 geoip 配置），并按 geoip.rules 拒绝指定地区买家的订单，
+// 例如只允许中国大陆买家购买的商品
+package service
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/geoip"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrBuyerRegionBlocked 买家IP归属地被风控规则拒绝（错误信息直接返回给商户）
+var ErrBuyerRegionBlocked = errors.New("payment is not available in the buyer's region")
+
+// checkBuyerRegion 按风控规则检查买家IP归属地
+// @description 未启用IP库、未提供买家IP或买家为局域网地址时不检查
+// @param clientIP 买家IP
+// @param name 商品名称（规则按关键词匹配商品）
+// @return geoip.Location 归属地（查询不到时为零值）
+// @return error 被规则拒绝时返回 ErrBuyerRegionBlocked
+func (s *CodePayService) checkBuyerRegion(clientIP, name string) (geoip.Location, error) {
+	if clientIP == "" || !geoip.Enabled() {
+		return geoip.Location{}, nil
+	}
+
+	location, found := geoip.Lookup(clientIP)
+	if found && location.Country == geoip.CountryLAN {
+		return location, nil
+	}
+
+	for _, rule := range s.cfg.GeoIP.Rules {
+		if !geoRuleApplies(rule, name) {
+			continue
+		}
+
+		blocked := !found && rule.BlockUnknown
+		if found {
+			blocked = (len(rule.AllowedCountries) > 0 && !containsCountry(rule.AllowedCountries, location.Country)) ||
+				containsCountry(rule.BlockedCountries, location.Country)
+		}
+		if blocked {
+			logger.Warn("Order blocked by geoip rule",
+				zap.String("rule", rule.Name),
+				zap.String("ip", clientIP),
+				zap.String("location", location.String()),
+				zap.String("name", name))
+			return location, ErrBuyerRegionBlocked
+		}
+	}
+
+	return location, nil
+}
+
+// geoRuleApplies 规则是否适用于该商品（未配置关键词时适用于全部订单）
+func geoRuleApplies(rule config.GeoIPRule, name string) bool {
+	if len(rule.NameKeywords) == 0 {
+		return true
+	}
+	for _, keyword := range rule.NameKeywords {
+		if keyword != "" && strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsCountry 国家代码是否在列表中（不区分大小写）
+func containsCountry(codes []string, country string) bool {
+	for _, code := range codes {
+		if strings.EqualFold(code, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeClientIP 校验买家IP，格式错误时忽略（商户传入的 clientip 不可信）
+func normalizeClientIP(clientIP string) string {
+	ip := net.ParseIP(strings.TrimSpace(clientIP))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// saveOrderClient 记录订单的买家IP及归属地（失败不影响下单）
+func (s *CodePayService) saveOrderClient(orderID, clientIP string, location geoip.Location) {
+	err := s.db.SaveOrderClient(&model.OrderClient{
+		OrderID:  orderID,
+		IP:       clientIP,
+		Country:  location.Country,
+		Province: location.Province,
+	})
+	if err != nil {
+		logger.Warn("Failed to save order client ip", zap.String("trade_no", orderID), zap.Error(err))
+	}
+}
@@ -1,21 +1,28 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
 	"alimpay-go/internal/events"
 	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/httpclient"
+	"alimpay-go/internal/pkg/i18n"
 	"alimpay-go/internal/pkg/lock"
 	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/money"
 	"alimpay-go/internal/pkg/qrcode"
 	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/worker"
 
 	"go.uber.org/zap"
 )
@@ -30,6 +37,20 @@ type CodePayService struct {
 	alipayClient *AlipayClient
 	merchantKey  string
 	qrSelector   *QRCodeSelector
+	notifyPool   *worker.Pool       // 商户通知Worker池
+	states       *OrderStateMachine // 订单状态机
+	printedMu    sync.Mutex         // 串行化线下收款码扫码（见 ResolvePrintedCode）
+
+	// 商户密钥轮换（见 merchant_key.go）
+	previousKey       string    // 过渡期内仍可验证签名的旧密钥
+	previousKeyExpiry time.Time // 旧密钥过渡期截止时间
+	keyMu             sync.RWMutex
+
+	// 沙箱商户（见 sandbox.go）
+	sandboxID    string               // 沙箱商户ID（未签发时为空）
+	sandboxKey   string               // 沙箱商户密钥
+	sandboxBills *SyntheticBillSource // 沙箱订单的模拟账单
+	sandboxMu    sync.RWMutex
 }
 
 // NewCodePayService 创建码支付服务
@@ -40,10 +61,10 @@ func NewCodePayService(cfg *config.Config, db *database.DB) (*CodePayService, er
 		return nil, fmt.Errorf("failed to create alipay client: %w", err)
 	}
 
-	// 创建二维码选择器（仅在多二维码模式下）
+	// 创建二维码选择器（经营码模式下，二维码可在运行时通过管理后台调整）
 	var qrSelector *QRCodeSelector
-	if cfg.Payment.BusinessQRMode.Enabled && len(cfg.Payment.BusinessQRMode.QRCodePaths) > 1 {
-		qrSelector = NewQRCodeSelector(cfg)
+	if cfg.Payment.BusinessQRMode.Enabled {
+		qrSelector = NewQRCodeSelector(cfg, db)
 	}
 
 	service := &CodePayService{
@@ -53,16 +74,28 @@ func NewCodePayService(cfg *config.Config, db *database.DB) (*CodePayService, er
 		qrGenerator:  qrcode.NewGenerator(cfg.Payment.QRCodeSize, cfg.Payment.QRCodeMargin),
 		alipayClient: alipayClient,
 		qrSelector:   qrSelector,
+		notifyPool:   worker.NewPool(cfg.Monitor.NotifyWorkerCount, cfg.Monitor.NotifyQueueSize),
+		states:       NewOrderStateMachine(db),
 	}
+	service.notifyPool.SetDrainTimeout(cfg.Monitor.DrainTimeoutDuration())
+	service.notifyPool.Start()
 
 	// 初始化商户信息
 	if err := service.initMerchant(); err != nil {
 		return nil, err
 	}
+	if err := service.initSandbox(); err != nil {
+		return nil, err
+	}
 
 	return service, nil
 }
 
+// OrderStates 获取订单状态机（订单状态变更均须经过状态机）
+func (s *CodePayService) OrderStates() *OrderStateMachine {
+	return s.states
+}
+
 // initMerchant 初始化商户信息
 func (s *CodePayService) initMerchant() error {
 	if s.cfg.Merchant.ID != "" && s.cfg.Merchant.Key != "" {
@@ -70,7 +103,7 @@ func (s *CodePayService) initMerchant() error {
 		s.merchantKey = s.cfg.Merchant.Key
 		logger.Info("Loaded merchant configuration",
 			zap.String("merchant_id", s.merchantID))
-		return nil
+		return s.loadRotatedMerchantKey()
 	}
 
 	// 生成新的商户信息
@@ -98,20 +131,31 @@ func (s *CodePayService) initMerchant() error {
 func (s *CodePayService) GetMerchantInfo() map[string]interface{} {
 	return map[string]interface{}{
 		"id":   s.merchantID,
-		"key":  s.merchantKey,
+		"key":  s.GetMerchantKey(),
 		"rate": s.cfg.Merchant.Rate,
 	}
 }
 
 // CreatePayment 创建支付订单
-func (s *CodePayService) CreatePayment(params map[string]string, baseURL string) (map[string]interface{}, error) {
+func (s *CodePayService) CreatePayment(params map[string]string, baseURL, clientIP string) (map[string]interface{}, error) {
 	// 验证参数
 	if err := s.validatePaymentParams(params); err != nil {
 		return nil, err
 	}
 
-	// 验证签名（使用调试版本获取详细信息）
-	isValid, debugInfo := utils.VerifySignDebug(params, s.merchantKey)
+	// 验证签名（使用调试版本获取详细信息，沙箱商户使用沙箱密钥）
+	if err := s.checkSignType(params["sign_type"]); err != nil {
+		logger.Warn("Signature type rejected",
+			zap.String("pid", params["pid"]),
+			zap.String("out_trade_no", params["out_trade_no"]),
+			zap.Error(err))
+		return nil, err
+	}
+	key, _ := s.merchantKeyFor(params["pid"])
+	isValid, debugInfo := utils.VerifySignDebug(params, key)
+	if !isValid && s.acceptPreviousKey(params["pid"], func(key string) bool { return utils.VerifySign(params, key) }) {
+		isValid = true
+	}
 	if !isValid {
 		logger.Error("Signature verification failed",
 			zap.String("pid", params["pid"]),
@@ -126,7 +170,67 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 		zap.String("out_trade_no", params["out_trade_no"]),
 		zap.String("debug_info", debugInfo))
 
-	// 检查订单是否已存在（防止重复提交）
+	return s.createPayment(params, baseURL, clientIP)
+}
+
+// CreateManualPayment 管理后台手动创建订单（线下收款，不经过商户网站）
+// @param name 商品名称
+// @param money 金额（元）
+// @param notifyURL 异步通知地址，可为空
+// @param baseURL 支付页面基础URL
+// @return map[string]interface{} 与 CreatePayment 相同的下单结果（含支付链接和二维码）
+func (s *CodePayService) CreateManualPayment(name, money, notifyURL, baseURL string) (map[string]interface{}, error) {
+	if name == "" || money == "" {
+		return nil, fmt.Errorf("missing required parameter: name or money")
+	}
+
+	params := map[string]string{
+		"pid":          s.merchantID,
+		"type":         model.PaymentTypeAlipay,
+		"out_trade_no": manualOutTradeNoPrefix + utils.GenerateTradeNo(),
+		"name":         name,
+		"money":        money,
+		"notify_url":   notifyURL,
+		"sitename":     "管理后台",
+	}
+
+	return s.createPayment(params, baseURL, "")
+}
+
+// keepOpen 解析下单参数 auto_close：0 表示未支付订单不自动过期，1 表示按 payment.order_timeout 过期，
+// 未指定时使用商户设置 merchant.keep_unpaid_orders
+// @param autoClose 下单参数 auto_close
+// @return bool 订单是否不自动过期
+func (s *CodePayService) keepOpen(autoClose string) (bool, error) {
+	switch autoClose {
+	case "":
+		return s.cfg.Merchant.KeepUnpaidOrders, nil
+	case "0":
+		return true, nil
+	case "1":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid auto_close: must be 0 or 1")
+	}
+}
+
+// autoCloseFlag 订单的 auto_close 返回值（1 超时自动关闭，0 保持打开）
+func autoCloseFlag(order *model.Order) int {
+	if order.KeepOpen {
+		return 0
+	}
+	return 1
+}
+
+// manualOutTradeNoPrefix 手动订单的商户订单号前缀
+const manualOutTradeNoPrefix = "MANUAL"
+
+// createPayment 创建订单并生成支付信息（参数已验证，clientIP 为买家IP，未知时为空）
+func (s *CodePayService) createPayment(params map[string]string, baseURL, clientIP string) (map[string]interface{}, error) {
+	// 支付说明和提示的语言（下单参数 lang，未指定时使用默认语言）
+	lang := i18n.Normalize(params["lang"])
+
+	// 检查订单是否已存在（防止重复提交，并发提交由创建订单时的事务兜底）
 	existingOrder, err := s.db.GetOrderByOutTradeNo(params["out_trade_no"], params["pid"])
 	if err != nil {
 		return nil, fmt.Errorf("failed to check existing order: %w", err)
@@ -137,32 +241,30 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 		logger.Info("Order already exists, returning existing order",
 			zap.String("out_trade_no", params["out_trade_no"]),
 			zap.String("trade_no", existingOrder.ID))
-		return s.buildOrderResponse(existingOrder, baseURL), nil
+		return s.buildOrderResponse(existingOrder, baseURL, lang), nil
 	}
 
 	// 解析金额（严格防止0元购）
-	var amount float64
 	moneyStr := params["money"]
 	if moneyStr == "" {
 		moneyStr = params["price"] // 兼容price参数
 	}
 
-	_, err = fmt.Sscanf(moneyStr, "%f", &amount)
+	amount, err := money.ParseOrderAmount(moneyStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid amount format: %w", err)
+		return nil, fmt.Errorf("invalid amount: %w", err)
 	}
 
-	// 严格验证金额（防止0元购）
-	if amount <= 0 {
-		return nil, fmt.Errorf("invalid amount: must be greater than 0 (0 yuan purchase not allowed)")
-	}
-
-	if amount < 0.01 {
-		return nil, fmt.Errorf("invalid amount: minimum is 0.01 yuan")
+	// 买家IP归属地风控
+	clientIP = normalizeClientIP(clientIP)
+	location, err := s.checkBuyerRegion(clientIP, params["name"])
+	if err != nil {
+		return nil, err
 	}
 
-	if amount > 99999.99 {
-		return nil, fmt.Errorf("invalid amount: maximum is 99999.99 yuan")
+	keepOpen, err := s.keepOpen(params["auto_close"])
+	if err != nil {
+		return nil, err
 	}
 
 	// 生成交易号
@@ -170,25 +272,24 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 
 	// 确定支付金额（经营码模式可能需要调整）
 	paymentAmount := amount
-	amountAdjusted := false
-	adjustmentNote := ""
 	var selectedQR *config.QRCode
+	sandbox := s.IsSandboxMerchant(params["pid"])
+	useWap := !sandbox && s.useWapPay(params)
 
-	if s.cfg.Payment.BusinessQRMode.Enabled {
+	// 手机网站支付按交易号确认，沙箱订单只匹配模拟账单，都不需要调整金额和分配经营码
+	if s.cfg.Payment.BusinessQRMode.Enabled && !useWap && !sandbox {
 		var err error
 		paymentAmount, err = s.allocateUniqueAmount(amount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to allocate unique amount: %w", err)
 		}
 
-		if paymentAmount != amount {
-			amountAdjusted = true
-			adjustmentNote = fmt.Sprintf("检测到相同金额订单，实际支付金额已调整为 %.2f 元", paymentAmount)
-		}
-
 		// 如果启用了多二维码模式，选择一个二维码
 		if s.qrSelector != nil && s.qrSelector.IsEnabled() {
-			selectedQR, err = s.qrSelector.SelectQRCode()
+			selectedQR, err = s.qrSelector.SelectQRCode(paymentAmount)
+			if errors.Is(err, ErrQRCodeQuotaReached) {
+				return nil, err
+			}
 			if err != nil {
 				logger.Warn("Failed to select QR code, using default", zap.Error(err))
 			}
@@ -215,11 +316,59 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 			}
 			return ""
 		}(),
+		KeepOpen: keepOpen,
+	}
+
+	// 手机网站支付：生成支付宝收银台地址
+	// 当面付模式：先向支付宝预下单，失败时不创建订单
+	var precreateQRCode, wapURL string
+	if sandbox {
+		order.QRCodeID = model.QRCodeIDSandbox
+	} else if useWap {
+		order.QRCodeID = model.QRCodeIDWap
+		if wapURL, err = s.wapPayURL(order, baseURL); err != nil {
+			return nil, fmt.Errorf("failed to build alipay wap pay url: %w", err)
+		}
+	} else if s.cfg.Payment.PrecreateMode.Enabled {
+		order.QRCodeID = model.QRCodeIDPrecreate
+		if precreateQRCode, err = s.precreateOrder(order, baseURL); err != nil {
+			return nil, fmt.Errorf("failed to precreate alipay trade: %w", err)
+		}
+	}
+
+	// 支付宝交易（手机网站支付、当面付）在支付宝侧按 order_timeout 过期，订单随之过期
+	if order.IsAlipayTrade() {
+		order.KeepOpen = false
 	}
 
-	if err := s.db.CreateOrder(order); err != nil {
+	// 并发重复提交时只有一个请求能创建订单，其余返回已有订单
+	existing, err := s.db.CreateOrderOrGetExisting(order)
+	if err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
+	if existing != nil {
+		return s.buildOrderResponse(existing, baseURL, lang), nil
+	}
+
+	if clientIP != "" {
+		s.saveOrderClient(order.ID, clientIP, location)
+	}
+
+	if precreateQRCode != "" {
+		if err := s.db.SavePrecreateQRCode(order.ID, precreateQRCode); err != nil {
+			logger.Warn("Failed to save precreate qr code", zap.String("trade_no", order.ID), zap.Error(err))
+		}
+	}
+
+	s.db.RecordOrderEvent(order.ID, model.OrderEventCreated,
+		fmt.Sprintf("金额: %s, 实付: %s", amount, paymentAmount))
+	if s.cfg.Payment.BusinessQRMode.Enabled || order.IsAlipayTrade() || sandbox {
+		qrID := order.QRCodeID
+		if qrID == "" {
+			qrID = "default"
+		}
+		s.db.RecordOrderEvent(order.ID, model.OrderEventQRAssigned, qrID)
+	}
 
 	// 发布订单创建事件（触发管理后台WebSocket推送）
 	events.PublishOrderCreated(order)
@@ -227,8 +376,8 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 	logger.Info("Order created",
 		zap.String("trade_no", tradeNo),
 		zap.String("out_trade_no", params["out_trade_no"]),
-		zap.Float64("amount", amount),
-		zap.Float64("payment_amount", paymentAmount))
+		zap.Stringer("amount", amount),
+		zap.Stringer("payment_amount", paymentAmount))
 
 	// 注意：本系统使用账单查询方式监听支付（和PHP版本一致）
 	// 不需要 alipay.trade.query 接口权限
@@ -241,17 +390,30 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 		"pid":            params["pid"],
 		"trade_no":       tradeNo,
 		"out_trade_no":   params["out_trade_no"],
-		"money":          utils.FormatAmount(amount),
+		"money":          amount.String(),
 		"payment_amount": paymentAmount,
 		"create_time":    order.AddTime.Format("2006-01-02 15:04:05"), // 订单创建时间
+		"auto_close":     autoCloseFlag(order),                        // 0 表示未支付订单不自动关闭
 	}
 
 	// 根据收款模式生成二维码
-	if s.cfg.Payment.BusinessQRMode.Enabled {
+	if sandbox {
+		// 沙箱订单：二维码为支付页面地址，在页面上模拟支付
+		if err := s.fillSandboxResponse(response, order, baseURL, lang); err != nil {
+			return nil, err
+		}
+	} else if wapURL != "" {
+		// 手机网站支付：跳转支付宝收银台
+		s.fillWapResponse(response, wapURL, paymentAmount, lang)
+	} else if precreateQRCode != "" {
+		// 当面付模式：支付宝生成的订单二维码
+		if err := s.fillPrecreateResponse(response, precreateQRCode, paymentAmount, lang); err != nil {
+			return nil, err
+		}
+	} else if s.cfg.Payment.BusinessQRMode.Enabled {
 		// 经营码模式：生成包含金额信息的支付链接
 		// 生成支付页面链接（包含金额信息）
-		paymentPageURL := fmt.Sprintf("%s/pay?trade_no=%s&amount=%.2f",
-			baseURL, tradeNo, paymentAmount)
+		paymentPageURL := payPageURL(baseURL, order, lang)
 
 		// 生成二维码（用户扫码后跳转到支付页面）
 		qrCodeBase64, err := s.qrGenerator.GenerateToBase64(paymentPageURL)
@@ -262,21 +424,7 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 		response["payment_url"] = paymentPageURL
 		response["qr_code"] = qrCodeBase64
 		response["business_qr_mode"] = true
-		response["payment_instruction"] = fmt.Sprintf("请使用支付宝扫描二维码，确认支付 %.2f 元", paymentAmount)
-
-		if amountAdjusted {
-			response["amount_adjusted"] = true
-			response["adjustment_note"] = adjustmentNote
-			response["original_amount"] = amount
-		}
-
-		response["payment_tips"] = []string{
-			fmt.Sprintf("请务必支付准确金额：%.2f 元", paymentAmount),
-			"支付时无需填写备注信息",
-			"请在5分钟内完成支付，超时订单将被自动删除",
-			"支付完成后系统会自动检测到账",
-			"如长时间未到账，请联系客服",
-		}
+		s.fillBusinessTips(response, order, lang)
 
 	} else {
 		// 传统转账模式：生成动态转账二维码
@@ -294,20 +442,42 @@ func (s *CodePayService) CreatePayment(params map[string]string, baseURL string)
 }
 
 // buildOrderResponse 构建订单响应（用于已存在的订单）
-func (s *CodePayService) buildOrderResponse(order *model.Order, baseURL string) map[string]interface{} {
+// lang 为支付说明和提示的语言，为空时使用默认语言
+func (s *CodePayService) buildOrderResponse(order *model.Order, baseURL, lang string) map[string]interface{} {
 	response := map[string]interface{}{
 		"code":           1,
 		"msg":            "SUCCESS",
 		"pid":            order.PID,
 		"trade_no":       order.ID,
 		"out_trade_no":   order.OutTradeNo,
-		"money":          utils.FormatAmount(order.Price),
+		"money":          order.Price.String(),
 		"payment_amount": order.PaymentAmount,
 		"create_time":    order.AddTime.Format("2006-01-02 15:04:05"), // 订单创建时间
+		"auto_close":     autoCloseFlag(order),                        // 0 表示未支付订单不自动关闭
 	}
 
 	// 根据收款模式生成二维码
-	if s.cfg.Payment.BusinessQRMode.Enabled {
+	if order.IsSandbox() {
+		// 沙箱订单
+		_ = s.fillSandboxResponse(response, order, baseURL, lang)
+	} else if order.QRCodeID == model.QRCodeIDWap {
+		// 手机网站支付
+		wapURL, err := s.wapPayURL(order, baseURL)
+		if err != nil {
+			logger.Warn("Failed to build alipay wap pay url", zap.String("trade_no", order.ID), zap.Error(err))
+		} else {
+			s.fillWapResponse(response, wapURL, order.PaymentAmount, lang)
+		}
+	} else if order.QRCodeID == model.QRCodeIDPrecreate {
+		// 当面付模式
+		qrCode, err := s.db.GetPrecreateQRCode(order.ID)
+		if err != nil {
+			logger.Warn("Failed to get precreate qr code", zap.String("trade_no", order.ID), zap.Error(err))
+		}
+		if qrCode != "" {
+			_ = s.fillPrecreateResponse(response, qrCode, order.PaymentAmount, lang)
+		}
+	} else if s.cfg.Payment.BusinessQRMode.Enabled {
 		// 经营码模式
 		token := utils.MD5(fmt.Sprintf("qrcode_access_%s", time.Now().Format("2006-01-02")))
 		qrCodeURL := fmt.Sprintf("%s/qrcode?type=business&token=%s", baseURL, token)
@@ -315,22 +485,7 @@ func (s *CodePayService) buildOrderResponse(order *model.Order, baseURL string)
 		response["payment_url"] = "" // 经营码模式没有直接URL
 		response["qr_code_url"] = qrCodeURL
 		response["business_qr_mode"] = true
-		response["payment_instruction"] = fmt.Sprintf("请使用支付宝扫描二维码，支付金额：%.2f 元", order.PaymentAmount)
-
-		// 检查金额是否被调整
-		if order.PaymentAmount != order.Price {
-			response["amount_adjusted"] = true
-			response["adjustment_note"] = fmt.Sprintf("检测到相同金额订单，实际支付金额已调整为 %.2f 元", order.PaymentAmount)
-			response["original_amount"] = order.Price
-		}
-
-		response["payment_tips"] = []string{
-			fmt.Sprintf("请务必支付准确金额：%.2f 元", order.PaymentAmount),
-			"支付时无需填写备注信息",
-			"请在5分钟内完成支付，超时订单将被自动删除",
-			"支付完成后系统会自动检测到账",
-			"如长时间未到账，请联系客服",
-		}
+		s.fillBusinessTips(response, order, lang)
 	} else {
 		// 传统转账模式
 		transferURL := s.transfer.GenerateTransferURL(order.PaymentAmount, order.OutTradeNo, "")
@@ -340,11 +495,28 @@ func (s *CodePayService) buildOrderResponse(order *model.Order, baseURL string)
 		response["qr_code"] = qrCodeBase64
 	}
 
+	// 已收到的分笔支付
+	s.fillPartialPayment(response, order, lang)
+
 	return response
 }
 
+// fillPrecreateResponse 填充当面付订单的支付信息（支付宝二维码内容可直接在手机上打开）
+func (s *CodePayService) fillPrecreateResponse(response map[string]interface{}, qrCode string, paymentAmount model.Amount, lang string) error {
+	qrCodeBase64, err := s.qrGenerator.GenerateToBase64(qrCode)
+	if err != nil {
+		return fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	response["payment_url"] = qrCode
+	response["qr_code"] = qrCodeBase64
+	response["precreate_mode"] = true
+	response["payment_instruction"] = i18n.T(lang, "tip.precreate", paymentAmount)
+	return nil
+}
+
 // allocateUniqueAmount 分配唯一的支付金额
-func (s *CodePayService) allocateUniqueAmount(originalAmount float64) (float64, error) {
+func (s *CodePayService) allocateUniqueAmount(originalAmount model.Amount) (model.Amount, error) {
 	amountLock := lock.GetAmountLock()
 	amountLock.Lock()
 	defer amountLock.Unlock()
@@ -364,8 +536,8 @@ func (s *CodePayService) allocateUniqueAmount(originalAmount float64) (float64,
 
 		if !exists {
 			logger.Info("Unique amount allocated",
-				zap.Float64("original", originalAmount),
-				zap.Float64("allocated", paymentAmount),
+				zap.Stringer("original", originalAmount),
+				zap.Stringer("allocated", paymentAmount),
 				zap.Int("attempts", i+1))
 			return paymentAmount, nil
 		}
@@ -376,16 +548,26 @@ func (s *CodePayService) allocateUniqueAmount(originalAmount float64) (float64,
 	return 0, fmt.Errorf("failed to allocate unique amount after %d attempts", maxAttempts)
 }
 
-// QueryOrder 查询订单
-func (s *CodePayService) QueryOrder(pid, key, outTradeNo string, validateKey bool) (map[string]interface{}, error) {
-	if validateKey && (pid != s.merchantID || key != s.merchantKey) {
-		return map[string]interface{}{
-			"code": -1,
-			"msg":  "Invalid merchant credentials",
-		}, nil
-	}
+// GetOrder 按系统交易号获取订单
+// @param tradeNo 系统交易号
+// @return *model.Order 订单，不存在时为nil
+func (s *CodePayService) GetOrder(tradeNo string) (*model.Order, error) {
+	return s.db.GetOrderByID(tradeNo)
+}
 
-	if !validateKey && pid != s.merchantID {
+// OrderPaymentInfo 获取订单当前的支付信息（支付链接、二维码、支付提示）
+// @description 与重复提交同一订单时返回的内容一致，供支付页面刷新展示
+// @param order 订单
+// @param baseURL 服务基础URL
+// @param lang 支付说明和提示的语言
+// @return map[string]interface{} 支付信息
+func (s *CodePayService) OrderPaymentInfo(order *model.Order, baseURL, lang string) map[string]interface{} {
+	return s.buildOrderResponse(order, baseURL, lang)
+}
+
+// QueryOrder 查询订单（商户凭据由认证中间件验证）
+func (s *CodePayService) QueryOrder(pid, outTradeNo string) (map[string]interface{}, error) {
+	if _, ok := s.merchantKeyFor(pid); !ok {
 		return map[string]interface{}{
 			"code": -1,
 			"msg":  "Invalid merchant ID",
@@ -405,23 +587,25 @@ func (s *CodePayService) QueryOrder(pid, key, outTradeNo string, validateKey boo
 	}
 
 	return map[string]interface{}{
-		"code":         1,
-		"msg":          "SUCCESS",
-		"trade_no":     order.ID,
-		"out_trade_no": order.OutTradeNo,
-		"type":         order.Type,
-		"pid":          order.PID,
-		"addtime":      utils.FormatTime(order.AddTime),
-		"endtime":      s.formatPayTime(order.PayTime),
-		"name":         order.Name,
-		"money":        utils.FormatAmount(order.Price),
-		"status":       order.Status,
+		"code":            1,
+		"msg":             "SUCCESS",
+		"trade_no":        order.ID,
+		"out_trade_no":    order.OutTradeNo,
+		"type":            order.Type,
+		"pid":             order.PID,
+		"addtime":         utils.FormatTime(order.AddTime),
+		"endtime":         s.formatPayTime(order.PayTime),
+		"name":            order.Name,
+		"money":           order.Price.String(),
+		"status":          order.Status,
+		"alipay_trade_no": order.AlipayTradeNo,
+		"paid_amount":     s.partialPaidAmount(order),
 	}, nil
 }
 
-// QueryOrders 查询订单列表
-func (s *CodePayService) QueryOrders(pid, key string, limit int) ([]map[string]interface{}, error) {
-	if pid != s.merchantID || key != s.merchantKey {
+// QueryOrders 查询订单列表（商户凭据由认证中间件验证）
+func (s *CodePayService) QueryOrders(pid string, limit int) ([]map[string]interface{}, error) {
+	if _, ok := s.merchantKeyFor(pid); !ok {
 		return nil, fmt.Errorf("invalid merchant credentials")
 	}
 
@@ -444,7 +628,7 @@ func (s *CodePayService) QueryOrders(pid, key string, limit int) ([]map[string]i
 			"addtime":      utils.FormatTime(order.AddTime),
 			"endtime":      s.formatPayTime(order.PayTime),
 			"name":         order.Name,
-			"money":        utils.FormatAmount(order.Price),
+			"money":        order.Price.String(),
 			"status":       order.Status,
 		})
 	}
@@ -452,6 +636,40 @@ func (s *CodePayService) QueryOrders(pid, key string, limit int) ([]map[string]i
 	return result, nil
 }
 
+// 订单关闭错误
+var (
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrOrderAlreadyPaid = errors.New("order already paid, cannot close")
+)
+
+// CloseOrder 关闭未支付订单（商户凭据由调用方验证）
+func (s *CodePayService) CloseOrder(pid, outTradeNo string) (*model.Order, error) {
+	order, err := s.db.GetOrderByOutTradeNo(outTradeNo, pid)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	if order.Status == model.OrderStatusPaid {
+		return nil, ErrOrderAlreadyPaid
+	}
+
+	if err := s.states.Close(order, "商户关闭订单"); err != nil {
+		if order.Status == model.OrderStatusPaid {
+			return nil, ErrOrderAlreadyPaid
+		}
+		return nil, fmt.Errorf("failed to close order: %w", err)
+	}
+
+	logger.Info("Order closed",
+		zap.String("trade_no", order.ID),
+		zap.String("out_trade_no", outTradeNo))
+
+	return order, nil
+}
+
 // validatePaymentParams 验证支付参数
 func (s *CodePayService) validatePaymentParams(params map[string]string) error {
 	required := []string{"pid", "type", "out_trade_no", "notify_url", "return_url", "name", "money", "sign"}
@@ -461,7 +679,7 @@ func (s *CodePayService) validatePaymentParams(params map[string]string) error {
 		}
 	}
 
-	if params["pid"] != s.merchantID {
+	if _, ok := s.merchantKeyFor(params["pid"]); !ok {
 		return fmt.Errorf("invalid merchant ID")
 	}
 
@@ -487,9 +705,16 @@ func (s *CodePayService) GetMerchantID() string {
 
 // GetMerchantKey 获取商户密钥
 func (s *CodePayService) GetMerchantKey() string {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
 	return s.merchantKey
 }
 
+// GetQRCodeSelector 获取二维码选择器（未启用经营码模式时为nil）
+func (s *CodePayService) GetQRCodeSelector() *QRCodeSelector {
+	return s.qrSelector
+}
+
 // SendNotification 发送支付通知给商户
 func (s *CodePayService) SendNotification(order *model.Order) error {
 	if order.NotifyURL == "" {
@@ -497,33 +722,167 @@ func (s *CodePayService) SendNotification(order *model.Order) error {
 		return nil
 	}
 
-	notifyData := map[string]string{
+	// 争议订单冻结通知（订单可能在排队期间被标记，按最新状态判断）
+	if s.orderDisputed(order) {
+		logger.Info("Notification frozen for disputed order",
+			zap.String("order_id", order.ID),
+			zap.String("out_trade_no", order.OutTradeNo))
+		return ErrOrderDisputed
+	}
+
+	notifyData := s.paymentResult(order)
+
+	logger.Info("Sending notification to merchant",
+		zap.String("order_id", order.ID),
+		zap.String("out_trade_no", order.OutTradeNo),
+		zap.String("notify_url", order.NotifyURL),
+		zap.String("sign", utils.MaskSign(notifyData["sign"]))) // 签名脱敏
+
+	// 实际发送HTTP通知并记录结果
+	return s.deliverNotification(order, order.NotifyURL, notifyData)
+}
+
+// paymentResult 生成签名的支付结果参数（易支付标准，异步通知与同步跳转相同，按商户的签名类型签名）
+func (s *CodePayService) paymentResult(order *model.Order) map[string]string {
+	result := map[string]string{
 		"pid":          order.PID,
 		"trade_no":     order.ID,
 		"out_trade_no": order.OutTradeNo,
 		"type":         order.Type,
 		"name":         order.Name,
-		"money":        utils.FormatAmount(order.Price),
+		"money":        order.Price.String(),
 		"trade_status": "TRADE_SUCCESS",
 	}
+	// 账单匹配的订单附带支付宝交易号（参与签名），便于商户对账
+	if order.AlipayTradeNo != "" {
+		result["alipay_trade_no"] = order.AlipayTradeNo
+	}
 
-	// 生成签名
-	sign := utils.GenerateSign(notifyData, s.merchantKey)
-	notifyData["sign"] = sign
-	notifyData["sign_type"] = "MD5"
+	// 沙箱订单使用沙箱密钥签名（沙箱密钥重新签发后，旧订单按新密钥签名）
+	key, ok := s.merchantKeyFor(order.PID)
+	if !ok {
+		key = s.GetMerchantKey()
+	}
+	result["sign_type"] = s.SignType()
+	result["sign"] = utils.GenerateSign(result, key)
+	return result
+}
 
-	logger.Info("Sending notification to merchant",
-		zap.String("order_id", order.ID),
-		zap.String("out_trade_no", order.OutTradeNo),
-		zap.String("notify_url", order.NotifyURL),
-		zap.String("sign", utils.MaskSign(sign))) // 签名脱敏
+// BuildReturnURL 生成支付完成后跳转商户的地址
+// @description 已支付订单在 return_url 后附加签名的支付结果参数（与异步通知相同），商户验签后即可展示支付结果；
+// 未支付订单（如用户中途退出收银台）直接跳转 return_url，不附加参数
+// @param order 订单（return_url 不能为空）
+// @return string 跳转地址
+func (s *CodePayService) BuildReturnURL(order *model.Order) (string, error) {
+	if order.Status != model.OrderStatusPaid {
+		return order.ReturnURL, nil
+	}
 
-	// 实际发送HTTP通知
-	return s.sendHTTPNotification(order.NotifyURL, notifyData)
+	returnURL, err := url.Parse(order.ReturnURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid return_url: %w", err)
+	}
+
+	// 保留商户地址原有的查询参数，同名参数以支付结果为准
+	query := returnURL.Query()
+	for k, v := range s.paymentResult(order) {
+		query.Set(k, v)
+	}
+	returnURL.RawQuery = query.Encode()
+	return returnURL.String(), nil
+}
+
+// deliverNotification 发送通知并写入通知记录
+func (s *CodePayService) deliverNotification(order *model.Order, notifyURL string, data map[string]string) error {
+	start := time.Now()
+	httpStatus, response, err := s.sendHTTPNotification(notifyURL, data)
+
+	payload, _ := json.Marshal(data)
+	log := &model.NotifyLog{
+		OrderID:    order.ID,
+		OutTradeNo: order.OutTradeNo,
+		PID:        order.PID,
+		NotifyURL:  notifyURL,
+		Payload:    string(payload),
+		Status:     model.NotifyStatusSuccess,
+		HTTPStatus: httpStatus,
+		Response:   truncate(response, 500),
+		DurationMs: time.Since(start).Milliseconds(),
+		CreatedAt:  start,
+	}
+	if err != nil {
+		log.Status = model.NotifyStatusFailed
+		log.Error = err.Error()
+	}
+
+	if logErr := s.db.CreateNotifyLog(log); logErr != nil {
+		logger.Warn("Failed to record notify log",
+			zap.String("order_id", order.ID),
+			zap.Error(logErr))
+	}
+
+	return err
+}
+
+// ReplayNotification 将历史通知的原始参数重新发送到指定地址
+// @description 用于调试商户的通知处理（如发送到测试环境），不修改订单状态，也不写入通知记录
+// @param original 原始通知记录
+// @param targetURL 目标地址，为空时使用原通知地址
+// @return *model.NotifyLog 本次发送结果（未入库）
+// @return error 原始参数无法解析、或争议订单重放到原通知地址时返回错误
+func (s *CodePayService) ReplayNotification(original *model.NotifyLog, targetURL string) (*model.NotifyLog, error) {
+	var data map[string]string
+	if err := json.Unmarshal([]byte(original.Payload), &data); err != nil {
+		return nil, fmt.Errorf("invalid notify payload: %w", err)
+	}
+
+	if targetURL == "" {
+		targetURL = original.NotifyURL
+	}
+
+	// 争议订单冻结通知，只允许重放到其他地址调试
+	if targetURL == original.NotifyURL && s.orderDisputed(&model.Order{ID: original.OrderID}) {
+		return nil, ErrOrderDisputed
+	}
+
+	logger.Info("Replaying notification",
+		zap.Int64("notify_log_id", original.ID),
+		zap.String("trade_no", original.OrderID),
+		zap.String("target_url", targetURL))
+
+	start := time.Now()
+	httpStatus, response, err := s.sendHTTPNotification(targetURL, data)
+
+	result := &model.NotifyLog{
+		OrderID:    original.OrderID,
+		OutTradeNo: original.OutTradeNo,
+		PID:        original.PID,
+		NotifyURL:  targetURL,
+		Payload:    original.Payload,
+		Status:     model.NotifyStatusSuccess,
+		HTTPStatus: httpStatus,
+		Response:   truncate(response, 500),
+		DurationMs: time.Since(start).Milliseconds(),
+		CreatedAt:  start,
+	}
+	if err != nil {
+		result.Status = model.NotifyStatusFailed
+		result.Error = err.Error()
+	}
+
+	return result, nil
+}
+
+// truncate 截断过长的字符串
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
 }
 
 // ProcessPaymentCallback 处理支付回调（内部使用）
-func (s *CodePayService) ProcessPaymentCallback(tradeNo string, paymentAmount float64, billTime string) error {
+func (s *CodePayService) ProcessPaymentCallback(tradeNo string, paymentAmount model.Amount, billTime string) error {
 	// 查询订单
 	order, err := s.db.GetOrderByID(tradeNo)
 	if err != nil {
@@ -542,34 +901,30 @@ func (s *CodePayService) ProcessPaymentCallback(tradeNo string, paymentAmount fl
 
 	// 验证金额
 	if order.PaymentAmount != paymentAmount {
-		return fmt.Errorf("payment amount mismatch: expected %.2f, got %.2f",
+		return fmt.Errorf("payment amount mismatch: expected %s, got %s",
 			order.PaymentAmount, paymentAmount)
 	}
 
 	// 更新订单状态
-	payTime := time.Now()
-	if err := s.db.UpdateOrderStatus(order.ID, model.OrderStatusPaid, payTime); err != nil {
+	detail := fmt.Sprintf("内部回调确认，账单时间: %s", billTime)
+	if err := s.states.MarkPaid(order, model.OrderEventMarkedPaid, detail); err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	logger.Info("Order payment confirmed",
 		zap.String("trade_no", tradeNo),
 		zap.String("out_trade_no", order.OutTradeNo),
-		zap.Float64("amount", paymentAmount))
+		zap.Stringer("amount", paymentAmount))
 
-	// 发送通知给商户
-	if err := s.SendNotification(order); err != nil {
-		logger.Error("Failed to send merchant notification",
-			zap.String("trade_no", tradeNo),
-			zap.Error(err))
-		// 不返回错误，因为订单已经更新成功
-	}
+	// 发送通知给商户（异步，失败时由自动回调重试，不影响订单已更新的结果）
+	_ = s.QueueNotification(order)
 
 	return nil
 }
 
 // sendHTTPNotification 发送HTTP通知
-func (s *CodePayService) sendHTTPNotification(notifyURL string, data map[string]string) error {
+// 返回商户响应的HTTP状态码和响应内容
+func (s *CodePayService) sendHTTPNotification(notifyURL string, data map[string]string) (int, string, error) {
 	// 构建查询字符串
 	values := make(url.Values)
 	for k, v := range data {
@@ -584,16 +939,18 @@ func (s *CodePayService) sendHTTPNotification(notifyURL string, data map[string]
 		fullURL += "?" + values.Encode()
 	}
 
-	// 创建HTTP客户端（设置超时）
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	req, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	if err != nil {
+		logger.Error("Failed to build notification request", zap.Error(err))
+		return 0, "", err
 	}
+	s.signNotifyRequest(req, data)
 
-	// 发送GET请求
-	resp, err := client.Get(fullURL)
+	// 发送GET请求（共享连接池，超时见 http_client.notify）
+	resp, err := httpclient.For(httpclient.Notify).Do(req)
 	if err != nil {
 		logger.Error("Failed to send notification", zap.Error(err))
-		return err
+		return 0, "", err
 	}
 	defer resp.Body.Close()
 
@@ -601,7 +958,7 @@ func (s *CodePayService) sendHTTPNotification(notifyURL string, data map[string]
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error("Failed to read notification response", zap.Error(err))
-		return err
+		return resp.StatusCode, "", err
 	}
 
 	responseStr := string(body)
@@ -612,7 +969,7 @@ func (s *CodePayService) sendHTTPNotification(notifyURL string, data map[string]
 		logger.Info("Notification sent successfully",
 			zap.String("notify_url", notifyURL),
 			zap.String("response", responseStr))
-		return nil
+		return resp.StatusCode, responseStr, nil
 	}
 
 	// 如果是测试URL（example.com），不报错，只记录警告
@@ -620,17 +977,19 @@ func (s *CodePayService) sendHTTPNotification(notifyURL string, data map[string]
 		logger.Warn("Test notify URL, skipping validation",
 			zap.String("notify_url", notifyURL),
 			zap.String("response_preview", responseStr[:min(len(responseStr), 100)]+"..."))
-		return nil // 测试URL不报错
+		return resp.StatusCode, responseStr, nil // 测试URL不报错
 	}
 
 	logger.Warn("Notification response is not success",
 		zap.String("notify_url", notifyURL),
 		zap.String("response", responseStr))
 
-	return fmt.Errorf("invalid notification response: %s", responseStr)
+	return resp.StatusCode, responseStr, fmt.Errorf("invalid notification response: %s", truncate(responseStr, 200))
 }
 
 // CleanupExpiredOrders 清理过期订单
+// 超时未支付的订单先经状态机标记为已过期（发布过期事件），再从数据库删除；
+// 已收到分笔支付的订单不自动过期，保留为待支付由商户补单或关闭后退款
 func (s *CodePayService) CleanupExpiredOrders() (int64, error) {
 	if !s.cfg.Payment.AutoCleanup {
 		return 0, nil
@@ -639,6 +998,34 @@ func (s *CodePayService) CleanupExpiredOrders() (int64, error) {
 	timeout := s.cfg.Payment.OrderTimeout
 	expiredTime := time.Now().Add(-time.Duration(timeout) * time.Second)
 
+	orders, err := s.db.GetPendingOrdersBefore(expiredTime)
+	if err != nil {
+		return 0, err
+	}
+	for _, order := range orders {
+		paid, err := s.db.GetPartialPaidAmount(order.ID)
+		if err != nil {
+			logger.Warn("Failed to check partial payments, order not expired",
+				zap.String("order_id", order.ID),
+				zap.Error(err))
+			continue
+		}
+		if paid > 0 {
+			logger.Warn("Order with partial payments not expired, please complete or close it manually",
+				zap.String("order_id", order.ID),
+				zap.Stringer("payment_amount", order.PaymentAmount),
+				zap.Stringer("received", paid))
+			continue
+		}
+
+		// 并发支付导致的转换失败无需处理，订单保持已支付状态不会被删除
+		if err := s.states.Expire(order); err != nil && !errors.Is(err, ErrIllegalTransition) {
+			logger.Warn("Failed to expire order",
+				zap.String("order_id", order.ID),
+				zap.Error(err))
+		}
+	}
+
 	count, err := s.db.DeleteExpiredOrders(expiredTime)
 	if err != nil {
 		return 0, err
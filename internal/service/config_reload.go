@@ -0,0 +1,264 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/pkg/lock"
+	"alimpay-go/internal/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// configReloadDebounce 配置文件变化后等待多久再加载（编辑器保存时通常会触发多次事件）
+const configReloadDebounce = 500 * time.Millisecond
+
+// ConfigReloader 配置热加载服务
+// @description 监听配置文件变化，在运行时应用可安全调整的配置项（监听间隔、Worker池大小、二维码列表、金额偏移、日志级别），
+// 其他配置项的修改仅记录警告，需重启后生效，避免重启中断正在进行的订单
+type ConfigReloader struct {
+	path    string
+	cfg     *config.Config
+	initial *config.Config // 启动时的配置文件内容（运行时生成的商户信息等不会写回配置文件，需与文件内容比较）
+	monitor *MonitorService
+	codepay *CodePayService
+	qrCodes *QRCodeManager
+	watcher *fsnotify.Watcher
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+}
+
+// NewConfigReloader 创建配置热加载服务
+// @param path 配置文件路径
+// @param cfg 当前配置（可安全调整的配置项通过 config.Config 的运行时访问方法在锁内更新）
+// @param monitor 监听服务
+// @param codepay 码支付服务（通知Worker池）
+// @param qrCodes 收款码管理服务
+// @return *ConfigReloader 配置热加载服务
+func NewConfigReloader(path string, cfg *config.Config, monitor *MonitorService, codepay *CodePayService, qrCodes *QRCodeManager) *ConfigReloader {
+	return &ConfigReloader{
+		path:    path,
+		cfg:     cfg,
+		monitor: monitor,
+		codepay: codepay,
+		qrCodes: qrCodes,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start 开始监听配置文件
+// @description 监听配置文件所在目录，以兼容编辑器“写临时文件再重命名”及Kubernetes ConfigMap的符号链接替换
+// @return error 监听错误
+func (r *ConfigReloader) Start() error {
+	initial, err := config.Parse(r.path)
+	if err != nil {
+		return err
+	}
+	r.initial = initial
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+
+	r.watcher = watcher
+	r.wg.Add(1)
+	go r.watch()
+
+	logger.Info("Config hot reload enabled", zap.String("config", r.path))
+	return nil
+}
+
+// Stop 停止监听配置文件
+func (r *ConfigReloader) Stop() {
+	if r.watcher == nil {
+		return
+	}
+
+	close(r.stopCh)
+	r.watcher.Close()
+	r.wg.Wait()
+}
+
+// watch 处理文件事件（合并短时间内的多次事件）
+func (r *ConfigReloader) watch() {
+	defer r.wg.Done()
+
+	name := filepath.Base(r.path)
+	timer := time.NewTimer(configReloadDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.stopCh:
+			return
+
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			base := filepath.Base(event.Name)
+			if base != name && !strings.HasPrefix(base, "..") {
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			timer.Reset(configReloadDebounce)
+
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("Config watcher error", zap.Error(err))
+
+		case <-timer.C:
+			if err := r.Reload(); err != nil {
+				logger.Error("Failed to reload config, keeping current settings", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Reload 重新加载配置文件并应用可安全调整的配置项
+// @return error 配置文件读取或解析错误（此时保持当前配置不变）
+func (r *ConfigReloader) Reload() error {
+	newCfg, err := config.Parse(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	applied := r.apply(newCfg)
+	pending := restartRequiredSections(r.initial, newCfg)
+
+	if len(applied) > 0 {
+		logger.Info("Config reloaded", zap.Strings("applied", applied))
+	}
+	if len(pending) > 0 {
+		logger.Warn("Config changes require restart to take effect", zap.Strings("sections", pending))
+	}
+
+	return nil
+}
+
+// apply 应用可安全调整的配置项
+// @param newCfg 新配置
+// @return []string 已应用的配置项
+func (r *ConfigReloader) apply(newCfg *config.Config) []string {
+	var applied []string
+
+	// 日志级别
+	if newCfg.Logging.Level != r.cfg.LoggingLevel() {
+		logger.SetLevel(newCfg.Logging.Level)
+		r.cfg.SetLoggingLevel(newCfg.Logging.Level)
+		applied = append(applied, "logging.level")
+	}
+
+	// 监听间隔（共享配置由各服务在锁内更新，管理后台也可能同时调整Worker池）
+	monitor := r.cfg.MonitorSnapshot()
+	if newCfg.Monitor.Interval != monitor.Interval {
+		if err := r.monitor.SetInterval(newCfg.Monitor.Interval); err != nil {
+			logger.Error("Failed to apply monitor interval", zap.Error(err))
+		} else {
+			applied = append(applied, "monitor.interval")
+		}
+	}
+
+	// Worker池大小
+	if newCfg.Monitor.WorkerCount != monitor.WorkerCount || newCfg.Monitor.QueueSize != monitor.QueueSize {
+		if err := r.monitor.ResizeWorkerPool(newCfg.Monitor.WorkerCount, newCfg.Monitor.QueueSize); err != nil {
+			logger.Error("Failed to resize worker pool", zap.Error(err))
+		} else {
+			applied = append(applied, "monitor.worker_count", "monitor.queue_size")
+		}
+	}
+
+	// 通知Worker池大小
+	if newCfg.Monitor.NotifyWorkerCount != monitor.NotifyWorkerCount || newCfg.Monitor.NotifyQueueSize != monitor.NotifyQueueSize {
+		if err := r.codepay.ResizeNotifyPool(newCfg.Monitor.NotifyWorkerCount, newCfg.Monitor.NotifyQueueSize); err != nil {
+			logger.Error("Failed to resize notify pool", zap.Error(err))
+		} else {
+			applied = append(applied, "monitor.notify_worker_count", "monitor.notify_queue_size")
+		}
+	}
+
+	// 金额偏移（由金额锁保护，避免与正在分配金额的请求冲突）
+	newMode := &newCfg.Payment.BusinessQRMode
+	mode := &r.cfg.Payment.BusinessQRMode
+	if newMode.AmountOffset != mode.AmountOffset {
+		amountLock := lock.GetAmountLock()
+		amountLock.Lock()
+		mode.AmountOffset = newMode.AmountOffset
+		amountLock.Unlock()
+		applied = append(applied, "payment.business_qr_mode.amount_offset")
+	}
+
+	// 二维码列表（管理后台保存的设置仍然优先）
+	if qrCodes := r.cfg.BusinessQRCodes(); !reflect.DeepEqual(newMode.QRCodePaths, qrCodes) {
+		previous := make(map[string]config.QRCode, len(qrCodes))
+		for _, qr := range qrCodes {
+			previous[qr.ID] = qr
+		}
+		for _, qr := range newMode.QRCodePaths {
+			// 独立API的账单查询服务在启动时创建
+			if qr.HasIndependentAPI() && !reflect.DeepEqual(qr.AlipayAPI, previous[qr.ID].AlipayAPI) {
+				logger.Warn("Independent alipay_api of QR code requires restart to take effect",
+					zap.String("qr_id", qr.ID))
+			}
+		}
+		r.cfg.SetBusinessQRCodes(newMode.QRCodePaths, newMode.QRCodePath, newMode.QRCodeID)
+		r.qrCodes.ReloadConfig(newMode.QRCodePaths)
+		applied = append(applied, "payment.business_qr_mode.qr_code_paths")
+	}
+
+	return applied
+}
+
+// restartRequiredSections 找出除可热加载项之外发生变化的配置段
+func restartRequiredSections(current, next *config.Config) []string {
+	a, b := *current, *next
+
+	// 忽略可热加载的配置项
+	for _, c := range []*config.Config{&a, &b} {
+		c.Logging.Level = ""
+		c.Monitor.Interval = 0
+		c.Monitor.WorkerCount = 0
+		c.Monitor.QueueSize = 0
+		c.Monitor.NotifyWorkerCount = 0
+		c.Monitor.NotifyQueueSize = 0
+		c.Payment.BusinessQRMode.AmountOffset = 0
+		c.Payment.BusinessQRMode.QRCodePaths = nil
+		c.Payment.BusinessQRMode.QRCodePath = ""
+		c.Payment.BusinessQRMode.QRCodeID = ""
+	}
+
+	var sections []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			sections = append(sections, strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0])
+		}
+	}
+
+	return sections
+}
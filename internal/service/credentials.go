@@ -0,0 +1,49 @@
+package service
+
+import (
+	"net/http"
+
+	"alimpay-go/internal/pkg/utils"
+)
+
+// CredentialError 商户凭据验证错误
+// @description 统一各接口的凭据错误信息与HTTP状态码
+type CredentialError struct {
+	Status int    // HTTP状态码（管理接口使用，易支付接口统一返回200）
+	Msg    string // 返回给客户端的错误信息
+}
+
+// Error 实现error接口
+func (e *CredentialError) Error() string {
+	return e.Msg
+}
+
+// 商户凭据验证错误
+var (
+	ErrCredentialsMissing = &CredentialError{Status: http.StatusBadRequest, Msg: "Missing merchant credentials"}
+	ErrCredentialsInvalid = &CredentialError{Status: http.StatusUnauthorized, Msg: "Invalid merchant credentials"}
+)
+
+// VerifyMerchant 验证商户ID和密钥
+// @description 使用常量时间比较，避免通过响应时间推测密钥
+// @param pid 商户ID
+// @param key 商户密钥
+// @return error 验证失败返回 ErrCredentialsMissing 或 ErrCredentialsInvalid
+func (s *CodePayService) VerifyMerchant(pid, key string) error {
+	if pid == "" || key == "" {
+		return ErrCredentialsMissing
+	}
+
+	// 两项都比较后再判断，避免通过耗时区分ID错误和密钥错误
+	idMatch := utils.SecureCompare(pid, s.merchantID)
+	keyMatch := utils.SecureCompare(key, s.GetMerchantKey())
+	if idMatch && !keyMatch {
+		// 商户密钥轮换的过渡期内也接受旧密钥
+		keyMatch = s.acceptPreviousKey(pid, func(previous string) bool { return utils.SecureCompare(key, previous) })
+	}
+	if !idMatch || !keyMatch {
+		return ErrCredentialsInvalid
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/notifier"
+
+	"go.uber.org/zap"
+)
+
+// dailyDigestSentKey 上次发送日报的日期（系统设置，重启或主节点切换后同一天不重复发送）
+const dailyDigestSentKey = "daily_digest_last_sent"
+
+// DailyDigest 运营日报内容（统计区间内创建的订单和发生的通知、异常）
+type DailyDigest struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	Orders      int          `json:"orders"`       // 订单数
+	Paid        int          `json:"paid"`         // 已支付订单数
+	Revenue     model.Amount `json:"revenue"`      // 实收金额（不含争议订单）
+	SuccessRate float64      `json:"success_rate"` // 支付成功率（已支付/全部）
+
+	TopMerchants []*database.MerchantOrderStats `json:"top_merchants"` // 收入最高的商户
+
+	NotifySuccess   int                    `json:"notify_success"`   // 通知成功次数
+	NotifyFailed    int                    `json:"notify_failed"`    // 通知失败次数
+	NotifyPending   int                    `json:"notify_pending"`   // 已支付但尚未通知成功的订单数
+	NotifyFailures  []*model.NotifySummary `json:"notify_failures"`  // 有通知失败的商户
+	CrashReports    int                    `json:"crash_reports"`    // 请求处理崩溃次数
+	MonitorHealth   string                 `json:"monitor_health"`   // 发送时的监听状态（healthy/degraded/paused/stopped）
+	MonitorFailures int                    `json:"monitor_failures"` // 发送时支付宝接口连续失败次数
+	UnhealthyNotify int                    `json:"unhealthy_notify"` // 发送时探测不可达的商户通知地址数
+}
+
+// DailyDigestService 运营日报
+// 每天在配置的整点汇总前24小时的统计数据，通过通知渠道发送给运营人员
+type DailyDigestService struct {
+	db           *database.DB
+	notifier     *notifier.Notifier
+	hour         int
+	topMerchants int
+	stopCh       chan struct{}
+
+	monitor      *MonitorService      // 监听服务（未设置时日报不含监听状态）
+	notifyHealth *NotifyHealthChecker // 通知地址健康检查（未启用时为nil）
+	leader       *LeaderElector       // 主节点选举（未启用时为nil）
+}
+
+// NewDailyDigestService 创建运营日报服务
+func NewDailyDigestService(db *database.DB, n *notifier.Notifier, hour, topMerchants int) *DailyDigestService {
+	return &DailyDigestService{
+		db:           db,
+		notifier:     n,
+		hour:         hour,
+		topMerchants: topMerchants,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// SetMonitor 设置监听服务（日报包含发送时的监听状态）
+func (s *DailyDigestService) SetMonitor(monitor *MonitorService) {
+	s.monitor = monitor
+}
+
+// SetNotifyHealth 设置通知地址健康检查（日报包含不可达的通知地址数）
+func (s *DailyDigestService) SetNotifyHealth(checker *NotifyHealthChecker) {
+	s.notifyHealth = checker
+}
+
+// SetLeaderElector 设置主节点选举（多实例部署时只在主节点发送日报）
+func (s *DailyDigestService) SetLeaderElector(leader *LeaderElector) {
+	s.leader = leader
+}
+
+// Start 启动运营日报服务
+func (s *DailyDigestService) Start() {
+	go s.run()
+	logger.Info("Daily digest started",
+		zap.Int("hour", s.hour),
+		zap.Int("channels", s.notifier.Len()))
+}
+
+// Stop 停止运营日报服务
+func (s *DailyDigestService) Stop() {
+	close(s.stopCh)
+	logger.Info("Daily digest stopped")
+}
+
+// run 每分钟检查是否到达发送时间
+func (s *DailyDigestService) run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if now.Hour() == s.hour && s.leader.IsLeader() {
+				s.sendDaily(now)
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// sendDaily 当天尚未发送时发送日报
+// 发送前记录日期，部分渠道失败时不重试，避免其他渠道重复收到
+func (s *DailyDigestService) sendDaily(now time.Time) {
+	today := now.Format("2006-01-02")
+	last, err := s.db.GetSetting(dailyDigestSentKey)
+	if err != nil {
+		logger.Error("Failed to read daily digest state", zap.Error(err))
+		return
+	}
+	if last == today {
+		return
+	}
+	if err := s.db.SetSetting(dailyDigestSentKey, today); err != nil {
+		logger.Error("Failed to save daily digest state", zap.Error(err))
+		return
+	}
+
+	start := time.Now()
+	err = s.Send(now)
+	RecordJobRun(s.db, JobDailyDigest, model.JobTriggerSchedule, start, "digest for "+today, true, err)
+	if err != nil {
+		logger.Error("Failed to send daily digest", zap.Error(err))
+		return
+	}
+	logger.Info("Daily digest sent", zap.String("date", today))
+}
+
+// Send 立即汇总截至 until 的前24小时并发送
+// @param until 统计区间结束时间
+// @return error 统计失败或任一渠道发送失败
+func (s *DailyDigestService) Send(until time.Time) error {
+	digest, err := s.Build(until)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return s.notifier.Send(ctx, digest.Message())
+}
+
+// Build 汇总截至 until 的前24小时的统计数据
+// @param until 统计区间结束时间
+// @return *DailyDigest 日报内容
+// @return error 查询错误
+func (s *DailyDigestService) Build(until time.Time) (*DailyDigest, error) {
+	digest := &DailyDigest{
+		Since: until.Add(-24 * time.Hour),
+		Until: until,
+	}
+
+	merchants, err := s.db.GetMerchantOrderStats(digest.Since)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range merchants {
+		digest.Orders += m.Total
+		digest.Paid += m.Paid
+		digest.Revenue += m.Revenue
+	}
+	if digest.Orders > 0 {
+		digest.SuccessRate = math.Round(float64(digest.Paid)/float64(digest.Orders)*10000) / 10000
+	}
+	for _, m := range merchants {
+		if len(digest.TopMerchants) >= s.topMerchants || m.Paid == 0 {
+			break
+		}
+		digest.TopMerchants = append(digest.TopMerchants, m)
+	}
+
+	summaries, err := s.db.GetNotifySummary(digest.Since)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		digest.NotifySuccess += summary.Success
+		digest.NotifyFailed += summary.Failed
+		digest.NotifyPending += summary.Pending
+		if summary.Failed > 0 {
+			digest.NotifyFailures = append(digest.NotifyFailures, summary)
+		}
+	}
+
+	if digest.CrashReports, err = s.db.CountCrashReportsSince(digest.Since); err != nil {
+		return nil, err
+	}
+
+	if s.monitor != nil {
+		status := s.monitor.GetMonitorStatus()
+		digest.MonitorHealth, _ = status["health_status"].(string)
+		digest.MonitorFailures, _ = status["api_failure_count"].(int)
+	}
+	if s.notifyHealth != nil {
+		for _, result := range s.notifyHealth.Results() {
+			if !result.Healthy {
+				digest.UnhealthyNotify++
+			}
+		}
+	}
+
+	return digest, nil
+}
+
+// Message 日报消息（Markdown）
+func (d *DailyDigest) Message() notifier.Message {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n\n", args...)
+	}
+
+	line("统计区间：%s ~ %s", d.Since.Format("2006-01-02 15:04"), d.Until.Format("2006-01-02 15:04"))
+
+	line("**订单**")
+	line("- 订单数：%d，已支付：%d，成功率：%.2f%%", d.Orders, d.Paid, d.SuccessRate*100)
+	line("- 实收金额：%s 元", d.Revenue)
+
+	if len(d.TopMerchants) > 0 {
+		line("**收入最高的商户**")
+		for i, m := range d.TopMerchants {
+			line("%d. %s：%s 元（%d/%d 笔）", i+1, m.PID, m.Revenue, m.Paid, m.Total)
+		}
+	}
+
+	line("**商户通知**")
+	line("- 成功：%d 次，失败：%d 次，待通知订单：%d", d.NotifySuccess, d.NotifyFailed, d.NotifyPending)
+	for _, summary := range d.NotifyFailures {
+		line("- %s 失败 %d 次：%s", summary.PID, summary.Failed, summary.LastError)
+	}
+
+	line("**异常**")
+	line("- 请求处理崩溃：%d 次", d.CrashReports)
+	if d.MonitorHealth != "" {
+		line("- 账单监听：%s（支付宝接口连续失败 %d 次）", d.MonitorHealth, d.MonitorFailures)
+	}
+	if d.UnhealthyNotify > 0 {
+		line("- 不可达的商户通知地址：%d 个", d.UnhealthyNotify)
+	}
+
+	return notifier.Message{
+		Title: "AliMPay 运营日报 " + d.Until.Format("2006-01-02"),
+		Text:  strings.TrimSpace(b.String()),
+	}
+}
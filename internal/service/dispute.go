@@ -0,0 +1,111 @@
+// Package service 争议订单
+// @author AliMPay Team
+// @description 已支付订单被用户拒付或投诉时，商户通过签名接口或管理员在后台将订单标记为争议：
+// 争议期间冻结商户通知，订单统计的收入不含争议订单；争议只能由管理员解除
+package service
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// maxDisputeReasonLength 争议原因的最大长度（字符）
+const maxDisputeReasonLength = 256
+
+// 争议订单错误（错误信息直接返回给商户）
+var (
+	ErrInvalidDispute      = errors.New("invalid dispute request")
+	ErrDisputeOrderNotPaid = errors.New("only paid orders can be disputed")
+	ErrOrderDisputed       = errors.New("order is disputed, notifications are frozen")
+)
+
+// DisputeOrder 商户将订单标记为争议（商户凭据由调用方验证）
+// @param pid 商户ID
+// @param tradeNo 订单号（与商户订单号二选一）
+// @param outTradeNo 商户订单号
+// @param reason 争议原因
+// @return *model.Order 订单
+// @return bool 是否为本次标记（订单已是争议状态时为false）
+func (s *CodePayService) DisputeOrder(pid, tradeNo, outTradeNo, reason string) (*model.Order, bool, error) {
+	if tradeNo == "" && outTradeNo == "" {
+		return nil, false, fmt.Errorf("%w: missing trade_no or out_trade_no", ErrInvalidDispute)
+	}
+
+	order, err := s.merchantOrder(pid, tradeNo, outTradeNo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed, err := s.setOrderDisputed(order, true, "商户", reason)
+	return order, changed, err
+}
+
+// SetOrderDispute 管理员标记或解除订单争议
+// @param tradeNo 订单号
+// @param disputed true为标记争议，false为解除争议
+// @param reason 原因（标记时为争议原因，解除时为处理结果）
+// @return *model.Order 订单
+// @return bool 争议标记是否变化（重复操作时为false）
+func (s *CodePayService) SetOrderDispute(tradeNo string, disputed bool, reason string) (*model.Order, bool, error) {
+	order, err := s.db.GetOrderByID(tradeNo)
+	if err != nil {
+		return nil, false, err
+	}
+	if order == nil {
+		return nil, false, ErrOrderNotFound
+	}
+
+	changed, err := s.setOrderDisputed(order, disputed, "管理后台", reason)
+	return order, changed, err
+}
+
+// setOrderDisputed 更新争议标记并记录订单事件
+// 只有已支付订单可以标记争议，解除争议不限订单状态（争议期间可能已退款）
+func (s *CodePayService) setOrderDisputed(order *model.Order, disputed bool, source, reason string) (bool, error) {
+	if utf8.RuneCountInString(reason) > maxDisputeReasonLength {
+		return false, fmt.Errorf("%w: reason must not exceed %d characters", ErrInvalidDispute, maxDisputeReasonLength)
+	}
+	if order.Disputed == disputed {
+		return false, nil
+	}
+	if disputed && order.Status != model.OrderStatusPaid {
+		return false, ErrDisputeOrderNotPaid
+	}
+
+	changed, err := s.db.SetOrderDisputed(order.ID, disputed)
+	if err != nil {
+		return false, err
+	}
+	order.Disputed = disputed
+	if !changed {
+		return false, nil
+	}
+
+	event := model.OrderEventDisputed
+	if !disputed {
+		event = model.OrderEventDisputeResolved
+	}
+	s.db.RecordOrderEvent(order.ID, event, fmt.Sprintf("来源: %s, 原因: %s", source, reason))
+
+	logger.Info("Order dispute flag changed",
+		zap.String("trade_no", order.ID),
+		zap.String("out_trade_no", order.OutTradeNo),
+		zap.Bool("disputed", disputed),
+		zap.String("source", source))
+	return true, nil
+}
+
+// orderDisputed 按数据库中的最新状态判断订单是否为争议订单（查询失败时按订单参数判断）
+func (s *CodePayService) orderDisputed(order *model.Order) bool {
+	current, err := s.db.GetOrderByID(order.ID)
+	if err != nil || current == nil {
+		return order.Disputed
+	}
+	return current.Disputed
+}
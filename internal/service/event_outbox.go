@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// outboxPruneInterval 清理超过保留期事件的间隔
+const outboxPruneInterval = time.Hour
+
+// MaxOutboxReplay 单次补拉的最大事件数
+const MaxOutboxReplay = 500
+
+// EventOutbox 事件发件箱
+// 本节点发布的订单事件在处理器执行前写入 event_outbox 表并分配递增序号，
+// 外部消费者（Webhook、Kafka 同步等）保存已处理的序号，故障恢复后从该序号补拉，无需全量对账
+type EventOutbox struct {
+	db        *database.DB
+	retention time.Duration
+	stopCh    chan struct{}
+	leader    *LeaderElector // 主节点选举（未启用时为nil）
+
+	appended atomic.Int64 // 本次启动以来写入的事件数
+	failed   atomic.Int64 // 写入失败的事件数
+}
+
+// OutboxReplay 事件补拉结果
+type OutboxReplay struct {
+	Events    []*model.OutboxEvent
+	NextAfter int64 // 下次补拉使用的 after（本批最后一个事件的序号，无事件时为请求的 after）
+	HasMore   bool  // 是否还有更多事件
+	FirstSeq  int64 // 发件箱中最早的事件序号
+	LastSeq   int64 // 发件箱中最新的事件序号
+	Gap       bool  // after 之后的部分事件已超过保留期被清理，消费者需对账补齐
+}
+
+// NewEventOutbox 创建事件发件箱
+// @param db 数据库
+// @param retention 事件保留时长
+// @return *EventOutbox 事件发件箱
+func NewEventOutbox(db *database.DB, retention time.Duration) *EventOutbox {
+	return &EventOutbox{
+		db:        db,
+		retention: retention,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetLeaderElector 设置主节点选举（多实例部署时定时清理只在主节点执行，事件记录不受影响）
+func (o *EventOutbox) SetLeaderElector(leader *LeaderElector) {
+	o.leader = leader
+}
+
+// Start 开始记录事件并启动定时清理
+func (o *EventOutbox) Start() {
+	events.SetRecorder(o.record)
+	go o.run()
+	logger.Info("Event outbox started", zap.Duration("retention", o.retention))
+}
+
+// Stop 停止记录事件和定时清理
+func (o *EventOutbox) Stop() {
+	events.SetRecorder(nil)
+	close(o.stopCh)
+	logger.Info("Event outbox stopped")
+}
+
+// record 写入发件箱（写入失败仅记录日志，不影响事件处理）
+// @param eventType 事件类型
+// @param data 事件数据（订单）
+func (o *EventOutbox) record(eventType string, data interface{}) {
+	order, ok := data.(*model.Order)
+	if !ok || order == nil {
+		return
+	}
+
+	payload, err := json.Marshal(order)
+	if err != nil {
+		o.failed.Add(1)
+		logger.Warn("Failed to encode outbox event",
+			zap.String("event_type", eventType),
+			zap.String("order_id", order.ID),
+			zap.Error(err))
+		return
+	}
+
+	event := &model.OutboxEvent{
+		Type:      eventType,
+		OrderID:   order.ID,
+		PID:       order.PID,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	}
+	if err := o.db.AppendOutboxEvent(event); err != nil {
+		o.failed.Add(1)
+		logger.Warn("Failed to append outbox event",
+			zap.String("event_type", eventType),
+			zap.String("order_id", order.ID),
+			zap.Error(err))
+		return
+	}
+	o.appended.Add(1)
+}
+
+// Replay 补拉序号大于 after 的事件
+// @param after 消费者已处理的最后一个事件序号（首次补拉为0）
+// @param pid 商户ID（只返回该商户的订单事件）
+// @param types 事件类型，为空时返回全部类型
+// @param limit 最多返回的事件数（超过 MaxOutboxReplay 时按 MaxOutboxReplay）
+// @return *OutboxReplay 补拉结果
+func (o *EventOutbox) Replay(after int64, pid string, types []string, limit int) (*OutboxReplay, error) {
+	if limit <= 0 || limit > MaxOutboxReplay {
+		limit = MaxOutboxReplay
+	}
+
+	// 多查询一条判断是否还有更多事件
+	list, err := o.db.GetOutboxEvents(after, pid, types, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	first, last, err := o.db.GetOutboxRange()
+	if err != nil {
+		return nil, err
+	}
+
+	replay := &OutboxReplay{
+		Events:    list,
+		NextAfter: after,
+		FirstSeq:  first,
+		LastSeq:   last,
+		Gap:       first > 0 && after < first-1,
+	}
+	if len(list) > limit {
+		replay.Events = list[:limit]
+		replay.HasMore = true
+	}
+	if n := len(replay.Events); n > 0 {
+		replay.NextAfter = replay.Events[n-1].Seq
+	}
+	return replay, nil
+}
+
+// run 定时清理超过保留期的事件
+func (o *EventOutbox) run() {
+	ticker := time.NewTicker(outboxPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.prune()
+		case <-o.stopCh:
+			return
+		}
+	}
+}
+
+// prune 清理超过保留期的事件（非主节点跳过）
+func (o *EventOutbox) prune() {
+	if !o.leader.IsLeader() {
+		return
+	}
+
+	pruned, err := o.db.PruneOutboxEvents(time.Now().Add(-o.retention))
+	if err != nil {
+		logger.Error("Failed to prune outbox events", zap.Error(err))
+		return
+	}
+	if pruned > 0 {
+		logger.Info("Pruned outbox events", zap.Int64("count", pruned))
+	}
+}
+
+// Status 获取发件箱状态
+func (o *EventOutbox) Status() map[string]interface{} {
+	first, last, err := o.db.GetOutboxRange()
+	status := map[string]interface{}{
+		"retention_days": int(o.retention / (24 * time.Hour)),
+		"appended":       o.appended.Load(),
+		"failed":         o.failed.Load(),
+		"first_seq":      first,
+		"last_seq":       last,
+	}
+	if err != nil {
+		status["error"] = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 维护任务名称
+const (
+	JobCleanup        = "cleanup"
+	JobReconciliation = "reconciliation"
+	JobStats          = "stats"
+	JobBackup         = "backup"
+)
+
+// reconcileWindow 对账检查的支付时间范围
+const reconcileWindow = 24 * time.Hour
+
+// reconcileGrace 支付后多久仍未通知成功才重新通知
+const reconcileGrace = time.Minute
+
+// reconcileBatch 每次对账最多重新通知的订单数（其余订单在下次对账时处理）
+const reconcileBatch = 200
+
+// backupPrefix 备份文件名前缀（清理旧备份时只删除此前缀的文件）
+const backupPrefix = "alimpay-"
+
+// 维护任务错误
+var (
+	ErrUnknownJob = errors.New("unknown housekeeping job")
+	ErrJobRunning = errors.New("housekeeping job is already running")
+)
+
+// housekeepingJob 维护任务
+type housekeepingJob struct {
+	name    string
+	spec    string                 // cron表达式，为空时不定时执行
+	run     func() (string, error) // 返回执行结果摘要
+	running bool                   // 是否正在执行（防止定时任务重叠或与手动触发同时执行）
+}
+
+// Housekeeping 维护任务
+// 清理、对账、统计汇总、备份各自按配置的cron表达式在监听服务的调度器上执行，
+// 同一任务上次未执行完时跳过本次；多实例部署时定时任务只在主节点执行
+type Housekeeping struct {
+	cfg     *config.HousekeepingConfig
+	db      *database.DB
+	codepay *CodePayService
+	monitor *MonitorService
+	leader  *LeaderElector // 主节点选举（未启用时为nil）
+
+	jobs map[string]*housekeepingJob
+	mu   sync.Mutex
+}
+
+// NewHousekeeping 创建维护任务
+// @param cfg 维护任务配置
+// @param db 数据库实例
+// @param codepay 码支付服务（对账时重新通知）
+// @param monitor 监听服务（清理过期订单，提供定时任务调度器）
+// @return *Housekeeping 维护任务
+func NewHousekeeping(cfg *config.HousekeepingConfig, db *database.DB, codepay *CodePayService, monitor *MonitorService) *Housekeeping {
+	h := &Housekeeping{
+		cfg:     cfg,
+		db:      db,
+		codepay: codepay,
+		monitor: monitor,
+	}
+	h.jobs = map[string]*housekeepingJob{
+		JobCleanup:        {name: JobCleanup, spec: cfg.Cleanup, run: h.cleanup},
+		JobReconciliation: {name: JobReconciliation, spec: cfg.Reconciliation, run: h.reconcile},
+		JobStats:          {name: JobStats, spec: cfg.Stats, run: h.aggregateStats},
+		JobBackup:         {name: JobBackup, spec: cfg.Backup, run: h.backup},
+	}
+	return h
+}
+
+// SetLeaderElector 设置主节点选举（多实例部署时定时任务只在主节点执行，手动触发不受影响）
+// @param leader 主节点选举
+func (h *Housekeeping) SetLeaderElector(leader *LeaderElector) {
+	h.leader = leader
+}
+
+// Start 在监听服务的调度器上注册配置了cron表达式的任务
+// @return error 注册失败
+func (h *Housekeeping) Start() error {
+	for _, name := range h.Jobs() {
+		job := h.jobs[name]
+		if job.spec == "" {
+			continue
+		}
+		if err := h.monitor.Schedule(job.spec, func() { h.runScheduled(name) }); err != nil {
+			return fmt.Errorf("housekeeping.%s: %w", name, err)
+		}
+		logger.Info("Housekeeping job scheduled", zap.String("job", name), zap.String("spec", job.spec))
+	}
+	return nil
+}
+
+// Jobs 全部维护任务名称
+func (h *Housekeeping) Jobs() []string {
+	names := make([]string, 0, len(h.jobs))
+	for name := range h.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runScheduled 定时执行并记录执行结果（非主节点跳过）
+func (h *Housekeeping) runScheduled(name string) {
+	if !h.leader.IsLeader() {
+		return
+	}
+
+	start := time.Now()
+	result, started, err := h.Run(name)
+	RecordJobRun(h.db, name, model.JobTriggerSchedule, start, result, started, err)
+	if !started {
+		logger.Warn("Housekeeping job skipped, previous run still in progress", zap.String("job", name))
+		return
+	}
+	if err != nil {
+		logger.Error("Housekeeping job failed",
+			zap.String("job", name),
+			zap.Duration("duration", time.Since(start)),
+			zap.Error(err))
+		return
+	}
+	logger.Info("Housekeeping job completed",
+		zap.String("job", name),
+		zap.String("result", result),
+		zap.Duration("duration", time.Since(start)))
+}
+
+// Run 立即执行一次维护任务
+// @param name 任务名称
+// @return string 执行结果摘要
+// @return bool 是否已执行（同一任务正在执行时返回false）
+// @return error 执行错误
+func (h *Housekeeping) Run(name string) (string, bool, error) {
+	job, ok := h.jobs[name]
+	if !ok {
+		return "", false, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	if !h.acquire(job) {
+		return "", false, nil
+	}
+	defer h.release(job)
+
+	result, err := job.run()
+	return result, true, err
+}
+
+// Trigger 在后台执行一次维护任务（手动触发）
+// 立即写入状态为 running 的执行记录并返回，执行完成后更新该记录，可按记录ID查询进度
+// @param name 任务名称
+// @return *model.JobRun 执行记录
+// @return error 任务不存在（ErrUnknownJob）、正在执行（ErrJobRunning）或写入记录失败
+func (h *Housekeeping) Trigger(name string) (*model.JobRun, error) {
+	job, ok := h.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	if !h.acquire(job) {
+		return nil, ErrJobRunning
+	}
+
+	run := &model.JobRun{
+		Name:      name,
+		Trigger:   model.JobTriggerManual,
+		Status:    model.JobStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := h.db.CreateJobRun(run); err != nil {
+		h.release(job)
+		return nil, err
+	}
+
+	snapshot := *run
+	go func() {
+		defer h.release(job)
+
+		result, err := job.run()
+		run.Result = result
+		run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+		run.Status = model.JobStatusSuccess
+		if err != nil {
+			run.Status = model.JobStatusFailed
+			run.Error = err.Error()
+			logger.Error("Housekeeping job failed", zap.String("job", name), zap.String("trigger", run.Trigger), zap.Error(err))
+		} else {
+			logger.Info("Housekeeping job completed", zap.String("job", name), zap.String("trigger", run.Trigger), zap.String("result", result))
+		}
+		if err := h.db.UpdateJobRun(run); err != nil {
+			logger.Error("Failed to record job run", zap.String("job", name), zap.Error(err))
+		}
+	}()
+	return &snapshot, nil
+}
+
+// acquire 标记任务开始执行，任务正在执行时返回false
+func (h *Housekeeping) acquire(job *housekeepingJob) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if job.running {
+		return false
+	}
+	job.running = true
+	return true
+}
+
+// release 标记任务执行结束
+func (h *Housekeeping) release(job *housekeepingJob) {
+	h.mu.Lock()
+	job.running = false
+	h.mu.Unlock()
+}
+
+// cleanup 清理过期订单和过期的匹配记录
+func (h *Housekeeping) cleanup() (string, error) {
+	count, err := h.monitor.Cleanup()
+	return fmt.Sprintf("expired %d orders", count), err
+}
+
+// reconcile 对账：近24小时已支付但商户尚未确认通知的订单重新提交通知
+func (h *Housekeeping) reconcile() (string, error) {
+	orders, err := h.db.GetUnnotifiedPaidOrders(time.Now().Add(-reconcileWindow), reconcileBatch)
+	if err != nil {
+		return "", err
+	}
+
+	queued := 0
+	for _, order := range orders {
+		// 刚支付的订单由自动回调发送
+		if order.PayTime != nil && time.Since(*order.PayTime) < reconcileGrace {
+			continue
+		}
+		// 队列已满时停止，剩余订单在下次对账时处理
+		if err := h.codepay.QueueNotification(order); err != nil {
+			break
+		}
+		queued++
+	}
+	return fmt.Sprintf("queued %d of %d unnotified orders", queued, len(orders)), nil
+}
+
+// aggregateStats 汇总当天和前一天的每日订单统计（前一天的订单在凌晨仍可能支付或过期）
+func (h *Housekeeping) aggregateStats() (string, error) {
+	now := time.Now()
+	merchants := 0
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		count, err := h.db.RebuildDailyStats(day)
+		if err != nil {
+			return "", err
+		}
+		merchants += count
+	}
+	return fmt.Sprintf("aggregated %d merchant-days", merchants), nil
+}
+
+// backup 备份数据库，并删除超出保留数量的旧备份
+func (h *Housekeeping) backup() (string, error) {
+	path := filepath.Join(h.cfg.BackupDir, backupPrefix+time.Now().Format("20060102-150405")+".db")
+	if err := h.db.Backup(path); err != nil {
+		return "", err
+	}
+
+	removed, err := h.pruneBackups()
+	if err != nil {
+		return "", fmt.Errorf("backup saved to %s, but failed to remove old backups: %w", path, err)
+	}
+	return fmt.Sprintf("saved %s, removed %d old backups", path, removed), nil
+}
+
+// pruneBackups 只保留最近 BackupKeep 个备份
+func (h *Housekeeping) pruneBackups() (int, error) {
+	entries, err := os.ReadDir(h.cfg.BackupDir)
+	if err != nil {
+		return 0, err
+	}
+
+	// 文件名含时间，按名称排序即按时间排序
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), backupPrefix) && strings.HasSuffix(entry.Name(), ".db") {
+			backups = append(backups, entry.Name())
+		}
+	}
+	sort.Strings(backups)
+
+	removed := 0
+	for len(backups)-removed > h.cfg.BackupKeep {
+		if err := os.Remove(filepath.Join(h.cfg.BackupDir, backups[removed])); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
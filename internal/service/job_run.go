@@ -0,0 +1,47 @@
+package service
+
+import (
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 其他后台任务名称（维护任务名称见 housekeeping.go）
+const (
+	JobArchive     = "archive"
+	JobDailyDigest = "daily_digest"
+)
+
+// RecordJobRun 记录一次后台任务执行（写入失败只记录日志，不影响任务本身）
+// @param db 数据库实例
+// @param name 任务名称
+// @param trigger 触发方式（model.JobTriggerSchedule/model.JobTriggerManual）
+// @param start 开始时间
+// @param result 执行结果摘要
+// @param started 是否已执行（false表示上次执行尚未完成，本次跳过）
+// @param err 执行错误
+func RecordJobRun(db *database.DB, name, trigger string, start time.Time, result string, started bool, err error) {
+	run := &model.JobRun{
+		Name:       name,
+		Trigger:    trigger,
+		Status:     model.JobStatusSuccess,
+		Result:     result,
+		StartedAt:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	switch {
+	case !started:
+		run.Status = model.JobStatusSkipped
+	case err != nil:
+		run.Status = model.JobStatusFailed
+		run.Error = err.Error()
+	}
+
+	if err := db.CreateJobRun(run); err != nil {
+		logger.Error("Failed to record job run", zap.String("job", name), zap.Error(err))
+	}
+}
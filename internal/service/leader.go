@@ -0,0 +1,130 @@
+// Package service 主节点选举
+// @author AliMPay Team
+// @description 多实例部署时通过租约（文件或Redis）选举主节点，监听周期、自动回调、
+// 通知地址健康检查和订单归档等定时任务只在主节点执行；主节点异常退出后租约过期，由其他实例接管
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"alimpay-go/internal/pkg/lock"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// LeaderElector 主节点选举
+// 未启用选举时为nil，IsLeader 对nil返回true（单实例部署时所有定时任务照常执行）
+type LeaderElector struct {
+	lease      lock.Lease
+	instanceID string
+	ttl        time.Duration
+	leader     atomic.Bool
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewLeaderElector 创建主节点选举
+// @param lease 租约实现（文件租约或Redis租约）
+// @param instanceID 当前实例标识
+// @param ttl 租约有效期，每 ttl/3 续约一次
+func NewLeaderElector(lease lock.Lease, instanceID string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{
+		lease:      lease,
+		instanceID: instanceID,
+		ttl:        ttl,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start 启动选举（立即尝试获取租约）
+func (e *LeaderElector) Start() {
+	e.campaign()
+
+	e.wg.Add(1)
+	go e.run()
+
+	logger.Info("Leader election started",
+		zap.String("instance_id", e.instanceID),
+		zap.Duration("lease_ttl", e.ttl),
+		zap.Bool("leader", e.IsLeader()))
+}
+
+// Stop 停止选举，当前为主节点时释放租约，其他实例无需等待租约过期即可接管
+func (e *LeaderElector) Stop() {
+	close(e.stopCh)
+	e.wg.Wait()
+
+	if e.leader.Swap(false) {
+		if err := e.lease.Release(); err != nil {
+			logger.Warn("Failed to release leader lease", zap.Error(err))
+		}
+	}
+	logger.Info("Leader election stopped", zap.String("instance_id", e.instanceID))
+}
+
+// IsLeader 当前实例是否为主节点（未启用选举时始终为true）
+func (e *LeaderElector) IsLeader() bool {
+	return e == nil || e.leader.Load()
+}
+
+// InstanceID 当前实例标识
+func (e *LeaderElector) InstanceID() string {
+	return e.instanceID
+}
+
+// Status 选举状态（用于健康检查）
+func (e *LeaderElector) Status() map[string]interface{} {
+	holder, err := e.lease.Holder()
+	status := map[string]interface{}{
+		"instance_id": e.instanceID,
+		"leader":      e.IsLeader(),
+		"leader_id":   holder,
+		"lease_ttl":   int(e.ttl.Seconds()),
+	}
+	if err != nil {
+		status["error"] = err.Error()
+	}
+	return status
+}
+
+// run 定期续约或竞选
+func (e *LeaderElector) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.campaign()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// campaign 获取或续约租约，记录主节点变化
+// 租约后端不可用时放弃主节点身份（宁可暂停定时任务，也不在多个实例上重复执行）
+func (e *LeaderElector) campaign() {
+	acquired, err := e.lease.Acquire()
+	if err != nil {
+		logger.Error("Failed to acquire leader lease",
+			zap.String("instance_id", e.instanceID),
+			zap.Error(err))
+		acquired = false
+	}
+
+	if was := e.leader.Swap(acquired); was != acquired {
+		if acquired {
+			logger.Success("This instance is now the leader, scheduled jobs enabled",
+				zap.String("instance_id", e.instanceID))
+		} else {
+			logger.Warn("This instance is no longer the leader, scheduled jobs paused",
+				zap.String("instance_id", e.instanceID))
+		}
+	}
+}
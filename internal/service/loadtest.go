@@ -0,0 +1,340 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// LoadTestOutTradeNoPrefix 压测订单的商户订单号前缀
+const LoadTestOutTradeNoPrefix = "LOADTEST"
+
+// LoadTestNotifyPath 压测订单的通知地址（本服务内的空接收端）
+const LoadTestNotifyPath = "/loadtest/notify"
+
+// ErrLoadTestRunning 已有压测正在生成订单
+var ErrLoadTestRunning = errors.New("load test is already running")
+
+// IsLoadTestOrder 判断订单是否由压测生成
+func IsLoadTestOrder(order *model.Order) bool {
+	return strings.HasPrefix(order.OutTradeNo, LoadTestOutTradeNoPrefix)
+}
+
+// SyntheticBillSource 模拟账单来源
+// @description 压测时代替支付宝账单接口，为压测订单提供可匹配的账单（按商户订单号索引，避免大量订单时逐条比对）
+type SyntheticBillSource struct {
+	bills map[string]BillRecord
+	mu    sync.RWMutex
+}
+
+// NewSyntheticBillSource 创建模拟账单来源
+func NewSyntheticBillSource() *SyntheticBillSource {
+	return &SyntheticBillSource{bills: make(map[string]BillRecord)}
+}
+
+// Add 为订单添加一条模拟账单
+func (s *SyntheticBillSource) Add(outTradeNo string, bill BillRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bills[outTradeNo] = bill
+}
+
+// BillsFor 获取订单对应的模拟账单
+func (s *SyntheticBillSource) BillsFor(order *model.Order) []BillRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if bill, ok := s.bills[order.OutTradeNo]; ok {
+		return []BillRecord{bill}
+	}
+	return nil
+}
+
+// Remove 删除订单对应的模拟账单
+func (s *SyntheticBillSource) Remove(outTradeNo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bills, outTradeNo)
+}
+
+// Clear 清空模拟账单
+func (s *SyntheticBillSource) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bills = make(map[string]BillRecord)
+}
+
+// LoadTestRun 一次压测的运行状态
+type LoadTestRun struct {
+	ID                 string     `json:"id"`
+	Orders             int        `json:"orders"`      // 计划生成的订单数
+	PayRatio           float64    `json:"pay_ratio"`   // 生成模拟账单（模拟支付）的订单比例
+	Concurrency        int        `json:"concurrency"` // 并发创建数
+	Creating           bool       `json:"creating"`    // 是否仍在创建订单
+	StartedAt          time.Time  `json:"started_at"`
+	CreationFinishedAt *time.Time `json:"creation_finished_at,omitempty"`
+	CreationRate       float64    `json:"creation_rate"` // 每秒创建订单数
+	Created            int        `json:"created"`
+	CreateFailed       int        `json:"create_failed"`
+	LastError          string     `json:"last_error,omitempty"`
+	BillsInjected      int        `json:"bills_injected"`
+	Paid               int        `json:"paid"`               // 已匹配到模拟账单的订单数
+	AvgPayLatencyMs    int64      `json:"avg_pay_latency_ms"` // 从创建到匹配的平均耗时
+	MaxPayLatencyMs    int64      `json:"max_pay_latency_ms"` // 从创建到匹配的最大耗时
+	NotifySuccess      int        `json:"notify_success"`     // 通知成功次数
+	NotifyFailed       int        `json:"notify_failed"`      // 通知失败次数
+	payLatencyTotal    time.Duration
+}
+
+// outTradeNoPrefix 本次压测订单的商户订单号前缀
+func (r *LoadTestRun) outTradeNoPrefix() string {
+	return LoadTestOutTradeNoPrefix + r.ID + "_"
+}
+
+// LoadTestService 压测服务
+// @description 生成模拟订单和模拟账单，经过真实的下单、监听Worker池、WebSocket推送和商户通知流程，
+// 用于在大促前了解系统容量。压测订单不调用支付宝接口。
+type LoadTestService struct {
+	cfg     *config.Config
+	db      *database.DB
+	codepay *CodePayService
+	bills   *SyntheticBillSource
+	run     *LoadTestRun
+	mu      sync.Mutex
+}
+
+// NewLoadTestService 创建压测服务
+// @description 向监听服务注册模拟账单来源，并订阅支付事件统计匹配耗时
+// @param cfg 配置
+// @param db 数据库
+// @param codepay 码支付服务
+// @param monitor 监听服务
+// @return *LoadTestService 压测服务
+func NewLoadTestService(cfg *config.Config, db *database.DB, codepay *CodePayService, monitor *MonitorService) *LoadTestService {
+	s := &LoadTestService{
+		cfg:     cfg,
+		db:      db,
+		codepay: codepay,
+		bills:   NewSyntheticBillSource(),
+	}
+
+	monitor.SetSyntheticBillSource(s.bills)
+	events.Subscribe(events.EventOrderPaid, s.onOrderPaid)
+
+	return s
+}
+
+// Start 开始一次压测
+// @description 异步生成订单，按比例注入模拟账单，立即返回运行状态
+// @param orders 订单数
+// @param payRatio 模拟支付比例（0-1）
+// @param baseURL 服务基础URL（用于支付链接和通知地址）
+// @return *LoadTestRun 运行状态
+// @return error 参数错误或已有压测在运行
+func (s *LoadTestService) Start(orders int, payRatio float64, baseURL string) (*LoadTestRun, error) {
+	if orders <= 0 || orders > s.cfg.LoadTest.MaxOrders {
+		return nil, fmt.Errorf("orders must be between 1 and %d", s.cfg.LoadTest.MaxOrders)
+	}
+	if payRatio < 0 || payRatio > 1 {
+		return nil, fmt.Errorf("pay_ratio must be between 0 and 1")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.run != nil && s.run.Creating {
+		return nil, ErrLoadTestRunning
+	}
+
+	run := &LoadTestRun{
+		ID:          time.Now().Format("20060102150405"),
+		Orders:      orders,
+		PayRatio:    payRatio,
+		Concurrency: s.cfg.LoadTest.Concurrency,
+		Creating:    true,
+		StartedAt:   time.Now(),
+	}
+	s.run = run
+
+	logger.Warn("Load test started",
+		zap.String("run_id", run.ID),
+		zap.Int("orders", orders),
+		zap.Float64("pay_ratio", payRatio),
+		zap.Int("concurrency", run.Concurrency))
+
+	go s.execute(run, baseURL)
+
+	snapshot := *run
+	return &snapshot, nil
+}
+
+// execute 并发创建订单并注入模拟账单
+func (s *LoadTestService) execute(run *LoadTestRun, baseURL string) {
+	payCount := int(math.Round(float64(run.Orders) * run.PayRatio))
+	notifyURL := strings.TrimRight(baseURL, "/") + LoadTestNotifyPath
+
+	sem := make(chan struct{}, run.Concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < run.Orders; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(seq int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			outTradeNo := fmt.Sprintf("%s%06d", run.outTradeNoPrefix(), seq)
+			// 随机金额，减少经营码模式下的金额调整
+			money := model.Amount(rand.Intn(99900) + 100).String()
+
+			result, err := s.codepay.createPayment(map[string]string{
+				"pid":          s.codepay.GetMerchantID(),
+				"type":         model.PaymentTypeAlipay,
+				"out_trade_no": outTradeNo,
+				"name":         "压测订单",
+				"money":        money,
+				"notify_url":   notifyURL,
+				"sitename":     "压测",
+			}, baseURL, "")
+
+			s.mu.Lock()
+			if err != nil {
+				run.CreateFailed++
+				run.LastError = err.Error()
+			} else {
+				run.Created++
+			}
+			s.mu.Unlock()
+
+			if err != nil || seq >= payCount {
+				return
+			}
+
+			s.injectBill(run, outTradeNo, money, result)
+		}(i)
+	}
+
+	wg.Wait()
+
+	s.mu.Lock()
+	finishedAt := time.Now()
+	run.Creating = false
+	run.CreationFinishedAt = &finishedAt
+	if elapsed := finishedAt.Sub(run.StartedAt).Seconds(); elapsed > 0 {
+		run.CreationRate = math.Round(float64(run.Created)/elapsed*100) / 100
+	}
+	s.mu.Unlock()
+
+	logger.Warn("Load test orders created",
+		zap.String("run_id", run.ID),
+		zap.Int("created", run.Created),
+		zap.Int("failed", run.CreateFailed),
+		zap.Float64("orders_per_second", run.CreationRate))
+}
+
+// injectBill 为订单注入一条可匹配的模拟账单
+func (s *LoadTestService) injectBill(run *LoadTestRun, outTradeNo, money string, result map[string]interface{}) {
+	var amount model.Amount
+	if s.cfg.Payment.BusinessQRMode.Enabled {
+		// 经营码模式按实际支付金额匹配
+		amount, _ = result["payment_amount"].(model.Amount)
+	} else {
+		amount, _ = model.ParseAmount(money)
+	}
+
+	s.bills.Add(outTradeNo, BillRecord{
+		TradeNo: strings.Replace(outTradeNo, LoadTestOutTradeNoPrefix, LoadTestOutTradeNoPrefix+"BILL", 1),
+		Amount:  amount,
+		Remark:  outTradeNo,
+		// 账单时间精确到秒，需晚于订单创建时间才能匹配
+		TransDate: time.Now().Add(time.Second).Format("2006-01-02 15:04:05"),
+		Direction: "收入",
+	})
+
+	s.mu.Lock()
+	run.BillsInjected++
+	s.mu.Unlock()
+}
+
+// onOrderPaid 统计压测订单的匹配耗时
+func (s *LoadTestService) onOrderPaid(data interface{}) {
+	order, ok := data.(*model.Order)
+	if !ok || !IsLoadTestOrder(order) || order.PayTime == nil {
+		return
+	}
+
+	s.bills.Remove(order.OutTradeNo)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := s.run
+	if run == nil || !strings.HasPrefix(order.OutTradeNo, run.outTradeNoPrefix()) {
+		return
+	}
+
+	latency := order.PayTime.Sub(order.AddTime)
+	run.Paid++
+	run.payLatencyTotal += latency
+	run.AvgPayLatencyMs = (run.payLatencyTotal / time.Duration(run.Paid)).Milliseconds()
+	if ms := latency.Milliseconds(); ms > run.MaxPayLatencyMs {
+		run.MaxPayLatencyMs = ms
+	}
+}
+
+// Status 获取最近一次压测的运行状态（未运行过时返回nil）
+func (s *LoadTestService) Status() (*LoadTestRun, error) {
+	s.mu.Lock()
+	if s.run == nil {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	snapshot := *s.run
+	s.mu.Unlock()
+
+	success, failed, err := s.db.CountNotifyLogsByOutTradeNoPrefix(snapshot.outTradeNoPrefix())
+	if err != nil {
+		return nil, err
+	}
+	snapshot.NotifySuccess = success
+	snapshot.NotifyFailed = failed
+
+	return &snapshot, nil
+}
+
+// Cleanup 删除全部压测订单及模拟账单
+// @return int64 删除的订单数
+// @return error 压测仍在运行或删除失败
+func (s *LoadTestService) Cleanup() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.run != nil && s.run.Creating {
+		return 0, ErrLoadTestRunning
+	}
+
+	count, err := s.db.DeleteOrdersByOutTradeNoPrefix(LoadTestOutTradeNoPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	s.bills.Clear()
+	s.run = nil
+
+	logger.Info("Load test data cleaned up", zap.Int64("orders", count))
+	return count, nil
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"alimpay-go/internal/pkg/logger"
+)
+
+// TestMain 基准测试和模糊测试只保留致命错误日志（账单解析每次都会输出成功/失败日志）
+func TestMain(m *testing.M) {
+	if err := logger.Init(&logger.Config{Level: "fatal", Output: "stdout"}); err != nil {
+		fmt.Printf("Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,176 @@
+// Package service 商户密钥轮换
+// @author AliMPay Team
+// @description 商户密钥泄露时在管理后台生成新密钥：新密钥立即生效，旧密钥在过渡期内仍可验证商户请求的签名，
+// 商户更换密钥期间不中断下单和查询。轮换后的密钥保存在数据库中，重启后仍然有效；
+// 配置文件中的 merchant.key 被修改后，以配置文件为准
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// 商户密钥轮换的过渡期
+const (
+	DefaultKeyRotationGrace = 24 * time.Hour      // 默认过渡期
+	MaxKeyRotationGrace     = 30 * 24 * time.Hour // 最长过渡期
+)
+
+// ErrInvalidKeyRotationGrace 过渡期超出范围
+var ErrInvalidKeyRotationGrace = errors.New("grace must be between 0 and 30 days")
+
+// MerchantKeyRotation 商户密钥轮换结果
+type MerchantKeyRotation struct {
+	Key                  string     `json:"key"`                               // 新密钥
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"` // 旧密钥过渡期截止时间（过渡期为0时为空）
+}
+
+// loadRotatedMerchantKey 加载管理后台轮换生成的商户密钥
+// @description 轮换的密钥只在配置文件中的商户密钥未修改时使用；配置文件修改后（如运维手动更换密钥）以配置文件为准
+func (s *CodePayService) loadRotatedMerchantKey() error {
+	key, err := s.db.GetSetting(database.SettingMerchantKey)
+	if err != nil || key == "" {
+		return err
+	}
+	origin, err := s.db.GetSetting(database.SettingMerchantKeyOrigin)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case key == s.cfg.Merchant.Key:
+		// 配置文件已更新为轮换后的密钥，之后以此判断配置文件是否再次修改
+		if err := s.db.SetSetting(database.SettingMerchantKeyOrigin, merchantKeyDigest(key)); err != nil {
+			return err
+		}
+	case origin != merchantKeyDigest(s.cfg.Merchant.Key):
+		logger.Warn("merchant.key changed in config file, discarding rotated merchant key")
+		return s.db.SetSettings(map[string]string{
+			database.SettingMerchantKey:               "",
+			database.SettingMerchantKeyOrigin:         "",
+			database.SettingMerchantPreviousKey:       "",
+			database.SettingMerchantPreviousKeyExpiry: "",
+		})
+	}
+
+	previous, err := s.db.GetSetting(database.SettingMerchantPreviousKey)
+	if err != nil {
+		return err
+	}
+	expiry, err := s.db.GetSetting(database.SettingMerchantPreviousKeyExpiry)
+	if err != nil {
+		return err
+	}
+
+	s.merchantKey = key
+	if seconds, err := strconv.ParseInt(expiry, 10, 64); err == nil && previous != "" {
+		s.previousKey = previous
+		s.previousKeyExpiry = time.Unix(seconds, 0)
+	}
+
+	logger.Info("Loaded rotated merchant key",
+		zap.String("merchant_id", s.merchantID),
+		zap.Bool("previous_key_active", s.previousKeyActive()))
+	return nil
+}
+
+// RotateMerchantKey 轮换商户密钥
+// @description 新密钥立即用于验证请求和签名通知；旧密钥在过渡期内仍可验证商户请求（签名、key 参数），
+// 过渡期为0时旧密钥立即失效。再次轮换时，上一次轮换的旧密钥立即失效
+// @param grace 旧密钥的过渡期（0 ~ MaxKeyRotationGrace）
+// @return *MerchantKeyRotation 新密钥及旧密钥过渡期截止时间
+func (s *CodePayService) RotateMerchantKey(grace time.Duration) (*MerchantKeyRotation, error) {
+	if grace < 0 || grace > MaxKeyRotationGrace {
+		return nil, ErrInvalidKeyRotationGrace
+	}
+
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	// 记录首次轮换时配置文件中的密钥，用于判断配置文件是否被修改
+	origin, err := s.db.GetSetting(database.SettingMerchantKeyOrigin)
+	if err != nil {
+		return nil, err
+	}
+	if origin == "" {
+		origin = merchantKeyDigest(s.cfg.Merchant.Key)
+	}
+
+	rotation := &MerchantKeyRotation{Key: utils.GenerateMerchantKey()}
+	previous, expiry := "", ""
+	var expiresAt time.Time
+	if grace > 0 {
+		expiresAt = time.Now().Add(grace).Truncate(time.Second)
+		previous, expiry = s.merchantKey, strconv.FormatInt(expiresAt.Unix(), 10)
+		rotation.PreviousKeyExpiresAt = &expiresAt
+	}
+
+	err = s.db.SetSettings(map[string]string{
+		database.SettingMerchantKey:               rotation.Key,
+		database.SettingMerchantKeyOrigin:         origin,
+		database.SettingMerchantPreviousKey:       previous,
+		database.SettingMerchantPreviousKeyExpiry: expiry,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.merchantKey = rotation.Key
+	s.previousKey = previous
+	s.previousKeyExpiry = expiresAt
+
+	logger.Warn("Merchant key rotated",
+		zap.String("merchant_id", s.merchantID),
+		zap.Duration("grace", grace))
+	return rotation, nil
+}
+
+// PreviousMerchantKey 获取过渡期内的旧商户密钥
+// @return string 旧密钥（不在过渡期时为空）
+// @return time.Time 过渡期截止时间
+func (s *CodePayService) PreviousMerchantKey() (string, time.Time) {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	if !s.previousKeyActive() {
+		return "", time.Time{}
+	}
+	return s.previousKey, s.previousKeyExpiry
+}
+
+// previousKeyActive 旧密钥是否在过渡期内（调用方需持有 keyMu）
+func (s *CodePayService) previousKeyActive() bool {
+	return s.previousKey != "" && time.Now().Before(s.previousKeyExpiry)
+}
+
+// acceptPreviousKey 使用过渡期内的旧密钥验证正式商户的请求
+// @description 验证通过时记录日志，便于确认商户是否已更换密钥
+// @param pid 商户ID
+// @param verify 使用指定密钥验证请求
+// @return bool 是否通过验证
+func (s *CodePayService) acceptPreviousKey(pid string, verify func(key string) bool) bool {
+	if pid != s.merchantID {
+		return false
+	}
+	key, _ := s.PreviousMerchantKey()
+	if key == "" || !verify(key) {
+		return false
+	}
+
+	logger.Info("Request authenticated with previous merchant key", zap.String("pid", pid))
+	return true
+}
+
+// merchantKeyDigest 商户密钥的SHA-256摘要（数据库中不保存配置文件的密钥原文）
+func merchantKeyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -4,12 +4,12 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"alimpay-go/internal/config"
 	"alimpay-go/internal/database"
-	"alimpay-go/internal/events"
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/worker"
 	"alimpay-go/internal/pkg/lock"
@@ -22,13 +22,23 @@ import (
 // BillRecord 账单记录
 // @description 支付宝账单数据结构
 type BillRecord struct {
-	TradeNo   string  // 支付宝订单号
-	Amount    float64 // 金额
-	Remark    string  // 备注
-	TransDate string  // 交易时间
-	Direction string  // 方向（收入/支出）
+	TradeNo      string       // 支付宝订单号
+	AccountLogID string       // 账务流水号
+	Amount       model.Amount // 金额（分）
+	Remark       string       // 备注
+	TransDate    string       // 交易时间
+	Direction    string       // 方向（收入/支出）
 }
 
+// matchedBillRetention 已匹配账单记录保留时长（远大于账单查询窗口）
+const matchedBillRetention = 24 * time.Hour
+
+// errBillAlreadyMatched 账单已被其他订单使用
+var errBillAlreadyMatched = errors.New("bill already matched to another order")
+
+// monitorLockFile 监听周期文件锁路径
+const monitorLockFile = "./data/monitor.lock"
+
 // MonitorService 订单监听服务
 // @description 定期检查待支付订单，使用Worker池处理订单监听任务
 type MonitorService struct {
@@ -39,11 +49,17 @@ type MonitorService struct {
 	qrBillQueries    map[string]*BillQueryService // 二维码专属的账单查询服务 (qr_id -> service)
 	workerPool       *worker.Pool
 	cron             *cron.Cron
+	cronEntry        cron.EntryID
 	lockFile         string
+	locker           lock.Locker // 监听周期互斥锁（默认文件锁，多实例部署可替换为Redis锁）
 	isRunning        bool
 	apiFailureCount  int
 	lastSuccessTime  time.Time
 	monitoringPaused bool
+	syntheticBills   *SyntheticBillSource // 压测模式的模拟账单（未启用时为nil）
+
+	leader   *LeaderElector       // 主节点选举（未启用时为nil，定时监听周期只在主节点执行）
+	qrHealth *QRCodeHealthChecker // 收款码健康检查（未启用时为nil，记录专属接口的查询结果）
 }
 
 // NewMonitorService 创建监听服务
@@ -87,9 +103,10 @@ func NewMonitorService(cfg *config.Config, db *database.DB, codepay *CodePayServ
 	}
 
 	// 创建Worker池 - 使用固定数量的Worker避免创建过多goroutine
-	// workerCount: 5个Worker足够处理大部分场景
-	// queueSize: 队列大小为100，可容纳100个待处理订单
-	workerPool := worker.NewPool(5, 100)
+	// 默认5个Worker、队列大小100，足够处理大部分场景，可通过配置调整
+	// 关闭时先执行完排队中的任务，避免匹配任务在写入订单状态时被取消
+	workerPool := worker.NewPool(cfg.Monitor.WorkerCount, cfg.Monitor.QueueSize)
+	workerPool.SetDrainTimeout(cfg.Monitor.DrainTimeoutDuration())
 
 	return &MonitorService{
 		cfg:           cfg,
@@ -98,10 +115,49 @@ func NewMonitorService(cfg *config.Config, db *database.DB, codepay *CodePayServ
 		billQuery:     billQuery,
 		qrBillQueries: qrBillQueries,
 		workerPool:    workerPool,
-		lockFile:      "./data/monitor.lock",
+		lockFile:      monitorLockFile,
+		locker:        lock.NewFileLock(monitorLockFile, time.Duration(cfg.Monitor.LockTimeout)*time.Second),
 	}, nil
 }
 
+// SetLocker 替换监听周期使用的锁
+// @description 多实例部署时传入分布式锁，保证集群内同一时刻只有一个实例执行监听周期
+// @param locker 锁实现
+func (m *MonitorService) SetLocker(locker lock.Locker) {
+	if locker != nil {
+		m.locker = locker
+	}
+}
+
+// SetSyntheticBillSource 设置模拟账单来源
+// @description 压测模式下，压测订单只与模拟账单匹配，不调用支付宝接口
+// @param source 模拟账单来源
+func (m *MonitorService) SetSyntheticBillSource(source *SyntheticBillSource) {
+	m.syntheticBills = source
+}
+
+// SetLeaderElector 设置主节点选举
+// @description 多实例部署时定时监听周期只在主节点执行，手动触发不受影响
+// @param leader 主节点选举
+func (m *MonitorService) SetLeaderElector(leader *LeaderElector) {
+	m.leader = leader
+}
+
+// SetQRCodeHealth 设置收款码健康检查
+// @description 专属接口的每次账单查询结果交给健康检查，连续失败的收款码不再分配给新订单
+// @param checker 收款码健康检查
+func (m *MonitorService) SetQRCodeHealth(checker *QRCodeHealthChecker) {
+	m.qrHealth = checker
+}
+
+// runScheduledCycle 定时任务执行的监听周期（非主节点跳过）
+func (m *MonitorService) runScheduledCycle() {
+	if !m.leader.IsLeader() {
+		return
+	}
+	m.RunMonitoringCycle()
+}
+
 // Start 启动监听服务
 // @description 启动定时任务和Worker池
 // @return error 启动错误
@@ -114,20 +170,21 @@ func (m *MonitorService) Start() error {
 	// 启动Worker池
 	m.workerPool.Start()
 
-	// 创建定时任务
-	m.cron = cron.New()
+	// 创建定时任务（维护任务可能已在调度器上注册）
+	if m.cron == nil {
+		m.cron = cron.New()
+	}
 
-	interval := m.cfg.Monitor.Interval
+	interval := m.cfg.MonitorSnapshot().Interval
 	spec := fmt.Sprintf("@every %ds", interval)
 
-	_, err := m.cron.AddFunc(spec, func() {
-		m.RunMonitoringCycle()
-	})
+	entryID, err := m.cron.AddFunc(spec, m.runScheduledCycle)
 
 	if err != nil {
 		return fmt.Errorf("failed to add cron job: %w", err)
 	}
 
+	m.cronEntry = entryID
 	m.cron.Start()
 	m.isRunning = true
 
@@ -143,6 +200,78 @@ func (m *MonitorService) Start() error {
 	return nil
 }
 
+// SetInterval 调整监听周期
+// @description 配置热加载时使用，正在执行的监听周期不受影响
+// @param seconds 监听间隔（秒）
+// @return error 调整错误
+func (m *MonitorService) SetInterval(seconds int) error {
+	if seconds <= 0 {
+		return fmt.Errorf("invalid monitor interval: %d", seconds)
+	}
+
+	if m.cron != nil && m.cronEntry != 0 {
+		entryID, err := m.cron.AddFunc(fmt.Sprintf("@every %ds", seconds), m.runScheduledCycle)
+		if err != nil {
+			return fmt.Errorf("failed to add cron job: %w", err)
+		}
+		m.cron.Remove(m.cronEntry)
+		m.cronEntry = entryID
+	}
+
+	m.cfg.SetMonitorInterval(seconds)
+	return nil
+}
+
+// Schedule 在监听服务的定时任务调度器上注册任务
+// @description 维护任务与监听周期共用调度器；监听服务未启用时也会启动调度器
+// @param spec cron表达式
+// @param job 任务
+// @return error 表达式无效
+func (m *MonitorService) Schedule(spec string, job func()) error {
+	if m.cron == nil {
+		m.cron = cron.New()
+	}
+	if _, err := m.cron.AddFunc(spec, job); err != nil {
+		return fmt.Errorf("failed to add cron job: %w", err)
+	}
+	m.cron.Start()
+	return nil
+}
+
+// ResizeWorkerPool 调整Worker池大小
+// @description 运行时调整Worker数量和队列大小，不中断正在处理的订单
+// @param workerCount Worker数量
+// @param queueSize 队列大小
+// @return error 调整错误
+func (m *MonitorService) ResizeWorkerPool(workerCount, queueSize int) error {
+	if err := m.workerPool.Resize(workerCount, queueSize); err != nil {
+		return err
+	}
+
+	m.cfg.SetWorkerPoolSize(workerCount, queueSize)
+	return nil
+}
+
+// GetWorkerPoolStats 获取Worker池统计信息
+func (m *MonitorService) GetWorkerPoolStats() map[string]interface{} {
+	return m.workerPool.GetStats()
+}
+
+// GetWorkerPoolMetrics 获取Worker池任务指标
+func (m *MonitorService) GetWorkerPoolMetrics() worker.Metrics {
+	return m.workerPool.Metrics()
+}
+
+// StopScheduler 停止定时任务调度器（监听周期和维护任务）
+// @description 平滑升级交接完成后调用，避免新旧进程同时查询支付宝账单；Worker池保留到 Stop，处理完已提交的订单
+func (m *MonitorService) StopScheduler() {
+	if m.cron != nil {
+		<-m.cron.Stop().Done()
+	}
+	m.isRunning = false
+	logger.Info("Monitor scheduler stopped")
+}
+
 // Stop 停止监听服务
 // @description 停止定时任务和Worker池
 func (m *MonitorService) Stop() {
@@ -161,10 +290,8 @@ func (m *MonitorService) Stop() {
 // RunMonitoringCycle 运行一次监听周期
 // @description 获取待支付订单并提交到Worker池处理
 func (m *MonitorService) RunMonitoringCycle() {
-	// 使用文件锁防止并发执行
-	fileLock := lock.NewFileLock(m.lockFile, time.Duration(m.cfg.Monitor.LockTimeout)*time.Second)
-
-	acquired, err := fileLock.TryLock()
+	// 加锁防止并发执行（本地文件锁或集群分布式锁）
+	acquired, err := m.locker.TryLock()
 	if err != nil {
 		logger.Error("Failed to acquire lock", zap.Error(err))
 		return
@@ -174,22 +301,19 @@ func (m *MonitorService) RunMonitoringCycle() {
 		return // 另一个周期正在运行
 	}
 	defer func() {
-		if err := fileLock.Unlock(); err != nil {
-			logger.Error("Failed to unlock file", zap.Error(err))
+		if err := m.locker.Unlock(); err != nil {
+			logger.Error("Failed to release lock", zap.Error(err))
 		}
 	}()
 
-	// 1. 清理过期订单
-	if m.cfg.Payment.AutoCleanup {
-		count, err := m.codepay.CleanupExpiredOrders()
-		if err != nil {
-			logger.Error("Failed to cleanup expired orders", zap.Error(err))
-		} else if count > 0 {
-			logger.Info("Cleaned up expired orders", zap.Int64("count", count))
+	// 1. 清理过期订单（配置了 housekeeping.cleanup 时由维护任务定时执行）
+	if m.cfg.Housekeeping.Cleanup == "" {
+		if _, err := m.Cleanup(); err != nil {
+			logger.Error("Failed to cleanup", zap.Error(err))
 		}
 	}
 
-	// 2. 获取待支付订单（只监听10分钟内创建的订单）
+	// 2. 获取待支付订单（只监听10分钟内创建的订单和不自动过期的订单）
 	pendingOrders, err := m.getRecentPendingOrders(10 * time.Minute)
 	if err != nil {
 		logger.Error("Failed to get pending orders", zap.Error(err))
@@ -203,19 +327,17 @@ func (m *MonitorService) RunMonitoringCycle() {
 	logger.Info("Found pending orders to monitor",
 		zap.Int("count", len(pendingOrders)))
 
-	// 3. 提交订单到Worker池处理
+	// 3. 按账单来源分组，每个来源只查询一次账单，提交到Worker池批量匹配
 	submitted := 0
 	rejected := 0
 
-	for _, order := range pendingOrders {
-		task := NewOrderMonitorTask(order, m)
-
+	for _, task := range m.buildMatchTasks(pendingOrders) {
 		err := m.workerPool.Submit(task)
 		if err != nil {
 			rejected++
 			if err == worker.ErrQueueFull {
 				logger.Warn("Worker pool queue full, task rejected",
-					zap.String("order_id", order.ID))
+					zap.String("task", fmt.Sprintf("%T", task)))
 			}
 		} else {
 			submitted++
@@ -223,12 +345,77 @@ func (m *MonitorService) RunMonitoringCycle() {
 	}
 
 	if submitted > 0 {
-		logger.Info("Submitted orders to worker pool",
+		logger.Info("Submitted bill match tasks to worker pool",
+			zap.Int("orders", len(pendingOrders)),
 			zap.Int("submitted", submitted),
 			zap.Int("rejected", rejected))
 	}
 }
 
+// Cleanup 清理过期订单，以及早于账单查询窗口的已匹配账单记录和当面付二维码
+// @return int64 过期的订单数
+// @return error 清理错误（某一项失败不影响其他项）
+func (m *MonitorService) Cleanup() (int64, error) {
+	var errs []error
+
+	count, err := m.codepay.CleanupExpiredOrders()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("expired orders: %w", err))
+	} else if count > 0 {
+		logger.Info("Cleaned up expired orders", zap.Int64("count", count))
+	}
+
+	if _, err := m.db.DeleteMatchedBillsBefore(time.Now().Add(-matchedBillRetention)); err != nil {
+		errs = append(errs, fmt.Errorf("matched bills: %w", err))
+	}
+	if _, err := m.db.DeletePrecreateOrdersBefore(time.Now().Add(-precreateRetention)); err != nil {
+		errs = append(errs, fmt.Errorf("precreate orders: %w", err))
+	}
+
+	return count, errors.Join(errs...)
+}
+
+// buildMatchTasks 生成本周期的匹配任务
+// @description 真实订单按账单来源（二维码专属账号或默认账号）分组，每组一个 BillMatchTask；
+// 当面付订单各自查询交易状态；压测订单、沙箱订单各自匹配模拟账单
+// @param orders 待支付订单
+// @return []worker.Task 任务列表
+func (m *MonitorService) buildMatchTasks(orders []*model.Order) []worker.Task {
+	var tasks []worker.Task
+	groups := make(map[string][]*model.Order)
+	var sources []string
+
+	for _, order := range orders {
+		if m.syntheticBills != nil && IsLoadTestOrder(order) {
+			tasks = append(tasks, NewOrderMonitorTask(order, m, m.syntheticBills))
+			continue
+		}
+		if order.IsSandbox() {
+			tasks = append(tasks, NewOrderMonitorTask(order, m, m.codepay.SandboxBills()))
+			continue
+		}
+		if order.IsAlipayTrade() {
+			tasks = append(tasks, NewTradeQueryTask(order, m.codepay))
+			continue
+		}
+
+		source := ""
+		if _, exists := m.qrBillQueries[order.QRCodeID]; exists && order.QRCodeID != "" {
+			source = order.QRCodeID
+		}
+		if _, exists := groups[source]; !exists {
+			sources = append(sources, source)
+		}
+		groups[source] = append(groups[source], order)
+	}
+
+	for _, source := range sources {
+		tasks = append(tasks, NewBillMatchTask(source, groups[source], m))
+	}
+
+	return tasks
+}
+
 // GetBillQueryServiceForOrder 获取订单对应的账单查询服务
 // @description 根据订单的二维码ID返回对应的账单查询服务
 // @param order 订单
@@ -259,6 +446,10 @@ func (m *MonitorService) queryRecentBills() ([]BillRecord, error) {
 
 	// 查询最近1小时的账单
 	result, err := m.billQuery.QueryRecentBills(1)
+	if errors.Is(err, ErrAlipayRateLimited) {
+		// 本地限流未发出请求，不计入接口失败次数
+		return []BillRecord{}, err
+	}
 	if err != nil {
 		m.apiFailureCount++
 		logger.Error("Failed to query bills",
@@ -282,49 +473,7 @@ func (m *MonitorService) queryRecentBills() ([]BillRecord, error) {
 	}
 	m.lastSuccessTime = time.Now()
 
-	// 解析账单数据
-	success, _ := result["success"].(bool)
-	if !success {
-		return []BillRecord{}, nil
-	}
-
-	data, ok := result["data"].(map[string]interface{})
-	if !ok {
-		return []BillRecord{}, nil
-	}
-
-	detailList, ok := data["detail_list"].([]map[string]interface{})
-	if !ok {
-		return []BillRecord{}, nil
-	}
-
-	var bills []BillRecord
-	for _, detail := range detailList {
-		direction, _ := detail["direction"].(string)
-		if direction != "收入" {
-			continue
-		}
-
-		amountStr, _ := detail["trans_amount"].(string)
-		var amount float64
-		if _, err := fmt.Sscanf(amountStr, "%f", &amount); err != nil {
-			logger.Warn("Failed to parse amount",
-				zap.String("amount_str", amountStr),
-				zap.Error(err))
-			continue
-		}
-
-		bill := BillRecord{
-			TradeNo:   detail["alipay_order_no"].(string),
-			Amount:    amount,
-			Remark:    detail["trans_memo"].(string),
-			TransDate: detail["trans_dt"].(string),
-			Direction: direction,
-		}
-		bills = append(bills, bill)
-	}
-
-	return bills, nil
+	return ParseBillRecords(result), nil
 }
 
 // queryRecentBillsForQRCode 查询特定二维码的最近账单
@@ -342,6 +491,7 @@ func (m *MonitorService) queryRecentBillsForQRCode(qrCodeID string) ([]BillRecor
 
 	// 查询最近1小时的账单
 	result, err := qrBillQuery.QueryRecentBills(1)
+	m.qrHealth.RecordQuery(qrCodeID, err)
 	if err != nil {
 		logger.Error("Failed to query bills for QR code",
 			zap.String("qr_code_id", qrCodeID),
@@ -349,20 +499,47 @@ func (m *MonitorService) queryRecentBillsForQRCode(qrCodeID string) ([]BillRecor
 		return []BillRecord{}, err
 	}
 
-	// 解析账单数据
+	bills := ParseBillRecords(result, zap.String("qr_code_id", qrCodeID))
+
+	logger.Debug("Queried bills for QR code",
+		zap.String("qr_code_id", qrCodeID),
+		zap.Int("bill_count", len(bills)))
+
+	return bills, nil
+}
+
+// ProbeQRCodeAPI 使用二维码专属的API查询一次账单
+// @description 收款码健康检查探测因接口失败被标记为不健康的收款码是否已恢复
+// @param qrCodeID 二维码ID
+// @return error 查询错误，二维码没有专属API时返回错误
+func (m *MonitorService) ProbeQRCodeAPI(qrCodeID string) error {
+	qrBillQuery, exists := m.qrBillQueries[qrCodeID]
+	if !exists {
+		return fmt.Errorf("QR code %s has no independent API", qrCodeID)
+	}
+	_, err := qrBillQuery.QueryRecentBills(1)
+	return err
+}
+
+// ParseBillRecords 从账单查询结果中提取收入账单
+// @description 跳过支出和金额无法解析的记录
+// @param result BillQueryService 的查询结果
+// @param fields 金额解析失败时附加的日志字段（如二维码ID）
+// @return []BillRecord 收入账单列表
+func ParseBillRecords(result map[string]interface{}, fields ...zap.Field) []BillRecord {
 	success, _ := result["success"].(bool)
 	if !success {
-		return []BillRecord{}, nil
+		return []BillRecord{}
 	}
 
 	data, ok := result["data"].(map[string]interface{})
 	if !ok {
-		return []BillRecord{}, nil
+		return []BillRecord{}
 	}
 
 	detailList, ok := data["detail_list"].([]map[string]interface{})
 	if !ok {
-		return []BillRecord{}, nil
+		return []BillRecord{}
 	}
 
 	var bills []BillRecord
@@ -373,69 +550,68 @@ func (m *MonitorService) queryRecentBillsForQRCode(qrCodeID string) ([]BillRecor
 		}
 
 		amountStr, _ := detail["trans_amount"].(string)
-		var amount float64
-		if _, err := fmt.Sscanf(amountStr, "%f", &amount); err != nil {
-			logger.Warn("Failed to parse amount for QR code",
-				zap.String("qr_code_id", qrCodeID),
+		amount, err := model.ParseAmount(amountStr)
+		if err != nil {
+			logger.Warn("Failed to parse amount", append(fields,
 				zap.String("amount_str", amountStr),
-				zap.Error(err))
+				zap.Error(err))...)
 			continue
 		}
 
+		accountLogID, _ := detail["account_log_id"].(string)
 		bill := BillRecord{
-			TradeNo:   detail["alipay_order_no"].(string),
-			Amount:    amount,
-			Remark:    detail["trans_memo"].(string),
-			TransDate: detail["trans_dt"].(string),
-			Direction: direction,
+			TradeNo:      detail["alipay_order_no"].(string),
+			AccountLogID: accountLogID,
+			Amount:       amount,
+			Remark:       detail["trans_memo"].(string),
+			TransDate:    detail["trans_dt"].(string),
+			Direction:    direction,
 		}
 		bills = append(bills, bill)
 	}
 
-	logger.Debug("Queried bills for QR code",
-		zap.String("qr_code_id", qrCodeID),
-		zap.Int("bill_count", len(bills)))
-
-	return bills, nil
+	return bills
 }
 
 // updateOrderToPaid 更新订单为已支付状态
-// @description 更新数据库并发送商户通知
+// @description 先占用账单（每笔账单只能匹配一个订单），订单状态更新失败时释放账单
 // @param order 订单
-// @param alipayTradeNo 支付宝订单号
-// @return error 更新错误
-func (m *MonitorService) updateOrderToPaid(order *model.Order, alipayTradeNo string) error {
-	payTime := time.Now()
+// @param bill 匹配到的账单
+// @return error 账单已被其他订单使用时返回 errBillAlreadyMatched
+func (m *MonitorService) updateOrderToPaid(order *model.Order, bill BillRecord) error {
+	claimed, err := m.db.ClaimBill(bill.TradeNo, bill.AccountLogID, order.ID, bill.Amount)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errBillAlreadyMatched
+	}
 
-	if err := m.db.UpdateOrderStatus(order.ID, model.OrderStatusPaid, payTime); err != nil {
+	detail := fmt.Sprintf("支付宝交易号: %s", bill.TradeNo)
+	if err := m.codepay.OrderStates().MarkPaidByBill(order, bill.TradeNo, detail); err != nil {
+		if releaseErr := m.db.ReleaseBill(bill.TradeNo, bill.AccountLogID, order.ID); releaseErr != nil {
+			logger.Error("Failed to release bill",
+				zap.String("order_id", order.ID),
+				zap.String("alipay_trade_no", bill.TradeNo),
+				zap.Error(releaseErr))
+		}
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	logger.Success("Order paid successfully",
 		zap.String("order_id", order.ID),
 		zap.String("merchant_order_no", order.OutTradeNo),
-		zap.Float64("amount", order.PaymentAmount),
-		zap.String("alipay_trade_no", alipayTradeNo))
+		zap.Stringer("amount", order.PaymentAmount),
+		zap.String("alipay_trade_no", bill.TradeNo))
 
-	// 重新获取更新后的订单信息
-	updatedOrder, err := m.db.GetOrderByID(order.ID)
-	if err == nil && updatedOrder != nil {
-		// 发布订单支付事件（触发WebSocket推送等）
-		events.PublishOrderPaid(updatedOrder)
-	}
-
-	// 发送通知给商户
-	if err := m.codepay.SendNotification(order); err != nil {
-		logger.Warn("Failed to send notification (will retry later)",
-			zap.String("order_id", order.ID),
-			zap.Error(err))
-	}
+	// 发送通知给商户（提交到通知Worker池，不占用账单匹配的Worker）
+	_ = m.codepay.QueueNotification(order)
 
 	return nil
 }
 
 // getRecentPendingOrders 获取最近的待支付订单
-// @description 查询指定时间范围内创建的待支付订单
+// @description 查询指定时间范围内创建的待支付订单，以及更早创建但不自动过期的待支付订单（auto_close=0）
 // @param duration 时间范围
 // @return []*model.Order 订单列表
 // @return error 查询错误
@@ -451,7 +627,7 @@ func (m *MonitorService) getRecentPendingOrders(duration time.Duration) ([]*mode
 	now := time.Now()
 	for _, order := range orders {
 		orderAge := now.Sub(order.AddTime)
-		if orderAge <= duration {
+		if orderAge <= duration || order.KeepOpen {
 			recentOrders = append(recentOrders, order)
 		}
 	}
@@ -510,7 +686,8 @@ func (m *MonitorService) GetStatus() map[string]interface{} {
 	return map[string]interface{}{
 		"enabled":   m.cfg.Monitor.Enabled,
 		"running":   m.isRunning,
-		"interval":  m.cfg.Monitor.Interval,
+		"interval":  m.cfg.MonitorSnapshot().Interval,
 		"lock_file": m.lockFile,
+		"lock_type": fmt.Sprintf("%T", m.locker),
 	}
 }
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/httpclient"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// notifyProbeConcurrency 同时探测的地址数
+const notifyProbeConcurrency = 5
+
+// NotifyHealthChecker 商户通知地址健康检查
+// 定期探测近期订单使用过的通知地址（HEAD，不支持时退回GET，2xx视为健康），
+// 在真实支付需要通知前发现不可达的商户接口
+type NotifyHealthChecker struct {
+	db       *database.DB
+	client   *http.Client
+	interval time.Duration
+	lookback time.Duration // 只探测该时间内订单使用过的地址
+	results  map[string]*model.NotifyEndpointHealth
+	mu       sync.RWMutex
+	stopCh   chan struct{}
+	leader   *LeaderElector // 主节点选举（未启用时为nil）
+}
+
+// NewNotifyHealthChecker 创建通知地址健康检查服务
+func NewNotifyHealthChecker(db *database.DB, interval, timeout, lookback time.Duration) *NotifyHealthChecker {
+	client := httpclient.For(httpclient.Notify)
+	client.Timeout = timeout
+	// 重定向视为可达，不跟随
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &NotifyHealthChecker{
+		db:       db,
+		client:   client,
+		interval: interval,
+		lookback: lookback,
+		results:  make(map[string]*model.NotifyEndpointHealth),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// SetLeaderElector 设置主节点选举（多实例部署时只在主节点定期探测）
+func (c *NotifyHealthChecker) SetLeaderElector(leader *LeaderElector) {
+	c.leader = leader
+}
+
+// Start 启动健康检查
+func (c *NotifyHealthChecker) Start() {
+	go c.run()
+	logger.Info("Notify health checker started",
+		zap.Duration("interval", c.interval),
+		zap.Duration("lookback", c.lookback))
+}
+
+// Stop 停止健康检查
+func (c *NotifyHealthChecker) Stop() {
+	close(c.stopCh)
+	logger.Info("Notify health checker stopped")
+}
+
+// Results 获取最近一次探测结果（不健康的排在前面）
+func (c *NotifyHealthChecker) Results() []*model.NotifyEndpointHealth {
+	c.mu.RLock()
+	results := make([]*model.NotifyEndpointHealth, 0, len(c.results))
+	for _, r := range c.results {
+		copied := *r
+		results = append(results, &copied)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Healthy != results[j].Healthy {
+			return !results[i].Healthy
+		}
+		if results[i].PID != results[j].PID {
+			return results[i].PID < results[j].PID
+		}
+		return results[i].URL < results[j].URL
+	})
+
+	return results
+}
+
+// run 运行检查循环
+func (c *NotifyHealthChecker) run() {
+	if c.leader.IsLeader() {
+		c.checkAll()
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if c.leader.IsLeader() {
+				c.checkAll()
+			}
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// checkAll 探测所有近期使用过的通知地址
+func (c *NotifyHealthChecker) checkAll() {
+	urlsByPID, err := c.db.GetRecentNotifyURLs(time.Now().Add(-c.lookback))
+	if err != nil {
+		logger.Error("Failed to get notify urls for health check", zap.Error(err))
+		return
+	}
+
+	// 同一地址不同查询参数只探测一次
+	endpoints := make(map[string]string) // endpoint -> pid
+	for pid, urls := range urlsByPID {
+		for _, raw := range urls {
+			if endpoint, ok := probeEndpoint(raw); ok {
+				endpoints[endpoint] = pid
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, notifyProbeConcurrency)
+	for endpoint, pid := range endpoints {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(endpoint, pid string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.record(pid, endpoint, c.probe(endpoint))
+		}(endpoint, pid)
+	}
+	wg.Wait()
+
+	// 清理不再使用的地址
+	c.mu.Lock()
+	for endpoint := range c.results {
+		if _, ok := endpoints[endpoint]; !ok {
+			delete(c.results, endpoint)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// probeResult 单次探测结果
+type probeResult struct {
+	statusCode int
+	latency    time.Duration
+	err        error
+}
+
+// probe 探测地址，HEAD不被支持时退回GET
+func (c *NotifyHealthChecker) probe(endpoint string) probeResult {
+	start := time.Now()
+	statusCode, err := c.request(http.MethodHead, endpoint)
+	if err == nil && (statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented) {
+		statusCode, err = c.request(http.MethodGet, endpoint)
+	}
+
+	result := probeResult{statusCode: statusCode, latency: time.Since(start), err: err}
+	if err == nil && (statusCode < 200 || statusCode >= 300) {
+		result.err = fmt.Errorf("unexpected status code %d", statusCode)
+	}
+	return result
+}
+
+// request 发送探测请求
+func (c *NotifyHealthChecker) request(method, endpoint string) (int, error) {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "AliMPay-HealthCheck/1.0")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// record 记录探测结果，状态变化时输出日志
+func (c *NotifyHealthChecker) record(pid, endpoint string, result probeResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	health, exists := c.results[endpoint]
+	if !exists {
+		health = &model.NotifyEndpointHealth{PID: pid, URL: endpoint, Healthy: true}
+		c.results[endpoint] = health
+	}
+	wasHealthy := health.Healthy
+
+	health.PID = pid
+	health.StatusCode = result.statusCode
+	health.LatencyMs = result.latency.Milliseconds()
+	health.CheckedAt = time.Now()
+
+	if result.err == nil {
+		health.Healthy = true
+		health.Error = ""
+		health.ConsecutiveFailures = 0
+		checkedAt := health.CheckedAt
+		health.LastHealthyAt = &checkedAt
+	} else {
+		health.Healthy = false
+		health.Error = result.err.Error()
+		health.ConsecutiveFailures++
+	}
+
+	if wasHealthy && !health.Healthy {
+		logger.Warn("Merchant notify endpoint unhealthy",
+			zap.String("pid", pid),
+			zap.String("url", endpoint),
+			zap.Int("status_code", result.statusCode),
+			zap.Error(result.err))
+	} else if !wasHealthy && health.Healthy {
+		logger.Info("Merchant notify endpoint recovered",
+			zap.String("pid", pid),
+			zap.String("url", endpoint))
+	}
+}
+
+// probeEndpoint 去除查询参数和片段，得到探测地址
+func probeEndpoint(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), true
+}
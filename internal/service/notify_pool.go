@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/worker"
+
+	"go.uber.org/zap"
+)
+
+// notifyTask 商户通知任务
+// @description 在独立的通知Worker池中发送，商户地址响应慢时不占用账单匹配的Worker
+type notifyTask struct {
+	order   *model.Order
+	codepay *CodePayService
+}
+
+// Execute 发送商户通知
+func (t *notifyTask) Execute(ctx context.Context) error {
+	if err := t.codepay.SendNotification(t.order); err != nil {
+		return fmt.Errorf("notify order %s: %w", t.order.ID, err)
+	}
+	return nil
+}
+
+// Persist 关闭时未发送的通知写入失败的通知记录
+// @description 自动回调只重试刚支付的订单，服务重启后这些通知不会再自动发送；
+// 记录失败原因后可在管理后台的通知记录中查看并重新发送
+func (t *notifyTask) Persist() error {
+	payload, _ := json.Marshal(t.codepay.paymentResult(t.order))
+	return t.codepay.db.CreateNotifyLog(&model.NotifyLog{
+		OrderID:    t.order.ID,
+		OutTradeNo: t.order.OutTradeNo,
+		PID:        t.order.PID,
+		NotifyURL:  t.order.NotifyURL,
+		Payload:    string(payload),
+		Status:     model.NotifyStatusFailed,
+		Error:      "service stopped before the notification was sent",
+		CreatedAt:  time.Now(),
+	})
+}
+
+// QueueNotification 将商户通知提交到通知Worker池异步发送
+// @description 队列已满时返回错误，由自动回调服务稍后重试
+// @param order 订单
+// @return error 提交错误
+func (s *CodePayService) QueueNotification(order *model.Order) error {
+	if order.NotifyURL == "" {
+		return nil
+	}
+
+	if err := s.notifyPool.Submit(&notifyTask{order: order, codepay: s}); err != nil {
+		logger.Warn("Failed to queue merchant notification",
+			zap.String("order_id", order.ID),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ResizeNotifyPool 调整通知Worker池大小
+// @param workerCount Worker数量
+// @param queueSize 队列大小
+// @return error 调整错误
+func (s *CodePayService) ResizeNotifyPool(workerCount, queueSize int) error {
+	if err := s.notifyPool.Resize(workerCount, queueSize); err != nil {
+		return err
+	}
+
+	s.cfg.SetNotifyPoolSize(workerCount, queueSize)
+	return nil
+}
+
+// GetNotifyPoolStats 获取通知Worker池统计信息
+func (s *CodePayService) GetNotifyPoolStats() map[string]interface{} {
+	return s.notifyPool.GetStats()
+}
+
+// GetNotifyPoolMetrics 获取通知Worker池任务指标
+func (s *CodePayService) GetNotifyPoolMetrics() worker.Metrics {
+	return s.notifyPool.Metrics()
+}
+
+// Close 停止通知Worker池（在排空期限内发送完排队中的通知，未发送的写入失败的通知记录）
+func (s *CodePayService) Close() {
+	s.notifyPool.Stop()
+}
@@ -0,0 +1,81 @@
+// Package service 商户通知来源验证
+// @author AliMPay Team
+// @description 商户除MD5签名外还可通过两种方式确认通知来自本系统：
+// 配置 merchant.notify_secret 后通知请求附带 HMAC-SHA256 签名请求头（含时间戳，可拒绝重放）；
+// 配置 merchant.outbound_ips 后通过 GET /api/notify/ips 公布发送通知的出口IP，商户可加入白名单
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alimpay-go/internal/pkg/utils"
+)
+
+// 商户通知签名请求头
+const (
+	NotifySignatureHeader = "X-AliMPay-Signature" // HMAC-SHA256签名（十六进制小写）
+	NotifyTimestampHeader = "X-AliMPay-Timestamp" // 签名时间（Unix秒）
+)
+
+// NotifySignature 计算商户通知的HMAC签名
+// @description 签名内容为 时间戳 + "\n" + 待签名字符串（与MD5签名相同：过滤空值和 sign、sign_type 后按参数名升序拼接）
+// @param secret 通知签名密钥
+// @param timestamp 时间戳（Unix秒）
+// @param params 通知参数
+// @return string HMAC-SHA256签名（十六进制小写）
+func NotifySignature(secret, timestamp string, params map[string]string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + utils.SignContent(params)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifySecretFor 获取商户的通知签名密钥
+// @description 正式商户使用 merchant.notify_secret；沙箱商户使用沙箱密钥，便于在沙箱中测试验签。
+// 未配置 merchant.notify_secret 时不签名（沙箱商户同样不签名）
+// @param pid 商户ID
+// @return string 通知签名密钥，为空时不附加签名请求头
+func (s *CodePayService) notifySecretFor(pid string) string {
+	secret := s.cfg.Merchant.NotifySecret
+	if secret == "" || pid == s.merchantID {
+		return secret
+	}
+	if key, ok := s.merchantKeyFor(pid); ok {
+		return key
+	}
+	return ""
+}
+
+// signNotifyRequest 为通知请求附加签名请求头（商户未配置通知签名密钥时不处理）
+func (s *CodePayService) signNotifyRequest(req *http.Request, data map[string]string) {
+	secret := s.notifySecretFor(data["pid"])
+	if secret == "" {
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(NotifyTimestampHeader, timestamp)
+	req.Header.Set(NotifySignatureHeader, NotifySignature(secret, timestamp, data))
+}
+
+// NotifySourceInfo 商户验证通知来源所需的信息（GET /api/notify/ips）
+// @return map[string]interface{} 出口IP列表和通知签名方式
+func (s *CodePayService) NotifySourceInfo() map[string]interface{} {
+	ips := s.cfg.Merchant.OutboundIPs
+	if ips == nil {
+		ips = []string{}
+	}
+	return map[string]interface{}{
+		"ips": ips,
+		"signature": map[string]interface{}{
+			"enabled":          s.cfg.Merchant.NotifySecret != "",
+			"algorithm":        "HMAC-SHA256",
+			"header":           NotifySignatureHeader,
+			"timestamp_header": NotifyTimestampHeader,
+		},
+	}
+}
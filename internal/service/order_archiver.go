@@ -0,0 +1,173 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// OrderArchiver 订单归档任务
+// 定期将创建时间超过保留期的已结束订单移入归档表，使SQLite订单表保持精简；
+// 每批在独立事务中复制并删除，批次之间释放写锁，不阻塞下单
+type OrderArchiver struct {
+	db        *database.DB
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+	stopCh    chan struct{}
+	leader    *LeaderElector // 主节点选举（未启用时为nil）
+
+	mu            sync.Mutex
+	running       bool      // 是否正在归档（防止定时任务与手动触发同时执行）
+	lastRun       time.Time // 上次归档完成时间
+	lastArchived  int       // 上次归档的订单数
+	lastError     string    // 上次归档的错误
+	totalArchived int       // 本次启动以来归档的订单数
+}
+
+// NewOrderArchiver 创建订单归档任务
+func NewOrderArchiver(db *database.DB, retention, interval time.Duration, batchSize int) *OrderArchiver {
+	return &OrderArchiver{
+		db:        db,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetLeaderElector 设置主节点选举（多实例部署时定时归档只在主节点执行，手动归档不受影响）
+func (a *OrderArchiver) SetLeaderElector(leader *LeaderElector) {
+	a.leader = leader
+}
+
+// Start 启动归档任务
+func (a *OrderArchiver) Start() {
+	go a.run()
+	logger.Info("Order archiver started",
+		zap.Duration("retention", a.retention),
+		zap.Duration("interval", a.interval),
+		zap.Int("batch_size", a.batchSize))
+}
+
+// Stop 停止归档任务
+func (a *OrderArchiver) Stop() {
+	close(a.stopCh)
+	logger.Info("Order archiver stopped")
+}
+
+// run 运行归档循环
+func (a *OrderArchiver) run() {
+	a.archive()
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.archive()
+		case <-a.stopCh:
+			return
+		}
+	}
+}
+
+// RunOnce 立即执行一次归档，返回归档的订单数（已有归档在执行时返回false）
+func (a *OrderArchiver) RunOnce() (int, bool, error) {
+	a.mu.Lock()
+	if a.running {
+		a.mu.Unlock()
+		return 0, false, nil
+	}
+	a.running = true
+	a.mu.Unlock()
+
+	archived, err := a.archiveAll()
+
+	a.mu.Lock()
+	a.running = false
+	a.lastRun = time.Now()
+	a.lastArchived = archived
+	a.totalArchived += archived
+	a.lastError = ""
+	if err != nil {
+		a.lastError = err.Error()
+	}
+	a.mu.Unlock()
+
+	return archived, true, err
+}
+
+// archive 定时归档（非主节点跳过）
+func (a *OrderArchiver) archive() {
+	if !a.leader.IsLeader() {
+		return
+	}
+
+	start := time.Now()
+	archived, started, err := a.RunOnce()
+	RecordJobRun(a.db, JobArchive, model.JobTriggerSchedule, start, fmt.Sprintf("archived %d orders", archived), started, err)
+	if !started {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to archive orders",
+			zap.Int("archived", archived),
+			zap.Error(err))
+		return
+	}
+	if archived > 0 {
+		logger.Info("Archived old orders", zap.Int("count", archived))
+	}
+}
+
+// archiveAll 分批归档所有超过保留期的订单
+func (a *OrderArchiver) archiveAll() (int, error) {
+	before := time.Now().Add(-a.retention)
+
+	total := 0
+	for {
+		select {
+		case <-a.stopCh:
+			return total, nil
+		default:
+		}
+
+		count, err := a.db.ArchiveOrders(before, a.batchSize)
+		total += count
+		if err != nil {
+			return total, err
+		}
+		if count < a.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Status 获取归档任务状态
+func (a *OrderArchiver) Status() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := map[string]interface{}{
+		"retention_days": int(a.retention / (24 * time.Hour)),
+		"interval":       int(a.interval.Seconds()),
+		"batch_size":     a.batchSize,
+		"running":        a.running,
+		"last_archived":  a.lastArchived,
+		"last_error":     a.lastError,
+		"total_archived": a.totalArchived,
+		"last_run":       nil,
+	}
+	if !a.lastRun.IsZero() {
+		status["last_run"] = a.lastRun.Format("2006-01-02 15:04:05")
+	}
+	return status
+}
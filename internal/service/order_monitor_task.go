@@ -1,119 +1,188 @@
 // Package service 订单监听任务实现
 // @author AliMPay Team
-// @description 提供订单监听任务的具体实现
+// @description 提供订单监听任务的具体实现：真实订单按账单来源批量匹配，压测订单逐个匹配模拟账单
 package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"sort"
 	"time"
 
+	"alimpay-go/internal/config"
 	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
 
 	"go.uber.org/zap"
 )
 
+// BillMatchTask 账单批量匹配任务
+// @description 每个监听周期内，同一账单来源（默认支付宝账号或二维码专属账号）只查询一次账单，
+// 再在内存中匹配该来源的全部待支付订单
+type BillMatchTask struct {
+	qrCodeID string // 二维码ID（为空时使用默认账单查询服务）
+	orders   []*model.Order
+	monitor  *MonitorService
+}
+
+// NewBillMatchTask 创建账单批量匹配任务
+// @param qrCodeID 有独立API的二维码ID，为空表示默认账号
+// @param orders 使用该账单来源的待支付订单
+// @param monitor 监听服务
+// @return *BillMatchTask 任务实例
+func NewBillMatchTask(qrCodeID string, orders []*model.Order, monitor *MonitorService) *BillMatchTask {
+	return &BillMatchTask{
+		qrCodeID: qrCodeID,
+		orders:   orders,
+		monitor:  monitor,
+	}
+}
+
+// Execute 执行账单批量匹配任务
+// @description 查询一次支付宝账单并匹配全部订单
+// @param ctx 上下文
+// @return error 账单查询错误
+func (t *BillMatchTask) Execute(ctx context.Context) error {
+	var bills []BillRecord
+	var err error
+	if t.qrCodeID != "" {
+		// 查询该二维码对应的账单
+		bills, err = t.monitor.queryRecentBillsForQRCode(t.qrCodeID)
+		if err != nil {
+			logger.Debug("Failed to query bills for QR code, fallback to default",
+				zap.String("qr_code_id", t.qrCodeID),
+				zap.Error(err))
+			// 如果失败，尝试使用默认服务
+			bills, err = t.monitor.queryRecentBills()
+		}
+	} else {
+		bills, err = t.monitor.queryRecentBills()
+	}
+	if err != nil {
+		return err
+	}
+
+	t.monitor.matchOrders(t.orders, bills)
+	return nil
+}
+
 // OrderMonitorTask 订单监听任务
-// @description 监听单个订单的支付状态变化
+// @description 压测订单和沙箱订单只匹配模拟账单，不调用支付宝接口
 type OrderMonitorTask struct {
 	order   *model.Order
 	monitor *MonitorService
+	bills   *SyntheticBillSource
 }
 
 // NewOrderMonitorTask 创建订单监听任务
-// @description 为指定订单创建监听任务
+// @description 为指定压测订单或沙箱订单创建监听任务
 // @param order 要监听的订单
 // @param monitor 监听服务
+// @param bills 模拟账单来源
 // @return *OrderMonitorTask 任务实例
-func NewOrderMonitorTask(order *model.Order, monitor *MonitorService) *OrderMonitorTask {
+func NewOrderMonitorTask(order *model.Order, monitor *MonitorService, bills *SyntheticBillSource) *OrderMonitorTask {
 	return &OrderMonitorTask{
 		order:   order,
 		monitor: monitor,
+		bills:   bills,
 	}
 }
 
 // Execute 执行订单监听任务
-// @description 查询支付宝账单并尝试匹配订单
+// @description 匹配订单对应的模拟账单
 // @param ctx 上下文
 // @return error 执行错误
 func (t *OrderMonitorTask) Execute(ctx context.Context) error {
-	// 检查订单当前状态
-	currentOrder, err := t.monitor.db.GetOrderByID(t.order.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get order: %w", err)
-	}
-
-	if currentOrder == nil || currentOrder.Status == model.OrderStatusPaid {
-		return nil // 订单不存在或已支付
+	if t.bills == nil {
+		return nil
 	}
+	t.monitor.matchOrders([]*model.Order{t.order}, t.bills.BillsFor(t.order))
+	return nil
+}
 
-	// 检查订单是否超时
-	orderAge := time.Since(currentOrder.AddTime)
-	if orderAge > 10*time.Minute {
-		return nil // 超过10分钟不再监听
+// matchOrders 在内存中为订单匹配账单，匹配成功则更新订单为已支付
+// @description 先创建的订单优先匹配；同一批次中已使用的账单不再参与匹配，
+// 已被其他订单使用的账单（matched_bills）会被跳过；开启分笔支付时，未整笔匹配的订单再累计分笔账单
+// @param orders 待支付订单
+// @param bills 账单列表
+func (m *MonitorService) matchOrders(orders []*model.Order, bills []BillRecord) {
+	if len(bills) == 0 {
+		return
 	}
 
-	// 获取订单对应的账单查询服务
-	billQueryService := t.monitor.GetBillQueryServiceForOrder(currentOrder)
-	if billQueryService == nil {
-		return nil // 账单查询服务不可用
-	}
+	sorted := make([]*model.Order, len(orders))
+	copy(sorted, orders)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].AddTime.Before(sorted[j].AddTime)
+	})
+
+	used := make([]bool, len(bills))
+	for _, order := range sorted {
+		handled := false
+		for i, bill := range bills {
+			if used[i] || !MatchBill(&m.cfg.Payment, order, bill) {
+				continue
+			}
 
-	// 查询支付宝账单（使用订单对应的API）
-	var bills []BillRecord
-	if currentOrder.QRCodeID != "" {
-		// 如果订单有二维码ID，查询该二维码对应的账单
-		bills, err = t.monitor.queryRecentBillsForQRCode(currentOrder.QRCodeID)
-		if err != nil {
-			logger.Debug("Failed to query bills for QR code, fallback to default",
-				zap.String("qr_code_id", currentOrder.QRCodeID),
-				zap.Error(err))
-			// 如果失败，尝试使用默认服务
-			bills, err = t.monitor.queryRecentBills()
+			// 更新订单状态（账单已被其他同金额订单使用时继续匹配下一笔）
+			err := m.updateOrderToPaid(order, bill)
+			if errors.Is(err, errBillAlreadyMatched) {
+				used[i] = true
+				logger.Debug("Bill already matched, skipping",
+					zap.String("order_id", order.ID),
+					zap.String("alipay_trade_no", bill.TradeNo))
+				continue
+			}
+			if errors.Is(err, ErrIllegalTransition) {
+				// 订单已在其他地方支付或关闭，账单已释放，可匹配其他订单
+				logger.Debug("Order no longer pending, skipping",
+					zap.String("order_id", order.ID),
+					zap.Error(err))
+				handled = true
+				break
+			}
 			if err != nil {
-				return err
+				logger.Error("Failed to update order status",
+					zap.String("order_id", order.ID),
+					zap.Error(err))
+				handled = true
+				break
 			}
-		}
-	} else {
-		// 使用默认账单查询
-		bills, err = t.monitor.queryRecentBills()
-		if err != nil {
-			return err
-		}
-	}
-
-	// 尝试匹配账单
-	for _, bill := range bills {
-		matched := false
 
-		if t.monitor.cfg.Payment.BusinessQRMode.Enabled {
-			matched = t.matchBusinessModeBill(bill)
-		} else {
-			matched = t.matchTraditionalModeBill(bill)
+			used[i] = true
+			handled = true
+			break
 		}
 
-		if matched {
-			// 更新订单状态
-			if err := t.monitor.updateOrderToPaid(currentOrder, bill.TradeNo); err != nil {
-				logger.Error("Failed to update order status",
-					zap.String("order_id", currentOrder.ID),
-					zap.Error(err))
-			}
-			return nil
+		if !handled && PartialPaymentEligible(&m.cfg.Payment, order) {
+			m.accumulatePartialBills(order, bills, used)
 		}
 	}
+}
 
-	return nil
+// MatchBill 判断账单是否属于订单
+// @description 经营码模式按金额和时间匹配，传统模式按备注（商户订单号）和金额匹配
+// @param payment 支付配置
+// @param order 订单
+// @param bill 账单记录
+// @return bool 是否匹配
+func MatchBill(payment *config.PaymentConfig, order *model.Order, bill BillRecord) bool {
+	if payment.BusinessQRMode.Enabled {
+		tolerance := time.Duration(payment.BusinessQRMode.MatchTolerance) * time.Second
+		return matchBusinessModeBill(order, bill, tolerance)
+	}
+	return matchTraditionalModeBill(order, bill)
 }
 
 // matchBusinessModeBill 匹配经营码模式账单
 // @description 根据金额和时间匹配
+// @param order 订单
 // @param bill 账单记录
+// @param tolerance 支付时间与下单时间的最大间隔
 // @return bool 是否匹配
-func (t *OrderMonitorTask) matchBusinessModeBill(bill BillRecord) bool {
+func matchBusinessModeBill(order *model.Order, bill BillRecord, tolerance time.Duration) bool {
 	// 检查金额
-	if fmt.Sprintf("%.2f", bill.Amount) != fmt.Sprintf("%.2f", t.order.PaymentAmount) {
+	if bill.Amount != order.PaymentAmount {
 		return false
 	}
 
@@ -124,26 +193,26 @@ func (t *OrderMonitorTask) matchBusinessModeBill(bill BillRecord) bool {
 	}
 
 	// 验证时间（支付必须在订单创建之后）
-	timeDiff := billTime.Sub(t.order.AddTime)
+	timeDiff := billTime.Sub(order.AddTime)
 	if timeDiff < 0 {
 		return false
 	}
 
-	// 检查时间容差
-	tolerance := time.Duration(t.monitor.cfg.Payment.BusinessQRMode.MatchTolerance) * time.Second
-	return timeDiff <= tolerance
+	// 检查时间容差（不自动过期的订单一直占用该金额，不限制支付时间）
+	return order.KeepOpen || timeDiff <= tolerance
 }
 
 // matchTraditionalModeBill 匹配传统模式账单
 // @description 根据备注（订单号）和金额匹配
+// @param order 订单
 // @param bill 账单记录
 // @return bool 是否匹配
-func (t *OrderMonitorTask) matchTraditionalModeBill(bill BillRecord) bool {
+func matchTraditionalModeBill(order *model.Order, bill BillRecord) bool {
 	// 检查备注是否为订单号
-	if bill.Remark != t.order.OutTradeNo {
+	if bill.Remark != order.OutTradeNo {
 		return false
 	}
 
 	// 验证金额
-	return fmt.Sprintf("%.2f", bill.Amount) == fmt.Sprintf("%.2f", t.order.Price)
+	return bill.Amount == order.Price
 }
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/model"
+)
+
+// benchBillCount 每次账单查询返回的账单数（与监听周期查询的分页大小一致）
+const benchBillCount = 100
+
+// benchOrder 基准测试订单（支付金额与账单金额不重复）
+func benchOrder() *model.Order {
+	return &model.Order{
+		ID:            "20260101000000000001",
+		OutTradeNo:    "BENCH20260101000001",
+		Price:         1230,
+		PaymentAmount: 1230,
+		AddTime:       time.Now().Add(-time.Minute).Truncate(time.Second),
+	}
+}
+
+// benchBills 构造账单，最后一条与订单匹配
+func benchBills(order *model.Order) []BillDetail {
+	details := make([]BillDetail, 0, benchBillCount)
+	for i := 0; i < benchBillCount; i++ {
+		details = append(details, BillDetail{
+			AccountLogID:  fmt.Sprintf("L%08d", i),
+			AlipayOrderNo: fmt.Sprintf("2026%016d", i),
+			TransAmount:   model.Amount(100 + i).String(),
+			TransMemo:     fmt.Sprintf("OTHER%08d", i),
+			TransDt:       order.AddTime.Add(time.Duration(i) * time.Second).Format("2006-01-02 15:04:05"),
+			Direction:     "收入",
+			Balance:       "0.00",
+			Type:          "在线支付",
+		})
+	}
+
+	last := &details[len(details)-1]
+	last.TransAmount = order.PaymentAmount.String()
+	last.TransMemo = order.OutTradeNo
+	return details
+}
+
+// BenchmarkMatchBill 在一次查询的账单中匹配订单（匹配的账单在最后）
+func BenchmarkMatchBill(b *testing.B) {
+	for _, mode := range []struct {
+		name         string
+		businessMode bool
+	}{
+		{name: "Traditional", businessMode: false},
+		{name: "Business", businessMode: true},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			order := benchOrder()
+			payment := &config.PaymentConfig{
+				BusinessQRMode: config.BusinessQRMode{
+					Enabled:        mode.businessMode,
+					MatchTolerance: 600,
+				},
+			}
+
+			bills := ParseBillRecords(map[string]interface{}{
+				"success": true,
+				"data":    FormatBillData(&BillQueryResponse{DetailList: benchBills(order)}),
+			})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				matched := -1
+				for j, bill := range bills {
+					if MatchBill(payment, order, bill) {
+						matched = j
+						break
+					}
+				}
+				if matched != len(bills)-1 {
+					b.Fatalf("matched bill %d, want %d", matched, len(bills)-1)
+				}
+			}
+		})
+	}
+}
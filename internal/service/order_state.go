@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrIllegalTransition 订单状态转换不合法（或订单状态已被其他请求修改）
+var ErrIllegalTransition = errors.New("illegal order status transition")
+
+// orderTransitions 允许的订单状态转换
+var orderTransitions = map[int][]int{
+	model.OrderStatusPending: {model.OrderStatusPaid, model.OrderStatusClosed, model.OrderStatusExpired},
+	model.OrderStatusPaid:    {model.OrderStatusRefund},
+}
+
+// orderStatusNames 订单状态名称（用于错误信息和日志）
+var orderStatusNames = map[int]string{
+	model.OrderStatusPending: "pending",
+	model.OrderStatusPaid:    "paid",
+	model.OrderStatusClosed:  "closed",
+	model.OrderStatusRefund:  "refunded",
+	model.OrderStatusExpired: "expired",
+}
+
+// OrderStatusName 获取订单状态名称
+func OrderStatusName(status int) string {
+	if name, ok := orderStatusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", status)
+}
+
+// CanTransition 判断订单状态能否从from转换为to
+func CanTransition(from, to int) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStateMachine 订单状态机
+// @description 所有订单状态变更都经由状态机完成：校验转换是否合法、
+// 以条件更新避免并发请求互相覆盖、记录订单生命周期事件并发布对应的事件
+type OrderStateMachine struct {
+	db *database.DB
+}
+
+// NewOrderStateMachine 创建订单状态机
+// @param db 数据库
+// @return *OrderStateMachine 状态机实例
+func NewOrderStateMachine(db *database.DB) *OrderStateMachine {
+	return &OrderStateMachine{db: db}
+}
+
+// MarkPaid 将待支付订单标记为已支付
+// @param order 订单（成功后状态和支付时间会被更新）
+// @param event 订单事件类型（账单匹配或手动/回调确认）
+// @param detail 事件详情
+// @return error 转换不合法时返回 ErrIllegalTransition
+func (m *OrderStateMachine) MarkPaid(order *model.Order, event, detail string) error {
+	return m.transition(order, model.OrderStatusPaid, event, detail, "")
+}
+
+// MarkPaidByBill 支付宝账单匹配成功后将订单标记为已支付，并保存支付宝交易号用于对账
+// @param order 订单（成功后状态、支付时间和支付宝交易号会被更新）
+// @param alipayTradeNo 匹配到的支付宝交易号
+// @param detail 事件详情
+// @return error 转换不合法时返回 ErrIllegalTransition
+func (m *OrderStateMachine) MarkPaidByBill(order *model.Order, alipayTradeNo, detail string) error {
+	return m.transition(order, model.OrderStatusPaid, model.OrderEventBillMatched, detail, alipayTradeNo)
+}
+
+// MarkPaidByTrade 当面付交易成功后将订单标记为已支付，并保存支付宝交易号
+// @param order 订单（成功后状态、支付时间和支付宝交易号会被更新）
+// @param alipayTradeNo 支付宝交易号
+// @param detail 事件详情（确认来源）
+// @return error 转换不合法时返回 ErrIllegalTransition
+func (m *OrderStateMachine) MarkPaidByTrade(order *model.Order, alipayTradeNo, detail string) error {
+	return m.transition(order, model.OrderStatusPaid, model.OrderEventTradePaid, detail, alipayTradeNo)
+}
+
+// Close 关闭待支付订单
+// @param order 订单
+// @param detail 事件详情（如关闭来源）
+// @return error 转换不合法时返回 ErrIllegalTransition
+func (m *OrderStateMachine) Close(order *model.Order, detail string) error {
+	return m.transition(order, model.OrderStatusClosed, model.OrderEventClosed, detail, "")
+}
+
+// Expire 将超时未支付的订单标记为已过期
+// @param order 订单
+// @return error 转换不合法时返回 ErrIllegalTransition
+func (m *OrderStateMachine) Expire(order *model.Order) error {
+	return m.transition(order, model.OrderStatusExpired, model.OrderEventExpired, "订单超时未支付", "")
+}
+
+// Refund 将已支付订单标记为已退款（实际退款需在支付宝中处理）
+// @param order 订单
+// @param detail 事件详情
+// @return error 转换不合法时返回 ErrIllegalTransition
+func (m *OrderStateMachine) Refund(order *model.Order, detail string) error {
+	return m.transition(order, model.OrderStatusRefund, model.OrderEventRefunded, detail, "")
+}
+
+// transition 执行状态转换
+// @description 校验转换、按当前状态条件更新数据库，成功后记录订单事件并发布事件；
+// 若订单状态已被其他请求修改，则刷新order的状态并返回 ErrIllegalTransition；alipayTradeNo 为空时不修改已保存的交易号
+func (m *OrderStateMachine) transition(order *model.Order, to int, event, detail, alipayTradeNo string) error {
+	from := order.Status
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, OrderStatusName(from), OrderStatusName(to))
+	}
+
+	var payTime *time.Time
+	if to == model.OrderStatusPaid {
+		now := time.Now()
+		payTime = &now
+	}
+
+	updated, err := m.db.TransitionOrderStatus(order.ID, from, to, payTime, alipayTradeNo)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		current, err := m.db.GetOrderByID(order.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reload order: %w", err)
+		}
+		if current == nil {
+			return fmt.Errorf("order not found: %s", order.ID)
+		}
+		order.Status = current.Status
+		order.PayTime = current.PayTime
+		order.AlipayTradeNo = current.AlipayTradeNo
+		return fmt.Errorf("%w: order is already %s", ErrIllegalTransition, OrderStatusName(current.Status))
+	}
+
+	order.Status = to
+	if payTime != nil {
+		order.PayTime = payTime
+	}
+	if alipayTradeNo != "" {
+		order.AlipayTradeNo = alipayTradeNo
+	}
+
+	m.db.RecordOrderEvent(order.ID, event, detail)
+
+	logger.Info("Order status changed",
+		zap.String("order_id", order.ID),
+		zap.String("from", OrderStatusName(from)),
+		zap.String("to", OrderStatusName(to)))
+
+	switch to {
+	case model.OrderStatusPaid:
+		events.PublishOrderPaid(order)
+	case model.OrderStatusClosed:
+		events.PublishOrderClosed(order)
+	case model.OrderStatusExpired:
+		events.PublishOrderExpired(order)
+	case model.OrderStatusRefund:
+		events.PublishOrderRefund(order)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package service 分笔支付
+// @author AliMPay Team
+// @description 高额订单受支付宝单笔转账限额限制需要拆分付款时，备注为商户订单号的多笔小额账单
+// 在订单有效期内累计，累计金额达到支付金额后订单完成支付
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/i18n"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// PartialPaymentEligible 订单是否允许分笔支付
+// @description 当面付和手机网站支付订单由支付宝一次性完成支付，不参与分笔支付
+// @param payment 支付配置
+// @param order 订单
+// @return bool 是否允许
+func PartialPaymentEligible(payment *config.PaymentConfig, order *model.Order) bool {
+	if !payment.PartialPayment.Enabled || order.IsAlipayTrade() {
+		return false
+	}
+	return order.PaymentAmount >= payment.PartialPayment.MinAmount
+}
+
+// matchPartialBill 判断账单是否为订单的一笔分笔支付
+// @description 经营码模式无法按金额区分分笔账单，两种模式都要求付款备注为商户订单号；
+// 金额小于订单支付金额（等于支付金额的账单按整笔支付匹配），且付款时间不早于下单时间
+// @param order 订单
+// @param bill 账单记录
+// @return bool 是否匹配
+func matchPartialBill(order *model.Order, bill BillRecord) bool {
+	if bill.Remark != order.OutTradeNo || bill.Amount <= 0 || bill.Amount >= order.PaymentAmount {
+		return false
+	}
+
+	billTime, err := time.ParseInLocation("2006-01-02 15:04:05", bill.TransDate, time.Local)
+	if err != nil {
+		return false
+	}
+	// 账单时间精确到秒
+	return !billTime.Before(order.AddTime.Truncate(time.Second))
+}
+
+// accumulatePartialBills 累计订单的分笔支付，累计金额达到支付金额时更新订单为已支付
+// @description 分笔账单先占用（matched_bills，避免匹配其他订单）再记录到 partial_payments；
+// 账单在查询窗口内会被重复查到，已记录的账单不重复累计
+// @param order 待支付订单
+// @param bills 账单列表
+// @param used 本批次已使用的账单（匹配到的分笔账单会被标记）
+func (m *MonitorService) accumulatePartialBills(order *model.Order, bills []BillRecord, used []bool) {
+	found := false
+	for i, bill := range bills {
+		if used[i] || !matchPartialBill(order, bill) {
+			continue
+		}
+
+		claimed, err := m.db.ClaimBill(bill.TradeNo, bill.AccountLogID, order.ID, bill.Amount)
+		if err != nil {
+			logger.Error("Failed to claim partial bill",
+				zap.String("order_id", order.ID),
+				zap.String("alipay_trade_no", bill.TradeNo),
+				zap.Error(err))
+			return
+		}
+		used[i] = true
+		if !claimed {
+			continue
+		}
+		found = true
+
+		recorded, err := m.db.AddPartialPayment(&model.PartialPayment{
+			OrderID:       order.ID,
+			AlipayTradeNo: bill.TradeNo,
+			AccountLogID:  bill.AccountLogID,
+			Amount:        bill.Amount,
+		})
+		if err != nil {
+			logger.Error("Failed to record partial payment",
+				zap.String("order_id", order.ID),
+				zap.String("alipay_trade_no", bill.TradeNo),
+				zap.Error(err))
+			return
+		}
+		if !recorded {
+			continue
+		}
+
+		m.db.RecordOrderEvent(order.ID, model.OrderEventPartialPaid,
+			fmt.Sprintf("支付宝交易号: %s, 金额: %s", bill.TradeNo, bill.Amount))
+		logger.Info("Partial payment received",
+			zap.String("order_id", order.ID),
+			zap.String("merchant_order_no", order.OutTradeNo),
+			zap.Stringer("amount", bill.Amount),
+			zap.String("alipay_trade_no", bill.TradeNo))
+	}
+	// 上个周期已记录但订单状态更新失败时，账单仍在查询窗口内，本周期重新累计
+	if !found {
+		return
+	}
+
+	payments, err := m.db.GetPartialPayments(order.ID)
+	if err != nil {
+		logger.Error("Failed to get partial payments", zap.String("order_id", order.ID), zap.Error(err))
+		return
+	}
+
+	var total model.Amount
+	tradeNos := make([]string, 0, len(payments))
+	for _, payment := range payments {
+		total += payment.Amount
+		tradeNos = append(tradeNos, payment.AlipayTradeNo)
+	}
+	if total < order.PaymentAmount {
+		return
+	}
+
+	// 订单的支付宝交易号记录最后一笔，全部交易号见事件详情
+	detail := fmt.Sprintf("分笔支付 %d 笔，合计 %s 元，支付宝交易号: %s", len(payments), total, strings.Join(tradeNos, ", "))
+	if err := m.codepay.OrderStates().MarkPaidByBill(order, tradeNos[len(tradeNos)-1], detail); err != nil {
+		// 分笔账单已记录，订单已在其他地方支付或关闭时需人工处理多收的款项
+		logger.Warn("Failed to complete partially paid order",
+			zap.String("order_id", order.ID),
+			zap.Stringer("received", total),
+			zap.Error(err))
+		return
+	}
+
+	if total > order.PaymentAmount {
+		logger.Warn("Partially paid order received more than payment amount",
+			zap.String("order_id", order.ID),
+			zap.Stringer("payment_amount", order.PaymentAmount),
+			zap.Stringer("received", total))
+	}
+
+	logger.Success("Order paid by partial payments",
+		zap.String("order_id", order.ID),
+		zap.String("merchant_order_no", order.OutTradeNo),
+		zap.Stringer("amount", order.PaymentAmount),
+		zap.Int("payments", len(payments)))
+
+	_ = m.codepay.QueueNotification(order)
+}
+
+// fillPartialPayment 填充订单已收到的分笔支付金额和剩余金额
+// @param response 支付信息
+// @param order 订单
+// @param lang 语言（为空时使用默认语言）
+func (s *CodePayService) fillPartialPayment(response map[string]interface{}, order *model.Order, lang string) {
+	if !PartialPaymentEligible(&s.cfg.Payment, order) {
+		return
+	}
+
+	paid := s.partialPaidAmount(order)
+	if paid <= 0 {
+		return
+	}
+
+	remaining := max(0, order.PaymentAmount-paid)
+	response["paid_amount"] = paid
+	response["remaining_amount"] = remaining
+
+	tips, _ := response["payment_tips"].([]string)
+	response["payment_tips"] = append([]string{i18n.T(lang, "tip.partial.received", paid, remaining)}, tips...)
+}
+
+// partialPaidAmount 订单已收到的分笔支付金额（订单查询接口返回，已整笔支付或未开启分笔支付时为0）
+// @param order 订单
+// @return model.Amount 已收到的金额
+func (s *CodePayService) partialPaidAmount(order *model.Order) model.Amount {
+	if !s.cfg.Payment.PartialPayment.Enabled {
+		return 0
+	}
+
+	paid, err := s.db.GetPartialPaidAmount(order.ID)
+	if err != nil {
+		logger.Warn("Failed to get partial paid amount", zap.String("trade_no", order.ID), zap.Error(err))
+		return 0
+	}
+	return paid
+}
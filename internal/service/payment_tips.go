@@ -0,0 +1,60 @@
+// Package service 支付说明和提示
+// @author AliMPay Team
+// @description 按下单参数 lang 或浏览器语言生成支付说明、支付提示，文本见 internal/pkg/i18n
+package service
+
+import (
+	"fmt"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/i18n"
+)
+
+// fillBusinessTips 填充经营码订单的支付说明、金额调整说明和支付提示
+// @param response 支付信息
+// @param order 订单
+// @param lang 语言（为空时使用默认语言）
+func (s *CodePayService) fillBusinessTips(response map[string]interface{}, order *model.Order, lang string) {
+	response["payment_instruction"] = i18n.T(lang, "tip.business.instruction", order.PaymentAmount)
+
+	// 同金额订单的支付金额已调整
+	if order.PaymentAmount != order.Price {
+		response["amount_adjusted"] = true
+		response["adjustment_note"] = i18n.T(lang, "tip.adjustment_note", order.PaymentAmount)
+		response["original_amount"] = order.Price
+	}
+
+	// 不自动过期的订单（下单参数 auto_close=0）不提示支付时限
+	timeoutTip := i18n.T(lang, "tip.business.timeout", max(1, s.cfg.Payment.OrderTimeout/60))
+	if order.KeepOpen {
+		timeoutTip = i18n.T(lang, "tip.business.keep_open")
+	}
+
+	// 允许分笔支付的订单需在每笔付款备注中填写订单号
+	remarkTip := i18n.T(lang, "tip.business.no_remark")
+	if PartialPaymentEligible(&s.cfg.Payment, order) {
+		remarkTip = i18n.T(lang, "tip.partial.remark", order.OutTradeNo)
+	}
+
+	response["payment_tips"] = []string{
+		i18n.T(lang, "tip.business.amount", order.PaymentAmount),
+		remarkTip,
+		timeoutTip,
+		i18n.T(lang, "tip.business.detect"),
+		i18n.T(lang, "tip.business.contact"),
+	}
+}
+
+// payPageURL 订单支付页面地址
+// @description 下单时指定了语言则附加 lang 参数，买家扫码打开的支付页面使用相同语言
+// @param baseURL 服务基础URL
+// @param order 订单
+// @param lang 语言（为空时支付页面按浏览器语言展示）
+// @return string 支付页面地址
+func payPageURL(baseURL string, order *model.Order, lang string) string {
+	pageURL := fmt.Sprintf("%s/pay?trade_no=%s&amount=%s", baseURL, order.ID, order.PaymentAmount)
+	if lang != "" {
+		pageURL += "&lang=" + lang
+	}
+	return pageURL
+}
@@ -0,0 +1,152 @@
+// Package service 当面付收款模式
+// @author AliMPay Team
+// @description 通过 alipay.trade.precreate 为每个订单生成支付宝收款二维码，
+// 按交易号确认支付（支付宝异步通知 + 监听周期内的交易查询兜底），不需要调整金额和匹配账单
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// AlipayNotifyPath 支付宝交易异步通知地址（当面付、手机网站支付）
+const AlipayNotifyPath = "/alipay/notify"
+
+// precreateRetention 当面付二维码保留时间（订单早已支付或过期后清理）
+const precreateRetention = 24 * time.Hour
+
+// precreateOrder 当面付预下单，返回支付宝二维码内容
+// @param order 待创建的订单（使用订单交易号作为支付宝侧的商户订单号）
+// @param baseURL 服务基础URL（未配置 notify_url 时用于生成异步通知地址）
+// @return string 二维码内容
+func (s *CodePayService) precreateOrder(order *model.Order, baseURL string) (string, error) {
+	notifyURL := tradeNotifyURL(s.cfg.Payment.PrecreateMode.NotifyURL, baseURL)
+	timeout := time.Duration(s.cfg.Payment.OrderTimeout) * time.Second
+	return s.alipayClient.TradePrecreate(order.ID, order.PaymentAmount, order.Name, notifyURL, timeout)
+}
+
+// tradeNotifyURL 支付宝交易异步通知地址：优先使用配置，否则为服务基础URL + AlipayNotifyPath
+func tradeNotifyURL(configured, baseURL string) string {
+	if configured != "" {
+		return configured
+	}
+	return baseURL + AlipayNotifyPath
+}
+
+// HandleTradeNotify 处理支付宝交易异步通知（当面付、手机网站支付）
+// @description 校验签名后按交易状态确认订单支付；非支付成功的通知（如交易关闭）直接忽略
+// @param params 通知参数
+// @return error 签名错误、订单不存在或金额不符；返回nil时应答 success，支付宝不再重发
+func (s *CodePayService) HandleTradeNotify(params map[string]string) error {
+	if err := s.alipayClient.VerifyNotify(params); err != nil {
+		return err
+	}
+
+	if !IsTradePaid(params["trade_status"]) {
+		logger.Info("Ignoring alipay trade notify",
+			zap.String("out_trade_no", params["out_trade_no"]),
+			zap.String("trade_status", params["trade_status"]))
+		return nil
+	}
+
+	order, err := s.db.GetOrderByID(params["out_trade_no"])
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if order == nil {
+		return fmt.Errorf("order not found: %s", params["out_trade_no"])
+	}
+
+	return s.confirmTradePaid(order, params["trade_no"], params["total_amount"], "支付宝异步通知")
+}
+
+// SyncTradeOrder 查询当面付、手机网站支付订单的交易状态，已支付则确认订单（异步通知丢失时的兜底）
+// @param order 待支付的交易订单
+// @return error 查询错误；用户尚未付款时返回nil
+func (s *CodePayService) SyncTradeOrder(order *model.Order) error {
+	trade, err := s.alipayClient.TradeQuery(order.ID)
+	if errors.Is(err, ErrTradeNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !IsTradePaid(trade.TradeStatus) {
+		return nil
+	}
+	return s.confirmTradePaid(order, trade.TradeNo, trade.TotalAmount, "交易查询")
+}
+
+// confirmTradePaid 确认交易订单已支付并通知商户
+// @description 金额必须与订单支付金额一致；订单已由另一来源（通知/查询）确认时视为成功
+func (s *CodePayService) confirmTradePaid(order *model.Order, alipayTradeNo, totalAmount, source string) error {
+	if !order.IsAlipayTrade() {
+		return fmt.Errorf("order %s is not an alipay trade order", order.ID)
+	}
+
+	amount, err := model.ParseAmount(totalAmount)
+	if err != nil {
+		return fmt.Errorf("invalid total_amount %q: %w", totalAmount, err)
+	}
+	if amount != order.PaymentAmount {
+		logger.Error("Alipay trade amount mismatch",
+			zap.String("order_id", order.ID),
+			zap.Stringer("expected", order.PaymentAmount),
+			zap.Stringer("actual", amount),
+			zap.String("alipay_trade_no", alipayTradeNo))
+		return fmt.Errorf("payment amount mismatch: expected %s, got %s", order.PaymentAmount, amount)
+	}
+
+	detail := fmt.Sprintf("%s，支付宝交易号: %s", source, alipayTradeNo)
+	if err := s.states.MarkPaidByTrade(order, alipayTradeNo, detail); err != nil {
+		if errors.Is(err, ErrIllegalTransition) && order.Status == model.OrderStatusPaid {
+			return nil
+		}
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	logger.Success("Order paid successfully",
+		zap.String("order_id", order.ID),
+		zap.String("merchant_order_no", order.OutTradeNo),
+		zap.Stringer("amount", order.PaymentAmount),
+		zap.String("alipay_trade_no", alipayTradeNo),
+		zap.String("source", source))
+
+	// 发送通知给商户（提交到通知Worker池）
+	_ = s.QueueNotification(order)
+
+	return nil
+}
+
+// TradeQueryTask 交易订单查询任务
+// @description 每个监听周期查询一次待支付的当面付、手机网站支付订单的交易状态
+type TradeQueryTask struct {
+	order   *model.Order
+	codepay *CodePayService
+}
+
+// NewTradeQueryTask 创建交易查询任务
+// @param order 待支付的交易订单
+// @param codepay 码支付服务
+// @return *TradeQueryTask 任务实例
+func NewTradeQueryTask(order *model.Order, codepay *CodePayService) *TradeQueryTask {
+	return &TradeQueryTask{
+		order:   order,
+		codepay: codepay,
+	}
+}
+
+// Execute 执行交易查询任务
+// @param ctx 上下文
+// @return error 查询错误
+func (t *TradeQueryTask) Execute(ctx context.Context) error {
+	return t.codepay.SyncTradeOrder(t.order)
+}
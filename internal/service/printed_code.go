@@ -0,0 +1,262 @@
+// Package service 线下打印收款码
+// @author AliMPay Team
+// @description 批量预生成固定金额的收款码（短链接 /s/{code}）供线下活动打印，
+// 顾客扫码时才创建订单并跳转支付页面；每个收款码同一时间只关联一个订单
+package service
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/money"
+	"alimpay-go/internal/pkg/qrcode"
+	"alimpay-go/internal/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxPrintedCodeBatch 单批最多生成的收款码数量
+	MaxPrintedCodeBatch = 500
+
+	// printedCodeLength 短码长度（32个字符，40位随机数）
+	printedCodeLength = 8
+	// printedCodeAlphabet 短码字符集（去除易混淆的 0/O、1/I）
+	printedCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	// printedQRCodeSize 打印用二维码图片尺寸（像素）
+	printedQRCodeSize = 600
+	// printedOutTradeNoPrefix 扫码创建订单的商户订单号前缀
+	printedOutTradeNoPrefix = "PRINT"
+)
+
+// PrintedCodeBatch 一批线下打印收款码
+type PrintedCodeBatch struct {
+	ID    string
+	Name  string
+	Price model.Amount
+	Codes []*model.PrintedCode
+}
+
+// PrintedCodeURL 收款码短链接（二维码内容）
+func PrintedCodeURL(baseURL, code string) string {
+	return baseURL + "/s/" + code
+}
+
+// CreatePrintedCodes 批量生成固定金额的收款码
+// @param name 商品名称（扫码创建的订单名称）
+// @param amount 固定金额（元）
+// @param count 生成数量（1 ~ MaxPrintedCodeBatch）
+// @return *PrintedCodeBatch 生成的批次
+func (s *CodePayService) CreatePrintedCodes(name, amount string, count int) (*PrintedCodeBatch, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing required parameter: name")
+	}
+	if count <= 0 || count > MaxPrintedCodeBatch {
+		return nil, fmt.Errorf("count must be between 1 and %d", MaxPrintedCodeBatch)
+	}
+	price, err := money.ParseOrderAmount(amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %w", err)
+	}
+
+	batch := &PrintedCodeBatch{
+		ID:    utils.GenerateTradeNo(),
+		Name:  name,
+		Price: price,
+		Codes: make([]*model.PrintedCode, 0, count),
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, count)
+	for len(batch.Codes) < count {
+		code, err := generatePrintedCode()
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			continue
+		}
+		// 短码空间足够大，与已有收款码冲突的概率极低，冲突时重新生成
+		existing, err := s.db.GetPrintedCode(code)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			continue
+		}
+
+		seen[code] = true
+		batch.Codes = append(batch.Codes, &model.PrintedCode{
+			Code:      code,
+			BatchID:   batch.ID,
+			Name:      name,
+			Price:     price,
+			CreatedAt: now,
+		})
+	}
+
+	if err := s.db.CreatePrintedCodes(batch.Codes); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Printed QR codes created",
+		zap.String("batch_id", batch.ID),
+		zap.String("name", name),
+		zap.Stringer("price", price),
+		zap.Int("count", count))
+
+	return batch, nil
+}
+
+// GetPrintedCodeBatch 查询收款码批次，不存在时返回nil
+func (s *CodePayService) GetPrintedCodeBatch(batchID string) (*PrintedCodeBatch, error) {
+	codes, err := s.db.GetPrintedCodesByBatch(batchID)
+	if err != nil || len(codes) == 0 {
+		return nil, err
+	}
+
+	return &PrintedCodeBatch{
+		ID:    batchID,
+		Name:  codes[0].Name,
+		Price: codes[0].Price,
+		Codes: codes,
+	}, nil
+}
+
+// WritePrintedCodeArchive 输出批次的ZIP压缩包
+// 每个收款码一张PNG二维码图片（{短码}.png），另附 codes.csv 列出短码、短链接、名称和金额
+// @param w 输出
+// @param batch 收款码批次
+// @param baseURL 服务基础URL（短链接域名）
+func (s *CodePayService) WritePrintedCodeArchive(w io.Writer, batch *PrintedCodeBatch, baseURL string) error {
+	archive := zip.NewWriter(w)
+	generator := qrcode.NewGenerator(printedQRCodeSize, s.cfg.Payment.QRCodeMargin)
+
+	created := time.Now()
+	if len(batch.Codes) > 0 {
+		created = batch.Codes[0].CreatedAt
+	}
+
+	index, err := archive.CreateHeader(&zip.FileHeader{Name: "codes.csv", Method: zip.Deflate, Modified: created})
+	if err != nil {
+		return err
+	}
+	// UTF-8 BOM，Excel直接打开中文不乱码
+	if _, err := io.WriteString(index, "\ufeff"); err != nil {
+		return err
+	}
+	rows := csv.NewWriter(index)
+	if err := rows.Write([]string{"短码", "链接", "图片", "商品名称", "金额"}); err != nil {
+		return err
+	}
+	for _, code := range batch.Codes {
+		link := PrintedCodeURL(baseURL, code.Code)
+		if err := rows.Write([]string{code.Code, link, code.Code + ".png", code.Name, code.Price.String()}); err != nil {
+			return err
+		}
+	}
+	rows.Flush()
+	if err := rows.Error(); err != nil {
+		return err
+	}
+
+	for _, code := range batch.Codes {
+		png, err := generator.GenerateToBytes(PrintedCodeURL(baseURL, code.Code))
+		if err != nil {
+			return err
+		}
+		// PNG已压缩，直接存储
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: code.Code + ".png", Method: zip.Store, Modified: created})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(png); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// ResolvePrintedCode 扫描收款码：返回关联的订单，首次扫码或关联订单已关闭、过期时创建新订单
+// 已支付的收款码再次扫码返回原订单（支付页面显示已支付）
+// @param code 短码
+// @param baseURL 服务基础URL
+// @return *model.Order 订单，收款码不存在时返回nil
+func (s *CodePayService) ResolvePrintedCode(code, baseURL string) (*model.Order, error) {
+	// 同一收款码的并发扫码串行处理，避免创建多个订单
+	s.printedMu.Lock()
+	defer s.printedMu.Unlock()
+
+	printed, err := s.db.GetPrintedCode(strings.ToUpper(code))
+	if err != nil || printed == nil {
+		return nil, err
+	}
+
+	if printed.TradeNo != "" {
+		order, err := s.db.GetOrderByID(printed.TradeNo)
+		if err != nil {
+			return nil, err
+		}
+		if order != nil && s.printedOrderActive(order) {
+			return order, nil
+		}
+	}
+
+	result, err := s.createPayment(map[string]string{
+		"pid":          s.merchantID,
+		"type":         model.PaymentTypeAlipay,
+		"out_trade_no": printedOutTradeNoPrefix + printed.Code + "-" + utils.GenerateTradeNo(),
+		"name":         printed.Name,
+		"money":        printed.Price.String(),
+		"sitename":     "线下收款码",
+	}, baseURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tradeNo, _ := result["trade_no"].(string)
+	if _, err := s.db.BindPrintedCodeOrder(printed.Code, printed.TradeNo, tradeNo); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Order created from printed QR code",
+		zap.String("code", printed.Code),
+		zap.String("batch_id", printed.BatchID),
+		zap.String("trade_no", tradeNo),
+		zap.String("previous_trade_no", printed.TradeNo))
+
+	return s.db.GetOrderByID(tradeNo)
+}
+
+// printedOrderActive 收款码关联的订单是否仍有效（待支付且未超时或不自动过期，或已支付、已退款）
+func (s *CodePayService) printedOrderActive(order *model.Order) bool {
+	switch order.Status {
+	case model.OrderStatusPending:
+		timeout := time.Duration(s.cfg.Payment.OrderTimeout) * time.Second
+		return order.KeepOpen || time.Since(order.AddTime) < timeout
+	case model.OrderStatusPaid, model.OrderStatusRefund:
+		return true
+	default:
+		return false
+	}
+}
+
+// generatePrintedCode 生成随机短码
+func generatePrintedCode() (string, error) {
+	b := make([]byte, printedCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate printed code: %w", err)
+	}
+	for i := range b {
+		b[i] = printedCodeAlphabet[int(b[i])%len(printedCodeAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/notifier"
+
+	"go.uber.org/zap"
+)
+
+// ErrQRCodeHealthy 收款码未被标记为不健康，无需恢复
+var ErrQRCodeHealthy = errors.New("QR code is not marked unhealthy")
+
+// qrHealthState 收款码健康状态
+type qrHealthState struct {
+	failures    int       // 专属接口连续失败次数
+	lastError   string    // 专属接口最近一次错误
+	unhealthy   bool      // 是否已标记为不健康
+	reason      string    // 不健康的原因
+	detail      string    // 原因说明
+	since       time.Time // 标记为不健康的时间
+	recoveredAt time.Time // 最近一次恢复的时间（之前分配的订单不再计入无支付检查）
+	checkedAt   time.Time // 最近一次检查支付情况的时间
+}
+
+// QRCodeHealthChecker 收款码健康检查
+// 专属支付宝接口连续失败，或最近一段时间分配了足够多的订单却没有一笔支付的收款码标记为不健康，
+// 选择器不再分配给新订单，并通过运营通知渠道告警。
+// 接口失败的收款码在专属接口查询成功后自动恢复；无支付的收款码在配置的时间后自动恢复或由管理员手动恢复。
+// 健康状态保存在内存中：多实例部署时各实例独立检查支付情况，专属接口失败只在执行监听周期的主节点检测
+type QRCodeHealthChecker struct {
+	cfg          *config.QRCodeHealthConfig
+	db           *database.DB
+	selector     *QRCodeSelector
+	orderTimeout time.Duration // 未超时的订单可能仍在支付中，不计入无支付检查
+	states       map[string]*qrHealthState
+	mu           sync.RWMutex
+	stopCh       chan struct{}
+
+	monitor  *MonitorService    // 监听服务（探测专属接口是否恢复，未设置时只能手动恢复）
+	notifier *notifier.Notifier // 告警渠道（未设置时只记录日志）
+	leader   *LeaderElector     // 主节点选举（未启用时为nil）
+}
+
+// NewQRCodeHealthChecker 创建收款码健康检查服务
+// @param cfg 健康检查配置
+// @param db 数据库实例
+// @param selector 二维码选择器（提供需要检查的收款码）
+// @param orderTimeout 订单超时时间
+// @return *QRCodeHealthChecker 健康检查服务
+func NewQRCodeHealthChecker(cfg *config.QRCodeHealthConfig, db *database.DB, selector *QRCodeSelector, orderTimeout time.Duration) *QRCodeHealthChecker {
+	return &QRCodeHealthChecker{
+		cfg:          cfg,
+		db:           db,
+		selector:     selector,
+		orderTimeout: orderTimeout,
+		states:       make(map[string]*qrHealthState),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// SetMonitor 设置监听服务（定期探测不健康收款码的专属接口）
+// @param monitor 监听服务
+func (c *QRCodeHealthChecker) SetMonitor(monitor *MonitorService) {
+	c.monitor = monitor
+}
+
+// SetNotifier 设置告警渠道（收款码被标记为不健康或恢复时发送）
+// @param n 运营通知渠道
+func (c *QRCodeHealthChecker) SetNotifier(n *notifier.Notifier) {
+	c.notifier = n
+}
+
+// SetLeaderElector 设置主节点选举（多实例部署时只在主节点探测专属接口和发送告警）
+// @param leader 主节点选举
+func (c *QRCodeHealthChecker) SetLeaderElector(leader *LeaderElector) {
+	c.leader = leader
+}
+
+// Start 启动健康检查
+func (c *QRCodeHealthChecker) Start() {
+	go c.run()
+	logger.Info("QR code health checker started",
+		zap.Int("interval", c.cfg.Interval),
+		zap.Int("failure_threshold", c.cfg.FailureThreshold),
+		zap.Int("no_payment_hours", c.cfg.NoPaymentHours),
+		zap.Int("min_orders", c.cfg.MinOrders))
+}
+
+// Stop 停止健康检查
+func (c *QRCodeHealthChecker) Stop() {
+	close(c.stopCh)
+	logger.Info("QR code health checker stopped")
+}
+
+// run 运行检查循环
+func (c *QRCodeHealthChecker) run() {
+	ticker := time.NewTicker(time.Duration(c.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Check()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// IsHealthy 收款码是否可以分配给新订单
+// @param id 二维码ID
+// @return bool 未启用健康检查或未被标记为不健康时返回true
+func (c *QRCodeHealthChecker) IsHealthy(id string) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.states[id]
+	return !ok || !state.unhealthy
+}
+
+// RecordQuery 记录一次专属接口的账单查询结果
+// @description 连续失败达到阈值时标记为不健康；查询成功时恢复因接口失败被标记的收款码
+// @param id 二维码ID
+// @param err 查询错误（限流未发送的请求不计入）
+func (c *QRCodeHealthChecker) RecordQuery(id string, err error) {
+	if c == nil || errors.Is(err, ErrAlipayRateLimited) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(id)
+	if err == nil {
+		state.failures = 0
+		state.lastError = ""
+		if state.unhealthy && state.reason == model.QRCodeUnhealthyAPIFailures {
+			c.markHealthy(id, state, "专属支付宝接口查询已恢复")
+		}
+		return
+	}
+
+	state.failures++
+	state.lastError = err.Error()
+	if !state.unhealthy && state.failures >= c.cfg.FailureThreshold {
+		c.markUnhealthy(id, state, model.QRCodeUnhealthyAPIFailures,
+			fmt.Sprintf("专属支付宝接口连续失败 %d 次：%s", state.failures, state.lastError))
+	}
+}
+
+// Check 立即检查一次全部启用的收款码
+// @description 健康的收款码检查近期订单的支付情况；接口失败的收款码探测专属接口；无支付的收款码到期后自动恢复
+func (c *QRCodeHealthChecker) Check() {
+	ids := c.selector.QRCodeIDs()
+	c.prune(ids)
+
+	now := time.Now()
+	for _, id := range ids {
+		c.mu.RLock()
+		state := *c.state(id)
+		c.mu.RUnlock()
+
+		if !state.unhealthy {
+			c.checkPayments(id, state.recoveredAt, now)
+			continue
+		}
+
+		switch state.reason {
+		case model.QRCodeUnhealthyAPIFailures:
+			if c.monitor != nil && c.leader.IsLeader() {
+				c.RecordQuery(id, c.monitor.ProbeQRCodeAPI(id))
+			}
+		case model.QRCodeUnhealthyNoPayment:
+			if c.cfg.RecoverAfter > 0 && now.Sub(state.since) >= time.Duration(c.cfg.RecoverAfter)*time.Minute {
+				c.recover(id, state.since, fmt.Sprintf("标记已超过 %d 分钟，自动恢复分配", c.cfg.RecoverAfter))
+			}
+		}
+	}
+}
+
+// checkPayments 最近一段时间（且在上次恢复之后）分配的已超时订单达到最少订单数却没有一笔支付时标记为不健康
+func (c *QRCodeHealthChecker) checkPayments(id string, recoveredAt, now time.Time) {
+	since := now.Add(-time.Duration(c.cfg.NoPaymentHours) * time.Hour)
+	if recoveredAt.After(since) {
+		since = recoveredAt
+	}
+	until := now.Add(-c.orderTimeout)
+	if !until.After(since) {
+		return
+	}
+
+	total, paid, err := c.db.CountQRCodeOrders(id, since, until)
+	if err != nil {
+		logger.Error("Failed to check QR code payments", zap.String("qr_id", id), zap.Error(err))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(id)
+	state.checkedAt = now
+	if state.unhealthy || total < c.cfg.MinOrders || paid > 0 {
+		return
+	}
+	c.markUnhealthy(id, state, model.QRCodeUnhealthyNoPayment,
+		fmt.Sprintf("最近 %d 小时分配了 %d 笔订单，没有匹配到任何支付", c.cfg.NoPaymentHours, total))
+}
+
+// Reset 手动恢复不健康的收款码
+// @param id 二维码ID
+// @return error 收款码未被标记为不健康时返回 ErrQRCodeHealthy
+func (c *QRCodeHealthChecker) Reset(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.states[id]
+	if !ok || !state.unhealthy {
+		return ErrQRCodeHealthy
+	}
+	c.markHealthy(id, state, "管理员手动恢复")
+	return nil
+}
+
+// recover 自动恢复（标记时间未变化时才恢复，期间已被手动恢复或重新标记的跳过）
+func (c *QRCodeHealthChecker) recover(id string, since time.Time, detail string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(id)
+	if state.unhealthy && state.since.Equal(since) {
+		c.markHealthy(id, state, detail)
+	}
+}
+
+// Statuses 全部启用的收款码的健康状态（不健康的排在前面）
+func (c *QRCodeHealthChecker) Statuses() []*model.QRCodeHealth {
+	ids := c.selector.QRCodeIDs()
+
+	c.mu.RLock()
+	statuses := make([]*model.QRCodeHealth, 0, len(ids))
+	for _, id := range ids {
+		status := &model.QRCodeHealth{ID: id, Healthy: true}
+		if state, ok := c.states[id]; ok {
+			status.Healthy = !state.unhealthy
+			status.ConsecutiveFailures = state.failures
+			status.LastError = state.lastError
+			if state.unhealthy {
+				since := state.since
+				status.Reason = state.reason
+				status.Detail = state.detail
+				status.UnhealthySince = &since
+			}
+			if !state.checkedAt.IsZero() {
+				checkedAt := state.checkedAt
+				status.CheckedAt = &checkedAt
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	c.mu.RUnlock()
+
+	// 稳定排序，保持选择器的优先级顺序
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return !statuses[i].Healthy && statuses[j].Healthy
+	})
+	return statuses
+}
+
+// state 获取收款码的状态，不存在时创建（调用方需持有锁）
+func (c *QRCodeHealthChecker) state(id string) *qrHealthState {
+	state, ok := c.states[id]
+	if !ok {
+		state = &qrHealthState{}
+		c.states[id] = state
+	}
+	return state
+}
+
+// prune 删除已禁用或已删除的收款码的状态
+func (c *QRCodeHealthChecker) prune(ids []string) {
+	enabled := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		enabled[id] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.states {
+		if !enabled[id] {
+			delete(c.states, id)
+		}
+	}
+}
+
+// markUnhealthy 标记为不健康并告警（调用方需持有锁）
+func (c *QRCodeHealthChecker) markUnhealthy(id string, state *qrHealthState, reason, detail string) {
+	state.unhealthy = true
+	state.reason = reason
+	state.detail = detail
+	state.since = time.Now()
+
+	logger.Warn("QR code marked unhealthy, no longer assigned to new orders",
+		zap.String("qr_id", id),
+		zap.String("reason", reason),
+		zap.String("detail", detail))
+	c.alert(notifier.Message{
+		Title: "收款码异常：" + id,
+		Text:  detail + "\n\n已停止分配给新订单，请检查该收款码和支付宝账号状态，处理后可在管理后台手动恢复。",
+	})
+}
+
+// markHealthy 恢复分配并通知（调用方需持有锁）
+func (c *QRCodeHealthChecker) markHealthy(id string, state *qrHealthState, detail string) {
+	reason := state.reason
+	state.unhealthy = false
+	state.reason = ""
+	state.detail = ""
+	state.since = time.Time{}
+	state.failures = 0
+	state.recoveredAt = time.Now()
+
+	logger.Info("QR code recovered, assigned to new orders again",
+		zap.String("qr_id", id),
+		zap.String("reason", reason),
+		zap.String("detail", detail))
+	c.alert(notifier.Message{
+		Title: "收款码已恢复：" + id,
+		Text:  detail + "，重新分配给新订单。",
+	})
+}
+
+// alert 在后台发送告警（未配置通知渠道或非主节点时不发送）
+func (c *QRCodeHealthChecker) alert(msg notifier.Message) {
+	if c.notifier == nil || c.notifier.Len() == 0 || !c.leader.IsLeader() {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := c.notifier.Send(ctx, msg); err != nil {
+			logger.Error("Failed to send QR code health alert", zap.String("title", msg.Title), zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// MaxQRCodeImageSize 上传二维码图片的大小上限
+const MaxQRCodeImageSize = 2 << 20
+
+// qrCodeIDPattern 二维码ID格式（同时用作上传文件名）
+var qrCodeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// 收款码管理错误
+var (
+	ErrQRCodeNotFound     = errors.New("qr code not found")
+	ErrInvalidQRCodeID    = errors.New("invalid qr code id: use 1-32 letters, digits, '_' or '-'")
+	ErrInvalidQRCodeImage = errors.New("invalid qr code image: must be a PNG or JPEG no larger than 2MB")
+)
+
+// QRCodeManager 收款码运行时管理
+// @description 合并配置文件与数据库中保存的收款码设置，修改后立即同步到二维码选择器，无需重启
+type QRCodeManager struct {
+	cfg      *config.Config
+	db       *database.DB
+	selector *QRCodeSelector                 // 经营码模式未启用时为nil
+	qrCodes  []config.QRCode                 // 全部二维码（含未启用的）
+	settings map[string]*model.QRCodeSetting // 已保存到数据库的设置
+	mu       sync.RWMutex
+}
+
+// NewQRCodeManager 创建收款码管理服务
+// @description 加载数据库中保存的设置覆盖配置文件中的同ID二维码，并刷新选择器
+// @param cfg 配置
+// @param db 数据库
+// @param selector 二维码选择器（可为nil）
+// @return *QRCodeManager 收款码管理服务
+// @return error 加载错误
+func NewQRCodeManager(cfg *config.Config, db *database.DB, selector *QRCodeSelector) (*QRCodeManager, error) {
+	settings, err := db.GetQRCodeSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &QRCodeManager{
+		cfg:      cfg,
+		db:       db,
+		selector: selector,
+		qrCodes:  append([]config.QRCode(nil), cfg.Payment.BusinessQRMode.QRCodePaths...),
+		settings: make(map[string]*model.QRCodeSetting, len(settings)),
+	}
+
+	for _, setting := range settings {
+		m.settings[setting.ID] = setting
+		m.applySetting(setting)
+	}
+
+	if len(settings) > 0 {
+		m.selector.Reload(m.qrCodes)
+		logger.Info("Loaded QR code settings from database", zap.Int("count", len(settings)))
+	}
+
+	return m, nil
+}
+
+// List 获取全部二维码（按优先级排序）
+func (m *QRCodeManager) List() []config.QRCode {
+	m.mu.RLock()
+	qrCodes := append([]config.QRCode(nil), m.qrCodes...)
+	m.mu.RUnlock()
+
+	sort.SliceStable(qrCodes, func(i, j int) bool {
+		return qrCodes[i].Priority < qrCodes[j].Priority
+	})
+	return qrCodes
+}
+
+// Get 根据ID获取二维码（包括已禁用的，供已分配该二维码的订单使用）
+func (m *QRCodeManager) Get(id string) (config.QRCode, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.find(id)
+}
+
+// Upload 上传收款码图片
+// @description 新建二维码或替换已有二维码的图片，图片保存到 upload_dir
+// @param id 二维码ID
+// @param codeID 支付宝收款码ID（为空时保留原值）
+// @param priority 优先级
+// @param enabled 是否启用
+// @param image 图片内容（PNG/JPEG）
+// @return config.QRCode 保存后的二维码
+// @return error 保存错误
+func (m *QRCodeManager) Upload(id, codeID string, priority int, enabled bool, image []byte) (config.QRCode, error) {
+	if !qrCodeIDPattern.MatchString(id) {
+		return config.QRCode{}, ErrInvalidQRCodeID
+	}
+	if len(image) == 0 || len(image) > MaxQRCodeImageSize {
+		return config.QRCode{}, ErrInvalidQRCodeImage
+	}
+
+	var ext string
+	switch http.DetectContentType(image) {
+	case "image/png":
+		ext = ".png"
+	case "image/jpeg":
+		ext = ".jpg"
+	default:
+		return config.QRCode{}, ErrInvalidQRCodeImage
+	}
+
+	path, err := m.writeImage(id+ext, image)
+	if err != nil {
+		return config.QRCode{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	setting := m.settingFor(id)
+	setting.Path = path
+	if codeID != "" {
+		setting.CodeID = codeID
+	}
+	setting.Enabled = enabled
+	setting.Priority = priority
+
+	if err := m.save(setting); err != nil {
+		return config.QRCode{}, err
+	}
+
+	logger.Info("QR code uploaded",
+		zap.String("qr_id", id),
+		zap.String("path", path),
+		zap.Bool("enabled", enabled),
+		zap.Int("priority", priority))
+
+	qr, _ := m.find(id)
+	return qr, nil
+}
+
+// Update 启用/禁用二维码或调整优先级
+// @param id 二维码ID
+// @param enabled 是否启用（nil不修改）
+// @param priority 优先级（nil不修改）
+// @return config.QRCode 修改后的二维码
+// @return error 修改错误
+func (m *QRCodeManager) Update(id string, enabled *bool, priority *int) (config.QRCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.find(id)
+	if !ok {
+		return config.QRCode{}, ErrQRCodeNotFound
+	}
+
+	setting := m.settingFor(id)
+	setting.Enabled = current.Enabled
+	setting.Priority = current.Priority
+	if enabled != nil {
+		setting.Enabled = *enabled
+	}
+	if priority != nil {
+		setting.Priority = *priority
+	}
+
+	if err := m.save(setting); err != nil {
+		return config.QRCode{}, err
+	}
+
+	logger.Info("QR code updated",
+		zap.String("qr_id", id),
+		zap.Bool("enabled", setting.Enabled),
+		zap.Int("priority", setting.Priority))
+
+	qr, _ := m.find(id)
+	return qr, nil
+}
+
+// ReloadConfig 使用新的配置文件二维码列表（配置热加载时使用）
+// @description 重新合并数据库中保存的设置并刷新选择器
+// @param qrCodes 配置文件中的二维码列表
+func (m *QRCodeManager) ReloadConfig(qrCodes []config.QRCode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.qrCodes = append([]config.QRCode(nil), qrCodes...)
+
+	ids := make([]string, 0, len(m.settings))
+	for id := range m.settings {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		m.applySetting(m.settings[id])
+	}
+
+	m.selector.Reload(m.qrCodes)
+}
+
+// writeImage 写入上传的图片（先写临时文件再重命名，避免读取到不完整的图片）
+func (m *QRCodeManager) writeImage(name string, image []byte) (string, error) {
+	dir := m.cfg.Payment.BusinessQRMode.UploadDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload dir: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, image, 0644); err != nil {
+		return "", fmt.Errorf("failed to write qr code image: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to save qr code image: %w", err)
+	}
+
+	return path, nil
+}
+
+// settingFor 获取二维码已保存的设置副本，不存在时新建（调用方需持有锁）
+func (m *QRCodeManager) settingFor(id string) *model.QRCodeSetting {
+	if existing, ok := m.settings[id]; ok {
+		setting := *existing
+		return &setting
+	}
+
+	setting := &model.QRCodeSetting{ID: id, Enabled: true}
+	if current, ok := m.find(id); ok {
+		setting.Enabled = current.Enabled
+		setting.Priority = current.Priority
+	}
+	return setting
+}
+
+// save 持久化设置并刷新选择器（调用方需持有锁）
+func (m *QRCodeManager) save(setting *model.QRCodeSetting) error {
+	setting.UpdatedAt = time.Now()
+	if err := m.db.SaveQRCodeSetting(setting); err != nil {
+		return err
+	}
+
+	m.settings[setting.ID] = setting
+	m.applySetting(setting)
+	m.selector.Reload(m.qrCodes)
+	return nil
+}
+
+// applySetting 将保存的设置合并到二维码列表（路径和收款码ID为空时沿用配置文件，调用方需持有锁）
+func (m *QRCodeManager) applySetting(setting *model.QRCodeSetting) {
+	for i := range m.qrCodes {
+		qr := &m.qrCodes[i]
+		if qr.ID != setting.ID {
+			continue
+		}
+		if setting.Path != "" {
+			qr.Path = setting.Path
+		}
+		if setting.CodeID != "" {
+			qr.CodeID = setting.CodeID
+		}
+		qr.Enabled = setting.Enabled
+		qr.Priority = setting.Priority
+		return
+	}
+
+	// 配置文件中已删除的二维码
+	if setting.Path == "" {
+		return
+	}
+
+	// 管理后台新增的二维码（使用全局支付宝配置）
+	m.qrCodes = append(m.qrCodes, config.QRCode{
+		ID:       setting.ID,
+		Path:     setting.Path,
+		CodeID:   setting.CodeID,
+		Enabled:  setting.Enabled,
+		Priority: setting.Priority,
+	})
+}
+
+// find 根据ID查找二维码（调用方需持有锁）
+func (m *QRCodeManager) find(id string) (config.QRCode, bool) {
+	for _, qr := range m.qrCodes {
+		if qr.ID == id {
+			return qr, true
+		}
+	}
+	return config.QRCode{}, false
+}
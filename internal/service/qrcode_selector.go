@@ -1,52 +1,46 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
 
 	"go.uber.org/zap"
 )
 
+// ErrQRCodeQuotaReached 全部二维码都已达到当天的限额
+var ErrQRCodeQuotaReached = errors.New("all QR codes have reached their daily quota")
+
 // QRCodeSelector 二维码选择器
-// @description 负责选择和分配二维码给订单
+// @description 负责选择和分配二维码给订单，跳过已达到每日限额和不健康的二维码，优先选择金额区间匹配的二维码
 type QRCodeSelector struct {
 	cfg          *config.Config
+	db           *database.DB // 统计每日限额的用量（为nil时不检查限额）
 	qrCodes      []config.QRCode
 	currentIndex int
 	usageCount   map[string]int
 	lastUsedTime map[string]time.Time
+	quotaReached map[string]string    // 达到每日限额的二维码及日期（每天只记录一次日志）
+	health       *QRCodeHealthChecker // 收款码健康检查（未启用时为nil）
 	mu           sync.RWMutex
 	pollingMode  string
 }
 
 // NewQRCodeSelector 创建二维码选择器
-func NewQRCodeSelector(cfg *config.Config) *QRCodeSelector {
-	// 过滤出启用的二维码并按优先级排序
-	var enabledQRCodes []config.QRCode
-	for _, qr := range cfg.Payment.BusinessQRMode.QRCodePaths {
-		if qr.Enabled {
-			enabledQRCodes = append(enabledQRCodes, qr)
-		}
-	}
+func NewQRCodeSelector(cfg *config.Config, db *database.DB) *QRCodeSelector {
+	enabledQRCodes := enabledQRCodesByPriority(cfg.Payment.BusinessQRMode.QRCodePaths)
 
-	// 如果没有配置多个二维码，返回nil（使用传统单二维码模式）
+	// 没有启用的二维码时使用传统单二维码模式，之后可通过 Reload 启用
 	if len(enabledQRCodes) == 0 {
 		logger.Warn("No enabled QR codes found, falling back to single QR code mode")
-		return nil
-	}
-
-	// 按优先级排序（优先级数字越小越高）
-	for i := 0; i < len(enabledQRCodes)-1; i++ {
-		for j := i + 1; j < len(enabledQRCodes); j++ {
-			if enabledQRCodes[i].Priority > enabledQRCodes[j].Priority {
-				enabledQRCodes[i], enabledQRCodes[j] = enabledQRCodes[j], enabledQRCodes[i]
-			}
-		}
 	}
 
 	pollingMode := cfg.Payment.BusinessQRMode.PollingMode
@@ -56,10 +50,12 @@ func NewQRCodeSelector(cfg *config.Config) *QRCodeSelector {
 
 	selector := &QRCodeSelector{
 		cfg:          cfg,
+		db:           db,
 		qrCodes:      enabledQRCodes,
 		currentIndex: 0,
 		usageCount:   make(map[string]int),
 		lastUsedTime: make(map[string]time.Time),
+		quotaReached: make(map[string]string),
 		pollingMode:  pollingMode,
 	}
 
@@ -70,29 +66,100 @@ func NewQRCodeSelector(cfg *config.Config) *QRCodeSelector {
 	return selector
 }
 
+// enabledQRCodesByPriority 过滤出启用的二维码并按优先级排序（数字越小优先级越高）
+func enabledQRCodesByPriority(qrCodes []config.QRCode) []config.QRCode {
+	var enabledQRCodes []config.QRCode
+	for _, qr := range qrCodes {
+		if qr.Enabled {
+			enabledQRCodes = append(enabledQRCodes, qr)
+		}
+	}
+
+	sort.SliceStable(enabledQRCodes, func(i, j int) bool {
+		return enabledQRCodes[i].Priority < enabledQRCodes[j].Priority
+	})
+
+	return enabledQRCodes
+}
+
+// SetHealthChecker 设置收款码健康检查（不健康的二维码不再分配给新订单）
+// @param health 收款码健康检查
+func (s *QRCodeSelector) SetHealthChecker(health *QRCodeHealthChecker) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.health = health
+	s.mu.Unlock()
+}
+
+// Reload 重新加载二维码列表
+// @description 运行时修改二维码（上传、启用/禁用、调整优先级）后调用，保留已有的使用统计
+// @param qrCodes 全部二维码（含未启用的）
+func (s *QRCodeSelector) Reload(qrCodes []config.QRCode) {
+	if s == nil {
+		return
+	}
+
+	enabledQRCodes := enabledQRCodesByPriority(qrCodes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.qrCodes = enabledQRCodes
+	if s.currentIndex >= len(enabledQRCodes) {
+		s.currentIndex = 0
+	}
+
+	logger.Info("QR code selector reloaded",
+		zap.Int("qr_code_count", len(enabledQRCodes)))
+}
+
 // SelectQRCode 选择一个二维码
-// @description 根据配置的轮询模式选择二维码
+// @description 根据配置的轮询模式，在未达到每日限额的二维码中选择；有金额区间匹配的二维码时只在其中选择
+// @param amount 订单实付金额（检查收款金额限额和金额区间）
 // @return *config.QRCode 选中的二维码
-// @return error 选择错误
-func (s *QRCodeSelector) SelectQRCode() (*config.QRCode, error) {
-	if s == nil || len(s.qrCodes) == 0 {
+// @return error 选择错误，全部二维码都已达到限额时为 ErrQRCodeQuotaReached
+func (s *QRCodeSelector) SelectQRCode(amount model.Amount) (*config.QRCode, error) {
+	if s == nil {
 		return nil, fmt.Errorf("no available QR codes")
 	}
 
+	// 查询数据库不持有锁
+	usage := s.dailyUsage()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if len(s.qrCodes) == 0 {
+		return nil, fmt.Errorf("no available QR codes")
+	}
+
+	available := make([]bool, len(s.qrCodes))
+	availableCount := 0
+	for i := range s.qrCodes {
+		if s.withinQuota(&s.qrCodes[i], usage, amount) {
+			available[i] = true
+			availableCount++
+		}
+	}
+	if availableCount == 0 {
+		return nil, ErrQRCodeQuotaReached
+	}
+	available, availableCount = s.excludeUnhealthy(available, availableCount)
+	available, availableCount = s.preferAmountRange(available, availableCount, amount)
+
 	var selected *config.QRCode
 
 	switch s.pollingMode {
 	case "round_robin":
-		selected = s.selectRoundRobin()
+		selected = s.selectRoundRobin(available)
 	case "random":
-		selected = s.selectRandom()
+		selected = s.selectRandom(available, availableCount)
 	case "least_used":
-		selected = s.selectLeastUsed()
+		selected = s.selectLeastUsed(available)
 	default:
-		selected = s.selectRoundRobin()
+		selected = s.selectRoundRobin(available)
 	}
 
 	if selected == nil {
@@ -111,25 +178,109 @@ func (s *QRCodeSelector) SelectQRCode() (*config.QRCode, error) {
 	return selected, nil
 }
 
-// selectRoundRobin 轮询选择
-func (s *QRCodeSelector) selectRoundRobin() *config.QRCode {
-	selected := &s.qrCodes[s.currentIndex]
-	s.currentIndex = (s.currentIndex + 1) % len(s.qrCodes)
-	return selected
+// excludeUnhealthy 从可用的二维码中排除不健康的（调用方需持有锁）
+// 可用的二维码全部不健康时仍然分配，避免误判导致无法下单
+func (s *QRCodeSelector) excludeUnhealthy(available []bool, availableCount int) ([]bool, int) {
+	if s.health == nil {
+		return available, availableCount
+	}
+
+	healthy := make([]bool, len(available))
+	healthyCount := 0
+	for i := range s.qrCodes {
+		if available[i] && s.health.IsHealthy(s.qrCodes[i].ID) {
+			healthy[i] = true
+			healthyCount++
+		}
+	}
+	if healthyCount == 0 {
+		logger.Warn("All available QR codes are unhealthy, assigning anyway")
+		return available, availableCount
+	}
+	return healthy, healthyCount
 }
 
-// selectRandom 随机选择
-func (s *QRCodeSelector) selectRandom() *config.QRCode {
-	idx := rand.Intn(len(s.qrCodes))
-	return &s.qrCodes[idx]
+// preferAmountRange 按金额区间缩小可用的二维码范围（调用方需持有锁）
+// 依次选择：设置了区间且包含本订单金额的二维码、未设置区间的二维码、全部可用的二维码，不因金额区间拒绝下单
+func (s *QRCodeSelector) preferAmountRange(available []bool, availableCount int, amount model.Amount) ([]bool, int) {
+	matched, matchedCount := s.filterAvailable(available, func(qr *config.QRCode) bool {
+		return hasAmountRange(qr) && inAmountRange(qr, amount)
+	})
+	if matchedCount > 0 {
+		return matched, matchedCount
+	}
+
+	unranged, unrangedCount := s.filterAvailable(available, func(qr *config.QRCode) bool {
+		return !hasAmountRange(qr)
+	})
+	if unrangedCount > 0 {
+		return unranged, unrangedCount
+	}
+
+	logger.Info("No QR code amount range matches the order, assigning from all available",
+		zap.Stringer("amount", amount))
+	return available, availableCount
 }
 
-// selectLeastUsed 选择使用次数最少的
-func (s *QRCodeSelector) selectLeastUsed() *config.QRCode {
+// filterAvailable 从可用的二维码中选出满足条件的（调用方需持有锁）
+func (s *QRCodeSelector) filterAvailable(available []bool, keep func(qr *config.QRCode) bool) ([]bool, int) {
+	filtered := make([]bool, len(available))
+	count := 0
+	for i := range s.qrCodes {
+		if available[i] && keep(&s.qrCodes[i]) {
+			filtered[i] = true
+			count++
+		}
+	}
+	return filtered, count
+}
+
+// hasAmountRange 二维码是否设置了金额区间（下限或上限）
+func hasAmountRange(qr *config.QRCode) bool {
+	return qr.MinAmount > 0 || qr.MaxAmount > 0
+}
+
+// inAmountRange 订单金额是否在二维码的金额区间内（未设置的下限或上限不限制）
+func inAmountRange(qr *config.QRCode, amount model.Amount) bool {
+	return (qr.MinAmount <= 0 || amount >= qr.MinAmount) && (qr.MaxAmount <= 0 || amount <= qr.MaxAmount)
+}
+
+// selectRoundRobin 轮询选择（跳过不可用的二维码）
+func (s *QRCodeSelector) selectRoundRobin(available []bool) *config.QRCode {
+	for range s.qrCodes {
+		idx := s.currentIndex
+		s.currentIndex = (s.currentIndex + 1) % len(s.qrCodes)
+		if available[idx] {
+			return &s.qrCodes[idx]
+		}
+	}
+	return nil
+}
+
+// selectRandom 在可用的二维码中随机选择
+func (s *QRCodeSelector) selectRandom(available []bool, availableCount int) *config.QRCode {
+	n := rand.Intn(availableCount)
+	for i := range s.qrCodes {
+		if !available[i] {
+			continue
+		}
+		if n == 0 {
+			return &s.qrCodes[i]
+		}
+		n--
+	}
+	return nil
+}
+
+// selectLeastUsed 在可用的二维码中选择使用次数最少的
+func (s *QRCodeSelector) selectLeastUsed(available []bool) *config.QRCode {
 	var selected *config.QRCode
 	minUsage := -1
 
 	for i := range s.qrCodes {
+		if !available[i] {
+			continue
+		}
 		qr := &s.qrCodes[i]
 		usage := s.usageCount[qr.ID]
 
@@ -142,6 +293,69 @@ func (s *QRCodeSelector) selectLeastUsed() *config.QRCode {
 	return selected
 }
 
+// dailyUsage 查询当天各二维码的用量（没有二维码配置限额时不查询）
+// 查询失败时返回nil，不限制分配，避免数据库异常导致无法下单
+func (s *QRCodeSelector) dailyUsage() map[string]*database.QRCodeUsage {
+	if s.db == nil || !s.hasQuota() {
+		return nil
+	}
+
+	usage, err := s.TodayUsage()
+	if err != nil {
+		logger.Warn("Failed to get QR code daily usage, quotas not enforced", zap.Error(err))
+		return nil
+	}
+	return usage
+}
+
+// TodayUsage 当天（0点起）各二维码分配的待支付和已支付订单数及金额
+func (s *QRCodeSelector) TodayUsage() (map[string]*database.QRCodeUsage, error) {
+	if s == nil || s.db == nil {
+		return map[string]*database.QRCodeUsage{}, nil
+	}
+	now := time.Now()
+	return s.db.GetQRCodeUsage(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+}
+
+// hasQuota 是否有二维码配置了每日限额
+func (s *QRCodeSelector) hasQuota() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, qr := range s.qrCodes {
+		if qr.MaxDailyAmount > 0 || qr.MaxDailyCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withinQuota 分配本订单后是否仍在二维码的每日限额内（调用方需持有锁）
+func (s *QRCodeSelector) withinQuota(qr *config.QRCode, usage map[string]*database.QRCodeUsage, amount model.Amount) bool {
+	u := usage[qr.ID]
+	if u == nil {
+		u = &database.QRCodeUsage{}
+	}
+	if (qr.MaxDailyCount <= 0 || u.Count < qr.MaxDailyCount) &&
+		(qr.MaxDailyAmount <= 0 || u.Amount+amount <= qr.MaxDailyAmount) {
+		return true
+	}
+
+	// 订单数达到限额时每天记录一次；金额只是容纳不下本订单时，更小的订单仍可分配，不记录
+	today := time.Now().Format("2006-01-02")
+	if s.quotaReached[qr.ID] != today && (qr.MaxDailyCount > 0 && u.Count >= qr.MaxDailyCount ||
+		qr.MaxDailyAmount > 0 && u.Amount >= qr.MaxDailyAmount) {
+		s.quotaReached[qr.ID] = today
+		logger.Warn("QR code reached daily quota, skipped until tomorrow",
+			zap.String("qr_id", qr.ID),
+			zap.Int("count", u.Count),
+			zap.Int("max_daily_count", qr.MaxDailyCount),
+			zap.Stringer("amount", u.Amount),
+			zap.Stringer("max_daily_amount", qr.MaxDailyAmount))
+	}
+	return false
+}
+
 // GetQRCodeByID 根据ID获取二维码
 // @description 根据二维码ID获取二维码配置
 // @param id 二维码ID
@@ -177,13 +391,20 @@ func (s *QRCodeSelector) GetStats() map[string]interface{} {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	today := time.Now().Format("2006-01-02")
 	stats := make([]map[string]interface{}, 0, len(s.qrCodes))
 	for _, qr := range s.qrCodes {
 		stats = append(stats, map[string]interface{}{
-			"id":             qr.ID,
-			"usage_count":    s.usageCount[qr.ID],
-			"last_used_time": s.lastUsedTime[qr.ID],
-			"priority":       qr.Priority,
+			"id":               qr.ID,
+			"usage_count":      s.usageCount[qr.ID],
+			"last_used_time":   s.lastUsedTime[qr.ID],
+			"priority":         qr.Priority,
+			"max_daily_amount": qr.MaxDailyAmount,
+			"max_daily_count":  qr.MaxDailyCount,
+			"min_amount":       qr.MinAmount,
+			"max_amount":       qr.MaxAmount,
+			"quota_reached":    s.quotaReached[qr.ID] == today,
+			"healthy":          s.health.IsHealthy(qr.ID),
 		})
 	}
 
@@ -195,6 +416,37 @@ func (s *QRCodeSelector) GetStats() map[string]interface{} {
 	}
 }
 
+// GetUsageCounts 获取各二维码的分配次数（本次启动以来）
+func (s *QRCodeSelector) GetUsageCounts() map[string]int {
+	counts := make(map[string]int)
+	if s == nil {
+		return counts
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, count := range s.usageCount {
+		counts[id] = count
+	}
+	return counts
+}
+
+// QRCodeIDs 获取启用的二维码ID（按优先级排序）
+func (s *QRCodeSelector) QRCodeIDs() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.qrCodes))
+	for _, qr := range s.qrCodes {
+		ids = append(ids, qr.ID)
+	}
+	return ids
+}
+
 // GetQRCodeCount 获取可用二维码数量
 func (s *QRCodeSelector) GetQRCodeCount() int {
 	if s == nil {
@@ -207,5 +459,5 @@ func (s *QRCodeSelector) GetQRCodeCount() int {
 
 // IsEnabled 检查是否启用了多二维码模式
 func (s *QRCodeSelector) IsEnabled() bool {
-	return s != nil && len(s.qrCodes) > 0
+	return s.GetQRCodeCount() > 0
 }
@@ -0,0 +1,252 @@
+// Package service 退款申请
+// @author AliMPay Team
+// @description 目前无法自动原路退款：商户通过签名接口提交退款申请并轮询审核结果，
+// 管理员在后台审核，同意后在支付宝中手动退款；同意的金额累计达到订单金额时订单标记为已退款
+package service
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/money"
+	"alimpay-go/internal/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRefundReasonLength 退款原因、审核备注的最大长度（字符）
+	maxRefundReasonLength = 256
+	// maxOutRefundNoLength 商户退款单号的最大长度
+	maxOutRefundNoLength = 64
+	// refundNoPrefix 退款申请号前缀
+	refundNoPrefix = "R"
+)
+
+// 退款申请错误（错误信息直接返回给商户）
+var (
+	ErrInvalidRefundRequest   = errors.New("invalid refund request")
+	ErrRefundOrderNotPaid     = errors.New("only paid orders can be refunded")
+	ErrRefundAmountExceeded   = errors.New("refund amount exceeds the refundable amount of the order")
+	ErrRefundRequestNotFound  = errors.New("refund request not found")
+	ErrRefundRequestProcessed = errors.New("refund request has already been processed")
+	ErrOutRefundNoConflict    = errors.New("out_refund_no is already used by another order")
+)
+
+// RefundRequestParams 商户提交的退款申请参数
+type RefundRequestParams struct {
+	TradeNo     string // 订单号（与商户订单号二选一）
+	OutTradeNo  string // 商户订单号
+	OutRefundNo string // 商户退款单号（可选，用于重复提交时返回已有申请）
+	Money       string // 退款金额（元）
+	Reason      string // 退款原因
+}
+
+// RequestRefund 提交退款申请（商户凭据由调用方验证）
+// @description 只有已支付订单可以申请，同一订单待审核和已同意的退款金额合计不超过订单金额；
+// 传入已使用的商户退款单号时返回已有申请，不重复创建
+// @param pid 商户ID
+// @param params 退款申请参数
+// @return *model.RefundRequest 退款申请
+// @return bool 是否为本次新建的申请
+func (s *CodePayService) RequestRefund(pid string, params RefundRequestParams) (*model.RefundRequest, bool, error) {
+	if params.TradeNo == "" && params.OutTradeNo == "" {
+		return nil, false, fmt.Errorf("%w: missing trade_no or out_trade_no", ErrInvalidRefundRequest)
+	}
+	if params.Money == "" {
+		return nil, false, fmt.Errorf("%w: missing money", ErrInvalidRefundRequest)
+	}
+	if len(params.OutRefundNo) > maxOutRefundNoLength {
+		return nil, false, fmt.Errorf("%w: out_refund_no must not exceed %d characters", ErrInvalidRefundRequest, maxOutRefundNoLength)
+	}
+	if utf8.RuneCountInString(params.Reason) > maxRefundReasonLength {
+		return nil, false, fmt.Errorf("%w: reason must not exceed %d characters", ErrInvalidRefundRequest, maxRefundReasonLength)
+	}
+	amount, err := money.ParseOrderAmount(params.Money)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: invalid money: %v", ErrInvalidRefundRequest, err)
+	}
+
+	order, err := s.merchantOrder(pid, params.TradeNo, params.OutTradeNo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 重复提交（商户重试）返回已有申请
+	if params.OutRefundNo != "" {
+		existing, err := s.db.GetRefundRequestByOutRefundNo(pid, params.OutRefundNo)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			if existing.OrderID != order.ID {
+				return nil, false, ErrOutRefundNoConflict
+			}
+			return existing, false, nil
+		}
+	}
+
+	if order.Status != model.OrderStatusPaid {
+		return nil, false, ErrRefundOrderNotPaid
+	}
+
+	request := &model.RefundRequest{
+		RefundNo:    refundNoPrefix + utils.GenerateTradeNo(),
+		OutRefundNo: params.OutRefundNo,
+		OrderID:     order.ID,
+		OutTradeNo:  order.OutTradeNo,
+		PID:         pid,
+		Amount:      amount,
+		Reason:      params.Reason,
+		Status:      model.RefundStatusPending,
+	}
+	created, err := s.db.CreateRefundRequest(request, order.Price)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		return nil, false, ErrRefundAmountExceeded
+	}
+
+	s.db.RecordOrderEvent(order.ID, model.OrderEventRefundRequested,
+		fmt.Sprintf("退款申请号: %s, 金额: %s, 原因: %s", request.RefundNo, request.Amount, request.Reason))
+	logger.Info("Refund requested",
+		zap.String("refund_no", request.RefundNo),
+		zap.String("trade_no", order.ID),
+		zap.String("out_trade_no", order.OutTradeNo),
+		zap.Stringer("amount", request.Amount))
+
+	return request, true, nil
+}
+
+// GetRefundRequest 查询商户的退款申请
+// @param pid 商户ID
+// @param refundNo 退款申请号（与商户退款单号二选一）
+// @param outRefundNo 商户退款单号
+// @return *model.RefundRequest 退款申请
+func (s *CodePayService) GetRefundRequest(pid, refundNo, outRefundNo string) (*model.RefundRequest, error) {
+	if refundNo == "" && outRefundNo == "" {
+		return nil, fmt.Errorf("%w: missing refund_no or out_refund_no", ErrInvalidRefundRequest)
+	}
+
+	var request *model.RefundRequest
+	var err error
+	if refundNo != "" {
+		request, err = s.db.GetRefundRequest(refundNo)
+	} else {
+		request, err = s.db.GetRefundRequestByOutRefundNo(pid, outRefundNo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.PID != pid {
+		return nil, ErrRefundRequestNotFound
+	}
+	return request, nil
+}
+
+// ApproveRefundRequest 同意退款申请（退款需在支付宝中手动处理）
+// @description 订单已同意的退款金额累计达到订单金额时，订单标记为已退款
+// @param refundNo 退款申请号
+// @param note 审核备注
+// @return *model.RefundRequest 审核后的退款申请
+func (s *CodePayService) ApproveRefundRequest(refundNo, note string) (*model.RefundRequest, error) {
+	request, err := s.processRefundRequest(refundNo, model.RefundStatusApproved, note)
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.RecordOrderEvent(request.OrderID, model.OrderEventRefundApproved,
+		fmt.Sprintf("退款申请号: %s, 金额: %s", request.RefundNo, request.Amount))
+
+	order, err := s.db.GetOrderByID(request.OrderID)
+	if err != nil || order == nil {
+		logger.Warn("Failed to load order of approved refund request",
+			zap.String("refund_no", request.RefundNo),
+			zap.String("trade_no", request.OrderID),
+			zap.Error(err))
+		return request, nil
+	}
+
+	approved, err := s.db.GetApprovedRefundAmount(order.ID)
+	if err != nil {
+		logger.Warn("Failed to get approved refund amount", zap.String("trade_no", order.ID), zap.Error(err))
+		return request, nil
+	}
+	if approved < order.Price || order.Status != model.OrderStatusPaid {
+		return request, nil
+	}
+
+	if err := s.states.Refund(order, fmt.Sprintf("退款申请 %s 已同意，累计退款 %s 元", request.RefundNo, approved)); err != nil {
+		logger.Warn("Failed to mark order as refunded",
+			zap.String("trade_no", order.ID),
+			zap.String("refund_no", request.RefundNo),
+			zap.Error(err))
+	}
+	return request, nil
+}
+
+// RejectRefundRequest 拒绝退款申请
+// @param refundNo 退款申请号
+// @param note 拒绝原因（商户查询时可见）
+// @return *model.RefundRequest 审核后的退款申请
+func (s *CodePayService) RejectRefundRequest(refundNo, note string) (*model.RefundRequest, error) {
+	request, err := s.processRefundRequest(refundNo, model.RefundStatusRejected, note)
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.RecordOrderEvent(request.OrderID, model.OrderEventRefundRejected,
+		fmt.Sprintf("退款申请号: %s, 原因: %s", request.RefundNo, note))
+	return request, nil
+}
+
+// processRefundRequest 审核待审核的退款申请
+func (s *CodePayService) processRefundRequest(refundNo, status, note string) (*model.RefundRequest, error) {
+	if utf8.RuneCountInString(note) > maxRefundReasonLength {
+		return nil, fmt.Errorf("%w: note must not exceed %d characters", ErrInvalidRefundRequest, maxRefundReasonLength)
+	}
+
+	processed, err := s.db.ProcessRefundRequest(refundNo, status, note)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := s.db.GetRefundRequest(refundNo)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrRefundRequestNotFound
+	}
+	if !processed {
+		return nil, ErrRefundRequestProcessed
+	}
+
+	logger.Info("Refund request processed",
+		zap.String("refund_no", refundNo),
+		zap.String("trade_no", request.OrderID),
+		zap.String("status", status))
+	return request, nil
+}
+
+// merchantOrder 按订单号或商户订单号查询商户的订单
+func (s *CodePayService) merchantOrder(pid, tradeNo, outTradeNo string) (*model.Order, error) {
+	var order *model.Order
+	var err error
+	if tradeNo != "" {
+		order, err = s.db.GetOrderByID(tradeNo)
+	} else {
+		order, err = s.db.GetOrderByOutTradeNo(outTradeNo, pid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.PID != pid {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
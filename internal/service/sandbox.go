@@ -0,0 +1,183 @@
+// Package service 商户沙箱
+// @author AliMPay Team
+// @description 为商户签发独立的沙箱商户ID和密钥。沙箱订单不分配真实收款码、不调用支付宝接口，
+// 在支付页面确认支付后由监听服务匹配模拟账单，再按正常流程签名通知商户，
+// 商户可在生产环境完整测试 下单 → 支付 → 异步通知 → 同步跳转 的流程
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/i18n"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// sandboxBillTradeNoPrefix 沙箱模拟账单的支付宝交易号前缀
+const sandboxBillTradeNoPrefix = "SANDBOX"
+
+// ErrNotSandboxOrder 订单不是沙箱订单
+var ErrNotSandboxOrder = errors.New("not a sandbox order")
+
+// initSandbox 加载已签发的沙箱凭据
+func (s *CodePayService) initSandbox() error {
+	id, err := s.db.GetSetting(database.SettingSandboxMerchantID)
+	if err != nil {
+		return err
+	}
+	key, err := s.db.GetSetting(database.SettingSandboxMerchantKey)
+	if err != nil {
+		return err
+	}
+
+	s.sandboxID = id
+	s.sandboxKey = key
+	s.sandboxBills = NewSyntheticBillSource()
+
+	// 沙箱订单支付、关闭或过期后不再需要模拟账单
+	for _, event := range []string{events.EventOrderPaid, events.EventOrderClosed, events.EventOrderExpired} {
+		events.Subscribe(event, s.onSandboxOrderDone)
+	}
+
+	if id != "" && key != "" {
+		logger.Info("Loaded sandbox merchant credentials", zap.String("sandbox_merchant_id", id))
+	}
+	return nil
+}
+
+// SandboxCredentials 获取沙箱商户ID和密钥（未签发时均为空）
+func (s *CodePayService) SandboxCredentials() (string, string) {
+	s.sandboxMu.RLock()
+	defer s.sandboxMu.RUnlock()
+	return s.sandboxID, s.sandboxKey
+}
+
+// IssueSandboxCredentials 签发沙箱凭据
+// @description 首次签发时生成沙箱商户ID，之后重新签发只更换密钥（商户ID不变，已有沙箱订单仍可查询），
+// 旧密钥立即失效
+// @return string 沙箱商户ID
+// @return string 沙箱商户密钥
+func (s *CodePayService) IssueSandboxCredentials() (string, string, error) {
+	s.sandboxMu.Lock()
+	defer s.sandboxMu.Unlock()
+
+	id := s.sandboxID
+	if id == "" {
+		// 与正式商户ID同样为16位数字，兼容按数字处理pid的商户SDK
+		for id == "" || id == s.merchantID {
+			id = fmt.Sprintf("9001%012d", utils.RandomInt(0, 999999999999))
+		}
+		if err := s.db.SetSetting(database.SettingSandboxMerchantID, id); err != nil {
+			return "", "", err
+		}
+		s.sandboxID = id
+	}
+
+	key := utils.GenerateMerchantKey()
+	if err := s.db.SetSetting(database.SettingSandboxMerchantKey, key); err != nil {
+		return "", "", err
+	}
+	s.sandboxKey = key
+
+	logger.Info("Sandbox merchant credentials issued", zap.String("sandbox_merchant_id", id))
+	return id, key, nil
+}
+
+// IsSandboxMerchant 判断商户ID是否为沙箱商户
+func (s *CodePayService) IsSandboxMerchant(pid string) bool {
+	s.sandboxMu.RLock()
+	defer s.sandboxMu.RUnlock()
+	return pid != "" && pid == s.sandboxID
+}
+
+// merchantKeyFor 获取商户ID对应的签名密钥（正式商户或已签发的沙箱商户）
+// @return bool 商户ID是否有效
+func (s *CodePayService) merchantKeyFor(pid string) (string, bool) {
+	if pid == s.merchantID {
+		return s.GetMerchantKey(), true
+	}
+
+	s.sandboxMu.RLock()
+	defer s.sandboxMu.RUnlock()
+	if pid != "" && pid == s.sandboxID && s.sandboxKey != "" {
+		return s.sandboxKey, true
+	}
+	return "", false
+}
+
+// SandboxBills 沙箱订单的模拟账单来源（监听服务匹配沙箱订单时使用）
+func (s *CodePayService) SandboxBills() *SyntheticBillSource {
+	return s.sandboxBills
+}
+
+// ConfirmSandboxOrder 模拟支付沙箱订单
+// @description 为订单添加一条模拟账单，监听服务下一周期匹配后按正常流程更新订单并通知商户；
+// 非待支付订单不做处理
+// @param tradeNo 系统交易号
+// @return *model.Order 订单
+// @return error 订单不存在返回 ErrOrderNotFound，非沙箱订单返回 ErrNotSandboxOrder
+func (s *CodePayService) ConfirmSandboxOrder(tradeNo string) (*model.Order, error) {
+	order, err := s.db.GetOrderByID(tradeNo)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if !order.IsSandbox() {
+		return nil, ErrNotSandboxOrder
+	}
+	if order.Status != model.OrderStatusPending {
+		return order, nil
+	}
+
+	s.sandboxBills.Add(order.OutTradeNo, BillRecord{
+		TradeNo: sandboxBillTradeNoPrefix + order.ID,
+		Amount:  order.PaymentAmount,
+		Remark:  order.OutTradeNo,
+		// 账单时间精确到秒，需晚于订单创建时间且在经营码模式的匹配容差内
+		TransDate: order.AddTime.Add(time.Second).Format("2006-01-02 15:04:05"),
+		Direction: "收入",
+	})
+
+	s.db.RecordOrderEvent(order.ID, model.OrderEventSandboxPaid, "")
+
+	logger.Info("Sandbox order confirmed",
+		zap.String("trade_no", order.ID),
+		zap.String("out_trade_no", order.OutTradeNo))
+
+	return order, nil
+}
+
+// onSandboxOrderDone 删除已结束沙箱订单的模拟账单
+func (s *CodePayService) onSandboxOrderDone(data interface{}) {
+	if order, ok := data.(*model.Order); ok && order.IsSandbox() {
+		s.sandboxBills.Remove(order.OutTradeNo)
+	}
+}
+
+// fillSandboxResponse 填充沙箱订单的支付信息（二维码为支付页面地址，在页面上模拟支付）
+func (s *CodePayService) fillSandboxResponse(response map[string]interface{}, order *model.Order, baseURL, lang string) error {
+	paymentPageURL := payPageURL(baseURL, order, lang)
+	qrCodeBase64, err := s.qrGenerator.GenerateToBase64(paymentPageURL)
+	if err != nil {
+		return fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	response["payment_url"] = paymentPageURL
+	response["qr_code"] = qrCodeBase64
+	response["sandbox"] = true
+	response["payment_instruction"] = i18n.T(lang, "tip.sandbox.instruction")
+	response["payment_tips"] = []string{
+		i18n.T(lang, "tip.sandbox.no_charge"),
+		i18n.T(lang, "tip.sandbox.confirm"),
+	}
+	return nil
+}
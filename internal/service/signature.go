@@ -1,12 +1,41 @@
 package service
 
 import (
+	"fmt"
+	"sort"
+
+	"alimpay-go/internal/model"
 	"alimpay-go/internal/pkg/logger"
 	"alimpay-go/internal/pkg/utils"
 
 	"go.uber.org/zap"
 )
 
+// SignType 商户的签名类型（MD5 或 HMAC-SHA256）
+// @description 沙箱商户与正式商户相同，便于在沙箱中测试验签；支付结果通知等按此类型签名
+// @return string 签名类型
+func (s *CodePayService) SignType() string {
+	if utils.IsHMACSignType(s.cfg.Merchant.SignType) {
+		return utils.SignTypeHMACSHA256
+	}
+	return utils.SignTypeMD5
+}
+
+// checkSignType 检查请求的签名类型是否为商户接受的类型
+// @description 商户签名类型为 MD5 时同时接受 MD5 和 HMAC-SHA256（兼容旧版接入）；为 HMAC-SHA256 时不接受MD5签名
+// @param signType 请求参数 sign_type（未传视为 MD5）
+// @return error 不接受时返回原因
+func (s *CodePayService) checkSignType(signType string) error {
+	normalized, ok := utils.NormalizeSignType(signType)
+	if !ok {
+		return fmt.Errorf("unsupported sign_type %q", signType)
+	}
+	if want := s.SignType(); want == utils.SignTypeHMACSHA256 && normalized != want {
+		return fmt.Errorf("sign_type %s is not allowed, want %s", normalized, want)
+	}
+	return nil
+}
+
 // ValidateSignature 验证请求签名
 func (s *CodePayService) ValidateSignature(params map[string]string) bool {
 	receivedSign := params["sign"]
@@ -14,12 +43,23 @@ func (s *CodePayService) ValidateSignature(params map[string]string) bool {
 		logger.Warn("Missing signature in request")
 		return false
 	}
+	if err := s.checkSignType(params["sign_type"]); err != nil {
+		logger.Warn("Signature type rejected", zap.String("pid", params["pid"]), zap.Error(err))
+		return false
+	}
 
-	// 计算签名
-	calculatedSign := utils.GenerateSign(params, s.merchantKey)
+	// 计算签名（沙箱商户使用沙箱密钥）
+	key, ok := s.merchantKeyFor(params["pid"])
+	if !ok {
+		key = s.GetMerchantKey()
+	}
+	calculatedSign := utils.GenerateSign(params, key)
 
-	// 对比签名
-	if receivedSign != calculatedSign {
+	// 对比签名（区分大小写，与签名生成结果完全一致；商户密钥轮换的过渡期内也接受旧密钥的签名）
+	if !utils.SecureCompare(receivedSign, calculatedSign) &&
+		!s.acceptPreviousKey(params["pid"], func(key string) bool {
+			return utils.SecureCompare(receivedSign, utils.GenerateSign(params, key))
+		}) {
 		logger.Warn("Signature mismatch",
 			zap.String("received", receivedSign),
 			zap.String("calculated", calculatedSign))
@@ -28,3 +68,114 @@ func (s *CodePayService) ValidateSignature(params map[string]string) bool {
 
 	return true
 }
+
+// notifyParams 支付结果通知包含的参数（见 paymentResult）
+var notifyParams = map[string]bool{
+	"pid": true, "trade_no": true, "out_trade_no": true, "type": true, "name": true,
+	"money": true, "trade_status": true, "alipay_trade_no": true, "sign": true, "sign_type": true,
+}
+
+// NotifyVerification 支付结果通知的验证结果
+type NotifyVerification struct {
+	Valid       bool     `json:"valid"`            // 签名正确且与订单一致，通知可信
+	SignValid   bool     `json:"sign_valid"`       // 签名是否正确
+	OrderMatch  bool     `json:"order_match"`      // 参数是否与已支付订单一致（签名正确时才核对）
+	SignContent string   `json:"sign_content"`     // 按收到的参数生成的待签名字符串（不含密钥），用于排查签名实现
+	Errors      []string `json:"errors,omitempty"` // 验证失败原因
+}
+
+// VerifyNotification 验证商户收到的支付结果通知（异步通知或同步跳转参数）
+// @description 检查签名，签名正确时再核对订单号、金额、状态等是否与本系统已支付的订单一致，
+// 帮助商户排查自行实现的验签逻辑；不返回正确签名及订单中的值，避免被用于伪造通知或探测订单
+// @param params 商户收到的全部参数（含 sign）
+// @return *NotifyVerification 验证结果
+func (s *CodePayService) VerifyNotification(params map[string]string) (*NotifyVerification, error) {
+	result := &NotifyVerification{SignContent: utils.SignContent(params)}
+	fail := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+	}
+
+	key, ok := s.merchantKeyFor(params["pid"])
+	if !ok {
+		fail("pid %q does not match merchant", params["pid"])
+		key = s.GetMerchantKey()
+	}
+
+	// 通知按商户的签名类型签名（sign_type 未传视为 MD5）
+	signType, _ := utils.NormalizeSignType(params["sign_type"])
+	switch {
+	case params["sign"] == "":
+		fail("missing parameter: sign")
+	case signType != s.SignType():
+		fail("unsupported sign_type %q, want %s", params["sign_type"], s.SignType())
+	default:
+		// 轮换商户密钥前发出的通知使用旧密钥签名
+		result.SignValid = utils.VerifySign(params, key) ||
+			s.acceptPreviousKey(params["pid"], func(key string) bool { return utils.VerifySign(params, key) })
+		if !result.SignValid {
+			fail("signature mismatch")
+			// 多出的参数同样参与签名，常见于框架附加的路由参数
+			var extra []string
+			for key, value := range params {
+				if !notifyParams[key] && value != "" {
+					extra = append(extra, key)
+				}
+			}
+			sort.Strings(extra)
+			for _, key := range extra {
+				fail("unexpected parameter %q is included in the signature", key)
+			}
+		}
+	}
+
+	// 只有签名正确才核对订单，避免未持有密钥的请求借此探测订单信息
+	if result.SignValid {
+		mismatches, err := s.matchNotifyOrder(params)
+		if err != nil {
+			return nil, err
+		}
+		result.Errors = append(result.Errors, mismatches...)
+		result.OrderMatch = len(mismatches) == 0
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// matchNotifyOrder 核对通知参数与订单，返回不一致的项（只指出参数名，不回显订单中的值）
+func (s *CodePayService) matchNotifyOrder(params map[string]string) ([]string, error) {
+	if params["trade_no"] == "" {
+		return []string{"missing parameter: trade_no"}, nil
+	}
+
+	order, err := s.db.GetOrderByID(params["trade_no"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if order == nil || order.PID != params["pid"] {
+		return []string{fmt.Sprintf("order %q not found", params["trade_no"])}, nil
+	}
+
+	var mismatches []string
+	check := func(key, want string) {
+		if params[key] != want {
+			mismatches = append(mismatches, fmt.Sprintf("%s does not match order", key))
+		}
+	}
+	check("out_trade_no", order.OutTradeNo)
+	check("type", order.Type)
+	check("name", order.Name)
+	check("alipay_trade_no", order.AlipayTradeNo)
+
+	if amount, err := model.ParseAmount(params["money"]); err != nil || amount != order.Price {
+		mismatches = append(mismatches, "money does not match order")
+	}
+	if params["trade_status"] != "TRADE_SUCCESS" {
+		mismatches = append(mismatches, fmt.Sprintf("trade_status = %q, want TRADE_SUCCESS", params["trade_status"]))
+	}
+	if order.Status != model.OrderStatusPaid {
+		mismatches = append(mismatches, "order is not paid")
+	}
+
+	return mismatches, nil
+}
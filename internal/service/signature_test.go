@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/utils"
+)
+
+// benchMerchantKey 基准测试商户密钥
+const benchMerchantKey = "bench0123456789abcdef0123456789ab"
+
+// benchSubmitParams 典型的下单请求参数
+func benchSubmitParams() map[string]string {
+	return map[string]string{
+		"pid":          "1001",
+		"type":         model.PaymentTypeAlipay,
+		"out_trade_no": "BENCH20260101000001",
+		"notify_url":   "https://merchant.example.com/notify",
+		"return_url":   "https://merchant.example.com/return",
+		"name":         "会员充值",
+		"money":        "12.30",
+		"price":        "12.30",
+		"sitename":     "bench",
+	}
+}
+
+// BenchmarkGenerateSign 下单/通知的MD5签名
+func BenchmarkGenerateSign(b *testing.B) {
+	params := benchSubmitParams()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = utils.GenerateSign(params, benchMerchantKey)
+	}
+}
+
+// BenchmarkVerifySign 商户请求验签
+func BenchmarkVerifySign(b *testing.B) {
+	params := benchSubmitParams()
+	params["sign"] = utils.GenerateSign(params, benchMerchantKey)
+	params["sign_type"] = utils.SignTypeMD5
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !utils.VerifySign(params, benchMerchantKey) {
+			b.Fatal("signature verification failed")
+		}
+	}
+}
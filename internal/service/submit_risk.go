@@ -0,0 +1,50 @@
+// Package service 下单风控
+// @author AliMPay Team
+// @description 页面跳转下单（/submit）在创建订单前检查买家IP的风险：同一IP短时间内下单过多，
+// 或IP属于配置的风险网段（机房、代理等）时，要求买家先完成人机验证（见 submit_captcha 配置）
+package service
+
+import (
+	"time"
+
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// 下单风控原因（记录在日志中）
+const (
+	SubmitRiskVelocity = "velocity"    // 下单频率过高
+	SubmitRiskIPRange  = "risky_range" // IP属于风险网段
+)
+
+// SubmitRiskReason 检查买家IP是否命中下单风控规则
+// @description 未启用 submit_captcha 或买家IP无效时不检查；频率按买家IP在统计窗口内已创建的订单数计算
+// @param clientIP 买家IP
+// @return string 命中的风控原因，未命中时为空
+func (s *CodePayService) SubmitRiskReason(clientIP string) string {
+	rules := s.cfg.SubmitCaptcha
+	clientIP = normalizeClientIP(clientIP)
+	if !rules.Enabled || clientIP == "" {
+		return ""
+	}
+
+	if utils.IPInList(clientIP, rules.RiskyRanges) {
+		return SubmitRiskIPRange
+	}
+
+	if rules.VelocityLimit > 0 {
+		since := time.Now().Add(-time.Duration(rules.VelocityWindow) * time.Second)
+		count, err := s.db.CountOrderClientsSince(clientIP, since)
+		if err != nil {
+			logger.Warn("Failed to count recent orders of client ip", zap.String("ip", clientIP), zap.Error(err))
+			return ""
+		}
+		if count >= rules.VelocityLimit {
+			return SubmitRiskVelocity
+		}
+	}
+
+	return ""
+}
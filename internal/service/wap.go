@@ -0,0 +1,42 @@
+// Package service 手机网站支付模式
+// @author AliMPay Team
+// @description 下单请求携带 device=h5 时通过 alipay.trade.wap.pay 生成支付宝收银台地址，
+// 手机浏览器直接跳转付款，无需扫描经营码；支付确认与当面付相同（异步通知 + 交易查询兜底）
+package service
+
+import (
+	"net/url"
+	"time"
+
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/i18n"
+)
+
+// DeviceH5 下单参数 device 的取值：手机浏览器，启用手机网站支付时跳转支付宝收银台
+const DeviceH5 = "h5"
+
+// useWapPay 下单请求是否使用手机网站支付
+func (s *CodePayService) useWapPay(params map[string]string) bool {
+	return s.cfg.Payment.WapMode.Enabled && params["device"] == DeviceH5
+}
+
+// wapPayURL 生成订单的支付宝收银台地址
+// 付款完成或中途退出时经 /pay/return 跳转回商户的 return_url
+// @param order 手机网站支付订单（使用订单交易号作为支付宝侧的商户订单号）
+// @param baseURL 服务基础URL
+// @return string 收银台地址
+func (s *CodePayService) wapPayURL(order *model.Order, baseURL string) (string, error) {
+	notifyURL := tradeNotifyURL(s.cfg.Payment.WapMode.NotifyURL, baseURL)
+	returnURL := baseURL + "/pay/return?trade_no=" + url.QueryEscape(order.ID)
+
+	// 收银台地址只在本地签名生成，重复提交时按订单剩余时间重新生成
+	timeout := time.Until(order.AddTime.Add(time.Duration(s.cfg.Payment.OrderTimeout) * time.Second))
+	return s.alipayClient.TradeWapPayURL(order.ID, order.PaymentAmount, order.Name, notifyURL, returnURL, returnURL, timeout)
+}
+
+// fillWapResponse 填充手机网站支付订单的支付信息
+func (s *CodePayService) fillWapResponse(response map[string]interface{}, wapURL string, paymentAmount model.Amount, lang string) {
+	response["payment_url"] = wapURL
+	response["wap_mode"] = true
+	response["payment_instruction"] = i18n.T(lang, "tip.wap", paymentAmount)
+}
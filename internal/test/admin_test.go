@@ -0,0 +1,371 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/totp"
+	"alimpay-go/internal/web"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestAdminAPITokens 管理后台API令牌：Bearer令牌按权限范围访问管理接口，无效、过期或注销后返回401，不能访问管理员接口
+func TestAdminAPITokens(t *testing.T) {
+	h := startHarness(t)
+
+	adminAuth, err := middleware.NewAdminAuthMiddleware(MerchantID, MerchantKey, h.DB, nil, middleware.SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminAuth.SetTokenStore(h.DB)
+	audit := middleware.NewAuditTrail(h.DB, nil)
+
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "role": c.GetString("admin_role")})
+	}
+	router := gin.New()
+	// 管理员会话创建令牌（不经过登录流程）
+	router.POST("/manage/tokens", func(c *gin.Context) {
+		c.Set("admin_username", "ops")
+		c.Set("admin_role", model.AdminRoleAdmin)
+	}, adminAuth.HandleCreateToken)
+	router.POST("/manage/tokens/revoke", adminAuth.HandleRevokeToken)
+	router.GET("/manage/tokens", adminAuth.HandleListTokens)
+	admin := router.Group("/admin", adminAuth.RequireAuth())
+	admin.GET("/stats", ok)
+	admin.POST("/action", audit.Record("order.action"), adminAuth.RequireRole(model.AdminRoleOperator), ok)
+	admin.GET("/users", adminAuth.RequireRole(model.AdminRoleAdmin), ok)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	call := func(method, path, token string, body url.Values) (int, map[string]interface{}, error) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body.Encode()))
+		if err != nil {
+			return 0, nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out, nil
+	}
+	create := func(name, scope string) (string, string, error) {
+		status, out, err := call(http.MethodPost, "/manage/tokens", "", url.Values{"name": {name}, "scope": {scope}})
+		if err != nil {
+			return "", "", err
+		}
+		token, _ := out["token"].(string)
+		apiToken, _ := out["api_token"].(map[string]interface{})
+		if status != http.StatusOK || token == "" || apiToken == nil {
+			return "", "", fmt.Errorf("create %s token returned %d %v", scope, status, out)
+		}
+		if apiToken["created_by"] != "ops" || !strings.HasPrefix(token, apiToken["prefix"].(string)) {
+			return "", "", fmt.Errorf("created token record = %v", apiToken)
+		}
+		return token, apiToken["id"].(string), nil
+	}
+
+	if status, _, _ := call(http.MethodPost, "/manage/tokens", "", url.Values{"name": {"bad"}, "scope": {"admin"}}); status != http.StatusBadRequest {
+		t.Fatalf("token with admin scope returned %d, want 400", status)
+	}
+	readToken, readID, err := create("grafana", model.AdminTokenScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordersToken, _, err := create("reconcile-bot", model.AdminTokenScopeOrders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未携带令牌时仍按会话认证（跳转登录页）；无效令牌返回401
+	if status, _, _ := call(http.MethodGet, "/admin/stats", "", nil); status != http.StatusFound {
+		t.Fatalf("request without token returned %d, want redirect to login", status)
+	}
+	if status, _, _ := call(http.MethodGet, "/admin/stats", "amp_invalid", nil); status != http.StatusUnauthorized {
+		t.Fatalf("invalid token returned %d, want 401", status)
+	}
+
+	cases := []struct {
+		token, method, path string
+		want                int
+	}{
+		{readToken, http.MethodGet, "/admin/stats", http.StatusOK},
+		{readToken, http.MethodPost, "/admin/action", http.StatusForbidden},
+		{ordersToken, http.MethodPost, "/admin/action", http.StatusOK},
+		{ordersToken, http.MethodGet, "/admin/users", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if status, out, err := call(tc.method, tc.path, tc.token, nil); err != nil || status != tc.want {
+			t.Fatalf("%s %s with %s returned %d %v (%v), want %d", tc.method, tc.path, tc.token[:12], status, out, err, tc.want)
+		}
+	}
+
+	// 审计日志记录令牌标识
+	var actors []string
+	if err := h.DB.ExportAuditLogs(time.Now().Add(-time.Minute), time.Now().Add(time.Minute), func(log *model.AuditLog) error {
+		if log.Action == "order.action" {
+			actors = append(actors, log.Actor)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 只读令牌被拒绝的请求同样记录
+	if len(actors) != 2 || !strings.HasPrefix(actors[0], "token:") || actors[0] == actors[1] {
+		t.Fatalf("audit actors for token requests = %v, want two token:<id>", actors)
+	}
+
+	// 列表不含令牌明文，记录最后使用时间
+	_, out, err := call(http.MethodGet, "/manage/tokens", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, _ := json.Marshal(out["tokens"])
+	if strings.Contains(string(listed), readToken) || strings.Contains(string(listed), "token_hash") || !strings.Contains(string(listed), `"last_used_at":"`) {
+		t.Fatalf("token list = %s", listed)
+	}
+
+	// 过期令牌
+	expired := time.Now().Add(-time.Hour)
+	hash := sha256.Sum256([]byte("amp_expired"))
+	if err := h.DB.CreateAdminAPIToken(&model.AdminAPIToken{
+		ID: "expired", Name: "expired", TokenHash: hex.EncodeToString(hash[:]), Prefix: "amp_expi",
+		Scope: model.AdminTokenScopeRead, ExpiresAt: &expired,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, _ := call(http.MethodGet, "/admin/stats", "amp_expired", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expired token returned %d, want 401", status)
+	}
+
+	// 注销后立即失效
+	if status, _, _ := call(http.MethodPost, "/manage/tokens/revoke", "", url.Values{"id": {readID}}); status != http.StatusOK {
+		t.Fatalf("revoke returned %d", status)
+	}
+	if status, _, _ := call(http.MethodGet, "/admin/stats", readToken, nil); status != http.StatusUnauthorized {
+		t.Fatalf("revoked token returned %d, want 401", status)
+	}
+	if status, _, _ := call(http.MethodPost, "/manage/tokens/revoke", "", url.Values{"id": {readID}}); status != http.StatusNotFound {
+		t.Fatalf("revoking a revoked token returned %d, want 404", status)
+	}
+}
+
+// twoFactorChallengePattern 登录页第二步中的两步验证令牌
+var twoFactorChallengePattern = regexp.MustCompile(`name="two_factor" value="([^"]+)"`)
+
+// TestAdminUserTwoFactor 每个账号独立开启两步验证：已开启的账号（含 operator 角色）登录需通过第二步，未开启的账号直接登录；
+// 其他账号的动态验证码无效，登录令牌和动态验证码都只能使用一次，重置一个账号不影响其他账号
+func TestAdminUserTwoFactor(t *testing.T) {
+	h := startHarness(t)
+
+	for _, user := range []struct{ username, role string }{
+		{"root-ops", model.AdminRoleAdmin},
+		{"cashier", model.AdminRoleOperator},
+		{"auditor", model.AdminRoleViewer},
+	} {
+		hash, err := bcrypt.GenerateFromPassword([]byte("e2e-password"), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := h.DB.CreateAdminUser(&model.AdminUser{Username: user.username, PasswordHash: string(hash), Role: user.role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 为 root-ops 和 cashier 分别开启两步验证（确认时使用的时间步不能再用于登录）
+	twoFactor := middleware.NewTwoFactor(h.DB, MerchantID)
+	step := totp.Step(time.Now())
+	secrets := make(map[string]string)
+	recoveryCodes := make(map[string][]string)
+	for _, account := range []string{"root-ops", "cashier"} {
+		setup, err := twoFactor.Setup(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := totp.Code(setup.Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recoveryCodes[account], err = twoFactor.Enable(account, code); err != nil {
+			t.Fatal(err)
+		}
+		secrets[account] = setup.Secret
+	}
+	if secrets["root-ops"] == secrets["cashier"] {
+		t.Fatalf("accounts share the same two-factor secret")
+	}
+	if enabled, err := twoFactor.Enabled(""); err != nil || enabled {
+		t.Fatalf("merchant login two-factor enabled = %v, %v, want not enabled", enabled, err)
+	}
+	users, err := h.DB.ListAdminUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range users {
+		if want := user.Username != "auditor"; user.TwoFactor != want {
+			t.Fatalf("user %s two_factor = %v, want %v", user.Username, user.TwoFactor, want)
+		}
+	}
+	nextCode := func(account string) string {
+		code, err := totp.Code(secrets[account], step+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tmpl, _, err := web.ParseTemplates("", web.Branding{SiteName: "e2e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminAuth, err := middleware.NewAdminAuthMiddleware(MerchantID, MerchantKey, h.DB, nil, middleware.SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminAuth.SetUserStore(h.DB)
+	adminAuth.SetTwoFactorStore(h.DB)
+	router := gin.New()
+	router.SetHTMLTemplate(tmpl)
+	router.POST("/admin/login", adminAuth.HandleLogin)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	// login 提交登录表单，返回状态码、跳转地址和第二步的两步验证令牌
+	login := func(form url.Values) (int, string, string, error) {
+		resp, err := client.PostForm(server.URL+"/admin/login", form)
+		if err != nil {
+			return 0, "", "", err
+		}
+		defer resp.Body.Close()
+		page, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, "", "", err
+		}
+		challenge := ""
+		if match := twoFactorChallengePattern.FindSubmatch(page); match != nil {
+			challenge = string(match[1])
+		}
+		return resp.StatusCode, resp.Header.Get("Location"), challenge, nil
+	}
+	// passwordStep 密码正确后进入第二步，不签发会话
+	passwordStep := func(username string) string {
+		t.Helper()
+		status, location, challenge, err := login(url.Values{"pid": {username}, "key": {"e2e-password"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusOK || location != "" || challenge == "" {
+			t.Fatalf("%s login returned %d %q without two-factor step", username, status, location)
+		}
+		return challenge
+	}
+
+	// 未开启两步验证的账号直接登录
+	for _, form := range []url.Values{
+		{"pid": {"auditor"}, "key": {"e2e-password"}},
+		{"pid": {MerchantID}, "key": {MerchantKey}},
+	} {
+		if status, location, _, err := login(form); err != nil {
+			t.Fatal(err)
+		} else if status != http.StatusFound || location != "/admin/dashboard" {
+			t.Fatalf("login as %s returned %d %q, want redirect to dashboard", form.Get("pid"), status, location)
+		}
+	}
+
+	// operator 角色开启后同样需要第二步
+	challenge := passwordStep("cashier")
+
+	// 不输入、输错或输入其他账号的动态验证码时拒绝登录
+	for _, otp := range []string{"", "000000", nextCode("root-ops")} {
+		status, location, _, err := login(url.Values{"two_factor": {challenge}, "otp": {otp}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status == http.StatusFound || location != "" {
+			t.Fatalf("cashier login with otp %q redirected to %q", otp, location)
+		}
+	}
+
+	// 篡改令牌中的用户名无效
+	parts := strings.Split(challenge, ".")
+	parts[2] = base64.RawURLEncoding.EncodeToString([]byte("root-ops"))
+	if status, location, _, err := login(url.Values{"two_factor": {strings.Join(parts, ".")}, "otp": {nextCode("root-ops")}}); err != nil {
+		t.Fatal(err)
+	} else if status == http.StatusFound {
+		t.Fatalf("tampered challenge redirected to %q", location)
+	}
+
+	if status, location, _, err := login(url.Values{"two_factor": {challenge}, "otp": {nextCode("cashier")}}); err != nil {
+		t.Fatal(err)
+	} else if status != http.StatusFound || location != "/admin/dashboard" {
+		t.Fatalf("cashier login with valid otp returned %d %q, want redirect to dashboard", status, location)
+	}
+
+	// 已使用的登录令牌不能配合新的验证码再次登录
+	if status, location, _, err := login(url.Values{"two_factor": {challenge}, "otp": {recoveryCodes["cashier"][0]}}); err != nil {
+		t.Fatal(err)
+	} else if status == http.StatusFound {
+		t.Fatalf("reused challenge redirected to %q", location)
+	}
+
+	// 已使用的动态验证码不能用于新的登录令牌，恢复码可以
+	challenge = passwordStep("cashier")
+	if status, location, _, err := login(url.Values{"two_factor": {challenge}, "otp": {nextCode("cashier")}}); err != nil {
+		t.Fatal(err)
+	} else if status == http.StatusFound {
+		t.Fatalf("reused otp redirected to %q", location)
+	}
+	if status, location, _, err := login(url.Values{"two_factor": {challenge}, "otp": {recoveryCodes["cashier"][1]}}); err != nil {
+		t.Fatal(err)
+	} else if status != http.StatusFound || location != "/admin/dashboard" {
+		t.Fatalf("cashier login with recovery code returned %d %q, want redirect to dashboard", status, location)
+	}
+
+	user, err := h.DB.GetAdminUser("cashier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.LastLoginAt == nil {
+		t.Fatalf("admin user last login not recorded after two-factor login")
+	}
+
+	// 重置 cashier 不影响 root-ops
+	if err := twoFactor.Reset("cashier"); err != nil {
+		t.Fatal(err)
+	}
+	if status, location, _, err := login(url.Values{"pid": {"cashier"}, "key": {"e2e-password"}}); err != nil {
+		t.Fatal(err)
+	} else if status != http.StatusFound || location != "/admin/dashboard" {
+		t.Fatalf("cashier login after reset returned %d %q, want redirect to dashboard", status, location)
+	}
+	challenge = passwordStep("root-ops")
+	if status, location, _, err := login(url.Values{"two_factor": {challenge}, "otp": {nextCode("root-ops")}}); err != nil {
+		t.Fatal(err)
+	} else if status != http.StatusFound || location != "/admin/dashboard" {
+		t.Fatalf("root-ops login with valid otp returned %d %q, want redirect to dashboard", status, location)
+	}
+}
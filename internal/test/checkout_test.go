@@ -0,0 +1,488 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/i18n"
+	"alimpay-go/internal/pkg/utils"
+)
+
+// TestPrecreateNotify 当面付模式：下单时预下单获得支付宝二维码，支付宝异步通知确认支付（伪造的通知被拒绝），不查询账单
+func TestPrecreateNotify(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Payment.PrecreateMode.Enabled = true
+
+	order, err := h.CreateOrder("E2E-F2F-1", "9.90")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentURL != MockQRCodePrefix+order.TradeNo {
+		t.Fatalf("payment_url = %q, want alipay qr code of trade %s", order.PaymentURL, order.TradeNo)
+	}
+
+	// 重复提交返回同一个二维码
+	again, err := h.CreateOrder("E2E-F2F-1", "9.90")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.TradeNo != order.TradeNo || again.PaymentURL != order.PaymentURL {
+		t.Fatalf("resubmitted order = %s %q, want %s %q", again.TradeNo, again.PaymentURL, order.TradeNo, order.PaymentURL)
+	}
+
+	alipayTradeNo, err := h.Gateway.PayTrade(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := h.Gateway.SendTradeNotify(order.TradeNo, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "fail" {
+		t.Fatalf("tampered notify answered %q, want fail", reply)
+	}
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("order status = %d after tampered notify, want %d", status, model.OrderStatusPending)
+	}
+
+	reply, err = h.Gateway.SendTradeNotify(order.TradeNo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "success" {
+		t.Fatalf("notify answered %q, want success", reply)
+	}
+
+	queried, err := h.QueryOrder(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queried.Status != model.OrderStatusPaid || queried.AlipayTradeNo != alipayTradeNo {
+		t.Fatalf("order status = %d, alipay_trade_no = %q after notify, want paid %q",
+			queried.Status, queried.AlipayTradeNo, alipayTradeNo)
+	}
+
+	// 支付宝重发通知时同样应答 success
+	if reply, err = h.Gateway.SendTradeNotify(order.TradeNo, false); err != nil || reply != "success" {
+		t.Fatalf("repeated notify answered %q: %v", reply, err)
+	}
+
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+	if requests := h.Gateway.Requests(); requests != 0 {
+		t.Fatalf("gateway queried bills %d times in precreate mode", requests)
+	}
+
+}
+
+// TestPrecreateQuery 当面付模式：异步通知丢失时，监听周期查询交易状态确认支付
+func TestPrecreateQuery(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Payment.PrecreateMode.Enabled = true
+
+	order, err := h.CreateOrder("E2E-F2F-2", "3.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用户尚未扫码：交易不存在，订单保持待支付
+	h.RunMonitor()
+	time.Sleep(200 * time.Millisecond)
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("order status = %d before payment, want %d", status, model.OrderStatusPending)
+	}
+
+	alipayTradeNo, err := h.Gateway.PayTrade(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("order not paid after trade query: %v", err)
+	}
+
+	queried, err := h.QueryOrder(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queried.AlipayTradeNo != alipayTradeNo {
+		t.Fatalf("alipay_trade_no = %q, want %q", queried.AlipayTradeNo, alipayTradeNo)
+	}
+	if requests := h.Gateway.Requests(); requests != 0 {
+		t.Fatalf("gateway queried bills %d times in precreate mode", requests)
+	}
+
+}
+
+// TestWAPPay 手机网站支付：device=h5 的订单返回支付宝收银台地址，浏览器打开后付款，异步通知确认支付；
+// 未携带 device 的订单仍使用原收款模式
+func TestWAPPay(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Payment.WapMode.Enabled = true
+
+	regular, err := h.CreateOrder("E2E-WAP-0", "1.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regular.WapMode {
+		t.Fatalf("order without device=h5 returned wap_mode")
+	}
+
+	order, err := h.CreateH5Order("E2E-WAP-1", "6.60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.WapMode || !strings.HasPrefix(order.PaymentURL, h.Gateway.URL()+"?") {
+		t.Fatalf("payment_url = %q, wap_mode = %v, want alipay cashier url", order.PaymentURL, order.WapMode)
+	}
+
+	// 用户尚未打开收银台：交易不存在，订单保持待支付
+	h.RunMonitor()
+	time.Sleep(200 * time.Millisecond)
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("order status = %d before payment, want %d", status, model.OrderStatusPending)
+	}
+
+	// 浏览器跳转收银台（模拟网关校验签名并创建交易）
+	resp, err := http.Get(order.PaymentURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("alipay cashier returned status %d", resp.StatusCode)
+	}
+	trade := h.Gateway.Trade(order.TradeNo)
+	if trade == nil || trade.Amount != "6.60" || !strings.Contains(trade.ReturnURL, "/pay/return?trade_no="+order.TradeNo) {
+		t.Fatalf("cashier trade = %+v, want amount 6.60 returning to /pay/return", trade)
+	}
+
+	alipayTradeNo, err := h.Gateway.PayTrade(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := h.Gateway.SendTradeNotify(order.TradeNo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "success" {
+		t.Fatalf("notify answered %q, want success", reply)
+	}
+
+	queried, err := h.QueryOrder(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queried.Status != model.OrderStatusPaid || queried.AlipayTradeNo != alipayTradeNo {
+		t.Fatalf("order status = %d, alipay_trade_no = %q after notify, want paid %q",
+			queried.Status, queried.AlipayTradeNo, alipayTradeNo)
+	}
+
+	// 重复提交返回同一订单的收银台地址
+	again, err := h.CreateH5Order("E2E-WAP-1", "6.60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.TradeNo != order.TradeNo || !again.WapMode {
+		t.Fatalf("resubmitted order = %s wap_mode=%v, want %s", again.TradeNo, again.WapMode, order.TradeNo)
+	}
+
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+
+}
+
+// TestSignedReturn 用户从收银台返回：未支付时直接跳转 return_url；付款后（异步通知未到达）查询交易确认订单，
+// 跳转地址附带签名的支付结果
+func TestSignedReturn(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Payment.WapMode.Enabled = true
+
+	order, err := h.CreateH5Order("E2E-RETURN-1", "8.80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(order.PaymentURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	location, err := h.Return(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location != h.Notify.URL() {
+		t.Fatalf("return before payment redirected to %q, want %q", location, h.Notify.URL())
+	}
+
+	alipayTradeNo, err := h.Gateway.PayTrade(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err = h.Return(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	returnURL, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base := strings.SplitN(location, "?", 2)[0]; base != h.Notify.URL() {
+		t.Fatalf("return after payment redirected to %q, want %q", base, h.Notify.URL())
+	}
+	params := make(map[string]string)
+	for k := range returnURL.Query() {
+		params[k] = returnURL.Query().Get(k)
+	}
+	if !utils.VerifySign(params, MerchantKey) {
+		t.Fatalf("return params %v have invalid signature", params)
+	}
+	want := map[string]string{
+		"trade_no":        order.TradeNo,
+		"out_trade_no":    order.OutTradeNo,
+		"money":           "8.80",
+		"trade_status":    "TRADE_SUCCESS",
+		"alipay_trade_no": alipayTradeNo,
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Fatalf("return param %s = %q, want %q", k, params[k], v)
+		}
+	}
+
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPaid {
+		t.Fatalf("order status = %d after return, want %d", status, model.OrderStatusPaid)
+	}
+}
+
+// TestPrintedCodes 批量生成线下收款码（ZIP含二维码图片和清单）→ 首次扫码创建订单，重复扫码返回同一订单 →
+// 支付后仍返回已支付订单；关联订单关闭后再次扫码创建新订单
+func TestPrintedCodes(t *testing.T) {
+	h := startHarness(t)
+
+	batch, err := h.CodePay.CreatePrintedCodes("E2E门票", "5.50", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Codes) != 2 {
+		t.Fatalf("batch has %d codes, want 2", len(batch.Codes))
+	}
+
+	var archive bytes.Buffer
+	if err := h.CodePay.WritePrintedCodeArchive(&archive, batch, h.URL()); err != nil {
+		t.Fatal(err)
+	}
+	files, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("invalid archive: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, file := range files.File {
+		names[file.Name] = true
+	}
+	for _, code := range batch.Codes {
+		if !names[code.Code+".png"] {
+			t.Fatalf("archive missing %s.png: %v", code.Code, names)
+		}
+	}
+	if !names["codes.csv"] || len(names) != len(batch.Codes)+1 {
+		t.Fatalf("archive files = %v, want codes.csv and one image per code", names)
+	}
+
+	first := batch.Codes[0].Code
+	tradeNo, err := h.ScanPrintedCode(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := h.DB.GetOrderByID(tradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order == nil || order.Status != model.OrderStatusPending || order.Price != batch.Price {
+		t.Fatalf("order after first scan = %+v, want pending %s", order, batch.Price)
+	}
+
+	if again, err := h.ScanPrintedCode(first); err != nil || again != tradeNo {
+		t.Fatalf("second scan returned order %q (%v), want %q", again, err, tradeNo)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("printed code order not paid: %v", err)
+	}
+	if again, err := h.ScanPrintedCode(first); err != nil || again != tradeNo {
+		t.Fatalf("scan after payment returned order %q (%v), want paid order %q", again, err, tradeNo)
+	}
+
+	// 关联订单关闭后，再次扫码创建新订单
+	second := batch.Codes[1].Code
+	closedTradeNo, err := h.ScanPrintedCode(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DB.TransitionOrderStatus(closedTradeNo, model.OrderStatusPending, model.OrderStatusClosed, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	newTradeNo, err := h.ScanPrintedCode(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newTradeNo == "" || newTradeNo == closedTradeNo || newTradeNo == tradeNo {
+		t.Fatalf("scan after close returned order %q, want a new order", newTradeNo)
+	}
+
+	if order, err := h.CodePay.ResolvePrintedCode("E2EMISSING", h.URL()); err != nil || order != nil {
+		t.Fatalf("unknown code resolved to %+v (%v), want nil", order, err)
+	}
+}
+
+// TestSandboxCheckout 沙箱商户：沙箱订单不查询支付宝账单，模拟支付后匹配模拟账单并以沙箱密钥签名通知；
+// 下单参数 lang 决定支付提示和支付页面的语言；重新签发后旧密钥失效
+func TestSandboxCheckout(t *testing.T) {
+	h := startHarness(t)
+
+	pid, key, err := h.CodePay.IssueSandboxCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.MerchantAuth.SetCredential(middleware.MerchantCredential{ID: pid, Key: key, SignType: h.CodePay.SignType()})
+
+	if _, err := h.CreateSandboxOrder(pid, MerchantKey, "E2E-SANDBOX-0", "5.00"); err == nil {
+		t.Fatalf("sandbox order signed with merchant key was accepted")
+	}
+
+	order, err := h.CreateSandboxOrder(pid, key, "E2E-SANDBOX-1", "5.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.Sandbox {
+		t.Fatalf("created order %+v is not marked as sandbox", order)
+	}
+
+	// 真实账单不参与沙箱订单匹配，监听周期也不查询支付宝
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	time.Sleep(200 * time.Millisecond)
+	if requests := h.Gateway.Requests(); requests != 0 {
+		t.Fatalf("gateway queried %d times for sandbox order", requests)
+	}
+	stored, err := h.DB.GetOrderByID(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != model.OrderStatusPending {
+		t.Fatalf("sandbox order status = %d before simulated payment, want %d", stored.Status, model.OrderStatusPending)
+	}
+
+	if err := h.SandboxPay(order.TradeNo); err != nil {
+		t.Fatal(err)
+	}
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("sandbox notification not received: %v", err)
+	}
+
+	notify := h.Notify.Find(order.TradeNo)[0]
+	params := make(map[string]string)
+	for k := range notify {
+		params[k] = notify.Get(k)
+	}
+	if params["pid"] != pid || !utils.VerifySign(params, key) {
+		t.Fatalf("sandbox notification %v is not signed for sandbox merchant %s", params, pid)
+	}
+	if utils.VerifySign(params, MerchantKey) {
+		t.Fatalf("sandbox notification is signed with merchant key")
+	}
+	if verified, err := h.VerifyNotify(notify); err != nil {
+		t.Fatal(err)
+	} else if !verified.Valid {
+		t.Fatalf("sandbox notification verification = %+v, want authentic", verified)
+	}
+
+	// 指定语言下单：支付提示为英文，支付页面地址带 lang 参数；支付页面数据按 lang 参数选择语言
+	english, err := h.createOrderAs(pid, key, "E2E-SANDBOX-EN", "5.00", map[string]string{"lang": i18n.EnUS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := i18n.T(i18n.EnUS, "tip.sandbox.instruction"); english.Instruction != want {
+		t.Fatalf("payment instruction = %q, want %q", english.Instruction, want)
+	}
+	if !strings.Contains(english.PaymentURL, "lang="+i18n.EnUS) {
+		t.Fatalf("payment url %q does not carry lang", english.PaymentURL)
+	}
+	for lang, want := range map[string]string{i18n.EnUS: "Pending", "": "待支付"} {
+		view, err := h.FetchPayView(english.TradeNo, lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if view.StatusText != want || len(view.Tips) == 0 {
+			t.Fatalf("pay view with lang %q = %+v, want status %q with tips", lang, view, want)
+		}
+	}
+
+	// 重新签发后商户ID不变，旧密钥失效
+	newPID, newKey, err := h.CodePay.IssueSandboxCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPID != pid || newKey == key {
+		t.Fatalf("reissued credentials = %s/%s, want same pid %s with new key", newPID, newKey, pid)
+	}
+	if _, err := h.CreateSandboxOrder(pid, key, "E2E-SANDBOX-2", "5.00"); err == nil {
+		t.Fatalf("sandbox order signed with revoked key was accepted")
+	}
+	if _, err := h.CreateSandboxOrder(pid, newKey, "E2E-SANDBOX-2", "5.00"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,645 @@
+// Package test 端到端集成测试
+// @author AliMPay Team
+// @description 在临时目录中启动服务（SQLite）、模拟支付宝网关和商户通知地址，
+// 覆盖 下单 → 账单匹配 → 商户通知 的完整流程；各功能的测试按领域分文件（payment_test.go、admin_test.go 等），
+// 随 go test ./... 一起运行
+package test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/logger"
+	"alimpay-go/internal/pkg/utils"
+	approuter "alimpay-go/internal/router"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 测试商户与支付宝应用
+const (
+	MerchantID  = "1001"
+	MerchantKey = "e2e0123456789abcdef0123456789abc"
+	AlipayAppID = "2021000000000001"
+)
+
+// waitTimeout 等待异步匹配和通知的超时时间
+const waitTimeout = 10 * time.Second
+
+// configTemplate 测试配置（监听周期由测试手动触发，定时任务间隔设为1小时）
+const configTemplate = `server:
+  host: "127.0.0.1"
+  port: 0
+  mode: "release"
+alipay:
+  server_url: %q
+  app_id: %q
+  private_key: %q
+  alipay_public_key: %q
+  transfer_user_id: "2088000000000001"
+  sign_type: "RSA2"
+  charset: "UTF-8"
+  format: "json"
+  qps: -1
+database:
+  type: "sqlite3"
+  path: "./data/alimpay.db"
+payment:
+  order_timeout: 300
+  auto_cleanup: false
+  business_qr_mode:
+    enabled: false
+merchant:
+  id: %q
+  key: %q
+logging:
+  level: "info"
+  output: "file"
+  file_path: "./logs/alimpay.log"
+monitor:
+  enabled: true
+  interval: 3600
+  lock_timeout: 60
+`
+
+// Harness 端到端测试环境
+// 服务的相对路径（数据库、日志、监听锁文件）均位于临时目录，启动时切换工作目录，
+// 因此同一进程内同一时刻只能运行一个 Harness（测试不能使用 t.Parallel）
+type Harness struct {
+	Dir     string
+	Config  *config.Config
+	DB      *database.DB
+	CodePay *service.CodePayService
+	Monitor *service.MonitorService
+	Gateway *MockAlipayGateway
+	Notify  *NotifyReceiver
+	// MerchantAuth 商户认证（签发沙箱凭据后需添加，与管理后台一致）
+	MerchantAuth *middleware.MerchantAuth
+
+	server  *httptest.Server
+	client  *http.Client
+	prevDir string
+}
+
+// TestMain 设置全局时区为北京时间（与主程序一致）
+func TestMain(m *testing.M) {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		time.Local = loc
+	}
+	os.Exit(m.Run())
+}
+
+// startHarness 启动测试环境，测试结束时检查模拟网关是否收到错误请求并清理
+func startHarness(t *testing.T) *Harness {
+	t.Helper()
+
+	h, err := Start()
+	if err != nil {
+		t.Fatalf("failed to start harness: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := h.Gateway.Err(); err != nil {
+			t.Error(err)
+		}
+		h.Close()
+	})
+	return h
+}
+
+// Start 启动测试环境
+func Start() (*Harness, error) {
+	h := &Harness{
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	ok := false
+	defer func() {
+		if !ok {
+			h.Close()
+		}
+	}()
+
+	dir, err := os.MkdirTemp("", "alimpay-e2e-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	h.Dir = dir
+
+	// 应用密钥：服务用私钥签名，模拟网关用公钥验签
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	// 支付宝密钥：模拟网关用私钥签名响应，服务用公钥验签
+	alipayKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&alipayKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	h.Gateway = NewMockAlipayGateway(AlipayAppID, &key.PublicKey, alipayKey)
+	h.Notify = NewNotifyReceiver()
+
+	configPath := filepath.Join(dir, "config.yaml")
+	configData := fmt.Sprintf(configTemplate,
+		h.Gateway.URL(),
+		AlipayAppID,
+		base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key)),
+		base64.StdEncoding.EncodeToString(publicKey),
+		MerchantID,
+		MerchantKey,
+	)
+	if err := os.WriteFile(configPath, []byte(configData), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write config: %w", err)
+	}
+
+	if h.prevDir, err = os.Getwd(); err != nil {
+		return nil, fmt.Errorf("failed to get working dir: %w", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return nil, fmt.Errorf("failed to change working dir: %w", err)
+	}
+
+	if h.Config, err = config.Load(configPath); err != nil {
+		return nil, err
+	}
+
+	if err := logger.Init(&logger.Config{
+		Level:    h.Config.Logging.Level,
+		Format:   h.Config.Logging.Format,
+		Output:   h.Config.Logging.Output,
+		FilePath: h.Config.Logging.FilePath,
+		MaxSize:  h.Config.Logging.MaxSize,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	h.DB, err = database.Init(&database.Config{
+		Type:            h.Config.Database.Type,
+		Path:            h.Config.Database.Path,
+		MaxIdleConns:    h.Config.Database.MaxIdleConns,
+		MaxOpenConns:    h.Config.Database.MaxOpenConns,
+		ConnMaxLifetime: h.Config.Database.ConnMaxLifetime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if h.CodePay, err = service.NewCodePayService(h.Config, h.DB); err != nil {
+		return nil, err
+	}
+	if h.Monitor, err = service.NewMonitorService(h.Config, h.DB, h.CodePay); err != nil {
+		return nil, err
+	}
+	if err := h.Monitor.Start(); err != nil {
+		return nil, err
+	}
+
+	h.server = httptest.NewServer(middleware.StripExtension(h.router(), approuter.LegacyExtension))
+
+	ok = true
+	return h, nil
+}
+
+// router 注册商户接口（与 cmd/main.go 中的路由一致）
+func (h *Harness) router() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+
+	router := gin.New()
+	router.Use(middleware.Recovery(h.DB, nil))
+	router.Use(middleware.Logger(nil))
+
+	yipayHandler := handler.NewYiPayHandler(h.DB, h.CodePay, h.Config)
+	healthHandler := handler.NewHealthHandler(h.DB, h.CodePay, h.Monitor)
+
+	approuter.RegisterCompat(router, "/api/submit", middleware.JSONBody(), yipayHandler.HandleSubmitAPI)
+	approuter.RegisterCompat(router, "/api/order", yipayHandler.HandleQueryOrder)
+	h.MerchantAuth = middleware.NewMerchantAuth(middleware.MerchantCredential{
+		ID:      MerchantID,
+		Key:     MerchantKey,
+		Methods: middleware.DefaultAuthMethods,
+	})
+	approuter.RegisterCompat(router, "/api/verify_notify", middleware.JSONBody(), h.MerchantAuth.Require(), yipayHandler.HandleVerifyNotify)
+	router.GET("/health", healthHandler.HandleHealth)
+	router.GET("/metrics", healthHandler.HandleMetrics)
+	payHandler := handler.NewPayHandler(h.DB, h.CodePay, h.Config, nil)
+	router.GET("/pay/return", payHandler.HandleReturn)
+	router.GET("/s/:code", payHandler.HandlePrintedCode)
+	router.POST("/pay/sandbox", payHandler.HandleSandboxPay)
+	router.GET("/api/pay/order", payHandler.HandleOrderView)
+	router.GET("/api/notify/ips", yipayHandler.HandleNotifyIPs)
+	orderStatusHandler := handler.NewOrderStatusHandler(h.DB)
+	router.GET("/api/order/status", orderStatusHandler.HandleStatus)
+	router.GET("/badge/order/:file", orderStatusHandler.HandleBadge)
+	router.POST(service.AlipayNotifyPath, handler.NewAlipayNotifyHandler(h.CodePay).HandleNotify)
+
+	return router
+}
+
+// Close 停止服务并删除临时目录
+func (h *Harness) Close() {
+	if h.server != nil {
+		h.server.Close()
+	}
+	if h.Monitor != nil {
+		h.Monitor.Stop()
+	}
+	if h.CodePay != nil {
+		h.CodePay.Close()
+	}
+	if h.DB != nil {
+		h.DB.Close()
+	}
+	if h.Gateway != nil {
+		h.Gateway.Close()
+	}
+	if h.Notify != nil {
+		h.Notify.Close()
+	}
+	_ = logger.Sync()
+	if h.prevDir != "" {
+		_ = os.Chdir(h.prevDir)
+	}
+	if h.Dir != "" {
+		_ = os.RemoveAll(h.Dir)
+	}
+}
+
+// URL 服务地址
+func (h *Harness) URL() string {
+	return h.server.URL
+}
+
+// Sign 使用测试商户密钥生成请求签名
+func (h *Harness) Sign(params map[string]string) string {
+	return utils.GenerateSign(params, MerchantKey)
+}
+
+// CreatedOrder 下单结果
+type CreatedOrder struct {
+	Code          int          `json:"code"`
+	Msg           string       `json:"msg"`
+	TradeNo       string       `json:"trade_no"`
+	OutTradeNo    string       `json:"out_trade_no"`
+	Money         string       `json:"money"`
+	PaymentAmount model.Amount `json:"payment_amount"`
+	PaymentURL    string       `json:"payment_url"`
+	WapMode       bool         `json:"wap_mode"`
+	Sandbox       bool         `json:"sandbox"`
+	Instruction   string       `json:"payment_instruction"`
+}
+
+// CreateOrder 通过 /api/submit 下单（通知地址为模拟商户地址）
+func (h *Harness) CreateOrder(outTradeNo, money string) (*CreatedOrder, error) {
+	return h.createOrder(outTradeNo, money, nil)
+}
+
+// CreateH5Order 以 device=h5 下单（手机浏览器）
+func (h *Harness) CreateH5Order(outTradeNo, money string) (*CreatedOrder, error) {
+	return h.createOrder(outTradeNo, money, map[string]string{"device": service.DeviceH5})
+}
+
+// CreateHMACOrder 以 sign_type=HMAC-SHA256 签名下单
+func (h *Harness) CreateHMACOrder(outTradeNo, money string) (*CreatedOrder, error) {
+	return h.createOrder(outTradeNo, money, map[string]string{"sign_type": utils.SignTypeHMACSHA256})
+}
+
+// CreateSandboxOrder 以沙箱商户ID和密钥下单
+func (h *Harness) CreateSandboxOrder(pid, key, outTradeNo, money string) (*CreatedOrder, error) {
+	return h.createOrderAs(pid, key, outTradeNo, money, nil)
+}
+
+// createOrder 通过 /api/submit 下单，extra 为附加的下单参数（参与签名）
+func (h *Harness) createOrder(outTradeNo, money string, extra map[string]string) (*CreatedOrder, error) {
+	return h.createOrderAs(MerchantID, MerchantKey, outTradeNo, money, extra)
+}
+
+// createOrderAs 以指定商户ID和密钥通过 /api/submit 下单
+func (h *Harness) createOrderAs(pid, key, outTradeNo, money string, extra map[string]string) (*CreatedOrder, error) {
+	params := map[string]string{
+		"pid":          pid,
+		"type":         model.PaymentTypeAlipay,
+		"out_trade_no": outTradeNo,
+		"notify_url":   h.Notify.URL(),
+		"return_url":   h.Notify.URL(),
+		"name":         "E2E " + outTradeNo,
+		"money":        money,
+		"price":        money, // /api/submit 以 money 补全 price 后验签，两者都参与签名
+		"sitename":     "e2e",
+		"sign_type":    utils.SignTypeMD5,
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	params["sign"] = utils.GenerateSign(params, key)
+
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+
+	var order CreatedOrder
+	if err := h.postForm("/api/submit", form, &order); err != nil {
+		return nil, err
+	}
+	if order.Code != 1 {
+		return nil, fmt.Errorf("create order failed: %s", order.Msg)
+	}
+	return &order, nil
+}
+
+// Return 模拟浏览器访问 /pay/return，返回跳转地址（不跟随跳转）
+func (h *Harness) Return(tradeNo string) (string, error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(h.URL() + "/pay/return?trade_no=" + url.QueryEscape(tradeNo))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", fmt.Errorf("GET /pay/return returned status %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+	return resp.Header.Get("Location"), nil
+}
+
+// ScanPrintedCode 模拟扫描线下打印的收款码 /s/{code}，返回跳转到的支付页面订单号（不跟随跳转）
+func (h *Harness) ScanPrintedCode(code string) (string, error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(h.URL() + "/s/" + url.PathEscape(code))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", fmt.Errorf("GET /s/%s returned status %d, want %d", code, resp.StatusCode, http.StatusFound)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", err
+	}
+	if location.Path != "/pay" {
+		return "", fmt.Errorf("GET /s/%s redirected to %s, want /pay", code, location)
+	}
+	return location.Query().Get("trade_no"), nil
+}
+
+// SandboxPay 在支付页面模拟支付沙箱订单 POST /pay/sandbox（不跟随跳转）
+func (h *Harness) SandboxPay(tradeNo string) error {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.PostForm(h.URL()+"/pay/sandbox", url.Values{"trade_no": {tradeNo}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		return fmt.Errorf("POST /pay/sandbox returned status %d, want %d", resp.StatusCode, http.StatusSeeOther)
+	}
+	return nil
+}
+
+// PolledStatus 订单状态轮询结果
+type PolledStatus struct {
+	HTTPStatus int
+	ETag       string
+	Status     int    `json:"status"`
+	PayTime    string `json:"pay_time"`
+}
+
+// PollStatus 轮询 /api/order/status，etag 非空时作为 If-None-Match 发送
+func (h *Harness) PollStatus(tradeNo, etag string) (*PolledStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, h.URL()+"/api/order/status?trade_no="+url.QueryEscape(tradeNo), nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	polled := &PolledStatus{HTTPStatus: resp.StatusCode, ETag: resp.Header.Get("ETag")}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(polled); err != nil {
+			return nil, fmt.Errorf("GET /api/order/status: %w", err)
+		}
+	}
+	return polled, nil
+}
+
+// PayPageView 支付页面数据（GET /api/pay/order 的 order 字段）
+type PayPageView struct {
+	StatusText string   `json:"status_text"`
+	Tips       []string `json:"tips"`
+	Lang       string   `json:"lang"`
+}
+
+// FetchPayView 请求支付页面数据 /api/pay/order，lang 非空时作为 lang 参数发送
+func (h *Harness) FetchPayView(tradeNo, lang string) (*PayPageView, error) {
+	var resp struct {
+		Code  int          `json:"code"`
+		Msg   string       `json:"msg"`
+		Order *PayPageView `json:"order"`
+	}
+	path := "/api/pay/order?trade_no=" + url.QueryEscape(tradeNo)
+	if lang != "" {
+		path += "&lang=" + url.QueryEscape(lang)
+	}
+	if err := h.get(path, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 1 || resp.Order == nil {
+		return nil, fmt.Errorf("GET /api/pay/order failed: %s", resp.Msg)
+	}
+	return resp.Order, nil
+}
+
+// NotifySource 通知来源信息（GET /api/notify/ips）
+type NotifySource struct {
+	Code      int      `json:"code"`
+	IPs       []string `json:"ips"`
+	Signature struct {
+		Enabled         bool   `json:"enabled"`
+		Header          string `json:"header"`
+		TimestampHeader string `json:"timestamp_header"`
+	} `json:"signature"`
+}
+
+// FetchNotifySource 请求通知来源信息 /api/notify/ips
+func (h *Harness) FetchNotifySource() (*NotifySource, error) {
+	var source NotifySource
+	if err := h.get("/api/notify/ips", &source); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// Badge 订单状态徽章响应
+type Badge struct {
+	HTTPStatus  int
+	ContentType string
+	Body        string
+}
+
+// FetchBadge 请求订单状态徽章 /badge/order/<file>
+func (h *Harness) FetchBadge(file string) (*Badge, error) {
+	resp, err := http.Get(h.URL() + "/badge/order/" + url.PathEscape(file))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("GET /badge/order/%s: %w", file, err)
+	}
+	return &Badge{
+		HTTPStatus:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	}, nil
+}
+
+// VerifiedNotify 通知验证结果
+type VerifiedNotify struct {
+	Code        int      `json:"code"`
+	Valid       bool     `json:"valid"`
+	SignValid   bool     `json:"sign_valid"`
+	OrderMatch  bool     `json:"order_match"`
+	SignContent string   `json:"sign_content"`
+	Errors      []string `json:"errors"`
+}
+
+// VerifyNotify 将通知参数提交到 /api/verify_notify
+func (h *Harness) VerifyNotify(params url.Values) (*VerifiedNotify, error) {
+	var result VerifiedNotify
+	if err := h.postForm("/api/verify_notify", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueriedOrder 订单查询结果
+type QueriedOrder struct {
+	Code          int    `json:"code"`
+	Msg           string `json:"msg"`
+	TradeNo       string `json:"trade_no"`
+	Status        int    `json:"status"`
+	AlipayTradeNo string `json:"alipay_trade_no"`
+}
+
+// QueryOrder 查询订单（经旧版 .php 路径，同时覆盖后缀兼容）
+func (h *Harness) QueryOrder(outTradeNo string) (*QueriedOrder, error) {
+	query := url.Values{}
+	query.Set("pid", MerchantID)
+	query.Set("out_trade_no", outTradeNo)
+
+	var result QueriedOrder
+	if err := h.get("/api/order"+approuter.LegacyExtension+"?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if result.Code != 1 {
+		return nil, fmt.Errorf("query order failed: %s", result.Msg)
+	}
+	return &result, nil
+}
+
+// OrderStatus 查询订单状态
+func (h *Harness) OrderStatus(outTradeNo string) (int, error) {
+	order, err := h.QueryOrder(outTradeNo)
+	if err != nil {
+		return 0, err
+	}
+	return order.Status, nil
+}
+
+// RunMonitor 执行一次监听周期（订单由Worker池异步匹配，结果需用 WaitFor 等待）
+func (h *Harness) RunMonitor() {
+	h.Monitor.RunMonitoringCycle()
+}
+
+// WaitFor 轮询直到条件满足或超时
+func WaitFor(timeout time.Duration, cond func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := cond()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("condition not met within %s", timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// postForm 发送表单请求并解析JSON响应
+func (h *Harness) postForm(path string, form url.Values, out interface{}) error {
+	resp, err := h.client.Post(h.server.URL+path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(path, resp, out)
+}
+
+// get 发送GET请求并解析JSON响应
+func (h *Harness) get(path string, out interface{}) error {
+	resp, err := h.client.Get(h.server.URL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(path, resp, out)
+}
+
+// decodeResponse 检查状态码并解析JSON
+func decodeResponse(path string, resp *http.Response, out interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
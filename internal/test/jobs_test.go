@@ -0,0 +1,462 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/notifier"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestDailyDigest 运营日报汇总订单、收入、商户通知失败，发送到全部通知渠道；某个渠道失败不影响其他渠道
+func TestDailyDigest(t *testing.T) {
+	h := startHarness(t)
+
+	paid, err := h.CreateOrder("E2E-DIGEST-1", "12.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CreateOrder("E2E-DIGEST-2", "5.00"); err != nil {
+		t.Fatal(err)
+	}
+	h.Gateway.AddBill(paid.PaymentAmount, paid.OutTradeNo)
+	h.RunMonitor()
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(paid.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	}); err != nil {
+		t.Fatalf("order not paid: %v", err)
+	}
+
+	if err := h.DB.CreateNotifyLog(&model.NotifyLog{
+		OrderID:   paid.TradeNo,
+		PID:       MerchantID,
+		NotifyURL: h.Notify.URL(),
+		Status:    model.NotifyStatusFailed,
+		Error:     "connection refused",
+		CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 通用Webhook与钉钉（加签）成功，企业微信返回错误码
+	type received struct {
+		path  string
+		query url.Values
+		body  map[string]interface{}
+	}
+	var mu sync.Mutex
+	var requests []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, received{r.URL.Path, r.URL.Query(), body})
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/wecom":
+			_, _ = w.Write([]byte(`{"errcode":93000,"errmsg":"invalid webhook url"}`))
+		default:
+			_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	var channels []notifier.Channel
+	for _, channel := range []struct{ kind, path, secret string }{
+		{"webhook", "/webhook", ""},
+		{"dingtalk", "/dingtalk", "SECe2e"},
+		{"wecom", "/wecom", ""},
+	} {
+		c, err := notifier.New(channel.kind, notifier.Options{URL: server.URL + channel.path, Secret: channel.secret})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels = append(channels, c)
+	}
+
+	digest := service.NewDailyDigestService(h.DB, notifier.NewNotifier(channels...), 9, 5)
+	digest.SetMonitor(h.Monitor)
+	err = digest.Send(time.Now())
+	if err == nil || !strings.Contains(err.Error(), "wecom: errcode 93000") {
+		t.Fatalf("send error = %v, want wecom errcode 93000", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 3 {
+		t.Fatalf("channels received %d requests, want 3", len(requests))
+	}
+
+	text, _ := requests[0].body["text"].(string)
+	for _, want := range []string{
+		"订单数：2，已支付：1，成功率：50.00%",
+		"实收金额：" + paid.PaymentAmount.String() + " 元",
+		"1. " + MerchantID + "：" + paid.PaymentAmount.String() + " 元（1/2 笔）",
+		MerchantID + " 失败 1 次：connection refused",
+		"账单监听：healthy",
+	} {
+		if requests[0].path != "/webhook" || !strings.Contains(text, want) {
+			t.Fatalf("webhook text missing %q:\n%s", want, text)
+		}
+	}
+
+	ding := requests[1]
+	if ding.body["msgtype"] != "markdown" || ding.query.Get("timestamp") == "" || ding.query.Get("sign") == "" {
+		t.Fatalf("dingtalk request = %v %v, want signed markdown message", ding.query, ding.body)
+	}
+}
+
+// TestHousekeepingJobs 维护任务：清理过期订单、对账重新通知、汇总每日统计、备份数据库并只保留最近的备份；
+// 配置了cron表达式的任务在监听调度器上定时执行
+func TestHousekeepingJobs(t *testing.T) {
+	h := startHarness(t)
+
+	backupDir := filepath.Join(h.Dir, "backups")
+	h.Config.Payment.AutoCleanup = true
+	h.Config.Housekeeping = config.HousekeepingConfig{
+		Cleanup:    "@every 1h",
+		Stats:      "@every 1s",
+		BackupDir:  backupDir,
+		BackupKeep: 2,
+	}
+	housekeeping := service.NewHousekeeping(&h.Config.Housekeeping, h.DB, h.CodePay, h.Monitor)
+
+	// 清理：超时未支付的订单过期
+	expired, err := h.CreateOrder("E2E-HK-EXPIRED", "3.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DB.Exec(`UPDATE codepay_orders SET add_time = ? WHERE id = ?`, time.Now().Add(-time.Hour), expired.TradeNo); err != nil {
+		t.Fatal(err)
+	}
+	result, started, err := housekeeping.Run(service.JobCleanup)
+	if err != nil || !started || result != "expired 1 orders" {
+		t.Fatalf("cleanup = %q, %v, %v, want expired 1 orders", result, started, err)
+	}
+
+	// 对账：已支付但商户未确认通知的订单重新通知
+	paid, err := h.CreateOrder("E2E-HK-PAID", "8.80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Gateway.AddBill(paid.PaymentAmount, paid.OutTradeNo)
+	h.RunMonitor()
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(paid.TradeNo)) == 1, nil
+	}); err != nil {
+		t.Fatalf("payment notification not received: %v", err)
+	}
+	if _, err := h.DB.Exec(`DELETE FROM notify_logs WHERE order_id = ?`, paid.TradeNo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DB.Exec(`UPDATE codepay_orders SET pay_time = ? WHERE id = ?`, time.Now().Add(-10*time.Minute), paid.TradeNo); err != nil {
+		t.Fatal(err)
+	}
+	result, _, err = housekeeping.Run(service.JobReconciliation)
+	if err != nil || result != "queued 1 of 1 unnotified orders" {
+		t.Fatalf("reconciliation = %q, %v, want queued 1 of 1 unnotified orders", result, err)
+	}
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(paid.TradeNo)) == 2, nil
+	}); err != nil {
+		t.Fatalf("notification not resent by reconciliation: %v", err)
+	}
+
+	// 统计汇总：定时执行（@every 1s）
+	if err := housekeeping.Start(); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006-01-02")
+	var stats []*database.DailyStats
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		var err error
+		stats, err = h.DB.GetDailyStats(MerchantID, today)
+		return len(stats) == 1, err
+	}); err != nil {
+		t.Fatalf("scheduled stats job did not run: %v", err)
+	}
+	// 过期订单已被清理删除
+	if s := stats[0]; s.Total != 1 || s.Paid != 1 || s.Revenue != paid.PaymentAmount {
+		t.Fatalf("daily stats = %+v, want 1 paid order, revenue %s", s, paid.PaymentAmount)
+	}
+
+	// 备份：只保留最近 backup_keep 个
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alimpay-20000101-000000.db", "alimpay-20000102-000000.db", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(backupDir, name), []byte("old"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, _, err = housekeeping.Run(service.JobBackup)
+	if err != nil || !strings.HasSuffix(result, "removed 1 old backups") {
+		t.Fatalf("backup = %q, %v, want 1 old backup removed", result, err)
+	}
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != "alimpay-20000102-000000.db" || names[2] != "notes.txt" {
+		t.Fatalf("backup dir = %v, want newest 2 backups and notes.txt", names)
+	}
+	data, err := os.ReadFile(filepath.Join(backupDir, names[1]))
+	if err != nil || !bytes.HasPrefix(data, []byte("SQLite format 3")) {
+		t.Fatalf("backup %s is not a SQLite database: %v", names[1], err)
+	}
+
+	if _, _, err := housekeeping.Run("vacuum"); !errors.Is(err, service.ErrUnknownJob) {
+		t.Fatalf("unknown job error = %v, want ErrUnknownJob", err)
+	}
+}
+
+// TestOrderArchive 归档只移走超过保留期的已结束订单；保持开放的待支付订单、待审核退款和争议中的订单留在订单表，
+// 归档后保持开放的订单仍可匹配迟到的付款
+func TestOrderArchive(t *testing.T) {
+	h := startHarness(t)
+
+	pay := func(order *CreatedOrder) {
+		t.Helper()
+		h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+		h.RunMonitor()
+		if err := WaitFor(waitTimeout, func() (bool, error) {
+			stored, err := h.DB.GetOrderByID(order.TradeNo)
+			return err == nil && stored != nil && stored.Status == model.OrderStatusPaid, err
+		}); err != nil {
+			t.Fatalf("order %s not paid: %v", order.OutTradeNo, err)
+		}
+	}
+
+	paid, err := h.CreateOrder("E2E-ARCHIVE-PAID", "6.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pay(paid)
+	refunding, err := h.CreateOrder("E2E-ARCHIVE-REFUND", "6.20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pay(refunding)
+	created, err := h.DB.CreateRefundRequest(&model.RefundRequest{
+		RefundNo:   "R-E2E-ARCHIVE",
+		OrderID:    refunding.TradeNo,
+		OutTradeNo: refunding.OutTradeNo,
+		PID:        MerchantID,
+		Amount:     refunding.PaymentAmount,
+		Status:     model.RefundStatusPending,
+	}, refunding.PaymentAmount)
+	if err != nil || !created {
+		t.Fatalf("create refund request = %v, %v", created, err)
+	}
+	disputed, err := h.CreateOrder("E2E-ARCHIVE-DISPUTED", "6.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pay(disputed)
+	if _, err := h.DB.SetOrderDisputed(disputed.TradeNo, true); err != nil {
+		t.Fatal(err)
+	}
+	open, err := h.createOrder("E2E-ARCHIVE-OPEN", "6.40", map[string]string{"auto_close": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := []*CreatedOrder{paid, refunding, disputed, open}
+	for _, order := range orders {
+		if _, err := h.DB.Exec(`UPDATE codepay_orders SET add_time = ? WHERE id = ?`, time.Now().Add(-48*time.Hour), order.TradeNo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archiver := service.NewOrderArchiver(h.DB, 24*time.Hour, time.Hour, 500)
+	archived, started, err := archiver.RunOnce()
+	if err != nil || !started || archived != 1 {
+		t.Fatalf("archive = %d, %v, %v, want 1 order archived", archived, started, err)
+	}
+	for _, order := range orders {
+		stored, err := h.DB.GetOrderByID(order.TradeNo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantArchived := order == paid; (stored == nil) != wantArchived {
+			t.Fatalf("order %s in codepay_orders = %v, want archived %v", order.OutTradeNo, stored != nil, wantArchived)
+		}
+	}
+	if stored, err := h.DB.GetArchivedOrderByID(paid.TradeNo); err != nil || stored == nil {
+		t.Fatalf("paid order not in archive: %v", err)
+	}
+
+	// 保持开放的订单留在订单表，迟到的付款仍能匹配
+	pay(open)
+}
+
+// TestJobHistory 定时执行的维护任务记录执行结果（成功和失败），可按任务和状态查询
+func TestJobHistory(t *testing.T) {
+	h := startHarness(t)
+
+	// 备份目录是普通文件，备份必然失败
+	blocker := filepath.Join(h.Dir, "not-a-dir")
+	if err := os.WriteFile(blocker, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h.Config.Housekeeping = config.HousekeepingConfig{
+		Stats:      "@every 1s",
+		Backup:     "@every 1s",
+		BackupDir:  blocker,
+		BackupKeep: 1,
+	}
+	housekeeping := service.NewHousekeeping(&h.Config.Housekeeping, h.DB, h.CodePay, h.Monitor)
+	if err := housekeeping.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var latest []*model.JobRun
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		var err error
+		latest, err = h.DB.GetLatestJobRuns()
+		return len(latest) == 2, err
+	}); err != nil {
+		t.Fatalf("scheduled jobs not recorded: %v", err)
+	}
+	if latest[0].Name != service.JobBackup || latest[0].Status != model.JobStatusFailed || latest[0].Error == "" {
+		t.Fatalf("latest backup run = %+v, want failed with error", latest[0])
+	}
+	if latest[1].Name != service.JobStats || latest[1].Status != model.JobStatusSuccess ||
+		latest[1].Trigger != model.JobTriggerSchedule || latest[1].Result == "" {
+		t.Fatalf("latest stats run = %+v, want scheduled success with result", latest[1])
+	}
+
+	runs, err := h.DB.GetJobRuns("", model.JobStatusFailed, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range runs {
+		if run.Name != service.JobBackup {
+			t.Fatalf("failed runs include %s, want backup only", run.Name)
+		}
+	}
+	if len(runs) == 0 {
+		t.Fatalf("no failed runs returned")
+	}
+}
+
+// TestWorkerPoolMetrics 监听和通知Worker池的统计通过 /metrics 以 Prometheus 文本格式输出
+func TestWorkerPoolMetrics(t *testing.T) {
+	h := startHarness(t)
+
+	resp, err := h.client.Get(h.URL() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE alimpay_worker_pool_tasks_rejected_total counter",
+		`alimpay_worker_pool_workers{pool="notify"} `,
+		`alimpay_worker_pool_task_wait_seconds_bucket{pool="monitor",le="+Inf"} `,
+		`alimpay_worker_pool_task_duration_seconds_count{pool="notify"} `,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("/metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// TestJobTrigger 通过管理接口手动触发维护任务：立即返回执行记录ID，轮询该记录直到执行完成
+func TestJobTrigger(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Housekeeping = config.HousekeepingConfig{
+		BackupDir:  filepath.Join(h.Dir, "backups"),
+		BackupKeep: 1,
+	}
+	adminHandler := handler.NewAdminHandler(h.DB, h.CodePay, h.Config)
+	adminHandler.SetHousekeeping(service.NewHousekeeping(&h.Config.Housekeeping, h.DB, h.CodePay, h.Monitor))
+	router := gin.New()
+	router.GET("/admin/jobs/:id", adminHandler.HandleJobRun)
+	router.POST("/admin/jobs/run", adminHandler.HandleRunJob)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	type response struct {
+		Success bool          `json:"success"`
+		Error   string        `json:"error"`
+		Run     *model.JobRun `json:"run"`
+	}
+	trigger := func(job string) (int, *response, error) {
+		resp, err := http.Post(server.URL+"/admin/jobs/run", "application/json", strings.NewReader(`{"job":"`+job+`"}`))
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		var out response
+		return resp.StatusCode, &out, json.NewDecoder(resp.Body).Decode(&out)
+	}
+
+	status, out, err := trigger(service.JobBackup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusAccepted || out.Run == nil || out.Run.ID == 0 || out.Run.Trigger != model.JobTriggerManual {
+		t.Fatalf("trigger backup = %d %+v, want 202 with manual run", status, out)
+	}
+
+	var run *model.JobRun
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		resp, err := http.Get(fmt.Sprintf("%s/admin/jobs/%d", server.URL, out.Run.ID))
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		var polled response
+		if err := json.NewDecoder(resp.Body).Decode(&polled); err != nil {
+			return false, err
+		}
+		run = polled.Run
+		return run != nil && run.Status != model.JobStatusRunning, nil
+	}); err != nil {
+		t.Fatalf("backup run %d did not finish: %v", out.Run.ID, err)
+	}
+	if run.Status != model.JobStatusSuccess || !strings.HasPrefix(run.Result, "saved ") {
+		t.Fatalf("backup run = %+v, want success", run)
+	}
+
+	if status, out, err := trigger("vacuum"); err != nil || status != http.StatusBadRequest {
+		t.Fatalf("trigger unknown job = %d %+v %v, want 400", status, out, err)
+	}
+	resp, err := http.Get(server.URL + "/admin/jobs/999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing run status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,426 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/database"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/middleware"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRefundRequest 商户对已支付订单分两次申请部分退款：超出订单金额的申请被拒绝，重复的商户退款单号返回已有申请，
+// 同意的金额累计达到订单金额时订单变为已退款
+func TestRefundRequest(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-REFUND", "10.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.CodePay.RequestRefund(MerchantID, service.RefundRequestParams{
+		OutTradeNo: order.OutTradeNo,
+		Money:      "1.00",
+	}); !errors.Is(err, service.ErrRefundOrderNotPaid) {
+		t.Fatalf("refund request for unpaid order: err = %v, want %v", err, service.ErrRefundOrderNotPaid)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("order not paid: %v", err)
+	}
+
+	first, created, err := h.CodePay.RequestRefund(MerchantID, service.RefundRequestParams{
+		OutTradeNo:  order.OutTradeNo,
+		OutRefundNo: "E2E-REFUND-1",
+		Money:       "6.00",
+		Reason:      "部分退款",
+	})
+	if err != nil || !created || first.Status != model.RefundStatusPending {
+		t.Fatalf("first refund request = %+v, created %v, err %v", first, created, err)
+	}
+
+	// 商户重试：返回已有申请
+	retried, created, err := h.CodePay.RequestRefund(MerchantID, service.RefundRequestParams{
+		OutTradeNo:  order.OutTradeNo,
+		OutRefundNo: "E2E-REFUND-1",
+		Money:       "6.00",
+	})
+	if err != nil || created || retried.RefundNo != first.RefundNo {
+		t.Fatalf("retried refund request = %+v, created %v, err %v, want %s", retried, created, err, first.RefundNo)
+	}
+
+	// 待审核金额计入可退款金额
+	if _, _, err := h.CodePay.RequestRefund(MerchantID, service.RefundRequestParams{
+		TradeNo: order.TradeNo,
+		Money:   "4.01",
+	}); !errors.Is(err, service.ErrRefundAmountExceeded) {
+		t.Fatalf("refund request over order amount: err = %v, want %v", err, service.ErrRefundAmountExceeded)
+	}
+
+	second, _, err := h.CodePay.RequestRefund(MerchantID, service.RefundRequestParams{
+		TradeNo: order.TradeNo,
+		Money:   "4.00",
+	})
+	if err != nil {
+		t.Fatalf("second refund request: %v", err)
+	}
+
+	if _, err := h.CodePay.ApproveRefundRequest(first.RefundNo, ""); err != nil {
+		t.Fatalf("approve first refund request: %v", err)
+	}
+	if _, err := h.CodePay.ApproveRefundRequest(first.RefundNo, ""); !errors.Is(err, service.ErrRefundRequestProcessed) {
+		t.Fatalf("approve processed refund request: err = %v, want %v", err, service.ErrRefundRequestProcessed)
+	}
+	if status, err := h.OrderStatus(order.OutTradeNo); err != nil || status != model.OrderStatusPaid {
+		t.Fatalf("order status after partial refund = %d, %v, want %d", status, err, model.OrderStatusPaid)
+	}
+
+	if _, err := h.CodePay.ApproveRefundRequest(second.RefundNo, "已退款"); err != nil {
+		t.Fatalf("approve second refund request: %v", err)
+	}
+	if status, err := h.OrderStatus(order.OutTradeNo); err != nil || status != model.OrderStatusRefund {
+		t.Fatalf("order status after full refund = %d, %v, want %d", status, err, model.OrderStatusRefund)
+	}
+
+	queried, err := h.CodePay.GetRefundRequest(MerchantID, "", "E2E-REFUND-1")
+	if err != nil || queried.Status != model.RefundStatusApproved || queried.ProcessedAt == nil {
+		t.Fatalf("queried refund request = %+v, err %v, want approved", queried, err)
+	}
+	if _, err := h.CodePay.GetRefundRequest("other-merchant", first.RefundNo, ""); !errors.Is(err, service.ErrRefundRequestNotFound) {
+		t.Fatalf("refund request of another merchant: err = %v, want %v", err, service.ErrRefundRequestNotFound)
+	}
+}
+
+// TestDisputedOrder 商户将已支付订单标记为争议：争议期间不再通知商户，收入统计不含该订单；管理员解除后恢复
+func TestDisputedOrder(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-DISPUTE", "8.80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.CodePay.DisputeOrder(MerchantID, "", order.OutTradeNo, "拒付"); !errors.Is(err, service.ErrDisputeOrderNotPaid) {
+		t.Fatalf("dispute unpaid order: err = %v, want %v", err, service.ErrDisputeOrderNotPaid)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+
+	disputed, changed, err := h.CodePay.DisputeOrder(MerchantID, order.TradeNo, "", "买家投诉")
+	if err != nil || !changed || !disputed.Disputed {
+		t.Fatalf("dispute order = %+v, changed %v, err %v", disputed, changed, err)
+	}
+	if _, changed, err := h.CodePay.DisputeOrder(MerchantID, "", order.OutTradeNo, ""); err != nil || changed {
+		t.Fatalf("dispute disputed order: changed %v, err %v, want unchanged", changed, err)
+	}
+
+	// 通知冻结（使用标记前加载的订单，模拟排队中的通知）
+	stale, err := h.DB.GetOrderByOutTradeNo(order.OutTradeNo, MerchantID)
+	if err != nil || !stale.Disputed {
+		t.Fatalf("stored order = %+v, err %v, want disputed", stale, err)
+	}
+	stale.Disputed = false
+	notified := len(h.Notify.Find(order.TradeNo))
+	if err := h.CodePay.SendNotification(stale); !errors.Is(err, service.ErrOrderDisputed) {
+		t.Fatalf("notify disputed order: err = %v, want %v", err, service.ErrOrderDisputed)
+	}
+	if n := len(h.Notify.Find(order.TradeNo)); n != notified {
+		t.Fatalf("disputed order notified %d times, want %d", n, notified)
+	}
+
+	stats, err := h.DB.GetOrderStats(MerchantID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Disputed != 1 || stats.Revenue != 0 || stats.DisputedAmount != order.PaymentAmount {
+		t.Fatalf("stats of disputed order = %+v, want revenue 0 and disputed amount %s", stats, order.PaymentAmount)
+	}
+
+	if _, changed, err := h.CodePay.SetOrderDispute(order.TradeNo, false, "已协商"); err != nil || !changed {
+		t.Fatalf("resolve dispute: changed %v, err %v", changed, err)
+	}
+	if err := h.CodePay.SendNotification(stale); err != nil {
+		t.Fatalf("notify after dispute resolved: %v", err)
+	}
+	stats, err = h.DB.GetOrderStats(MerchantID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Disputed != 0 || stats.Revenue != order.PaymentAmount {
+		t.Fatalf("stats after dispute resolved = %+v, want revenue %s", stats, order.PaymentAmount)
+	}
+}
+
+// TestMerchantKeyRotation 商户密钥轮换：过渡期内新旧密钥都能下单，通知使用新密钥签名；再次轮换且过渡期为0时旧密钥立即失效
+func TestMerchantKeyRotation(t *testing.T) {
+	h := startHarness(t)
+
+	rotation, err := h.CodePay.RotateMerchantKey(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotation.Key == MerchantKey || rotation.PreviousKeyExpiresAt == nil {
+		t.Fatalf("rotation = %+v, want new key and previous key expiry", rotation)
+	}
+	if stored, err := h.DB.GetSetting(database.SettingMerchantKey); err != nil || stored != rotation.Key {
+		t.Fatalf("stored merchant key = %q, err %v, want rotated key", stored, err)
+	}
+
+	if _, err := h.createOrderAs(MerchantID, MerchantKey, "E2E-KEY-OLD", "5.10", nil); err != nil {
+		t.Fatalf("create order with previous key in grace: %v", err)
+	}
+	order, err := h.createOrderAs(MerchantID, rotation.Key, "E2E-KEY-NEW", "5.20", nil)
+	if err != nil {
+		t.Fatalf("create order with new key: %v", err)
+	}
+	if err := h.CodePay.VerifyMerchant(MerchantID, MerchantKey); err != nil {
+		t.Fatalf("verify previous key in grace: %v", err)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+	params := make(map[string]string)
+	notify := h.Notify.Find(order.TradeNo)[0]
+	for k := range notify {
+		params[k] = notify.Get(k)
+	}
+	if !utils.VerifySign(params, rotation.Key) {
+		t.Fatalf("notification is not signed with the new key: %v", params)
+	}
+
+	// 过渡期为0：上一次的新密钥和最初的密钥都立即失效
+	latest, err := h.CodePay.RotateMerchantKey(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.PreviousKeyExpiresAt != nil {
+		t.Fatalf("rotation without grace = %+v, want no previous key expiry", latest)
+	}
+	for _, key := range []string{MerchantKey, rotation.Key} {
+		if _, err := h.createOrderAs(MerchantID, key, "E2E-KEY-REVOKED", "5.30", nil); err == nil {
+			t.Fatalf("create order with revoked key %s succeeded", key)
+		}
+		if err := h.CodePay.VerifyMerchant(MerchantID, key); err == nil {
+			t.Fatalf("verify revoked key %s succeeded", key)
+		}
+	}
+	if _, err := h.createOrderAs(MerchantID, latest.Key, "E2E-KEY-LATEST", "5.40", nil); err != nil {
+		t.Fatalf("create order with latest key: %v", err)
+	}
+
+	if _, err := h.CodePay.RotateMerchantKey(-time.Second); !errors.Is(err, service.ErrInvalidKeyRotationGrace) {
+		t.Fatalf("rotate with negative grace: err = %v, want %v", err, service.ErrInvalidKeyRotationGrace)
+	}
+}
+
+// TestMerchantIPWhitelist 商户来源IP白名单：白名单外的下单请求返回签名的错误（伪造 X-Forwarded-For 无效），
+// 加入白名单后正常下单；未配置白名单的商户不受影响
+func TestMerchantIPWhitelist(t *testing.T) {
+	h := startHarness(t)
+
+	merchantAuth := middleware.NewMerchantAuth(middleware.MerchantCredential{
+		ID:         MerchantID,
+		Key:        MerchantKey,
+		AllowedIPs: []string{"203.0.113.0/24"},
+	})
+	yipayHandler := handler.NewYiPayHandler(h.DB, h.CodePay, h.Config)
+	router := gin.New()
+	router.POST("/api/submit", middleware.JSONBody(), merchantAuth.RestrictIPs(false), yipayHandler.HandleSubmitAPI)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	type response struct {
+		Code     int    `json:"code"`
+		Msg      string `json:"msg"`
+		PID      string `json:"pid"`
+		IP       string `json:"ip"`
+		Sign     string `json:"sign"`
+		SignType string `json:"sign_type"`
+		TradeNo  string `json:"trade_no"`
+	}
+	submit := func(pid, key, outTradeNo, forwardedFor string) (*response, error) {
+		params := map[string]string{
+			"pid":          pid,
+			"type":         model.PaymentTypeAlipay,
+			"out_trade_no": outTradeNo,
+			"notify_url":   h.Notify.URL(),
+			"return_url":   h.Notify.URL(),
+			"name":         "E2E " + outTradeNo,
+			"money":        "7.20",
+			"price":        "7.20",
+		}
+		params["sign"] = utils.GenerateSign(params, key)
+		form := url.Values{}
+		for k, v := range params {
+			form.Set(k, v)
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/submit", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		var result response
+		return &result, decodeResponse("/api/submit", resp, &result)
+	}
+
+	// 白名单外的请求被拒绝，伪造 X-Forwarded-For 无效；错误响应使用商户密钥签名
+	result, err := submit(MerchantID, MerchantKey, "E2E-IPWL-1", "203.0.113.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := map[string]string{"code": strconv.Itoa(result.Code), "msg": result.Msg, "pid": result.PID, "ip": result.IP, "sign": result.Sign}
+	if result.Code != -1 || result.IP != "127.0.0.1" || result.PID != MerchantID || !utils.VerifySign(signed, MerchantKey) {
+		t.Fatalf("request outside whitelist = %+v, want signed rejection for 127.0.0.1", result)
+	}
+	if order, err := h.DB.GetOrderByOutTradeNo("E2E-IPWL-1", MerchantID); err != nil || order != nil {
+		t.Fatalf("order created from disallowed ip: %+v, err %v", order, err)
+	}
+
+	// 未配置白名单的商户（如沙箱商户）不受限制
+	sandboxID, sandboxKey, err := h.CodePay.IssueSandboxCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merchantAuth.SetCredential(middleware.MerchantCredential{ID: sandboxID, Key: sandboxKey})
+	if result, err := submit(sandboxID, sandboxKey, "E2E-IPWL-SANDBOX", ""); err != nil || result.Code != 1 {
+		t.Fatalf("sandbox merchant order = %+v, err %v, want created", result, err)
+	}
+
+	// 加入白名单后正常下单
+	merchantAuth.SetCredential(middleware.MerchantCredential{
+		ID:         MerchantID,
+		Key:        MerchantKey,
+		AllowedIPs: []string{"203.0.113.0/24", "127.0.0.1"},
+	})
+	if result, err := submit(MerchantID, MerchantKey, "E2E-IPWL-2", ""); err != nil || result.Code != 1 || result.TradeNo == "" {
+		t.Fatalf("request inside whitelist = %+v, err %v, want created", result, err)
+	}
+}
+
+// TestMerchantRateLimit 已配置商户的请求超出商户限额后返回429；轮换未配置的随机 pid 不会获得新的商户限额，只受IP限额约束
+func TestMerchantRateLimit(t *testing.T) {
+	merchantAuth := middleware.NewMerchantAuth(middleware.MerchantCredential{ID: MerchantID, Key: MerchantKey})
+	limiter := middleware.NewRateLimiter(middleware.RateLimitOptions{
+		IPRate: 0.001, IPBurst: 5,
+		MerchantRate: 0.001, MerchantBurst: 2,
+		KnownMerchant: merchantAuth.HasMerchant,
+	})
+	router := gin.New()
+	router.GET("/api/order", limiter.Limit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	query := func(pid string) int {
+		resp, err := http.Get(server.URL + "/api/order?pid=" + url.QueryEscape(pid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if status := query(MerchantID); status != want {
+			t.Fatalf("merchant request %d = %d, want %d", i+1, status, want)
+		}
+	}
+
+	// IP限额已用3个令牌，随机 pid 只剩2个请求可用
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		if status := query("random-" + strconv.Itoa(i)); status != want {
+			t.Fatalf("random pid request %d = %d, want %d", i+1, status, want)
+		}
+	}
+}
+
+// TestHMACSign 商户签名类型为 MD5 时同时接受 HMAC-SHA256 签名；改为 HMAC-SHA256 后拒绝MD5签名，通知按HMAC-SHA256签名
+func TestHMACSign(t *testing.T) {
+	h := startHarness(t)
+
+	if _, err := h.CreateHMACOrder("E2E-HMAC-SIGN-1", "8.10"); err != nil {
+		t.Fatalf("HMAC-SHA256 order rejected by MD5 merchant: %v", err)
+	}
+
+	h.Config.Merchant.SignType = utils.SignTypeHMACSHA256
+	if _, err := h.CreateOrder("E2E-HMAC-SIGN-2", "8.20"); err == nil {
+		t.Fatalf("MD5 order accepted by HMAC-SHA256 merchant")
+	}
+	order, err := h.CreateHMACOrder("E2E-HMAC-SIGN-3", "8.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+
+	notify := h.Notify.Find(order.TradeNo)[0]
+	params := make(map[string]string)
+	for k := range notify {
+		params[k] = notify.Get(k)
+	}
+	if params["sign_type"] != utils.SignTypeHMACSHA256 || len(params["sign"]) != 64 || !utils.VerifySign(params, MerchantKey) {
+		t.Fatalf("notify sign_type = %q, sign = %q, want valid HMAC-SHA256 signature", params["sign_type"], params["sign"])
+	}
+
+	// 验签排查接口同样按商户的签名类型检查；MD5签名的通知不可信
+	verification, err := h.CodePay.VerifyNotification(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verification.Valid {
+		t.Fatalf("notify verification = %+v, want valid", verification)
+	}
+	params["sign_type"] = utils.SignTypeMD5
+	params["sign"] = utils.GenerateSign(params, MerchantKey)
+	if verification, err = h.CodePay.VerifyNotification(params); err != nil {
+		t.Fatal(err)
+	}
+	if verification.Valid {
+		t.Fatalf("MD5 signed notification accepted by HMAC-SHA256 merchant")
+	}
+}
@@ -0,0 +1,523 @@
+package test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// 模拟的开放平台接口
+const (
+	billQueryMethod      = "alipay.data.bill.accountlog.query"
+	tradePrecreateMethod = "alipay.trade.precreate"
+	tradeQueryMethod     = "alipay.trade.query"
+	tradeWapPayMethod    = "alipay.trade.wap.pay"
+)
+
+// MockAlipayGateway 模拟支付宝开放平台网关
+// 校验请求签名，按时间范围返回通过 AddBill 注入的账单，响应使用支付宝私钥签名；
+// 当面付预下单和手机网站支付收银台按商户订单号保存交易，由 PayTrade 模拟用户付款
+type MockAlipayGateway struct {
+	server    *httptest.Server
+	appID     string
+	publicKey *rsa.PublicKey  // 应用公钥，用于校验请求签名
+	alipayKey *rsa.PrivateKey // 支付宝私钥，用于响应签名（服务配置对应的公钥）
+	tamper    bool            // 签名后篡改响应内容
+
+	mu       sync.Mutex
+	bills    []MockBill
+	trades   map[string]*MockTrade // 当面付、手机网站支付交易（商户订单号 -> 交易）
+	paid     int                   // 已付款的交易数（生成交易号）
+	requests int                   // 账单查询请求数
+	errors   []string              // 签名错误、参数错误等（测试结束时检查）
+}
+
+// MockTrade 模拟当面付、手机网站支付交易
+type MockTrade struct {
+	OutTradeNo string
+	TradeNo    string // 支付宝交易号（付款后生成）
+	Amount     string
+	Subject    string
+	NotifyURL  string
+	ReturnURL  string // 手机网站支付付款后的跳转地址
+	Status     string // 为空表示用户尚未付款（查询返回交易不存在）
+}
+
+// MockBill 模拟账单
+type MockBill struct {
+	TradeNo string       // 支付宝交易号
+	Amount  model.Amount // 收入金额
+	Memo    string       // 转账备注（传统模式为商户订单号）
+	Time    time.Time    // 入账时间
+}
+
+// NewMockAlipayGateway 启动模拟网关
+func NewMockAlipayGateway(appID string, publicKey *rsa.PublicKey, alipayKey *rsa.PrivateKey) *MockAlipayGateway {
+	g := &MockAlipayGateway{
+		appID:     appID,
+		publicKey: publicKey,
+		alipayKey: alipayKey,
+		trades:    make(map[string]*MockTrade),
+	}
+	g.server = httptest.NewServer(http.HandlerFunc(g.handle))
+	return g
+}
+
+// URL 网关地址（配置为 alipay.server_url）
+func (g *MockAlipayGateway) URL() string {
+	return g.server.URL
+}
+
+// Close 关闭网关
+func (g *MockAlipayGateway) Close() {
+	g.server.Close()
+}
+
+// SetTamperResponses 设置是否在签名后篡改响应内容（用于验证服务拒绝被篡改的响应）
+func (g *MockAlipayGateway) SetTamperResponses(tamper bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tamper = tamper
+}
+
+// AddBill 注入一条收入账单，返回支付宝交易号
+func (g *MockAlipayGateway) AddBill(amount model.Amount, memo string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tradeNo := fmt.Sprintf("2026%012d", len(g.bills)+1)
+	g.bills = append(g.bills, MockBill{
+		TradeNo: tradeNo,
+		Amount:  amount,
+		Memo:    memo,
+		Time:    time.Now(),
+	})
+	return tradeNo
+}
+
+// Requests 收到的账单查询次数
+func (g *MockAlipayGateway) Requests() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.requests
+}
+
+// Err 返回网关记录的第一个请求错误（签名错误等）
+func (g *MockAlipayGateway) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("mock alipay gateway: %s", g.errors[0])
+}
+
+// handle 处理网关请求（手机网站支付由浏览器以GET访问，参数在查询字符串中）
+func (g *MockAlipayGateway) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		g.fail(w, "", "invalid form: "+err.Error())
+		return
+	}
+
+	params := make(map[string]string)
+	for k := range r.Form {
+		params[k] = r.Form.Get(k)
+	}
+
+	method := params["method"]
+	if params["app_id"] != g.appID {
+		g.fail(w, method, "unexpected app_id: "+params["app_id"])
+		return
+	}
+	if err := g.verify(params); err != nil {
+		g.fail(w, method, "invalid sign: "+err.Error())
+		return
+	}
+
+	switch method {
+	case billQueryMethod:
+		g.handleBillQuery(w, params)
+	case tradePrecreateMethod:
+		g.handlePrecreate(w, params)
+	case tradeQueryMethod:
+		g.handleTradeQuery(w, params)
+	case tradeWapPayMethod:
+		g.handleWapPay(w, params)
+	default:
+		g.fail(w, method, "unexpected method: "+method)
+	}
+}
+
+// handleBillQuery 账单查询
+func (g *MockAlipayGateway) handleBillQuery(w http.ResponseWriter, params map[string]string) {
+	var biz struct {
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		PageNo    int    `json:"page_no"`
+		PageSize  int    `json:"page_size"`
+	}
+	if err := json.Unmarshal([]byte(params["biz_content"]), &biz); err != nil {
+		g.fail(w, billQueryMethod, "invalid biz_content: "+err.Error())
+		return
+	}
+	start, err1 := time.ParseInLocation("2006-01-02 15:04:05", biz.StartTime, time.Local)
+	end, err2 := time.ParseInLocation("2006-01-02 15:04:05", biz.EndTime, time.Local)
+	if err1 != nil || err2 != nil {
+		g.fail(w, billQueryMethod, "invalid time range: "+biz.StartTime+" ~ "+biz.EndTime)
+		return
+	}
+	if biz.PageNo < 1 || biz.PageSize < 1 {
+		g.fail(w, billQueryMethod, fmt.Sprintf("invalid page: page_no=%d page_size=%d", biz.PageNo, biz.PageSize))
+		return
+	}
+
+	g.mu.Lock()
+	g.requests++
+	details := make([]map[string]string, 0)
+	for _, bill := range g.bills {
+		// 时间精确到秒，结束时间包含当秒
+		if bill.Time.Before(start) || bill.Time.After(end.Add(time.Second)) {
+			continue
+		}
+		details = append(details, map[string]string{
+			"account_log_id":  "L" + bill.TradeNo,
+			"alipay_order_no": bill.TradeNo,
+			"merchant_out_no": "",
+			"trans_amount":    bill.Amount.String(),
+			"trans_memo":      bill.Memo,
+			"trans_dt":        bill.Time.Format("2006-01-02 15:04:05"),
+			"direction":       "收入",
+			"other_account":   "test***@example.com",
+			"balance":         "0.00",
+			"type":            "在线支付",
+		})
+	}
+	g.mu.Unlock()
+
+	// 按页返回，total_size 为时间范围内的全部账单数
+	total := len(details)
+	from := min((biz.PageNo-1)*biz.PageSize, total)
+	to := min(from+biz.PageSize, total)
+
+	g.respond(w, billQueryMethod, map[string]interface{}{
+		"code":        "10000",
+		"msg":         "Success",
+		"detail_list": details[from:to],
+		"page_no":     fmt.Sprint(biz.PageNo),
+		"page_size":   fmt.Sprint(biz.PageSize),
+		"total_size":  fmt.Sprint(total),
+	})
+}
+
+// verify 按支付宝RSA2规则校验请求签名
+func (g *MockAlipayGateway) verify(params map[string]string) error {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k != "sign" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["sign"])
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	return rsa.VerifyPKCS1v15(g.publicKey, crypto.SHA256, hashed[:], signature)
+}
+
+// fail 记录错误并返回业务错误响应
+func (g *MockAlipayGateway) fail(w http.ResponseWriter, method, msg string) {
+	g.mu.Lock()
+	g.errors = append(g.errors, msg)
+	g.mu.Unlock()
+
+	if method == "" {
+		method = billQueryMethod
+	}
+	g.respond(w, method, map[string]interface{}{
+		"code":     "40002",
+		"msg":      "Invalid Arguments",
+		"sub_code": "isv.invalid-arguments",
+		"sub_msg":  msg,
+	})
+}
+
+// respond 按开放平台格式返回响应
+func (g *MockAlipayGateway) respond(w http.ResponseWriter, method string, body map[string]interface{}) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 与支付宝一致：对响应节点的原始JSON文本签名（公钥证书模式附带 alipay_cert_sn）
+	hashed := sha256.Sum256(content)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.alipayKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	g.mu.Lock()
+	tamper := g.tamper
+	g.mu.Unlock()
+	if tamper {
+		// 模拟中间人修改账单金额等内容，签名保持不变
+		content = []byte(strings.Replace(string(content), `"code":"10000"`, `"code":"10000","tampered":true`, 1))
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	fmt.Fprintf(w, `{"%s_response":%s,"alipay_cert_sn":"mock","sign":%q}`,
+		strings.ReplaceAll(method, ".", "_"), content, base64.StdEncoding.EncodeToString(signature))
+}
+
+// handlePrecreate 当面付预下单（同一商户订单号重复预下单返回同一个二维码）
+func (g *MockAlipayGateway) handlePrecreate(w http.ResponseWriter, params map[string]string) {
+	var biz struct {
+		OutTradeNo     string `json:"out_trade_no"`
+		TotalAmount    string `json:"total_amount"`
+		Subject        string `json:"subject"`
+		TimeoutExpress string `json:"timeout_express"`
+	}
+	if err := json.Unmarshal([]byte(params["biz_content"]), &biz); err != nil {
+		g.fail(w, tradePrecreateMethod, "invalid biz_content: "+err.Error())
+		return
+	}
+	if biz.OutTradeNo == "" || biz.TotalAmount == "" || biz.Subject == "" || biz.TimeoutExpress == "" {
+		g.fail(w, tradePrecreateMethod, "missing precreate parameter: "+params["biz_content"])
+		return
+	}
+	if !strings.HasPrefix(params["notify_url"], "http") {
+		g.fail(w, tradePrecreateMethod, "invalid notify_url: "+params["notify_url"])
+		return
+	}
+
+	g.mu.Lock()
+	if _, exists := g.trades[biz.OutTradeNo]; !exists {
+		g.trades[biz.OutTradeNo] = &MockTrade{
+			OutTradeNo: biz.OutTradeNo,
+			Amount:     biz.TotalAmount,
+			Subject:    biz.Subject,
+			NotifyURL:  params["notify_url"],
+		}
+	}
+	g.mu.Unlock()
+
+	g.respond(w, tradePrecreateMethod, map[string]interface{}{
+		"code":         "10000",
+		"msg":          "Success",
+		"out_trade_no": biz.OutTradeNo,
+		"qr_code":      MockQRCodePrefix + biz.OutTradeNo,
+	})
+}
+
+// handleTradeQuery 交易查询（用户尚未付款时返回交易不存在）
+func (g *MockAlipayGateway) handleTradeQuery(w http.ResponseWriter, params map[string]string) {
+	var biz struct {
+		OutTradeNo string `json:"out_trade_no"`
+	}
+	if err := json.Unmarshal([]byte(params["biz_content"]), &biz); err != nil {
+		g.fail(w, tradeQueryMethod, "invalid biz_content: "+err.Error())
+		return
+	}
+
+	g.mu.Lock()
+	trade := g.trades[biz.OutTradeNo]
+	var found MockTrade
+	if trade != nil {
+		found = *trade
+	}
+	g.mu.Unlock()
+
+	if trade == nil || found.Status == "" {
+		g.respond(w, tradeQueryMethod, map[string]interface{}{
+			"code":     "40004",
+			"msg":      "Business Failed",
+			"sub_code": "ACQ.TRADE_NOT_EXIST",
+			"sub_msg":  "交易不存在",
+		})
+		return
+	}
+
+	g.respond(w, tradeQueryMethod, map[string]interface{}{
+		"code":           "10000",
+		"msg":            "Success",
+		"trade_no":       found.TradeNo,
+		"out_trade_no":   found.OutTradeNo,
+		"trade_status":   found.Status,
+		"total_amount":   found.Amount,
+		"buyer_logon_id": "tes***@example.com",
+	})
+}
+
+// handleWapPay 手机网站支付收银台（浏览器打开收银台地址时创建交易）
+func (g *MockAlipayGateway) handleWapPay(w http.ResponseWriter, params map[string]string) {
+	var biz struct {
+		OutTradeNo     string `json:"out_trade_no"`
+		TotalAmount    string `json:"total_amount"`
+		Subject        string `json:"subject"`
+		ProductCode    string `json:"product_code"`
+		QuitURL        string `json:"quit_url"`
+		TimeoutExpress string `json:"timeout_express"`
+	}
+	if err := json.Unmarshal([]byte(params["biz_content"]), &biz); err != nil {
+		g.failPage(w, "invalid biz_content: "+err.Error())
+		return
+	}
+	if biz.OutTradeNo == "" || biz.TotalAmount == "" || biz.Subject == "" || biz.TimeoutExpress == "" ||
+		biz.ProductCode != "QUICK_WAP_WAY" || biz.QuitURL == "" {
+		g.failPage(w, "missing wap pay parameter: "+params["biz_content"])
+		return
+	}
+	if !strings.HasPrefix(params["notify_url"], "http") || !strings.HasPrefix(params["return_url"], "http") {
+		g.failPage(w, "invalid notify_url or return_url: "+params["notify_url"]+" "+params["return_url"])
+		return
+	}
+
+	g.mu.Lock()
+	if _, exists := g.trades[biz.OutTradeNo]; !exists {
+		g.trades[biz.OutTradeNo] = &MockTrade{
+			OutTradeNo: biz.OutTradeNo,
+			Amount:     biz.TotalAmount,
+			Subject:    biz.Subject,
+			NotifyURL:  params["notify_url"],
+			ReturnURL:  params["return_url"],
+		}
+	}
+	g.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	fmt.Fprintf(w, "<html><body>支付宝收银台 %s %s</body></html>", biz.OutTradeNo, biz.TotalAmount)
+}
+
+// failPage 记录错误并返回收银台错误页面
+func (g *MockAlipayGateway) failPage(w http.ResponseWriter, msg string) {
+	g.mu.Lock()
+	g.errors = append(g.errors, msg)
+	g.mu.Unlock()
+
+	http.Error(w, msg, http.StatusBadRequest)
+}
+
+// Trade 获取交易（不存在时返回nil）
+func (g *MockAlipayGateway) Trade(outTradeNo string) *MockTrade {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	trade, exists := g.trades[outTradeNo]
+	if !exists {
+		return nil
+	}
+	found := *trade
+	return &found
+}
+
+// MockQRCodePrefix 模拟预下单返回的二维码内容前缀
+const MockQRCodePrefix = "https://qr.alipay.com/mock"
+
+// PayTrade 模拟用户付款，返回支付宝交易号
+func (g *MockAlipayGateway) PayTrade(outTradeNo string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	trade, exists := g.trades[outTradeNo]
+	if !exists {
+		return "", fmt.Errorf("trade not created: %s", outTradeNo)
+	}
+	if trade.Status == "" {
+		g.paid++
+		trade.TradeNo = fmt.Sprintf("2027%012d", g.paid)
+		trade.Status = "TRADE_SUCCESS"
+	}
+	return trade.TradeNo, nil
+}
+
+// SendTradeNotify 向下单时的 notify_url 发送异步通知，返回服务的应答内容
+// tamper 为 true 时签名后修改通知金额
+func (g *MockAlipayGateway) SendTradeNotify(outTradeNo string, tamper bool) (string, error) {
+	g.mu.Lock()
+	trade, exists := g.trades[outTradeNo]
+	var found MockTrade
+	if exists {
+		found = *trade
+	}
+	g.mu.Unlock()
+
+	if !exists || found.Status == "" {
+		return "", fmt.Errorf("trade not paid: %s", outTradeNo)
+	}
+
+	params := map[string]string{
+		"notify_time":    time.Now().Format("2006-01-02 15:04:05"),
+		"notify_type":    "trade_status_sync",
+		"notify_id":      "mock-" + found.TradeNo,
+		"app_id":         g.appID,
+		"charset":        "utf-8",
+		"version":        "1.0",
+		"trade_no":       found.TradeNo,
+		"out_trade_no":   found.OutTradeNo,
+		"trade_status":   found.Status,
+		"total_amount":   found.Amount,
+		"receipt_amount": found.Amount,
+		"subject":        found.Subject,
+		"gmt_payment":    time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	hashed := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.alipayKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	form.Set("sign", base64.StdEncoding.EncodeToString(signature))
+	form.Set("sign_type", "RSA2")
+	if tamper {
+		form.Set("total_amount", "0.01")
+	}
+
+	resp, err := http.PostForm(found.NotifyURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
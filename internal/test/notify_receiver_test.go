@@ -0,0 +1,77 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// NotifyReceiver 模拟商户的异步通知接收地址
+// 记录收到的通知参数和请求头并返回 success
+type NotifyReceiver struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	received []url.Values
+	headers  []http.Header // 与 received 一一对应
+}
+
+// NewNotifyReceiver 启动通知接收地址
+func NewNotifyReceiver() *NotifyReceiver {
+	n := &NotifyReceiver{}
+	n.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.mu.Lock()
+		n.received = append(n.received, r.URL.Query())
+		n.headers = append(n.headers, r.Header.Clone())
+		n.mu.Unlock()
+
+		_, _ = w.Write([]byte("success"))
+	}))
+	return n
+}
+
+// URL 通知地址（下单时作为 notify_url）
+func (n *NotifyReceiver) URL() string {
+	return n.server.URL + "/notify"
+}
+
+// Close 关闭通知接收地址
+func (n *NotifyReceiver) Close() {
+	n.server.Close()
+}
+
+// Find 查找指定平台订单号的通知（按收到顺序）
+func (n *NotifyReceiver) Find(tradeNo string) []url.Values {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var found []url.Values
+	for _, values := range n.received {
+		if values.Get("trade_no") == tradeNo {
+			found = append(found, values)
+		}
+	}
+	return found
+}
+
+// FindHeaders 查找指定平台订单号的通知请求头（按收到顺序）
+func (n *NotifyReceiver) FindHeaders(tradeNo string) []http.Header {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var found []http.Header
+	for i, values := range n.received {
+		if values.Get("trade_no") == tradeNo {
+			found = append(found, n.headers[i])
+		}
+	}
+	return found
+}
+
+// Count 收到的通知总数
+func (n *NotifyReceiver) Count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.received)
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/service"
+)
+
+// TestVerifyNotify 商户提交收到的通知参数验证：原样提交可信；篡改金额、附加参与签名的参数、未支付订单均不可信
+func TestVerifyNotify(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-VERIFY-1", "7.70")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未支付订单：签名正确但订单不一致
+	unpaid := map[string]string{
+		"pid":          MerchantID,
+		"trade_no":     order.TradeNo,
+		"out_trade_no": order.OutTradeNo,
+		"type":         "alipay",
+		"name":         "E2E " + order.OutTradeNo,
+		"money":        order.Money,
+		"trade_status": "TRADE_SUCCESS",
+	}
+	unpaid["sign"] = h.Sign(unpaid)
+	form := url.Values{}
+	for k, v := range unpaid {
+		form.Set(k, v)
+	}
+	verified, err := h.VerifyNotify(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Valid || !verified.SignValid || verified.OrderMatch {
+		t.Fatalf("unpaid order notification = %+v, want signature valid but order mismatch", verified)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+	notify := h.Notify.Find(order.TradeNo)[0]
+
+	verified, err = h.VerifyNotify(notify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Code != 1 || !verified.Valid || !verified.SignValid || !verified.OrderMatch || len(verified.Errors) != 0 {
+		t.Fatalf("received notification = %+v, want authentic", verified)
+	}
+	if strings.Contains(verified.SignContent, "sign=") || !strings.Contains(verified.SignContent, "trade_no="+order.TradeNo) {
+		t.Fatalf("unexpected sign_content %q", verified.SignContent)
+	}
+
+	// 签名错误的请求需用 key 参数认证
+	tampered := cloneValues(notify)
+	tampered.Set("money", "0.01")
+	if verified, err = h.VerifyNotify(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if verified.Code != -1 || verified.Valid || verified.SignValid || verified.OrderMatch || len(verified.Errors) != 0 {
+		t.Fatalf("unauthenticated tampered notification = %+v, want rejected by merchant authentication", verified)
+	}
+	tampered.Set("key", MerchantKey)
+	if verified, err = h.VerifyNotify(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if verified.Valid || verified.SignValid || verified.OrderMatch {
+		t.Fatalf("tampered notification = %+v, want rejected", verified)
+	}
+	// 签名错误时不核对订单，也不回显订单中的值；认证用的 key 不参与签名
+	for _, stored := range []string{order.Money, "E2E " + order.OutTradeNo, notify.Get("alipay_trade_no")} {
+		if containsString(verified.Errors, stored) {
+			t.Fatalf("tampered notification errors %q leak order data", verified.Errors)
+		}
+	}
+	if strings.Contains(verified.SignContent, "key=") {
+		t.Fatalf("sign_content %q includes the authentication key", verified.SignContent)
+	}
+
+	// 签名正确但参数与订单不一致：只指出不一致的参数名
+	mismatch := map[string]string{}
+	for k := range notify {
+		mismatch[k] = notify.Get(k)
+	}
+	mismatch["name"] = "other"
+	delete(mismatch, "sign")
+	mismatch["sign"] = h.Sign(mismatch)
+	form = url.Values{}
+	for k, v := range mismatch {
+		form.Set(k, v)
+	}
+	if verified, err = h.VerifyNotify(form); err != nil {
+		t.Fatal(err)
+	}
+	if verified.Valid || !verified.SignValid || verified.OrderMatch || !containsString(verified.Errors, "name does not match order") ||
+		containsString(verified.Errors, "E2E "+order.OutTradeNo) {
+		t.Fatalf("mismatched notification = %+v, want only the mismatching field named", verified)
+	}
+
+	// 框架附加的路由参数参与了签名
+	extra := cloneValues(notify)
+	extra.Set("s", "/notify/alipay")
+	extra.Set("key", MerchantKey)
+	if verified, err = h.VerifyNotify(extra); err != nil {
+		t.Fatal(err)
+	}
+	if verified.Valid || verified.SignValid || verified.OrderMatch || !containsString(verified.Errors, `"s"`) ||
+		containsString(verified.Errors, `"key"`) {
+		t.Fatalf("notification with extra parameter = %+v, want signature mismatch naming the parameter", verified)
+	}
+}
+
+// cloneValues 复制参数
+func cloneValues(values url.Values) url.Values {
+	cloned := url.Values{}
+	for k, v := range values {
+		cloned[k] = append([]string(nil), v...)
+	}
+	return cloned
+}
+
+// containsString 是否有元素包含子串
+func containsString(items []string, substr string) bool {
+	for _, item := range items {
+		if strings.Contains(item, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestSignedNotify 配置通知签名密钥后通知附带可验证的HMAC签名请求头；出口IP和签名方式通过 /api/notify/ips 公布
+func TestSignedNotify(t *testing.T) {
+	h := startHarness(t)
+
+	const secret = "e2e-notify-secret"
+	h.Config.Merchant.NotifySecret = secret
+	h.Config.Merchant.OutboundIPs = []string{"203.0.113.10", "198.51.100.0/24"}
+
+	source, err := h.FetchNotifySource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Code != 1 || len(source.IPs) != 2 || !source.Signature.Enabled ||
+		source.Signature.Header != service.NotifySignatureHeader {
+		t.Fatalf("notify source = %+v, want 2 ips and signature enabled", source)
+	}
+
+	order, err := h.CreateOrder("E2E-HMAC-1", "6.60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+
+	notify := h.Notify.Find(order.TradeNo)[0]
+	header := h.Notify.FindHeaders(order.TradeNo)[0]
+	params := make(map[string]string)
+	for k := range notify {
+		params[k] = notify.Get(k)
+	}
+
+	timestamp := header.Get(service.NotifyTimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(unix, 0)).Abs() > time.Minute {
+		t.Fatalf("notify timestamp header = %q, want current unix time", timestamp)
+	}
+	if got, want := header.Get(service.NotifySignatureHeader), service.NotifySignature(secret, timestamp, params); got != want {
+		t.Fatalf("notify signature header = %q, want %q", got, want)
+	}
+
+	// 篡改参数或使用其他密钥时签名不一致
+	params["money"] = "0.01"
+	if service.NotifySignature(secret, timestamp, params) == header.Get(service.NotifySignatureHeader) {
+		t.Fatalf("signature still matches after tampering money")
+	}
+}
@@ -0,0 +1,249 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/events"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// TestStatusPolling 轮询订单状态：状态未变化时返回304，订单支付后返回新状态和支付时间
+func TestStatusPolling(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-POLL-1", "3.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	polled, err := h.PollStatus(order.TradeNo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polled.HTTPStatus != http.StatusOK || polled.Status != model.OrderStatusPending || polled.ETag == "" {
+		t.Fatalf("first poll = %+v, want 200 pending with ETag", polled)
+	}
+	pendingETag := polled.ETag
+
+	polled, err = h.PollStatus(order.TradeNo, pendingETag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polled.HTTPStatus != http.StatusNotModified {
+		t.Fatalf("conditional poll returned %d, want 304", polled.HTTPStatus)
+	}
+
+	if polled, err = h.PollStatus("E2E-POLL-MISSING", ""); err != nil {
+		t.Fatal(err)
+	}
+	if polled.HTTPStatus != http.StatusNotFound {
+		t.Fatalf("poll of unknown order returned %d, want 404", polled.HTTPStatus)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+
+	// 支付事件使缓存失效；缓存时间很短，最迟过期后即可看到新状态
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		polled, err = h.PollStatus(order.TradeNo, pendingETag)
+		return err == nil && polled.HTTPStatus == http.StatusOK, err
+	})
+	if err != nil {
+		t.Fatalf("paid status not visible to polling: %v", err)
+	}
+	if polled.Status != model.OrderStatusPaid || polled.PayTime == "" || polled.ETag == pendingETag {
+		t.Fatalf("poll after payment = %+v, want paid with pay_time and new ETag", polled)
+	}
+}
+
+// TestStatusBadge 订单状态徽章为SVG图片，随订单支付从"待支付"变为"已支付"；未知订单同样返回徽章
+func TestStatusBadge(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-BADGE-1", "4.40")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badge, err := h.FetchBadge(order.TradeNo + ".svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if badge.HTTPStatus != http.StatusOK || !strings.HasPrefix(badge.ContentType, "image/svg+xml") {
+		t.Fatalf("badge = %d %q, want 200 image/svg+xml", badge.HTTPStatus, badge.ContentType)
+	}
+	if !strings.Contains(badge.Body, "<svg") || !strings.Contains(badge.Body, "待支付") {
+		t.Fatalf("pending badge does not show 待支付: %s", badge.Body)
+	}
+
+	if badge, err = h.FetchBadge(order.TradeNo); err != nil {
+		t.Fatal(err)
+	}
+	if badge.HTTPStatus != http.StatusNotFound {
+		t.Fatalf("badge without .svg returned %d, want 404", badge.HTTPStatus)
+	}
+
+	if badge, err = h.FetchBadge("E2E-BADGE-MISSING.svg"); err != nil {
+		t.Fatal(err)
+	}
+	if badge.HTTPStatus != http.StatusOK || !strings.Contains(badge.Body, "订单不存在") {
+		t.Fatalf("badge of unknown order = %d %s, want 200 订单不存在", badge.HTTPStatus, badge.Body)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		badge, err := h.FetchBadge(order.TradeNo + ".svg")
+		return err == nil && strings.Contains(badge.Body, "已支付"), err
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWebsocketLimits 支付页面WebSocket连接数上限：同一订单超过订阅上限、总连接数超过上限时拒绝（503），连接断开后释放名额
+func TestWebsocketLimits(t *testing.T) {
+	h := startHarness(t)
+
+	first, err := h.CreateOrder("E2E-WS-1", "1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := h.CreateOrder("E2E-WS-2", "1.20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wsHandler := handler.NewWebSocketHandler(h.DB, config.WebSocketConfig{
+		PingInterval:   1,
+		ReadTimeout:    2,
+		MaxConnections: 2,
+		MaxPerOrder:    1,
+	})
+	router := gin.New()
+	router.GET("/ws/order", wsHandler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dial := func(tradeNo string) (*websocket.Conn, int, error) {
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/order?order_id=" + url.QueryEscape(tradeNo)
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		return conn, status, err
+	}
+
+	conn, _, err := dial(first.TradeNo)
+	if err != nil {
+		t.Fatalf("first connection failed: %v", err)
+	}
+	// 连接建立后立即推送当前状态
+	var message handler.OrderStatusMessage
+	if err := conn.ReadJSON(&message); err != nil || message.OrderID != first.TradeNo {
+		conn.Close()
+		t.Fatalf("initial status = %+v (%v), want order %s", message, err, first.TradeNo)
+	}
+
+	if _, status, err := dial(first.TradeNo); err == nil || status != http.StatusServiceUnavailable {
+		conn.Close()
+		t.Fatalf("second connection for the same order returned %d (%v), want 503", status, err)
+	}
+
+	other, _, err := dial(second.TradeNo)
+	if err != nil {
+		conn.Close()
+		t.Fatalf("connection for another order failed: %v", err)
+	}
+	defer other.Close()
+
+	if _, status, err := dial("E2E-WS-3"); err == nil || status != http.StatusServiceUnavailable {
+		conn.Close()
+		t.Fatalf("connection over the total limit returned %d (%v), want 503", status, err)
+	}
+
+	// 断开后名额释放
+	conn.Close()
+	if err := WaitFor(waitTimeout, func() (bool, error) {
+		conn, _, err := dial(first.TradeNo)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestEventOutbox 订单事件写入发件箱并按序号补拉：从上次处理的序号继续只返回之后的事件，可按事件类型过滤
+func TestEventOutbox(t *testing.T) {
+	h := startHarness(t)
+
+	outbox := service.NewEventOutbox(h.DB, time.Hour)
+	outbox.Start()
+	defer outbox.Stop()
+
+	order, err := h.CreateOrder("E2E-OUTBOX-1", "7.70")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+
+	var replay *service.OutboxReplay
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		replay, err = outbox.Replay(0, MerchantID, nil, 10)
+		return err == nil && len(replay.Events) >= 2, err
+	})
+	if err != nil {
+		t.Fatalf("outbox events not recorded: %v", err)
+	}
+
+	created, paid := replay.Events[0], replay.Events[1]
+	if created.Type != events.EventOrderCreated || paid.Type != events.EventOrderPaid ||
+		created.OrderID != order.TradeNo || paid.OrderID != order.TradeNo || paid.Seq <= created.Seq {
+		t.Fatalf("outbox events = %+v, %+v, want created then paid for %s", created, paid, order.TradeNo)
+	}
+	if !strings.Contains(paid.Payload, `"status":1`) {
+		t.Fatalf("paid event payload = %s, want paid order", paid.Payload)
+	}
+
+	// 消费者从已处理的序号继续补拉
+	next, err := outbox.Replay(created.Seq, MerchantID, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Events) != 1 || next.Events[0].Seq != paid.Seq || next.NextAfter != paid.Seq || next.Gap {
+		t.Fatalf("replay after %d = %+v, want only the paid event", created.Seq, next)
+	}
+
+	filtered, err := outbox.Replay(0, MerchantID, []string{events.EventOrderPaid}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered.Events) != 1 || filtered.Events[0].Seq != paid.Seq || filtered.HasMore {
+		t.Fatalf("replay of %s = %+v, want only the paid event", events.EventOrderPaid, filtered)
+	}
+
+	other, err := outbox.Replay(0, "other-merchant", nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Events) != 0 {
+		t.Fatalf("replay for another merchant returned %d events", len(other.Events))
+	}
+}
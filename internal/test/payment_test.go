@@ -0,0 +1,406 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/model"
+)
+
+// TestPaymentLoop 下单 → 支付宝账单匹配 → 订单变为已支付（保存支付宝交易号）→ 商户收到签名正确的通知
+func TestPaymentLoop(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-PAY-1", "12.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("new order status = %d, want %d", status, model.OrderStatusPending)
+	}
+
+	// 传统模式：转账备注为商户订单号
+	alipayTradeNo := h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.RunMonitor()
+
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("order not paid after bill %s: %v", alipayTradeNo, err)
+	}
+	if h.Gateway.Requests() == 0 {
+		t.Fatalf("order paid without querying the gateway")
+	}
+
+	queried, err := h.QueryOrder(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queried.AlipayTradeNo != alipayTradeNo {
+		t.Fatalf("alipay_trade_no = %q, want %q", queried.AlipayTradeNo, alipayTradeNo)
+	}
+
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant notification not received: %v", err)
+	}
+
+	notify := h.Notify.Find(order.TradeNo)[0]
+	params := make(map[string]string)
+	for k := range notify {
+		params[k] = notify.Get(k)
+	}
+	if params["sign"] != h.Sign(params) {
+		t.Fatalf("notification sign mismatch: %v", params)
+	}
+	if params["trade_status"] != "TRADE_SUCCESS" || params["out_trade_no"] != order.OutTradeNo ||
+		params["money"] != order.Money || params["alipay_trade_no"] != alipayTradeNo {
+		t.Fatalf("unexpected notification: %v", params)
+	}
+
+	logs, err := h.DB.GetNotifyLogsByOrder(order.TradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 || logs[0].Status != model.NotifyStatusSuccess {
+		t.Fatalf("notification not recorded as success: %+v", logs)
+	}
+
+}
+
+// TestUnmatchedBill 金额不符的账单不能匹配订单，也不发送通知
+func TestUnmatchedBill(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-MISMATCH-1", "8.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount+1, order.OutTradeNo)
+	h.RunMonitor()
+
+	// 等待Worker完成账单查询
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return h.Gateway.Requests() > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("gateway not queried: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("order status = %d after mismatched bill, want %d", status, model.OrderStatusPending)
+	}
+	if count := h.Notify.Count(); count != 0 {
+		t.Fatalf("received %d notifications for unpaid order", count)
+	}
+
+}
+
+// TestBillMatchedOnce 经营码模式下同一笔账单只能匹配一个同金额订单（重复的监听周期也不会再次使用）
+func TestBillMatchedOnce(t *testing.T) {
+	h := startHarness(t)
+
+	first, err := h.CreateOrder("E2E-ONCE-1", "6.60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := h.CreateOrder("E2E-ONCE-2", "6.60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 订单以传统模式创建（支付金额相同），之后切换为按金额和时间匹配的经营码模式
+	h.Config.Payment.BusinessQRMode.Enabled = true
+	h.Config.Payment.BusinessQRMode.MatchTolerance = 600
+
+	// 账单时间精确到秒，需晚于订单创建时间
+	time.Sleep(time.Second)
+	h.Gateway.AddBill(first.PaymentAmount, "")
+
+	var paid, pending int
+	countStatus := func() error {
+		paid, pending = 0, 0
+		for _, order := range []*CreatedOrder{first, second} {
+			status, err := h.OrderStatus(order.OutTradeNo)
+			if err != nil {
+				return err
+			}
+			switch status {
+			case model.OrderStatusPaid:
+				paid++
+			case model.OrderStatusPending:
+				pending++
+			}
+		}
+		return nil
+	}
+
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		err := countStatus()
+		return paid > 0, err
+	})
+	if err != nil {
+		t.Fatalf("no order paid: %v", err)
+	}
+
+	checkOnce := func() {
+		t.Helper()
+
+		// 等待两个订单的Worker都处理完账单
+		time.Sleep(200 * time.Millisecond)
+		if err := countStatus(); err != nil {
+			t.Fatal(err)
+		}
+		if paid != 1 || pending != 1 {
+			t.Fatalf("one bill paid %d orders (%d pending), want 1 paid and 1 pending", paid, pending)
+		}
+	}
+	checkOnce()
+
+	// 再执行一个周期，账单仍在查询窗口内
+	requests := h.Gateway.Requests()
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return h.Gateway.Requests() > requests, nil
+	})
+	if err != nil {
+		t.Fatalf("gateway not queried again: %v", err)
+	}
+
+	checkOnce()
+}
+
+// TestBatchedBillQuery 一个监听周期只查询一次账单，即可匹配全部待支付订单
+func TestBatchedBillQuery(t *testing.T) {
+	h := startHarness(t)
+
+	var orders []*CreatedOrder
+	for i, money := range []string{"1.01", "2.02", "3.03"} {
+		order, err := h.CreateOrder(fmt.Sprintf("E2E-BATCH-%d", i+1), money)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+		orders = append(orders, order)
+	}
+
+	h.RunMonitor()
+	err := WaitFor(waitTimeout, func() (bool, error) {
+		for _, order := range orders {
+			status, err := h.OrderStatus(order.OutTradeNo)
+			if err != nil || status != model.OrderStatusPaid {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("orders not paid: %v", err)
+	}
+
+	if requests := h.Gateway.Requests(); requests != 1 {
+		t.Fatalf("gateway queried %d times for %d orders, want 1", requests, len(orders))
+	}
+
+}
+
+// TestPaginatedBills 查询窗口内账单超过一页时，翻页查询到后面页的账单
+func TestPaginatedBills(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-PAGE-1", "4.56")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 150笔其他收入在前，订单的账单在第2页（每页100条）
+	for i := 0; i < 150; i++ {
+		h.Gateway.AddBill(model.Amount(100+i), fmt.Sprintf("OTHER-%d", i))
+	}
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("order on page 2 not paid: %v", err)
+	}
+
+	if requests := h.Gateway.Requests(); requests != 2 {
+		t.Fatalf("gateway queried %d times for 151 bills, want 2 pages", requests)
+	}
+
+}
+
+// TestTamperedResponse 签名后被篡改的网关响应被拒绝，账单不参与匹配；恢复正常响应后订单照常支付
+func TestTamperedResponse(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-TAMPER-1", "7.77")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Gateway.AddBill(order.PaymentAmount, order.OutTradeNo)
+	h.Gateway.SetTamperResponses(true)
+	h.RunMonitor()
+
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return h.Gateway.Requests() > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("gateway not queried: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("order status = %d after tampered response, want %d", status, model.OrderStatusPending)
+	}
+	if count := h.Notify.Count(); count != 0 {
+		t.Fatalf("received %d notifications from tampered response", count)
+	}
+
+	h.Gateway.SetTamperResponses(false)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("order not paid after signed response: %v", err)
+	}
+
+}
+
+// TestKeepOpenOrder auto_close=0 下单的订单超时后不被清理，仍可匹配账单完成支付；普通订单超时后删除
+func TestKeepOpenOrder(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Payment.AutoCleanup = true
+
+	open, err := h.createOrder("E2E-KEEP-OPEN", "8.80", map[string]string{"auto_close": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	normal, err := h.CreateOrder("E2E-AUTO-CLOSE", "8.90")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个订单都超过支付时限
+	past := time.Now().Add(-time.Duration(h.Config.Payment.OrderTimeout+60) * time.Second)
+	if _, err := h.DB.Exec(`UPDATE codepay_orders SET add_time = ? WHERE id IN (?, ?)`, past, open.TradeNo, normal.TradeNo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CodePay.CleanupExpiredOrders(); err != nil {
+		t.Fatal(err)
+	}
+
+	if order, err := h.DB.GetOrderByID(normal.TradeNo); err == nil && order != nil {
+		t.Fatalf("order %s with auto_close default was not cleaned up, status = %d", normal.TradeNo, order.Status)
+	}
+	kept, err := h.DB.GetOrderByID(open.TradeNo)
+	if err != nil || kept == nil {
+		t.Fatalf("order %s with auto_close=0 was cleaned up: %v", open.TradeNo, err)
+	}
+	if !kept.KeepOpen || kept.Status != model.OrderStatusPending {
+		t.Fatalf("kept order = keep_open %v status %d, want open pending order", kept.KeepOpen, kept.Status)
+	}
+
+	h.Gateway.AddBill(open.PaymentAmount, open.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(open.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("kept order not paid after its timeout: %v", err)
+	}
+
+	if _, err := h.createOrder("E2E-BAD-AUTO-CLOSE", "9.00", map[string]string{"auto_close": "yes"}); err == nil {
+		t.Fatalf("order with auto_close=yes was accepted")
+	}
+}
+
+// TestPartialPayment 开启分笔支付后，备注为商户订单号的多笔小额转账累计到支付金额后订单完成支付
+func TestPartialPayment(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.Payment.PartialPayment.Enabled = true
+
+	order, err := h.CreateOrder("E2E-PARTIAL", "30.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := h.Gateway.AddBill(order.PaymentAmount-2000, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		paid, err := h.DB.GetPartialPaidAmount(order.TradeNo)
+		return paid == order.PaymentAmount-2000, err
+	})
+	if err != nil {
+		t.Fatalf("first partial payment %s not recorded: %v", first, err)
+	}
+
+	// 账单在查询窗口内被重复查到，不重复累计
+	h.RunMonitor()
+	status, err := h.OrderStatus(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != model.OrderStatusPending {
+		t.Fatalf("order status after partial payment = %d, want %d", status, model.OrderStatusPending)
+	}
+	if paid, err := h.DB.GetPartialPaidAmount(order.TradeNo); err != nil || paid != order.PaymentAmount-2000 {
+		t.Fatalf("partial paid amount after rescan = %s, %v", paid, err)
+	}
+
+	last := h.Gateway.AddBill(2000, order.OutTradeNo)
+	h.RunMonitor()
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		status, err := h.OrderStatus(order.OutTradeNo)
+		return status == model.OrderStatusPaid, err
+	})
+	if err != nil {
+		t.Fatalf("order not paid after partial payments: %v", err)
+	}
+
+	queried, err := h.QueryOrder(order.OutTradeNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queried.AlipayTradeNo != last {
+		t.Fatalf("alipay_trade_no = %q, want last partial payment %q", queried.AlipayTradeNo, last)
+	}
+
+	err = WaitFor(waitTimeout, func() (bool, error) {
+		return len(h.Notify.Find(order.TradeNo)) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("merchant not notified after partial payments: %v", err)
+	}
+}
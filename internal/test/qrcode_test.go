@@ -0,0 +1,339 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/notifier"
+	"alimpay-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestQRDailyQuota 达到每日限额（订单数、金额）的二维码不再分配；过期订单和前一天的订单不计入限额
+func TestQRDailyQuota(t *testing.T) {
+	h := startHarness(t)
+
+	yuan := func(s string) model.Amount {
+		amount, _ := model.ParseAmount(s)
+		return amount
+	}
+	h.Config.Payment.BusinessQRMode.PollingMode = "round_robin"
+	h.Config.Payment.BusinessQRMode.QRCodePaths = []config.QRCode{
+		{ID: "qr_a", Enabled: true, Priority: 1, MaxDailyCount: 2},
+		{ID: "qr_b", Enabled: true, Priority: 2, MaxDailyAmount: yuan("10.00")},
+	}
+	selector := service.NewQRCodeSelector(h.Config, h.DB)
+
+	seq := 0
+	assign := func(qrID, amount string, status int) (string, error) {
+		seq++
+		order := &model.Order{
+			ID:            fmt.Sprintf("E2EQUOTA%d", seq),
+			OutTradeNo:    fmt.Sprintf("E2E-QUOTA-%d", seq),
+			Type:          model.PaymentTypeAlipay,
+			PID:           MerchantID,
+			Name:          "quota",
+			Price:         yuan(amount),
+			PaymentAmount: yuan(amount),
+			Status:        status,
+			AddTime:       time.Now(),
+			QRCodeID:      qrID,
+		}
+		_, err := h.DB.CreateOrderOrGetExisting(order)
+		return order.ID, err
+	}
+	expect := func(amount, want string) {
+		t.Helper()
+
+		qr, err := selector.SelectQRCode(yuan(amount))
+		switch {
+		case want == "" && !errors.Is(err, service.ErrQRCodeQuotaReached):
+			t.Fatalf("select %s = %v, %v, want ErrQRCodeQuotaReached", amount, qr, err)
+		case want != "" && (err != nil || qr.ID != want):
+			t.Fatalf("select %s = %v, %v, want %s", amount, qr, err, want)
+		}
+	}
+
+	// qr_a 当天已有2笔订单，达到订单数限额
+	var quotaA []string
+	for _, status := range []int{model.OrderStatusPending, model.OrderStatusPaid} {
+		id, err := assign("qr_a", "1.00", status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		quotaA = append(quotaA, id)
+	}
+	for i := 0; i < 3; i++ {
+		expect("5.00", "qr_b")
+	}
+
+	// qr_b 已收6元，5元订单超出10元限额，4元订单仍可分配
+	paidB, err := assign("qr_b", "6.00", model.OrderStatusPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("5.00", "")
+	expect("4.00", "qr_b")
+
+	// 过期订单不计入限额
+	if _, err := h.DB.Exec(`UPDATE codepay_orders SET status = ? WHERE id = ?`, model.OrderStatusExpired, quotaA[0]); err != nil {
+		t.Fatal(err)
+	}
+	expect("5.00", "qr_a")
+
+	// 前一天的订单不计入限额
+	if _, err := h.DB.Exec(`UPDATE codepay_orders SET add_time = ? WHERE id = ?`, time.Now().AddDate(0, 0, -1), paidB); err != nil {
+		t.Fatal(err)
+	}
+	expect("5.00", "qr_b")
+
+	today, err := selector.TodayUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := today["qr_a"]; u == nil || u.Count != 1 || u.Amount != yuan("1.00") {
+		t.Fatalf("qr_a usage today = %+v, want 1 order, 1.00", u)
+	}
+}
+
+// TestQRHealth 专属接口连续失败或长时间无支付的收款码不再分配并告警；接口恢复或手动恢复后重新分配
+func TestQRHealth(t *testing.T) {
+	h := startHarness(t)
+
+	var mu sync.Mutex
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		title, _ := body["title"].(string)
+		mu.Lock()
+		alerts = append(alerts, title)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	channel, err := notifier.New("webhook", notifier.Options{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitAlert := func(title string) error {
+		return WaitFor(5*time.Second, func() (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, alert := range alerts {
+				if alert == title {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+	}
+
+	h.Config.Payment.BusinessQRMode.PollingMode = "round_robin"
+	h.Config.Payment.BusinessQRMode.QRCodePaths = []config.QRCode{
+		{ID: "qr_a", Enabled: true, Priority: 1},
+		{ID: "qr_b", Enabled: true, Priority: 2},
+	}
+	selector := service.NewQRCodeSelector(h.Config, h.DB)
+	checker := service.NewQRCodeHealthChecker(&config.QRCodeHealthConfig{
+		Interval:         300,
+		FailureThreshold: 3,
+		NoPaymentHours:   6,
+		MinOrders:        3,
+	}, h.DB, selector, 5*time.Minute)
+	checker.SetNotifier(notifier.NewNotifier(channel))
+	selector.SetHealthChecker(checker)
+
+	expect := func(want ...string) {
+		t.Helper()
+
+		for _, id := range want {
+			qr, err := selector.SelectQRCode(1)
+			if err != nil || qr.ID != id {
+				t.Fatalf("select = %v, %v, want %s", qr, err, id)
+			}
+		}
+	}
+
+	// 专属接口连续失败3次后不再分配 qr_a，查询成功后恢复
+	for i := 0; i < 3; i++ {
+		checker.RecordQuery("qr_a", errors.New("isv.invalid-signature"))
+	}
+	if checker.IsHealthy("qr_a") {
+		t.Fatalf("qr_a healthy after 3 failures")
+	}
+	expect("qr_b", "qr_b", "qr_b")
+	if err := waitAlert("收款码异常：qr_a"); err != nil {
+		t.Fatalf("no unhealthy alert for qr_a: %v", err)
+	}
+	checker.RecordQuery("qr_a", nil)
+	if !checker.IsHealthy("qr_a") {
+		t.Fatalf("qr_a still unhealthy after a successful query")
+	}
+	if err := waitAlert("收款码已恢复：qr_a"); err != nil {
+		t.Fatalf("no recovery alert for qr_a: %v", err)
+	}
+
+	// qr_b 最近分配的3笔已超时订单都未支付；未超时的订单不计入
+	for i, age := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, time.Minute} {
+		order := &model.Order{
+			ID:         fmt.Sprintf("E2EQRHEALTH%d", i),
+			OutTradeNo: fmt.Sprintf("E2E-QRHEALTH-%d", i),
+			Type:       model.PaymentTypeAlipay,
+			PID:        MerchantID,
+			Name:       "qr health",
+			Status:     model.OrderStatusExpired,
+			AddTime:    time.Now().Add(-age),
+			QRCodeID:   "qr_b",
+		}
+		if _, err := h.DB.CreateOrderOrGetExisting(order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checker.Check()
+	if checker.IsHealthy("qr_b") {
+		t.Fatalf("qr_b healthy after 3 unpaid orders")
+	}
+	expect("qr_a", "qr_a")
+
+	// 全部不健康时仍然分配，避免无法下单
+	for i := 0; i < 3; i++ {
+		checker.RecordQuery("qr_a", errors.New("timeout"))
+	}
+	if qr, err := selector.SelectQRCode(1); err != nil || qr == nil {
+		t.Fatalf("select with all QR codes unhealthy = %v, %v, want a QR code", qr, err)
+	}
+
+	adminHandler := handler.NewAdminHandler(h.DB, h.CodePay, h.Config)
+	adminHandler.SetQRCodeHealthChecker(checker)
+	router := gin.New()
+	router.GET("/admin/qrcodes/health", adminHandler.HandleQRCodeHealth)
+	router.POST("/admin/qrcodes/health/reset", adminHandler.HandleResetQRCodeHealth)
+	admin := httptest.NewServer(router)
+	defer admin.Close()
+
+	reset := func(id string) (int, error) {
+		resp, err := http.Post(admin.URL+"/admin/qrcodes/health/reset", "application/json", strings.NewReader(`{"id":"`+id+`"}`))
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	if status, err := reset("qr_b"); err != nil || status != http.StatusOK {
+		t.Fatalf("reset qr_b = %d, %v, want 200", status, err)
+	}
+	if status, err := reset("qr_b"); err != nil || status != http.StatusConflict {
+		t.Fatalf("reset healthy qr_b = %d, %v, want 409", status, err)
+	}
+
+	// 恢复前分配的订单不再计入
+	checker.Check()
+	if !checker.IsHealthy("qr_b") {
+		t.Fatalf("qr_b marked unhealthy again by orders assigned before reset")
+	}
+
+	resp, err := http.Get(admin.URL + "/admin/qrcodes/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Unhealthy int                   `json:"unhealthy"`
+		QRCodes   []*model.QRCodeHealth `json:"qrcodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Unhealthy != 1 || len(out.QRCodes) != 2 || out.QRCodes[0].ID != "qr_a" ||
+		out.QRCodes[0].Reason != model.QRCodeUnhealthyAPIFailures || out.QRCodes[0].ConsecutiveFailures != 3 {
+		t.Fatalf("health = %+v, want qr_a unhealthy with 3 API failures", out)
+	}
+}
+
+// TestQRAmountRange 金额区间：订单分配给区间匹配的二维码；没有匹配的（含匹配的二维码达到限额）时在全部可用的二维码中选择
+func TestQRAmountRange(t *testing.T) {
+	h := startHarness(t)
+
+	yuan := func(s string) model.Amount {
+		amount, _ := model.ParseAmount(s)
+		return amount
+	}
+	h.Config.Payment.BusinessQRMode.PollingMode = "round_robin"
+	h.Config.Payment.BusinessQRMode.QRCodePaths = []config.QRCode{
+		{ID: "qr_small", Enabled: true, Priority: 1, MaxAmount: yuan("99.99")},
+		{ID: "qr_large", Enabled: true, Priority: 2, MinAmount: yuan("100.00"), MaxAmount: yuan("5000.00"), MaxDailyCount: 1},
+	}
+	selector := service.NewQRCodeSelector(h.Config, h.DB)
+
+	selected := func(amount string) (map[string]int, error) {
+		counts := make(map[string]int)
+		for i := 0; i < 4; i++ {
+			qr, err := selector.SelectQRCode(yuan(amount))
+			if err != nil {
+				return nil, fmt.Errorf("select %s: %w", amount, err)
+			}
+			counts[qr.ID]++
+		}
+		return counts, nil
+	}
+	expect := func(amount string, want ...string) {
+		t.Helper()
+
+		counts, err := selected(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(counts) != len(want) {
+			t.Fatalf("order of %s assigned to %v, want only %v", amount, counts, want)
+		}
+		for _, id := range want {
+			if counts[id] == 0 {
+				t.Fatalf("order of %s assigned to %v, want %v", amount, counts, want)
+			}
+		}
+	}
+
+	expect("10.00", "qr_small")
+	expect("99.99", "qr_small")
+	expect("100.00", "qr_large")
+	// 超出全部区间时轮换全部二维码
+	expect("8000.00", "qr_small", "qr_large")
+
+	// 匹配的二维码达到每日限额后，大额订单分配给其他二维码
+	order := &model.Order{
+		ID:            "E2ERANGE1",
+		OutTradeNo:    "E2E-RANGE-1",
+		Type:          model.PaymentTypeAlipay,
+		PID:           MerchantID,
+		Name:          "range",
+		Price:         yuan("200.00"),
+		PaymentAmount: yuan("200.00"),
+		Status:        model.OrderStatusPending,
+		AddTime:       time.Now(),
+		QRCodeID:      "qr_large",
+	}
+	if _, err := h.DB.CreateOrderOrGetExisting(order); err != nil {
+		t.Fatal(err)
+	}
+	expect("200.00", "qr_small")
+
+	// 区间包含金额的二维码优先于未设置区间的二维码，未设置区间的二维码优先于区间不匹配的二维码
+	h.Config.Payment.BusinessQRMode.QRCodePaths = []config.QRCode{
+		{ID: "qr_default", Enabled: true, Priority: 1},
+		{ID: "qr_vip", Enabled: true, Priority: 2, MinAmount: yuan("1000.00")},
+	}
+	selector = service.NewQRCodeSelector(h.Config, h.DB)
+	expect("1500.00", "qr_vip")
+	expect("10.00", "qr_default")
+}
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/pkg/listen"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// TestServerListeners 同一服务同时监听TCP地址和Unix socket；Unix socket 连接视为本机，可信代理传递的客户端IP被采信
+func TestServerListeners(t *testing.T) {
+	h := startHarness(t)
+
+	legacy := h.Config.Server.ListenAddresses()
+	if len(legacy) != 1 || legacy[0].Address != net.JoinHostPort(h.Config.Server.Host, strconv.Itoa(h.Config.Server.Port)) {
+		t.Fatalf("listen addresses without server.listeners = %+v, want host:port", legacy)
+	}
+
+	// 上次未正常退出遗留的 socket 文件
+	socket := filepath.Join(h.Dir, "alimpay.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	unixListener, err := listen.Open(listen.UnixPrefix+socket, 0600)
+	if err != nil {
+		t.Fatalf("listen on stale socket: %v", err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("socket mode = %v, want 0600", info.Mode().Perm())
+	}
+	if ln, err := listen.Open(listen.UnixPrefix+socket, 0600); err == nil {
+		ln.Close()
+		t.Fatalf("second listener on a socket in use succeeded")
+	}
+
+	tcpListener, err := listen.Open("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners := []net.Listener{unixListener, tcpListener}
+	// IPv6 地址只监听IPv6，可与同端口的IPv4地址同时监听（环境不支持IPv6时跳过）
+	_, port, _ := net.SplitHostPort(tcpListener.Addr().String())
+	if ln, err := listen.Open("[::1]:"+port, 0); err == nil {
+		listeners = append(listeners, ln)
+	} else if !strings.Contains(err.Error(), "cannot assign requested address") && !strings.Contains(err.Error(), "address family not supported") {
+		t.Fatalf("listen on [::1]:%s alongside 127.0.0.1:%s: %v", port, port, err)
+	}
+
+	router := gin.New()
+	if err := router.SetTrustedProxies([]string{"127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	server := &http.Server{Handler: router}
+	for _, ln := range listeners {
+		go server.Serve(ln)
+	}
+
+	get := func(client *http.Client, url string) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-Real-IP", "203.0.113.9")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	for _, l := range listeners {
+		client, url := http.DefaultClient, "http://"+l.Addr().String()+"/ip"
+		if l == unixListener {
+			client, url = unixClient, "http://unix/ip"
+		}
+		ip, err := get(client, url)
+		if err != nil {
+			t.Fatalf("request via %s: %v", l.Addr(), err)
+		}
+		if l == unixListener && ip != "203.0.113.9" {
+			t.Fatalf("client ip via unix socket = %q, want X-Real-IP from local proxy", ip)
+		}
+		if ip == "" {
+			t.Fatalf("empty client ip via %s", l.Addr())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("socket file still exists after shutdown: %v", err)
+	}
+}
+
+// TestGracefulUpgrade 平滑升级：旧进程关闭监听后停止接受新连接；
+// 支付页面WebSocket收到服务重启（1012）关闭帧
+func TestGracefulUpgrade(t *testing.T) {
+	h := startHarness(t)
+
+	order, err := h.CreateOrder("E2E-UPGRADE-1", "1.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wsHandler := handler.NewWebSocketHandler(h.DB, config.WebSocketConfig{PingInterval: 1, ReadTimeout: 2})
+	router := gin.New()
+	router.GET("/ws/order", wsHandler.HandleWebSocket)
+
+	upgrader := listen.NewUpgrader()
+	if upgrader.Inherited() {
+		t.Fatalf("upgrader reports inherited listeners without a previous process")
+	}
+	socket := filepath.Join(h.Dir, "upgrade.sock")
+	ln, err := upgrader.Listen(listen.UnixPrefix+socket, listen.DefaultSocketMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: router}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ln) }()
+
+	dialer := websocket.Dialer{NetDial: func(network, addr string) (net.Conn, error) {
+		return net.Dial("unix", socket)
+	}}
+	// 两个客户端：conn 收到 reconnect 后主动断开，straggler 不处理（旧版页面）
+	var conns []*websocket.Conn
+	for i := 0; i < 2; i++ {
+		c, _, err := dialer.Dial("ws://alimpay/ws/order?order_id="+url.QueryEscape(order.TradeNo), nil)
+		if err != nil {
+			upgrader.Close()
+			t.Fatalf("websocket over unix socket: %v", err)
+		}
+		defer c.Close()
+		var message handler.OrderStatusMessage
+		if err := c.ReadJSON(&message); err != nil {
+			upgrader.Close()
+			t.Fatalf("initial status: %v", err)
+		}
+		conns = append(conns, c)
+	}
+	conn, straggler := conns[0], conns[1]
+
+	// 停止接受新连接：Serve 返回监听已关闭
+	upgrader.Close()
+	select {
+	case err := <-served:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("serve returned %v after closing listeners, want net.ErrClosed", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("serve did not return after closing listeners")
+	}
+	if c, err := net.Dial("unix", socket); err == nil {
+		c.Close()
+		t.Fatalf("closed listener still accepts connections")
+	}
+
+	// 通知客户端重连到新进程，连接不会被服务端关闭
+	wsHandler.Reconnect()
+	for _, c := range conns {
+		var message map[string]interface{}
+		c.SetReadDeadline(time.Now().Add(waitTimeout))
+		if err := c.ReadJSON(&message); err != nil {
+			t.Fatalf("read reconnect message: %v", err)
+		}
+		if message["type"] != "reconnect" {
+			t.Fatalf("got %v, want reconnect message", message)
+		}
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	// 等待期限（短于心跳超时）到达时只剩未处理 reconnect 的连接，之后收到关闭帧
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	remaining := wsHandler.Drain(ctx)
+	cancel()
+	if remaining != 1 {
+		t.Fatalf("%d websocket connections remain after drain, want 1", remaining)
+	}
+	wsHandler.CloseAll()
+	straggler.SetReadDeadline(time.Now().Add(waitTimeout))
+	for {
+		if _, _, err = straggler.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+		t.Fatalf("websocket closed with %v, want close code 1012", err)
+	}
+}
@@ -0,0 +1,342 @@
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"alimpay-go/internal/config"
+	"alimpay-go/internal/handler"
+	"alimpay-go/internal/model"
+	"alimpay-go/internal/pkg/botfilter"
+	"alimpay-go/internal/pkg/captcha"
+	"alimpay-go/internal/pkg/geoip"
+	"alimpay-go/internal/pkg/utils"
+	"alimpay-go/internal/service"
+	"alimpay-go/internal/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestGeoIPRules 买家IP归属地：记录订单买家IP及归属地，按规则拒绝非中国大陆买家购买指定商品
+func TestGeoIPRules(t *testing.T) {
+	h := startHarness(t)
+
+	geoDB, err := geoip.Parse(strings.NewReader("# e2e\n" +
+		"1.0.1.0,1.0.3.255,CN,福建\n" +
+		"8.8.8.0,8.8.8.255,US\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	geoip.SetDefault(geoDB)
+	defer geoip.SetDefault(nil)
+
+	h.Config.GeoIP.Rules = []config.GeoIPRule{{
+		Name:             "cn-only",
+		NameKeywords:     []string{"CN-ONLY"},
+		AllowedCountries: []string{"cn"},
+		BlockUnknown:     true,
+	}}
+
+	order, err := h.createOrder("E2E-GEO-CN-ONLY-1", "6.60", map[string]string{"clientip": "1.0.2.3"})
+	if err != nil {
+		t.Fatalf("create order from allowed region: %v", err)
+	}
+	client, err := h.DB.GetOrderClient(order.TradeNo)
+	if err != nil || client == nil || client.IP != "1.0.2.3" || client.Country != "CN" || client.Province != "福建" {
+		t.Fatalf("order client = %+v, err %v, want 1.0.2.3 CN 福建", client, err)
+	}
+
+	for _, ip := range []string{"8.8.8.8", "203.0.113.1"} {
+		_, err := h.createOrder("E2E-GEO-CN-ONLY-"+ip, "6.60", map[string]string{"clientip": ip})
+		if err == nil || !strings.Contains(err.Error(), service.ErrBuyerRegionBlocked.Error()) {
+			t.Fatalf("create order from %s: err = %v, want %v", ip, err, service.ErrBuyerRegionBlocked)
+		}
+	}
+
+	// 规则只适用于匹配关键词的商品，局域网地址和未传买家IP的订单不受限制
+	if _, err := h.createOrder("E2E-GEO-OTHER", "6.70", map[string]string{"clientip": "8.8.8.8"}); err != nil {
+		t.Fatalf("create order of other goods: %v", err)
+	}
+	if _, err := h.createOrder("E2E-GEO-CN-ONLY-LAN", "6.80", map[string]string{"clientip": "192.168.1.10"}); err != nil {
+		t.Fatalf("create order from lan: %v", err)
+	}
+	unknown, err := h.createOrder("E2E-GEO-CN-ONLY-NOIP", "6.90", nil)
+	if err != nil {
+		t.Fatalf("create order without client ip: %v", err)
+	}
+	if client, err := h.DB.GetOrderClient(unknown.TradeNo); err != nil || client != nil {
+		t.Fatalf("order client without client ip = %+v, err %v, want none", client, err)
+	}
+}
+
+// 验证页面中的滑块题目ID和缺口位置
+var (
+	captchaIDPattern    = regexp.MustCompile(`name="captcha_id" value="([0-9a-f]+)"`)
+	captchaImagePattern = regexp.MustCompile(`src="(data:image/svg[^"]+)"`)
+	captchaGapPattern   = regexp.MustCompile(`<rect x="(\d+)" y="\d+" width="44"`)
+)
+
+// TestSubmitCaptcha 下单人机验证：命中风险网段的 /submit 请求先渲染滑块验证页面，验证通过后才创建订单；
+// 同一IP下单达到频率上限后同样要求验证
+func TestSubmitCaptcha(t *testing.T) {
+	h := startHarness(t)
+
+	h.Config.SubmitCaptcha = config.SubmitCaptchaConfig{
+		Enabled:        true,
+		Provider:       "slider",
+		VelocityWindow: 600,
+		RiskyRanges:    []string{"127.0.0.0/8"},
+	}
+
+	tmpl, _, err := web.ParseTemplates("", web.Branding{SiteName: "e2e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := captcha.New("slider", captcha.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitHandler := handler.NewSubmitHandler(h.CodePay, h.Config, nil)
+	submitHandler.SetCaptcha(provider)
+	router := gin.New()
+	router.SetHTMLTemplate(tmpl)
+	router.POST("/submit", submitHandler.HandleSubmit)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	params := map[string]string{
+		"pid":          MerchantID,
+		"type":         model.PaymentTypeAlipay,
+		"out_trade_no": "E2E-CAPTCHA-1",
+		"notify_url":   h.Notify.URL(),
+		"return_url":   h.Notify.URL(),
+		"name":         "E2E captcha",
+		"money":        "7.10",
+		"price":        "7.10",
+	}
+	params["sign"] = utils.GenerateSign(params, MerchantKey)
+
+	submit := func(extra map[string]string) (string, error) {
+		form := url.Values{}
+		for k, v := range params {
+			form.Set(k, v)
+		}
+		for k, v := range extra {
+			form.Set(k, v)
+		}
+		resp, err := http.PostForm(server.URL+"/submit", form)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		return body.String(), err
+	}
+	// challenge 提交下单请求，返回验证页面中的题目ID和缺口位置（未创建订单）
+	challenge := func(extra map[string]string) (string, int, error) {
+		page, err := submit(extra)
+		if err != nil {
+			return "", 0, err
+		}
+		id, image := captchaIDPattern.FindStringSubmatch(page), captchaImagePattern.FindStringSubmatch(page)
+		if id == nil || image == nil {
+			return "", 0, fmt.Errorf("submit did not render captcha page: %.200s", page)
+		}
+		svg, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(html.UnescapeString(image[1]), "data:image/svg+xml;base64,"))
+		if err != nil {
+			return "", 0, err
+		}
+		gap := captchaGapPattern.FindStringSubmatch(string(svg))
+		if gap == nil {
+			return "", 0, errors.New("captcha image has no gap")
+		}
+		offset, _ := strconv.Atoi(gap[1])
+		if order, err := h.DB.GetOrderByOutTradeNo(params["out_trade_no"], MerchantID); err != nil || order != nil {
+			return "", 0, fmt.Errorf("order created before captcha passed: %+v, err %v", order, err)
+		}
+		return id[1], offset, nil
+	}
+
+	id, offset, err := challenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 缺口位置不对时重新出题
+	if id, offset, err = challenge(map[string]string{
+		captcha.SliderFieldID:     id,
+		captcha.SliderFieldOffset: strconv.Itoa(offset + 30),
+	}); err != nil {
+		t.Fatalf("wrong offset: %v", err)
+	}
+
+	// 验证通过后创建订单（验证字段不参与签名）
+	if _, err := submit(map[string]string{
+		captcha.SliderFieldID:     id,
+		captcha.SliderFieldOffset: strconv.Itoa(offset + 2),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := h.DB.GetOrderByOutTradeNo(params["out_trade_no"], MerchantID)
+	if err != nil || order == nil {
+		t.Fatalf("order after captcha passed = %+v, err %v, want created", order, err)
+	}
+
+	// 题目只能使用一次
+	if passed, _ := provider.Verify("127.0.0.1", map[string]string{
+		captcha.SliderFieldID:     id,
+		captcha.SliderFieldOffset: strconv.Itoa(offset),
+	}); passed {
+		t.Fatal("captcha challenge accepted twice")
+	}
+
+	// 下单频率：127.0.0.1 已有1笔订单
+	h.Config.SubmitCaptcha.RiskyRanges = nil
+	h.Config.SubmitCaptcha.VelocityLimit = 2
+	if reason := h.CodePay.SubmitRiskReason("127.0.0.1"); reason != "" {
+		t.Fatalf("risk reason below velocity limit = %q, want none", reason)
+	}
+	h.Config.SubmitCaptcha.VelocityLimit = 1
+	if reason := h.CodePay.SubmitRiskReason("127.0.0.1"); reason != service.SubmitRiskVelocity {
+		t.Fatalf("risk reason = %q, want %q", reason, service.SubmitRiskVelocity)
+	}
+	if reason := h.CodePay.SubmitRiskReason("198.51.100.9"); reason != "" {
+		t.Fatalf("risk reason of other ip = %q, want none", reason)
+	}
+}
+
+// botTokenPattern 机器人过滤检查页面中的题目
+var botTokenPattern = regexp.MustCompile(`name="bf_token" value="([^"]+)"`)
+
+// TestSubmitBotFilter /submit 先返回检查页面，完成工作量证明后才创建订单；蜜罐字段被填写、答案错误或题目重复使用时不创建订单
+func TestSubmitBotFilter(t *testing.T) {
+	h := startHarness(t)
+
+	const difficulty = 8
+
+	tmpl, _, err := web.ParseTemplates("", web.Branding{SiteName: "e2e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitHandler := handler.NewSubmitHandler(h.CodePay, h.Config, nil)
+	submitHandler.SetBotFilter(botfilter.New("", difficulty))
+	router := gin.New()
+	router.SetHTMLTemplate(tmpl)
+	router.POST("/submit", submitHandler.HandleSubmit)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	submit := func(outTradeNo string, extra map[string]string) (string, error) {
+		params := map[string]string{
+			"pid":          MerchantID,
+			"type":         model.PaymentTypeAlipay,
+			"out_trade_no": outTradeNo,
+			"notify_url":   h.Notify.URL(),
+			"return_url":   h.Notify.URL(),
+			"name":         "E2E bot filter",
+			"money":        "7.30",
+			"price":        "7.30",
+		}
+		params["sign"] = utils.GenerateSign(params, MerchantKey)
+		form := url.Values{}
+		for k, v := range params {
+			form.Set(k, v)
+		}
+		for k, v := range extra {
+			form.Set(k, v)
+		}
+		resp, err := http.PostForm(server.URL+"/submit", form)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		return body.String(), err
+	}
+	// challenge 提交不含过滤字段的下单请求，返回检查页面中的题目（未创建订单）
+	challenge := func(outTradeNo string) (string, error) {
+		page, err := submit(outTradeNo, nil)
+		if err != nil {
+			return "", err
+		}
+		token := botTokenPattern.FindStringSubmatch(page)
+		if token == nil {
+			return "", fmt.Errorf("submit did not render bot check page: %.200s", page)
+		}
+		return html.UnescapeString(token[1]), nil
+	}
+	solve := func(token string, want bool) string {
+		for nonce := 0; ; nonce++ {
+			if botfilter.Solves(token, strconv.Itoa(nonce), difficulty) == want {
+				return strconv.Itoa(nonce)
+			}
+		}
+	}
+	noOrder := func(outTradeNo string) {
+		t.Helper()
+
+		if order, err := h.DB.GetOrderByOutTradeNo(outTradeNo, MerchantID); err != nil || order != nil {
+			t.Fatalf("order %s created without passing bot filter: %+v, err %v", outTradeNo, order, err)
+		}
+	}
+
+	// 蜜罐字段被填写时直接拒绝（即使答案正确）
+	token, err := challenge("E2E-BOT-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := submit("E2E-BOT-1", map[string]string{
+		botfilter.FieldToken:    token,
+		botfilter.FieldNonce:    solve(token, true),
+		botfilter.FieldHoneypot: "http://spam.example",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if botTokenPattern.MatchString(page) {
+		t.Fatalf("honeypot submission got another challenge instead of rejection")
+	}
+	noOrder("E2E-BOT-1")
+
+	// 答案错误时重新显示检查页面
+	token, err = challenge("E2E-BOT-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err = submit("E2E-BOT-2", map[string]string{botfilter.FieldToken: token, botfilter.FieldNonce: solve(token, false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !botTokenPattern.MatchString(page) {
+		t.Fatalf("wrong proof-of-work did not render bot check page again")
+	}
+	noOrder("E2E-BOT-2")
+
+	// 答案正确时创建订单，同一题目不能再次使用
+	token, err = challenge("E2E-BOT-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solved := map[string]string{botfilter.FieldToken: token, botfilter.FieldNonce: solve(token, true), botfilter.FieldHoneypot: ""}
+	if _, err := submit("E2E-BOT-3", solved); err != nil {
+		t.Fatal(err)
+	}
+	if order, err := h.DB.GetOrderByOutTradeNo("E2E-BOT-3", MerchantID); err != nil || order == nil {
+		t.Fatalf("order not created after passing bot filter: err %v", err)
+	}
+	if _, err := submit("E2E-BOT-4", solved); err != nil {
+		t.Fatal(err)
+	}
+	noOrder("E2E-BOT-4")
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 管理操作
+const (
+	AdminActionPay      = "pay"
+	AdminActionMarkPaid = "mark_paid" // pay 的别名
+	AdminActionCancel   = "cancel"
+	AdminActionRefund   = "refund"
+)
+
+// adminActions 支持的管理操作
+var adminActions = map[string]bool{
+	AdminActionPay:      true,
+	AdminActionMarkPaid: true,
+	AdminActionCancel:   true,
+	AdminActionRefund:   true,
+}
+
+// orderNoPattern 系统订单号、商户订单号允许的字符
+var orderNoPattern = regexp.MustCompile("^[a-zA-Z0-9_-]{1,64}$")
+
+// AdminActionRequest 管理操作请求
+type AdminActionRequest struct {
+	Action     string `json:"action"`
+	TradeNo    string `json:"trade_no"`
+	OutTradeNo string `json:"out_trade_no"`
+}
+
+// ParseAdminActionRequest 严格解析并校验管理操作请求，错误为 *ValidationError
+func ParseAdminActionRequest(r io.Reader) (*AdminActionRequest, error) {
+	var req AdminActionRequest
+	if err := DecodeStrictJSON(r, &req); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Validate 校验操作类型和订单标识（标记已支付可使用商户订单号，取消和退款需要系统订单号）
+func (r *AdminActionRequest) Validate() error {
+	verr := &ValidationError{}
+
+	switch {
+	case r.Action == "":
+		verr.Add("action", "is required")
+	case !adminActions[r.Action]:
+		verr.Add("action", "must be one of: "+strings.Join(AdminActions(), ", "))
+	}
+
+	if r.TradeNo != "" && !orderNoPattern.MatchString(r.TradeNo) {
+		verr.Add("trade_no", "must be 1-64 letters, digits, '_' or '-'")
+	}
+	if r.OutTradeNo != "" && !orderNoPattern.MatchString(r.OutTradeNo) {
+		verr.Add("out_trade_no", "must be 1-64 letters, digits, '_' or '-'")
+	}
+
+	switch r.Action {
+	case AdminActionPay, AdminActionMarkPaid:
+		if r.TradeNo == "" && r.OutTradeNo == "" {
+			verr.Add("trade_no", "trade_no or out_trade_no is required")
+		}
+	case AdminActionCancel, AdminActionRefund:
+		if r.TradeNo == "" {
+			verr.Add("trade_no", "is required")
+		}
+		if r.OutTradeNo != "" {
+			verr.Add("out_trade_no", "is not supported for "+r.Action+", use trade_no")
+		}
+	}
+
+	return verr.Err()
+}
+
+// AdminActions 支持的管理操作（排序后）
+func AdminActions() []string {
+	actions := make([]string, 0, len(adminActions))
+	for action := range adminActions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
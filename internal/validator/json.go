@@ -0,0 +1,88 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field,omitempty"` // 字段名，请求体整体错误时为空
+	Message string `json:"message"`
+}
+
+// ValidationError 请求校验错误，Errors 可直接作为响应的 details 返回
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		if fe.Field == "" {
+			parts = append(parts, fe.Message)
+		} else {
+			parts = append(parts, fe.Field+": "+fe.Message)
+		}
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// Add 添加一个字段错误
+func (e *ValidationError) Add(field, message string) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: message})
+}
+
+// Err 没有字段错误时返回 nil
+func (e *ValidationError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// DecodeStrictJSON 严格解析JSON请求体：拒绝未知字段、类型不符的字段和JSON对象之后的多余内容
+// 解析失败时返回 *ValidationError
+func DecodeStrictJSON(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return &ValidationError{Errors: []FieldError{{Message: "unexpected data after JSON object"}}}
+	}
+	return nil
+}
+
+// decodeError 将 encoding/json 的错误转换为字段错误
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	fe := FieldError{}
+
+	switch {
+	case errors.Is(err, io.EOF):
+		fe.Message = "request body is required"
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		fe.Field = typeErr.Field
+		fe.Message = fmt.Sprintf("must be %s, got %s", typeErr.Type.Kind(), typeErr.Value)
+	case errors.As(err, &typeErr):
+		fe.Message = "request body must be a JSON object"
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		fe.Message = "malformed JSON"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json 未导出未知字段错误类型，只能从错误信息中提取字段名
+		fe.Field = strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		fe.Message = "unknown field"
+	default:
+		fe.Message = err.Error()
+	}
+
+	return &ValidationError{Errors: []FieldError{fe}}
+}
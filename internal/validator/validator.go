@@ -3,8 +3,9 @@ package validator
 import (
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
+
+	"alimpay-go/internal/pkg/money"
 )
 
 // ValidateOrderParams 验证订单参数
@@ -83,33 +84,10 @@ func ValidateOutTradeNo(outTradeNo string) error {
 	return nil
 }
 
-// ValidateMoney 验证金额
-func ValidateMoney(money string) error {
-	// 验证金额格式（允许负数用于格式检测，但后续会拒绝）
-	matched, _ := regexp.MatchString(`^-?\d+(\.\d{1,2})?$`, money)
-	if !matched {
-		return fmt.Errorf("invalid money format")
-	}
-
-	// 转换并严格验证金额
-	amount, err := strconv.ParseFloat(money, 64)
-	if err != nil {
-		return fmt.Errorf("invalid money value")
-	}
-
-	if amount <= 0 {
-		return fmt.Errorf("money must be greater than 0 (0 yuan purchase not allowed)")
-	}
-
-	if amount < 0.01 {
-		return fmt.Errorf("money must be at least 0.01 yuan")
-	}
-
-	if amount > 99999.99 {
-		return fmt.Errorf("money exceeds maximum limit (99999.99)")
-	}
-
-	return nil
+// ValidateMoney 验证金额（严格格式，大于0且不超过单笔上限）
+func ValidateMoney(value string) error {
+	_, err := money.ParseOrderAmount(value)
+	return err
 }
 
 // ValidatePaymentType 验证支付类型
@@ -154,9 +132,10 @@ func SanitizeString(s string) string {
 // ValidateSignType 验证签名类型
 func ValidateSignType(signType string) error {
 	validTypes := map[string]bool{
-		"MD5":  true,
-		"RSA":  true,
-		"RSA2": true,
+		"MD5":         true,
+		"HMAC-SHA256": true,
+		"RSA":         true,
+		"RSA2":        true,
 	}
 
 	if !validTypes[signType] {
@@ -0,0 +1,46 @@
+package validator
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"alimpay-go/internal/model"
+)
+
+// FuzzValidateOrderParams 通过校验的参数必须满足下单的前提（金额范围、订单号长度、地址协议）
+func FuzzValidateOrderParams(f *testing.F) {
+	f.Add("pid=1001&type=alipay&out_trade_no=A1&name=test&money=1.00&notify_url=http://a.com/n&return_url=https://a.com/r")
+	f.Add("pid=1001&type=wxpay&out_trade_no=A_B-2&name=x&money=99999.99")
+	f.Add("pid=1001&type=alipay&out_trade_no=A1&name=test&money=-0.01")
+
+	f.Fuzz(func(t *testing.T, query string) {
+		values, _ := url.ParseQuery(query)
+		params := make(map[string]string, len(values))
+		for k := range values {
+			params[k] = values.Get(k)
+		}
+		if err := ValidateOrderParams(params); err != nil {
+			return
+		}
+
+		amount, err := model.ParseAmount(params["money"])
+		if err != nil {
+			t.Fatalf("accepted unparsable money %q: %v", params["money"], err)
+		}
+		if amount <= 0 || amount > model.MaxOrderAmount {
+			t.Fatalf("accepted money out of range: %q", params["money"])
+		}
+		if roundTrip, err := model.ParseAmount(amount.String()); err != nil || roundTrip != amount {
+			t.Fatalf("money %q does not round-trip: %s", params["money"], amount)
+		}
+		if n := len(params["out_trade_no"]); n == 0 || n > 64 {
+			t.Fatalf("accepted out_trade_no of length %d", n)
+		}
+		for _, key := range []string{"notify_url", "return_url"} {
+			if u := params[key]; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				t.Fatalf("accepted %s %q", key, u)
+			}
+		}
+	})
+}
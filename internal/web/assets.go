@@ -0,0 +1,123 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"alimpay-go/internal/pkg/i18n"
+)
+
+// StaticURLPrefix 静态资源的URL前缀（与 cmd/main.go 中的 /static 路由组一致）
+const StaticURLPrefix = "/static/"
+
+// assetHashLength 指纹长度（内容SHA-256的前10位十六进制）
+const assetHashLength = 10
+
+// assetManifest 静态资源指纹清单
+// @description 嵌入的静态文件在编译时即已确定，指纹由文件内容计算，升级后内容变化的文件会得到新文件名，
+// 因此带指纹的地址可以安全地长期缓存
+type assetManifest struct {
+	fingerprinted map[string]string // 原始路径 → 带指纹路径，如 css/admin.css → css/admin.3f2a1b4c5d.css
+	original      map[string]string // 带指纹路径 → 原始路径
+}
+
+var (
+	manifestOnce sync.Once
+	manifest     *assetManifest
+)
+
+// loadManifest 计算所有嵌入静态文件的指纹（仅计算一次）
+func loadManifest() *assetManifest {
+	manifestOnce.Do(func() {
+		manifest = &assetManifest{
+			fingerprinted: make(map[string]string),
+			original:      make(map[string]string),
+		}
+
+		staticFS, err := GetStaticFS()
+		if err != nil {
+			return
+		}
+		_ = fs.WalkDir(staticFS, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(staticFS, name)
+			if err != nil {
+				return err
+			}
+			hashed := fingerprintName(name, data)
+			manifest.fingerprinted[name] = hashed
+			manifest.original[hashed] = name
+			return nil
+		})
+	})
+	return manifest
+}
+
+// fingerprintName 在扩展名前插入内容指纹
+func fingerprintName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:assetHashLength]
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// AssetURL 获取静态资源的带指纹URL
+// @param name 相对 static 目录的路径，如 css/admin.css
+// @return string 如 /static/css/admin.3f2a1b4c5d.css；资源不存在时返回原始路径
+func AssetURL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := loadManifest().fingerprinted[name]; ok {
+		return StaticURLPrefix + hashed
+	}
+	return StaticURLPrefix + name
+}
+
+// IsFingerprinted 判断路径是否为带指纹的静态资源
+// @param name 相对 static 目录的路径
+// @return bool 带指纹的路径内容不会变化，可使用长期缓存
+func IsFingerprinted(name string) bool {
+	_, ok := loadManifest().original[strings.TrimPrefix(name, "/")]
+	return ok
+}
+
+// TemplateFuncs 模板函数
+// @description asset：在模板中引用静态资源，如 {{asset "css/admin.css"}}；
+// clock：将秒数格式化为 mm:ss（支付页面倒计时的首屏显示），如 {{clock .View.ExpiresIn}}；
+// brand：品牌信息，默认为空，由 ParseTemplates 按配置替换；
+// t：多语言文本，如 {{t .View.Lang "pay.title"}}，文本见 internal/pkg/i18n
+// @return template.FuncMap 模板函数集合
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"asset": AssetURL,
+		"clock": formatClock,
+		"brand": func() Branding { return Branding{} },
+		"t":     i18n.T,
+	}
+}
+
+// formatClock 将秒数格式化为 mm:ss
+func formatClock(seconds int) string {
+	seconds = max(seconds, 0)
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// fingerprintFS 同时支持原始路径与带指纹路径的静态文件系统
+type fingerprintFS struct {
+	fs.FS
+}
+
+// Open 将带指纹的路径映射回原始文件
+func (f fingerprintFS) Open(name string) (fs.File, error) {
+	if original, ok := loadManifest().original[name]; ok {
+		name = original
+	}
+	return f.FS.Open(name)
+}
@@ -0,0 +1,180 @@
+/*
+ * AliMPay 管理后台订单详情脚本
+ * @version 1.0.0
+ * @description 展示订单信息及生命周期时间线，定位订单停留的阶段
+ */
+
+(function() {
+    'use strict';
+
+    const API = {
+        timeline: '/admin/orders/timeline'
+    };
+
+    // 阶段名称
+    const stageText = {
+        created: '订单创建',
+        qr_assigned: '分配收款码',
+        page_viewed: '打开支付页面',
+        sandbox_paid: '沙箱模拟支付',
+        bill_matched: '账单匹配成功',
+        partial_paid: '收到分笔支付',
+        marked_paid: '确认支付',
+        trade_paid: '支付宝交易成功',
+        paid: '支付完成',
+        notified: '通知商户',
+        returned: '跳转回商户',
+        closed: '订单关闭',
+        expired: '订单过期',
+        refunded: '订单退款',
+        refund_requested: '商户申请退款',
+        refund_approved: '退款申请通过',
+        refund_rejected: '退款申请拒绝',
+        disputed: '标记争议',
+        dispute_resolved: '解除争议',
+        admin_action: '管理操作'
+    };
+
+    // 来源名称
+    const sourceText = {
+        event: '订单事件',
+        notify: '通知记录',
+        audit: '审计日志',
+        order: '订单字段'
+    };
+
+    const statusText = {
+        0: '待支付',
+        1: '已支付',
+        2: '已关闭',
+        3: '已退款',
+        4: '已过期'
+    };
+
+    const utils = {
+        formatTime(timestamp) {
+            if (!timestamp) return '-';
+            return new Date(timestamp).toLocaleString('zh-CN', {
+                year: 'numeric',
+                month: '2-digit',
+                day: '2-digit',
+                hour: '2-digit',
+                minute: '2-digit',
+                second: '2-digit'
+            });
+        },
+
+        formatAmount(amount) {
+            return `¥${parseFloat(amount).toFixed(2)}`;
+        },
+
+        escapeHTML(text) {
+            const div = document.createElement('div');
+            div.textContent = text == null ? '' : String(text);
+            return div.innerHTML;
+        },
+
+        showAlert(message, type = 'error') {
+            const alert = document.getElementById('alert');
+            alert.className = `alert alert-${type}`;
+            alert.textContent = message;
+            alert.style.display = 'block';
+        }
+    };
+
+    // 买家IP及归属地（未记录时显示 -）
+    function formatClientIP(order) {
+        if (!order.client_ip) return '-';
+        const location = [order.ip_country, order.ip_province].filter(Boolean).join(' ');
+        return utils.escapeHTML(location ? `${order.client_ip} (${location})` : order.client_ip);
+    }
+
+    // 渲染订单基本信息
+    function renderOrder(order) {
+        const rows = [
+            ['订单号', `<code>${utils.escapeHTML(order.trade_no)}</code>`],
+            ['商户订单号', utils.escapeHTML(order.out_trade_no || '-')],
+            ['商品名称', utils.escapeHTML(order.name || '-')],
+            ['金额', utils.formatAmount(order.price)],
+            ['实付金额', utils.formatAmount(order.payment_amount || order.price)],
+            ['状态', statusText[order.status] || '未知'],
+            ['收款码', utils.escapeHTML(order.qr_code_id || '-')],
+            ['买家IP', formatClientIP(order)],
+            ['通知地址', utils.escapeHTML(order.notify_url || '-')],
+            ['跳转地址', utils.escapeHTML(order.return_url || '-')],
+            ['创建时间', utils.formatTime(order.add_time)],
+            ['支付时间', utils.formatTime(order.pay_time)],
+            ['支付宝交易号', order.alipay_trade_no ? `<code>${utils.escapeHTML(order.alipay_trade_no)}</code>` : '-']
+        ];
+
+        document.getElementById('orderInfoBody').innerHTML = rows.map(([label, value]) => `
+            <tr>
+                <th style="width: 160px;">${label}</th>
+                <td>${value}</td>
+            </tr>
+        `).join('');
+    }
+
+    // 渲染时间线
+    function renderTimeline(timeline, nextStage) {
+        const tbody = document.getElementById('timelineBody');
+        const hint = document.getElementById('nextStage');
+
+        hint.textContent = nextStage
+            ? `⏳ 订单停留在「${stageText[nextStage] || nextStage}」之前`
+            : '✅ 订单流程已结束';
+
+        if (!timeline || timeline.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="4" class="empty-state">暂无记录</td></tr>';
+            return;
+        }
+
+        tbody.innerHTML = timeline.map(entry => {
+            let mark = '';
+            if (entry.success === true) mark = '✅ ';
+            if (entry.success === false) mark = '❌ ';
+
+            return `
+                <tr>
+                    <td>${utils.formatTime(entry.time)}</td>
+                    <td>${mark}${utils.escapeHTML(stageText[entry.stage] || entry.stage)}</td>
+                    <td>${utils.escapeHTML(sourceText[entry.source] || entry.source)}</td>
+                    <td style="word-break: break-all;">${utils.escapeHTML(entry.detail || '-')}</td>
+                </tr>
+            `;
+        }).join('');
+    }
+
+    async function load() {
+        const tradeNo = new URLSearchParams(window.location.search).get('trade_no');
+        if (!tradeNo) {
+            utils.showAlert('缺少订单号');
+            return;
+        }
+
+        try {
+            const response = await fetch(`${API.timeline}?trade_no=${encodeURIComponent(tradeNo)}`, {
+                credentials: 'include'
+            });
+
+            if (response.status === 401) {
+                window.location.href = '/admin/login';
+                return;
+            }
+
+            const data = await response.json();
+            if (!data.success) {
+                utils.showAlert(data.error || '加载订单失败');
+                return;
+            }
+
+            renderOrder(data.order);
+            renderTimeline(data.timeline, data.next_stage);
+        } catch (error) {
+            console.error('Load timeline error:', error);
+            utils.showAlert('加载订单失败: ' + error.message);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', load);
+})();
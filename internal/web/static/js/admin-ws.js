@@ -172,6 +172,17 @@ const AdminWebSocket = (function() {
         };
     }
     
+    /*
+    立即重连（不计入重连次数）
+    */
+    function reconnectNow() {
+        const previous = ws;
+        previous.onclose = null;
+        ws = null;
+        connect();
+        previous.close();
+    }
+    
     /*
     处理WebSocket消息
     @param data {Object} 消息数据
@@ -180,6 +191,10 @@ const AdminWebSocket = (function() {
         const { type, ...payload } = data;
         
         switch (type) {
+            case 'reconnect':
+                // 服务平滑升级：先连接新进程再关闭旧连接
+                reconnectNow();
+                return;
             case 'order_created':
                 handleOrderCreated(payload);
                 break;
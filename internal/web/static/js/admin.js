@@ -10,6 +10,9 @@
     // 全局状态
     const state = {
         orders: [],
+        page: 1,
+        pageSize: 20,
+        total: 0,
         ws: null,
         stats: {
             pending: 0,
@@ -21,9 +24,41 @@
     // API配置
     const API = {
         orders: '/admin/orders',
+        exportOrders: '/admin/orders/export',
+        createOrder: '/admin/orders/create',
+        printedCodes: '/admin/qrcodes/print',
+        sandbox: '/admin/sandbox',
+        sandboxIssue: '/admin/sandbox/issue',
+        merchantKey: '/admin/merchant/key',
+        merchantKeyRotate: '/admin/merchant/rotate-key',
+        refunds: '/admin/refunds',
+        refundReview: '/admin/refunds/review',
         action: '/admin/action',
+        dispute: '/admin/orders/dispute',
         wsAdmin: '/admin/ws', // 管理后台WebSocket（需要认证）
-        logout: '/admin/logout'
+        logout: '/admin/logout',
+        rotateSessions: '/admin/session/rotate',
+        sessions: '/admin/sessions',
+        revokeSession: '/admin/sessions/revoke',
+        users: '/admin/users',
+        updateUser: '/admin/users/update',
+        deleteUser: '/admin/users/delete',
+        tokens: '/admin/tokens',
+        revokeToken: '/admin/tokens/revoke',
+        twoFactor: '/admin/2fa',
+        twoFactorSetup: '/admin/2fa/setup',
+        twoFactorEnable: '/admin/2fa/enable',
+        twoFactorDisable: '/admin/2fa/disable',
+        twoFactorRecoveryCodes: '/admin/2fa/recovery-codes',
+        notifications: '/admin/notifications',
+        notifyLogs: '/admin/notifications/logs',
+        notifyReplay: '/admin/notifications/replay',
+        notifyHealth: '/admin/notifications/health',
+        qrcodes: '/admin/qrcodes',
+        qrcodeImage: '/admin/qrcodes/image',
+        qrcodeUpload: '/admin/qrcodes/upload',
+        qrcodeUpdate: '/admin/qrcodes/update',
+        crashes: '/admin/crashes'
     };
 
     // 工具函数
@@ -53,7 +88,8 @@
                 0: { text: '待支付', class: 'status-pending' },
                 1: { text: '已支付', class: 'status-paid' },
                 2: { text: '已关闭', class: 'status-closed' },
-                3: { text: '已过期', class: 'status-expired' }
+                3: { text: '已退款', class: 'status-refund' },
+                4: { text: '已过期', class: 'status-expired' }
             };
             return statusMap[status] || { text: '未知', class: '' };
         },
@@ -73,6 +109,345 @@
         // 确认对话框
         confirm(message) {
             return window.confirm(message);
+        },
+
+        // 当前账号是否拥有指定角色的权限（viewer < operator < admin）
+        can(role) {
+            const levels = { viewer: 1, operator: 2, admin: 3 };
+            return (levels[document.body.dataset.role] || 0) >= levels[role];
+        },
+
+        // HTML转义（防止User-Agent等外部数据注入）
+        escapeHTML(text) {
+            const div = document.createElement('div');
+            div.textContent = text == null ? '' : String(text);
+            return div.innerHTML;
+        }
+    };
+
+    // 商户通知概览
+    const notifyManager = {
+        // 当前明细中的通知记录 (id -> log)
+        logs: {},
+
+        // 加载按商户汇总的通知情况
+        async loadSummary() {
+            try {
+                const response = await fetch(API.notifications, {
+                    credentials: 'include'
+                });
+
+                if (!response.ok) {
+                    throw new Error('Failed to load notifications');
+                }
+
+                const data = await response.json();
+
+                if (data.success) {
+                    this.renderSummary(data.merchants || []);
+                }
+            } catch (error) {
+                console.error('Load notifications error:', error);
+            }
+        },
+
+        // 加载通知地址健康状态（只展示异常地址）
+        async loadHealth() {
+            try {
+                const response = await fetch(API.notifyHealth, {
+                    credentials: 'include'
+                });
+
+                const data = await response.json();
+                if (!data.success) return;
+
+                const unhealthy = (data.endpoints || []).filter(e => !e.healthy);
+                const wrapper = document.getElementById('notifyHealthWrapper');
+                if (!wrapper) return;
+
+                if (unhealthy.length === 0) {
+                    wrapper.style.display = 'none';
+                    return;
+                }
+
+                document.getElementById('notifyHealthBody').innerHTML = unhealthy.map(e => `
+                    <tr>
+                        <td>${utils.escapeHTML(e.url)}</td>
+                        <td>${utils.escapeHTML(e.pid)}</td>
+                        <td>${e.status_code || '-'}</td>
+                        <td>${utils.escapeHTML(e.error)}</td>
+                        <td>${e.consecutive_failures}</td>
+                        <td>${utils.formatTime(e.checked_at)}</td>
+                    </tr>
+                `).join('');
+                wrapper.style.display = 'block';
+            } catch (error) {
+                console.error('Load notify health error:', error);
+            }
+        },
+
+        // 渲染汇总表
+        renderSummary(merchants) {
+            const tbody = document.getElementById('notifyBody');
+            if (!tbody) return;
+
+            if (merchants.length === 0) {
+                tbody.innerHTML = `
+                    <tr>
+                        <td colspan="6" class="empty-state">最近24小时没有通知记录</td>
+                    </tr>
+                `;
+                return;
+            }
+
+            tbody.innerHTML = merchants.map(m => `
+                <tr>
+                    <td>${utils.escapeHTML(m.pid)}</td>
+                    <td>${m.success}</td>
+                    <td>${m.failed > 0 ? `<strong style="color: #e74c3c;">${m.failed}</strong>` : 0}</td>
+                    <td>${m.pending}</td>
+                    <td title="${utils.escapeHTML(m.last_error)}">${m.last_failure_at ? utils.formatTime(m.last_failure_at) : '-'}</td>
+                    <td>
+                        <button class="btn btn-primary" onclick="window.adminActions.showNotifyLogs('${utils.escapeHTML(m.pid)}')">
+                            明细
+                        </button>
+                    </td>
+                </tr>
+            `).join('');
+        },
+
+        // 下钻查看商户通知明细
+        async showLogs(pid) {
+            try {
+                const response = await fetch(`${API.notifyLogs}?pid=${encodeURIComponent(pid)}&limit=50`, {
+                    credentials: 'include'
+                });
+
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '加载通知明细失败', 'error');
+                    return;
+                }
+
+                const logs = data.logs || [];
+                this.logs = {};
+                logs.forEach(log => { this.logs[log.id] = log; });
+
+                const tbody = document.getElementById('notifyLogsBody');
+                tbody.innerHTML = logs.length === 0
+                    ? '<tr><td colspan="7" class="empty-state">暂无记录</td></tr>'
+                    : logs.map(log => `
+                        <tr>
+                            <td>${utils.formatTime(log.created_at)}</td>
+                            <td>${utils.escapeHTML(log.trade_no)}</td>
+                            <td>${utils.escapeHTML(log.notify_url)}</td>
+                            <td>${log.status === 1 ? '✅ 成功' : '❌ 失败'}</td>
+                            <td>${log.http_status || '-'}</td>
+                            <td>${utils.escapeHTML(log.error || log.response)}</td>
+                            <td>${log.status === 1 ? '-' : `
+                                <button class="btn btn-sm btn-primary" onclick="window.adminActions.replayNotify(${log.id})">
+                                    🔁 重放
+                                </button>
+                            `}</td>
+                        </tr>
+                    `).join('');
+
+                document.getElementById('notifyLogsWrapper').style.display = 'block';
+            } catch (error) {
+                console.error('Load notify logs error:', error);
+                utils.showAlert('加载通知明细失败: ' + error.message, 'error');
+            }
+        },
+
+        // 重放通知（发送原始参数到指定地址，用于调试商户通知处理）
+        async replay(id) {
+            const log = this.logs[id];
+            if (!log) return;
+
+            const url = window.prompt('将原始通知参数发送到以下地址（可修改为测试环境地址）：', log.notify_url);
+            if (url === null) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.notifyReplay, {
+                    method: 'POST',
+                    credentials: 'include',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ id: id, url: url.trim() })
+                });
+
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '重放失败', 'error');
+                    return;
+                }
+
+                const result = data.result;
+                if (result.status === 1) {
+                    utils.showAlert(`重放成功（HTTP ${result.http_status}，${result.duration_ms}ms）`, 'success');
+                } else {
+                    utils.showAlert(`重放失败：${result.error || '商户未返回success'}`, 'error');
+                }
+            } catch (error) {
+                console.error('Replay notify error:', error);
+                utils.showAlert('重放失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 会话管理
+    const sessionManager = {
+        // 加载活跃会话
+        async loadSessions() {
+            try {
+                const response = await fetch(API.sessions, {
+                    credentials: 'include'
+                });
+
+                if (!response.ok) {
+                    throw new Error('Failed to load sessions');
+                }
+
+                const data = await response.json();
+
+                if (data.success) {
+                    this.renderSessions(data.sessions || []);
+                }
+            } catch (error) {
+                console.error('Load sessions error:', error);
+            }
+        },
+
+        // 渲染会话列表
+        renderSessions(sessions) {
+            const tbody = document.getElementById('sessionsBody');
+            if (!tbody) return;
+
+            if (sessions.length === 0) {
+                tbody.innerHTML = `
+                    <tr>
+                        <td colspan="7" class="empty-state">暂无活跃会话</td>
+                    </tr>
+                `;
+                return;
+            }
+
+            tbody.innerHTML = sessions.map(session => `
+                <tr>
+                    <td>${session.username ? utils.escapeHTML(session.username) : '商户'}</td>
+                    <td>${utils.escapeHTML(session.ip)}${session.location ? ` <small>${utils.escapeHTML(session.location)}</small>` : ''}${session.current ? ' <strong>(当前)</strong>' : ''}</td>
+                    <td title="${utils.escapeHTML(session.user_agent)}">${utils.escapeHTML((session.user_agent || '-').slice(0, 40))}</td>
+                    <td>${utils.formatTime(session.created_at)}</td>
+                    <td>${utils.formatTime(session.last_access)}</td>
+                    <td>${utils.formatTime(session.expires_at)}${session.remember ? ' · 记住我' : ''}</td>
+                    <td>
+                        <button class="btn btn-danger" onclick="window.adminActions.revokeSession('${utils.escapeHTML(session.id)}', ${session.current})">
+                            注销
+                        </button>
+                    </td>
+                </tr>
+            `).join('');
+        },
+
+        // 注销指定会话
+        async revokeSession(id, current) {
+            const message = current
+                ? '确定要注销当前会话吗？注销后需要重新登录。'
+                : '确定要注销该会话吗？';
+            if (!utils.confirm(message)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.revokeSession, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({ id })
+                });
+
+                const data = await response.json();
+
+                if (data.success) {
+                    if (data.current) {
+                        window.location.href = '/admin/login';
+                        return;
+                    }
+                    utils.showAlert('会话已注销', 'success');
+                    this.loadSessions();
+                } else {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                }
+            } catch (error) {
+                console.error('Revoke session error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 崩溃报告
+    const crashManager = {
+        // 加载最近的崩溃报告
+        async load() {
+            try {
+                const response = await fetch(API.crashes, {
+                    credentials: 'include'
+                });
+
+                if (!response.ok) {
+                    throw new Error('Failed to load crash reports');
+                }
+
+                const data = await response.json();
+
+                if (data.success) {
+                    const count = document.getElementById('crashCount');
+                    if (count) {
+                        count.textContent = data.last_24h || 0;
+                    }
+                    this.render(data.crashes || []);
+                }
+            } catch (error) {
+                console.error('Load crash reports error:', error);
+            }
+        },
+
+        // 渲染崩溃报告列表
+        render(crashes) {
+            const tbody = document.getElementById('crashesBody');
+            if (!tbody) return;
+
+            if (crashes.length === 0) {
+                tbody.innerHTML = `
+                    <tr>
+                        <td colspan="6" class="empty-state">暂无崩溃记录</td>
+                    </tr>
+                `;
+                return;
+            }
+
+            tbody.innerHTML = crashes.map(crash => `
+                <tr>
+                    <td>${utils.formatTime(crash.created_at)}</td>
+                    <td><code>${utils.escapeHTML(crash.method)} ${utils.escapeHTML(crash.path)}${crash.query ? '?' + utils.escapeHTML(crash.query) : ''}</code></td>
+                    <td>${utils.escapeHTML(crash.error)}</td>
+                    <td><code>${utils.escapeHTML(crash.request_id || '-')}</code></td>
+                    <td>${utils.escapeHTML(crash.ip || '-')}</td>
+                    <td>
+                        <details>
+                            <summary>查看</summary>
+                            <pre class="crash-stack">${utils.escapeHTML(crash.stack || '')}</pre>
+                        </details>
+                    </td>
+                </tr>
+            `).join('');
         }
     };
 
@@ -81,7 +456,7 @@
         // 加载订单列表
         async loadOrders() {
             try {
-                const response = await fetch(API.orders, {
+                const response = await fetch(`${API.orders}?${this.buildQuery()}`, {
                     credentials: 'include'
                 });
 
@@ -94,88 +469,948 @@
                 }
 
                 const data = await response.json();
-
-                if (data.code === 1) {
-                    state.orders = data.orders || [];
-                    this.renderOrders(state.orders);
-                } else {
-                    utils.showAlert(data.msg || '加载订单失败', 'error');
+
+                if (data.code === 1) {
+                    state.orders = data.orders || [];
+                    state.total = data.total || 0;
+                    this.renderOrders(state.orders);
+                    this.renderPagination();
+                } else {
+                    utils.showAlert(data.msg || '加载订单失败', 'error');
+                }
+            } catch (error) {
+                console.error('Load orders error:', error);
+                utils.showAlert('加载订单失败: ' + error.message, 'error');
+            }
+        },
+
+        // 渲染订单列表
+        renderOrders(orders) {
+            const tbody = document.getElementById('ordersBody');
+
+            if (!orders || orders.length === 0) {
+                tbody.innerHTML = `
+                    <tr>
+                        <td colspan="8" class="empty-state">
+                            <p>📭 暂无订单</p>
+                        </td>
+                    </tr>
+                `;
+                return;
+            }
+
+            tbody.innerHTML = orders.map(order => {
+                const statusInfo = utils.getStatusInfo(order.status);
+                return `
+                    <tr data-order-id="${order.trade_no}">
+                        <td><a href="/admin/orders/detail?trade_no=${encodeURIComponent(order.trade_no)}" title="查看订单详情"><code>${order.trade_no}</code></a></td>
+                        <td>${order.out_trade_no || '-'}</td>
+                        <td>${order.name || '-'}</td>
+                        <td>${utils.formatAmount(order.price)}</td>
+                        <td class="amount">${utils.formatAmount(order.payment_amount || order.price)}</td>
+                        <td>
+                            <span class="status ${statusInfo.class}">${statusInfo.text}</span>
+                            ${order.disputed ? '<span class="status closed" title="拒付/投诉中，已冻结商户通知">争议</span>' : ''}
+                        </td>
+                        <td>${utils.formatTime(order.add_time)}</td>
+                        <td>${this.renderActions(order)}</td>
+                    </tr>
+                `;
+            }).join('');
+        },
+
+        // 渲染操作按钮
+        renderActions(order) {
+            const actions = [];
+            if (!utils.can('operator')) {
+                return '<span style="color: #999;">-</span>';
+            }
+
+            if (order.status === 0) {
+                actions.push(`
+                    <button class="btn btn-sm btn-success" onclick="window.adminActions.markPaid('${order.trade_no}')">
+                        ✅ 标记已支付
+                    </button>
+                `);
+                actions.push(`
+                    <button class="btn btn-sm btn-danger" onclick="window.adminActions.cancelOrder('${order.trade_no}')">
+                        ❌ 取消
+                    </button>
+                `);
+            } else if (order.status === 1) {
+                actions.push(`
+                    <button class="btn btn-sm btn-warning" onclick="window.adminActions.refundOrder('${order.trade_no}')">
+                        ↩️ 标记已退款
+                    </button>
+                `);
+            }
+
+            if (order.disputed) {
+                actions.push(`
+                    <button class="btn btn-sm btn-info" onclick="window.adminActions.setDispute('${order.trade_no}', false)">
+                        ✔️ 解除争议
+                    </button>
+                `);
+            } else if (order.status === 1) {
+                actions.push(`
+                    <button class="btn btn-sm btn-danger" onclick="window.adminActions.setDispute('${order.trade_no}', true)">
+                        ⚠️ 标记争议
+                    </button>
+                `);
+            }
+
+            return actions.length > 0 ? actions.join('') : '<span style="color: #999;">-</span>';
+        },
+
+        // 构建查询参数（搜索条件 + 分页）
+        buildQuery() {
+            const params = new URLSearchParams({
+                page: state.page,
+                page_size: state.pageSize
+            });
+
+            const filters = {
+                keyword: 'searchInput',
+                status: 'filterStatus',
+                start_date: 'filterStartDate',
+                end_date: 'filterEndDate',
+                min_amount: 'filterMinAmount',
+                max_amount: 'filterMaxAmount'
+            };
+
+            Object.entries(filters).forEach(([param, id]) => {
+                const el = document.getElementById(id);
+                const value = el ? el.value.trim() : '';
+                if (value) {
+                    params.set(param, value);
+                }
+            });
+
+            return params.toString();
+        },
+
+        // 渲染分页信息
+        renderPagination() {
+            const totalPages = Math.max(1, Math.ceil(state.total / state.pageSize));
+            const info = document.getElementById('ordersPageInfo');
+            if (info) {
+                info.textContent = `共 ${state.total} 条，第 ${state.page} / ${totalPages} 页`;
+            }
+
+            const prev = document.getElementById('ordersPrevPage');
+            const next = document.getElementById('ordersNextPage');
+            if (prev) prev.disabled = state.page <= 1;
+            if (next) next.disabled = state.page >= totalPages;
+        },
+
+        // 翻页
+        changePage(delta) {
+            const totalPages = Math.max(1, Math.ceil(state.total / state.pageSize));
+            const page = state.page + delta;
+            if (page < 1 || page > totalPages) {
+                return;
+            }
+            state.page = page;
+            this.loadOrders();
+        },
+
+        // 按当前筛选条件导出订单（不分页）
+        exportOrders(format) {
+            const params = new URLSearchParams(this.buildQuery());
+            params.delete('page');
+            params.delete('page_size');
+            params.set('format', format);
+            window.location.href = `${API.exportOrders}?${params.toString()}`;
+        },
+
+        // 搜索订单（服务端过滤，从第一页开始）
+        searchOrder() {
+            state.page = 1;
+            this.loadOrders();
+        }
+    };
+
+    // 收款码管理
+    const qrcodeManager = {
+        // 加载收款码列表
+        async load() {
+            try {
+                const response = await fetch(API.qrcodes, {
+                    credentials: 'include'
+                });
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '加载收款码失败', 'error');
+                    return;
+                }
+
+                this.render(data.qrcodes, data.business_qr_mode);
+            } catch (error) {
+                console.error('Load QR codes error:', error);
+            }
+        },
+
+        // 渲染收款码列表
+        render(qrcodes, businessMode) {
+            const tbody = document.getElementById('qrcodesBody');
+            document.getElementById('qrcodesModeHint').style.display = businessMode ? 'none' : 'block';
+
+            if (!qrcodes || qrcodes.length === 0) {
+                tbody.innerHTML = '<tr><td colspan="7" class="empty-state">暂无收款码</td></tr>';
+                return;
+            }
+
+            tbody.innerHTML = qrcodes.map(qr => {
+                const id = utils.escapeHTML(qr.id);
+                const image = qr.image_exists
+                    ? `<img src="${API.qrcodeImage}?id=${encodeURIComponent(qr.id)}&t=${Date.now()}" alt="${id}" style="width: 60px; height: 60px;">`
+                    : '<span style="color: #ff4d4f;">图片缺失</span>';
+                return `
+                    <tr>
+                        <td>${image}</td>
+                        <td><code>${id}</code>${qr.independent_api ? ' <small>(独立API)</small>' : ''}</td>
+                        <td>${utils.escapeHTML(qr.code_id || '-')}</td>
+                        <td>
+                            <input type="number" value="${qr.priority}" style="width: 70px;"
+                                onchange="window.adminActions.setQRCodePriority('${id}', this.value)">
+                        </td>
+                        <td><span class="status ${qr.enabled ? 'paid' : 'closed'}">${qr.enabled ? '已启用' : '已禁用'}</span></td>
+                        <td>${qr.usage_count}</td>
+                        <td>
+                            <button class="btn btn-sm ${qr.enabled ? 'btn-danger' : 'btn-success'}"
+                                onclick="window.adminActions.toggleQRCode('${id}', ${!qr.enabled})">
+                                ${qr.enabled ? '禁用' : '启用'}
+                            </button>
+                        </td>
+                    </tr>
+                `;
+            }).join('');
+        },
+
+        // 修改收款码设置
+        async update(payload) {
+            try {
+                const response = await fetch(API.qrcodeUpdate, {
+                    method: 'POST',
+                    credentials: 'include',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify(payload)
+                });
+                const data = await response.json();
+
+                if (data.success) {
+                    utils.showAlert('收款码已更新', 'success');
+                } else {
+                    utils.showAlert(data.error || '更新收款码失败', 'error');
+                }
+            } catch (error) {
+                console.error('Update QR code error:', error);
+                utils.showAlert('更新收款码失败: ' + error.message, 'error');
+            }
+            this.load();
+        },
+
+        // 上传收款码图片
+        async upload() {
+            const fileInput = document.getElementById('qrcodeFile');
+            const id = document.getElementById('qrcodeID').value.trim();
+
+            if (!id || fileInput.files.length === 0) {
+                utils.showAlert('请填写二维码ID并选择图片', 'warning');
+                return;
+            }
+
+            const form = new FormData();
+            form.append('id', id);
+            form.append('code_id', document.getElementById('qrcodeCodeID').value.trim());
+            form.append('priority', document.getElementById('qrcodePriority').value.trim() || '0');
+            form.append('file', fileInput.files[0]);
+
+            try {
+                const response = await fetch(API.qrcodeUpload, {
+                    method: 'POST',
+                    credentials: 'include',
+                    body: form
+                });
+                const data = await response.json();
+
+                if (data.success) {
+                    utils.showAlert(`收款码 ${data.qrcode.id} 已上传`, 'success');
+                    fileInput.value = '';
+                } else {
+                    utils.showAlert(data.error || '上传收款码失败', 'error');
+                }
+            } catch (error) {
+                console.error('Upload QR code error:', error);
+                utils.showAlert('上传收款码失败: ' + error.message, 'error');
+            }
+            this.load();
+        }
+    };
+
+    // 手动创建订单（线下收款）
+    const manualOrder = {
+        async create() {
+            const name = document.getElementById('manualOrderName').value.trim();
+            const money = document.getElementById('manualOrderMoney').value.trim();
+            const notifyURL = document.getElementById('manualOrderNotifyURL').value.trim();
+
+            if (!name || !money) {
+                utils.showAlert('请填写商品名称和金额', 'warning');
+                return;
+            }
+
+            try {
+                const response = await fetch(API.createOrder, {
+                    method: 'POST',
+                    credentials: 'include',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ name: name, money: money, notify_url: notifyURL })
+                });
+
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '创建订单失败', 'error');
+                    return;
+                }
+
+                const order = data.order;
+                document.getElementById('manualOrderQR').src = `data:image/png;base64,${order.qr_code}`;
+                document.getElementById('manualOrderTradeNo').textContent = order.trade_no;
+                document.getElementById('manualOrderAmount').textContent = utils.formatAmount(order.payment_amount);
+                document.getElementById('manualOrderLink').href = order.payment_url;
+                document.getElementById('manualOrderResult').style.display = 'block';
+
+                utils.showAlert('订单已创建，请让顾客扫码支付', 'success');
+                orderManager.loadOrders();
+            } catch (error) {
+                console.error('Create order error:', error);
+                utils.showAlert('创建订单失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 线下收款码批量生成
+    const printedCodes = {
+        async create() {
+            const name = document.getElementById('printedCodeName').value.trim();
+            const money = document.getElementById('printedCodeMoney').value.trim();
+            const count = parseInt(document.getElementById('printedCodeCount').value, 10);
+
+            if (!name || !money || !count) {
+                utils.showAlert('请填写商品名称、金额和数量', 'warning');
+                return;
+            }
+
+            try {
+                const response = await fetch(API.printedCodes, {
+                    method: 'POST',
+                    credentials: 'include',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ name: name, money: money, count: count })
+                });
+
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '生成收款码失败', 'error');
+                    return;
+                }
+
+                utils.showAlert(`已生成 ${data.batch.count} 个收款码，正在下载`, 'success');
+                window.location.href = data.download_url;
+            } catch (error) {
+                console.error('Create printed codes error:', error);
+                utils.showAlert('生成收款码失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 沙箱凭据
+    const sandboxManager = {
+        async load() {
+            try {
+                const response = await fetch(API.sandbox, { credentials: 'include' });
+                const data = await response.json();
+                if (data.success) {
+                    this.render(data);
+                }
+            } catch (error) {
+                console.error('Load sandbox credentials error:', error);
+            }
+        },
+
+        render(data) {
+            const el = document.getElementById('sandboxCredentials');
+            if (!data.issued) {
+                el.textContent = '未签发';
+                return;
+            }
+            el.innerHTML = `商户ID <code>${utils.escapeHTML(data.pid)}</code>，密钥 <code>${utils.escapeHTML(data.key)}</code>`;
+            document.getElementById('sandboxIssueBtn').textContent = '🔑 重新签发密钥';
+        },
+
+        async issue() {
+            const issued = document.getElementById('sandboxCredentials').querySelector('code');
+            if (issued && !utils.confirm('确定要重新签发沙箱密钥吗？\n\n沙箱商户ID不变，旧密钥立即失效。')) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.sandboxIssue, {
+                    method: 'POST',
+                    credentials: 'include'
+                });
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '签发沙箱密钥失败', 'error');
+                    return;
+                }
+
+                this.render(data);
+                utils.showAlert('沙箱密钥已签发', 'success');
+            } catch (error) {
+                console.error('Issue sandbox credentials error:', error);
+                utils.showAlert('签发沙箱密钥失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 商户密钥轮换
+    const merchantKeyManager = {
+        async load() {
+            try {
+                const response = await fetch(API.merchantKey, { credentials: 'include' });
+                const data = await response.json();
+                if (data.success) {
+                    this.render(data);
+                }
+            } catch (error) {
+                console.error('Load merchant key status error:', error);
+            }
+        },
+
+        render(data) {
+            const el = document.getElementById('merchantKeyStatus');
+            el.textContent = data.previous_key_active
+                ? `旧密钥过渡期至 ${utils.formatTime(data.previous_key_expires_at)}，过渡期内旧密钥仍可签名请求`
+                : '无过渡期中的旧密钥';
+        },
+
+        async rotate() {
+            const hours = window.prompt('轮换商户密钥后，新密钥立即生效，管理后台需使用新密钥登录。\n\n旧密钥在过渡期内仍可签名商户请求，请输入过渡期（小时，0表示旧密钥立即失效，最长720）：', '24');
+            if (hours === null) {
+                return;
+            }
+            const grace = Number(hours);
+            if (hours.trim() === '' || !Number.isFinite(grace) || grace < 0) {
+                utils.showAlert('过渡期格式错误', 'error');
+                return;
+            }
+
+            try {
+                const response = await fetch(API.merchantKeyRotate, {
+                    method: 'POST',
+                    credentials: 'include',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ grace: Math.round(grace * 3600) })
+                });
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '轮换商户密钥失败', 'error');
+                    return;
+                }
+
+                document.getElementById('merchantKeyNew').innerHTML =
+                    `新密钥 <code>${utils.escapeHTML(data.rotation.key)}</code>（仅显示一次，请立即更新商户系统的配置）`;
+                this.load();
+                utils.showAlert('商户密钥已轮换', 'success');
+            } catch (error) {
+                console.error('Rotate merchant key error:', error);
+                utils.showAlert('轮换商户密钥失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 退款申请审核
+    const refundManager = {
+        statusText: {
+            pending: '待审核',
+            approved: '已同意',
+            rejected: '已拒绝'
+        },
+
+        async load() {
+            const status = document.getElementById('refundStatus').value;
+            try {
+                const response = await fetch(`${API.refunds}?status=${encodeURIComponent(status)}`, { credentials: 'include' });
+                const data = await response.json();
+                if (data.success) {
+                    this.render(data.requests || []);
+                }
+            } catch (error) {
+                console.error('Load refund requests error:', error);
+            }
+        },
+
+        render(requests) {
+            const tbody = document.getElementById('refundsBody');
+            if (requests.length === 0) {
+                tbody.innerHTML = `
+                    <tr>
+                        <td colspan="8" class="empty-state">暂无退款申请</td>
+                    </tr>
+                `;
+                return;
+            }
+
+            tbody.innerHTML = requests.map(request => `
+                <tr>
+                    <td>${utils.escapeHTML(request.refund_no)}</td>
+                    <td><a href="/admin/orders/detail?trade_no=${encodeURIComponent(request.trade_no)}">${utils.escapeHTML(request.trade_no)}</a></td>
+                    <td>${utils.escapeHTML(request.out_trade_no)}</td>
+                    <td>${utils.formatAmount(request.money)}</td>
+                    <td>${utils.escapeHTML(request.reason || '-')}</td>
+                    <td>${utils.formatTime(request.created_at)}</td>
+                    <td title="${utils.escapeHTML(request.admin_note)}">${this.statusText[request.status] || utils.escapeHTML(request.status)}</td>
+                    <td>
+                        ${request.status === 'pending' && utils.can('operator') ? `
+                        <button class="btn btn-success" onclick="window.adminActions.reviewRefund('${utils.escapeHTML(request.refund_no)}', true)">同意</button>
+                        <button class="btn btn-danger" onclick="window.adminActions.reviewRefund('${utils.escapeHTML(request.refund_no)}', false)">拒绝</button>
+                        ` : '-'}
+                    </td>
+                </tr>
+            `).join('');
+        },
+
+        // 同意或拒绝退款申请
+        async review(refundNo, approve) {
+            const note = approve
+                ? window.prompt(`同意退款申请 ${refundNo}？\n\n同意后请在支付宝中手动退款。可填写备注（商户可见）：`, '')
+                : window.prompt(`拒绝退款申请 ${refundNo}？\n\n请填写拒绝原因（商户可见）：`, '');
+            if (note === null) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.refundReview, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({ refund_no: refundNo, approve: approve, note: note })
+                });
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                    return;
+                }
+
+                utils.showAlert(approve ? '已同意，请在支付宝中完成退款' : '已拒绝退款申请', 'success');
+                this.load();
+                orderManager.loadOrders();
+            } catch (error) {
+                console.error('Review refund request error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+            }
+        }
+    };
+
+    // 管理员账号
+    const userManager = {
+        roleText: {
+            viewer: '只读',
+            operator: '操作员',
+            admin: '管理员'
+        },
+
+        async load() {
+            try {
+                const response = await fetch(API.users, { credentials: 'include' });
+                const data = await response.json();
+                if (data.success) {
+                    this.render(data.users || []);
+                }
+            } catch (error) {
+                console.error('Load admin users error:', error);
+            }
+        },
+
+        render(users) {
+            const tbody = document.getElementById('usersBody');
+            if (!tbody) return;
+
+            if (users.length === 0) {
+                tbody.innerHTML = `
+                    <tr>
+                        <td colspan="7" class="empty-state">暂无管理员账号，当前仅可使用商户ID和密钥登录</td>
+                    </tr>
+                `;
+                return;
+            }
+
+            tbody.innerHTML = users.map(user => {
+                const name = utils.escapeHTML(user.username);
+                const options = Object.entries(this.roleText).map(([role, text]) =>
+                    `<option value="${role}"${user.role === role ? ' selected' : ''}>${text}</option>`
+                ).join('');
+                return `
+                    <tr>
+                        <td>${name}</td>
+                        <td><select onchange="window.adminActions.changeUserRole('${name}', this.value)">${options}</select></td>
+                        <td>${user.disabled ? '<span class="status closed">已停用</span>' : '<span class="status paid">正常</span>'}</td>
+                        <td>${user.two_factor ? '已开启' : '未开启'}</td>
+                        <td>${utils.formatTime(user.created_at)}</td>
+                        <td>${utils.formatTime(user.last_login_at)}</td>
+                        <td>
+                            <button class="btn btn-primary" onclick="window.adminActions.resetUserPassword('${name}')">重置密码</button>
+                            ${user.two_factor ? `<button class="btn btn-warning" onclick="window.adminActions.resetUserTwoFactor('${name}')">重置两步验证</button>` : ''}
+                            <button class="btn btn-warning" onclick="window.adminActions.toggleUser('${name}', ${!user.disabled})">${user.disabled ? '启用' : '停用'}</button>
+                            <button class="btn btn-danger" onclick="window.adminActions.deleteUser('${name}')">删除</button>
+                        </td>
+                    </tr>
+                `;
+            }).join('');
+        },
+
+        // 发送请求（成功后刷新列表，修改的是当前账号时需重新登录）
+        async post(url, body, successMessage) {
+            try {
+                const response = await fetch(url, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify(body)
+                });
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                    this.load();
+                    return false;
+                }
+
+                if (data.current) {
+                    window.location.href = '/admin/login';
+                    return true;
+                }
+                utils.showAlert(successMessage, 'success');
+                this.load();
+                sessionManager.loadSessions();
+                return true;
+            } catch (error) {
+                console.error('Admin user request error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+                return false;
+            }
+        },
+
+        async create() {
+            const username = document.getElementById('userUsername').value.trim();
+            const password = document.getElementById('userPassword').value;
+            const role = document.getElementById('userRole').value;
+            if (!username || !password) {
+                utils.showAlert('请输入用户名和密码', 'warning');
+                return;
+            }
+
+            if (await this.post(API.users, { username, password, role }, `已添加账号 ${username}`)) {
+                document.getElementById('userUsername').value = '';
+                document.getElementById('userPassword').value = '';
+            }
+        },
+
+        changeRole(username, role) {
+            if (!utils.confirm(`确定将 ${username} 的角色改为「${this.roleText[role]}」吗？该账号需要重新登录。`)) {
+                this.load();
+                return;
+            }
+            this.post(API.updateUser, { username, role }, '角色已修改');
+        },
+
+        toggle(username, disabled) {
+            if (disabled && !utils.confirm(`确定停用 ${username} 吗？该账号的会话将立即注销。`)) {
+                return;
+            }
+            this.post(API.updateUser, { username, disabled }, disabled ? '账号已停用' : '账号已启用');
+        },
+
+        resetPassword(username) {
+            const password = window.prompt(`请输入 ${username} 的新密码（至少8位）：`, '');
+            if (!password) {
+                return;
+            }
+            this.post(API.updateUser, { username, password }, '密码已重置');
+        },
+
+        resetTwoFactor(username) {
+            if (!utils.confirm(`确定关闭 ${username} 的两步验证吗？该账号的会话将立即注销，需重新开启两步验证。`)) {
+                return;
+            }
+            this.post(API.updateUser, { username, reset_two_factor: true }, '两步验证已重置');
+        },
+
+        remove(username) {
+            if (!utils.confirm(`确定删除账号 ${username} 吗？`)) {
+                return;
+            }
+            this.post(API.deleteUser, { username }, '账号已删除');
+        }
+    };
+
+    // API令牌
+    const tokenManager = {
+        scopeText: {
+            read: '只读',
+            orders: '订单操作'
+        },
+        tokens: [],
+
+        async load() {
+            try {
+                const response = await fetch(API.tokens, { credentials: 'include' });
+                const data = await response.json();
+                if (data.success) {
+                    this.render(data.tokens || []);
                 }
             } catch (error) {
-                console.error('Load orders error:', error);
-                utils.showAlert('加载订单失败: ' + error.message, 'error');
+                console.error('Load API tokens error:', error);
             }
         },
 
-        // 渲染订单列表
-        renderOrders(orders) {
-            const tbody = document.getElementById('ordersBody');
+        render(tokens) {
+            this.tokens = tokens;
+            const tbody = document.getElementById('tokensBody');
+            if (!tbody) return;
 
-            if (!orders || orders.length === 0) {
+            if (tokens.length === 0) {
                 tbody.innerHTML = `
                     <tr>
-                        <td colspan="8" class="empty-state">
-                            <p>📭 暂无订单</p>
-                        </td>
+                        <td colspan="7" class="empty-state">暂无API令牌</td>
                     </tr>
                 `;
                 return;
             }
 
-            tbody.innerHTML = orders.map(order => {
-                const statusInfo = utils.getStatusInfo(order.status);
+            tbody.innerHTML = tokens.map(token => {
+                const name = utils.escapeHTML(token.name);
+                const expired = token.expires_at && new Date(token.expires_at) < new Date();
+                const lastUsed = token.last_used_at
+                    ? `${utils.formatTime(token.last_used_at)} ${utils.escapeHTML(token.last_used_ip)}`
+                    : '从未使用';
                 return `
-                    <tr data-order-id="${order.trade_no}">
-                        <td><code>${order.trade_no}</code></td>
-                        <td>${order.out_trade_no || '-'}</td>
-                        <td>${order.name || '-'}</td>
-                        <td>${utils.formatAmount(order.price)}</td>
-                        <td class="amount">${utils.formatAmount(order.payment_amount || order.price)}</td>
-                        <td><span class="status ${statusInfo.class}">${statusInfo.text}</span></td>
-                        <td>${utils.formatTime(order.add_time)}</td>
-                        <td>${this.renderActions(order)}</td>
+                    <tr>
+                        <td>${name}</td>
+                        <td><code>${utils.escapeHTML(token.prefix)}…</code></td>
+                        <td>${this.scopeText[token.scope] || utils.escapeHTML(token.scope)}</td>
+                        <td>${utils.formatTime(token.created_at)}</td>
+                        <td>${token.expires_at ? utils.formatTime(token.expires_at) : '长期有效'}${expired ? ' <span class="status closed">已过期</span>' : ''}</td>
+                        <td>${lastUsed}</td>
+                        <td>
+                            <button class="btn btn-danger" onclick="window.adminActions.revokeToken('${utils.escapeHTML(token.id)}')">注销</button>
+                        </td>
                     </tr>
                 `;
             }).join('');
         },
 
-        // 渲染操作按钮
-        renderActions(order) {
-            const actions = [];
+        async post(url, body) {
+            try {
+                const response = await fetch(url, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify(body)
+                });
+                const data = await response.json();
+                if (!data.success) {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                    return null;
+                }
+                this.load();
+                return data;
+            } catch (error) {
+                console.error('API token request error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+                return null;
+            }
+        },
 
-            if (order.status === 0) {
-                actions.push(`
-                    <button class="btn btn-sm btn-success" onclick="window.adminActions.markPaid('${order.trade_no}')">
-                        ✅ 标记已支付
-                    </button>
-                `);
-                actions.push(`
-                    <button class="btn btn-sm btn-danger" onclick="window.adminActions.cancelOrder('${order.trade_no}')">
-                        ❌ 取消
-                    </button>
-                `);
+        async create() {
+            const name = document.getElementById('tokenName').value.trim();
+            const scope = document.getElementById('tokenScope').value;
+            const expiresDays = parseInt(document.getElementById('tokenExpiresDays').value, 10) || 0;
+            if (!name) {
+                utils.showAlert('请输入令牌名称', 'warning');
+                return;
             }
 
-            return actions.length > 0 ? actions.join('') : '<span style="color: #999;">-</span>';
+            const data = await this.post(API.tokens, { name, scope, expires_days: expiresDays });
+            if (data) {
+                const el = document.getElementById('tokenCreated');
+                el.textContent = `令牌「${name}」（仅显示一次，请立即保存）：\n\n${data.token}`;
+                el.style.display = '';
+                document.getElementById('tokenName').value = '';
+                document.getElementById('tokenExpiresDays').value = '';
+                utils.showAlert('令牌已创建', 'success');
+            }
         },
 
-        // 搜索订单
-        searchOrder() {
-            const input = document.getElementById('searchInput');
-            const keyword = input.value.trim().toLowerCase();
+        async revoke(id) {
+            const token = this.tokens.find(t => t.id === id);
+            if (!utils.confirm(`确定注销令牌「${token ? token.name : id}」吗？使用该令牌的程序将无法再访问。`)) {
+                return;
+            }
+            if (await this.post(API.revokeToken, { id })) {
+                utils.showAlert('令牌已注销', 'success');
+            }
+        }
+    };
+
+    // 两步验证
+    const twoFactorManager = {
+        async load() {
+            try {
+                const response = await fetch(API.twoFactor, { credentials: 'include' });
+                const data = await response.json();
+                if (data.success) {
+                    this.render(data);
+                }
+            } catch (error) {
+                console.error('Load two-factor status error:', error);
+            }
+        },
+
+        render(data) {
+            const status = document.getElementById('twoFactorStatus');
+            if (!data.available) {
+                status.textContent = '当前部署未配置持久化存储，无法开启两步验证';
+                return;
+            }
+
+            if (data.enabled) {
+                status.innerHTML = `<strong>已开启</strong>，剩余恢复码 ${data.recovery_codes} 个`;
+                document.getElementById('twoFactorSetup').style.display = 'none';
+            } else {
+                status.textContent = '未开启';
+            }
+            document.getElementById('twoFactorSetupBtn').style.display = data.enabled ? 'none' : '';
+            document.getElementById('twoFactorRecoveryBtn').style.display = data.enabled ? '' : 'none';
+            document.getElementById('twoFactorDisableBtn').style.display = data.enabled ? '' : 'none';
+        },
+
+        // 生成密钥并显示二维码
+        async setup() {
+            try {
+                const response = await fetch(API.twoFactorSetup, {
+                    method: 'POST',
+                    credentials: 'include'
+                });
+                const data = await response.json();
+
+                if (!data.success) {
+                    utils.showAlert(data.error || '生成密钥失败', 'error');
+                    return;
+                }
+
+                document.getElementById('twoFactorSecret').textContent = data.setup.secret;
+                const qrcode = document.getElementById('twoFactorQRCode');
+                qrcode.src = data.setup.qrcode || '';
+                qrcode.style.display = data.setup.qrcode ? 'block' : 'none';
+                document.getElementById('twoFactorCode').value = '';
+                document.getElementById('twoFactorSetup').style.display = '';
+            } catch (error) {
+                console.error('Setup two-factor error:', error);
+                utils.showAlert('生成密钥失败: ' + error.message, 'error');
+            }
+        },
 
-            if (!keyword) {
-                this.renderOrders(state.orders);
+        // 输入动态验证码确认开启
+        async enable() {
+            const otp = document.getElementById('twoFactorCode').value.trim();
+            if (!otp) {
+                utils.showAlert('请输入动态验证码', 'warning');
                 return;
             }
 
-            const filtered = state.orders.filter(order => 
-                (order.trade_no && order.trade_no.toLowerCase().includes(keyword)) ||
-                (order.out_trade_no && order.out_trade_no.toLowerCase().includes(keyword)) ||
-                (order.name && order.name.toLowerCase().includes(keyword))
-            );
+            const data = await this.post(API.twoFactorEnable, otp);
+            if (!data) return;
+
+            this.showRecoveryCodes(data.recovery_codes);
+            utils.showAlert('两步验证已开启，请妥善保存恢复码', 'success');
+            this.load();
+        },
+
+        // 关闭两步验证
+        async disable() {
+            const otp = window.prompt('关闭两步验证后，仅凭商户ID和密钥即可登录。\n\n请输入动态验证码或恢复码：');
+            if (!otp) return;
+
+            const data = await this.post(API.twoFactorDisable, otp);
+            if (!data) return;
+
+            document.getElementById('twoFactorRecoveryCodes').style.display = 'none';
+            utils.showAlert('两步验证已关闭', 'success');
+            this.load();
+        },
+
+        // 重新生成恢复码
+        async regenerateRecoveryCodes() {
+            const otp = window.prompt('重新生成后旧恢复码全部失效。\n\n请输入动态验证码：');
+            if (!otp) return;
+
+            const data = await this.post(API.twoFactorRecoveryCodes, otp);
+            if (!data) return;
+
+            this.showRecoveryCodes(data.recovery_codes);
+            utils.showAlert('恢复码已重新生成，请妥善保存', 'success');
+            this.load();
+        },
+
+        showRecoveryCodes(codes) {
+            const el = document.getElementById('twoFactorRecoveryCodes');
+            el.textContent = '恢复码（仅显示一次，每个只能使用一次）：\n\n' + (codes || []).join('\n');
+            el.style.display = '';
+        },
+
+        async post(url, otp) {
+            try {
+                const response = await fetch(url, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({ otp: otp })
+                });
+                const data = await response.json();
 
-            this.renderOrders(filtered);
+                if (!data.success) {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                    return null;
+                }
+                return data;
+            } catch (error) {
+                console.error('Two-factor request error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+                return null;
+            }
         }
     };
 
@@ -257,6 +1492,190 @@
             }
         },
 
+        // 标记订单已退款（退款需先在支付宝中手动完成）
+        async refundOrder(tradeNo) {
+            if (!utils.confirm(`确定要将订单 ${tradeNo} 标记为已退款吗？\n\n请先在支付宝中完成退款，此操作仅更新订单状态且不可撤销！`)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.action, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        action: 'refund',
+                        trade_no: tradeNo
+                    })
+                });
+
+                const data = await response.json();
+
+                if (data.success) {
+                    utils.showAlert('订单已标记为已退款', 'success');
+                    // 重新加载订单列表
+                    orderManager.loadOrders();
+                } else {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                }
+            } catch (error) {
+                console.error('Refund order error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+            }
+        },
+
+        // 标记或解除订单争议（争议期间冻结商户通知，不计入收入统计）
+        async setDispute(tradeNo, disputed) {
+            const reason = prompt(disputed
+                ? `将订单 ${tradeNo} 标记为争议（拒付/投诉）\n\n争议期间不再通知商户，请输入争议原因：`
+                : `解除订单 ${tradeNo} 的争议\n\n请输入处理结果：`, '');
+            if (reason === null) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.dispute, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    credentials: 'include',
+                    body: JSON.stringify({
+                        trade_no: tradeNo,
+                        disputed: disputed,
+                        reason: reason
+                    })
+                });
+
+                const data = await response.json();
+
+                if (data.success) {
+                    utils.showAlert(disputed ? '订单已标记为争议' : '订单争议已解除', 'success');
+                    orderManager.loadOrders();
+                } else {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                }
+            } catch (error) {
+                console.error('Dispute order error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+            }
+        },
+
+        // 轮换签名密钥，注销所有会话（包括当前会话）
+        async rotateSessions() {
+            if (!utils.confirm('确定要注销所有管理后台会话吗？\n\n所有已登录的设备（包括当前页面）都需要重新登录。')) {
+                return;
+            }
+
+            try {
+                const response = await fetch(API.rotateSessions, {
+                    method: 'POST',
+                    credentials: 'include'
+                });
+
+                const data = await response.json();
+
+                if (data.success) {
+                    utils.showAlert(data.message, 'success');
+                    setTimeout(() => {
+                        window.location.href = data.redirect || '/admin/login';
+                    }, 1000);
+                } else {
+                    utils.showAlert(data.error || '操作失败', 'error');
+                }
+            } catch (error) {
+                console.error('Rotate sessions error:', error);
+                utils.showAlert('操作失败: ' + error.message, 'error');
+            }
+        },
+
+        // 查看商户通知明细
+        showNotifyLogs(pid) {
+            notifyManager.showLogs(pid);
+        },
+
+        // 重放通知
+        replayNotify(id) {
+            notifyManager.replay(id);
+        },
+
+        // 注销指定会话
+        revokeSession(id, current) {
+            sessionManager.revokeSession(id, current);
+        },
+
+        // 添加管理员账号
+        createUser() {
+            userManager.create();
+        },
+
+        // 修改管理员账号角色
+        changeUserRole(username, role) {
+            userManager.changeRole(username, role);
+        },
+
+        // 停用或启用管理员账号
+        toggleUser(username, disabled) {
+            userManager.toggle(username, disabled);
+        },
+
+        // 重置管理员账号密码
+        resetUserPassword(username) {
+            userManager.resetPassword(username);
+        },
+
+        // 重置管理员账号的两步验证
+        resetUserTwoFactor(username) {
+            userManager.resetTwoFactor(username);
+        },
+
+        // 删除管理员账号
+        deleteUser(username) {
+            userManager.remove(username);
+        },
+
+        // 创建API令牌
+        createToken() {
+            tokenManager.create();
+        },
+
+        // 注销API令牌
+        revokeToken(id) {
+            tokenManager.revoke(id);
+        },
+
+        // 刷新退款申请列表
+        loadRefunds() {
+            refundManager.load();
+        },
+
+        // 审核退款申请
+        reviewRefund(refundNo, approve) {
+            refundManager.review(refundNo, approve);
+        },
+
+        // 开启两步验证（显示二维码）
+        setupTwoFactor() {
+            twoFactorManager.setup();
+        },
+
+        // 确认开启两步验证
+        enableTwoFactor() {
+            twoFactorManager.enable();
+        },
+
+        // 关闭两步验证
+        disableTwoFactor() {
+            twoFactorManager.disable();
+        },
+
+        // 重新生成恢复码
+        regenerateRecoveryCodes() {
+            twoFactorManager.regenerateRecoveryCodes();
+        },
+
         // 刷新订单列表
         loadOrders() {
             orderManager.loadOrders();
@@ -265,6 +1684,56 @@
         // 搜索订单
         searchOrder() {
             orderManager.searchOrder();
+        },
+
+        // 翻页
+        changePage(delta) {
+            orderManager.changePage(delta);
+        },
+
+        // 导出订单
+        exportOrders(format) {
+            orderManager.exportOrders(format);
+        },
+
+        // 手动创建订单
+        createOrder() {
+            manualOrder.create();
+        },
+
+        // 批量生成线下收款码
+        createPrintedCodes() {
+            printedCodes.create();
+        },
+
+        // 签发沙箱密钥
+        issueSandbox() {
+            sandboxManager.issue();
+        },
+
+        // 轮换商户密钥
+        rotateMerchantKey() {
+            merchantKeyManager.rotate();
+        },
+
+        // 启用/禁用收款码
+        toggleQRCode(id, enabled) {
+            qrcodeManager.update({ id: id, enabled: enabled });
+        },
+
+        // 调整收款码优先级
+        setQRCodePriority(id, priority) {
+            const value = parseInt(priority, 10);
+            if (isNaN(value)) {
+                utils.showAlert('优先级必须是整数', 'warning');
+                return;
+            }
+            qrcodeManager.update({ id: id, priority: value });
+        },
+
+        // 上传收款码
+        uploadQRCode() {
+            qrcodeManager.upload();
         }
     };
 
@@ -304,6 +1773,15 @@
             console.log('[Admin WS] Message:', data);
 
             switch (data.type) {
+                case 'reconnect': {
+                    // 服务平滑升级：先连接新进程再关闭旧连接
+                    const previous = state.ws;
+                    previous.onclose = null;
+                    state.ws = null;
+                    this.connect();
+                    previous.close();
+                    break;
+                }
                 case 'stats_update':
                     this.updateStats(data);
                     break;
@@ -355,6 +1833,40 @@
         // 加载订单
         orderManager.loadOrders();
 
+        // 加载通知概览
+        notifyManager.loadSummary();
+        notifyManager.loadHealth();
+
+        // 加载退款申请
+        refundManager.load();
+
+        // 加载当前账号的两步验证状态（每个账号独立开启）
+        twoFactorManager.load();
+
+        // 配置、会话和账号仅管理员可见
+        if (utils.can('admin')) {
+            // 加载收款码
+            qrcodeManager.load();
+
+            // 加载沙箱凭据
+            sandboxManager.load();
+
+            // 加载商户密钥轮换状态
+            merchantKeyManager.load();
+
+            // 加载活跃会话
+            sessionManager.loadSessions();
+
+            // 加载管理员账号
+            userManager.load();
+
+            // 加载API令牌
+            tokenManager.load();
+
+            // 加载崩溃报告
+            crashManager.load();
+        }
+
         // 连接WebSocket
         wsManager.connect();
 
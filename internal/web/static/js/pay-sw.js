@@ -0,0 +1,125 @@
+/*
+支付页面 Service Worker
+功能:
+  - 网络短暂中断时保持支付页面可用（倒计时、二维码）
+  - 支付页面（/pay、/submit 的GET请求）和二维码图片（/qrcode）: 网络优先，失败时返回最近一次成功的响应
+  - 带指纹的静态资源（/static/，响应头含 immutable）: 缓存优先
+  - 订单状态查询、WebSocket等其他请求不经过缓存，始终反映最新状态
+
+使用示例:
+  由 /pay-sw.js 从根路径提供（作用域需覆盖 /pay 和 /submit），支付页面内注册:
+  navigator.serviceWorker.register('/pay-sw.js');
+*/
+
+'use strict';
+
+const CACHE_PREFIX = 'alimpay-pay-';
+const CACHE_NAME = CACHE_PREFIX + 'v1';
+const PAGE_PATHS = ['/pay', '/submit', '/qrcode'];
+const MAX_PAGE_ENTRIES = 20;
+
+// 新版本安装后立即接管，避免旧脚本继续轮询已变更的接口
+self.addEventListener('install', function() {
+    self.skipWaiting();
+});
+
+self.addEventListener('activate', function(event) {
+    event.waitUntil(
+        caches.keys()
+            .then(keys => Promise.all(keys
+                .filter(key => key.startsWith(CACHE_PREFIX) && key !== CACHE_NAME)
+                .map(key => caches.delete(key))))
+            .then(() => self.clients.claim())
+    );
+});
+
+self.addEventListener('fetch', function(event) {
+    const request = event.request;
+    if (request.method !== 'GET') {
+        return;
+    }
+
+    const url = new URL(request.url);
+    if (url.origin !== self.location.origin) {
+        return;
+    }
+
+    if (url.pathname.startsWith('/static/')) {
+        event.respondWith(cacheFirst(request));
+    } else if (PAGE_PATHS.includes(url.pathname)) {
+        event.respondWith(networkFirst(request));
+    }
+});
+
+/*
+networkFirst 网络优先
+  - 请求成功: 缓存响应并返回
+  - 请求失败: 返回最近一次缓存的响应，没有缓存时返回离线提示页
+*/
+async function networkFirst(request) {
+    const cache = await caches.open(CACHE_NAME);
+    try {
+        const response = await fetch(request);
+        if (response.ok) {
+            await cache.put(request, response.clone());
+            await trimCache(cache);
+        }
+        return response;
+    } catch (err) {
+        const cached = await cache.match(request);
+        if (cached) {
+            return cached;
+        }
+        return offlineResponse(request);
+    }
+}
+
+/*
+cacheFirst 缓存优先（仅缓存带 immutable 的响应，原始地址的资源内容可能随升级变化）
+*/
+async function cacheFirst(request) {
+    const cache = await caches.open(CACHE_NAME);
+    const cached = await cache.match(request);
+    if (cached) {
+        return cached;
+    }
+
+    const response = await fetch(request);
+    const cacheControl = response.headers.get('Cache-Control') || '';
+    if (response.ok && cacheControl.includes('immutable')) {
+        await cache.put(request, response.clone());
+    }
+    return response;
+}
+
+/*
+trimCache 限制页面缓存数量（按写入顺序删除最早的条目，带指纹的静态资源不计入）
+*/
+async function trimCache(cache) {
+    const keys = await cache.keys();
+    const pages = keys.filter(request => !new URL(request.url).pathname.startsWith('/static/'));
+    for (let i = 0; i < pages.length - MAX_PAGE_ENTRIES; i++) {
+        await cache.delete(pages[i]);
+    }
+}
+
+/*
+offlineResponse 无缓存时的离线提示
+*/
+function offlineResponse(request) {
+    if (request.mode !== 'navigate') {
+        return new Response('', { status: 503, statusText: 'Offline' });
+    }
+
+    const html = '<!DOCTYPE html><html lang="zh-CN"><head><meta charset="UTF-8">' +
+        '<meta name="viewport" content="width=device-width, initial-scale=1.0">' +
+        '<title>网络连接已断开</title></head>' +
+        '<body style="font-family: sans-serif; text-align: center; padding: 60px 20px; color: #333;">' +
+        '<h2>网络连接已断开</h2><p>如已完成支付，请勿重复付款。网络恢复后将自动刷新。</p>' +
+        '<script>window.addEventListener("online", function() { location.reload(); });</script>' +
+        '</body></html>';
+    return new Response(html, {
+        status: 503,
+        headers: { 'Content-Type': 'text/html; charset=utf-8' }
+    });
+}
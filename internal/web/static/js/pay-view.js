@@ -0,0 +1,250 @@
+/*
+支付页面数据绑定脚本（pay.html、submit.html 共用）
+功能:
+  - 首屏由服务端按 PayView 渲染，未启用JavaScript时页面同样可用
+  - 定期轮询 GET /api/order/status（只返回状态，支持ETag/304），状态变化时请求 GET /api/pay/order
+    刷新订单数据、支付提示和倒计时，按绑定属性更新页面
+  - 倒计时使用服务器计算的剩余秒数（expires_in），不受客户端时钟和时区影响
+  - 断网提示，网络恢复或页面重新可见（从支付宝APP切换回来）时立即刷新
+  - 注册支付页面 Service Worker（/pay-sw.js）
+
+模板约定（主题、多语言只需修改模板和样式，无需调整处理器和脚本）:
+  - 根元素 [data-pay-view]，属性 data-trade-no、data-status、data-expires-in、data-keep-open（订单不自动关闭，不计算过期）；
+    脚本更新 data-status（0待支付 1已支付 2已关闭 3已退款 4已过期）和 data-expired，样式可按属性切换状态
+  - [data-field="字段名"]: 显示接口返回的字段，data-format="amount" 时保留两位小数
+  - [data-if="字段名"]: 字段为真时显示，否则隐藏
+  - [data-list="字段名"]: 按数组字段重建列表项（li）
+  - [data-countdown]: 剩余支付时间（mm:ss，超时后显示 data-expired-text，默认"已过期"）
+  - 根元素 data-offline-text: 断网提示文本（多语言页面按语言设置，默认为中文）
+
+使用示例:
+  <script src="{{asset "js/pay-view.js"}}"></script>
+  PayView.init({
+      poll: true,                       // 是否定期轮询（页面自行维护WebSocket时可设为false，需要时调用 PayView.check()）
+      onPaid: view => { ... }           // 支付成功回调，默认跳转 return_url
+  });
+  document.querySelector('[data-pay-view]').addEventListener('payview:update', e => console.log(e.detail));
+*/
+
+(function() {
+    'use strict';
+
+    const API_PATH = '/api/pay/order';
+    const STATUS_PATH = '/api/order/status';
+    const POLL_INTERVAL = 3000;
+    const STATUS_PENDING = 0;
+    const STATUS_PAID = 1;
+
+    const PayView = {
+        root: null,
+        view: null,
+        options: {},
+        expireAt: 0,
+        done: false,
+        pollTimer: null,
+        countdownTimer: null,
+
+        /*
+        init 初始化（读取服务端渲染的初始状态，启动倒计时和轮询）
+        */
+        init(options) {
+            this.root = document.querySelector('[data-pay-view]');
+            if (!this.root) {
+                return this;
+            }
+            this.options = Object.assign({ poll: true, onPaid: null }, options);
+
+            const status = parseInt(this.root.dataset.status, 10) || STATUS_PENDING;
+            this.done = status !== STATUS_PENDING;
+            this.setExpiresIn(parseInt(this.root.dataset.expiresIn, 10) || 0);
+            this.countdownTimer = setInterval(() => this.renderCountdown(), 1000);
+
+            this.updateNetworkBanner();
+            window.addEventListener('offline', () => this.updateNetworkBanner());
+            window.addEventListener('online', () => {
+                this.updateNetworkBanner();
+                this.refresh();
+            });
+            document.addEventListener('visibilitychange', () => {
+                if (document.visibilityState === 'visible') {
+                    this.refresh();
+                }
+            });
+
+            if (this.options.poll && !this.done) {
+                this.startPolling();
+            }
+
+            // 网络短暂中断时刷新页面仍可显示二维码和倒计时
+            if ('serviceWorker' in navigator) {
+                navigator.serviceWorker.register('/pay-sw.js')
+                    .catch(err => console.warn('[SW] Registration failed:', err));
+            }
+            return this;
+        },
+
+        startPolling() {
+            if (!this.pollTimer && !this.done) {
+                this.check();
+                this.pollTimer = setInterval(() => this.check(), POLL_INTERVAL);
+            }
+        },
+
+        stopPolling() {
+            clearInterval(this.pollTimer);
+            this.pollTimer = null;
+        },
+
+        /*
+        check 轮询订单状态（浏览器按ETag重新验证，状态未变化时服务器只返回304），状态变化时刷新页面数据
+        */
+        check() {
+            if (!this.root || this.done || !navigator.onLine) {
+                return Promise.resolve(this.view);
+            }
+            const tradeNo = this.root.dataset.tradeNo;
+            return fetch(`${STATUS_PATH}?trade_no=${encodeURIComponent(tradeNo)}`, { cache: 'no-cache' })
+                .then(res => res.ok ? res.json() : null)
+                .then(data => {
+                    if (data && data.status !== parseInt(this.root.dataset.status, 10)) {
+                        return this.refresh();
+                    }
+                    return this.view;
+                })
+                .catch(err => {
+                    console.warn('[PayView] Status check failed:', err);
+                    return this.view;
+                });
+        },
+
+        /*
+        refresh 查询最新订单数据并渲染（断网或订单已结束时跳过）
+        */
+        refresh() {
+            if (!this.root || this.done || !navigator.onLine) {
+                return Promise.resolve(this.view);
+            }
+            const tradeNo = this.root.dataset.tradeNo;
+            return fetch(`${API_PATH}?trade_no=${encodeURIComponent(tradeNo)}`, { cache: 'no-store' })
+                .then(res => res.json())
+                .then(data => {
+                    if (data.code === 1 && data.order) {
+                        this.render(data.order);
+                    }
+                    return this.view;
+                })
+                .catch(err => {
+                    console.warn('[PayView] Refresh failed:', err);
+                    return this.view;
+                });
+        },
+
+        /*
+        render 按绑定属性渲染订单数据
+        */
+        render(view) {
+            this.view = view;
+            this.root.dataset.status = view.status;
+            this.root.dataset.keepOpen = view.keep_open;
+            this.setExpiresIn(view.expires_in);
+
+            this.root.querySelectorAll('[data-field]').forEach(el => {
+                const value = view[el.dataset.field];
+                if (value === undefined || value === null) {
+                    return;
+                }
+                el.textContent = el.dataset.format === 'amount' ? Number(value).toFixed(2) : value;
+            });
+            this.root.querySelectorAll('[data-if]').forEach(el => {
+                const value = view[el.dataset.if];
+                el.hidden = !value || (Array.isArray(value) && value.length === 0);
+            });
+            this.root.querySelectorAll('[data-list]').forEach(el => {
+                const items = view[el.dataset.list];
+                if (!Array.isArray(items)) {
+                    return;
+                }
+                el.replaceChildren(...items.map(item => {
+                    const li = document.createElement('li');
+                    li.textContent = item;
+                    return li;
+                }));
+            });
+
+            this.root.dispatchEvent(new CustomEvent('payview:update', { detail: view }));
+
+            if (view.status !== STATUS_PENDING) {
+                this.finish(view);
+            }
+        },
+
+        /*
+        finish 订单已结束（支付成功、关闭、过期）：停止轮询，支付成功时跳转
+        */
+        finish(view) {
+            if (this.done) {
+                return;
+            }
+            this.done = true;
+            this.stopPolling();
+            clearInterval(this.countdownTimer);
+            this.renderCountdown();
+
+            if (view.status !== STATUS_PAID) {
+                return;
+            }
+            this.root.dispatchEvent(new CustomEvent('payview:paid', { detail: view }));
+            if (typeof this.options.onPaid === 'function') {
+                this.options.onPaid(view);
+            } else if (view.return_url) {
+                setTimeout(() => { window.location.href = view.return_url; }, 2000);
+            }
+        },
+
+        setExpiresIn(seconds) {
+            this.expireAt = Date.now() + Math.max(0, seconds) * 1000;
+            this.renderCountdown();
+        },
+
+        renderCountdown() {
+            const timeLeft = Math.max(0, Math.floor((this.expireAt - Date.now()) / 1000));
+            const keepOpen = this.root.dataset.keepOpen === 'true';
+            const expired = !keepOpen && timeLeft <= 0 && parseInt(this.root.dataset.status, 10) === STATUS_PENDING;
+            this.root.dataset.expired = expired;
+
+            this.root.querySelectorAll('[data-countdown]').forEach(el => {
+                if (this.done) {
+                    el.textContent = el.dataset.doneText || '--:--';
+                } else if (expired) {
+                    el.textContent = el.dataset.expiredText || '已过期';
+                } else {
+                    el.textContent = `${String(Math.floor(timeLeft / 60)).padStart(2, '0')}:${String(timeLeft % 60).padStart(2, '0')}`;
+                }
+            });
+        },
+
+        /*
+        updateNetworkBanner 断网时在页面顶部显示提示，恢复后移除
+        */
+        updateNetworkBanner() {
+            let banner = document.getElementById('networkBanner');
+            if (navigator.onLine) {
+                if (banner) banner.remove();
+                return;
+            }
+            if (!banner) {
+                banner = document.createElement('div');
+                banner.id = 'networkBanner';
+                banner.setAttribute('role', 'alert');
+                banner.textContent = this.root.dataset.offlineText || '网络连接已断开，恢复后将自动继续检测支付状态';
+                banner.style.cssText = `
+                    position: fixed; top: 0; left: 0; right: 0; padding: 8px 16px;
+                    background: #faad14; color: white; font-size: 13px; text-align: center; z-index: 10001;
+                `;
+                document.body.appendChild(banner);
+            }
+        }
+    };
+
+    window.PayView = PayView;
+})();
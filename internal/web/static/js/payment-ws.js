@@ -126,6 +126,16 @@
             const data = JSON.parse(event.data);
             console.log('[Payment WS] Received:', data);
 
+            // 服务平滑升级：先连接新进程再关闭旧连接，订阅不中断
+            if (data.type === 'reconnect') {
+                const previous = state.ws;
+                previous.onclose = null;
+                state.ws = null;
+                connectWebSocket();
+                previous.close();
+                return;
+            }
+
             if (data.type === 'status_update' && data.order_id === state.orderId) {
                 if (data.status === 1 && !state.paid) {
                     handlePaymentSuccess(data);
@@ -147,6 +157,12 @@
             return;
         }
 
+        // 服务平滑重启（1012）：新进程已在接受连接，立即重连且不提示
+        if (event.code === 1012) {
+            setTimeout(connectWebSocket, 200);
+            return;
+        }
+
         if (state.reconnectAttempts < CONFIG.WS_RECONNECT_ATTEMPTS) {
             state.reconnectAttempts++;
             const delay = Math.min(
@@ -5,8 +5,10 @@ package web
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
 	"io/fs"
+	"path/filepath"
 )
 
 // Templates 嵌入所有HTML模板文件
@@ -21,12 +23,59 @@ var Templates embed.FS
 //go:embed static/css/*.css static/js/*.js
 var Static embed.FS
 
+// OpenAPISpec 嵌入OpenAPI接口描述文档
+// @description 由 /openapi.json 接口对外提供，可用于生成客户端SDK
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// PayServiceWorker 嵌入支付页面的Service Worker脚本
+// @description 由 /pay-sw.js 从根路径提供，作用域覆盖 /pay、/submit 支付页面
+//
+//go:embed static/js/pay-sw.js
+var PayServiceWorker []byte
+
+// Branding 支付页面品牌信息
+// @description 模板中通过 brand 函数读取，如 {{$brand := brand}}{{$brand.SiteName}}；
+// 除 SiteName 外留空时页面使用默认内容
+type Branding struct {
+	SiteName       string // 站点名称
+	LogoURL        string // Logo图片地址
+	PrimaryColor   string // 主题色（十六进制颜色）
+	Footer         string // 页脚文字
+	SupportContact string // 客服联系方式
+}
+
 // ParseTemplates 解析所有模板文件
-// @description 从embed.FS中解析HTML模板
+// @description 从embed.FS中解析HTML模板，并注册 asset、brand 等模板函数；
+// 指定自定义模板目录时，目录中的同名 .html 文件替换内置模板，新文件名作为新模板加入
+// @param dir 自定义模板目录，为空时只使用内置模板
+// @param brand 品牌信息
 // @return *template.Template 解析后的模板集合
+// @return []string 被自定义模板替换或新增的模板名称
 // @return error 解析错误
-func ParseTemplates() (*template.Template, error) {
-	return template.ParseFS(Templates, "templates/*.html")
+func ParseTemplates(dir string, brand Branding) (*template.Template, []string, error) {
+	funcs := TemplateFuncs()
+	funcs["brand"] = func() Branding { return brand }
+
+	tmpl, err := template.New("").Funcs(funcs).ParseFS(Templates, "templates/*.html")
+	if err != nil || dir == "" {
+		return tmpl, nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil || len(files) == 0 {
+		return tmpl, nil, err
+	}
+	if tmpl, err = tmpl.ParseFiles(files...); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse custom templates in %s: %w", dir, err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	return tmpl, names, nil
 }
 
 // GetTemplatesFS 获取模板文件系统
@@ -38,9 +87,21 @@ func GetTemplatesFS() (fs.FS, error) {
 }
 
 // GetStaticFS 获取静态文件系统
-// @description 返回一个只包含static目录的文件系统，用于Gin提供静态文件服务
+// @description 返回一个只包含static目录的文件系统
 // @return fs.FS 静态文件系统
 // @return error 错误信息
 func GetStaticFS() (fs.FS, error) {
 	return fs.Sub(Static, "static")
 }
+
+// GetFingerprintedStaticFS 获取支持指纹路径的静态文件系统
+// @description 在 GetStaticFS 基础上同时响应带指纹的文件名（见 AssetURL），用于Gin提供静态文件服务
+// @return fs.FS 静态文件系统
+// @return error 错误信息
+func GetFingerprintedStaticFS() (fs.FS, error) {
+	staticFS, err := GetStaticFS()
+	if err != nil {
+		return nil, err
+	}
+	return fingerprintFS{staticFS}, nil
+}
@@ -0,0 +1,18 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"alimpay-go/internal/pkg/logger"
+)
+
+// TestMain 与主程序一样先初始化日志（未初始化时并发的Worker会同时创建默认logger），只保留致命错误
+func TestMain(m *testing.M) {
+	if err := logger.Init(&logger.Config{Level: "fatal", Output: "stdout"}); err != nil {
+		fmt.Printf("Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets 任务耗时直方图的桶上限（秒），与 Prometheus 客户端默认桶一致
+var LatencyBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram 耗时直方图
+// @description 按 LatencyBuckets 统计耗时分布，并发安全
+type Histogram struct {
+	buckets [len(LatencyBuckets)]atomic.Int64 // 落在各桶内的次数（非累计）
+	count   atomic.Int64                      // 总次数
+	sum     atomic.Int64                      // 总耗时（纳秒）
+}
+
+// Observe 记录一次耗时
+// @param d 耗时
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, le := range LatencyBuckets {
+		if seconds <= le {
+			h.buckets[i].Add(1)
+			break
+		}
+	}
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Snapshot 获取直方图快照
+// @return HistogramSnapshot 快照
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	snapshot := HistogramSnapshot{
+		Buckets: make([]int64, len(LatencyBuckets)),
+		Count:   h.count.Load(),
+		Sum:     time.Duration(h.sum.Load()).Seconds(),
+	}
+	var cumulative int64
+	for i := range LatencyBuckets {
+		cumulative += h.buckets[i].Load()
+		snapshot.Buckets[i] = cumulative
+	}
+	return snapshot
+}
+
+// HistogramSnapshot 直方图快照
+type HistogramSnapshot struct {
+	Buckets []int64 `json:"buckets"` // 耗时不超过 LatencyBuckets[i] 的累计次数
+	Count   int64   `json:"count"`   // 总次数（含超过最大桶的）
+	Sum     float64 `json:"sum"`     // 总耗时（秒）
+}
+
+// Average 平均耗时（秒）
+func (s HistogramSnapshot) Average() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Quantile 按桶估算分位数（秒），超过最大桶时返回最大桶上限
+// @param q 分位（0-1）
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := q * float64(s.Count)
+	for i, cumulative := range s.Buckets {
+		if float64(cumulative) >= rank {
+			return LatencyBuckets[i]
+		}
+	}
+	return LatencyBuckets[len(LatencyBuckets)-1]
+}
+
+// Metrics Worker池任务指标
+// @description 计数和耗时自池创建以来累计，调整大小不清零
+type Metrics struct {
+	Workers     int `json:"workers"`      // 当前Worker数量
+	QueueSize   int `json:"queue_size"`   // 队列容量
+	QueueLength int `json:"queue_length"` // 排队中的任务数
+
+	Submitted int64             `json:"submitted"` // 已进入队列的任务数
+	Completed int64             `json:"completed"` // 执行成功的任务数
+	Failed    int64             `json:"failed"`    // 执行返回错误的任务数
+	Rejected  int64             `json:"rejected"`  // 队列已满或池已停止被拒绝的任务数
+	Persisted int64             `json:"persisted"` // 停止时保存的未执行任务数
+	Dropped   int64             `json:"dropped"`   // 停止或缩小队列时丢弃的未执行任务数
+	Wait      HistogramSnapshot `json:"wait"`      // 排队耗时（进入队列到开始执行）
+	Execution HistogramSnapshot `json:"execution"` // 执行耗时
+}
+
+// queuedTask 队列中的任务（记录入队时间，用于统计排队耗时）
+type queuedTask struct {
+	task     Task
+	queuedAt time.Time
+}
@@ -6,6 +6,8 @@ package worker
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"alimpay-go/internal/pkg/logger"
 
@@ -21,16 +23,41 @@ type Task interface {
 	Execute(ctx context.Context) error
 }
 
+// Persister 可选的任务接口
+// @description 池关闭时超过排空期限仍未执行的任务，实现此接口时调用 Persist 保存（如写入数据库稍后重试），否则丢弃
+type Persister interface {
+	// Persist 保存未执行的任务
+	// @return error 保存错误
+	Persist() error
+}
+
+// DefaultDrainTimeout 默认排空期限
+const DefaultDrainTimeout = 10 * time.Second
+
 // Pool Worker池
 // @description 管理固定数量的Worker goroutine，处理任务队列
 type Pool struct {
 	workerCount int                // Worker数量
-	taskQueue   chan Task          // 任务队列
+	taskQueue   chan queuedTask    // 任务队列
+	retire      chan struct{}      // 缩容时通知Worker退出
+	nextID      int                // 下一个Worker的ID
 	wg          sync.WaitGroup     // 等待组，用于优雅关闭
 	ctx         context.Context    // 上下文
 	cancel      context.CancelFunc // 取消函数
 	started     bool               // 是否已启动
+	stopped     bool               // 是否已停止（停止后不能再启动或调整大小）
 	mu          sync.RWMutex       // 读写锁
+
+	drainTimeout time.Duration // 停止时等待排队任务执行完成的期限
+	persisted    atomic.Int64  // 停止时保存的未执行任务数
+	dropped      atomic.Int64  // 停止或缩小队列时丢弃的未执行任务数
+
+	submitted atomic.Int64 // 已进入队列的任务数
+	completed atomic.Int64 // 执行成功的任务数
+	failed    atomic.Int64 // 执行返回错误的任务数
+	rejected  atomic.Int64 // 被拒绝的任务数
+	wait      Histogram    // 排队耗时
+	execution Histogram    // 执行耗时
 }
 
 // NewPool 创建Worker池
@@ -42,12 +69,25 @@ func NewPool(workerCount, queueSize int) *Pool {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Pool{
 		workerCount: workerCount,
-		taskQueue:   make(chan Task, queueSize),
+		taskQueue:   make(chan queuedTask, queueSize),
+		retire:      make(chan struct{}),
 		ctx:         ctx,
 		cancel:      cancel,
+
+		drainTimeout: DefaultDrainTimeout,
 	}
 }
 
+// SetDrainTimeout 设置排空期限
+// @description Stop 时先等待Worker执行完排队中的任务，超过期限后取消上下文（正在执行的任务收到取消信号），
+// 剩余任务保存或丢弃（见 Persister）；期限为0时不等待
+// @param timeout 排空期限
+func (p *Pool) SetDrainTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.drainTimeout = timeout
+}
+
 // Start 启动Worker池
 // @description 启动所有Worker goroutine开始处理任务
 func (p *Pool) Start() {
@@ -58,12 +98,15 @@ func (p *Pool) Start() {
 		logger.Warn("Worker pool already started")
 		return
 	}
+	if p.stopped {
+		logger.Warn("Worker pool already stopped")
+		return
+	}
 
 	p.started = true
 
 	for i := 0; i < p.workerCount; i++ {
-		p.wg.Add(1)
-		go p.worker(i)
+		p.startWorker()
 	}
 
 	logger.Success("Worker pool started",
@@ -71,6 +114,15 @@ func (p *Pool) Start() {
 		zap.Int("queue_size", cap(p.taskQueue)))
 }
 
+// startWorker 启动一个Worker（调用方需持有锁）
+func (p *Pool) startWorker() {
+	id := p.nextID
+	p.nextID++
+
+	p.wg.Add(1)
+	go p.worker(id)
+}
+
 // worker Worker协程
 // @description 从任务队列中取出任务并执行
 // @param id Worker ID
@@ -80,27 +132,61 @@ func (p *Pool) worker(id int) {
 	logger.Info("Worker started", zap.Int("worker_id", id))
 
 	for {
+		queue := p.queue()
+
 		select {
 		case <-p.ctx.Done():
 			logger.Info("Worker stopped", zap.Int("worker_id", id))
 			return
-		case task, ok := <-p.taskQueue:
+		case <-p.retire:
+			logger.Info("Worker retired", zap.Int("worker_id", id))
+			return
+		case item, ok := <-queue:
 			if !ok {
+				// 队列因调整大小被替换，继续从新队列取任务
+				if p.queue() != queue {
+					continue
+				}
 				logger.Info("Task queue closed, worker exiting",
 					zap.Int("worker_id", id))
 				return
 			}
 
-			// 执行任务
-			if err := task.Execute(p.ctx); err != nil {
-				logger.Error("Task execution failed",
-					zap.Int("worker_id", id),
-					zap.Error(err))
+			// 排空期限已过：不再执行，保存或丢弃
+			if p.ctx.Err() != nil {
+				p.abandon(item.task)
+				continue
 			}
+
+			p.execute(id, item)
 		}
 	}
 }
 
+// execute 执行任务并记录排队和执行耗时
+func (p *Pool) execute(id int, item queuedTask) {
+	start := time.Now()
+	p.wait.Observe(start.Sub(item.queuedAt))
+
+	err := item.task.Execute(p.ctx)
+	p.execution.Observe(time.Since(start))
+	if err != nil {
+		p.failed.Add(1)
+		logger.Error("Task execution failed",
+			zap.Int("worker_id", id),
+			zap.Error(err))
+		return
+	}
+	p.completed.Add(1)
+}
+
+// queue 获取当前任务队列
+func (p *Pool) queue() chan queuedTask {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.taskQueue
+}
+
 // Submit 提交任务到队列
 // @description 将任务添加到任务队列，由Worker池处理
 // @param task 要执行的任务
@@ -109,19 +195,27 @@ func (p *Pool) Submit(task Task) error {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	if p.stopped {
+		p.rejected.Add(1)
+		return ErrPoolStopped
+	}
 	if !p.started {
 		logger.Error("Cannot submit task: worker pool not started")
+		p.rejected.Add(1)
 		return ErrPoolNotStarted
 	}
 
 	select {
 	case <-p.ctx.Done():
+		p.rejected.Add(1)
 		return ErrPoolStopped
-	case p.taskQueue <- task:
+	case p.taskQueue <- queuedTask{task: task, queuedAt: time.Now()}:
+		p.submitted.Add(1)
 		return nil
 	default:
 		// 队列已满，记录警告
 		logger.Warn("Task queue is full, task rejected")
+		p.rejected.Add(1)
 		return ErrQueueFull
 	}
 }
@@ -131,16 +225,101 @@ func (p *Pool) Submit(task Task) error {
 // @param task 要执行的任务
 // @return bool 是否成功提交
 func (p *Pool) TrySubmit(task Task) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// 停止后队列已关闭
+	if p.stopped {
+		p.rejected.Add(1)
+		return false
+	}
+
 	select {
-	case p.taskQueue <- task:
+	case p.taskQueue <- queuedTask{task: task, queuedAt: time.Now()}:
+		p.submitted.Add(1)
 		return true
 	default:
+		p.rejected.Add(1)
 		return false
 	}
 }
 
+// Resize 调整Worker数量和队列大小
+// @description 运行时调整，不中断正在执行的任务。缩小队列时超出新容量的排队任务会被丢弃
+// （监听任务会在下个周期重新提交）；减少的Worker在完成当前任务后退出
+// @param workerCount Worker数量
+// @param queueSize 任务队列大小
+// @return error 参数无效时返回错误
+func (p *Pool) Resize(workerCount, queueSize int) error {
+	if workerCount <= 0 || queueSize <= 0 {
+		return ErrInvalidSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped || p.ctx.Err() != nil {
+		return ErrPoolStopped
+	}
+
+	if queueSize != cap(p.taskQueue) {
+		old := p.taskQueue
+		p.taskQueue = make(chan queuedTask, queueSize)
+
+		// 迁移排队中的任务
+		dropped := 0
+	drain:
+		for {
+			select {
+			case task := <-old:
+				select {
+				case p.taskQueue <- task:
+				default:
+					dropped++
+				}
+			default:
+				break drain
+			}
+		}
+		close(old)
+
+		if dropped > 0 {
+			p.dropped.Add(int64(dropped))
+			logger.Warn("Queued tasks dropped while shrinking task queue", zap.Int("dropped", dropped))
+		}
+	}
+
+	if p.started {
+		for i := p.workerCount; i < workerCount; i++ {
+			p.startWorker()
+		}
+		if workerCount < p.workerCount {
+			go p.retireWorkers(p.workerCount - workerCount)
+		}
+	}
+
+	logger.Info("Worker pool resized",
+		zap.Int("worker_count", workerCount),
+		zap.Int("queue_size", queueSize))
+
+	p.workerCount = workerCount
+	return nil
+}
+
+// retireWorkers 通知指定数量的Worker退出
+func (p *Pool) retireWorkers(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case p.retire <- struct{}{}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
 // Stop 停止Worker池
-// @description 停止接收新任务，等待所有Worker完成当前任务后退出
+// @description 停止接收新任务，在排空期限内等待Worker执行完排队中的任务；超过期限后取消上下文，
+// 等待正在执行的任务退出，剩余任务保存或丢弃（见 Persister）
 func (p *Pool) Stop() {
 	p.mu.Lock()
 	if !p.started {
@@ -148,34 +327,123 @@ func (p *Pool) Stop() {
 		return
 	}
 	p.started = false
+	p.stopped = true
+	queue := p.taskQueue
+	timeout := p.drainTimeout
+
+	// 关闭任务队列：Worker执行完剩余任务后退出
+	close(queue)
 	p.mu.Unlock()
 
-	logger.Info("Stopping worker pool...")
+	logger.Info("Stopping worker pool, draining queued tasks...",
+		zap.Int("queued", len(queue)),
+		zap.Duration("drain_timeout", timeout))
 
-	// 取消上下文，通知所有Worker
-	p.cancel()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
 
-	// 关闭任务队列
-	close(p.taskQueue)
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Warn("Worker pool drain deadline exceeded, cancelling running tasks",
+			zap.Int("queued", len(queue)))
+		// 取消上下文，通知正在执行的任务
+		p.cancel()
+		<-done
+	}
+	p.cancel()
 
-	// 等待所有Worker完成
-	p.wg.Wait()
+	// 未执行的任务
+	for item := range queue {
+		p.abandon(item.task)
+	}
 
+	if persisted, dropped := p.persisted.Load(), p.dropped.Load(); persisted > 0 || dropped > 0 {
+		logger.Warn("Worker pool stopped with unfinished tasks",
+			zap.Int64("persisted", persisted),
+			zap.Int64("dropped", dropped))
+		return
+	}
 	logger.Success("Worker pool stopped")
 }
 
+// abandon 保存或丢弃未执行的任务
+func (p *Pool) abandon(task Task) {
+	persister, ok := task.(Persister)
+	if !ok {
+		p.dropped.Add(1)
+		return
+	}
+	if err := persister.Persist(); err != nil {
+		logger.Error("Failed to persist unfinished task", zap.Error(err))
+		p.dropped.Add(1)
+		return
+	}
+	p.persisted.Add(1)
+}
+
+// Metrics 获取任务指标
+// @description 返回自池创建以来的任务计数和耗时分布
+// @return Metrics 任务指标
+func (p *Pool) Metrics() Metrics {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return Metrics{
+		Workers:     p.workerCount,
+		QueueSize:   cap(p.taskQueue),
+		QueueLength: len(p.taskQueue),
+
+		Submitted: p.submitted.Load(),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+		Rejected:  p.rejected.Load(),
+		Persisted: p.persisted.Load(),
+		Dropped:   p.dropped.Load(),
+		Wait:      p.wait.Snapshot(),
+		Execution: p.execution.Snapshot(),
+	}
+}
+
 // GetStats 获取池统计信息
-// @description 返回Worker池的当前状态统计
+// @description 返回Worker池的当前状态和任务指标（耗时为秒）
 // @return map[string]interface{} 统计信息
 func (p *Pool) GetStats() map[string]interface{} {
+	metrics := p.Metrics()
+
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	return map[string]interface{}{
-		"worker_count": p.workerCount,
-		"queue_size":   cap(p.taskQueue),
-		"queue_length": len(p.taskQueue),
+		"worker_count": metrics.Workers,
+		"queue_size":   metrics.QueueSize,
+		"queue_length": metrics.QueueLength,
 		"started":      p.started,
+		"stopped":      p.stopped,
+		"tasks": map[string]int64{
+			"submitted": metrics.Submitted,
+			"completed": metrics.Completed,
+			"failed":    metrics.Failed,
+			"rejected":  metrics.Rejected,
+			"persisted": metrics.Persisted,
+			"dropped":   metrics.Dropped,
+		},
+		"wait_seconds":      latencySummary(metrics.Wait),
+		"execution_seconds": latencySummary(metrics.Execution),
+	}
+}
+
+// latencySummary 耗时摘要（平均值和按桶估算的分位数）
+func latencySummary(s HistogramSnapshot) map[string]interface{} {
+	return map[string]interface{}{
+		"count": s.Count,
+		"avg":   s.Average(),
+		"p50":   s.Quantile(0.5),
+		"p95":   s.Quantile(0.95),
+		"p99":   s.Quantile(0.99),
 	}
 }
 
@@ -184,6 +452,7 @@ var (
 	ErrPoolNotStarted = &PoolError{"worker pool not started"}
 	ErrPoolStopped    = &PoolError{"worker pool stopped"}
 	ErrQueueFull      = &PoolError{"task queue is full"}
+	ErrInvalidSize    = &PoolError{"worker count and queue size must be positive"}
 )
 
 // PoolError Worker池错误
@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// drainTask 记录执行情况的测试任务：执行 delay 后完成，或在上下文取消时提前返回
+type drainTask struct {
+	delay     time.Duration
+	executed  *atomic.Int32
+	cancelled *atomic.Int32
+}
+
+// Execute 执行任务
+func (t *drainTask) Execute(ctx context.Context) error {
+	select {
+	case <-time.After(t.delay):
+		t.executed.Add(1)
+		return nil
+	case <-ctx.Done():
+		t.cancelled.Add(1)
+		return ctx.Err()
+	}
+}
+
+// persistedDrainTask 可保存的测试任务
+type persistedDrainTask struct {
+	drainTask
+	persisted *atomic.Int32
+}
+
+// Persist 记录保存次数
+func (t *persistedDrainTask) Persist() error {
+	t.persisted.Add(1)
+	return nil
+}
+
+// TestWorkerPoolDrain Worker池停止时在排空期限内执行完排队任务；超过期限后取消正在执行的任务，剩余任务保存或丢弃
+func TestWorkerPoolDrain(t *testing.T) {
+	var executed, cancelled, persisted atomic.Int32
+
+	// 排空期限内执行完全部排队任务，不取消
+	pool := NewPool(1, 10)
+	pool.SetDrainTimeout(5 * time.Second)
+	pool.Start()
+	for i := 0; i < 4; i++ {
+		if err := pool.Submit(&drainTask{delay: 20 * time.Millisecond, executed: &executed, cancelled: &cancelled}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.Stop()
+	if executed.Load() != 4 || cancelled.Load() != 0 {
+		t.Fatalf("drained pool executed %d, cancelled %d tasks, want 4 executed", executed.Load(), cancelled.Load())
+	}
+	if err := pool.Submit(&drainTask{executed: &executed, cancelled: &cancelled}); err != ErrPoolStopped {
+		t.Fatalf("submit after stop = %v, want ErrPoolStopped", err)
+	}
+
+	// 超过排空期限：正在执行的任务收到取消信号，排队任务保存（实现 Persister）或丢弃，不再执行
+	executed.Store(0)
+	pool = NewPool(1, 10)
+	pool.SetDrainTimeout(100 * time.Millisecond)
+	pool.Start()
+	if err := pool.Submit(&drainTask{delay: time.Minute, executed: &executed, cancelled: &cancelled}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(&persistedDrainTask{drainTask{delay: time.Minute, executed: &executed, cancelled: &cancelled}, &persisted}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(&drainTask{delay: time.Minute, executed: &executed, cancelled: &cancelled}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	pool.Stop()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("stop took %v despite 100ms drain timeout", elapsed)
+	}
+	if executed.Load() != 0 || cancelled.Load() != 1 || persisted.Load() != 1 {
+		t.Fatalf("after drain deadline executed %d, cancelled %d, persisted %d tasks, want 0, 1, 1",
+			executed.Load(), cancelled.Load(), persisted.Load())
+	}
+}
+
+// failingTask 执行即返回错误的测试任务
+type failingTask struct{}
+
+// Execute 执行任务
+func (failingTask) Execute(ctx context.Context) error {
+	return errors.New("task failed")
+}
+
+// TestPoolMetrics Worker池统计提交、成功、失败、拒绝的任务数和排队、执行耗时
+func TestPoolMetrics(t *testing.T) {
+	var executed, cancelled atomic.Int32
+	pool := NewPool(1, 1)
+	pool.Start()
+	defer pool.Stop()
+
+	// 第一个任务开始执行后，第二个任务排队，第三个任务因队列已满被拒绝
+	if err := pool.Submit(&drainTask{delay: 300 * time.Millisecond, executed: &executed, cancelled: &cancelled}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "first task picked up", func() bool {
+		return pool.Metrics().QueueLength == 0
+	})
+	if err := pool.Submit(failingTask{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(failingTask{}); err != ErrQueueFull {
+		t.Fatalf("submit to full queue = %v, want ErrQueueFull", err)
+	}
+	waitFor(t, "tasks executed", func() bool {
+		m := pool.Metrics()
+		return m.Completed+m.Failed == 2
+	})
+
+	m := pool.Metrics()
+	if m.Submitted != 2 || m.Completed != 1 || m.Failed != 1 || m.Rejected != 1 {
+		t.Fatalf("metrics = %+v, want 2 submitted, 1 completed, 1 failed, 1 rejected", m)
+	}
+	if m.Execution.Count != 2 || m.Wait.Count != 2 {
+		t.Fatalf("latency counts = %d/%d, want 2", m.Execution.Count, m.Wait.Count)
+	}
+	// 第二个任务排队等待第一个任务执行完
+	if m.Wait.Quantile(1) < 0.05 || m.Execution.Sum < 0.05 {
+		t.Fatalf("latency = wait p100 %vs, execution sum %vs, want at least 50ms", m.Wait.Quantile(1), m.Execution.Sum)
+	}
+	stats := pool.GetStats()
+	if tasks, _ := stats["tasks"].(map[string]int64); tasks["rejected"] != 1 {
+		t.Fatalf("GetStats tasks = %v, want 1 rejected", stats["tasks"])
+	}
+}
+
+// waitFor 轮询直到条件满足，10秒内不满足时测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: condition not met within 10s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}